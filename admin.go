@@ -0,0 +1,44 @@
+package main
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// adminToken authorizes admin-only routes; they are disabled while it is empty
+var adminToken = os.Getenv("ADMIN_TOKEN")
+
+// isAdmin reports whether the request carries the admin bearer token
+func isAdmin(c *gin.Context) bool {
+	token := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+	return adminToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(adminToken)) == 1
+}
+
+// requireAdmin rejects requests without the admin bearer token
+func requireAdmin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !isAdmin(c) {
+			abortWithError(c, &APIError{
+				Status:  http.StatusForbidden,
+				Code:    CodeAdminRequired,
+				Message: "Admin access required",
+			})
+			return
+		}
+
+		c.Set("actor", "admin")
+		c.Next()
+	}
+}
+
+// currentActor names who is performing the request, for the audit log
+func currentActor(c *gin.Context) string {
+	if actor := c.GetString("actor"); actor != "" {
+		return actor
+	}
+	return "anonymous"
+}
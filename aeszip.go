@@ -0,0 +1,204 @@
+package main
+
+import (
+	"archive/zip"
+	"compress/flate"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/binary"
+	"hash"
+	"io"
+	"os"
+	"time"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// Password-protected ZIP entries use WinZip's AES-256 encryption (AE-2),
+// which 7-Zip, WinZip, WinRAR and libarchive open; the legacy ZipCrypto
+// cipher is broken and never written
+const (
+	aesZipMethod     = 99     // compression method of AES encrypted entries
+	aesZipExtraID    = 0x9901 // extra field describing the encryption
+	aesZipVersion    = 51     // ZIP version needed to extract them
+	aesZipSaltLen    = 16
+	aesZipKeyLen     = 32
+	aesZipAuthLen    = 10
+	aesZipIterations = 1000 // PBKDF2 iterations, fixed by the format
+)
+
+// minExportPasswordLength is the shortest password accepted for an export
+const minExportPasswordLength = 8
+
+// aesZipEntry is a ZIP entry deflated and encrypted into a temporary file.
+// Entries are spooled because ZIP headers carry their sizes, so a failed
+// entry can still be reported before anything is sent.
+type aesZipEntry struct {
+	file      *os.File
+	size      int64
+	plainSize int64
+}
+
+// newAESZipEntry encrypts what write writes with password
+func newAESZipEntry(password string, write func(w io.Writer) error) (*aesZipEntry, error) {
+	file, err := os.CreateTemp("", "export-*.zip")
+	if err != nil {
+		return nil, err
+	}
+	entry := &aesZipEntry{file: file}
+	if err := entry.encrypt(password, write); err != nil {
+		entry.Close()
+		return nil, err
+	}
+	return entry, nil
+}
+
+// encrypt writes the salt, password verifier, encrypted data and
+// authentication code of the entry to its file
+func (e *aesZipEntry) encrypt(password string, write func(w io.Writer) error) error {
+	salt := make([]byte, aesZipSaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return err
+	}
+	keys := pbkdf2.Key([]byte(password), salt, aesZipIterations, 2*aesZipKeyLen+2, sha1.New)
+	block, err := aes.NewCipher(keys[:aesZipKeyLen])
+	if err != nil {
+		return err
+	}
+	if _, err := e.file.Write(append(salt, keys[2*aesZipKeyLen:]...)); err != nil {
+		return err
+	}
+
+	ctr := &aesZipWriter{block: block, mac: hmac.New(sha1.New, keys[aesZipKeyLen:2*aesZipKeyLen]), w: e.file, used: aes.BlockSize}
+	fw, err := flate.NewWriter(ctr, flate.DefaultCompression)
+	if err != nil {
+		return err
+	}
+	plain := &countingWriter{w: fw}
+	if err := write(plain); err != nil {
+		return err
+	}
+	if err := fw.Close(); err != nil {
+		return err
+	}
+	if _, err := e.file.Write(ctr.mac.Sum(nil)[:aesZipAuthLen]); err != nil {
+		return err
+	}
+
+	e.plainSize = plain.n
+	e.size, err = e.file.Seek(0, io.SeekCurrent)
+	return err
+}
+
+// addTo copies the entry into zw as name
+func (e *aesZipEntry) addTo(zw *zip.Writer, name string) error {
+	if _, err := e.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	// AE-2 leaves the CRC out, as it would reveal something of the data;
+	// the authentication code protects it instead
+	extra := make([]byte, 11)
+	binary.LittleEndian.PutUint16(extra[0:], aesZipExtraID)
+	binary.LittleEndian.PutUint16(extra[2:], 7)
+	binary.LittleEndian.PutUint16(extra[4:], 2) // AE-2
+	copy(extra[6:], "AE")
+	extra[8] = 3 // AES-256
+	binary.LittleEndian.PutUint16(extra[9:], zip.Deflate)
+
+	modifiedDate, modifiedTime := dosTime(time.Now())
+	w, err := zw.CreateRaw(&zip.FileHeader{
+		Name:               name,
+		CreatorVersion:     aesZipVersion,
+		ReaderVersion:      aesZipVersion,
+		Flags:              0x1, // encrypted
+		Method:             aesZipMethod,
+		ModifiedDate:       modifiedDate,
+		ModifiedTime:       modifiedTime,
+		CompressedSize64:   uint64(e.size),
+		UncompressedSize64: uint64(e.plainSize),
+		Extra:              extra,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, e.file)
+	return err
+}
+
+// Close removes the entry's temporary file
+func (e *aesZipEntry) Close() error {
+	e.file.Close()
+	return os.Remove(e.file.Name())
+}
+
+// addZipEntry adds name to zw, written by write and encrypted with password
+// unless it is empty
+func addZipEntry(zw *zip.Writer, name, password string, write func(w io.Writer) error) error {
+	if password == "" {
+		w, err := zw.Create(name)
+		if err != nil {
+			return err
+		}
+		return write(w)
+	}
+	entry, err := newAESZipEntry(password, write)
+	if err != nil {
+		return err
+	}
+	defer entry.Close()
+	return entry.addTo(zw, name)
+}
+
+// aesZipWriter encrypts with AES in WinZip's CTR mode, whose counter is
+// little endian and starts at 1, and authenticates the ciphertext
+type aesZipWriter struct {
+	block   cipher.Block
+	mac     hash.Hash
+	w       io.Writer
+	counter [aes.BlockSize]byte
+	stream  [aes.BlockSize]byte
+	used    int // bytes of stream used
+}
+
+// Write implements io.Writer
+func (e *aesZipWriter) Write(p []byte) (int, error) {
+	out := make([]byte, len(p))
+	for i, b := range p {
+		if e.used == aes.BlockSize {
+			for j := range e.counter {
+				if e.counter[j]++; e.counter[j] != 0 {
+					break
+				}
+			}
+			e.block.Encrypt(e.stream[:], e.counter[:])
+			e.used = 0
+		}
+		out[i] = b ^ e.stream[e.used]
+		e.used++
+	}
+	e.mac.Write(out)
+	return e.w.Write(out)
+}
+
+// countingWriter counts the bytes written through it
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+// Write implements io.Writer
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// dosTime converts t to the MS-DOS date and time of ZIP headers
+func dosTime(t time.Time) (uint16, uint16) {
+	date := uint16((t.Year()-1980)<<9 | int(t.Month())<<5 | t.Day())
+	clock := uint16(t.Hour()<<11 | t.Minute()<<5 | t.Second()/2)
+	return date, clock
+}
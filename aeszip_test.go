@@ -0,0 +1,124 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/flate"
+	"crypto/aes"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// openAESZipFile decrypts an AE-2 entry as an unzip tool would
+func openAESZipFile(f *zip.File, password string) ([]byte, error) {
+	if f.Method != aesZipMethod || f.Flags&0x1 == 0 {
+		return nil, errors.New("entry is not AES encrypted")
+	}
+	r, err := f.OpenRaw()
+	if err != nil {
+		return nil, err
+	}
+	raw, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	salt, verifier := raw[:aesZipSaltLen], raw[aesZipSaltLen:aesZipSaltLen+2]
+	data, code := raw[aesZipSaltLen+2:len(raw)-aesZipAuthLen], raw[len(raw)-aesZipAuthLen:]
+
+	keys := pbkdf2.Key([]byte(password), salt, aesZipIterations, 2*aesZipKeyLen+2, sha1.New)
+	if !bytes.Equal(keys[2*aesZipKeyLen:], verifier) {
+		return nil, errors.New("wrong password")
+	}
+	mac := hmac.New(sha1.New, keys[aesZipKeyLen:2*aesZipKeyLen])
+	mac.Write(data)
+	if !hmac.Equal(mac.Sum(nil)[:aesZipAuthLen], code) {
+		return nil, errors.New("authentication failed")
+	}
+
+	block, _ := aes.NewCipher(keys[:aesZipKeyLen])
+	compressed := make([]byte, len(data))
+	var counter, stream [aes.BlockSize]byte
+	for i := 0; i < len(data); i += aes.BlockSize {
+		binary.LittleEndian.PutUint64(counter[:], uint64(i/aes.BlockSize+1))
+		block.Encrypt(stream[:], counter[:])
+		for j := i; j < len(data) && j < i+aes.BlockSize; j++ {
+			compressed[j] = data[j] ^ stream[j-i]
+		}
+	}
+	return io.ReadAll(flate.NewReader(bytes.NewReader(compressed)))
+}
+
+func TestAESZip(t *testing.T) {
+	// Long enough to span many counter blocks, written in odd-sized pieces
+	content := strings.Repeat("respondent,answer\n", 1000)
+	var b bytes.Buffer
+	zw := zip.NewWriter(&b)
+	for _, name := range []string{"plain.csv", "secret.csv"} {
+		password := ""
+		if name == "secret.csv" {
+			password = "correct horse"
+		}
+		err := addZipEntry(zw, name, password, func(w io.Writer) error {
+			for _, line := range strings.SplitAfter(content, "\n") {
+				if _, err := io.WriteString(w, line); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, zw.Close())
+
+	archive, err := zip.NewReader(bytes.NewReader(b.Bytes()), int64(b.Len()))
+	assert.NoError(t, err)
+	plain, secret := archive.File[0], archive.File[1]
+	r, err := plain.Open()
+	assert.NoError(t, err)
+	read, _ := io.ReadAll(r)
+	assert.Equal(t, content, string(read))
+
+	assert.Equal(t, "secret.csv", secret.Name)
+	assert.Equal(t, uint16(aesZipMethod), secret.Method)
+	assert.Equal(t, uint64(len(content)), secret.UncompressedSize64)
+	assert.Less(t, secret.CompressedSize64, uint64(len(content)/10))
+	assert.Equal(t, []byte{0x01, 0x99, 7, 0, 2, 0, 'A', 'E', 3, 8, 0}, secret.Extra)
+	assert.False(t, secret.Modified.IsZero())
+	assert.NotContains(t, b.String(), "respondent,answer\nrespondent")
+
+	read, err = openAESZipFile(secret, "correct horse")
+	assert.NoError(t, err)
+	assert.Equal(t, content, string(read))
+	_, err = openAESZipFile(secret, "wrong horse")
+	assert.Error(t, err)
+
+	// Tampering is caught by the authentication code
+	offset, _ := secret.DataOffset()
+	tampered := bytes.Clone(b.Bytes())
+	tampered[offset+aesZipSaltLen+5] ^= 1
+	archive, _ = zip.NewReader(bytes.NewReader(tampered), int64(len(tampered)))
+	_, err = openAESZipFile(archive.File[1], "correct horse")
+	assert.EqualError(t, err, "authentication failed")
+}
+
+func TestAESZipEntryFailure(t *testing.T) {
+	// A failed entry is reported, leaving no temporary file behind
+	dir := t.TempDir()
+	t.Setenv("TMPDIR", dir)
+	_, err := newAESZipEntry("correct horse", func(w io.Writer) error {
+		io.WriteString(w, "partial")
+		return errors.New("scan failed")
+	})
+	assert.EqualError(t, err, "scan failed")
+	files, _ := os.ReadDir(dir)
+	assert.Empty(t, files)
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Guardrails protecting the database from expensive analytics queries
+var (
+	// analyticsMaxScanned caps how many responses one analytics query may read
+	analyticsMaxScanned = envInt("ANALYTICS_MAX_SCANNED", 50000)
+	// analyticsFilterThreshold is the survey size above which analytics require a filter
+	analyticsFilterThreshold = envInt("ANALYTICS_FILTER_THRESHOLD", 10000)
+	// analyticsTimeout bounds how long an analytics query may run
+	analyticsTimeout = envDuration("ANALYTICS_TIMEOUT", 5*time.Second)
+)
+
+// analyticsFilterHint tells clients how to narrow an analytics query
+const analyticsFilterHint = "Narrow the query with from/to, channel, country, device, moderation_status, bot_score_min/bot_score_max, exclude_quality or answer[question]"
+
+// envInt reads a positive integer from the environment, falling back to def
+func envInt(name string, def int) int {
+	value := os.Getenv(name)
+	if value == "" {
+		return def
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 {
+		log.Printf("Ignoring %s: invalid number %q", name, value)
+		return def
+	}
+	return n
+}
+
+// parseAnalyticsQuery reads the filters of an analytics endpoint: the metadata
+// and answer filters of response listings plus ?from= and ?to= (RFC 3339) on created_at
+func parseAnalyticsQuery(c *gin.Context, surveyID int) (responseListQuery, []string) {
+	q := responseListQuery{SurveyID: surveyID}
+	params := c.Request.URL.Query()
+	errors := parseMetadataFilters(params, &q)
+	errors = append(errors, parseAnswerFilters(params, &q)...)
+
+	for _, bound := range []struct {
+		param, label string
+		dest         *string
+	}{
+		{"from", "From", &q.CreatedFrom},
+		{"to", "To", &q.CreatedTo},
+	} {
+		value := c.Query(bound.param)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			errors = append(errors, bound.label+" must be an RFC 3339 timestamp")
+			continue
+		}
+		*bound.dest = t.UTC().Format(cursorTimeFormat)
+	}
+
+	// Viewers only see the responses matching their attributes, whatever they filter on
+	scope := viewerScope(c)
+	if len(scope) > 0 && responseDataOpaque() {
+		errors = append(errors, "Viewer analytics are unavailable while response data is encrypted or compressed")
+	}
+	q.Answers = append(q.Answers, scope...)
+
+	return q, errors
+}
+
+// filtered reports whether q narrows a survey's responses at all
+func (q responseListQuery) filtered() bool {
+	return len(q.Metadata) > 0 || len(q.Answers) > 0 || q.BotScoreMin != nil || q.BotScoreMax != nil ||
+		q.QualityFlag != "" || len(q.ExcludeQuality) > 0 || q.CreatedFrom != "" || q.CreatedTo != ""
+}
+
+// checkAnalyticsCost counts the responses q would scan and rejects the request
+// when a large survey is queried unfiltered or the count exceeds the cap
+func checkAnalyticsCost(ctx context.Context, c *gin.Context, q responseListQuery) bool {
+	where, args := q.where()
+	var count int
+	err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM survey_responses sr WHERE "+where, args...).Scan(&count)
+	if err != nil {
+		analyticsQueryFailed(ctx, c, err)
+		return false
+	}
+
+	if !q.filtered() && count > analyticsFilterThreshold {
+		abortWithError(c, &APIError{
+			Status:  http.StatusUnprocessableEntity,
+			Code:    CodeFiltersRequired,
+			Message: "Filters are required for large surveys",
+			Errors: []string{
+				fmt.Sprintf("Survey has %d responses, more than %d can be analyzed unfiltered", count, analyticsFilterThreshold),
+				analyticsFilterHint,
+			},
+		})
+		return false
+	}
+	if count > analyticsMaxScanned {
+		abortWithError(c, &APIError{
+			Status:  http.StatusUnprocessableEntity,
+			Code:    CodeQueryTooLarge,
+			Message: "Query would scan too many responses",
+			Errors: []string{
+				fmt.Sprintf("%d responses match, at most %d can be analyzed at once", count, analyticsMaxScanned),
+				analyticsFilterHint,
+			},
+		})
+		return false
+	}
+	return true
+}
+
+// analyticsQueryFailed reports a failed analytics query, as 503 when it ran out of time
+func analyticsQueryFailed(ctx context.Context, c *gin.Context, err error) {
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		abortWithError(c, &APIError{
+			Status:  http.StatusServiceUnavailable,
+			Code:    CodeAnalyticsTimeout,
+			Message: "Analytics query timed out",
+			Errors:  []string{analyticsFilterHint},
+		})
+		return
+	}
+	abortWithError(c, errInternal("Failed to run analytics query", err))
+}
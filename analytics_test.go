@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAnalyticsGuardrails(t *testing.T) {
+	setupTestDB()
+	defer testDB.Close()
+
+	defer func(threshold, max int, timeout time.Duration) {
+		analyticsFilterThreshold, analyticsMaxScanned, analyticsTimeout = threshold, max, timeout
+	}(analyticsFilterThreshold, analyticsMaxScanned, analyticsTimeout)
+	analyticsFilterThreshold, analyticsMaxScanned = 2, 3
+
+	result, err := testDB.Exec("INSERT INTO surveys (title, description) VALUES (?, ?)", "Test Survey", "Test Description")
+	assert.NoError(t, err)
+	surveyID, _ := result.LastInsertId()
+
+	for _, channel := range []string{"web", "web", "web", "web", "email"} {
+		_, err = testDB.Exec("INSERT INTO survey_responses (survey_id, user_identifier, response_data, channel) VALUES (?, 'user', '{\"rating\":\"5\"}', ?)", surveyID, channel)
+		assert.NoError(t, err)
+	}
+
+	router := setupTestRouter()
+
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", fmt.Sprintf("/api/surveys/%d/%s", surveyID, path), nil)
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// Large surveys need a filter
+	w := get("results")
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Contains(t, w.Body.String(), "Filters are required for large surveys")
+	assert.Contains(t, w.Body.String(), analyticsFilterHint)
+
+	w = get("results?channel=email")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response struct {
+		Data SurveyResults `json:"data"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, 1, response.Data.ResponsesCount)
+	assert.Empty(t, w.Header().Get("X-Cache"))
+
+	// Filters must still narrow the scan below the cap
+	w = get("results?channel=web")
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Contains(t, w.Body.String(), "Query would scan too many responses")
+
+	w = get("questions/rating/answers?channel=web")
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+	w = get("questions/rating/answers?format=json&channel=email&from=2000-01-01T00:00:00Z")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = get("results?channel=email&from=yesterday")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// Queries running out of time are reported as unavailable
+	analyticsTimeout = time.Nanosecond
+	w = get("results?channel=email")
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Contains(t, w.Body.String(), "Analytics query timed out")
+}
+
+func TestAnalyticsCreatedAtFilter(t *testing.T) {
+	setupTestDB()
+	defer testDB.Close()
+
+	result, err := testDB.Exec("INSERT INTO surveys (title, description) VALUES (?, ?)", "Test Survey", "Test Description")
+	assert.NoError(t, err)
+	surveyID, _ := result.LastInsertId()
+
+	_, err = testDB.Exec(`INSERT INTO survey_responses (survey_id, user_identifier, response_data, created_at)
+		VALUES (?, 'old', '{"rating":"1"}', '2020-01-01 00:00:00'), (?, 'new', '{"rating":"5"}', '2024-01-01 00:00:00')`, surveyID, surveyID)
+	assert.NoError(t, err)
+
+	router := setupTestRouter()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", fmt.Sprintf("/api/surveys/%d/results?from=2023-01-01T00:00:00Z&to=2025-01-01T00:00:00Z", surveyID), nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response struct {
+		Data SurveyResults `json:"data"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, 1, response.Data.ResponsesCount)
+	assert.Equal(t, map[string]int{"5": 1}, response.Data.Questions[0].Values)
+}
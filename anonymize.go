@@ -0,0 +1,252 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"os"
+	"regexp"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Anonymization modes
+const (
+	AnonymizeModeHash  = "hash"
+	AnonymizeModeStrip = "strip"
+)
+
+// strippedIdentifier replaces user identifiers in strip mode
+const strippedIdentifier = "anonymous"
+
+// anonymizationKey keys pseudonym hashes so they stay stable across runs.
+// When unset a random key is used per run, so pseudonyms can't be linked.
+var anonymizationKey = os.Getenv("ANONYMIZATION_KEY")
+
+// pseudonymPattern matches values that were already hashed
+var pseudonymPattern = regexp.MustCompile(`^anon_[0-9a-f]{16}$`)
+
+// AnonymizeRequest represents the request body for anonymizing a survey's responses
+type AnonymizeRequest struct {
+	Anonymize struct {
+		Mode   string   `json:"mode"`
+		Fields []string `json:"fields"`
+		DryRun bool     `json:"dry_run"`
+	} `json:"anonymize" binding:"required"`
+}
+
+// AnonymizeReport summarizes what an anonymization changed, or would change on a dry run
+type AnonymizeReport struct {
+	SurveyID              int      `json:"survey_id"`
+	Mode                  string   `json:"mode"`
+	Fields                []string `json:"fields"`
+	DryRun                bool     `json:"dry_run"`
+	ResponsesScanned      int      `json:"responses_scanned"`
+	ResponsesChanged      int      `json:"responses_changed"`
+	IdentifiersAnonymized int      `json:"identifiers_anonymized"`
+	FieldsAnonymized      int      `json:"fields_anonymized"`
+}
+
+// newRespondentToken returns the opaque user_identifier stored for a response to
+// an anonymous survey
+func newRespondentToken() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return "respondent_" + hex.EncodeToString(b)
+}
+
+// anonymizer rewrites identifiers and PII answers of a single run
+type anonymizer struct {
+	mode   string
+	fields []string
+	key    []byte
+}
+
+// pseudonym returns the stable hashed replacement for a value
+func (a anonymizer) pseudonym(value string) string {
+	mac := hmac.New(sha256.New, a.key)
+	mac.Write([]byte(value))
+	return "anon_" + hex.EncodeToString(mac.Sum(nil))[:16]
+}
+
+// identifier returns the anonymized user identifier and whether it changed
+func (a anonymizer) identifier(value string) (string, bool) {
+	if a.mode == AnonymizeModeStrip {
+		return strippedIdentifier, value != strippedIdentifier
+	}
+	if pseudonymPattern.MatchString(value) {
+		return value, false
+	}
+	return a.pseudonym(value), true
+}
+
+// responseData anonymizes the configured fields and returns how many changed
+func (a anonymizer) responseData(data json.RawMessage) (json.RawMessage, int) {
+	var answers map[string]interface{}
+	if len(a.fields) == 0 || json.Unmarshal(data, &answers) != nil {
+		return data, 0
+	}
+
+	changed := 0
+	for _, field := range a.fields {
+		value, ok := answers[field]
+		if !ok {
+			continue
+		}
+		if a.mode == AnonymizeModeStrip {
+			delete(answers, field)
+			changed++
+			continue
+		}
+
+		s, isString := value.(string)
+		if isString && pseudonymPattern.MatchString(s) {
+			continue
+		}
+		if !isString {
+			raw, _ := json.Marshal(value)
+			s = string(raw)
+		}
+		answers[field] = a.pseudonym(s)
+		changed++
+	}
+
+	if changed == 0 {
+		return data, 0
+	}
+	raw, _ := json.Marshal(answers)
+	return raw, changed
+}
+
+// anonymizeSurveyResponses hashes or strips user identifiers and PII answers across a survey
+func anonymizeSurveyResponses(c *gin.Context) {
+	ctx := c.Request.Context()
+	sID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		abortWithError(c, errInvalidID("survey"))
+		return
+	}
+
+	// Check if survey exists
+	var anonymous bool
+	err = db.QueryRowContext(ctx, "SELECT anonymous FROM surveys WHERE id = ?", sID).Scan(&anonymous)
+	if err != nil {
+		abortWithError(c, errNotFound(CodeSurveyNotFound, "Survey not found"))
+		return
+	}
+
+	var req AnonymizeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		abortWithError(c, errInvalidRequest(err))
+		return
+	}
+
+	a := anonymizer{mode: req.Anonymize.Mode, fields: req.Anonymize.Fields, key: []byte(anonymizationKey)}
+	if a.mode == "" {
+		a.mode = AnonymizeModeHash
+	}
+	if a.fields == nil {
+		a.fields = []string{}
+	}
+	if a.mode != AnonymizeModeHash && a.mode != AnonymizeModeStrip {
+		abortWithError(c, errValidation("Failed to anonymize responses", []string{"Mode must be either hash or strip"}))
+		return
+	}
+	if len(a.key) == 0 {
+		a.key = make([]byte, 32)
+		rand.Read(a.key)
+	}
+
+	report := AnonymizeReport{
+		SurveyID: sID,
+		Mode:     a.mode,
+		Fields:   a.fields,
+		DryRun:   req.Anonymize.DryRun,
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		abortWithError(c, errInternal("Failed to anonymize responses", err))
+		return
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT `+responseColumns+`
+		FROM `+responsesFrom+`
+		WHERE sr.survey_id = ?
+	`, sID)
+	if err != nil {
+		abortWithError(c, errInternal("Failed to fetch responses", err))
+		return
+	}
+
+	var responses []SurveyResponse
+	for rows.Next() {
+		response, err := scanResponse(rows)
+		if err != nil {
+			rows.Close()
+			abortWithError(c, errInternal("Failed to scan response data", err))
+			return
+		}
+		responses = append(responses, response)
+	}
+	rows.Close()
+
+	for _, response := range responses {
+		report.ResponsesScanned++
+
+		// Responses to anonymous surveys only hold random tokens, which are kept
+		var identifier interface{}
+		identifierChanged := false
+		if !anonymous {
+			identifier, identifierChanged = a.identifier(response.UserIdentifier)
+		}
+		data, fieldsChanged := a.responseData(response.ResponseData)
+		if !identifierChanged && fieldsChanged == 0 {
+			continue
+		}
+
+		report.ResponsesChanged++
+		report.FieldsAnonymized += fieldsChanged
+		if identifierChanged {
+			report.IdentifiersAnonymized++
+		}
+		if report.DryRun {
+			continue
+		}
+
+		_, err := tx.ExecContext(ctx, `
+			UPDATE survey_responses
+			SET user_identifier = COALESCE(?, user_identifier), response_data = ?
+			WHERE id = ?
+		`, identifier, sealResponseData(data), response.ID)
+		if err != nil {
+			abortWithError(c, errInternal("Failed to anonymize responses", err))
+			return
+		}
+	}
+
+	message := "Dry run completed, no responses were changed"
+	if !report.DryRun {
+		message = "Survey responses anonymized successfully"
+		if err := recordAudit(ctx, tx, currentActor(c), "anonymize", "survey", sID, report); err != nil {
+			abortWithError(c, errInternal("Failed to record audit entry", err))
+			return
+		}
+		if err := tx.Commit(); err != nil {
+			abortWithError(c, errInternal("Failed to anonymize responses", err))
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Status:  "success",
+		Message: message,
+		Data:    report,
+	})
+}
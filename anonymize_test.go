@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// adminRequest builds a request authorized with the test admin token
+func adminRequest(method, url string, body []byte) *http.Request {
+	adminToken = "test-admin-token"
+	req, _ := http.NewRequest(method, url, bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+adminToken)
+	return req
+}
+
+func TestAnonymizeSurveyResponses(t *testing.T) {
+	setupTestDB()
+	defer testDB.Close()
+
+	result, err := testDB.Exec("INSERT INTO surveys (title, description) VALUES (?, ?)", "Test Survey", "Test Description")
+	assert.NoError(t, err)
+	surveyID, _ := result.LastInsertId()
+
+	for _, user := range []string{"alice", "bob"} {
+		data := json.RawMessage(fmt.Sprintf(`{"rating": "5", "email": "%s@example.com"}`, user))
+		_, err = testDB.Exec("INSERT INTO survey_responses (survey_id, user_identifier, response_data) VALUES (?, ?, ?)", surveyID, user, data)
+		assert.NoError(t, err)
+	}
+
+	router := setupTestRouter()
+	url := fmt.Sprintf("/api/admin/surveys/%d/anonymize", surveyID)
+
+	// Dry run only reports counts
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, adminRequest("POST", url, []byte(`{"anonymize":{"fields":["email"],"dry_run":true}}`)))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response struct {
+		Data AnonymizeReport `json:"data"`
+	}
+	err = json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, response.Data.ResponsesChanged)
+	assert.Equal(t, 2, response.Data.FieldsAnonymized)
+
+	var count int
+	testDB.QueryRow("SELECT COUNT(*) FROM survey_responses WHERE user_identifier = 'alice'").Scan(&count)
+	assert.Equal(t, 1, count)
+
+	// Real run hashes identifiers and PII answers and leaves an audit entry
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, adminRequest("POST", url, []byte(`{"anonymize":{"fields":["email"]}}`)))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var identifier string
+	var data []byte
+	err = testDB.QueryRow("SELECT user_identifier, response_data FROM survey_responses ORDER BY id LIMIT 1").Scan(&identifier, &data)
+	assert.NoError(t, err)
+	assert.Regexp(t, pseudonymPattern, identifier)
+	assert.NotContains(t, string(data), "alice@example.com")
+	assert.Contains(t, string(data), `"rating":"5"`)
+
+	testDB.QueryRow("SELECT COUNT(*) FROM audit_log WHERE action = 'anonymize' AND entity_id = ?", surveyID).Scan(&count)
+	assert.Equal(t, 1, count)
+
+	// Running again is a no-op
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, adminRequest("POST", url, []byte(`{"anonymize":{"fields":["email"]}}`)))
+	err = json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, response.Data.ResponsesChanged)
+}
+
+func TestAnonymizeRequiresAdmin(t *testing.T) {
+	setupTestDB()
+	defer testDB.Close()
+
+	router := setupTestRouter()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/admin/surveys/1/anonymize", bytes.NewBufferString(`{"anonymize":{}}`))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// maxAnswerFilters caps the answer[...] filters of one request; each one is a
+// JSON lookup per scanned response
+const maxAnswerFilters = 10
+
+// answerFilterParam matches answer[question] and answer[question][operator]
+var answerFilterParam = regexp.MustCompile(`^answer\[([^\]]*)\](?:\[([^\]]*)\])?$`)
+
+// answerFilterOperators lists the operators of answer filters; eq is the default
+var answerFilterOperators = []string{"eq", "ne", "contains", "gt", "gte", "lt", "lte"}
+
+// answerFilter matches the answer to one question in response_data
+type answerFilter struct {
+	Question string
+	Operator string
+	Value    string
+	Number   float64
+}
+
+// answerValueSQL renders a json_each value as text: booleans as true/false,
+// numbers and strings as written, so "5" and 5 compare equal
+const answerValueSQL = `CASE je.type WHEN 'true' THEN 'true' WHEN 'false' THEN 'false' ELSE CAST(je.value AS TEXT) END`
+
+// parseAnswerFilters reads the answer[question][operator]=value filters of a response listing into q
+func parseAnswerFilters(params url.Values, q *responseListQuery) []string {
+	errors := readAnswerFilters(params, q)
+	// SQLite can't look into encrypted or compressed answers
+	if len(q.Answers) > 0 && responseDataOpaque() {
+		errors = append(errors, "Answer filters are unavailable while response data is encrypted or compressed")
+	}
+	return errors
+}
+
+// readAnswerFilters reads answer filters into q, whether or not SQLite can
+// evaluate them; those matched in Go, with matches, work on opaque answers too
+func readAnswerFilters(params url.Values, q *responseListQuery) []string {
+	var errors []string
+
+	keys := make([]string, 0, len(params))
+	for key := range params {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		m := answerFilterParam.FindStringSubmatch(key)
+		if m == nil {
+			continue
+		}
+		filter := answerFilter{Question: m[1], Operator: m[2], Value: params.Get(key)}
+		if filter.Operator == "" {
+			filter.Operator = "eq"
+		}
+
+		if !questionIDPattern.MatchString(filter.Question) {
+			errors = append(errors, fmt.Sprintf("%s: question ID must be 1-64 letters, digits, underscores or dashes", key))
+			continue
+		}
+		switch filter.Operator {
+		case "eq", "ne", "contains":
+		case "gt", "gte", "lt", "lte":
+			n, err := strconv.ParseFloat(filter.Value, 64)
+			if err != nil {
+				errors = append(errors, fmt.Sprintf("%s must be a number", key))
+				continue
+			}
+			filter.Number = n
+		default:
+			errors = append(errors, fmt.Sprintf("%s: operator must be one of %s", key, strings.Join(answerFilterOperators, ", ")))
+			continue
+		}
+		q.Answers = append(q.Answers, filter)
+	}
+
+	if len(q.Answers) > maxAnswerFilters {
+		errors = append(errors, fmt.Sprintf("At most %d answer filters are allowed", maxAnswerFilters))
+	}
+	return errors
+}
+
+// condition returns the SQL condition of the filter on sr.response_data.
+// Answers to multiple choice questions match when any selected option does;
+// unanswered questions never match.
+func (f answerFilter) condition() (string, []interface{}) {
+	path := `$."` + f.Question + `"`
+	var predicate string
+	var arg interface{}
+
+	switch f.Operator {
+	case "eq", "ne":
+		predicate, arg = answerValueSQL+" = ?", f.Value
+	case "contains":
+		predicate, arg = answerValueSQL+` LIKE ? ESCAPE '\'`, "%"+likeEscaper.Replace(f.Value)+"%"
+	default:
+		ops := map[string]string{"gt": ">", "gte": ">=", "lt": "<", "lte": "<="}
+		predicate = "je.type IN ('integer', 'real', 'text') AND CAST(je.value AS REAL) " + ops[f.Operator] + " ?"
+		arg = f.Number
+	}
+
+	exists := "EXISTS (SELECT 1 FROM json_each(sr.response_data, ?) je WHERE " + predicate + ")"
+	if f.Operator == "ne" {
+		return "json_type(sr.response_data, ?) IS NOT NULL AND NOT " + exists, []interface{}{path, path, arg}
+	}
+	return exists, []interface{}{path, arg}
+}
+
+// matches evaluates the filter on opened response_data as condition does in
+// SQL, for answers SQLite can't see into
+func (f answerFilter) matches(data []byte) bool {
+	var answers map[string]json.RawMessage
+	if json.Unmarshal(data, &answers) != nil {
+		return false
+	}
+	answer, ok := answers[f.Question]
+	if !ok {
+		return false
+	}
+
+	// Like json_each, arrays and objects are matched by any element
+	var values []interface{}
+	decoder := json.NewDecoder(bytes.NewReader(answer))
+	decoder.UseNumber()
+	var value interface{}
+	if decoder.Decode(&value) != nil {
+		return false
+	}
+	switch v := value.(type) {
+	case []interface{}:
+		values = v
+	case map[string]interface{}:
+		for _, item := range v {
+			values = append(values, item)
+		}
+	default:
+		values = []interface{}{v}
+	}
+
+	found := false
+	for _, v := range values {
+		if f.matchesValue(v) {
+			found = true
+			break
+		}
+	}
+	if f.Operator == "ne" {
+		return !found
+	}
+	return found
+}
+
+// matchesValue compares one value with the filter, rendered as answerValueSQL
+// renders it; ne is compared as eq and negated by matches
+func (f answerFilter) matchesValue(value interface{}) bool {
+	if value == nil {
+		return false
+	}
+	var text string
+	switch v := value.(type) {
+	case bool:
+		text = strconv.FormatBool(v)
+	case json.Number:
+		text = v.String()
+	case string:
+		text = v
+	default:
+		raw, _ := json.Marshal(v)
+		text = string(raw)
+	}
+
+	switch f.Operator {
+	case "eq", "ne":
+		return text == f.Value
+	case "contains":
+		return strings.Contains(strings.ToLower(text), strings.ToLower(f.Value))
+	}
+	var n float64
+	switch v := value.(type) {
+	case json.Number:
+		n, _ = v.Float64()
+	case string:
+		// As CAST AS REAL, text that isn't a number counts as 0
+		n, _ = strconv.ParseFloat(strings.TrimSpace(v), 64)
+	default:
+		return false
+	}
+	switch f.Operator {
+	case "gt":
+		return n > f.Number
+	case "gte":
+		return n >= f.Number
+	case "lt":
+		return n < f.Number
+	default:
+		return n <= f.Number
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetSurveyResponsesAnswerFilters(t *testing.T) {
+	setupTestDB()
+	defer testDB.Close()
+
+	result, err := testDB.Exec("INSERT INTO surveys (title, description) VALUES (?, ?)", "Test Survey", "Test Description")
+	assert.NoError(t, err)
+	surveyID, _ := result.LastInsertId()
+
+	for i, data := range []string{
+		`{"rating":"5","comment":"Great service","colors":["red","blue"],"recommend":true}`,
+		`{"rating":3,"comment":"It was fine","colors":["green"],"recommend":false}`,
+		`{"rating":5,"comment":"100% GREAT"}`,
+		`{"rating":1}`,
+	} {
+		_, err := testDB.Exec("INSERT INTO survey_responses (survey_id, user_identifier, response_data, created_at) VALUES (?, ?, ?, datetime('now', ?))",
+			surveyID, fmt.Sprintf("user%d", i+1), data, fmt.Sprintf("+%d seconds", i))
+		assert.NoError(t, err)
+	}
+
+	router := setupTestRouter()
+
+	list := func(query url.Values) ([]string, int) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", fmt.Sprintf("/api/surveys/%d/responses?sort=created_at&order=asc&%s", surveyID, query.Encode()), nil)
+		router.ServeHTTP(w, req)
+
+		var response struct {
+			Data []SurveyResponse `json:"data"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		var users []string
+		for _, r := range response.Data {
+			users = append(users, r.UserIdentifier)
+		}
+		return users, w.Code
+	}
+
+	for _, tc := range []struct {
+		query url.Values
+		users []string
+	}{
+		{url.Values{"answer[rating]": {"5"}}, []string{"user1", "user3"}},
+		{url.Values{"answer[rating][ne]": {"5"}}, []string{"user2", "user4"}},
+		{url.Values{"answer[rating][gte]": {"3"}}, []string{"user1", "user2", "user3"}},
+		{url.Values{"answer[rating][lt]": {"3"}}, []string{"user4"}},
+		{url.Values{"answer[comment][contains]": {"great"}}, []string{"user1", "user3"}},
+		{url.Values{"answer[comment][contains]": {"100%"}}, []string{"user3"}},
+		{url.Values{"answer[colors]": {"blue"}}, []string{"user1"}},
+		{url.Values{"answer[recommend]": {"false"}}, []string{"user2"}},
+		{url.Values{"answer[rating]": {"5"}, "answer[comment][contains]": {"service"}}, []string{"user1"}},
+		{url.Values{"answer[missing]": {"5"}}, nil},
+	} {
+		users, code := list(tc.query)
+		assert.Equal(t, http.StatusOK, code, tc.query.Encode())
+		assert.Equal(t, tc.users, users, tc.query.Encode())
+	}
+
+	for _, query := range []url.Values{
+		{"answer[rating][between]": {"5"}},
+		{"answer[rating][gt]": {"high"}},
+		{"answer[bad id]": {"5"}},
+	} {
+		_, code := list(query)
+		assert.Equal(t, http.StatusBadRequest, code, query.Encode())
+	}
+}
+
+func TestAnswerFilterMatchesLikeSQL(t *testing.T) {
+	setupTestDB()
+	defer testDB.Close()
+
+	answers := []string{
+		`{"q": 5}`, `{"q": 4.5}`, `{"q": "5"}`, `{"q": "Great service"}`, `{"q": true}`, `{"q": false}`,
+		`{"q": null}`, `{"q": ["a", "b"]}`, `{"q": [1, 7]}`, `{"q": {"x": "a"}}`, `{"q": "abc"}`, `{"other": 5}`,
+	}
+	filters := []answerFilter{
+		{Question: "q", Operator: "eq", Value: "5"},
+		{Question: "q", Operator: "eq", Value: "true"},
+		{Question: "q", Operator: "eq", Value: "a"},
+		{Question: "q", Operator: "ne", Value: "5"},
+		{Question: "q", Operator: "ne", Value: "a"},
+		{Question: "q", Operator: "contains", Value: "great"},
+		{Question: "q", Operator: "contains", Value: "4."},
+		{Question: "q", Operator: "gt", Number: 4},
+		{Question: "q", Operator: "gte", Number: 5},
+		{Question: "q", Operator: "lt", Number: 1},
+		{Question: "q", Operator: "lte", Number: 0},
+	}
+	for _, data := range answers {
+		for _, f := range filters {
+			condition, args := f.condition()
+			var want bool
+			err := testDB.QueryRow("SELECT "+condition+" FROM (SELECT ? AS response_data) sr", append(args, data)...).Scan(&want)
+			assert.NoError(t, err)
+			assert.Equal(t, want, f.matches([]byte(data)), "%s %s %q%v on %s", f.Question, f.Operator, f.Value, f.Number, data)
+		}
+	}
+}
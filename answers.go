@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// QuestionAnswer is one respondent's raw answer to a single question. Questions
+// are the keys of response_data.
+type QuestionAnswer struct {
+	ResponseID     int             `json:"response_id"`
+	UserIdentifier string          `json:"user_identifier"`
+	Answer         json.RawMessage `json:"answer"`
+	CreatedAt      time.Time       `json:"created_at"`
+	UpdatedAt      time.Time       `json:"updated_at"`
+}
+
+// answerText renders an answer for CSV: strings unquoted, anything else as JSON
+func answerText(answer json.RawMessage) string {
+	var s string
+	if json.Unmarshal(answer, &s) == nil {
+		return s
+	}
+	return string(answer)
+}
+
+// findQuestionAnswers returns the answers to questionID of the responses matching q,
+// oldest first, skipping responses that left the question out or answered null
+func findQuestionAnswers(ctx context.Context, q responseListQuery, questionID string) ([]QuestionAnswer, error) {
+	where, args := q.where()
+	rows, err := db.QueryContext(ctx, `
+		SELECT sr.id, `+respondentColumn+`, sr.response_data, sr.created_at, sr.updated_at
+		FROM `+responsesFrom+`
+		WHERE `+where+`
+		ORDER BY sr.created_at ASC, sr.id ASC
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	answers := []QuestionAnswer{}
+	for rows.Next() {
+		var a QuestionAnswer
+		var data []byte
+		if err := rows.Scan(&a.ResponseID, &a.UserIdentifier, &data, &a.CreatedAt, &a.UpdatedAt); err != nil {
+			return nil, err
+		}
+		data, err := openResponseData(data)
+		if err != nil {
+			return nil, err
+		}
+		var fields map[string]json.RawMessage
+		if json.Unmarshal(data, &fields) != nil {
+			continue
+		}
+		answer, ok := fields[questionID]
+		if !ok || string(answer) == "null" {
+			continue
+		}
+		a.Answer = answer
+		answers = append(answers, a)
+	}
+	return answers, rows.Err()
+}
+
+// getQuestionAnswers exports every answer to one question, as CSV by default or as JSON with
+// ?format=json, filtered like the other analytics endpoints
+func getQuestionAnswers(c *gin.Context) {
+	ctx := c.Request.Context()
+	surveyID := c.Param("id")
+	id, err := strconv.Atoi(surveyID)
+	if err != nil {
+		abortWithError(c, errInvalidID("survey"))
+		return
+	}
+
+	q, errors := parseAnalyticsQuery(c, id)
+	format := c.DefaultQuery("format", "csv")
+	if format != "csv" && format != "json" {
+		errors = append(errors, "Format must be csv or json")
+	}
+	if len(errors) > 0 {
+		abortWithError(c, errInvalidQuery(errors))
+		return
+	}
+
+	survey, err := findSurvey(ctx, id)
+	if err != nil {
+		abortWithError(c, errNotFound(CodeSurveyNotFound, "Survey not found"))
+		return
+	}
+	recodes, errors := parseRecode(c, survey)
+	if len(errors) > 0 {
+		abortWithError(c, errInvalidQuery(errors))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, analyticsTimeout)
+	defer cancel()
+	if !checkAnalyticsCost(ctx, c, q) {
+		return
+	}
+
+	questionID := c.Param("question_id")
+	answers, err := findQuestionAnswers(ctx, q, questionID)
+	if err != nil {
+		analyticsQueryFailed(ctx, c, err)
+		return
+	}
+	if recode, ok := recodes[questionID]; ok {
+		for i, a := range answers {
+			var answer interface{}
+			if json.Unmarshal(a.Answer, &answer) == nil {
+				answers[i].Answer, _ = json.Marshal(recodeAnswer(recode, answer))
+			}
+		}
+	}
+	if pii := piiMaskFor(c, survey); pii[questionID] {
+		for i := range answers {
+			answers[i].Answer = maskRawAnswer(answers[i].Answer)
+		}
+	}
+
+	if format == "json" {
+		c.JSON(http.StatusOK, APIResponse{
+			Status: "success",
+			Data:   answers,
+		})
+		return
+	}
+
+	var b strings.Builder
+	w := csv.NewWriter(&b)
+	w.Write([]string{"response_id", "user_identifier", "answer", "created_at", "updated_at"})
+	for _, a := range answers {
+		w.Write([]string{
+			strconv.Itoa(a.ResponseID),
+			a.UserIdentifier,
+			answerText(a.Answer),
+			a.CreatedAt.UTC().Format(time.RFC3339),
+			a.UpdatedAt.UTC().Format(time.RFC3339),
+		})
+	}
+	w.Flush()
+
+	filename := fmt.Sprintf("survey-%d-%s-answers.csv", id, strings.Map(func(r rune) rune {
+		if r == '"' || r == '/' || r == '\\' || r < ' ' {
+			return '_'
+		}
+		return r
+	}, questionID))
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	c.Data(http.StatusOK, "text/csv; charset=utf-8", []byte(b.String()))
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetQuestionAnswers(t *testing.T) {
+	setupTestDB()
+	defer testDB.Close()
+
+	result, err := testDB.Exec("INSERT INTO surveys (title, description) VALUES (?, ?)", "Test Survey", "Test Description")
+	assert.NoError(t, err)
+	surveyID, _ := result.LastInsertId()
+
+	for i, data := range []string{
+		`{"feedback":"Great, \"really\" great","rating":"5"}`,
+		`{"rating":"3"}`,
+		`{"feedback":["fast","cheap"]}`,
+		`{"feedback":null}`,
+	} {
+		_, err = testDB.Exec(`INSERT INTO survey_responses (survey_id, user_identifier, response_data, created_at)
+			VALUES (?, ?, ?, datetime('now', ?))`, surveyID, fmt.Sprintf("user%d", i+1), data, fmt.Sprintf("-%d minutes", 10-i))
+		assert.NoError(t, err)
+	}
+	_, err = testDB.Exec(`INSERT INTO survey_responses (survey_id, user_identifier, response_data, deleted_at)
+		VALUES (?, 'deleted', '{"feedback":"gone"}', CURRENT_TIMESTAMP)`, surveyID)
+	assert.NoError(t, err)
+
+	router := setupTestRouter()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", fmt.Sprintf("/api/surveys/%d/questions/feedback/answers", surveyID), nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Type"), "text/csv")
+	assert.Contains(t, w.Header().Get("Content-Disposition"), fmt.Sprintf("survey-%d-feedback-answers.csv", surveyID))
+
+	records, err := csv.NewReader(strings.NewReader(w.Body.String())).ReadAll()
+	assert.NoError(t, err)
+	assert.Len(t, records, 3)
+	assert.Equal(t, []string{"response_id", "user_identifier", "answer", "created_at", "updated_at"}, records[0])
+	assert.Equal(t, "user1", records[1][1])
+	assert.Equal(t, `Great, "really" great`, records[1][2])
+	assert.Equal(t, "user3", records[2][1])
+	assert.Equal(t, `["fast","cheap"]`, records[2][2])
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", fmt.Sprintf("/api/surveys/%d/questions/rating/answers?format=json", surveyID), nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response struct {
+		Data []QuestionAnswer `json:"data"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Len(t, response.Data, 2)
+	assert.Equal(t, "user2", response.Data[1].UserIdentifier)
+	assert.JSONEq(t, `"3"`, string(response.Data[1].Answer))
+}
+
+func TestGetQuestionAnswersErrors(t *testing.T) {
+	setupTestDB()
+	defer testDB.Close()
+
+	router := setupTestRouter()
+
+	for url, code := range map[string]int{
+		"/api/surveys/abc/questions/feedback/answers":           http.StatusBadRequest,
+		"/api/surveys/1/questions/feedback/answers?format=xlsx": http.StatusBadRequest,
+		"/api/surveys/999/questions/feedback/answers":           http.StatusNotFound,
+	} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", url, nil)
+		router.ServeHTTP(w, req)
+		assert.Equal(t, code, w.Code, url)
+	}
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// apiKeyPrefix marks bearer tokens of API keys
+const apiKeyPrefix = "key_"
+
+// API key scopes
+const (
+	// APIKeyScopeAggregate permits the aggregateRoutes only
+	APIKeyScopeAggregate = "aggregate"
+)
+
+// aggregateRoutes lists the requests an aggregate API key permits, on any
+// survey: surveys themselves and analytics that never return individual
+// responses or their identifiers
+var aggregateRoutes = map[string]bool{
+	"GET /api/surveys":                          true,
+	"GET /api/surveys/:id":                      true,
+	"GET /api/surveys/:id/response-schema":      true,
+	"GET /api/surveys/:id/results":              true,
+	"GET /api/surveys/:id/results/live":         true,
+	"GET /api/surveys/:id/rollups":              true,
+	"GET /api/surveys/:id/nps":                  true,
+	"GET /api/surveys/:id/history":              true,
+	"GET /api/surveys/:id/dropout":              true,
+	"GET /api/surveys/:id/quality":              true,
+	"GET /api/surveys/:id/experience":           true,
+	"GET /api/surveys/:id/formulas":             true,
+	"GET /api/surveys/:id/formulas/:formula_id": true,
+}
+
+// APIKey grants external analysts or public dashboards access limited by its scope
+type APIKey struct {
+	ID    int    `json:"id"`
+	Name  string `json:"name"`
+	Scope string `json:"scope"`
+	// Prefix identifies the key without revealing it
+	Prefix string `json:"prefix"`
+	// Token is only returned when the key is created
+	Token      string     `json:"token,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+// CreateAPIKeyRequest represents the request body for creating an API key
+type CreateAPIKeyRequest struct {
+	APIKey struct {
+		Name string `json:"name" binding:"required"`
+		// Scope defaults to aggregate
+		Scope string `json:"scope"`
+	} `json:"api_key" binding:"required"`
+}
+
+// apiKeyColumns lists the columns read by scanAPIKey
+const apiKeyColumns = "id, name, scope, prefix, created_at, last_used_at, revoked_at"
+
+// scanAPIKey scans a row selected with apiKeyColumns
+func scanAPIKey(row rowScanner) (APIKey, error) {
+	var k APIKey
+	var lastUsedAt, revokedAt sql.NullTime
+	err := row.Scan(&k.ID, &k.Name, &k.Scope, &k.Prefix, &k.CreatedAt, &lastUsedAt, &revokedAt)
+	k.LastUsedAt = nullTimePtr(lastUsedAt)
+	k.RevokedAt = nullTimePtr(revokedAt)
+	return k, err
+}
+
+// findAPIKeyByToken loads the active key matching a bearer token
+func findAPIKeyByToken(ctx context.Context, token string) (APIKey, error) {
+	return scanAPIKey(db.QueryRowContext(ctx,
+		"SELECT "+apiKeyColumns+" FROM api_keys WHERE token_hash = ? AND revoked_at IS NULL",
+		hashSurveyToken(token)))
+}
+
+// apiKeyAuth restricts requests authenticated with an API key to the routes
+// of its scope. Other requests pass through.
+func apiKeyAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		token := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if !strings.HasPrefix(token, apiKeyPrefix) {
+			c.Next()
+			return
+		}
+
+		k, err := findAPIKeyByToken(ctx, token)
+		if err != nil {
+			abortWithError(c, &APIError{
+				Status:  http.StatusUnauthorized,
+				Code:    CodeInvalidAPIKey,
+				Message: "Invalid or revoked API key",
+			})
+			return
+		}
+		if !aggregateRoutes[c.Request.Method+" "+c.FullPath()] {
+			abortWithError(c, &APIError{
+				Status:  http.StatusForbidden,
+				Code:    CodeAPIKeyDenied,
+				Message: "API key scope does not permit this request",
+			})
+			return
+		}
+
+		db.ExecContext(ctx, "UPDATE api_keys SET last_used_at = CURRENT_TIMESTAMP WHERE id = ?", k.ID)
+		c.Set("actor", fmt.Sprintf("api_key:%d", k.ID))
+		c.Next()
+	}
+}
+
+// findActiveAPIKey loads an unrevoked API key, responding 404 when there is none
+func findActiveAPIKey(c *gin.Context, keyID int) (APIKey, bool) {
+	k, err := scanAPIKey(db.QueryRowContext(c.Request.Context(),
+		"SELECT "+apiKeyColumns+" FROM api_keys WHERE id = ? AND revoked_at IS NULL", keyID))
+	if err == sql.ErrNoRows {
+		abortWithError(c, errNotFound(CodeAPIKeyNotFound, "API key not found"))
+		return k, false
+	}
+	if err != nil {
+		abortWithError(c, errInternal("Failed to fetch API key", err))
+		return k, false
+	}
+	return k, true
+}
+
+// getAPIKeys lists the API keys, including revoked ones, without their tokens
+func getAPIKeys(c *gin.Context) {
+	rows, err := db.QueryContext(c.Request.Context(), "SELECT "+apiKeyColumns+" FROM api_keys ORDER BY id")
+	if err != nil {
+		abortWithError(c, errInternal("Failed to fetch API keys", err))
+		return
+	}
+	defer rows.Close()
+
+	keys := []APIKey{}
+	for rows.Next() {
+		k, err := scanAPIKey(rows)
+		if err != nil {
+			abortWithError(c, errInternal("Failed to scan API key data", err))
+			return
+		}
+		keys = append(keys, k)
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Status: "success",
+		Data:   keys,
+	})
+}
+
+// createAPIKey creates an API key; its token is shown once
+func createAPIKey(c *gin.Context) {
+	var req CreateAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		abortWithError(c, errInvalidRequest(err))
+		return
+	}
+	scope := req.APIKey.Scope
+	if scope == "" {
+		scope = APIKeyScopeAggregate
+	}
+
+	var errors []string
+	if len(req.APIKey.Name) > 100 {
+		errors = append(errors, "Name must be less than 100 characters")
+	}
+	if scope != APIKeyScopeAggregate {
+		errors = append(errors, "Scope must be aggregate")
+	}
+	if len(errors) > 0 {
+		abortWithError(c, errValidation("Failed to create API key", errors))
+		return
+	}
+
+	token := apiKeyPrefix + randomHex(24)
+	result, err := db.ExecContext(c.Request.Context(), `
+		INSERT INTO api_keys (name, scope, prefix, token_hash, created_at)
+		VALUES (?, ?, ?, ?, CURRENT_TIMESTAMP)
+	`, req.APIKey.Name, scope, token[:len(apiKeyPrefix)+8], hashSurveyToken(token))
+	if err != nil {
+		abortWithError(c, errInternal("Failed to create API key", err))
+		return
+	}
+
+	id, _ := result.LastInsertId()
+	k, ok := findActiveAPIKey(c, int(id))
+	if !ok {
+		return
+	}
+	auditChange(c, "create", "api_key", k.ID, nil, k)
+
+	k.Token = token
+	c.JSON(http.StatusCreated, APIResponse{
+		Status:  "success",
+		Message: "API key created successfully",
+		Data:    k,
+	})
+}
+
+// revokeAPIKey disables an API key; revoked keys stay listed for reference
+func revokeAPIKey(c *gin.Context) {
+	keyID, err := strconv.Atoi(c.Param("key_id"))
+	if err != nil {
+		abortWithError(c, errInvalidID("API key"))
+		return
+	}
+	before, ok := findActiveAPIKey(c, keyID)
+	if !ok {
+		return
+	}
+
+	if _, err := db.ExecContext(c.Request.Context(), "UPDATE api_keys SET revoked_at = CURRENT_TIMESTAMP WHERE id = ?", keyID); err != nil {
+		abortWithError(c, errInternal("Failed to revoke API key", err))
+		return
+	}
+	auditChange(c, "delete", "api_key", keyID, before, nil)
+
+	c.JSON(http.StatusOK, APIResponse{
+		Status:  "success",
+		Message: "API key revoked successfully",
+	})
+}
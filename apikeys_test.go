@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAggregateAPIKey(t *testing.T) {
+	setupTestDB()
+	defer testDB.Close()
+
+	testDB.Exec("INSERT INTO surveys (title, description) VALUES ('Public dashboard', 'd')")
+	testDB.Exec(`INSERT INTO survey_responses (survey_id, user_identifier, response_data) VALUES (1, 'alice', '{"rating": 5}')`)
+	router := setupTestRouter()
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, adminRequest("POST", "/api/admin/api-keys", []byte(`{"api_key":{"name":"Analyst"}}`)))
+	assert.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var created struct {
+		Data APIKey `json:"data"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &created)
+	assert.Equal(t, APIKeyScopeAggregate, created.Data.Scope)
+	assert.Contains(t, created.Data.Token, apiKeyPrefix)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, adminRequest("POST", "/api/admin/api-keys", []byte(`{"api_key":{"name":"Raw","scope":"raw"}}`)))
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+	withKey := func(method, url string) int {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, url, nil)
+		req.Header.Set("Authorization", "Bearer "+created.Data.Token)
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	for _, url := range []string{"/api/surveys", "/api/surveys/1", "/api/surveys/1/results", "/api/surveys/1/quality", "/api/surveys/1/dropout"} {
+		assert.Equal(t, http.StatusOK, withKey("GET", url), url)
+	}
+	// Raw responses, exports and writes are never permitted
+	for _, url := range []string{
+		"/api/surveys/1/responses",
+		"/api/surveys/1/responses/1",
+		"/api/surveys/1/responses/search?q=a",
+		"/api/surveys/1/responses/export.ndjson",
+		"/api/surveys/1/questions/rating/answers",
+		"/api/users/alice/responses",
+		"/api/admin/api-keys",
+	} {
+		assert.Equal(t, http.StatusForbidden, withKey("GET", url), url)
+	}
+	assert.Equal(t, http.StatusForbidden, withKey("POST", "/api/surveys/1/formulas"))
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, adminRequest("DELETE", fmt.Sprintf("/api/admin/api-keys/%d", created.Data.ID), nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, http.StatusUnauthorized, withKey("GET", "/api/surveys/1/results"))
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// execer is implemented by both *sql.DB and *sql.Tx
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// AuditEntry is a recorded write operation
+type AuditEntry struct {
+	ID         int             `json:"id"`
+	Actor      string          `json:"actor"`
+	Action     string          `json:"action"`
+	EntityType string          `json:"entity_type"`
+	EntityID   int             `json:"entity_id"`
+	Details    json.RawMessage `json:"details"`
+	Before     json.RawMessage `json:"before"`
+	After      json.RawMessage `json:"after"`
+	CreatedAt  time.Time       `json:"created_at"`
+}
+
+// auditColumns lists the columns read by scanAuditEntry
+const auditColumns = "id, actor, action, entity_type, entity_id, details, before_data, after_data, created_at"
+
+// scanAuditEntry scans a row selected with auditColumns
+func scanAuditEntry(row rowScanner) (AuditEntry, error) {
+	var entry AuditEntry
+	var details []byte
+	var before, after sql.NullString
+	err := row.Scan(&entry.ID, &entry.Actor, &entry.Action, &entry.EntityType, &entry.EntityID, &details, &before, &after, &entry.CreatedAt)
+	if err != nil {
+		return entry, err
+	}
+	entry.Details = details
+	if before.Valid {
+		if entry.Before, err = decryptResponseData([]byte(before.String)); err != nil {
+			return entry, err
+		}
+	}
+	if after.Valid {
+		entry.After, err = decryptResponseData([]byte(after.String))
+	}
+	return entry, err
+}
+
+// recordAudit appends an entry to the audit log; details are stored as JSON
+func recordAudit(ctx context.Context, exec execer, actor, action, entityType string, entityID int, details interface{}) error {
+	return insertAudit(ctx, exec, actor, action, entityType, entityID, details, nil, nil)
+}
+
+// recordChange appends an entry with snapshots of the entity before and after a write;
+// before is nil for creations and after is nil for deletions
+func recordChange(ctx context.Context, exec execer, actor, action, entityType string, entityID int, before, after interface{}) error {
+	return insertAudit(ctx, exec, actor, action, entityType, entityID, struct{}{}, before, after)
+}
+
+// auditChange records a write that already happened outside a transaction.
+// Failures are logged rather than failing a request whose write succeeded.
+func auditChange(c *gin.Context, action, entityType string, entityID int, before, after interface{}) {
+	if err := recordChange(c.Request.Context(), db, currentActor(c), action, entityType, entityID, before, after); err != nil {
+		log.Printf("Failed to audit %s of %s %d: %v", action, entityType, entityID, err)
+	}
+}
+
+// insertAudit stores an audit entry, encoding details and snapshots as JSON.
+// Snapshots are encrypted like response data, since those of responses hold answers.
+func insertAudit(ctx context.Context, exec execer, actor, action, entityType string, entityID int, details, before, after interface{}) error {
+	values := make([]interface{}, 3)
+	for i, v := range []interface{}{details, before, after} {
+		if v == nil {
+			continue
+		}
+		raw, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		if i > 0 {
+			raw = encryptResponseData(raw)
+		}
+		values[i] = string(raw)
+	}
+
+	_, err := exec.ExecContext(ctx, `
+		INSERT INTO audit_log (actor, action, entity_type, entity_id, details, before_data, after_data, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
+	`, actor, action, entityType, entityID, values[0], values[1], values[2])
+	return err
+}
+
+// getAuditLog lists audit entries, newest first, filtered by
+// ?entity_type=, ?entity_id=, ?action=, ?actor=, ?from= and ?to= (RFC 3339)
+func getAuditLog(c *gin.Context) {
+	ctx := c.Request.Context()
+	var conditions []string
+	var args []interface{}
+	var errors []string
+
+	for _, column := range []string{"entity_type", "action", "actor"} {
+		if value := c.Query(column); value != "" {
+			conditions = append(conditions, column+" = ?")
+			args = append(args, value)
+		}
+	}
+
+	if value := c.Query("entity_id"); value != "" {
+		id, err := strconv.Atoi(value)
+		if err != nil {
+			errors = append(errors, "Entity ID must be a number")
+		}
+		conditions = append(conditions, "entity_id = ?")
+		args = append(args, id)
+	}
+
+	for _, bound := range []struct{ param, operator, label string }{
+		{"from", ">=", "From"},
+		{"to", "<", "To"},
+	} {
+		value := c.Query(bound.param)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			errors = append(errors, bound.label+" must be an RFC 3339 timestamp")
+			continue
+		}
+		conditions = append(conditions, "created_at "+bound.operator+" ?")
+		args = append(args, t.UTC().Format(cursorTimeFormat))
+	}
+
+	limit := defaultPageSize
+	if value := c.Query("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > maxPageSize {
+			errors = append(errors, fmt.Sprintf("Limit must be between 1 and %d", maxPageSize))
+		}
+		limit = n
+	}
+
+	// The cursor is the ID of the last entry of the previous page
+	if value := c.Query("cursor"); value != "" {
+		id, err := strconv.Atoi(value)
+		if err != nil {
+			errors = append(errors, "Cursor is invalid")
+		}
+		conditions = append(conditions, "id < ?")
+		args = append(args, id)
+	}
+
+	if len(errors) > 0 {
+		abortWithError(c, errInvalidQuery(errors))
+		return
+	}
+
+	where := "1 = 1"
+	if len(conditions) > 0 {
+		where = strings.Join(conditions, " AND ")
+	}
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT `+auditColumns+`
+		FROM audit_log
+		WHERE `+where+`
+		ORDER BY id DESC
+		LIMIT ?
+	`, append(args, limit+1)...)
+	if err != nil {
+		abortWithError(c, errInternal("Failed to fetch audit log", err))
+		return
+	}
+	defer rows.Close()
+
+	entries := []AuditEntry{}
+	for rows.Next() {
+		entry, err := scanAuditEntry(rows)
+		if err != nil {
+			abortWithError(c, errInternal("Failed to scan audit log data", err))
+			return
+		}
+		entries = append(entries, entry)
+	}
+
+	meta := PageMeta{Limit: limit}
+	if len(entries) > limit {
+		entries = entries[:limit]
+		meta.NextCursor = strconv.Itoa(entries[len(entries)-1].ID)
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Status: "success",
+		Data:   entries,
+		Meta:   meta,
+	})
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuditLogRecordsWrites(t *testing.T) {
+	setupTestDB()
+	defer testDB.Close()
+
+	router := setupTestRouter()
+
+	send := func(method, url, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, url, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := send("POST", "/api/surveys", `{"survey":{"title":"Audited Survey","description":"Test Description"}}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	w = send("POST", "/api/surveys/1/responses", `{"survey_response":{"user_identifier":"testuser","response_data":{"rating":"5"}}}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	w = send("PATCH", "/api/surveys/1/responses/1", `{"survey_response":{"response_data":{"rating":"4"}}}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = send("DELETE", "/api/surveys/1/responses/1", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, adminRequest("GET", "/api/admin/audit?entity_type=survey_response&entity_id=1", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response struct {
+		Data []AuditEntry `json:"data"`
+	}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Len(t, response.Data, 3)
+
+	// Newest first, with snapshots of each write
+	deleted, updated, created := response.Data[0], response.Data[1], response.Data[2]
+	assert.Equal(t, "delete", deleted.Action)
+	assert.Equal(t, "null", string(deleted.After))
+	assert.Equal(t, "update", updated.Action)
+	assert.Contains(t, string(updated.Before), `"rating":"5"`)
+	assert.Contains(t, string(updated.After), `"rating":"4"`)
+	assert.Equal(t, "create", created.Action)
+	assert.Equal(t, "anonymous", created.Actor)
+
+	// Date range filters
+	from := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, adminRequest("GET", fmt.Sprintf("/api/admin/audit?from=%s", from), nil))
+	err = json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Empty(t, response.Data)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, adminRequest("GET", "/api/admin/audit?from=yesterday", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
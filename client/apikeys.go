@@ -0,0 +1,32 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// ListAPIKeys returns the API keys, including revoked ones, without their
+// tokens. It requires a client created WithAdminToken.
+func (c *Client) ListAPIKeys(ctx context.Context) ([]APIKey, error) {
+	var keys []APIKey
+	err := c.do(ctx, http.MethodGet, "/api/admin/api-keys", nil, nil, &keys, nil)
+	return keys, err
+}
+
+// CreateAPIKey creates an aggregate API key, which can only fetch surveys and
+// their analytics. The returned Token, for WithAPIKey, is not shown again. It
+// requires a client created WithAdminToken.
+func (c *Client) CreateAPIKey(ctx context.Context, name string) (*APIKey, error) {
+	body := map[string]interface{}{"api_key": map[string]interface{}{"name": name, "scope": "aggregate"}}
+	var key APIKey
+	if err := c.do(ctx, http.MethodPost, "/api/admin/api-keys", nil, body, &key, nil); err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+// RevokeAPIKey disables an API key. It requires a client created WithAdminToken.
+func (c *Client) RevokeAPIKey(ctx context.Context, keyID int) error {
+	return c.do(ctx, http.MethodDelete, fmt.Sprintf("/api/admin/api-keys/%d", keyID), nil, nil, nil, nil)
+}
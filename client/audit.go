@@ -0,0 +1,72 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// query encodes the audit filters, leaving out zero values
+func (p AuditParams) query() url.Values {
+	q := url.Values{}
+	for param, value := range map[string]string{
+		"entity_type": p.EntityType,
+		"action":      p.Action,
+		"actor":       p.Actor,
+		"cursor":      p.Cursor,
+	} {
+		if value != "" {
+			q.Set(param, value)
+		}
+	}
+	if p.EntityID != 0 {
+		q.Set("entity_id", strconv.Itoa(p.EntityID))
+	}
+	if !p.From.IsZero() {
+		q.Set("from", p.From.Format(time.RFC3339))
+	}
+	if !p.To.IsZero() {
+		q.Set("to", p.To.Format(time.RFC3339))
+	}
+	if p.Limit != 0 {
+		q.Set("limit", strconv.Itoa(p.Limit))
+	}
+	return q
+}
+
+// ListAuditLog returns one page of the recorded writes, newest first. It
+// requires a client created WithAdminToken.
+func (c *Client) ListAuditLog(ctx context.Context, params AuditParams) (*AuditPage, error) {
+	var page AuditPage
+	var meta struct {
+		NextCursor string `json:"next_cursor"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/api/admin/audit", params.query(), nil, &page.Entries, &meta); err != nil {
+		return nil, err
+	}
+	page.NextCursor = meta.NextCursor
+	return &page, nil
+}
+
+// ResponseDataStats reports the storage of responses and revisions and what
+// compression saves. It requires a client created WithAdminToken.
+func (c *Client) ResponseDataStats(ctx context.Context) (*ResponseDataReport, error) {
+	var report ResponseDataReport
+	if err := c.do(ctx, http.MethodGet, "/api/admin/response-data/stats", nil, nil, &report, nil); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// CompressResponseData compresses the responses and revisions stored before
+// compression was turned on; it fails with 409 Conflict while compression is
+// off. It requires a client created WithAdminToken.
+func (c *Client) CompressResponseData(ctx context.Context) (*CompressionResult, error) {
+	var result CompressionResult
+	if err := c.do(ctx, http.MethodPost, "/api/admin/response-data/compress", nil, nil, &result, nil); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
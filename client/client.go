@@ -0,0 +1,289 @@
+// Package client is a Go client for the Survey Form API.
+//
+//	c := client.New("http://localhost:8081")
+//	surveys, err := c.ListSurveys(ctx)
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Client calls the Survey Form API. It is safe for concurrent use.
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	token      string
+	orgID      int
+	maxRetries int
+	backoff    time.Duration
+}
+
+// Option configures a Client
+type Option func(*Client)
+
+// WithHTTPClient sets the HTTP client used for requests
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) { c.httpClient = hc }
+}
+
+// WithAdminToken sets the bearer token sent to the admin endpoints
+func WithAdminToken(token string) Option {
+	return func(c *Client) { c.token = token }
+}
+
+// WithSurveyToken sets a survey token, for clients embedding a form. Such clients
+// can only fetch the token's survey, its response schema and submit responses.
+func WithSurveyToken(token string) Option {
+	return func(c *Client) { c.token = token }
+}
+
+// WithViewerToken sets a viewer token, for team dashboards. Such clients can only
+// fetch surveys, their formulas, results and quality reports, counting the
+// responses matching the viewer's attributes.
+func WithViewerToken(token string) Option {
+	return func(c *Client) { c.token = token }
+}
+
+// WithAPIKey sets an aggregate API key, for external analysts and public
+// dashboards. Such clients can only fetch surveys and their analytics, never
+// individual responses or exports.
+func WithAPIKey(key string) Option {
+	return func(c *Client) { c.token = key }
+}
+
+// WithCreatorToken sets a creator token, for teams sharing a deployment. Surveys
+// such clients create are owned by the creator, and the survey list only has
+// the creator's surveys.
+func WithCreatorToken(token string) Option {
+	return func(c *Client) { c.token = token }
+}
+
+// WithOrganization sets the organization requests act in. Creators belonging
+// to several organizations need it; admins use it to act in one organization.
+func WithOrganization(orgID int) Option {
+	return func(c *Client) { c.orgID = orgID }
+}
+
+// WithRetries sets how many times a failed request is retried and the initial backoff,
+// which doubles after each attempt
+func WithRetries(maxRetries int, backoff time.Duration) Option {
+	return func(c *Client) {
+		c.maxRetries = maxRetries
+		c.backoff = backoff
+	}
+}
+
+// New returns a client for the API served at baseURL, e.g. "http://localhost:8081"
+func New(baseURL string, opts ...Option) *Client {
+	c := &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: http.DefaultClient,
+		maxRetries: 3,
+		backoff:    500 * time.Millisecond,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// APIError is returned when the API answers with an error status
+type APIError struct {
+	StatusCode int
+	// Code identifies the error, e.g. "SURVEY_NOT_FOUND"
+	Code    string
+	Message string
+	Errors  []string
+	// Data holds extra details such as existing_response_id on 409 Conflict
+	Data json.RawMessage
+	// RequestID identifies the request in the server logs, for bug reports
+	RequestID string
+}
+
+func (e *APIError) Error() string {
+	if len(e.Errors) > 0 {
+		return fmt.Sprintf("%d %s: %s", e.StatusCode, e.Message, strings.Join(e.Errors, "; "))
+	}
+	return fmt.Sprintf("%d %s", e.StatusCode, e.Message)
+}
+
+// envelope is the body shared by every API response
+type envelope struct {
+	Status  string          `json:"status"`
+	Code    string          `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data"`
+	Meta    json.RawMessage `json:"meta"`
+	Errors  []string        `json:"errors"`
+	// RequestID identifies the failed request in the server logs
+	RequestID string `json:"request_id"`
+}
+
+// document receives a whole response body that is not wrapped in the envelope,
+// such as a JSON Schema document
+type document struct {
+	body *json.RawMessage
+}
+
+// retryable reports whether a request may be sent again after failing with status.
+// Rate-limited requests were never processed, so they are retried for any method.
+func retryable(idempotent bool, status int) bool {
+	if status == http.StatusTooManyRequests {
+		return true
+	}
+	return idempotent && status >= 500
+}
+
+// do sends a request, retrying rate-limited and failed idempotent requests,
+// and decodes the data and meta of the response into data and meta when non-nil
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body, data, meta interface{}) error {
+	return c.send(ctx, method, path, query, "", body, data, meta)
+}
+
+// send is do with an Idempotency-Key, which makes any request safe to retry
+func (c *Client) send(ctx context.Context, method, path string, query url.Values, idempotencyKey string, body, data, meta interface{}) error {
+	header := http.Header{}
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return err
+		}
+		header.Set("Content-Type", "application/json")
+	}
+	if idempotencyKey != "" {
+		header.Set("Idempotency-Key", idempotencyKey)
+	}
+
+	resp, err := c.roundTrip(ctx, method, path, query, header, payload)
+	if err != nil {
+		return err
+	}
+	return decode(resp, data, meta)
+}
+
+// open sends a GET request and returns the body of a successful response
+// unread, for downloads too large to buffer. The caller must close it.
+func (c *Client) open(ctx context.Context, path string, query url.Values, header http.Header) (io.ReadCloser, error) {
+	resp, err := c.roundTrip(ctx, http.MethodGet, path, query, header, nil)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 400 {
+		return nil, decode(resp, nil, nil)
+	}
+	return resp.Body, nil
+}
+
+// roundTrip sends a request with header, retrying rate-limited and failed
+// idempotent requests, and returns the last response for the caller to read
+func (c *Client) roundTrip(ctx context.Context, method, path string, query url.Values, header http.Header, payload []byte) (*http.Response, error) {
+	target := c.baseURL + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+
+	idempotent := method == http.MethodGet || method == http.MethodDelete || header.Get("Idempotency-Key") != ""
+	backoff := c.backoff
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, method, target, bytes.NewReader(payload))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Accept", "application/json")
+		for key, values := range header {
+			req.Header[key] = values
+		}
+		if c.token != "" {
+			req.Header.Set("Authorization", "Bearer "+c.token)
+		}
+		if c.orgID != 0 {
+			req.Header.Set("X-Organization-ID", strconv.Itoa(c.orgID))
+		}
+
+		resp, err := c.httpClient.Do(req)
+		status := 0
+		var wait time.Duration
+		if err == nil {
+			status = resp.StatusCode
+			if seconds, perr := strconv.Atoi(resp.Header.Get("Retry-After")); perr == nil {
+				wait = time.Duration(seconds) * time.Second
+			}
+		}
+
+		canRetry := attempt < c.maxRetries &&
+			((err != nil && ctx.Err() == nil && idempotent) || (err == nil && retryable(idempotent, status)))
+		if !canRetry {
+			return resp, err
+		}
+
+		if resp != nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		if wait < backoff {
+			wait = backoff
+		}
+		backoff *= 2
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// decode reads an API response, turning error statuses into *APIError
+func decode(resp *http.Response, data, meta interface{}) error {
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if doc, ok := data.(document); ok && resp.StatusCode < 400 {
+		*doc.body = body
+		return nil
+	}
+
+	var env envelope
+	if err := json.Unmarshal(body, &env); err != nil && len(body) > 0 {
+		if resp.StatusCode >= 400 {
+			return &APIError{StatusCode: resp.StatusCode, Message: http.StatusText(resp.StatusCode), RequestID: resp.Header.Get("X-Request-ID")}
+		}
+		return err
+	}
+
+	if resp.StatusCode >= 400 {
+		return &APIError{
+			StatusCode: resp.StatusCode,
+			Code:       env.Code,
+			Message:    env.Message,
+			Errors:     env.Errors,
+			Data:       env.Data,
+			RequestID:  env.RequestID,
+		}
+	}
+
+	if data != nil && len(env.Data) > 0 {
+		if err := json.Unmarshal(env.Data, data); err != nil {
+			return err
+		}
+	}
+	if meta != nil && len(env.Meta) > 0 {
+		if err := json.Unmarshal(env.Meta, meta); err != nil {
+			return err
+		}
+	}
+	return nil
+}
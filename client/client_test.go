@@ -0,0 +1,117 @@
+package client
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetriesRateLimitedRequests(t *testing.T) {
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.Header().Set("Content-Type", "application/json")
+		if attempts < 3 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte(`{"status":"error","message":"Too many requests"}`))
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"status":"success","data":{"id":1,"title":"Survey"}}`))
+	}))
+	defer server.Close()
+
+	c := New(server.URL, WithRetries(3, time.Millisecond))
+	survey, err := c.CreateSurvey(context.Background(), CreateSurveyParams{Title: "Survey"})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, survey.ID)
+	assert.Equal(t, 3, attempts)
+}
+
+func TestDoesNotRetryFailedPosts(t *testing.T) {
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	c := New(server.URL, WithRetries(3, time.Millisecond))
+	_, err := c.CreateSurvey(context.Background(), CreateSurveyParams{Title: "Survey"})
+	assert.Error(t, err)
+	assert.Equal(t, 1, attempts)
+
+	// Reads are retried
+	attempts = 0
+	_, err = c.ListSurveys(context.Background())
+	assert.Error(t, err)
+	assert.Equal(t, 4, attempts)
+}
+
+func TestRetriesSubmissionsWithIdempotencyKey(t *testing.T) {
+	var keys []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys = append(keys, r.Header.Get("Idempotency-Key"))
+		if len(keys) < 2 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"status":"success","data":{"id":7}}`))
+	}))
+	defer server.Close()
+
+	c := New(server.URL, WithRetries(3, time.Millisecond))
+	response, err := c.CreateResponse(context.Background(), 1, "user1", map[string]int{"rating": 5})
+	assert.NoError(t, err)
+	assert.Equal(t, 7, response.ID)
+	if assert.Len(t, keys, 2) {
+		assert.Len(t, keys[0], 32)
+		assert.Equal(t, keys[0], keys[1])
+	}
+}
+
+func TestStreamsExports(t *testing.T) {
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if r.Header.Get("X-Export-Password") == "short" {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			w.Write([]byte(`{"status":"error","code":"VALIDATION_FAILED","message":"Invalid export password"}`))
+			return
+		}
+		assert.Equal(t, "/api/surveys/1/responses/export.ndjson", r.URL.Path)
+		assert.Equal(t, "web", r.URL.Query().Get("channel"))
+		assert.Empty(t, r.URL.Query().Get("limit"))
+		w.Write([]byte("{\"id\":1}\n{\"id\":2}\n"))
+	}))
+	defer server.Close()
+
+	c := New(server.URL, WithRetries(3, time.Millisecond))
+	body, err := c.ExportResponses(context.Background(), 1, ListResponsesParams{Channel: "web", Limit: 10}, "")
+	if assert.NoError(t, err) {
+		data, _ := io.ReadAll(body)
+		body.Close()
+		assert.Equal(t, "{\"id\":1}\n{\"id\":2}\n", string(data))
+	}
+	assert.Equal(t, 2, attempts)
+
+	_, err = c.ExportResponses(context.Background(), 1, ListResponsesParams{}, "short")
+	var apiErr *APIError
+	if assert.ErrorAs(t, err, &apiErr) {
+		assert.Equal(t, http.StatusUnprocessableEntity, apiErr.StatusCode)
+		assert.Equal(t, "Invalid export password", apiErr.Message)
+	}
+}
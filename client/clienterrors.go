@@ -0,0 +1,83 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// query encodes the client error filters, leaving out zero values
+func (p ClientErrorParams) query() url.Values {
+	q := url.Values{}
+	if p.SurveyID != 0 {
+		q.Set("survey_id", strconv.Itoa(p.SurveyID))
+	}
+	if p.Kind != "" {
+		q.Set("kind", p.Kind)
+	}
+	if p.Device != "" {
+		q.Set("device", p.Device)
+	}
+	if !p.From.IsZero() {
+		q.Set("from", p.From.Format(time.RFC3339))
+	}
+	if !p.To.IsZero() {
+		q.Set("to", p.To.Format(time.RFC3339))
+	}
+	if p.Limit != 0 {
+		q.Set("limit", strconv.Itoa(p.Limit))
+	}
+	if p.Cursor != "" {
+		q.Set("cursor", p.Cursor)
+	}
+	return q
+}
+
+// ReportClientError records a render or submit failure of a survey form. A
+// client created with a survey token may only report errors of its survey.
+func (c *Client) ReportClientError(ctx context.Context, report ClientErrorReport) error {
+	body := map[string]interface{}{"client_error": report}
+	return c.do(ctx, http.MethodPost, "/api/client-errors", nil, body, nil, nil)
+}
+
+// ListClientErrors returns one page of reported client errors, newest first. It
+// requires a client created WithAdminToken.
+func (c *Client) ListClientErrors(ctx context.Context, params ClientErrorParams) (*ClientErrorPage, error) {
+	var page ClientErrorPage
+	var meta struct {
+		NextCursor string `json:"next_cursor"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/api/admin/client-errors", params.query(), nil, &page.Errors, &meta); err != nil {
+		return nil, err
+	}
+	page.NextCursor = meta.NextCursor
+	return &page, nil
+}
+
+// ClientErrorSummary groups client errors by survey, kind and message, most
+// frequent first; a zero From covers the last day. Limit and Cursor are ignored.
+// It requires a client created WithAdminToken.
+func (c *Client) ClientErrorSummary(ctx context.Context, params ClientErrorParams) (*ClientErrorSummary, error) {
+	var summary ClientErrorSummary
+	if err := c.do(ctx, http.MethodGet, "/api/admin/client-errors/summary", params.query(), nil, &summary, nil); err != nil {
+		return nil, err
+	}
+	return &summary, nil
+}
+
+// SlowQueries reports the slowest recent SQL statements of the server instance
+// answering, grouped by statement and route; a zero limit uses the server
+// default. It requires a client created WithAdminToken.
+func (c *Client) SlowQueries(ctx context.Context, limit int) (*SlowQueryReport, error) {
+	q := url.Values{}
+	if limit != 0 {
+		q.Set("limit", strconv.Itoa(limit))
+	}
+	var report SlowQueryReport
+	if err := c.do(ctx, http.MethodGet, "/api/admin/slow-queries", q, nil, &report, nil); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
@@ -0,0 +1,32 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// ListCreators returns the creator accounts, including revoked ones, without
+// their tokens. It requires a client created WithAdminToken.
+func (c *Client) ListCreators(ctx context.Context) ([]Creator, error) {
+	var creators []Creator
+	err := c.do(ctx, http.MethodGet, "/api/admin/creators", nil, nil, &creators, nil)
+	return creators, err
+}
+
+// CreateCreator creates a creator account. The returned Token, for
+// WithCreatorToken, is not shown again. It requires a client created WithAdminToken.
+func (c *Client) CreateCreator(ctx context.Context, name string) (*Creator, error) {
+	body := map[string]interface{}{"creator": map[string]interface{}{"name": name}}
+	var creator Creator
+	if err := c.do(ctx, http.MethodPost, "/api/admin/creators", nil, body, &creator, nil); err != nil {
+		return nil, err
+	}
+	return &creator, nil
+}
+
+// RevokeCreator disables a creator account; its surveys keep their owner. It
+// requires a client created WithAdminToken.
+func (c *Client) RevokeCreator(ctx context.Context, creatorID int) error {
+	return c.do(ctx, http.MethodDelete, fmt.Sprintf("/api/admin/creators/%d", creatorID), nil, nil, nil, nil)
+}
@@ -0,0 +1,34 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// ReportExperienceEvent records that a survey's form rendered, or failed to, on a
+// respondent's device. Empty Device and Browser are detected from the User-Agent.
+func (c *Client) ReportExperienceEvent(ctx context.Context, surveyID int, event ExperienceEvent) error {
+	body := map[string]interface{}{"experience_event": event}
+	return c.do(ctx, http.MethodPost, fmt.Sprintf("/api/surveys/%d/experience-events", surveyID), nil, body, nil, nil)
+}
+
+// Experience returns the render error rates, median load time and device and
+// browser breakdowns of a survey's form between from and to. Zero times default
+// to a week ago and now.
+func (c *Client) Experience(ctx context.Context, surveyID int, from, to time.Time) (*ExperienceMetrics, error) {
+	q := url.Values{}
+	if !from.IsZero() {
+		q.Set("from", from.Format(time.RFC3339))
+	}
+	if !to.IsZero() {
+		q.Set("to", to.Format(time.RFC3339))
+	}
+	var metrics ExperienceMetrics
+	if err := c.do(ctx, http.MethodGet, fmt.Sprintf("/api/surveys/%d/experience", surveyID), q, nil, &metrics, nil); err != nil {
+		return nil, err
+	}
+	return &metrics, nil
+}
@@ -0,0 +1,48 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// ExportJobs lists a survey's scheduled exports. It requires a client created WithAdminToken.
+func (c *Client) ExportJobs(ctx context.Context, surveyID int) ([]ExportJob, error) {
+	var jobs []ExportJob
+	err := c.do(ctx, http.MethodGet, fmt.Sprintf("/api/admin/surveys/%d/export-jobs", surveyID), nil, nil, &jobs, nil)
+	return jobs, err
+}
+
+// CreateExportJob schedules a survey's responses to be exported every
+// interval, starting on the scheduler's next tick
+func (c *Client) CreateExportJob(ctx context.Context, surveyID int, params CreateExportJobParams) (*ExportJob, error) {
+	body := map[string]interface{}{"export_job": params}
+	var job ExportJob
+	if err := c.do(ctx, http.MethodPost, fmt.Sprintf("/api/admin/surveys/%d/export-jobs", surveyID), nil, body, &job, nil); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// ExportJob returns a scheduled export with its status and next run
+func (c *Client) ExportJob(ctx context.Context, surveyID, jobID int) (*ExportJob, error) {
+	var job ExportJob
+	if err := c.do(ctx, http.MethodGet, fmt.Sprintf("/api/admin/surveys/%d/export-jobs/%d", surveyID, jobID), nil, nil, &job, nil); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// LastExportRun returns the last run of a scheduled export
+func (c *Client) LastExportRun(ctx context.Context, surveyID, jobID int) (*ExportRun, error) {
+	var run ExportRun
+	if err := c.do(ctx, http.MethodGet, fmt.Sprintf("/api/admin/surveys/%d/export-jobs/%d/last-run", surveyID, jobID), nil, nil, &run, nil); err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
+
+// DeleteExportJob stops a scheduled export
+func (c *Client) DeleteExportJob(ctx context.Context, surveyID, jobID int) error {
+	return c.do(ctx, http.MethodDelete, fmt.Sprintf("/api/admin/surveys/%d/export-jobs/%d", surveyID, jobID), nil, nil, nil, nil)
+}
@@ -0,0 +1,38 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// ListFormulas returns the saved formulas of a survey
+func (c *Client) ListFormulas(ctx context.Context, surveyID int) ([]Formula, error) {
+	var formulas []Formula
+	err := c.do(ctx, http.MethodGet, fmt.Sprintf("/api/surveys/%d/formulas", surveyID), nil, nil, &formulas, nil)
+	return formulas, err
+}
+
+// GetFormula returns a saved formula
+func (c *Client) GetFormula(ctx context.Context, surveyID, formulaID int) (*Formula, error) {
+	var formula Formula
+	if err := c.do(ctx, http.MethodGet, fmt.Sprintf("/api/surveys/%d/formulas/%d", surveyID, formulaID), nil, nil, &formula, nil); err != nil {
+		return nil, err
+	}
+	return &formula, nil
+}
+
+// CreateFormula saves a formula; it's evaluated with the survey's results
+func (c *Client) CreateFormula(ctx context.Context, surveyID int, params CreateFormulaParams) (*Formula, error) {
+	body := map[string]interface{}{"formula": params}
+	var formula Formula
+	if err := c.do(ctx, http.MethodPost, fmt.Sprintf("/api/surveys/%d/formulas", surveyID), nil, body, &formula, nil); err != nil {
+		return nil, err
+	}
+	return &formula, nil
+}
+
+// DeleteFormula deletes a saved formula
+func (c *Client) DeleteFormula(ctx context.Context, surveyID, formulaID int) error {
+	return c.do(ctx, http.MethodDelete, fmt.Sprintf("/api/surveys/%d/formulas/%d", surveyID, formulaID), nil, nil, nil, nil)
+}
@@ -0,0 +1,61 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// ListInvitations returns a survey's invitations, only those with status when it is set
+func (c *Client) ListInvitations(ctx context.Context, surveyID int, status string) ([]Invitation, error) {
+	query := url.Values{}
+	if status != "" {
+		query.Set("status", status)
+	}
+	var invitations []Invitation
+	err := c.do(ctx, http.MethodGet, fmt.Sprintf("/api/surveys/%d/invitations", surveyID), query, nil, &invitations, nil)
+	return invitations, err
+}
+
+// CreateInvitations invites emails to a survey. The invitations are emailed in
+// the background once the survey is published; emails already invited are skipped.
+func (c *Client) CreateInvitations(ctx context.Context, surveyID int, emails []string) (*InvitationUpload, error) {
+	body := map[string]interface{}{"invitations": map[string]interface{}{"emails": emails}}
+	var upload InvitationUpload
+	if err := c.do(ctx, http.MethodPost, fmt.Sprintf("/api/surveys/%d/invitations", surveyID), nil, body, &upload, nil); err != nil {
+		return nil, err
+	}
+	return &upload, nil
+}
+
+// ResendInvitation queues an invitation to be emailed again with a new link,
+// which replaces the one sent before
+func (c *Client) ResendInvitation(ctx context.Context, surveyID, invitationID int) (*Invitation, error) {
+	var invitation Invitation
+	path := fmt.Sprintf("/api/surveys/%d/invitations/%d/resend", surveyID, invitationID)
+	if err := c.do(ctx, http.MethodPost, path, nil, nil, &invitation, nil); err != nil {
+		return nil, err
+	}
+	return &invitation, nil
+}
+
+// ReminderPreview returns a survey's reminder settings and the reminders they
+// will send, soonest first
+func (c *Client) ReminderPreview(ctx context.Context, surveyID int) (*ReminderPreview, error) {
+	var preview ReminderPreview
+	if err := c.do(ctx, http.MethodGet, fmt.Sprintf("/api/surveys/%d/invitations/reminders", surveyID), nil, nil, &preview, nil); err != nil {
+		return nil, err
+	}
+	return &preview, nil
+}
+
+// SaveReminderSettings configures when a survey's invitees who haven't responded are reminded
+func (c *Client) SaveReminderSettings(ctx context.Context, surveyID int, settings ReminderSettings) (*ReminderSettings, error) {
+	body := map[string]interface{}{"reminders": settings}
+	var saved ReminderSettings
+	if err := c.do(ctx, http.MethodPut, fmt.Sprintf("/api/surveys/%d/invitations/reminders", surveyID), nil, body, &saved, nil); err != nil {
+		return nil, err
+	}
+	return &saved, nil
+}
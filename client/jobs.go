@@ -0,0 +1,55 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// query encodes the job filters, leaving out zero values
+func (p JobParams) query() url.Values {
+	q := url.Values{}
+	if p.Status != "" {
+		q.Set("status", p.Status)
+	}
+	if p.Kind != "" {
+		q.Set("kind", p.Kind)
+	}
+	if p.Limit > 0 {
+		q.Set("limit", strconv.Itoa(p.Limit))
+	}
+	return q
+}
+
+// Jobs lists background jobs, e.g. the dead letters with Status "dead". It
+// requires a client created WithAdminToken.
+func (c *Client) Jobs(ctx context.Context, params JobParams) ([]Job, error) {
+	var jobs []Job
+	err := c.do(ctx, http.MethodGet, "/api/admin/jobs", params.query(), nil, &jobs, nil)
+	return jobs, err
+}
+
+// Job returns a background job with its payload and last error
+func (c *Client) Job(ctx context.Context, id int) (*Job, error) {
+	var job Job
+	if err := c.do(ctx, http.MethodGet, fmt.Sprintf("/api/admin/jobs/%d", id), nil, nil, &job, nil); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// RequeueJob queues a dead job again with its attempts reset
+func (c *Client) RequeueJob(ctx context.Context, id int) (*Job, error) {
+	var job Job
+	if err := c.do(ctx, http.MethodPost, fmt.Sprintf("/api/admin/jobs/%d/requeue", id), nil, nil, &job, nil); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// DeleteJob discards a background job
+func (c *Client) DeleteJob(ctx context.Context, id int) error {
+	return c.do(ctx, http.MethodDelete, fmt.Sprintf("/api/admin/jobs/%d", id), nil, nil, nil, nil)
+}
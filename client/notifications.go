@@ -0,0 +1,27 @@
+package client
+
+import (
+	"context"
+	"net/http"
+)
+
+// NotificationPreferences returns the notification preferences of the creator.
+// It requires a client created WithCreatorToken.
+func (c *Client) NotificationPreferences(ctx context.Context) (*NotificationPreferences, error) {
+	var prefs NotificationPreferences
+	if err := c.do(ctx, http.MethodGet, "/api/notification-preferences", nil, nil, &prefs, nil); err != nil {
+		return nil, err
+	}
+	return &prefs, nil
+}
+
+// SaveNotificationPreferences replaces the notification preferences of the
+// creator. It requires a client created WithCreatorToken.
+func (c *Client) SaveNotificationPreferences(ctx context.Context, prefs NotificationPreferences) (*NotificationPreferences, error) {
+	body := map[string]interface{}{"notification_preferences": prefs}
+	var saved NotificationPreferences
+	if err := c.do(ctx, http.MethodPut, "/api/notification-preferences", nil, body, &saved, nil); err != nil {
+		return nil, err
+	}
+	return &saved, nil
+}
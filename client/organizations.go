@@ -0,0 +1,66 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// ListOrganizations returns the organizations with their member counts. It
+// requires a client created WithAdminToken.
+func (c *Client) ListOrganizations(ctx context.Context) ([]Organization, error) {
+	var orgs []Organization
+	err := c.do(ctx, http.MethodGet, "/api/admin/organizations", nil, nil, &orgs, nil)
+	return orgs, err
+}
+
+// CreateOrganization creates an organization without members. It requires a
+// client created WithAdminToken.
+func (c *Client) CreateOrganization(ctx context.Context, name string) (*Organization, error) {
+	body := map[string]interface{}{"organization": map[string]interface{}{"name": name}}
+	var org Organization
+	if err := c.do(ctx, http.MethodPost, "/api/admin/organizations", nil, body, &org, nil); err != nil {
+		return nil, err
+	}
+	return &org, nil
+}
+
+// ListOrganizationMembers returns the creator accounts of an organization. It
+// requires a client created WithAdminToken.
+func (c *Client) ListOrganizationMembers(ctx context.Context, orgID int) ([]OrganizationMember, error) {
+	var members []OrganizationMember
+	err := c.do(ctx, http.MethodGet, fmt.Sprintf("/api/admin/organizations/%d/members", orgID), nil, nil, &members, nil)
+	return members, err
+}
+
+// AddOrganizationMember adds a creator account to an organization. It requires
+// a client created WithAdminToken.
+func (c *Client) AddOrganizationMember(ctx context.Context, orgID, creatorID int) error {
+	body := map[string]interface{}{"member": map[string]interface{}{"creator_id": creatorID}}
+	return c.do(ctx, http.MethodPost, fmt.Sprintf("/api/admin/organizations/%d/members", orgID), nil, body, nil, nil)
+}
+
+// RemoveOrganizationMember removes a creator account from an organization;
+// the surveys it created stay in the organization. It requires a client
+// created WithAdminToken.
+func (c *Client) RemoveOrganizationMember(ctx context.Context, orgID, creatorID int) error {
+	return c.do(ctx, http.MethodDelete, fmt.Sprintf("/api/admin/organizations/%d/members/%d", orgID, creatorID), nil, nil, nil, nil)
+}
+
+// SetOrganizationDomain serves an organization's public forms on a custom
+// domain, replacing its previous one. It requires a client created
+// WithAdminToken.
+func (c *Client) SetOrganizationDomain(ctx context.Context, orgID int, domain string) (*Organization, error) {
+	body := map[string]interface{}{"domain": domain}
+	var org Organization
+	if err := c.do(ctx, http.MethodPut, fmt.Sprintf("/api/admin/organizations/%d/domain", orgID), nil, body, &org, nil); err != nil {
+		return nil, err
+	}
+	return &org, nil
+}
+
+// RemoveOrganizationDomain stops serving an organization's public forms on its
+// custom domain. It requires a client created WithAdminToken.
+func (c *Client) RemoveOrganizationDomain(ctx context.Context, orgID int) error {
+	return c.do(ctx, http.MethodDelete, fmt.Sprintf("/api/admin/organizations/%d/domain", orgID), nil, nil, nil, nil)
+}
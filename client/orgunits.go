@@ -0,0 +1,50 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// ListOrgUnits returns the org hierarchy's units, parents before their
+// children. It requires a client created WithAdminToken.
+func (c *Client) ListOrgUnits(ctx context.Context) ([]OrgUnit, error) {
+	var units []OrgUnit
+	err := c.do(ctx, http.MethodGet, "/api/admin/org-units", nil, nil, &units, nil)
+	return units, err
+}
+
+// CreateOrgUnit adds an org unit under parentID, or as a root when it is nil.
+// Code is the answer placing responses in the unit. It requires a client
+// created WithAdminToken.
+func (c *Client) CreateOrgUnit(ctx context.Context, name, code string, parentID *int) (*OrgUnit, error) {
+	body := map[string]interface{}{"org_unit": map[string]interface{}{"name": name, "code": code, "parent_id": parentID}}
+	var unit OrgUnit
+	if err := c.do(ctx, http.MethodPost, "/api/admin/org-units", nil, body, &unit, nil); err != nil {
+		return nil, err
+	}
+	return &unit, nil
+}
+
+// DeleteOrgUnit removes an org unit; units with children can't be deleted. It
+// requires a client created WithAdminToken.
+func (c *Client) DeleteOrgUnit(ctx context.Context, unitID int) error {
+	return c.do(ctx, http.MethodDelete, fmt.Sprintf("/api/admin/org-units/%d", unitID), nil, nil, nil, nil)
+}
+
+// Rollups returns a survey's results for every org unit, placing responses by
+// their answer to question. Units with fewer than minN responses are
+// suppressed; 0 uses the server's minimum, which minN can only raise.
+func (c *Client) Rollups(ctx context.Context, surveyID int, question string, minN int) (*SurveyRollups, error) {
+	query := url.Values{"question": {question}}
+	if minN > 0 {
+		query.Set("min_n", strconv.Itoa(minN))
+	}
+	var rollups SurveyRollups
+	if err := c.do(ctx, http.MethodGet, fmt.Sprintf("/api/surveys/%d/rollups", surveyID), query, nil, &rollups, nil); err != nil {
+		return nil, err
+	}
+	return &rollups, nil
+}
@@ -0,0 +1,83 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// StartPartialResponse starts autosaving a respondent's answers. Keep the returned ID:
+// it is the only way back to the saved answers.
+func (c *Client) StartPartialResponse(ctx context.Context, surveyID int) (*PartialResponse, error) {
+	var partial PartialResponse
+	if err := c.do(ctx, http.MethodPost, fmt.Sprintf("/api/surveys/%d/partial-responses", surveyID), nil, nil, &partial, nil); err != nil {
+		return nil, err
+	}
+	return &partial, nil
+}
+
+// PartialResponse returns the answers saved so far
+func (c *Client) PartialResponse(ctx context.Context, surveyID int, id string) (*PartialResponse, error) {
+	var partial PartialResponse
+	if err := c.do(ctx, http.MethodGet, fmt.Sprintf("/api/surveys/%d/partial-responses/%s", surveyID, id), nil, nil, &partial, nil); err != nil {
+		return nil, err
+	}
+	return &partial, nil
+}
+
+// SaveAnswers merges answers into a partial response; a nil answer removes it.
+// With a version, the save fails with status 409 when another session changed
+// the same answers since that version.
+func (c *Client) SaveAnswers(ctx context.Context, surveyID int, id string, answers map[string]interface{}, version *int) (*PartialResponse, error) {
+	body := map[string]interface{}{"partial_response": map[string]interface{}{
+		"answers": answers,
+		"version": version,
+	}}
+	var partial PartialResponse
+	if err := c.do(ctx, http.MethodPatch, fmt.Sprintf("/api/surveys/%d/partial-responses/%s", surveyID, id), nil, body, &partial, nil); err != nil {
+		return nil, err
+	}
+	return &partial, nil
+}
+
+// CreateResumeCode issues a short code, such as K7M-4QX, that continues the
+// partial response on another device
+func (c *Client) CreateResumeCode(ctx context.Context, surveyID int, id string) (*ResumeCode, error) {
+	var code ResumeCode
+	if err := c.do(ctx, http.MethodPost, fmt.Sprintf("/api/surveys/%d/partial-responses/%s/resume-code", surveyID, id), nil, nil, &code, nil); err != nil {
+		return nil, err
+	}
+	return &code, nil
+}
+
+// RedeemResumeCode returns the partial response a resume code was issued for; codes work once
+func (c *Client) RedeemResumeCode(ctx context.Context, surveyID int, code string) (*PartialResponse, error) {
+	body := map[string]interface{}{"resume": map[string]string{"code": code}}
+	var partial PartialResponse
+	if err := c.do(ctx, http.MethodPost, fmt.Sprintf("/api/surveys/%d/partial-responses/resume", surveyID), nil, body, &partial, nil); err != nil {
+		return nil, err
+	}
+	return &partial, nil
+}
+
+// SubmitPartialResponse submits the saved answers as a response
+func (c *Client) SubmitPartialResponse(ctx context.Context, surveyID int, id, userIdentifier string) (*Response, error) {
+	body := map[string]interface{}{"survey_response": map[string]interface{}{
+		"user_identifier":     userIdentifier,
+		"partial_response_id": id,
+	}}
+	var response Response
+	if err := c.do(ctx, http.MethodPost, fmt.Sprintf("/api/surveys/%d/responses", surveyID), nil, body, &response, nil); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// Dropout reports how far respondents get through a survey before giving up
+func (c *Client) Dropout(ctx context.Context, surveyID int) (*DropoutReport, error) {
+	var report DropoutReport
+	if err := c.do(ctx, http.MethodGet, fmt.Sprintf("/api/surveys/%d/dropout", surveyID), nil, nil, &report, nil); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
@@ -0,0 +1,335 @@
+package client
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// query encodes the listing parameters, leaving out zero values
+func (p ListResponsesParams) query() url.Values {
+	q := url.Values{}
+	if p.View != 0 {
+		q.Set("view", strconv.Itoa(p.View))
+	}
+	if p.Sort != "" {
+		q.Set("sort", p.Sort)
+	}
+	if p.Order != "" {
+		q.Set("order", p.Order)
+	}
+	if p.Limit != 0 {
+		q.Set("limit", strconv.Itoa(p.Limit))
+	}
+	if p.Cursor != "" {
+		q.Set("cursor", p.Cursor)
+	}
+	for param, value := range map[string]string{
+		"channel":           p.Channel,
+		"country":           p.Country,
+		"device":            p.Device,
+		"moderation_status": p.ModerationStatus,
+		"quality_flag":      p.QualityFlag,
+	} {
+		if value != "" {
+			q.Set(param, value)
+		}
+	}
+	if p.BotScoreMin != nil {
+		q.Set("bot_score_min", strconv.FormatFloat(*p.BotScoreMin, 'f', -1, 64))
+	}
+	if p.BotScoreMax != nil {
+		q.Set("bot_score_max", strconv.FormatFloat(*p.BotScoreMax, 'f', -1, 64))
+	}
+	if len(p.ExcludeQuality) > 0 {
+		q.Set("exclude_quality", strings.Join(p.ExcludeQuality, ","))
+	}
+	for _, f := range p.Answers {
+		key := "answer[" + f.Question + "]"
+		if f.Operator != "" {
+			key += "[" + f.Operator + "]"
+		}
+		q.Set(key, f.Value)
+	}
+	return q
+}
+
+// ListResponses returns one page of a survey's responses
+func (c *Client) ListResponses(ctx context.Context, surveyID int, params ListResponsesParams) (*ResponsePage, error) {
+	var page ResponsePage
+	var meta struct {
+		NextCursor string `json:"next_cursor"`
+	}
+	err := c.do(ctx, http.MethodGet, fmt.Sprintf("/api/surveys/%d/responses", surveyID), params.query(), nil, &page.Responses, &meta)
+	if err != nil {
+		return nil, err
+	}
+	page.NextCursor = meta.NextCursor
+	return &page, nil
+}
+
+// ResponseIterator walks every page of a response listing
+//
+//	it := c.Responses(surveyID, client.ListResponsesParams{})
+//	for it.Next(ctx) {
+//		r := it.Response()
+//	}
+//	if err := it.Err(); err != nil { ... }
+type ResponseIterator struct {
+	client   *Client
+	surveyID int
+	params   ListResponsesParams
+	page     []Response
+	current  Response
+	done     bool
+	err      error
+}
+
+// Responses returns an iterator over all of a survey's responses, starting at params.Cursor
+func (c *Client) Responses(surveyID int, params ListResponsesParams) *ResponseIterator {
+	return &ResponseIterator{client: c, surveyID: surveyID, params: params}
+}
+
+// Next advances to the next response, fetching the next page when needed.
+// It returns false when there are no more responses or a request failed.
+func (it *ResponseIterator) Next(ctx context.Context) bool {
+	for len(it.page) == 0 {
+		if it.done || it.err != nil {
+			return false
+		}
+		page, err := it.client.ListResponses(ctx, it.surveyID, it.params)
+		if err != nil {
+			it.err = err
+			return false
+		}
+		it.page = page.Responses
+		it.params.Cursor = page.NextCursor
+		it.done = page.NextCursor == ""
+	}
+	it.current, it.page = it.page[0], it.page[1:]
+	return true
+}
+
+// Response returns the response Next advanced to
+func (it *ResponseIterator) Response() Response {
+	return it.current
+}
+
+// Err returns the error that stopped the iteration, if any
+func (it *ResponseIterator) Err() error {
+	return it.err
+}
+
+// GetResponse returns a single response of a survey
+func (c *Client) GetResponse(ctx context.Context, surveyID, responseID int) (*Response, error) {
+	var response Response
+	if err := c.do(ctx, http.MethodGet, fmt.Sprintf("/api/surveys/%d/responses/%d", surveyID, responseID), nil, nil, &response, nil); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// GetResponseByGlobalID returns a response by its rsp_ global ID, as GetResponse does
+func (c *Client) GetResponseByGlobalID(ctx context.Context, globalID string) (*Response, error) {
+	var response Response
+	if err := c.do(ctx, http.MethodGet, "/api/lookup/"+url.PathEscape(globalID), nil, nil, &response, nil); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// CreateResponse submits a response; data is encoded as the response_data object.
+// The submission carries an Idempotency-Key, so it is retried on network and
+// server errors without risking a duplicate response. userIdentifier may be
+// empty for anonymous surveys.
+func (c *Client) CreateResponse(ctx context.Context, surveyID int, userIdentifier string, data interface{}) (*Response, error) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	body := map[string]interface{}{"survey_response": map[string]interface{}{
+		"user_identifier": userIdentifier,
+		"response_data":   json.RawMessage(raw),
+	}}
+	key, err := newIdempotencyKey()
+	if err != nil {
+		return nil, err
+	}
+	var response Response
+	if err := c.send(ctx, http.MethodPost, fmt.Sprintf("/api/surveys/%d/responses", surveyID), nil, key, body, &response, nil); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// newIdempotencyKey returns a random key identifying one submission across its retries
+func newIdempotencyKey() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// UpdateResponse replaces the data of a response while it is still editable
+func (c *Client) UpdateResponse(ctx context.Context, surveyID, responseID int, data interface{}) (*Response, error) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	body := map[string]interface{}{"survey_response": map[string]interface{}{
+		"response_data": json.RawMessage(raw),
+	}}
+	var response Response
+	if err := c.do(ctx, http.MethodPatch, fmt.Sprintf("/api/surveys/%d/responses/%d", surveyID, responseID), nil, body, &response, nil); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// UpdateResponseVersion replaces the data of a response as edited from its
+// given version, e.g. offline. When the response changed since, policy (one of
+// the Conflict constants) resolves the conflict, which is returned with the
+// response; ConflictReject answers an *APIError with code ANSWER_CONFLICT.
+func (c *Client) UpdateResponseVersion(ctx context.Context, surveyID, responseID, version int, policy string, data interface{}) (*Response, *ResponseConflict, error) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	body := map[string]interface{}{"survey_response": map[string]interface{}{
+		"response_data":   json.RawMessage(raw),
+		"version":         version,
+		"conflict_policy": policy,
+	}}
+	var response Response
+	var meta struct {
+		Conflict *ResponseConflict `json:"conflict"`
+	}
+	if err := c.do(ctx, http.MethodPatch, fmt.Sprintf("/api/surveys/%d/responses/%d", surveyID, responseID), nil, body, &response, &meta); err != nil {
+		return nil, nil, err
+	}
+	return &response, meta.Conflict, nil
+}
+
+// ResponseRevisions returns the previous versions of a response's answers, oldest first
+func (c *Client) ResponseRevisions(ctx context.Context, surveyID, responseID int) ([]Revision, error) {
+	var revisions []Revision
+	path := fmt.Sprintf("/api/surveys/%d/responses/%d/revisions", surveyID, responseID)
+	err := c.do(ctx, http.MethodGet, path, nil, nil, &revisions, nil)
+	return revisions, err
+}
+
+// DeleteResponse soft-deletes a response
+func (c *Client) DeleteResponse(ctx context.Context, surveyID, responseID int) error {
+	return c.do(ctx, http.MethodDelete, fmt.Sprintf("/api/surveys/%d/responses/%d", surveyID, responseID), nil, nil, nil, nil)
+}
+
+// RestoreResponse undoes a soft delete. It requires a client created WithAdminToken.
+func (c *Client) RestoreResponse(ctx context.Context, surveyID, responseID int) (*Response, error) {
+	var response Response
+	path := fmt.Sprintf("/api/admin/surveys/%d/responses/%d/restore", surveyID, responseID)
+	if err := c.do(ctx, http.MethodPost, path, nil, nil, &response, nil); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// ResponseThread returns a response, deleted or not, with its earlier versions
+// and audit history. It requires a client created WithAdminToken.
+func (c *Client) ResponseThread(ctx context.Context, surveyID, responseID int) (*ResponseThread, error) {
+	var thread ResponseThread
+	path := fmt.Sprintf("/api/admin/surveys/%d/responses/%d/thread", surveyID, responseID)
+	if err := c.do(ctx, http.MethodGet, path, nil, nil, &thread, nil); err != nil {
+		return nil, err
+	}
+	return &thread, nil
+}
+
+// ResponseNeighbors returns the responses before and after a response in the listing order of params
+func (c *Client) ResponseNeighbors(ctx context.Context, surveyID, responseID int, params ListResponsesParams) (*ResponseNeighbors, error) {
+	var neighbors ResponseNeighbors
+	path := fmt.Sprintf("/api/surveys/%d/responses/%d/neighbors", surveyID, responseID)
+	if err := c.do(ctx, http.MethodGet, path, params.query(), nil, &neighbors, nil); err != nil {
+		return nil, err
+	}
+	return &neighbors, nil
+}
+
+// QuestionAnswers returns every answer to one question, keyed by questionID in response_data
+func (c *Client) QuestionAnswers(ctx context.Context, surveyID int, questionID string) ([]QuestionAnswer, error) {
+	var answers []QuestionAnswer
+	path := fmt.Sprintf("/api/surveys/%d/questions/%s/answers", surveyID, url.PathEscape(questionID))
+	err := c.do(ctx, http.MethodGet, path, url.Values{"format": {"json"}}, nil, &answers, nil)
+	return answers, err
+}
+
+// SearchResponses returns up to limit responses whose text answers contain every
+// word of query, newest first; limit 0 uses the server default
+func (c *Client) SearchResponses(ctx context.Context, surveyID int, query string, limit int) ([]SearchResult, error) {
+	var results []SearchResult
+	params := url.Values{"q": {query}}
+	if limit != 0 {
+		params.Set("limit", strconv.Itoa(limit))
+	}
+	err := c.do(ctx, http.MethodGet, fmt.Sprintf("/api/surveys/%d/responses/search", surveyID), params, nil, &results, nil)
+	return results, err
+}
+
+// EraseUserData deletes every response of a user across all surveys, or keeps
+// their answers under an unlinkable pseudonym when anonymize is set, for data
+// subject requests. It requires a client created WithAdminToken.
+func (c *Client) EraseUserData(ctx context.Context, userIdentifier string, anonymize bool) (*UserDataReport, error) {
+	query := url.Values{}
+	if anonymize {
+		query.Set("mode", "anonymize")
+	}
+	var report UserDataReport
+	path := "/api/users/" + url.PathEscape(userIdentifier) + "/data"
+	if err := c.do(ctx, http.MethodDelete, path, query, nil, &report, nil); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// ExportUserData returns every response tied to a user, deleted ones included,
+// with the surveys they answer, for data portability requests. It requires a
+// client created WithAdminToken.
+func (c *Client) ExportUserData(ctx context.Context, userIdentifier string) (*UserDataExport, error) {
+	var export UserDataExport
+	path := "/api/users/" + url.PathEscape(userIdentifier) + "/export"
+	if err := c.do(ctx, http.MethodGet, path, nil, nil, &export, nil); err != nil {
+		return nil, err
+	}
+	return &export, nil
+}
+
+// UserResponses returns every response submitted by a user
+func (c *Client) UserResponses(ctx context.Context, userIdentifier string) ([]UserResponse, error) {
+	var responses []UserResponse
+	path := "/api/users/" + url.PathEscape(userIdentifier) + "/responses"
+	err := c.do(ctx, http.MethodGet, path, nil, nil, &responses, nil)
+	return responses, err
+}
+
+// ExportResponses streams every response of a survey matching the filters of
+// params as newline-delimited JSON, oldest first; View, Sort, Order, Limit and
+// Cursor are ignored. With a password the export is a password-protected ZIP
+// instead. The caller must close the returned body.
+func (c *Client) ExportResponses(ctx context.Context, surveyID int, params ListResponsesParams, password string) (io.ReadCloser, error) {
+	q := params.query()
+	for _, param := range []string{"view", "sort", "order", "limit", "cursor"} {
+		q.Del(param)
+	}
+	header := http.Header{}
+	if password != "" {
+		header.Set("X-Export-Password", password)
+	}
+	return c.open(ctx, fmt.Sprintf("/api/surveys/%d/responses/export.ndjson", surveyID), q, header)
+}
@@ -0,0 +1,57 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// ReviewQueue lists a survey's scans and flagged responses awaiting review,
+// oldest first. A non-empty reason only lists items flagged for it. It requires
+// a client created WithAdminToken.
+func (c *Client) ReviewQueue(ctx context.Context, surveyID int, reason string) ([]ReviewItem, error) {
+	q := url.Values{}
+	if reason != "" {
+		q.Set("reason", reason)
+	}
+	var items []ReviewItem
+	err := c.do(ctx, http.MethodGet, fmt.Sprintf("/api/surveys/%d/review-queue", surveyID), q, nil, &items, nil)
+	return items, err
+}
+
+// AcceptReviewItem accepts a review item as it is. It requires a client created WithAdminToken.
+func (c *Client) AcceptReviewItem(ctx context.Context, surveyID int, itemID string) (*ReviewItem, error) {
+	return c.resolveReviewItem(ctx, surveyID, itemID, "accept", nil)
+}
+
+// FixReviewItem replaces answers of a review item and accepts it; a nil value
+// removes the answer. It requires a client created WithAdminToken.
+func (c *Client) FixReviewItem(ctx context.Context, surveyID int, itemID string, corrections map[string]interface{}) (*ReviewItem, error) {
+	raw := map[string]json.RawMessage{}
+	for questionID, value := range corrections {
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		raw[questionID] = encoded
+	}
+	body := map[string]interface{}{"review": map[string]interface{}{"corrections": raw}}
+	return c.resolveReviewItem(ctx, surveyID, itemID, "fix", body)
+}
+
+// RejectReviewItem rejects a review item. It requires a client created WithAdminToken.
+func (c *Client) RejectReviewItem(ctx context.Context, surveyID int, itemID string) (*ReviewItem, error) {
+	return c.resolveReviewItem(ctx, surveyID, itemID, "reject", nil)
+}
+
+// resolveReviewItem sends a review action
+func (c *Client) resolveReviewItem(ctx context.Context, surveyID int, itemID, action string, body interface{}) (*ReviewItem, error) {
+	var item ReviewItem
+	path := fmt.Sprintf("/api/surveys/%d/review-queue/%s/%s", surveyID, url.PathEscape(itemID), action)
+	if err := c.do(ctx, http.MethodPost, path, nil, body, &item, nil); err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
@@ -0,0 +1,69 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// ImportScannedResponse imports the answers a scanning service read from a
+// paper response. Scans with fields below the server's confidence threshold
+// are queued for review instead of creating a response. It requires a client
+// created WithAdminToken.
+func (c *Client) ImportScannedResponse(ctx context.Context, surveyID int, userIdentifier, source string, fields map[string]ScannedField) (*ScannedResponse, error) {
+	body := map[string]interface{}{"scanned_response": map[string]interface{}{
+		"user_identifier": userIdentifier,
+		"source":          source,
+		"fields":          fields,
+	}}
+	var scan ScannedResponse
+	if err := c.do(ctx, http.MethodPost, fmt.Sprintf("/api/admin/surveys/%d/scanned-responses", surveyID), nil, body, &scan, nil); err != nil {
+		return nil, err
+	}
+	return &scan, nil
+}
+
+// ScanReviewQueue lists the scans awaiting review, oldest first. A surveyID of
+// 0 lists scans of all surveys. It requires a client created WithAdminToken.
+func (c *Client) ScanReviewQueue(ctx context.Context, surveyID int) ([]ScannedResponse, error) {
+	q := url.Values{}
+	if surveyID != 0 {
+		q.Set("survey_id", strconv.Itoa(surveyID))
+	}
+	var scans []ScannedResponse
+	err := c.do(ctx, http.MethodGet, "/api/admin/scanned-responses", q, nil, &scans, nil)
+	return scans, err
+}
+
+// FinalizeScannedResponse creates the survey response of a reviewed scan.
+// Corrections replace the values of fields, a nil value drops the field. It
+// requires a client created WithAdminToken.
+func (c *Client) FinalizeScannedResponse(ctx context.Context, scanID int, corrections map[string]interface{}) (*ScannedResponse, error) {
+	raw := map[string]json.RawMessage{}
+	for questionID, value := range corrections {
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		raw[questionID] = encoded
+	}
+	body := map[string]interface{}{"review": map[string]interface{}{"corrections": raw}}
+	var scan ScannedResponse
+	if err := c.do(ctx, http.MethodPost, fmt.Sprintf("/api/admin/scanned-responses/%d/finalize", scanID), nil, body, &scan, nil); err != nil {
+		return nil, err
+	}
+	return &scan, nil
+}
+
+// RejectScannedResponse discards a scan that can't be read. It requires a
+// client created WithAdminToken.
+func (c *Client) RejectScannedResponse(ctx context.Context, scanID int) (*ScannedResponse, error) {
+	var scan ScannedResponse
+	if err := c.do(ctx, http.MethodPost, fmt.Sprintf("/api/admin/scanned-responses/%d/reject", scanID), nil, nil, &scan, nil); err != nil {
+		return nil, err
+	}
+	return &scan, nil
+}
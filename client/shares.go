@@ -0,0 +1,41 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// ListShareLinks returns a survey's share links, including revoked ones, without their tokens
+func (c *Client) ListShareLinks(ctx context.Context, surveyID int) ([]ShareLink, error) {
+	var links []ShareLink
+	err := c.do(ctx, http.MethodGet, fmt.Sprintf("/api/surveys/%d/share", surveyID), nil, nil, &links, nil)
+	return links, err
+}
+
+// CreateShareLink creates a public link to a survey's form. The returned Token
+// and URL are not shown again.
+func (c *Client) CreateShareLink(ctx context.Context, surveyID int) (*ShareLink, error) {
+	var link ShareLink
+	if err := c.do(ctx, http.MethodPost, fmt.Sprintf("/api/surveys/%d/share", surveyID), nil, nil, &link, nil); err != nil {
+		return nil, err
+	}
+	return &link, nil
+}
+
+// RevokeShareLink disables a share link
+func (c *Client) RevokeShareLink(ctx context.Context, surveyID, shareID int) error {
+	return c.do(ctx, http.MethodDelete, fmt.Sprintf("/api/surveys/%d/share/%d", surveyID, shareID), nil, nil, nil, nil)
+}
+
+// SharedSurvey returns the survey of a share link token. Only what a form
+// needs is set, never the response count or analysis settings; drafts only
+// carry their status and ComingSoon.
+func (c *Client) SharedSurvey(ctx context.Context, token string) (*Survey, error) {
+	var survey Survey
+	if err := c.do(ctx, http.MethodGet, "/s/"+url.PathEscape(token), nil, nil, &survey, nil); err != nil {
+		return nil, err
+	}
+	return &survey, nil
+}
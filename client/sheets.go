@@ -0,0 +1,41 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// SheetSync returns the Google Sheets sync of a survey
+func (c *Client) SheetSync(ctx context.Context, surveyID int) (*SheetSync, error) {
+	var sync SheetSync
+	if err := c.do(ctx, http.MethodGet, fmt.Sprintf("/api/surveys/%d/sheets", surveyID), nil, nil, &sync, nil); err != nil {
+		return nil, err
+	}
+	return &sync, nil
+}
+
+// SaveSheetSync appends a survey's new responses to a Google Sheet from now on
+func (c *Client) SaveSheetSync(ctx context.Context, surveyID int, params SaveSheetSyncParams) (*SheetSync, error) {
+	body := map[string]interface{}{"sheets": params}
+	var sync SheetSync
+	if err := c.do(ctx, http.MethodPut, fmt.Sprintf("/api/surveys/%d/sheets", surveyID), nil, body, &sync, nil); err != nil {
+		return nil, err
+	}
+	return &sync, nil
+}
+
+// DeleteSheetSync stops syncing a survey's responses to Google Sheets
+func (c *Client) DeleteSheetSync(ctx context.Context, surveyID int) error {
+	return c.do(ctx, http.MethodDelete, fmt.Sprintf("/api/surveys/%d/sheets", surveyID), nil, nil, nil, nil)
+}
+
+// BackfillSheet appends the responses received before the sync was
+// configured, returning how many were appended
+func (c *Client) BackfillSheet(ctx context.Context, surveyID int) (int, error) {
+	var report struct {
+		Appended int `json:"appended"`
+	}
+	err := c.do(ctx, http.MethodPost, fmt.Sprintf("/api/surveys/%d/sheets/backfill", surveyID), nil, nil, &report, nil)
+	return report.Appended, err
+}
@@ -0,0 +1,53 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// SummaryEmail returns the summary email settings of a survey, with the
+// default templates filled in
+func (c *Client) SummaryEmail(ctx context.Context, surveyID int) (*SummaryEmailSettings, error) {
+	var settings SummaryEmailSettings
+	if err := c.do(ctx, http.MethodGet, fmt.Sprintf("/api/surveys/%d/summary-email", surveyID), nil, nil, &settings, nil); err != nil {
+		return nil, err
+	}
+	return &settings, nil
+}
+
+// SaveSummaryEmail replaces the summary email settings of a survey
+func (c *Client) SaveSummaryEmail(ctx context.Context, surveyID int, settings SummaryEmailSettings) (*SummaryEmailSettings, error) {
+	body := map[string]interface{}{"summary_email": settings}
+	var saved SummaryEmailSettings
+	if err := c.do(ctx, http.MethodPut, fmt.Sprintf("/api/surveys/%d/summary-email", surveyID), nil, body, &saved, nil); err != nil {
+		return nil, err
+	}
+	return &saved, nil
+}
+
+// ListEmailSuppressions returns the addresses no email is sent to. It requires
+// a client created WithAdminToken.
+func (c *Client) ListEmailSuppressions(ctx context.Context) ([]EmailSuppression, error) {
+	var suppressions []EmailSuppression
+	err := c.do(ctx, http.MethodGet, "/api/admin/email-suppressions", nil, nil, &suppressions, nil)
+	return suppressions, err
+}
+
+// SuppressEmail stops every email to an address, such as after a complaint or
+// a hard bounce. It requires a client created WithAdminToken.
+func (c *Client) SuppressEmail(ctx context.Context, email, reason string) (*EmailSuppression, error) {
+	body := map[string]interface{}{"suppression": map[string]string{"email": email, "reason": reason}}
+	var suppression EmailSuppression
+	if err := c.do(ctx, http.MethodPost, "/api/admin/email-suppressions", nil, body, &suppression, nil); err != nil {
+		return nil, err
+	}
+	return &suppression, nil
+}
+
+// DeleteEmailSuppression lets emails reach an address again. It requires a
+// client created WithAdminToken.
+func (c *Client) DeleteEmailSuppression(ctx context.Context, email string) error {
+	return c.do(ctx, http.MethodDelete, "/api/admin/email-suppressions/"+url.PathEscape(email), nil, nil, nil, nil)
+}
@@ -0,0 +1,306 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+// ListSurveys returns the published surveys, newest first
+func (c *Client) ListSurveys(ctx context.Context) ([]Survey, error) {
+	return c.SearchSurveys(ctx, ListSurveysParams{})
+}
+
+// SearchSurveys returns the surveys matching params: a client created
+// WithCreatorToken gets the creator's surveys, others the surveys without an
+// owner. Listing drafts requires a client created WithAdminToken or
+// WithCreatorToken, and All one created WithAdminToken.
+func (c *Client) SearchSurveys(ctx context.Context, params ListSurveysParams) ([]Survey, error) {
+	q := url.Values{}
+	for param, value := range map[string]string{
+		"q":      params.Query,
+		"status": params.Status,
+		"sort":   params.Sort,
+		"order":  params.Order,
+	} {
+		if value != "" {
+			q.Set(param, value)
+		}
+	}
+	if params.All {
+		q.Set("all", "true")
+	}
+	var surveys []Survey
+	err := c.do(ctx, http.MethodGet, "/api/surveys", q, nil, &surveys, nil)
+	return surveys, err
+}
+
+// GetSurvey returns a survey; drafts only carry their status and ComingSoon
+func (c *Client) GetSurvey(ctx context.Context, id int) (*Survey, error) {
+	var survey Survey
+	if err := c.do(ctx, http.MethodGet, fmt.Sprintf("/api/surveys/%d", id), nil, nil, &survey, nil); err != nil {
+		return nil, err
+	}
+	return &survey, nil
+}
+
+// GetSurveyByGlobalID returns a survey by its srv_ global ID, as GetSurvey does
+func (c *Client) GetSurveyByGlobalID(ctx context.Context, globalID string) (*Survey, error) {
+	var survey Survey
+	if err := c.do(ctx, http.MethodGet, "/api/lookup/"+url.PathEscape(globalID), nil, nil, &survey, nil); err != nil {
+		return nil, err
+	}
+	return &survey, nil
+}
+
+// Discover returns one page of the public directory of open surveys, starting
+// after cursor (the previous page's NextCursor, or "" for the first page)
+func (c *Client) Discover(ctx context.Context, cursor string, limit int) (*DiscoverPage, error) {
+	q := url.Values{}
+	if cursor != "" {
+		q.Set("cursor", cursor)
+	}
+	if limit > 0 {
+		q.Set("limit", strconv.Itoa(limit))
+	}
+	var page DiscoverPage
+	var meta struct {
+		NextCursor string `json:"next_cursor"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/api/discover", q, nil, &page.Surveys, &meta); err != nil {
+		return nil, err
+	}
+	page.NextCursor = meta.NextCursor
+	return &page, nil
+}
+
+// SetDiscoverable lists a survey in the public directory, or takes it out.
+// It requires a client created WithAdminToken.
+func (c *Client) SetDiscoverable(ctx context.Context, id int, discoverable bool) (*Survey, error) {
+	body := map[string]bool{"discoverable": discoverable}
+	var survey Survey
+	if err := c.do(ctx, http.MethodPut, fmt.Sprintf("/api/surveys/%d/discoverable", id), nil, body, &survey, nil); err != nil {
+		return nil, err
+	}
+	return &survey, nil
+}
+
+// SetIndexable lets search engines index a survey's hosted form and list it
+// in the sitemap, or keeps it link-only. It requires a client created
+// WithAdminToken.
+func (c *Client) SetIndexable(ctx context.Context, id int, indexable bool) (*Survey, error) {
+	body := map[string]bool{"indexable": indexable}
+	var survey Survey
+	if err := c.do(ctx, http.MethodPut, fmt.Sprintf("/api/surveys/%d/indexing", id), nil, body, &survey, nil); err != nil {
+		return nil, err
+	}
+	return &survey, nil
+}
+
+// CreateSurvey creates a survey
+func (c *Client) CreateSurvey(ctx context.Context, params CreateSurveyParams) (*Survey, error) {
+	body := map[string]interface{}{"survey": params}
+	var survey Survey
+	if err := c.do(ctx, http.MethodPost, "/api/surveys", nil, body, &survey, nil); err != nil {
+		return nil, err
+	}
+	return &survey, nil
+}
+
+// ScheduleSurvey sets when a draft survey is published
+func (c *Client) ScheduleSurvey(ctx context.Context, id int, publishAt time.Time) (*Survey, error) {
+	body := map[string]interface{}{"survey": map[string]interface{}{"publish_at": publishAt}}
+	var survey Survey
+	if err := c.do(ctx, http.MethodPost, fmt.Sprintf("/api/surveys/%d/schedule", id), nil, body, &survey, nil); err != nil {
+		return nil, err
+	}
+	return &survey, nil
+}
+
+// Results returns per-question aggregates of a survey's responses; they may be a few seconds old
+func (c *Client) Results(ctx context.Context, id int) (*SurveyResults, error) {
+	var results SurveyResults
+	if err := c.do(ctx, http.MethodGet, fmt.Sprintf("/api/surveys/%d/results", id), nil, nil, &results, nil); err != nil {
+		return nil, err
+	}
+	return &results, nil
+}
+
+// RecodedResults returns the per-question aggregates of a survey with the recode
+// maps named recode applied to the questions defining them
+func (c *Client) RecodedResults(ctx context.Context, id int, recode string) (*SurveyResults, error) {
+	var results SurveyResults
+	if err := c.do(ctx, http.MethodGet, fmt.Sprintf("/api/surveys/%d/results", id), url.Values{"recode": {recode}}, nil, &results, nil); err != nil {
+		return nil, err
+	}
+	return &results, nil
+}
+
+// WatchResults calls fn with a survey's per-question aggregates over a
+// WebSocket, first with the current ones and then whenever they change, until
+// ctx is done, fn returns an error or the connection drops. The server caps
+// how many clients watch at once; refused connections fail to dial.
+func (c *Client) WatchResults(ctx context.Context, id int, fn func(SurveyResults) error) error {
+	target := fmt.Sprintf("%s/api/surveys/%d/results/live", c.baseURL, id)
+	target = "ws" + strings.TrimPrefix(target, "http")
+	config, err := websocket.NewConfig(target, c.baseURL)
+	if err != nil {
+		return err
+	}
+	if c.token != "" {
+		config.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	if c.orgID != 0 {
+		config.Header.Set("X-Organization-ID", strconv.Itoa(c.orgID))
+	}
+	ws, err := websocket.DialConfig(config)
+	if err != nil {
+		return err
+	}
+	defer ws.Close()
+
+	// Closing the connection stops the receive below when ctx is done
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-ctx.Done():
+			ws.Close()
+		case <-stop:
+		}
+	}()
+
+	for {
+		var msg struct {
+			Data SurveyResults `json:"data"`
+		}
+		if err := websocket.JSON.Receive(ws, &msg); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		}
+		if err := fn(msg.Data); err != nil {
+			return err
+		}
+	}
+}
+
+// History returns the daily snapshots of a survey's aggregates, oldest first,
+// from one day to another inclusive; zero times leave that end open
+func (c *Client) History(ctx context.Context, id int, from, to time.Time) ([]SurveySnapshot, error) {
+	query := url.Values{}
+	if !from.IsZero() {
+		query.Set("from", from.UTC().Format("2006-01-02"))
+	}
+	if !to.IsZero() {
+		query.Set("to", to.UTC().Format("2006-01-02"))
+	}
+	var snapshots []SurveySnapshot
+	err := c.do(ctx, http.MethodGet, fmt.Sprintf("/api/surveys/%d/history", id), query, nil, &snapshots, nil)
+	return snapshots, err
+}
+
+// SaveRecodes replaces the recode maps of a question, keyed by recode name
+func (c *Client) SaveRecodes(ctx context.Context, surveyID int, questionID string, recodes map[string]map[string]string) (*Survey, error) {
+	body := map[string]interface{}{"recodes": recodes}
+	path := fmt.Sprintf("/api/surveys/%d/questions/%s/recodes", surveyID, url.PathEscape(questionID))
+	var survey Survey
+	if err := c.do(ctx, http.MethodPut, path, nil, body, &survey, nil); err != nil {
+		return nil, err
+	}
+	return &survey, nil
+}
+
+// SavePII marks a question as PII, or unmarks it; requires an admin token
+func (c *Client) SavePII(ctx context.Context, surveyID int, questionID string, pii bool) (*Survey, error) {
+	body := map[string]interface{}{"pii": pii}
+	path := fmt.Sprintf("/api/surveys/%d/questions/%s/pii", surveyID, url.PathEscape(questionID))
+	var survey Survey
+	if err := c.do(ctx, http.MethodPut, path, nil, body, &survey, nil); err != nil {
+		return nil, err
+	}
+	return &survey, nil
+}
+
+// Quality reports the data quality of a survey's responses
+func (c *Client) Quality(ctx context.Context, id int) (*QualityReport, error) {
+	var report QualityReport
+	if err := c.do(ctx, http.MethodGet, fmt.Sprintf("/api/surveys/%d/quality", id), nil, nil, &report, nil); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// NPS returns the Net Promoter Score of a survey's 0-10 question, with the
+// score of every week too when weekly is set
+func (c *Client) NPS(ctx context.Context, id int, question string, weekly bool) (*NPSReport, error) {
+	query := url.Values{"question": {question}}
+	if weekly {
+		query.Set("interval", "week")
+	}
+	var report NPSReport
+	if err := c.do(ctx, http.MethodGet, fmt.Sprintf("/api/surveys/%d/nps", id), query, nil, &report, nil); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// ResponseSchema returns the JSON Schema document describing a survey's response_data
+func (c *Client) ResponseSchema(ctx context.Context, id int) (json.RawMessage, error) {
+	var schema json.RawMessage
+	if err := c.do(ctx, http.MethodGet, fmt.Sprintf("/api/surveys/%d/response-schema", id), nil, nil, document{&schema}, nil); err != nil {
+		return nil, err
+	}
+	return schema, nil
+}
+
+// AnonymizeSurvey hashes or strips identifiers and PII answers of a survey's responses.
+// It requires a client created WithAdminToken.
+func (c *Client) AnonymizeSurvey(ctx context.Context, id int, params AnonymizeParams) (*AnonymizeReport, error) {
+	body := map[string]interface{}{"anonymize": params}
+	var report AnonymizeReport
+	if err := c.do(ctx, http.MethodPost, fmt.Sprintf("/api/admin/surveys/%d/anonymize", id), nil, body, &report, nil); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// SurveyImpact lists what deleting a survey would break or remove. It
+// requires a client created WithAdminToken.
+func (c *Client) SurveyImpact(ctx context.Context, id int) (*SurveyImpact, error) {
+	var impact SurveyImpact
+	if err := c.do(ctx, http.MethodGet, fmt.Sprintf("/api/admin/surveys/%d/impact", id), nil, nil, &impact, nil); err != nil {
+		return nil, err
+	}
+	return &impact, nil
+}
+
+// DeleteSurvey deletes a survey, returning what was removed with it. Unless
+// cascade is set, a survey that anything refers to or that holds data is
+// kept and the error has the code SURVEY_IN_USE.
+func (c *Client) DeleteSurvey(ctx context.Context, id int, cascade bool) (*SurveyImpact, error) {
+	query := url.Values{}
+	if cascade {
+		query.Set("mode", "cascade")
+	}
+	var impact SurveyImpact
+	if err := c.do(ctx, http.MethodDelete, fmt.Sprintf("/api/admin/surveys/%d", id), query, nil, &impact, nil); err != nil {
+		return nil, err
+	}
+	return &impact, nil
+}
+
+// RateLimits lists the rate limits enforced by the API
+func (c *Client) RateLimits(ctx context.Context) ([]RateLimitRule, error) {
+	var rules []RateLimitRule
+	err := c.do(ctx, http.MethodGet, "/api/limits", nil, nil, &rules, nil)
+	return rules, err
+}
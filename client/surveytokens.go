@@ -0,0 +1,41 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// ListSurveyTokens returns a survey's tokens, including revoked ones, without
+// their values. It requires a client created WithAdminToken.
+func (c *Client) ListSurveyTokens(ctx context.Context, surveyID int) ([]SurveyToken, error) {
+	var tokens []SurveyToken
+	err := c.do(ctx, http.MethodGet, fmt.Sprintf("/api/admin/surveys/%d/tokens", surveyID), nil, nil, &tokens, nil)
+	return tokens, err
+}
+
+// CreateSurveyToken issues a token for embedding a survey. The returned Token is
+// not shown again. It requires a client created WithAdminToken.
+func (c *Client) CreateSurveyToken(ctx context.Context, surveyID int, name string) (*SurveyToken, error) {
+	body := map[string]interface{}{"token": map[string]string{"name": name}}
+	var token SurveyToken
+	if err := c.do(ctx, http.MethodPost, fmt.Sprintf("/api/admin/surveys/%d/tokens", surveyID), nil, body, &token, nil); err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// RotateSurveyToken replaces a token's value; the previous value stops working
+// immediately. It requires a client created WithAdminToken.
+func (c *Client) RotateSurveyToken(ctx context.Context, surveyID, tokenID int) (*SurveyToken, error) {
+	var token SurveyToken
+	if err := c.do(ctx, http.MethodPost, fmt.Sprintf("/api/admin/surveys/%d/tokens/%d/rotate", surveyID, tokenID), nil, nil, &token, nil); err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// RevokeSurveyToken disables a survey token. It requires a client created WithAdminToken.
+func (c *Client) RevokeSurveyToken(ctx context.Context, surveyID, tokenID int) error {
+	return c.do(ctx, http.MethodDelete, fmt.Sprintf("/api/admin/surveys/%d/tokens/%d", surveyID, tokenID), nil, nil, nil, nil)
+}
@@ -0,0 +1,31 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// Sync returns the surveys to cache and to drop since the previous sync, and
+// acknowledges responses uploaded with the given Idempotency-Keys, for apps
+// collecting responses offline
+func (c *Client) Sync(ctx context.Context, params SyncParams) (*SyncPage, error) {
+	q := url.Values{"key": params.Keys}
+	if params.Since != "" {
+		q.Set("since", params.Since)
+	}
+	if params.Limit != 0 {
+		q.Set("limit", strconv.Itoa(params.Limit))
+	}
+	var page SyncPage
+	var meta struct {
+		NextCursor string `json:"next_cursor"`
+		HasMore    bool   `json:"has_more"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/api/sync", q, nil, &page, &meta); err != nil {
+		return nil, err
+	}
+	page.NextCursor, page.HasMore = meta.NextCursor, meta.HasMore
+	return &page, nil
+}
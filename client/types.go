@@ -0,0 +1,1142 @@
+package client
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Survey is a survey as returned by the API
+type Survey struct {
+	ID                     int           `json:"id"`
+	GlobalID               string        `json:"global_id"`
+	Title                  string        `json:"title"`
+	Description            string        `json:"description"`
+	Status                 string        `json:"status"`
+	PublishAt              *time.Time    `json:"publish_at,omitempty"`
+	AllowMultipleResponses bool          `json:"allow_multiple_responses"`
+	Anonymous              bool          `json:"anonymous"`
+	Discoverable           bool          `json:"discoverable"`
+	Indexable              bool          `json:"indexable"`
+	EditWindowMinutes      *int          `json:"edit_window_minutes,omitempty"`
+	OpensAt                *time.Time    `json:"opens_at,omitempty"`
+	ClosesAt               *time.Time    `json:"closes_at,omitempty"`
+	MaxResponses           *int          `json:"max_responses,omitempty"`
+	Questions              []Question    `json:"questions,omitempty"`
+	QualityRules           *QualityRules `json:"quality_rules,omitempty"`
+	Pages                  []Page        `json:"pages,omitempty"`
+	Logic                  []LogicRule   `json:"logic,omitempty"`
+	// Translations maps language tags such as fr or pt-BR to the survey's texts
+	Translations map[string]SurveyTranslation `json:"translations,omitempty"`
+	// OwnerID is the creator account that created the survey, if any
+	OwnerID *int `json:"owner_id,omitempty"`
+	// OrganizationID is the organization the survey was created in, if any
+	OrganizationID     *int      `json:"organization_id,omitempty"`
+	CreatedAt          time.Time `json:"created_at"`
+	UpdatedAt          time.Time `json:"updated_at"`
+	ResponsesCount     int       `json:"responses_count"`
+	AcceptingResponses bool      `json:"accepting_responses"`
+	// ComingSoon is set instead of the details for surveys that are not published yet
+	ComingSoon bool `json:"coming_soon,omitempty"`
+}
+
+// ListSurveysParams filter and order the survey list; zero values use the server defaults
+type ListSurveysParams struct {
+	Query  string // matched against title and description
+	Status string // published, draft or all
+	Sort   string // created_at, responses_count or title
+	Order  string // asc or desc
+	// All lists the surveys of every owner; admin only
+	All bool
+}
+
+// CreateSurveyParams are the fields accepted when creating a survey
+type CreateSurveyParams struct {
+	Title                  string        `json:"title"`
+	Description            string        `json:"description"`
+	Status                 string        `json:"status,omitempty"`
+	PublishAt              *time.Time    `json:"publish_at,omitempty"`
+	AllowMultipleResponses *bool         `json:"allow_multiple_responses,omitempty"`
+	Anonymous              bool          `json:"anonymous,omitempty"`
+	EditWindowMinutes      *int          `json:"edit_window_minutes,omitempty"`
+	OpensAt                *time.Time    `json:"opens_at,omitempty"`
+	ClosesAt               *time.Time    `json:"closes_at,omitempty"`
+	MaxResponses           *int          `json:"max_responses,omitempty"`
+	Questions              []Question    `json:"questions,omitempty"`
+	QualityRules           *QualityRules `json:"quality_rules,omitempty"`
+}
+
+// Question describes one answer in response_data; ID is its key
+type Question struct {
+	ID       string   `json:"id"`
+	Type     string   `json:"type"`
+	Label    string   `json:"label"`
+	Required bool     `json:"required,omitempty"`
+	Options  []string `json:"options,omitempty"`
+	Min      *float64 `json:"min,omitempty"`
+	Max      *float64 `json:"max,omitempty"`
+	// Battery groups questions asked as one matrix, checked together for straight-lining
+	Battery string `json:"battery,omitempty"`
+	// Page is the ID of the page the question is shown on
+	Page string `json:"page,omitempty"`
+	// Recodes are named maps from answer values to derived values, e.g. "4" and
+	// "5" to "top_2_box"; "*" maps the values not listed
+	Recodes map[string]map[string]string `json:"recodes,omitempty"`
+	// PII answers are masked in responses, except for admins
+	PII bool `json:"pii,omitempty"`
+}
+
+// Page groups the questions shown together in the survey form
+type Page struct {
+	ID    string `json:"id"`
+	Title string `json:"title,omitempty"`
+}
+
+// LogicRule applies Action (show_question, hide_question or skip_to_page) to
+// Target when the answer to Question matches Operator and Value
+type LogicRule struct {
+	Question string      `json:"question"`
+	Operator string      `json:"operator"`
+	Value    interface{} `json:"value"`
+	Action   string      `json:"action"`
+	// Target is a question ID, or a page ID for skip_to_page
+	Target string `json:"target"`
+}
+
+// SurveyTranslation holds a survey's texts in one language
+type SurveyTranslation struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	// Pages maps page IDs to titles
+	Pages     map[string]string              `json:"pages,omitempty"`
+	Questions map[string]QuestionTranslation `json:"questions,omitempty"`
+}
+
+// QuestionTranslation holds a question's texts in one language
+type QuestionTranslation struct {
+	Label   string   `json:"label"`
+	Options []string `json:"options,omitempty"`
+}
+
+// SurveyIssue is a problem that keeps a survey from being published
+type SurveyIssue struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// SurveyValidation lists every blocking issue of a survey
+type SurveyValidation struct {
+	SurveyID int           `json:"survey_id"`
+	Valid    bool          `json:"valid"`
+	Issues   []SurveyIssue `json:"issues"`
+}
+
+// LintWarning is advice on improving a survey's design
+type LintWarning struct {
+	// Code is double_barreled, too_many_required, missing_prefer_not_to_say or unreachable_question
+	Code     string `json:"code"`
+	Question string `json:"question,omitempty"`
+	Message  string `json:"message"`
+}
+
+// SurveyLint lists the advisory warnings of a survey
+type SurveyLint struct {
+	SurveyID int           `json:"survey_id"`
+	Warnings []LintWarning `json:"warnings"`
+}
+
+// QualityRules tunes a survey's straight-lining and speeder checks; nil fields
+// use the server defaults and 0 turns a rule off
+type QualityRules struct {
+	StraightLiningMin *int     `json:"straight_lining_min,omitempty"`
+	SpeederRatio      *float64 `json:"speeder_ratio,omitempty"`
+	SpeederMinSample  *int     `json:"speeder_min_sample,omitempty"`
+}
+
+// SurveyResults aggregates a survey's responses question by question
+type SurveyResults struct {
+	SurveyID       int              `json:"survey_id"`
+	ResponsesCount int              `json:"responses_count"`
+	Questions      []QuestionResult `json:"questions"`
+	ComputedAt     time.Time        `json:"computed_at"`
+	Formulas       []FormulaResult  `json:"formulas"`
+}
+
+// SurveySnapshot holds a survey's aggregates at the end of a day (UTC), kept
+// after the responses are purged
+type SurveySnapshot struct {
+	// Day is formatted as 2006-01-02
+	Day            string           `json:"day"`
+	ResponsesCount int              `json:"responses_count"`
+	Questions      []QuestionResult `json:"questions"`
+	Formulas       []FormulaResult  `json:"formulas"`
+	CreatedAt      time.Time        `json:"created_at"`
+}
+
+// FormulaResult is the value of a saved formula; Value is nil and Error set
+// when it can't be computed
+type FormulaResult struct {
+	Name       string   `json:"name"`
+	Expression string   `json:"expression"`
+	Value      *float64 `json:"value"`
+	Error      string   `json:"error"`
+}
+
+// QuestionResult counts the answers to one question
+type QuestionResult struct {
+	ID       string         `json:"id"`
+	Answered int            `json:"answered"`
+	Values   map[string]int `json:"values"`
+}
+
+// Response is a survey response as returned by the API
+type Response struct {
+	ID             int             `json:"id"`
+	GlobalID       string          `json:"global_id"`
+	SurveyID       int             `json:"survey_id"`
+	UserIdentifier string          `json:"user_identifier"`
+	ResponseData   json.RawMessage `json:"response_data"`
+	CreatedAt      time.Time       `json:"created_at"`
+	UpdatedAt      time.Time       `json:"updated_at"`
+	Editable       bool            `json:"editable"`
+	Version        int             `json:"version"`
+	Metadata       Metadata        `json:"metadata"`
+}
+
+// Metadata describes where a response came from and how it was triaged
+type Metadata struct {
+	Channel          string   `json:"channel,omitempty"`
+	Country          string   `json:"country,omitempty"`
+	Device           string   `json:"device,omitempty"`
+	ModerationStatus string   `json:"moderation_status"`
+	BotScore         *float64 `json:"bot_score,omitempty"`
+	// Warnings lists where the answers don't match the survey's questions
+	Warnings   []string   `json:"warnings,omitempty"`
+	ReviewedAt *time.Time `json:"reviewed_at,omitempty"`
+	// QualityFlags lists data quality problems such as speeder or straight_lining
+	QualityFlags      []string `json:"quality_flags,omitempty"`
+	CompletionSeconds *int     `json:"completion_seconds,omitempty"`
+}
+
+// Revision is a previous version of a response's answers
+type Revision struct {
+	ID           int             `json:"id"`
+	ResponseID   int             `json:"response_id"`
+	Revision     int             `json:"revision"`
+	ResponseData json.RawMessage `json:"response_data"`
+	ReplacedAt   time.Time       `json:"replaced_at"`
+}
+
+// AuditEntry is one recorded write, with snapshots of the entity before and after it
+type AuditEntry struct {
+	ID         int             `json:"id"`
+	Actor      string          `json:"actor"`
+	Action     string          `json:"action"`
+	EntityType string          `json:"entity_type"`
+	EntityID   int             `json:"entity_id"`
+	Details    json.RawMessage `json:"details"`
+	Before     json.RawMessage `json:"before"`
+	After      json.RawMessage `json:"after"`
+	CreatedAt  time.Time       `json:"created_at"`
+}
+
+// ResponseThread is a response with its earlier versions and the history of changes to it
+type ResponseThread struct {
+	SurveyTitle string       `json:"survey_title"`
+	Questions   []Question   `json:"questions"`
+	Response    Response     `json:"response"`
+	Deleted     bool         `json:"deleted"`
+	Revisions   []Revision   `json:"revisions"`
+	History     []AuditEntry `json:"history"`
+	ExportedAt  time.Time    `json:"exported_at"`
+}
+
+// QuestionAnswer is one respondent's raw answer to a single question
+type QuestionAnswer struct {
+	ResponseID     int             `json:"response_id"`
+	UserIdentifier string          `json:"user_identifier"`
+	Answer         json.RawMessage `json:"answer"`
+	CreatedAt      time.Time       `json:"created_at"`
+	UpdatedAt      time.Time       `json:"updated_at"`
+}
+
+// UserResponse is a response listed for a user, with its survey embedded
+type UserResponse struct {
+	ID             int             `json:"id"`
+	Survey         Survey          `json:"survey"`
+	UserIdentifier string          `json:"user_identifier"`
+	ResponseData   json.RawMessage `json:"response_data"`
+	CreatedAt      time.Time       `json:"created_at"`
+	UpdatedAt      time.Time       `json:"updated_at"`
+	Editable       bool            `json:"editable"`
+}
+
+// ListResponsesParams filter and order a response listing; zero values use the server defaults
+type ListResponsesParams struct {
+	View   int
+	Sort   string
+	Order  string
+	Limit  int
+	Cursor string
+
+	// Metadata filters
+	Channel          string
+	Country          string
+	Device           string
+	ModerationStatus string
+	BotScoreMin      *float64
+	BotScoreMax      *float64
+
+	// QualityFlag keeps responses with the flag; ExcludeQuality drops responses
+	// with any of the flags, or any flag at all when it is []string{"all"}
+	QualityFlag    string
+	ExcludeQuality []string
+
+	// Answers filters on response_data; every filter must match
+	Answers []AnswerFilter
+}
+
+// AnswerFilter matches the answer to one question. Operator is one of eq
+// (the default), ne, contains, gt, gte, lt or lte.
+type AnswerFilter struct {
+	Question string
+	Operator string
+	Value    string
+}
+
+// SearchResult is a response matching a search. Snippet is HTML: the matched
+// words are wrapped in <mark> and the rest is escaped.
+type SearchResult struct {
+	Response Response `json:"response"`
+	Snippet  string   `json:"snippet"`
+}
+
+// ResponsePage is one page of a response listing
+type ResponsePage struct {
+	Responses  []Response
+	NextCursor string
+}
+
+// ResponseNeighbors identifies the responses around a given response
+type ResponseNeighbors struct {
+	ID       int  `json:"id"`
+	Previous *int `json:"previous"`
+	Next     *int `json:"next"`
+	Position int  `json:"position"`
+	Total    int  `json:"total"`
+}
+
+// View is a saved response view
+type View struct {
+	ID        int             `json:"id"`
+	SurveyID  int             `json:"survey_id"`
+	Name      string          `json:"name"`
+	Filters   json.RawMessage `json:"filters"`
+	Columns   []string        `json:"columns"`
+	Sort      string          `json:"sort"`
+	Order     string          `json:"order"`
+	CreatedAt time.Time       `json:"created_at"`
+	UpdatedAt time.Time       `json:"updated_at"`
+}
+
+// CreateViewParams are the fields accepted when saving a response view
+type CreateViewParams struct {
+	Name string `json:"name"`
+	// Filters are response list filter parameters and their values, e.g.
+	// {"channel": "email", "answer[rating][lte]": 2}
+	Filters json.RawMessage `json:"filters,omitempty"`
+	Columns []string        `json:"columns,omitempty"`
+	Sort    string          `json:"sort,omitempty"`
+	Order   string          `json:"order,omitempty"`
+}
+
+// Formula is a saved formula over question aggregates, e.g. top2box(q3) - top2box(q4)
+type Formula struct {
+	ID         int       `json:"id"`
+	SurveyID   int       `json:"survey_id"`
+	Name       string    `json:"name"`
+	Expression string    `json:"expression"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// CreateFormulaParams are the fields accepted when saving a formula
+type CreateFormulaParams struct {
+	Name       string `json:"name"`
+	Expression string `json:"expression"`
+}
+
+// RateLimitRule describes one of the API's rate limits
+type RateLimitRule struct {
+	Name          string   `json:"name"`
+	Key           string   `json:"key"`
+	Limit         int      `json:"limit"`
+	PeriodSeconds int      `json:"period_seconds"`
+	Routes        []string `json:"routes"`
+}
+
+// AnonymizeParams configure an anonymization run
+type AnonymizeParams struct {
+	Mode   string   `json:"mode,omitempty"`
+	Fields []string `json:"fields,omitempty"`
+	DryRun bool     `json:"dry_run"`
+}
+
+// SurveyReference is something that refers to a survey, such as a webhook
+// or a share link; Type is webhook, export_job, sheet_sync, share_link,
+// survey_token, response_view or formula
+type SurveyReference struct {
+	Type string `json:"type"`
+	ID   int    `json:"id,omitempty"`
+	Name string `json:"name"`
+}
+
+// SurveyImpact lists what deleting a survey would break or remove
+type SurveyImpact struct {
+	SurveyID         int               `json:"survey_id"`
+	References       []SurveyReference `json:"references"`
+	Responses        int               `json:"responses"`
+	PartialResponses int               `json:"partial_responses"`
+	ScannedResponses int               `json:"scanned_responses"`
+	Invitations      int               `json:"invitations"`
+	Snapshots        int               `json:"snapshots"`
+}
+
+// AnonymizeReport summarizes what an anonymization changed, or would change on a dry run
+type AnonymizeReport struct {
+	SurveyID              int      `json:"survey_id"`
+	Mode                  string   `json:"mode"`
+	Fields                []string `json:"fields"`
+	DryRun                bool     `json:"dry_run"`
+	ResponsesScanned      int      `json:"responses_scanned"`
+	ResponsesChanged      int      `json:"responses_changed"`
+	IdentifiersAnonymized int      `json:"identifiers_anonymized"`
+	FieldsAnonymized      int      `json:"fields_anonymized"`
+}
+
+// Webhook is a webhook subscription
+type Webhook struct {
+	ID         int               `json:"id"`
+	URL        string            `json:"url"`
+	Secret     string            `json:"secret,omitempty"`
+	Events     []string          `json:"events"`
+	SurveyID   *int              `json:"survey_id,omitempty"`
+	SampleRate *float64          `json:"sample_rate,omitempty"`
+	Filter     map[string]string `json:"filter"`
+	Template   string            `json:"template"`
+	RESTHook   bool              `json:"rest_hook"`
+	CreatedAt  time.Time         `json:"created_at"`
+}
+
+// CreateWebhookParams are the fields accepted when subscribing to webhooks;
+// no Events means every event and no SurveyID means every survey. SampleRate
+// and Filter thin out response events, using the response listing's filters.
+// Template, a Go text/template over the event's JSON, reshapes the deliveries.
+type CreateWebhookParams struct {
+	URL        string            `json:"url"`
+	Events     []string          `json:"events,omitempty"`
+	SurveyID   *int              `json:"survey_id,omitempty"`
+	SampleRate *float64          `json:"sample_rate,omitempty"`
+	Filter     map[string]string `json:"filter,omitempty"`
+	Template   string            `json:"template,omitempty"`
+}
+
+// SurveyToken is a credential limited to fetching and answering one survey
+type SurveyToken struct {
+	ID       int    `json:"id"`
+	SurveyID int    `json:"survey_id"`
+	Name     string `json:"name"`
+	Prefix   string `json:"prefix"`
+	// Token is only set when the token is created or rotated
+	Token      string     `json:"token,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+// ShareLink is a public link to a survey's form
+type ShareLink struct {
+	ID       int    `json:"id"`
+	SurveyID int    `json:"survey_id"`
+	Prefix   string `json:"prefix"`
+	// Token and URL are only set when the link is created
+	Token string `json:"token,omitempty"`
+	URL   string `json:"url,omitempty"`
+	// CanonicalURL is the absolute URL of the link, on the custom domain of the
+	// survey's organization when it has one
+	CanonicalURL string     `json:"canonical_url,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+	RevokedAt    *time.Time `json:"revoked_at,omitempty"`
+}
+
+// Invitation is an email inviting one respondent to a survey through a personal link
+type Invitation struct {
+	ID       int    `json:"id"`
+	SurveyID int    `json:"survey_id"`
+	Email    string `json:"email"`
+	// Status is pending, sent, failed, opened or responded
+	Status        string     `json:"status"`
+	Prefix        string     `json:"prefix,omitempty"`
+	Attempts      int        `json:"attempts"`
+	LastError     string     `json:"last_error,omitempty"`
+	ResponseID    *int       `json:"response_id,omitempty"`
+	RemindersSent int        `json:"reminders_sent"`
+	CreatedAt     time.Time  `json:"created_at"`
+	SentAt        *time.Time `json:"sent_at,omitempty"`
+	RemindedAt    *time.Time `json:"reminded_at,omitempty"`
+	OpenedAt      *time.Time `json:"opened_at,omitempty"`
+	RespondedAt   *time.Time `json:"responded_at,omitempty"`
+}
+
+// InvitationUpload reports what inviting a list of emails did
+type InvitationUpload struct {
+	Created int `json:"created"`
+	// Skipped lists the emails already invited to the survey
+	Skipped []string `json:"skipped"`
+}
+
+// ReminderSettings configure the reminders emailed to invitees who haven't responded
+type ReminderSettings struct {
+	// AfterDays is how many days after the invitation, and after each
+	// reminder, an invitee is reminded; nil turns reminders off
+	AfterDays *int `json:"after_days"`
+	// MaxReminders caps the reminders of one invitation; 0 uses the default of 1
+	MaxReminders int `json:"max_reminders"`
+}
+
+// ScheduledReminder is the next reminder of an invitation
+type ScheduledReminder struct {
+	Invitation Invitation `json:"invitation"`
+	Reminder   int        `json:"reminder"`
+	RemindAt   time.Time  `json:"remind_at"`
+	// Due reminders go out with the server's next scheduler run
+	Due bool `json:"due"`
+}
+
+// ReminderPreview lists who will be reminded under a survey's settings
+type ReminderPreview struct {
+	Settings  ReminderSettings    `json:"settings"`
+	Reminders []ScheduledReminder `json:"reminders"`
+}
+
+// UserDataReport summarizes what erasing a user's data removed or anonymized
+type UserDataReport struct {
+	Mode                 string `json:"mode"`
+	SurveyIDs            []int  `json:"survey_ids"`
+	ResponsesDeleted     int    `json:"responses_deleted"`
+	ResponsesAnonymized  int    `json:"responses_anonymized"`
+	RevisionsDeleted     int    `json:"revisions_deleted"`
+	PartialsDeleted      int    `json:"partial_responses_deleted"`
+	ScansDeleted         int    `json:"scanned_responses_deleted"`
+	ScansAnonymized      int    `json:"scanned_responses_anonymized"`
+	InvitationsDeleted   int    `json:"invitations_deleted"`
+	AuditEntriesRedacted int    `json:"audit_entries_redacted"`
+}
+
+// UserDataExport holds everything stored under a user identifier
+type UserDataExport struct {
+	UserIdentifier   string             `json:"user_identifier"`
+	ExportedAt       time.Time          `json:"exported_at"`
+	Surveys          []UserDataSurvey   `json:"surveys"`
+	Responses        []UserDataResponse `json:"responses"`
+	ScannedResponses []ScannedResponse  `json:"scanned_responses"`
+}
+
+// UserDataSurvey is a survey the user responded to, with the questions their answers are keyed by
+type UserDataSurvey struct {
+	ID          int        `json:"id"`
+	Title       string     `json:"title"`
+	Description string     `json:"description"`
+	Questions   []Question `json:"questions"`
+}
+
+// UserDataResponse is one of the user's responses, deleted or not, with the
+// answers edits replaced
+type UserDataResponse struct {
+	ID           int             `json:"id"`
+	SurveyID     int             `json:"survey_id"`
+	ResponseData json.RawMessage `json:"response_data"`
+	Channel      string          `json:"channel"`
+	Country      string          `json:"country"`
+	Device       string          `json:"device"`
+	CreatedAt    time.Time       `json:"created_at"`
+	UpdatedAt    time.Time       `json:"updated_at"`
+	DeletedAt    *time.Time      `json:"deleted_at"`
+	Revisions    []Revision      `json:"revisions"`
+}
+
+// Viewer is an account whose analytics only count the responses matching its
+// attributes, question IDs mapped to the required answer
+type Viewer struct {
+	ID         int               `json:"id"`
+	Name       string            `json:"name"`
+	Attributes map[string]string `json:"attributes"`
+	Prefix     string            `json:"prefix"`
+	// Token is only set when the viewer is created
+	Token      string     `json:"token,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+// APIKey grants access limited by its scope; aggregate keys only reach surveys
+// and their analytics
+type APIKey struct {
+	ID     int    `json:"id"`
+	Name   string `json:"name"`
+	Scope  string `json:"scope"`
+	Prefix string `json:"prefix"`
+	// Token is only set when the key is created
+	Token      string     `json:"token,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+// Creator is an account of a team creating surveys, which owns the surveys it creates
+type Creator struct {
+	ID     int    `json:"id"`
+	Name   string `json:"name"`
+	Prefix string `json:"prefix"`
+	// Token is only set when the account is created
+	Token      string     `json:"token,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+// Organization is a tenant whose surveys only requests acting in it see
+type Organization struct {
+	ID           int    `json:"id"`
+	Name         string `json:"name"`
+	MembersCount int    `json:"members_count"`
+	// CustomDomain serves the organization's public forms, e.g. forms.example.com
+	CustomDomain string    `json:"custom_domain,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// OrganizationMember is a creator account belonging to an organization
+type OrganizationMember struct {
+	CreatorID int       `json:"creator_id"`
+	Name      string    `json:"name"`
+	AddedAt   time.Time `json:"added_at"`
+}
+
+// OrgUnit is a node of the org hierarchy, e.g. a team within a department.
+// Responses belong to the unit whose Code they answer.
+type OrgUnit struct {
+	ID        int       `json:"id"`
+	ParentID  *int      `json:"parent_id"`
+	Name      string    `json:"name"`
+	Code      string    `json:"code"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// NPSCounts classify the answers to a 0-10 question: promoters answered 9-10,
+// passives 7-8 and detractors 0-6. Score, from -100 to 100, is nil without
+// answers.
+type NPSCounts struct {
+	Score      *float64 `json:"score"`
+	Promoters  int      `json:"promoters"`
+	Passives   int      `json:"passives"`
+	Detractors int      `json:"detractors"`
+	Total      int      `json:"total"`
+}
+
+// NPSWeek is the NPS of the week, Monday to Sunday UTC, starting at WeekStart
+type NPSWeek struct {
+	WeekStart time.Time `json:"week_start"`
+	NPSCounts
+}
+
+// NPSReport is the Net Promoter Score of a survey's question
+type NPSReport struct {
+	SurveyID int    `json:"survey_id"`
+	Question string `json:"question"`
+	NPSCounts
+	// Skipped counts the answers that aren't whole numbers from 0 to 10
+	Skipped    int       `json:"skipped"`
+	Weeks      []NPSWeek `json:"weeks"`
+	ComputedAt time.Time `json:"computed_at"`
+}
+
+// SurveyRollups holds a survey's results for every org unit
+type SurveyRollups struct {
+	SurveyID int    `json:"survey_id"`
+	Question string `json:"question"`
+	MinN     int    `json:"min_n"`
+	// Unassigned counts the responses whose answer matches no org unit
+	Unassigned int             `json:"unassigned"`
+	Units      []OrgUnitRollup `json:"units"`
+	ComputedAt time.Time       `json:"computed_at"`
+}
+
+// OrgUnitRollup aggregates the responses of an org unit and its descendants;
+// ResponsesCount is nil, and there are no questions or formulas, when the unit
+// is suppressed for having too few responses
+type OrgUnitRollup struct {
+	ID             int              `json:"id"`
+	Name           string           `json:"name"`
+	Code           string           `json:"code"`
+	Suppressed     bool             `json:"suppressed"`
+	ResponsesCount *int             `json:"responses_count"`
+	Questions      []QuestionResult `json:"questions"`
+	Formulas       []FormulaResult  `json:"formulas"`
+	Children       []OrgUnitRollup  `json:"children"`
+}
+
+// PartialResponse holds the answers autosaved while a respondent fills in a form
+type PartialResponse struct {
+	ID         string                     `json:"id"`
+	SurveyID   int                        `json:"survey_id"`
+	Answers    map[string]json.RawMessage `json:"answers"`
+	Version    int                        `json:"version"`
+	ResponseID *int                       `json:"response_id,omitempty"`
+	CreatedAt  time.Time                  `json:"created_at"`
+	UpdatedAt  time.Time                  `json:"updated_at"`
+}
+
+// ResumeCode continues a partial response on another device until it expires
+type ResumeCode struct {
+	Code      string    `json:"code"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// DropoutQuestion counts how far respondents got through one question
+type DropoutQuestion struct {
+	ID         string `json:"id"`
+	Answered   int    `json:"answered"`
+	DroppedOff int    `json:"dropped_off"`
+}
+
+// DropoutReport summarizes the progress of a survey's partial responses
+type DropoutReport struct {
+	SurveyID   int               `json:"survey_id"`
+	Started    int               `json:"started"`
+	Submitted  int               `json:"submitted"`
+	InProgress int               `json:"in_progress"`
+	Abandoned  int               `json:"abandoned"`
+	Questions  []DropoutQuestion `json:"questions"`
+}
+
+// QualityReport summarizes the data quality of a survey's responses
+type QualityReport struct {
+	SurveyID  int `json:"survey_id"`
+	Responses int `json:"responses"`
+	Flagged   int `json:"flagged"`
+	// Score is the share of responses without quality flags, nil without responses
+	Score                   *float64       `json:"score"`
+	Flags                   map[string]int `json:"flags"`
+	MedianCompletionSeconds *int           `json:"median_completion_seconds"`
+	// Rules are the survey's rules with the server defaults filled in
+	Rules QualityRules `json:"rules"`
+}
+
+// Types of experience events
+const (
+	ExperienceRender      = "render"
+	ExperienceRenderError = "render_error"
+)
+
+// ExperienceEvent is a render of a survey's form as seen by a respondent
+type ExperienceEvent struct {
+	Type string `json:"type"`
+	// LoadMs is how long the form took to render
+	LoadMs  *int   `json:"load_ms,omitempty"`
+	Device  string `json:"device,omitempty"`
+	Browser string `json:"browser,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// ExperienceStats summarizes the renders of a survey, or of one device or browser
+type ExperienceStats struct {
+	Name         string  `json:"name,omitempty"`
+	Renders      int     `json:"renders"`
+	RenderErrors int     `json:"render_errors"`
+	ErrorRate    float64 `json:"error_rate"`
+	MedianLoadMs *int    `json:"median_load_ms"`
+}
+
+// ExperienceMetrics describes how a survey's form behaves for respondents
+type ExperienceMetrics struct {
+	SurveyID int       `json:"survey_id"`
+	From     time.Time `json:"from"`
+	To       time.Time `json:"to"`
+	ExperienceStats
+	Devices  []ExperienceStats `json:"devices"`
+	Browsers []ExperienceStats `json:"browsers"`
+}
+
+// Kinds of client errors
+const (
+	ClientErrorRender = "render"
+	ClientErrorSubmit = "submit"
+	ClientErrorOther  = "other"
+)
+
+// ClientErrorReport describes a failure of a survey form
+type ClientErrorReport struct {
+	SurveyID *int   `json:"survey_id,omitempty"`
+	Kind     string `json:"kind"`
+	Message  string `json:"message"`
+	Detail   string `json:"detail,omitempty"`
+	PageURL  string `json:"page_url,omitempty"`
+	// RequestID is the X-Request-ID of the failed API call, see APIError
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// ClientError is a reported failure of a survey form
+type ClientError struct {
+	ID        int       `json:"id"`
+	SurveyID  *int      `json:"survey_id"`
+	Kind      string    `json:"kind"`
+	Message   string    `json:"message"`
+	Detail    string    `json:"detail"`
+	PageURL   string    `json:"page_url"`
+	RequestID string    `json:"request_id"`
+	UserAgent string    `json:"user_agent"`
+	Device    string    `json:"device"`
+	Browser   string    `json:"browser"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ClientErrorParams filter the client error listing and summary; zero values are ignored
+type ClientErrorParams struct {
+	SurveyID int
+	Kind     string
+	Device   string
+	From     time.Time
+	To       time.Time
+	Limit    int
+	Cursor   string
+}
+
+// ClientErrorPage is one page of a client error listing
+type ClientErrorPage struct {
+	Errors     []ClientError
+	NextCursor string
+}
+
+// ClientErrorGroup counts the reports of one error of one survey
+type ClientErrorGroup struct {
+	SurveyID  *int      `json:"survey_id"`
+	Kind      string    `json:"kind"`
+	Message   string    `json:"message"`
+	Count     int       `json:"count"`
+	Devices   []string  `json:"devices"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+}
+
+// ClientErrorSummary groups the client errors reported in a period
+type ClientErrorSummary struct {
+	From   time.Time          `json:"from"`
+	To     time.Time          `json:"to"`
+	Total  int                `json:"total"`
+	Kinds  map[string]int     `json:"kinds"`
+	Groups []ClientErrorGroup `json:"groups"`
+}
+
+// SlowQueryGroup sums up the slow runs of a SQL statement from one route
+type SlowQueryGroup struct {
+	Query string `json:"query"`
+	// Route is empty for statements run outside requests
+	Route    string    `json:"route"`
+	Count    int       `json:"count"`
+	MaxMs    float64   `json:"max_ms"`
+	MeanMs   float64   `json:"mean_ms"`
+	LastSeen time.Time `json:"last_seen"`
+}
+
+// SlowQueryReport lists the slowest recent SQL statements of a server instance
+type SlowQueryReport struct {
+	ThresholdMs float64          `json:"threshold_ms"`
+	Since       *time.Time       `json:"since"`
+	Groups      []SlowQueryGroup `json:"groups"`
+}
+
+// ScannedField is one answer read from a scanned paper response
+type ScannedField struct {
+	Value      json.RawMessage `json:"value"`
+	Confidence float64         `json:"confidence"`
+	// Flagged is set by the server on fields read with low confidence
+	Flagged bool `json:"flagged,omitempty"`
+}
+
+// ScannedResponse is a paper response imported from a scanning service
+type ScannedResponse struct {
+	ID             int                     `json:"id"`
+	SurveyID       int                     `json:"survey_id"`
+	UserIdentifier string                  `json:"user_identifier"`
+	Source         string                  `json:"source,omitempty"`
+	Fields         map[string]ScannedField `json:"fields"`
+	// Status is needs_review, finalized or rejected
+	Status     string     `json:"status"`
+	ResponseID *int       `json:"response_id,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ReviewedAt *time.Time `json:"reviewed_at,omitempty"`
+}
+
+// ReviewItem is a response or scanned paper response awaiting review
+type ReviewItem struct {
+	// ID identifies the item in review actions, e.g. response-12 or scan-3
+	ID string `json:"id"`
+	// Kind is response or scan
+	Kind string `json:"kind"`
+	// Reasons are low_confidence, bot_suspected, moderation_pending or validation_warning
+	Reasons   []string         `json:"reasons"`
+	CreatedAt time.Time        `json:"created_at"`
+	Response  *Response        `json:"response,omitempty"`
+	Scan      *ScannedResponse `json:"scan,omitempty"`
+}
+
+// QuietHours hold notifications back between Start and End, as HH:MM
+type QuietHours struct {
+	Start string `json:"start"`
+	End   string `json:"end"`
+}
+
+// NotificationPreferences choose how a creator hears about their surveys
+type NotificationPreferences struct {
+	Events          []string    `json:"events"`
+	Channels        []string    `json:"channels"`
+	Email           string      `json:"email,omitempty"`
+	SlackWebhookURL string      `json:"slack_webhook_url,omitempty"`
+	Delivery        string      `json:"delivery,omitempty"`
+	QuietHours      *QuietHours `json:"quiet_hours"`
+	TimeZone        string      `json:"time_zone,omitempty"`
+}
+
+// SheetSync appends a survey's responses to a Google Sheet; its credentials
+// are write-only
+type SheetSync struct {
+	SurveyID        int        `json:"survey_id"`
+	SpreadsheetID   string     `json:"spreadsheet_id"`
+	SheetName       string     `json:"sheet_name"`
+	ClientID        string     `json:"client_id"`
+	LastResponseID  int        `json:"last_response_id"`
+	BackfillThrough int        `json:"backfill_through"`
+	LastSyncedAt    *time.Time `json:"last_synced_at"`
+	LastError       string     `json:"last_error,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+}
+
+// SaveSheetSyncParams configure a Google Sheets sync; SheetName defaults to Sheet1
+type SaveSheetSyncParams struct {
+	SpreadsheetID string `json:"spreadsheet_id"`
+	SheetName     string `json:"sheet_name,omitempty"`
+	ClientID      string `json:"client_id"`
+	ClientSecret  string `json:"client_secret"`
+	RefreshToken  string `json:"refresh_token"`
+}
+
+// S3Destination is an S3 bucket, or a bucket of an S3-compatible store at
+// Endpoint; SecretAccessKey is write-only
+type S3Destination struct {
+	Bucket          string `json:"bucket"`
+	Region          string `json:"region"`
+	Prefix          string `json:"prefix,omitempty"`
+	Endpoint        string `json:"endpoint,omitempty"`
+	AccessKeyID     string `json:"access_key_id"`
+	SecretAccessKey string `json:"secret_access_key,omitempty"`
+}
+
+// SFTPDestination is a directory on an SFTP server whose public key, in
+// authorized_keys format, is HostKey; Password and PrivateKey are write-only
+type SFTPDestination struct {
+	Host       string `json:"host"`
+	User       string `json:"user"`
+	Path       string `json:"path,omitempty"`
+	HostKey    string `json:"host_key"`
+	Password   string `json:"password,omitempty"`
+	PrivateKey string `json:"private_key,omitempty"`
+}
+
+// ExportJob periodically exports all of a survey's responses to S3 or SFTP
+type ExportJob struct {
+	ID              int              `json:"id"`
+	SurveyID        int              `json:"survey_id"`
+	Format          string           `json:"format"`
+	Destination     string           `json:"destination"`
+	S3              *S3Destination   `json:"s3,omitempty"`
+	SFTP            *SFTPDestination `json:"sftp,omitempty"`
+	IntervalMinutes int              `json:"interval_minutes"`
+	NextRunAt       time.Time        `json:"next_run_at"`
+	// Status is pending, running, succeeded or failed
+	Status    string     `json:"status"`
+	LastRun   *ExportRun `json:"last_run"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// ExportRun reports one run of an export job
+type ExportRun struct {
+	Status     string     `json:"status"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at"`
+	Rows       int        `json:"rows"`
+	Bytes      int64      `json:"bytes"`
+	Location   string     `json:"location,omitempty"`
+	Error      string     `json:"error,omitempty"`
+}
+
+// CreateExportJobParams schedule an export; Format is csv or ndjson and
+// exactly one of S3 and SFTP is set
+type CreateExportJobParams struct {
+	Format          string           `json:"format"`
+	IntervalMinutes int              `json:"interval_minutes"`
+	S3              *S3Destination   `json:"s3,omitempty"`
+	SFTP            *SFTPDestination `json:"sftp,omitempty"`
+}
+
+// Job is a background job, such as a webhook delivery
+type Job struct {
+	ID      int             `json:"id"`
+	Kind    string          `json:"kind"`
+	Payload json.RawMessage `json:"payload"`
+	// Status is queued, running or dead
+	Status      string    `json:"status"`
+	Attempts    int       `json:"attempts"`
+	MaxAttempts int       `json:"max_attempts"`
+	RunAt       time.Time `json:"run_at"`
+	LastError   string    `json:"last_error,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// JobParams filter a job listing; zero values are left out
+type JobParams struct {
+	Status string
+	Kind   string
+	Limit  int
+}
+
+// DiscoverableSurvey is an open survey as listed in the public directory
+type DiscoverableSurvey struct {
+	ID          int        `json:"id"`
+	GlobalID    string     `json:"global_id"`
+	Title       string     `json:"title"`
+	Description string     `json:"description"`
+	OpensAt     *time.Time `json:"opens_at"`
+	ClosesAt    *time.Time `json:"closes_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// DiscoverPage is one page of the public survey directory
+type DiscoverPage struct {
+	Surveys    []DiscoverableSurvey
+	NextCursor string
+}
+
+// AuditParams filter the audit log; zero values are left out
+type AuditParams struct {
+	EntityType string
+	EntityID   int
+	Action     string
+	Actor      string
+	From       time.Time
+	To         time.Time
+	Limit      int
+	Cursor     string
+}
+
+// AuditPage is one page of the audit log
+type AuditPage struct {
+	Entries    []AuditEntry
+	NextCursor string
+}
+
+// ResponseDataStats measures the stored response_data of one table
+type ResponseDataStats struct {
+	Table          string `json:"table"`
+	Rows           int    `json:"rows"`
+	CompressedRows int    `json:"compressed_rows"`
+	StoredBytes    int64  `json:"stored_bytes"`
+	DataBytes      int64  `json:"data_bytes"`
+	SavedBytes     int64  `json:"saved_bytes"`
+}
+
+// ResponseDataReport is the storage of responses and revisions
+type ResponseDataReport struct {
+	Compression bool                `json:"compression"`
+	MinBytes    int                 `json:"min_bytes"`
+	Tables      []ResponseDataStats `json:"tables"`
+}
+
+// CompressionResult is what compressing stored response data did
+type CompressionResult struct {
+	Compressed int   `json:"compressed"`
+	SavedBytes int64 `json:"saved_bytes"`
+}
+
+// SummaryEmailSettings configure the copy of their answers emailed to
+// respondents. Subject and Template are Go text/template templates; empty ones
+// are the server defaults.
+type SummaryEmailSettings struct {
+	Enabled       bool   `json:"enabled"`
+	EmailQuestion string `json:"email_question"`
+	Subject       string `json:"subject"`
+	Template      string `json:"template"`
+}
+
+// EmailSuppression is an address no email is sent to
+type EmailSuppression struct {
+	Email     string    `json:"email"`
+	Reason    string    `json:"reason,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// SyncParams select the changes of a sync. Since is the NextCursor of the
+// previous sync, empty the first time; Keys are the Idempotency-Keys of
+// uploaded responses to acknowledge, at most 100.
+type SyncParams struct {
+	Since string
+	Limit int
+	Keys  []string
+}
+
+// SyncPage is what changed since the previous sync. Clients keep NextCursor
+// for their next sync, and sync again at once while HasMore.
+type SyncPage struct {
+	Surveys          []Survey                `json:"surveys"`
+	Removed          []int                   `json:"removed"`
+	Acknowledgements []UploadAcknowledgement `json:"acknowledgements"`
+	NextCursor       string                  `json:"-"`
+	HasMore          bool                    `json:"-"`
+}
+
+// UploadAcknowledgement reports whether the server has a response uploaded
+// with an Idempotency-Key: received, deleted or unknown
+type UploadAcknowledgement struct {
+	IdempotencyKey string     `json:"idempotency_key"`
+	Status         string     `json:"status"`
+	SurveyID       int        `json:"survey_id,omitempty"`
+	ResponseID     int        `json:"response_id,omitempty"`
+	GlobalID       string     `json:"global_id,omitempty"`
+	ReceivedAt     *time.Time `json:"received_at,omitempty"`
+}
+
+// Policies resolving an edit made to an older version of a response
+const (
+	ConflictReject     = "reject"
+	ConflictServerWins = "server_wins"
+	ConflictClientWins = "client_wins"
+	ConflictMerge      = "merge"
+)
+
+// ResponseConflict reports how an edit made to an older version of a response
+// was resolved
+type ResponseConflict struct {
+	Policy        string           `json:"policy"`
+	BaseVersion   int              `json:"base_version"`
+	ServerVersion int              `json:"server_version"`
+	Applied       bool             `json:"applied"`
+	Answers       []AnswerConflict `json:"answers"`
+}
+
+// AnswerConflict is an answer changed both by an edit and on the server
+type AnswerConflict struct {
+	QuestionID string          `json:"question_id"`
+	Base       json.RawMessage `json:"base"`
+	Server     json.RawMessage `json:"server"`
+	Client     json.RawMessage `json:"client"`
+	Kept       string          `json:"kept,omitempty"`
+}
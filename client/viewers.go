@@ -0,0 +1,33 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// ListViewers returns the viewer accounts, including revoked ones, without their
+// tokens. It requires a client created WithAdminToken.
+func (c *Client) ListViewers(ctx context.Context) ([]Viewer, error) {
+	var viewers []Viewer
+	err := c.do(ctx, http.MethodGet, "/api/admin/viewers", nil, nil, &viewers, nil)
+	return viewers, err
+}
+
+// CreateViewer creates a viewer account whose analytics only count responses
+// answering each attribute's question with its value, e.g. {"department": "Sales"}.
+// The returned Token, for WithViewerToken, is not shown again. It requires a
+// client created WithAdminToken.
+func (c *Client) CreateViewer(ctx context.Context, name string, attributes map[string]string) (*Viewer, error) {
+	body := map[string]interface{}{"viewer": map[string]interface{}{"name": name, "attributes": attributes}}
+	var viewer Viewer
+	if err := c.do(ctx, http.MethodPost, "/api/admin/viewers", nil, body, &viewer, nil); err != nil {
+		return nil, err
+	}
+	return &viewer, nil
+}
+
+// RevokeViewer disables a viewer account. It requires a client created WithAdminToken.
+func (c *Client) RevokeViewer(ctx context.Context, viewerID int) error {
+	return c.do(ctx, http.MethodDelete, fmt.Sprintf("/api/admin/viewers/%d", viewerID), nil, nil, nil, nil)
+}
@@ -0,0 +1,38 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// ListViews returns the saved response views of a survey
+func (c *Client) ListViews(ctx context.Context, surveyID int) ([]View, error) {
+	var views []View
+	err := c.do(ctx, http.MethodGet, fmt.Sprintf("/api/surveys/%d/views", surveyID), nil, nil, &views, nil)
+	return views, err
+}
+
+// GetView returns a saved response view
+func (c *Client) GetView(ctx context.Context, surveyID, viewID int) (*View, error) {
+	var view View
+	if err := c.do(ctx, http.MethodGet, fmt.Sprintf("/api/surveys/%d/views/%d", surveyID, viewID), nil, nil, &view, nil); err != nil {
+		return nil, err
+	}
+	return &view, nil
+}
+
+// CreateView saves a response view
+func (c *Client) CreateView(ctx context.Context, surveyID int, params CreateViewParams) (*View, error) {
+	body := map[string]interface{}{"view": params}
+	var view View
+	if err := c.do(ctx, http.MethodPost, fmt.Sprintf("/api/surveys/%d/views", surveyID), nil, body, &view, nil); err != nil {
+		return nil, err
+	}
+	return &view, nil
+}
+
+// DeleteView deletes a saved response view
+func (c *Client) DeleteView(ctx context.Context, surveyID, viewID int) error {
+	return c.do(ctx, http.MethodDelete, fmt.Sprintf("/api/surveys/%d/views/%d", surveyID, viewID), nil, nil, nil, nil)
+}
@@ -0,0 +1,68 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// ListWebhooks returns the webhook subscriptions, without their secrets.
+// It requires a client created WithAdminToken.
+func (c *Client) ListWebhooks(ctx context.Context) ([]Webhook, error) {
+	var webhooks []Webhook
+	err := c.do(ctx, http.MethodGet, "/api/admin/webhooks", nil, nil, &webhooks, nil)
+	return webhooks, err
+}
+
+// CreateWebhook subscribes a URL to webhook events. The returned Secret signs the
+// deliveries and is not shown again. It requires a client created WithAdminToken.
+func (c *Client) CreateWebhook(ctx context.Context, params CreateWebhookParams) (*Webhook, error) {
+	body := map[string]interface{}{"webhook": params}
+	var webhook Webhook
+	if err := c.do(ctx, http.MethodPost, "/api/admin/webhooks", nil, body, &webhook, nil); err != nil {
+		return nil, err
+	}
+	return &webhook, nil
+}
+
+// DeleteWebhook removes a webhook subscription. It requires a client created WithAdminToken.
+func (c *Client) DeleteWebhook(ctx context.Context, id int) error {
+	return c.do(ctx, http.MethodDelete, fmt.Sprintf("/api/admin/webhooks/%d", id), nil, nil, nil, nil)
+}
+
+// SubscribeRESTHook subscribes targetURL to one event per REST Hooks; the
+// deliveries are the event's data alone. A zero surveyID means every survey.
+// It requires a client created WithAdminToken.
+func (c *Client) SubscribeRESTHook(ctx context.Context, targetURL, event string, surveyID int) (*Webhook, error) {
+	body := map[string]interface{}{"target_url": targetURL, "event": event}
+	if surveyID != 0 {
+		body["survey_id"] = surveyID
+	}
+	var webhook Webhook
+	if err := c.do(ctx, http.MethodPost, "/api/admin/hooks", nil, body, &webhook, nil); err != nil {
+		return nil, err
+	}
+	return &webhook, nil
+}
+
+// RESTHookSample returns recent deliveries of event as a REST Hooks
+// subscriber would receive them, for setting up a trigger; a zero surveyID
+// means every survey. It requires a client created WithAdminToken.
+func (c *Client) RESTHookSample(ctx context.Context, event string, surveyID int) ([]json.RawMessage, error) {
+	q := url.Values{"event": {event}}
+	if surveyID != 0 {
+		q.Set("survey_id", strconv.Itoa(surveyID))
+	}
+	var items []json.RawMessage
+	err := c.do(ctx, http.MethodGet, "/api/admin/hooks/sample", q, nil, &items, nil)
+	return items, err
+}
+
+// UnsubscribeRESTHook removes a REST Hooks subscription. It requires a client
+// created WithAdminToken.
+func (c *Client) UnsubscribeRESTHook(ctx context.Context, id int) error {
+	return c.do(ctx, http.MethodDelete, fmt.Sprintf("/api/admin/hooks/%d", id), nil, nil, nil, nil)
+}
@@ -0,0 +1,70 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// The wizard builds a survey step by step: create it with CreateSurvey and
+// status draft, then set its pages, questions, logic and translations, check
+// ValidateSurvey and PublishSurvey. Only drafts can be edited this way.
+
+// SavePages replaces the pages of a draft survey
+func (c *Client) SavePages(ctx context.Context, surveyID int, pages []Page) (*Survey, error) {
+	return c.saveDraft(ctx, http.MethodPut, fmt.Sprintf("/api/surveys/%d/pages", surveyID), map[string]interface{}{"pages": pages})
+}
+
+// AddQuestion appends a question to a draft survey
+func (c *Client) AddQuestion(ctx context.Context, surveyID int, question Question) (*Survey, error) {
+	return c.saveDraft(ctx, http.MethodPost, fmt.Sprintf("/api/surveys/%d/questions", surveyID), map[string]interface{}{"question": question})
+}
+
+// RemoveQuestion removes a question from a draft survey
+func (c *Client) RemoveQuestion(ctx context.Context, surveyID int, questionID string) (*Survey, error) {
+	return c.saveDraft(ctx, http.MethodDelete, fmt.Sprintf("/api/surveys/%d/questions/%s", surveyID, url.PathEscape(questionID)), nil)
+}
+
+// SaveLogic replaces the logic rules of a draft survey
+func (c *Client) SaveLogic(ctx context.Context, surveyID int, logic []LogicRule) (*Survey, error) {
+	return c.saveDraft(ctx, http.MethodPut, fmt.Sprintf("/api/surveys/%d/logic", surveyID), map[string]interface{}{"logic": logic})
+}
+
+// SaveTranslations replaces the translations of a draft survey
+func (c *Client) SaveTranslations(ctx context.Context, surveyID int, translations map[string]SurveyTranslation) (*Survey, error) {
+	return c.saveDraft(ctx, http.MethodPut, fmt.Sprintf("/api/surveys/%d/translations", surveyID), map[string]interface{}{"translations": translations})
+}
+
+// ValidateSurvey lists every issue that keeps a survey from being published
+func (c *Client) ValidateSurvey(ctx context.Context, surveyID int) (*SurveyValidation, error) {
+	var validation SurveyValidation
+	if err := c.do(ctx, http.MethodGet, fmt.Sprintf("/api/surveys/%d/validation", surveyID), nil, nil, &validation, nil); err != nil {
+		return nil, err
+	}
+	return &validation, nil
+}
+
+// LintSurvey returns advisory warnings on a survey's design, such as questions
+// asking two things at once or made unreachable by its logic
+func (c *Client) LintSurvey(ctx context.Context, surveyID int) (*SurveyLint, error) {
+	var lint SurveyLint
+	if err := c.do(ctx, http.MethodGet, fmt.Sprintf("/api/surveys/%d/lint", surveyID), nil, nil, &lint, nil); err != nil {
+		return nil, err
+	}
+	return &lint, nil
+}
+
+// PublishSurvey publishes a draft survey. A survey with blocking issues fails
+// with code SURVEY_INVALID and the issues in the error's Errors.
+func (c *Client) PublishSurvey(ctx context.Context, surveyID int) (*Survey, error) {
+	return c.saveDraft(ctx, http.MethodPost, fmt.Sprintf("/api/surveys/%d/publish", surveyID), nil)
+}
+
+func (c *Client) saveDraft(ctx context.Context, method, path string, body interface{}) (*Survey, error) {
+	var survey Survey
+	if err := c.do(ctx, method, path, nil, body, &survey, nil); err != nil {
+		return nil, err
+	}
+	return &survey, nil
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"survey_form_go/client"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// setupTestClient serves the router over HTTP and returns a client for it
+func setupTestClient(t *testing.T) *client.Client {
+	// An in-memory database only lives as long as its connection
+	testDB.SetMaxOpenConns(1)
+
+	server := httptest.NewServer(setupTestRouter())
+	t.Cleanup(server.Close)
+	adminToken = "test-admin-token"
+	return client.New(server.URL, client.WithAdminToken(adminToken), client.WithRetries(0, 0))
+}
+
+func TestClientSurveysAndResponses(t *testing.T) {
+	setupTestDB()
+	defer testDB.Close()
+
+	c := setupTestClient(t)
+	ctx := context.Background()
+
+	survey, err := c.CreateSurvey(ctx, client.CreateSurveyParams{Title: "Client Survey", Description: "Created through the client"})
+	assert.NoError(t, err)
+	assert.Equal(t, "Client Survey", survey.Title)
+
+	schema, err := c.ResponseSchema(ctx, survey.ID)
+	assert.NoError(t, err)
+	assert.Contains(t, string(schema), `"type":"object"`)
+
+	surveys, err := c.ListSurveys(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, surveys, 1)
+
+	surveys, err = c.SearchSurveys(ctx, client.ListSurveysParams{Query: "nothing like it"})
+	assert.NoError(t, err)
+	assert.Empty(t, surveys)
+
+	response, err := c.CreateResponse(ctx, survey.ID, "testuser", map[string]string{"rating": "5"})
+	assert.NoError(t, err)
+	assert.True(t, response.Editable)
+
+	response, err = c.UpdateResponse(ctx, survey.ID, response.ID, map[string]string{"rating": "4"})
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"rating": "4"}`, string(response.ResponseData))
+
+	results, err := c.Results(ctx, survey.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, results.ResponsesCount)
+
+	errWatched := errors.New("watched")
+	err = c.WatchResults(ctx, survey.ID, func(live client.SurveyResults) error {
+		assert.Equal(t, 1, live.ResponsesCount)
+		return errWatched
+	})
+	assert.Equal(t, errWatched, err)
+
+	answers, err := c.QuestionAnswers(ctx, survey.ID, "rating")
+	assert.NoError(t, err)
+	assert.Len(t, answers, 1)
+	assert.JSONEq(t, `"4"`, string(answers[0].Answer))
+
+	userResponses, err := c.UserResponses(ctx, "testuser")
+	assert.NoError(t, err)
+	assert.Len(t, userResponses, 1)
+	assert.Equal(t, survey.ID, userResponses[0].Survey.ID)
+
+	partial, err := c.StartPartialResponse(ctx, survey.ID)
+	assert.NoError(t, err)
+	partial, err = c.SaveAnswers(ctx, survey.ID, partial.ID, map[string]interface{}{"rating": "3"}, &partial.Version)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, partial.Version)
+	code, err := c.CreateResumeCode(ctx, survey.ID, partial.ID)
+	assert.NoError(t, err)
+	resumed, err := c.RedeemResumeCode(ctx, survey.ID, code.Code)
+	assert.NoError(t, err)
+	assert.Equal(t, partial.ID, resumed.ID)
+	submitted, err := c.SubmitPartialResponse(ctx, survey.ID, partial.ID, "otheruser")
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"rating": "3"}`, string(submitted.ResponseData))
+	dropout, err := c.Dropout(ctx, survey.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, dropout.Submitted)
+
+	// API errors are returned as *client.APIError
+	_, err = c.GetSurvey(ctx, 999)
+	var apiErr *client.APIError
+	assert.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, http.StatusNotFound, apiErr.StatusCode)
+	assert.Equal(t, "SURVEY_NOT_FOUND", apiErr.Code)
+	assert.Equal(t, "Survey not found", apiErr.Message)
+	assert.Len(t, apiErr.RequestID, 32)
+}
+
+func TestClientResponseIterator(t *testing.T) {
+	setupTestDB()
+	defer testDB.Close()
+
+	surveyID, ids := insertTestResponses(t, 5)
+	c := setupTestClient(t)
+	ctx := context.Background()
+
+	var seen []int
+	it := c.Responses(int(surveyID), client.ListResponsesParams{Sort: "created_at", Order: "asc", Limit: 2})
+	for it.Next(ctx) {
+		seen = append(seen, it.Response().ID)
+	}
+	assert.NoError(t, it.Err())
+	assert.Equal(t, ids, seen)
+
+	page, err := c.ListResponses(ctx, int(surveyID), client.ListResponsesParams{
+		Answers: []client.AnswerFilter{{Question: "rating", Operator: "lt", Value: "5"}},
+	})
+	assert.NoError(t, err)
+	assert.Empty(t, page.Responses)
+
+	results, err := c.SearchResponses(ctx, int(surveyID), "5", 2)
+	assert.NoError(t, err)
+	assert.Len(t, results, 2)
+}
+
+func TestClientViewsAndAdmin(t *testing.T) {
+	setupTestDB()
+	defer testDB.Close()
+
+	c := setupTestClient(t)
+	ctx := context.Background()
+
+	survey, err := c.CreateSurvey(ctx, client.CreateSurveyParams{Title: "Client Survey", Description: "Created through the client"})
+	assert.NoError(t, err)
+
+	view, err := c.CreateView(ctx, survey.ID, client.CreateViewParams{Name: "Oldest first", Sort: "created_at", Order: "asc"})
+	assert.NoError(t, err)
+
+	views, err := c.ListViews(ctx, survey.ID)
+	assert.NoError(t, err)
+	assert.Len(t, views, 1)
+
+	assert.NoError(t, c.DeleteView(ctx, survey.ID, view.ID))
+
+	report, err := c.AnonymizeSurvey(ctx, survey.ID, client.AnonymizeParams{DryRun: true})
+	assert.NoError(t, err)
+	assert.True(t, report.DryRun)
+
+	hook, err := c.CreateWebhook(ctx, client.CreateWebhookParams{URL: "https://example.com/hook", Events: []string{"survey.closed"}})
+	assert.NoError(t, err)
+	assert.NotEmpty(t, hook.Secret)
+	hooks, err := c.ListWebhooks(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, hooks, 1)
+	assert.NoError(t, c.DeleteWebhook(ctx, hook.ID))
+
+	token, err := c.CreateSurveyToken(ctx, survey.ID, "Homepage")
+	assert.NoError(t, err)
+	rotated, err := c.RotateSurveyToken(ctx, survey.ID, token.ID)
+	assert.NoError(t, err)
+	assert.NotEqual(t, token.Token, rotated.Token)
+	assert.NoError(t, c.RevokeSurveyToken(ctx, survey.ID, token.ID))
+	tokens, err := c.ListSurveyTokens(ctx, survey.ID)
+	assert.NoError(t, err)
+	assert.Len(t, tokens, 1)
+	assert.NotNil(t, tokens[0].RevokedAt)
+
+	scan, err := c.ImportScannedResponse(ctx, survey.ID, "paper-1", "batch-1/page-1", map[string]client.ScannedField{
+		"comment": {Value: json.RawMessage(`"Gr8"`), Confidence: 0.4},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "needs_review", scan.Status)
+	queue, err := c.ScanReviewQueue(ctx, survey.ID)
+	assert.NoError(t, err)
+	assert.Len(t, queue, 1)
+	scan, err = c.FinalizeScannedResponse(ctx, scan.ID, map[string]interface{}{"comment": "Great"})
+	assert.NoError(t, err)
+	assert.NotNil(t, scan.ResponseID)
+
+	_, err = c.ImportScannedResponse(ctx, survey.ID, "paper-2", "", map[string]client.ScannedField{
+		"comment": {Value: json.RawMessage(`"Smudged"`), Confidence: 0.2},
+	})
+	assert.NoError(t, err)
+	items, err := c.ReviewQueue(ctx, survey.ID, "low_confidence")
+	assert.NoError(t, err)
+	if assert.Len(t, items, 1) {
+		item, err := c.FixReviewItem(ctx, survey.ID, items[0].ID, map[string]interface{}{"comment": "Smudged page"})
+		assert.NoError(t, err)
+		assert.Equal(t, "finalized", item.Scan.Status)
+	}
+
+	rules, err := c.RateLimits(ctx)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, rules)
+}
@@ -0,0 +1,345 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Kinds of failures reported by survey forms
+const (
+	ClientErrorRender = "render"
+	ClientErrorSubmit = "submit"
+	ClientErrorOther  = "other"
+)
+
+// clientErrorKinds lists the accepted kinds of client errors
+var clientErrorKinds = []string{ClientErrorRender, ClientErrorSubmit, ClientErrorOther}
+
+// Stored lengths of client error fields; longer values are cut
+const (
+	maxClientErrorMessage   = 500
+	maxClientErrorDetail    = 4000
+	maxClientErrorURL       = 2000
+	maxClientErrorUserAgent = 500
+)
+
+// clientErrorSummaryWindow is the period summarized when ?from= is not given
+const clientErrorSummaryWindow = 24 * time.Hour
+
+// ClientError is a failure reported by a hosted or embedded survey form
+type ClientError struct {
+	ID       int    `json:"id"`
+	SurveyID *int   `json:"survey_id"`
+	Kind     string `json:"kind"`
+	Message  string `json:"message"`
+	Detail   string `json:"detail"`
+	// PageURL is the page the form was shown on
+	PageURL string `json:"page_url"`
+	// RequestID is the X-Request-ID of the failed API call, if any
+	RequestID string    `json:"request_id"`
+	UserAgent string    `json:"user_agent"`
+	Device    string    `json:"device"`
+	Browser   string    `json:"browser"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// CreateClientErrorRequest represents the request body for reporting a client error
+type CreateClientErrorRequest struct {
+	ClientError struct {
+		SurveyID  *int   `json:"survey_id"`
+		Kind      string `json:"kind" binding:"required"`
+		Message   string `json:"message" binding:"required"`
+		Detail    string `json:"detail"`
+		PageURL   string `json:"page_url"`
+		RequestID string `json:"request_id"`
+	} `json:"client_error" binding:"required"`
+}
+
+// ClientErrorGroup counts the reports of one error of one survey
+type ClientErrorGroup struct {
+	SurveyID  *int      `json:"survey_id"`
+	Kind      string    `json:"kind"`
+	Message   string    `json:"message"`
+	Count     int       `json:"count"`
+	Devices   []string  `json:"devices"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+}
+
+// ClientErrorSummary groups the client errors reported in a period
+type ClientErrorSummary struct {
+	From   time.Time          `json:"from"`
+	To     time.Time          `json:"to"`
+	Total  int                `json:"total"`
+	Kinds  map[string]int     `json:"kinds"`
+	Groups []ClientErrorGroup `json:"groups"`
+}
+
+// truncate cuts s to at most n bytes
+func truncate(s string, n int) string {
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
+}
+
+// createClientError records a render or submit failure reported by a survey form.
+// Requests with a survey token may only report errors of the token's survey.
+func createClientError(c *gin.Context) {
+	ctx := c.Request.Context()
+	var req CreateClientErrorRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		abortWithError(c, errInvalidRequest(err))
+		return
+	}
+	report := req.ClientError
+
+	if tokenSurveyID, ok := c.Get("survey_token_survey_id"); ok {
+		if report.SurveyID == nil {
+			id := tokenSurveyID.(int)
+			report.SurveyID = &id
+		} else if *report.SurveyID != tokenSurveyID.(int) {
+			abortWithError(c, &APIError{
+				Status:  http.StatusForbidden,
+				Code:    CodeSurveyTokenDenied,
+				Message: "Survey token does not permit this request",
+			})
+			return
+		}
+	}
+
+	var errors []string
+	if !containsString(clientErrorKinds, report.Kind) {
+		errors = append(errors, "Kind must be one of "+strings.Join(clientErrorKinds, ", "))
+	}
+	if len(report.RequestID) > 64 {
+		errors = append(errors, "Request ID must be less than 64 characters")
+	}
+	if len(errors) > 0 {
+		abortWithError(c, errValidation("Failed to record client error", errors))
+		return
+	}
+
+	if report.SurveyID != nil {
+		var exists bool
+		err := db.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM surveys WHERE id = ?)", *report.SurveyID).Scan(&exists)
+		if err != nil || !exists {
+			abortWithError(c, errNotFound(CodeSurveyNotFound, "Survey not found"))
+			return
+		}
+	}
+
+	userAgent := c.GetHeader("User-Agent")
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO client_errors (survey_id, kind, message, detail, page_url, request_id, user_agent, device, browser, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
+	`, report.SurveyID, report.Kind, truncate(report.Message, maxClientErrorMessage), truncate(report.Detail, maxClientErrorDetail),
+		truncate(report.PageURL, maxClientErrorURL), report.RequestID, truncate(userAgent, maxClientErrorUserAgent),
+		deviceFromUserAgent(userAgent), browserFromUserAgent(userAgent))
+	if err != nil {
+		abortWithError(c, errInternal("Failed to record client error", err))
+		return
+	}
+
+	c.JSON(http.StatusCreated, APIResponse{
+		Status:  "success",
+		Message: "Client error recorded",
+	})
+}
+
+// clientErrorConditions reads the ?survey_id=, ?kind=, ?device=, ?from= and ?to=
+// filters of the client error routes
+func clientErrorConditions(c *gin.Context) (conditions []string, args []interface{}, errors []string) {
+	if value := c.Query("survey_id"); value != "" {
+		id, err := strconv.Atoi(value)
+		if err != nil {
+			errors = append(errors, "Survey ID must be a number")
+		}
+		conditions = append(conditions, "survey_id = ?")
+		args = append(args, id)
+	}
+	if value := c.Query("kind"); value != "" {
+		if !containsString(clientErrorKinds, value) {
+			errors = append(errors, "Kind must be one of "+strings.Join(clientErrorKinds, ", "))
+		}
+		conditions = append(conditions, "kind = ?")
+		args = append(args, value)
+	}
+	if value := c.Query("device"); value != "" {
+		conditions = append(conditions, "device = ?")
+		args = append(args, value)
+	}
+	for _, bound := range []struct{ param, operator, label string }{
+		{"from", ">=", "From"},
+		{"to", "<", "To"},
+	} {
+		value := c.Query(bound.param)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			errors = append(errors, bound.label+" must be an RFC 3339 timestamp")
+			continue
+		}
+		conditions = append(conditions, "created_at "+bound.operator+" ?")
+		args = append(args, t.UTC().Format(cursorTimeFormat))
+	}
+	return conditions, args, errors
+}
+
+// getClientErrors lists reported client errors, newest first, filtered by
+// ?survey_id=, ?kind=, ?device=, ?from= and ?to= (RFC 3339)
+func getClientErrors(c *gin.Context) {
+	ctx := c.Request.Context()
+	conditions, args, errors := clientErrorConditions(c)
+
+	limit := defaultPageSize
+	if value := c.Query("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > maxPageSize {
+			errors = append(errors, fmt.Sprintf("Limit must be between 1 and %d", maxPageSize))
+		}
+		limit = n
+	}
+
+	// The cursor is the ID of the last error of the previous page
+	if value := c.Query("cursor"); value != "" {
+		id, err := strconv.Atoi(value)
+		if err != nil {
+			errors = append(errors, "Cursor is invalid")
+		}
+		conditions = append(conditions, "id < ?")
+		args = append(args, id)
+	}
+
+	if len(errors) > 0 {
+		abortWithError(c, errInvalidQuery(errors))
+		return
+	}
+
+	where := "1 = 1"
+	if len(conditions) > 0 {
+		where = strings.Join(conditions, " AND ")
+	}
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT id, survey_id, kind, message, detail, page_url, request_id, user_agent, device, browser, created_at
+		FROM client_errors
+		WHERE `+where+`
+		ORDER BY id DESC
+		LIMIT ?
+	`, append(args, limit+1)...)
+	if err != nil {
+		abortWithError(c, errInternal("Failed to fetch client errors", err))
+		return
+	}
+	defer rows.Close()
+
+	reports := []ClientError{}
+	for rows.Next() {
+		var report ClientError
+		var surveyID sql.NullInt64
+		err := rows.Scan(&report.ID, &surveyID, &report.Kind, &report.Message, &report.Detail, &report.PageURL,
+			&report.RequestID, &report.UserAgent, &report.Device, &report.Browser, &report.CreatedAt)
+		if err != nil {
+			abortWithError(c, errInternal("Failed to scan client error data", err))
+			return
+		}
+		if surveyID.Valid {
+			id := int(surveyID.Int64)
+			report.SurveyID = &id
+		}
+		reports = append(reports, report)
+	}
+
+	meta := PageMeta{Limit: limit}
+	if len(reports) > limit {
+		reports = reports[:limit]
+		meta.NextCursor = strconv.Itoa(reports[len(reports)-1].ID)
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Status: "success",
+		Data:   reports,
+		Meta:   meta,
+	})
+}
+
+// getClientErrorSummary groups the client errors reported between ?from= (default
+// a day ago) and ?to= (default now) by survey, kind and message, most frequent first
+func getClientErrorSummary(c *gin.Context) {
+	ctx := c.Request.Context()
+	conditions, args, errors := clientErrorConditions(c)
+	if len(errors) > 0 {
+		abortWithError(c, errInvalidQuery(errors))
+		return
+	}
+
+	now := time.Now().UTC()
+	summary := ClientErrorSummary{From: now.Add(-clientErrorSummaryWindow), To: now, Kinds: map[string]int{}, Groups: []ClientErrorGroup{}}
+	if value := c.Query("from"); value != "" {
+		summary.From, _ = time.Parse(time.RFC3339, value)
+	} else {
+		conditions = append(conditions, "created_at >= ?")
+		args = append(args, summary.From.Format(cursorTimeFormat))
+	}
+	if value := c.Query("to"); value != "" {
+		summary.To, _ = time.Parse(time.RFC3339, value)
+	}
+	for _, kind := range clientErrorKinds {
+		summary.Kinds[kind] = 0
+	}
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT survey_id, kind, message, COUNT(*), GROUP_CONCAT(DISTINCT device), MIN(created_at), MAX(created_at)
+		FROM client_errors
+		WHERE `+strings.Join(conditions, " AND ")+`
+		GROUP BY survey_id, kind, message
+		ORDER BY COUNT(*) DESC, MAX(created_at) DESC
+	`, args...)
+	if err != nil {
+		abortWithError(c, errInternal("Failed to summarize client errors", err))
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var group ClientErrorGroup
+		var surveyID sql.NullInt64
+		var devices sql.NullString
+		var firstSeen, lastSeen string
+		if err := rows.Scan(&surveyID, &group.Kind, &group.Message, &group.Count, &devices, &firstSeen, &lastSeen); err != nil {
+			abortWithError(c, errInternal("Failed to scan client error data", err))
+			return
+		}
+		if surveyID.Valid {
+			id := int(surveyID.Int64)
+			group.SurveyID = &id
+		}
+		// Reports without a User-Agent the device can be told from are left out
+		group.Devices = []string{}
+		for _, device := range strings.Split(devices.String, ",") {
+			if device != "" {
+				group.Devices = append(group.Devices, device)
+			}
+		}
+		group.FirstSeen, _ = time.Parse(cursorTimeFormat, firstSeen)
+		group.LastSeen, _ = time.Parse(cursorTimeFormat, lastSeen)
+
+		summary.Total += group.Count
+		summary.Kinds[group.Kind] += group.Count
+		summary.Groups = append(summary.Groups, group)
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Status: "success",
+		Data:   summary,
+	})
+}
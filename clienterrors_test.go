@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientErrors(t *testing.T) {
+	setupTestDB()
+	defer testDB.Close()
+
+	result, err := testDB.Exec("INSERT INTO surveys (title, description) VALUES ('Embedded', 'd'), ('Other', 'd')")
+	assert.NoError(t, err)
+	otherID, _ := result.LastInsertId()
+	surveyID := otherID - 1
+
+	router := setupTestRouter()
+	report := func(body, userAgent, token string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/api/client-errors", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("User-Agent", userAgent)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		req.RemoteAddr = "192.0.2.1:1234"
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	const iphone = "Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) Version/17.0 Mobile Safari/604.1"
+	const desktop = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) Chrome/120.0 Safari/537.36"
+	submitFailed := fmt.Sprintf(`{"client_error":{"survey_id":%d,"kind":"submit","message":"Failed to fetch","request_id":"abc123"}}`, surveyID)
+	for _, userAgent := range []string{iphone, iphone, desktop} {
+		w := report(submitFailed, userAgent, "")
+		assert.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	}
+	w := report(`{"client_error":{"kind":"render","message":"Script error.","detail":"at embed.js:12"}}`, desktop, "")
+	assert.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+	w = report(`{"client_error":{"kind":"crash","message":"x"}}`, desktop, "")
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	w = report(`{"client_error":{"survey_id":999,"kind":"render","message":"x"}}`, desktop, "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	// Survey tokens report errors of their own survey, which is assumed when none is given
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, adminRequest("POST", fmt.Sprintf("/api/admin/surveys/%d/tokens", surveyID), []byte(`{"token":{"name":"Homepage"}}`)))
+	var issued struct {
+		Data SurveyToken `json:"data"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &issued)
+	w = report(`{"client_error":{"kind":"render","message":"Blocked by CSP"}}`, iphone, issued.Data.Token)
+	assert.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	w = report(fmt.Sprintf(`{"client_error":{"survey_id":%d,"kind":"render","message":"x"}}`, otherID), iphone, issued.Data.Token)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	// Reporting is public, the diagnostics are not
+	w = httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/admin/client-errors", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, adminRequest("GET", fmt.Sprintf("/api/admin/client-errors?survey_id=%d&limit=2", surveyID), nil))
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var listed struct {
+		Data []ClientError `json:"data"`
+		Meta PageMeta      `json:"meta"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &listed)
+	if assert.Len(t, listed.Data, 2) {
+		assert.Equal(t, "Blocked by CSP", listed.Data[0].Message)
+		assert.Equal(t, "mobile", listed.Data[0].Device)
+		assert.Equal(t, "safari", listed.Data[0].Browser)
+		assert.Equal(t, "abc123", listed.Data[1].RequestID)
+		assert.Equal(t, desktop, listed.Data[1].UserAgent)
+	}
+	assert.NotEmpty(t, listed.Meta.NextCursor)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, adminRequest("GET", "/api/admin/client-errors/summary", nil))
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var summary struct {
+		Data ClientErrorSummary `json:"data"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &summary)
+	assert.Equal(t, 5, summary.Data.Total)
+	assert.Equal(t, map[string]int{ClientErrorRender: 2, ClientErrorSubmit: 3, ClientErrorOther: 0}, summary.Data.Kinds)
+	if assert.Len(t, summary.Data.Groups, 3) {
+		group := summary.Data.Groups[0]
+		assert.Equal(t, int(surveyID), *group.SurveyID)
+		assert.Equal(t, "Failed to fetch", group.Message)
+		assert.Equal(t, 3, group.Count)
+		assert.ElementsMatch(t, []string{"mobile", "desktop"}, group.Devices)
+		assert.False(t, group.LastSeen.IsZero())
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, adminRequest("GET", "/api/admin/client-errors/summary?kind=bogus", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
@@ -0,0 +1,1681 @@
+// Code generated by "survey_form_go tsclient" from the OpenAPI document. DO NOT EDIT.
+
+export type QueryValue = string | number | boolean;
+
+/** Query parameters; filters such as answer[question][operator] go in by name. */
+export type Query<T> = T & Record<string, QueryValue | undefined>;
+
+/** The envelope of a successful response whose data is T. */
+export type Envelope<T> = Omit<APIResponse, "data"> & { data: T };
+
+export interface ClientOptions {
+  /** Where the API is served, e.g. "http://localhost:8081". */
+  baseURL: string;
+  /** Admin, creator, viewer or survey token, or aggregate API key, sent as a bearer token. */
+  token?: string;
+  /** Organization requests act in, sent as X-Organization-ID. */
+  organizationID?: number;
+  fetch?: typeof fetch;
+}
+
+export interface RequestOptions<Q = {}, H = {}> {
+  query?: Query<Q>;
+  headers?: H & Record<string, string | undefined>;
+  signal?: AbortSignal;
+}
+
+/** Thrown when the API answers with an error status. */
+export class APIError extends Error {
+  constructor(readonly status: number, readonly response: APIResponse) {
+    super(response.message || "HTTP " + status);
+    this.name = "APIError";
+  }
+
+  /** Identifies the error, e.g. "SURVEY_NOT_FOUND". */
+  get code(): string | undefined {
+    return this.response.code;
+  }
+}
+
+export class SurveyFormClient {
+  private readonly baseURL: string;
+
+  constructor(private readonly options: ClientOptions) {
+    this.baseURL = options.baseURL.replace(/\/+$/, "");
+  }
+
+  private async send(method: string, path: string, options: RequestOptions<any, any>, body?: unknown): Promise<Response> {
+    const url = new URL(this.baseURL + path);
+    for (const [name, value] of Object.entries(options.query ?? {})) {
+      if (value !== undefined) {
+        url.searchParams.set(name, String(value));
+      }
+    }
+    const headers: Record<string, string> = {};
+    if (this.options.token) {
+      headers["Authorization"] = "Bearer " + this.options.token;
+    }
+    if (this.options.organizationID) {
+      headers["X-Organization-ID"] = String(this.options.organizationID);
+    }
+    if (body !== undefined) {
+      headers["Content-Type"] = "application/json";
+    }
+    for (const [name, value] of Object.entries(options.headers ?? {})) {
+      if (value !== undefined) {
+        headers[name] = String(value);
+      }
+    }
+
+    const response = await (this.options.fetch ?? fetch)(url, {
+      method,
+      headers,
+      body: body === undefined ? undefined : JSON.stringify(body),
+      signal: options.signal,
+    });
+    if (!response.ok) {
+      let envelope: APIResponse;
+      try {
+        envelope = await response.json();
+      } catch {
+        envelope = { status: "error", message: response.statusText };
+      }
+      throw new APIError(response.status, envelope);
+    }
+    return response;
+  }
+
+  private async request<T>(method: string, path: string, options: RequestOptions<any, any>, body?: unknown): Promise<T> {
+    const response = await this.send(method, path, options, body);
+    return (await response.json()) as T;
+  }
+
+  /** List API keys */
+  getAdminApiKeys(options: RequestOptions = {}): Promise<Envelope<APIKey[]>> {
+    return this.request<Envelope<APIKey[]>>("GET", `/api/admin/api-keys`, options);
+  }
+
+  /** Create an API key limited to aggregate endpoints */
+  postAdminApiKeys(body: CreateAPIKeyRequest, options: RequestOptions = {}): Promise<Envelope<APIKey>> {
+    return this.request<Envelope<APIKey>>("POST", `/api/admin/api-keys`, options, body);
+  }
+
+  /** Revoke an API key */
+  deleteAdminApiKeysKeyId(keyId: number, options: RequestOptions = {}): Promise<APIResponse> {
+    return this.request<APIResponse>("DELETE", `/api/admin/api-keys/${encodeURIComponent(keyId)}`, options);
+  }
+
+  /** Query the audit log */
+  getAdminAudit(options: RequestOptions<{ entity_type?: QueryValue; entity_id?: QueryValue; action?: QueryValue; actor?: QueryValue; from?: QueryValue; to?: QueryValue; limit?: QueryValue; cursor?: QueryValue; }> = {}): Promise<Envelope<AuditEntry[]>> {
+    return this.request<Envelope<AuditEntry[]>>("GET", `/api/admin/audit`, options);
+  }
+
+  /** List failures reported by survey forms, newest first */
+  getAdminClientErrors(options: RequestOptions<{ limit?: QueryValue; cursor?: QueryValue; survey_id?: QueryValue; kind?: QueryValue; device?: QueryValue; from?: QueryValue; to?: QueryValue; }> = {}): Promise<Envelope<ClientError[]>> {
+    return this.request<Envelope<ClientError[]>>("GET", `/api/admin/client-errors`, options);
+  }
+
+  /** Group recent form failures by survey, kind and message */
+  getAdminClientErrorsSummary(options: RequestOptions<{ survey_id?: QueryValue; kind?: QueryValue; device?: QueryValue; from?: QueryValue; to?: QueryValue; }> = {}): Promise<Envelope<ClientErrorSummary>> {
+    return this.request<Envelope<ClientErrorSummary>>("GET", `/api/admin/client-errors/summary`, options);
+  }
+
+  /** List creator accounts */
+  getAdminCreators(options: RequestOptions = {}): Promise<Envelope<Creator[]>> {
+    return this.request<Envelope<Creator[]>>("GET", `/api/admin/creators`, options);
+  }
+
+  /** Create a creator account owning the surveys it creates */
+  postAdminCreators(body: CreateCreatorRequest, options: RequestOptions = {}): Promise<Envelope<Creator>> {
+    return this.request<Envelope<Creator>>("POST", `/api/admin/creators`, options, body);
+  }
+
+  /** Revoke a creator account */
+  deleteAdminCreatorsCreatorId(creatorId: number, options: RequestOptions = {}): Promise<APIResponse> {
+    return this.request<APIResponse>("DELETE", `/api/admin/creators/${encodeURIComponent(creatorId)}`, options);
+  }
+
+  /** List the addresses summary emails aren't sent to */
+  getAdminEmailSuppressions(options: RequestOptions = {}): Promise<Envelope<EmailSuppression[]>> {
+    return this.request<Envelope<EmailSuppression[]>>("GET", `/api/admin/email-suppressions`, options);
+  }
+
+  /** Stop summary emails to an address */
+  postAdminEmailSuppressions(body: CreateEmailSuppressionRequest, options: RequestOptions = {}): Promise<Envelope<EmailSuppression>> {
+    return this.request<Envelope<EmailSuppression>>("POST", `/api/admin/email-suppressions`, options, body);
+  }
+
+  /** Let summary emails reach an address again */
+  deleteAdminEmailSuppressionsEmail(email: string, options: RequestOptions = {}): Promise<APIResponse> {
+    return this.request<APIResponse>("DELETE", `/api/admin/email-suppressions/${encodeURIComponent(email)}`, options);
+  }
+
+  /** Subscribe a target URL to one event, per REST Hooks */
+  postAdminHooks(body: SubscribeRESTHookRequest, options: RequestOptions = {}): Promise<Envelope<WebhookSubscription>> {
+    return this.request<Envelope<WebhookSubscription>>("POST", `/api/admin/hooks`, options, body);
+  }
+
+  /** List recent items shaped like an event's REST hook deliveries */
+  getAdminHooksSample(options: RequestOptions<{ event?: QueryValue; survey_id?: QueryValue; }> = {}): Promise<Envelope<unknown[]>> {
+    return this.request<Envelope<unknown[]>>("GET", `/api/admin/hooks/sample`, options);
+  }
+
+  /** Unsubscribe a REST hook */
+  deleteAdminHooksWebhookId(webhookId: number, options: RequestOptions = {}): Promise<APIResponse> {
+    return this.request<APIResponse>("DELETE", `/api/admin/hooks/${encodeURIComponent(webhookId)}`, options);
+  }
+
+  /** List background jobs, such as the dead letters */
+  getAdminJobs(options: RequestOptions<{ status?: QueryValue; kind?: QueryValue; limit?: QueryValue; }> = {}): Promise<Envelope<Job[]>> {
+    return this.request<Envelope<Job[]>>("GET", `/api/admin/jobs`, options);
+  }
+
+  /** Get a background job with its payload and last error */
+  getAdminJobsJobId(jobId: number, options: RequestOptions = {}): Promise<Envelope<Job>> {
+    return this.request<Envelope<Job>>("GET", `/api/admin/jobs/${encodeURIComponent(jobId)}`, options);
+  }
+
+  /** Discard a background job */
+  deleteAdminJobsJobId(jobId: number, options: RequestOptions = {}): Promise<APIResponse> {
+    return this.request<APIResponse>("DELETE", `/api/admin/jobs/${encodeURIComponent(jobId)}`, options);
+  }
+
+  /** Queue a dead job again with its attempts reset */
+  postAdminJobsJobIdRequeue(jobId: number, options: RequestOptions = {}): Promise<Envelope<Job>> {
+    return this.request<Envelope<Job>>("POST", `/api/admin/jobs/${encodeURIComponent(jobId)}/requeue`, options);
+  }
+
+  /** List the org hierarchy's units */
+  getAdminOrgUnits(options: RequestOptions = {}): Promise<Envelope<OrgUnit[]>> {
+    return this.request<Envelope<OrgUnit[]>>("GET", `/api/admin/org-units`, options);
+  }
+
+  /** Create an org unit, under a parent or as a root */
+  postAdminOrgUnits(body: CreateOrgUnitRequest, options: RequestOptions = {}): Promise<Envelope<OrgUnit>> {
+    return this.request<Envelope<OrgUnit>>("POST", `/api/admin/org-units`, options, body);
+  }
+
+  /** Delete an org unit without children */
+  deleteAdminOrgUnitsUnitId(unitId: number, options: RequestOptions = {}): Promise<APIResponse> {
+    return this.request<APIResponse>("DELETE", `/api/admin/org-units/${encodeURIComponent(unitId)}`, options);
+  }
+
+  /** List organizations */
+  getAdminOrganizations(options: RequestOptions = {}): Promise<Envelope<Organization[]>> {
+    return this.request<Envelope<Organization[]>>("GET", `/api/admin/organizations`, options);
+  }
+
+  /** Create an organization */
+  postAdminOrganizations(body: CreateOrganizationRequest, options: RequestOptions = {}): Promise<Envelope<Organization>> {
+    return this.request<Envelope<Organization>>("POST", `/api/admin/organizations`, options, body);
+  }
+
+  /** Serve an organization's public forms on a custom domain */
+  putAdminOrganizationsOrgIdDomain(orgId: number, body: SaveDomainRequest, options: RequestOptions = {}): Promise<Envelope<Organization>> {
+    return this.request<Envelope<Organization>>("PUT", `/api/admin/organizations/${encodeURIComponent(orgId)}/domain`, options, body);
+  }
+
+  /** Stop serving an organization's public forms on its custom domain */
+  deleteAdminOrganizationsOrgIdDomain(orgId: number, options: RequestOptions = {}): Promise<Envelope<Organization>> {
+    return this.request<Envelope<Organization>>("DELETE", `/api/admin/organizations/${encodeURIComponent(orgId)}/domain`, options);
+  }
+
+  /** List the creator accounts of an organization */
+  getAdminOrganizationsOrgIdMembers(orgId: number, options: RequestOptions = {}): Promise<Envelope<OrganizationMember[]>> {
+    return this.request<Envelope<OrganizationMember[]>>("GET", `/api/admin/organizations/${encodeURIComponent(orgId)}/members`, options);
+  }
+
+  /** Add a creator account to an organization */
+  postAdminOrganizationsOrgIdMembers(orgId: number, body: AddMemberRequest, options: RequestOptions = {}): Promise<APIResponse> {
+    return this.request<APIResponse>("POST", `/api/admin/organizations/${encodeURIComponent(orgId)}/members`, options, body);
+  }
+
+  /** Remove a creator account from an organization */
+  deleteAdminOrganizationsOrgIdMembersCreatorId(orgId: number, creatorId: number, options: RequestOptions = {}): Promise<APIResponse> {
+    return this.request<APIResponse>("DELETE", `/api/admin/organizations/${encodeURIComponent(orgId)}/members/${encodeURIComponent(creatorId)}`, options);
+  }
+
+  /** Compress the response data stored before compression was turned on */
+  postAdminResponseDataCompress(options: RequestOptions = {}): Promise<Envelope<CompressionResult>> {
+    return this.request<Envelope<CompressionResult>>("POST", `/api/admin/response-data/compress`, options);
+  }
+
+  /** Measure stored response data and the space compression saves */
+  getAdminResponseDataStats(options: RequestOptions = {}): Promise<Envelope<ResponseDataReport>> {
+    return this.request<Envelope<ResponseDataReport>>("GET", `/api/admin/response-data/stats`, options);
+  }
+
+  /** List the scanned response review queue */
+  getAdminScannedResponses(options: RequestOptions<{ status?: QueryValue; survey_id?: QueryValue; limit?: QueryValue; }> = {}): Promise<Envelope<ScannedResponse[]>> {
+    return this.request<Envelope<ScannedResponse[]>>("GET", `/api/admin/scanned-responses`, options);
+  }
+
+  /** Finalize a reviewed scan into a survey response */
+  postAdminScannedResponsesScanIdFinalize(scanId: number, body: ReviewRequest, options: RequestOptions = {}): Promise<Envelope<ScannedResponse>> {
+    return this.request<Envelope<ScannedResponse>>("POST", `/api/admin/scanned-responses/${encodeURIComponent(scanId)}/finalize`, options, body);
+  }
+
+  /** Reject an unreadable scan */
+  postAdminScannedResponsesScanIdReject(scanId: number, options: RequestOptions = {}): Promise<Envelope<ScannedResponse>> {
+    return this.request<Envelope<ScannedResponse>>("POST", `/api/admin/scanned-responses/${encodeURIComponent(scanId)}/reject`, options);
+  }
+
+  /** Report this instance's slowest recent SQL statements by route */
+  getAdminSlowQueries(options: RequestOptions<{ limit?: QueryValue; }> = {}): Promise<Envelope<SlowQueryReport>> {
+    return this.request<Envelope<SlowQueryReport>>("GET", `/api/admin/slow-queries`, options);
+  }
+
+  /** Delete a survey, blocked while in use unless cascading */
+  deleteAdminSurveysId(id: number, options: RequestOptions<{ mode?: QueryValue; }> = {}): Promise<Envelope<SurveyImpact>> {
+    return this.request<Envelope<SurveyImpact>>("DELETE", `/api/admin/surveys/${encodeURIComponent(id)}`, options);
+  }
+
+  /** Anonymize a survey's responses */
+  postAdminSurveysIdAnonymize(id: number, body: AnonymizeRequest, options: RequestOptions = {}): Promise<Envelope<AnonymizeReport>> {
+    return this.request<Envelope<AnonymizeReport>>("POST", `/api/admin/surveys/${encodeURIComponent(id)}/anonymize`, options, body);
+  }
+
+  /** List a survey's scheduled exports */
+  getAdminSurveysIdExportJobs(id: number, options: RequestOptions = {}): Promise<Envelope<ExportJob[]>> {
+    return this.request<Envelope<ExportJob[]>>("GET", `/api/admin/surveys/${encodeURIComponent(id)}/export-jobs`, options);
+  }
+
+  /** Export a survey's responses to S3 or SFTP every interval */
+  postAdminSurveysIdExportJobs(id: number, body: CreateExportJobRequest, options: RequestOptions = {}): Promise<Envelope<ExportJob>> {
+    return this.request<Envelope<ExportJob>>("POST", `/api/admin/surveys/${encodeURIComponent(id)}/export-jobs`, options, body);
+  }
+
+  /** Get a scheduled export with its status */
+  getAdminSurveysIdExportJobsJobId(id: number, jobId: number, options: RequestOptions = {}): Promise<Envelope<ExportJob>> {
+    return this.request<Envelope<ExportJob>>("GET", `/api/admin/surveys/${encodeURIComponent(id)}/export-jobs/${encodeURIComponent(jobId)}`, options);
+  }
+
+  /** Stop a scheduled export */
+  deleteAdminSurveysIdExportJobsJobId(id: number, jobId: number, options: RequestOptions = {}): Promise<APIResponse> {
+    return this.request<APIResponse>("DELETE", `/api/admin/surveys/${encodeURIComponent(id)}/export-jobs/${encodeURIComponent(jobId)}`, options);
+  }
+
+  /** Get the last run of a scheduled export */
+  getAdminSurveysIdExportJobsJobIdLastRun(id: number, jobId: number, options: RequestOptions = {}): Promise<Envelope<ExportRun>> {
+    return this.request<Envelope<ExportRun>>("GET", `/api/admin/surveys/${encodeURIComponent(id)}/export-jobs/${encodeURIComponent(jobId)}/last-run`, options);
+  }
+
+  /** List what deleting a survey would break or remove */
+  getAdminSurveysIdImpact(id: number, options: RequestOptions = {}): Promise<Envelope<SurveyImpact>> {
+    return this.request<Envelope<SurveyImpact>>("GET", `/api/admin/surveys/${encodeURIComponent(id)}/impact`, options);
+  }
+
+  /** Restore a deleted response */
+  postAdminSurveysIdResponsesResponseIdRestore(id: number, responseId: number, options: RequestOptions = {}): Promise<Envelope<SurveyResponse>> {
+    return this.request<Envelope<SurveyResponse>>("POST", `/api/admin/surveys/${encodeURIComponent(id)}/responses/${encodeURIComponent(responseId)}/restore`, options);
+  }
+
+  /** Export a response with its revisions and history */
+  getAdminSurveysIdResponsesResponseIdThread(id: number, responseId: number, options: RequestOptions<{ format?: QueryValue; }> = {}): Promise<Envelope<ResponseThread>> {
+    return this.request<Envelope<ResponseThread>>("GET", `/api/admin/surveys/${encodeURIComponent(id)}/responses/${encodeURIComponent(responseId)}/thread`, options);
+  }
+
+  /** Import a scanned paper response; low-confidence fields are queued for review */
+  postAdminSurveysIdScannedResponses(id: number, body: CreateScannedResponseRequest, options: RequestOptions = {}): Promise<Envelope<ScannedResponse>> {
+    return this.request<Envelope<ScannedResponse>>("POST", `/api/admin/surveys/${encodeURIComponent(id)}/scanned-responses`, options, body);
+  }
+
+  /** List a survey's embed tokens */
+  getAdminSurveysIdTokens(id: number, options: RequestOptions = {}): Promise<Envelope<SurveyToken[]>> {
+    return this.request<Envelope<SurveyToken[]>>("GET", `/api/admin/surveys/${encodeURIComponent(id)}/tokens`, options);
+  }
+
+  /** Issue a token that can only fetch and answer the survey */
+  postAdminSurveysIdTokens(id: number, body: CreateSurveyTokenRequest, options: RequestOptions = {}): Promise<Envelope<SurveyToken>> {
+    return this.request<Envelope<SurveyToken>>("POST", `/api/admin/surveys/${encodeURIComponent(id)}/tokens`, options, body);
+  }
+
+  /** Revoke a survey token */
+  deleteAdminSurveysIdTokensTokenId(id: number, tokenId: number, options: RequestOptions = {}): Promise<APIResponse> {
+    return this.request<APIResponse>("DELETE", `/api/admin/surveys/${encodeURIComponent(id)}/tokens/${encodeURIComponent(tokenId)}`, options);
+  }
+
+  /** Replace a survey token's value */
+  postAdminSurveysIdTokensTokenIdRotate(id: number, tokenId: number, options: RequestOptions = {}): Promise<Envelope<SurveyToken>> {
+    return this.request<Envelope<SurveyToken>>("POST", `/api/admin/surveys/${encodeURIComponent(id)}/tokens/${encodeURIComponent(tokenId)}/rotate`, options);
+  }
+
+  /** List viewer accounts */
+  getAdminViewers(options: RequestOptions = {}): Promise<Envelope<Viewer[]>> {
+    return this.request<Envelope<Viewer[]>>("GET", `/api/admin/viewers`, options);
+  }
+
+  /** Create a viewer whose analytics are scoped to its attributes */
+  postAdminViewers(body: CreateViewerRequest, options: RequestOptions = {}): Promise<Envelope<Viewer>> {
+    return this.request<Envelope<Viewer>>("POST", `/api/admin/viewers`, options, body);
+  }
+
+  /** Revoke a viewer account */
+  deleteAdminViewersViewerId(viewerId: number, options: RequestOptions = {}): Promise<APIResponse> {
+    return this.request<APIResponse>("DELETE", `/api/admin/viewers/${encodeURIComponent(viewerId)}`, options);
+  }
+
+  /** List webhook subscriptions */
+  getAdminWebhooks(options: RequestOptions = {}): Promise<Envelope<WebhookSubscription[]>> {
+    return this.request<Envelope<WebhookSubscription[]>>("GET", `/api/admin/webhooks`, options);
+  }
+
+  /** Subscribe a URL to webhook events */
+  postAdminWebhooks(body: CreateWebhookRequest, options: RequestOptions = {}): Promise<Envelope<WebhookSubscription>> {
+    return this.request<Envelope<WebhookSubscription>>("POST", `/api/admin/webhooks`, options, body);
+  }
+
+  /** Delete a webhook subscription */
+  deleteAdminWebhooksWebhookId(webhookId: number, options: RequestOptions = {}): Promise<APIResponse> {
+    return this.request<APIResponse>("DELETE", `/api/admin/webhooks/${encodeURIComponent(webhookId)}`, options);
+  }
+
+  /** Report a render or submit failure of a survey form */
+  postClientErrors(body: CreateClientErrorRequest, options: RequestOptions = {}): Promise<APIResponse> {
+    return this.request<APIResponse>("POST", `/api/client-errors`, options, body);
+  }
+
+  /** List the open surveys marked discoverable, when the directory is enabled */
+  getDiscover(options: RequestOptions<{ cursor?: QueryValue; limit?: QueryValue; }> = {}): Promise<Envelope<DiscoverableSurvey[]>> {
+    return this.request<Envelope<DiscoverableSurvey[]>>("GET", `/api/discover`, options);
+  }
+
+  /** List rate limits */
+  getLimits(options: RequestOptions = {}): Promise<Envelope<RateLimitRule[]>> {
+    return this.request<Envelope<RateLimitRule[]>>("GET", `/api/limits`, options);
+  }
+
+  /** Get a survey (srv_) or response (rsp_) by its global ID, as its own route returns it */
+  getLookupGlobalId(globalId: string, options: RequestOptions = {}): Promise<Envelope<Survey>> {
+    return this.request<Envelope<Survey>>("GET", `/api/lookup/${encodeURIComponent(globalId)}`, options);
+  }
+
+  /** Get the calling creator's notification preferences */
+  getNotificationPreferences(options: RequestOptions = {}): Promise<Envelope<NotificationPreferences>> {
+    return this.request<Envelope<NotificationPreferences>>("GET", `/api/notification-preferences`, options);
+  }
+
+  /** Choose the events, channels, delivery and quiet hours of the calling creator's notifications */
+  putNotificationPreferences(body: SaveNotificationPreferencesRequest, options: RequestOptions = {}): Promise<Envelope<NotificationPreferences>> {
+    return this.request<Envelope<NotificationPreferences>>("PUT", `/api/notification-preferences`, options, body);
+  }
+
+  /** List surveys */
+  getSurveys(options: RequestOptions<{ q?: QueryValue; status?: QueryValue; sort?: QueryValue; order?: QueryValue; all?: QueryValue; }> = {}): Promise<Envelope<Survey[]>> {
+    return this.request<Envelope<Survey[]>>("GET", `/api/surveys`, options);
+  }
+
+  /** Create a survey */
+  postSurveys(body: CreateSurveyRequest, options: RequestOptions = {}): Promise<Envelope<Survey>> {
+    return this.request<Envelope<Survey>>("POST", `/api/surveys`, options, body);
+  }
+
+  /** Get a survey; drafts return a coming soon payload */
+  getSurveysId(id: number, options: RequestOptions = {}): Promise<Envelope<Survey>> {
+    return this.request<Envelope<Survey>>("GET", `/api/surveys/${encodeURIComponent(id)}`, options);
+  }
+
+  /** List a survey in the public directory, or take it out (admin only) */
+  putSurveysIdDiscoverable(id: number, body: SaveDiscoverableRequest, options: RequestOptions = {}): Promise<Envelope<Survey>> {
+    return this.request<Envelope<Survey>>("PUT", `/api/surveys/${encodeURIComponent(id)}/discoverable`, options, body);
+  }
+
+  /** Count where respondents abandon the survey */
+  getSurveysIdDropout(id: number, options: RequestOptions = {}): Promise<Envelope<DropoutReport>> {
+    return this.request<Envelope<DropoutReport>>("GET", `/api/surveys/${encodeURIComponent(id)}/dropout`, options);
+  }
+
+  /** Get render error rates, median load time and device and browser breakdowns */
+  getSurveysIdExperience(id: number, options: RequestOptions<{ from?: QueryValue; to?: QueryValue; }> = {}): Promise<Envelope<ExperienceMetrics>> {
+    return this.request<Envelope<ExperienceMetrics>>("GET", `/api/surveys/${encodeURIComponent(id)}/experience`, options);
+  }
+
+  /** Report a render or render error of the survey form */
+  postSurveysIdExperienceEvents(id: number, body: CreateExperienceEventRequest, options: RequestOptions = {}): Promise<APIResponse> {
+    return this.request<APIResponse>("POST", `/api/surveys/${encodeURIComponent(id)}/experience-events`, options, body);
+  }
+
+  /** List saved formulas */
+  getSurveysIdFormulas(id: number, options: RequestOptions = {}): Promise<Envelope<Formula[]>> {
+    return this.request<Envelope<Formula[]>>("GET", `/api/surveys/${encodeURIComponent(id)}/formulas`, options);
+  }
+
+  /** Save a formula */
+  postSurveysIdFormulas(id: number, body: CreateFormulaRequest, options: RequestOptions = {}): Promise<Envelope<Formula>> {
+    return this.request<Envelope<Formula>>("POST", `/api/surveys/${encodeURIComponent(id)}/formulas`, options, body);
+  }
+
+  /** Get a saved formula */
+  getSurveysIdFormulasFormulaId(id: number, formulaId: number, options: RequestOptions = {}): Promise<Envelope<Formula>> {
+    return this.request<Envelope<Formula>>("GET", `/api/surveys/${encodeURIComponent(id)}/formulas/${encodeURIComponent(formulaId)}`, options);
+  }
+
+  /** Delete a saved formula */
+  deleteSurveysIdFormulasFormulaId(id: number, formulaId: number, options: RequestOptions = {}): Promise<APIResponse> {
+    return this.request<APIResponse>("DELETE", `/api/surveys/${encodeURIComponent(id)}/formulas/${encodeURIComponent(formulaId)}`, options);
+  }
+
+  /** Get the daily snapshots of a survey's aggregates, oldest first */
+  getSurveysIdHistory(id: number, options: RequestOptions<{ from?: QueryValue; to?: QueryValue; }> = {}): Promise<Envelope<SurveySnapshot[]>> {
+    return this.request<Envelope<SurveySnapshot[]>>("GET", `/api/surveys/${encodeURIComponent(id)}/history`, options);
+  }
+
+  /** Let search engines index a survey's hosted form, or keep it link-only (admin only) */
+  putSurveysIdIndexing(id: number, body: SaveIndexingRequest, options: RequestOptions = {}): Promise<Envelope<Survey>> {
+    return this.request<Envelope<Survey>>("PUT", `/api/surveys/${encodeURIComponent(id)}/indexing`, options, body);
+  }
+
+  /** List a survey's email invitations and their status */
+  getSurveysIdInvitations(id: number, options: RequestOptions<{ status?: QueryValue; }> = {}): Promise<Envelope<Invitation[]>> {
+    return this.request<Envelope<Invitation[]>>("GET", `/api/surveys/${encodeURIComponent(id)}/invitations`, options);
+  }
+
+  /** Invite a list of emails to a survey */
+  postSurveysIdInvitations(id: number, body: CreateInvitationsRequest, options: RequestOptions = {}): Promise<Envelope<InvitationUpload>> {
+    return this.request<Envelope<InvitationUpload>>("POST", `/api/surveys/${encodeURIComponent(id)}/invitations`, options, body);
+  }
+
+  /** Get a survey's reminder settings and preview who will be reminded, and when */
+  getSurveysIdInvitationsReminders(id: number, options: RequestOptions = {}): Promise<Envelope<ReminderPreview>> {
+    return this.request<Envelope<ReminderPreview>>("GET", `/api/surveys/${encodeURIComponent(id)}/invitations/reminders`, options);
+  }
+
+  /** Configure reminders to invitees who haven't responded */
+  putSurveysIdInvitationsReminders(id: number, body: SaveReminderSettingsRequest, options: RequestOptions = {}): Promise<Envelope<ReminderSettings>> {
+    return this.request<Envelope<ReminderSettings>>("PUT", `/api/surveys/${encodeURIComponent(id)}/invitations/reminders`, options, body);
+  }
+
+  /** Send an invitation again with a new link */
+  postSurveysIdInvitationsInvitationIdResend(id: number, invitationId: number, options: RequestOptions = {}): Promise<Envelope<Invitation>> {
+    return this.request<Envelope<Invitation>>("POST", `/api/surveys/${encodeURIComponent(id)}/invitations/${encodeURIComponent(invitationId)}/resend`, options);
+  }
+
+  /** Advisory warnings on the survey's design */
+  getSurveysIdLint(id: number, options: RequestOptions = {}): Promise<Envelope<SurveyLint>> {
+    return this.request<Envelope<SurveyLint>>("GET", `/api/surveys/${encodeURIComponent(id)}/lint`, options);
+  }
+
+  /** Set the logic rules of a draft survey */
+  putSurveysIdLogic(id: number, body: SaveLogicRequest, options: RequestOptions = {}): Promise<Envelope<Survey>> {
+    return this.request<Envelope<Survey>>("PUT", `/api/surveys/${encodeURIComponent(id)}/logic`, options, body);
+  }
+
+  /** Get the Net Promoter Score of a 0-10 question, optionally by week */
+  getSurveysIdNps(id: number, options: RequestOptions<{ question?: QueryValue; interval?: QueryValue; from?: QueryValue; to?: QueryValue; channel?: QueryValue; country?: QueryValue; device?: QueryValue; moderation_status?: QueryValue; bot_score_min?: QueryValue; bot_score_max?: QueryValue; quality_flag?: QueryValue; exclude_quality?: QueryValue; }> = {}): Promise<Envelope<NPSReport>> {
+    return this.request<Envelope<NPSReport>>("GET", `/api/surveys/${encodeURIComponent(id)}/nps`, options);
+  }
+
+  /** Set the pages of a draft survey */
+  putSurveysIdPages(id: number, body: SavePagesRequest, options: RequestOptions = {}): Promise<Envelope<Survey>> {
+    return this.request<Envelope<Survey>>("PUT", `/api/surveys/${encodeURIComponent(id)}/pages`, options, body);
+  }
+
+  /** Start autosaving a respondent's answers */
+  postSurveysIdPartialResponses(id: number, options: RequestOptions = {}): Promise<Envelope<PartialResponse>> {
+    return this.request<Envelope<PartialResponse>>("POST", `/api/surveys/${encodeURIComponent(id)}/partial-responses`, options);
+  }
+
+  /** Redeem a resume code for its partial response */
+  postSurveysIdPartialResponsesResume(id: number, body: RedeemResumeCodeRequest, options: RequestOptions = {}): Promise<Envelope<PartialResponse>> {
+    return this.request<Envelope<PartialResponse>>("POST", `/api/surveys/${encodeURIComponent(id)}/partial-responses/resume`, options, body);
+  }
+
+  /** Get the autosaved answers */
+  getSurveysIdPartialResponsesPartialId(id: number, partialId: string, options: RequestOptions = {}): Promise<Envelope<PartialResponse>> {
+    return this.request<Envelope<PartialResponse>>("GET", `/api/surveys/${encodeURIComponent(id)}/partial-responses/${encodeURIComponent(partialId)}`, options);
+  }
+
+  /** Merge answers into a partial response */
+  patchSurveysIdPartialResponsesPartialId(id: number, partialId: string, body: SavePartialResponseRequest, options: RequestOptions = {}): Promise<Envelope<PartialResponse>> {
+    return this.request<Envelope<PartialResponse>>("PATCH", `/api/surveys/${encodeURIComponent(id)}/partial-responses/${encodeURIComponent(partialId)}`, options, body);
+  }
+
+  /** Issue a short code for resuming on another device */
+  postSurveysIdPartialResponsesPartialIdResumeCode(id: number, partialId: string, options: RequestOptions = {}): Promise<Envelope<ResumeCode>> {
+    return this.request<Envelope<ResumeCode>>("POST", `/api/surveys/${encodeURIComponent(id)}/partial-responses/${encodeURIComponent(partialId)}/resume-code`, options);
+  }
+
+  /** Publish a draft survey without blocking issues */
+  postSurveysIdPublish(id: number, options: RequestOptions = {}): Promise<Envelope<Survey>> {
+    return this.request<Envelope<Survey>>("POST", `/api/surveys/${encodeURIComponent(id)}/publish`, options);
+  }
+
+  /** Score the data quality of the responses */
+  getSurveysIdQuality(id: number, options: RequestOptions<{ from?: QueryValue; to?: QueryValue; channel?: QueryValue; country?: QueryValue; device?: QueryValue; moderation_status?: QueryValue; bot_score_min?: QueryValue; bot_score_max?: QueryValue; quality_flag?: QueryValue; exclude_quality?: QueryValue; }> = {}): Promise<Envelope<QualityReport>> {
+    return this.request<Envelope<QualityReport>>("GET", `/api/surveys/${encodeURIComponent(id)}/quality`, options);
+  }
+
+  /** Add a question to a draft survey */
+  postSurveysIdQuestions(id: number, body: AddQuestionRequest, options: RequestOptions = {}): Promise<Envelope<Survey>> {
+    return this.request<Envelope<Survey>>("POST", `/api/surveys/${encodeURIComponent(id)}/questions`, options, body);
+  }
+
+  /** Remove a question from a draft survey */
+  deleteSurveysIdQuestionsQuestionId(id: number, questionId: string, options: RequestOptions = {}): Promise<Envelope<Survey>> {
+    return this.request<Envelope<Survey>>("DELETE", `/api/surveys/${encodeURIComponent(id)}/questions/${encodeURIComponent(questionId)}`, options);
+  }
+
+  /** Export the answers to one question as CSV, or JSON with format=json */
+  getSurveysIdQuestionsQuestionIdAnswers(id: number, questionId: string, options: RequestOptions<{ format?: QueryValue; recode?: QueryValue; from?: QueryValue; to?: QueryValue; channel?: QueryValue; country?: QueryValue; device?: QueryValue; moderation_status?: QueryValue; bot_score_min?: QueryValue; bot_score_max?: QueryValue; quality_flag?: QueryValue; exclude_quality?: QueryValue; }> = {}): Promise<Envelope<QuestionAnswer[]>> {
+    return this.request<Envelope<QuestionAnswer[]>>("GET", `/api/surveys/${encodeURIComponent(id)}/questions/${encodeURIComponent(questionId)}/answers`, options);
+  }
+
+  /** Mark a question as PII, masking its answers for non-admins (admin only) */
+  putSurveysIdQuestionsQuestionIdPii(id: number, questionId: string, body: SavePIIRequest, options: RequestOptions = {}): Promise<Envelope<Survey>> {
+    return this.request<Envelope<Survey>>("PUT", `/api/surveys/${encodeURIComponent(id)}/questions/${encodeURIComponent(questionId)}/pii`, options, body);
+  }
+
+  /** Set a question's recode maps for analytics */
+  putSurveysIdQuestionsQuestionIdRecodes(id: number, questionId: string, body: SaveRecodesRequest, options: RequestOptions = {}): Promise<Envelope<Survey>> {
+    return this.request<Envelope<Survey>>("PUT", `/api/surveys/${encodeURIComponent(id)}/questions/${encodeURIComponent(questionId)}/recodes`, options, body);
+  }
+
+  /** Get the JSON Schema of response_data */
+  getSurveysIdResponseSchema(id: number, options: RequestOptions = {}): Promise<Response> {
+    return this.send("GET", `/api/surveys/${encodeURIComponent(id)}/response-schema`, options);
+  }
+
+  /** List responses */
+  getSurveysIdResponses(id: number, options: RequestOptions<{ view?: QueryValue; sort?: QueryValue; order?: QueryValue; limit?: QueryValue; cursor?: QueryValue; channel?: QueryValue; country?: QueryValue; device?: QueryValue; moderation_status?: QueryValue; bot_score_min?: QueryValue; bot_score_max?: QueryValue; quality_flag?: QueryValue; exclude_quality?: QueryValue; }> = {}): Promise<Envelope<SurveyResponse[]>> {
+    return this.request<Envelope<SurveyResponse[]>>("GET", `/api/surveys/${encodeURIComponent(id)}/responses`, options);
+  }
+
+  /** Submit a response */
+  postSurveysIdResponses(id: number, body: CreateResponseRequest, options: RequestOptions = {}): Promise<Envelope<SurveyResponse>> {
+    return this.request<Envelope<SurveyResponse>>("POST", `/api/surveys/${encodeURIComponent(id)}/responses`, options, body);
+  }
+
+  /** Stream all responses as newline-delimited JSON, oldest first */
+  getSurveysIdResponsesExportNdjson(id: number, options: RequestOptions<{ recode?: QueryValue; from?: QueryValue; to?: QueryValue; channel?: QueryValue; country?: QueryValue; device?: QueryValue; moderation_status?: QueryValue; bot_score_min?: QueryValue; bot_score_max?: QueryValue; quality_flag?: QueryValue; exclude_quality?: QueryValue; }, { "X-Export-Password"?: string; }> = {}): Promise<Response> {
+    return this.send("GET", `/api/surveys/${encodeURIComponent(id)}/responses/export.ndjson`, options);
+  }
+
+  /** Search the text answers of responses, with highlighted snippets */
+  getSurveysIdResponsesSearch(id: number, options: RequestOptions<{ q?: QueryValue; limit?: QueryValue; }> = {}): Promise<Envelope<ResponseSearchResult[]>> {
+    return this.request<Envelope<ResponseSearchResult[]>>("GET", `/api/surveys/${encodeURIComponent(id)}/responses/search`, options);
+  }
+
+  /** Get a response */
+  getSurveysIdResponsesResponseId(id: number, responseId: number, options: RequestOptions = {}): Promise<Envelope<SurveyResponse>> {
+    return this.request<Envelope<SurveyResponse>>("GET", `/api/surveys/${encodeURIComponent(id)}/responses/${encodeURIComponent(responseId)}`, options);
+  }
+
+  /** Update a response within the edit window */
+  patchSurveysIdResponsesResponseId(id: number, responseId: number, body: UpdateResponseRequest, options: RequestOptions = {}): Promise<Envelope<SurveyResponse>> {
+    return this.request<Envelope<SurveyResponse>>("PATCH", `/api/surveys/${encodeURIComponent(id)}/responses/${encodeURIComponent(responseId)}`, options, body);
+  }
+
+  /** Soft-delete a response */
+  deleteSurveysIdResponsesResponseId(id: number, responseId: number, options: RequestOptions = {}): Promise<APIResponse> {
+    return this.request<APIResponse>("DELETE", `/api/surveys/${encodeURIComponent(id)}/responses/${encodeURIComponent(responseId)}`, options);
+  }
+
+  /** Get the previous and next responses */
+  getSurveysIdResponsesResponseIdNeighbors(id: number, responseId: number, options: RequestOptions<{ view?: QueryValue; sort?: QueryValue; order?: QueryValue; limit?: QueryValue; cursor?: QueryValue; channel?: QueryValue; country?: QueryValue; device?: QueryValue; moderation_status?: QueryValue; bot_score_min?: QueryValue; bot_score_max?: QueryValue; quality_flag?: QueryValue; exclude_quality?: QueryValue; }> = {}): Promise<Envelope<ResponseNeighbors>> {
+    return this.request<Envelope<ResponseNeighbors>>("GET", `/api/surveys/${encodeURIComponent(id)}/responses/${encodeURIComponent(responseId)}/neighbors`, options);
+  }
+
+  /** List previous versions of a response */
+  getSurveysIdResponsesResponseIdRevisions(id: number, responseId: number, options: RequestOptions = {}): Promise<Envelope<ResponseRevision[]>> {
+    return this.request<Envelope<ResponseRevision[]>>("GET", `/api/surveys/${encodeURIComponent(id)}/responses/${encodeURIComponent(responseId)}/revisions`, options);
+  }
+
+  /** Get per-question aggregates, cached briefly when unfiltered */
+  getSurveysIdResults(id: number, options: RequestOptions<{ recode?: QueryValue; from?: QueryValue; to?: QueryValue; channel?: QueryValue; country?: QueryValue; device?: QueryValue; moderation_status?: QueryValue; bot_score_min?: QueryValue; bot_score_max?: QueryValue; quality_flag?: QueryValue; exclude_quality?: QueryValue; }> = {}): Promise<Envelope<SurveyResults>> {
+    return this.request<Envelope<SurveyResults>>("GET", `/api/surveys/${encodeURIComponent(id)}/results`, options);
+  }
+
+  /** Watch per-question aggregates over a WebSocket, sent whenever they change */
+  getSurveysIdResultsLive(id: number, options: RequestOptions = {}): Promise<Envelope<SurveyResults>> {
+    return this.request<Envelope<SurveyResults>>("GET", `/api/surveys/${encodeURIComponent(id)}/results/live`, options);
+  }
+
+  /** List scans and flagged responses awaiting review, oldest first */
+  getSurveysIdReviewQueue(id: number, options: RequestOptions<{ reason?: QueryValue; limit?: QueryValue; }> = {}): Promise<Envelope<ReviewItem[]>> {
+    return this.request<Envelope<ReviewItem[]>>("GET", `/api/surveys/${encodeURIComponent(id)}/review-queue`, options);
+  }
+
+  /** Accept a review item as it is */
+  postSurveysIdReviewQueueItemIdAccept(id: number, itemId: string, options: RequestOptions = {}): Promise<Envelope<ReviewItem>> {
+    return this.request<Envelope<ReviewItem>>("POST", `/api/surveys/${encodeURIComponent(id)}/review-queue/${encodeURIComponent(itemId)}/accept`, options);
+  }
+
+  /** Correct a review item's answers and accept it */
+  postSurveysIdReviewQueueItemIdFix(id: number, itemId: string, body: ReviewRequest, options: RequestOptions = {}): Promise<Envelope<ReviewItem>> {
+    return this.request<Envelope<ReviewItem>>("POST", `/api/surveys/${encodeURIComponent(id)}/review-queue/${encodeURIComponent(itemId)}/fix`, options, body);
+  }
+
+  /** Reject a review item */
+  postSurveysIdReviewQueueItemIdReject(id: number, itemId: string, options: RequestOptions = {}): Promise<Envelope<ReviewItem>> {
+    return this.request<Envelope<ReviewItem>>("POST", `/api/surveys/${encodeURIComponent(id)}/review-queue/${encodeURIComponent(itemId)}/reject`, options);
+  }
+
+  /** Get results per org unit, counting its descendants, with small units suppressed */
+  getSurveysIdRollups(id: number, options: RequestOptions<{ question?: QueryValue; min_n?: QueryValue; from?: QueryValue; to?: QueryValue; channel?: QueryValue; country?: QueryValue; device?: QueryValue; moderation_status?: QueryValue; bot_score_min?: QueryValue; bot_score_max?: QueryValue; quality_flag?: QueryValue; exclude_quality?: QueryValue; }> = {}): Promise<Envelope<SurveyRollups>> {
+    return this.request<Envelope<SurveyRollups>>("GET", `/api/surveys/${encodeURIComponent(id)}/rollups`, options);
+  }
+
+  /** Schedule a draft survey */
+  postSurveysIdSchedule(id: number, body: ScheduleSurveyRequest, options: RequestOptions = {}): Promise<Envelope<Survey>> {
+    return this.request<Envelope<Survey>>("POST", `/api/surveys/${encodeURIComponent(id)}/schedule`, options, body);
+  }
+
+  /** List a survey's share links */
+  getSurveysIdShare(id: number, options: RequestOptions = {}): Promise<Envelope<ShareLink[]>> {
+    return this.request<Envelope<ShareLink[]>>("GET", `/api/surveys/${encodeURIComponent(id)}/share`, options);
+  }
+
+  /** Create a public link to a survey's form */
+  postSurveysIdShare(id: number, options: RequestOptions = {}): Promise<Envelope<ShareLink>> {
+    return this.request<Envelope<ShareLink>>("POST", `/api/surveys/${encodeURIComponent(id)}/share`, options);
+  }
+
+  /** Revoke a share link */
+  deleteSurveysIdShareShareId(id: number, shareId: number, options: RequestOptions = {}): Promise<APIResponse> {
+    return this.request<APIResponse>("DELETE", `/api/surveys/${encodeURIComponent(id)}/share/${encodeURIComponent(shareId)}`, options);
+  }
+
+  /** Get the Google Sheets sync of a survey */
+  getSurveysIdSheets(id: number, options: RequestOptions = {}): Promise<Envelope<SheetSync>> {
+    return this.request<Envelope<SheetSync>>("GET", `/api/surveys/${encodeURIComponent(id)}/sheets`, options);
+  }
+
+  /** Append a survey's new responses to a Google Sheet */
+  putSurveysIdSheets(id: number, body: SaveSheetSyncRequest, options: RequestOptions = {}): Promise<Envelope<SheetSync>> {
+    return this.request<Envelope<SheetSync>>("PUT", `/api/surveys/${encodeURIComponent(id)}/sheets`, options, body);
+  }
+
+  /** Stop syncing a survey's responses to Google Sheets */
+  deleteSurveysIdSheets(id: number, options: RequestOptions = {}): Promise<APIResponse> {
+    return this.request<APIResponse>("DELETE", `/api/surveys/${encodeURIComponent(id)}/sheets`, options);
+  }
+
+  /** Append the responses received before the Google Sheets sync was configured */
+  postSurveysIdSheetsBackfill(id: number, options: RequestOptions = {}): Promise<Envelope<SheetBackfill>> {
+    return this.request<Envelope<SheetBackfill>>("POST", `/api/surveys/${encodeURIComponent(id)}/sheets/backfill`, options);
+  }
+
+  /** Get the settings of the copy of their answers emailed to respondents */
+  getSurveysIdSummaryEmail(id: number, options: RequestOptions = {}): Promise<Envelope<SummaryEmailSettings>> {
+    return this.request<Envelope<SummaryEmailSettings>>("GET", `/api/surveys/${encodeURIComponent(id)}/summary-email`, options);
+  }
+
+  /** Configure the copy of their answers emailed to respondents */
+  putSurveysIdSummaryEmail(id: number, body: SaveSummaryEmailSettingsRequest, options: RequestOptions = {}): Promise<Envelope<SummaryEmailSettings>> {
+    return this.request<Envelope<SummaryEmailSettings>>("PUT", `/api/surveys/${encodeURIComponent(id)}/summary-email`, options, body);
+  }
+
+  /** Set the translations of a draft survey */
+  putSurveysIdTranslations(id: number, body: SaveTranslationsRequest, options: RequestOptions = {}): Promise<Envelope<Survey>> {
+    return this.request<Envelope<Survey>>("PUT", `/api/surveys/${encodeURIComponent(id)}/translations`, options, body);
+  }
+
+  /** List every issue blocking the survey's publication */
+  getSurveysIdValidation(id: number, options: RequestOptions = {}): Promise<Envelope<SurveyValidation>> {
+    return this.request<Envelope<SurveyValidation>>("GET", `/api/surveys/${encodeURIComponent(id)}/validation`, options);
+  }
+
+  /** List saved views */
+  getSurveysIdViews(id: number, options: RequestOptions = {}): Promise<Envelope<ResponseView[]>> {
+    return this.request<Envelope<ResponseView[]>>("GET", `/api/surveys/${encodeURIComponent(id)}/views`, options);
+  }
+
+  /** Save a view */
+  postSurveysIdViews(id: number, body: CreateViewRequest, options: RequestOptions = {}): Promise<Envelope<ResponseView>> {
+    return this.request<Envelope<ResponseView>>("POST", `/api/surveys/${encodeURIComponent(id)}/views`, options, body);
+  }
+
+  /** Get a saved view */
+  getSurveysIdViewsViewId(id: number, viewId: number, options: RequestOptions = {}): Promise<Envelope<ResponseView>> {
+    return this.request<Envelope<ResponseView>>("GET", `/api/surveys/${encodeURIComponent(id)}/views/${encodeURIComponent(viewId)}`, options);
+  }
+
+  /** Delete a saved view */
+  deleteSurveysIdViewsViewId(id: number, viewId: number, options: RequestOptions = {}): Promise<APIResponse> {
+    return this.request<APIResponse>("DELETE", `/api/surveys/${encodeURIComponent(id)}/views/${encodeURIComponent(viewId)}`, options);
+  }
+
+  /** Get the surveys changed since the last sync and acknowledge uploaded responses, for offline clients */
+  getSync(options: RequestOptions<{ since?: QueryValue; limit?: QueryValue; key?: QueryValue; }> = {}): Promise<Envelope<SyncResult>> {
+    return this.request<Envelope<SyncResult>>("GET", `/api/sync`, options);
+  }
+
+  /** Delete or anonymize every response of a user (data subject request) */
+  deleteUsersUserIdentifierData(userIdentifier: string, options: RequestOptions<{ mode?: QueryValue; }> = {}): Promise<Envelope<UserDataReport>> {
+    return this.request<Envelope<UserDataReport>>("DELETE", `/api/users/${encodeURIComponent(userIdentifier)}/data`, options);
+  }
+
+  /** Export every response of a user with survey context (data portability request) */
+  getUsersUserIdentifierExport(userIdentifier: string, options: RequestOptions<{ format?: QueryValue; }, { "X-Export-Password"?: string; }> = {}): Promise<Envelope<UserDataExport>> {
+    return this.request<Envelope<UserDataExport>>("GET", `/api/users/${encodeURIComponent(userIdentifier)}/export`, options);
+  }
+
+  /** List a user's responses */
+  getUsersUserIdentifierResponses(userIdentifier: string, options: RequestOptions = {}): Promise<Envelope<UserResponse[]>> {
+    return this.request<Envelope<UserResponse[]>>("GET", `/api/users/${encodeURIComponent(userIdentifier)}/responses`, options);
+  }
+}
+
+export interface APIKey {
+  created_at: string;
+  id: number;
+  last_used_at: string | null;
+  name: string;
+  prefix: string;
+  revoked_at?: string | null;
+  scope: string;
+  token?: string;
+}
+
+export interface APIResponse {
+  code?: string;
+  data?: unknown;
+  errors?: string[];
+  message?: string;
+  meta?: unknown;
+  request_id?: string;
+  status: string;
+}
+
+export interface AddMemberRequest {
+  member: { creator_id: number; };
+}
+
+export interface AddQuestionRequest {
+  question: Question;
+}
+
+export interface AnonymizeReport {
+  dry_run: boolean;
+  fields: string[];
+  fields_anonymized: number;
+  identifiers_anonymized: number;
+  mode: string;
+  responses_changed: number;
+  responses_scanned: number;
+  survey_id: number;
+}
+
+export interface AnonymizeRequest {
+  anonymize: { dry_run: boolean; fields: string[]; mode: string; };
+}
+
+export interface AuditEntry {
+  action: string;
+  actor: string;
+  after: unknown;
+  before: unknown;
+  created_at: string;
+  details: unknown;
+  entity_id: number;
+  entity_type: string;
+  id: number;
+}
+
+export interface ClientError {
+  browser: string;
+  created_at: string;
+  detail: string;
+  device: string;
+  id: number;
+  kind: string;
+  message: string;
+  page_url: string;
+  request_id: string;
+  survey_id: number | null;
+  user_agent: string;
+}
+
+export interface ClientErrorGroup {
+  count: number;
+  devices: string[];
+  first_seen: string;
+  kind: string;
+  last_seen: string;
+  message: string;
+  survey_id: number | null;
+}
+
+export interface ClientErrorSummary {
+  from: string;
+  groups: ClientErrorGroup[];
+  kinds: Record<string, number>;
+  to: string;
+  total: number;
+}
+
+export interface CompressionResult {
+  compressed: number;
+  saved_bytes: number;
+}
+
+export interface CreateAPIKeyRequest {
+  api_key: { name: string; scope: string; };
+}
+
+export interface CreateClientErrorRequest {
+  client_error: { detail: string; kind: string; message: string; page_url: string; request_id: string; survey_id: number | null; };
+}
+
+export interface CreateCreatorRequest {
+  creator: { name: string; };
+}
+
+export interface CreateEmailSuppressionRequest {
+  suppression: { email: string; reason: string; };
+}
+
+export interface CreateExperienceEventRequest {
+  experience_event: { browser: string; device: string; error: string; load_ms: number | null; type: string; };
+}
+
+export interface CreateExportJobRequest {
+  export_job: { format: string; interval_minutes: number; s3: S3Destination; sftp: SFTPDestination; };
+}
+
+export interface CreateFormulaRequest {
+  formula: { expression: string; name: string; };
+}
+
+export interface CreateInvitationsRequest {
+  invitations: { emails: string[]; };
+}
+
+export interface CreateOrgUnitRequest {
+  org_unit: { code: string; name: string; parent_id: number | null; };
+}
+
+export interface CreateOrganizationRequest {
+  organization: { name: string; };
+}
+
+export interface CreateResponseRequest {
+  survey_response: { invitation_token: string; metadata: SubmittedMetadata; partial_response_id: string; response_data: unknown; user_identifier: string; };
+}
+
+export interface CreateScannedResponseRequest {
+  scanned_response: { fields: Record<string, { confidence: number | null; value: unknown; }>; source: string; user_identifier: string; };
+}
+
+export interface CreateSurveyRequest {
+  survey: { allow_multiple_responses: boolean | null; anonymous: boolean; closes_at: string | null; description: string; edit_window_minutes: number | null; max_responses: number | null; opens_at: string | null; publish_at: string | null; quality_rules: QualityRules; questions: Question[]; status: string; title: string; };
+}
+
+export interface CreateSurveyTokenRequest {
+  token: { name: string; };
+}
+
+export interface CreateViewRequest {
+  view: { columns: string[]; filters: unknown; name: string; order: string; sort: string; };
+}
+
+export interface CreateViewerRequest {
+  viewer: { attributes: Record<string, string>; name: string; };
+}
+
+export interface CreateWebhookRequest {
+  webhook: { events: string[]; filter: Record<string, string>; sample_rate: number | null; survey_id: number | null; template: string; url: string; };
+}
+
+export interface Creator {
+  created_at: string;
+  id: number;
+  last_used_at: string | null;
+  name: string;
+  prefix: string;
+  revoked_at?: string | null;
+  token?: string;
+}
+
+export interface DiscoverableSurvey {
+  closes_at: string | null;
+  created_at: string;
+  description: string;
+  global_id: string;
+  id: number;
+  opens_at: string | null;
+  title: string;
+}
+
+export interface DropoutQuestion {
+  answered: number;
+  dropped_off: number;
+  id: string;
+}
+
+export interface DropoutReport {
+  abandoned: number;
+  in_progress: number;
+  questions: DropoutQuestion[];
+  started: number;
+  submitted: number;
+  survey_id: number;
+}
+
+export interface EmailSuppression {
+  created_at: string;
+  email: string;
+  reason?: string;
+}
+
+export interface ExperienceMetrics {
+  ExperienceStats: ExperienceStats;
+  browsers: ExperienceStats[];
+  devices: ExperienceStats[];
+  from: string;
+  survey_id: number;
+  to: string;
+}
+
+export interface ExperienceStats {
+  error_rate: number;
+  median_load_ms: number | null;
+  name?: string;
+  render_errors: number;
+  renders: number;
+}
+
+export interface ExportJob {
+  created_at: string;
+  destination: string;
+  format: string;
+  id: number;
+  interval_minutes: number;
+  last_run: ExportRun;
+  next_run_at: string;
+  s3?: S3Destination;
+  sftp?: SFTPDestination;
+  status: string;
+  survey_id: number;
+}
+
+export interface ExportRun {
+  bytes: number;
+  error?: string;
+  finished_at: string | null;
+  location?: string;
+  rows: number;
+  started_at: string;
+  status: string;
+}
+
+export interface Formula {
+  created_at: string;
+  expression: string;
+  id: number;
+  name: string;
+  survey_id: number;
+  updated_at: string;
+}
+
+export interface FormulaResult {
+  error?: string;
+  expression: string;
+  name: string;
+  value: number | null;
+}
+
+export interface Invitation {
+  attempts: number;
+  created_at: string;
+  email: string;
+  id: number;
+  last_error?: string;
+  opened_at?: string | null;
+  prefix?: string;
+  reminded_at?: string | null;
+  reminders_sent: number;
+  responded_at?: string | null;
+  response_id?: number | null;
+  sent_at?: string | null;
+  status: string;
+  survey_id: number;
+}
+
+export interface InvitationUpload {
+  created: number;
+  skipped: string[];
+}
+
+export interface Job {
+  attempts: number;
+  created_at: string;
+  id: number;
+  kind: string;
+  last_error?: string;
+  max_attempts: number;
+  payload: unknown;
+  run_at: string;
+  status: string;
+  updated_at: string;
+}
+
+export interface LintWarning {
+  code: string;
+  message: string;
+  question?: string;
+}
+
+export interface LogicRule {
+  action: string;
+  operator: string;
+  question: string;
+  target: string;
+  value: unknown;
+}
+
+export interface NPSCounts {
+  detractors: number;
+  passives: number;
+  promoters: number;
+  score: number | null;
+  total: number;
+}
+
+export interface NPSReport {
+  NPSCounts: NPSCounts;
+  computed_at: string;
+  question: string;
+  skipped: number;
+  survey_id: number;
+  weeks?: NPSWeek[];
+}
+
+export interface NPSWeek {
+  NPSCounts: NPSCounts;
+  week_start: string;
+}
+
+export interface NotificationPreferences {
+  channels: string[];
+  delivery: string;
+  email?: string;
+  events: string[];
+  quiet_hours: QuietHours;
+  slack_webhook_url?: string;
+  time_zone: string;
+}
+
+export interface OrgUnit {
+  code: string;
+  created_at: string;
+  id: number;
+  name: string;
+  parent_id: number | null;
+}
+
+export interface OrgUnitRollup {
+  children: OrgUnitRollup[];
+  code: string;
+  formulas?: FormulaResult[];
+  id: number;
+  name: string;
+  questions?: QuestionResult[];
+  responses_count: number | null;
+  suppressed: boolean;
+}
+
+export interface Organization {
+  created_at: string;
+  custom_domain?: string;
+  id: number;
+  members_count: number;
+  name: string;
+}
+
+export interface OrganizationMember {
+  added_at: string;
+  creator_id: number;
+  name: string;
+}
+
+export interface Page {
+  id: string;
+  title?: string;
+}
+
+export interface PartialResponse {
+  answers: Record<string, unknown>;
+  created_at: string;
+  id: string;
+  response_id?: number | null;
+  survey_id: number;
+  updated_at: string;
+  version: number;
+}
+
+export interface QualityReport {
+  flagged: number;
+  flags: Record<string, number>;
+  median_completion_seconds: number | null;
+  responses: number;
+  rules: QualityRules;
+  score: number | null;
+  survey_id: number;
+}
+
+export interface QualityRules {
+  speeder_min_sample?: number | null;
+  speeder_ratio?: number | null;
+  straight_lining_min?: number | null;
+}
+
+export interface Question {
+  battery?: string;
+  id: string;
+  label: string;
+  max?: number | null;
+  min?: number | null;
+  options?: string[];
+  page?: string;
+  pii?: boolean;
+  recodes?: Record<string, Record<string, string>>;
+  required?: boolean;
+  type: string;
+}
+
+export interface QuestionAnswer {
+  answer: unknown;
+  created_at: string;
+  response_id: number;
+  updated_at: string;
+  user_identifier: string;
+}
+
+export interface QuestionResult {
+  answered: number;
+  id: string;
+  values: Record<string, number>;
+}
+
+export interface QuestionTranslation {
+  label: string;
+  options?: string[];
+}
+
+export interface QuietHours {
+  end: string;
+  start: string;
+}
+
+export interface RateLimitRule {
+  key: string;
+  limit: number;
+  name: string;
+  period_seconds: number;
+  routes: string[];
+}
+
+export interface RedeemResumeCodeRequest {
+  resume: { code: string; };
+}
+
+export interface ReminderPreview {
+  reminders: ScheduledReminder[];
+  settings: ReminderSettings;
+}
+
+export interface ReminderSettings {
+  after_days: number | null;
+  max_reminders: number;
+}
+
+export interface ResponseDataReport {
+  compression: boolean;
+  min_bytes: number;
+  tables: ResponseDataStats[];
+}
+
+export interface ResponseDataStats {
+  compressed_rows: number;
+  data_bytes: number;
+  rows: number;
+  saved_bytes: number;
+  stored_bytes: number;
+  table: string;
+}
+
+export interface ResponseMetadata {
+  bot_score?: number | null;
+  channel?: string;
+  completion_seconds?: number | null;
+  country?: string;
+  device?: string;
+  moderation_status: string;
+  quality_flags?: string[];
+  reviewed_at?: string | null;
+  warnings?: string[];
+}
+
+export interface ResponseNeighbors {
+  id: number;
+  next: number | null;
+  position: number;
+  previous: number | null;
+  total: number;
+}
+
+export interface ResponseRevision {
+  id: number;
+  replaced_at: string;
+  response_data: unknown;
+  response_id: number;
+  revision: number;
+}
+
+export interface ResponseSearchResult {
+  response: SurveyResponse;
+  snippet: string;
+}
+
+export interface ResponseThread {
+  deleted: boolean;
+  exported_at: string;
+  history: AuditEntry[];
+  questions: Question[];
+  response: SurveyResponse;
+  revisions: ResponseRevision[];
+  survey_title: string;
+}
+
+export interface ResponseView {
+  columns: string[];
+  created_at: string;
+  filters: unknown;
+  id: number;
+  name: string;
+  order: string;
+  sort: string;
+  survey_id: number;
+  updated_at: string;
+}
+
+export interface ResumeCode {
+  code: string;
+  expires_at: string;
+}
+
+export interface ReviewItem {
+  created_at: string;
+  id: string;
+  kind: string;
+  reasons: string[];
+  response?: SurveyResponse;
+  scan?: ScannedResponse;
+}
+
+export interface ReviewRequest {
+  review: { corrections: Record<string, unknown>; };
+}
+
+export interface S3Destination {
+  access_key_id: string;
+  bucket: string;
+  endpoint?: string;
+  prefix?: string;
+  region: string;
+  secret_access_key?: string;
+}
+
+export interface SFTPDestination {
+  host: string;
+  host_key: string;
+  password?: string;
+  path?: string;
+  private_key?: string;
+  user: string;
+}
+
+export interface SaveDiscoverableRequest {
+  discoverable: boolean | null;
+}
+
+export interface SaveDomainRequest {
+  domain: string;
+}
+
+export interface SaveIndexingRequest {
+  indexable: boolean | null;
+}
+
+export interface SaveLogicRequest {
+  logic: LogicRule[];
+}
+
+export interface SaveNotificationPreferencesRequest {
+  notification_preferences: NotificationPreferences;
+}
+
+export interface SavePIIRequest {
+  pii: boolean | null;
+}
+
+export interface SavePagesRequest {
+  pages: Page[];
+}
+
+export interface SavePartialResponseRequest {
+  partial_response: { answers: Record<string, unknown>; version: number | null; };
+}
+
+export interface SaveRecodesRequest {
+  recodes: Record<string, Record<string, string>>;
+}
+
+export interface SaveReminderSettingsRequest {
+  reminders: ReminderSettings;
+}
+
+export interface SaveSheetSyncRequest {
+  sheets: { client_id: string; client_secret: string; refresh_token: string; sheet_name: string; spreadsheet_id: string; };
+}
+
+export interface SaveSummaryEmailSettingsRequest {
+  summary_email: SummaryEmailSettings;
+}
+
+export interface SaveTranslationsRequest {
+  translations: Record<string, SurveyTranslation>;
+}
+
+export interface ScannedField {
+  confidence: number;
+  flagged: boolean;
+  value: unknown;
+}
+
+export interface ScannedResponse {
+  created_at: string;
+  fields: Record<string, ScannedField>;
+  id: number;
+  response_id?: number | null;
+  reviewed_at?: string | null;
+  source?: string;
+  status: string;
+  survey_id: number;
+  user_identifier: string;
+}
+
+export interface ScheduleSurveyRequest {
+  survey: { publish_at: string | null; };
+}
+
+export interface ScheduledReminder {
+  due: boolean;
+  invitation: Invitation;
+  remind_at: string;
+  reminder: number;
+}
+
+export interface ShareLink {
+  canonical_url?: string;
+  created_at: string;
+  id: number;
+  prefix: string;
+  revoked_at?: string | null;
+  survey_id: number;
+  token?: string;
+  url?: string;
+}
+
+export interface SheetBackfill {
+  appended: number;
+}
+
+export interface SheetSync {
+  backfill_through: number;
+  client_id: string;
+  created_at: string;
+  last_error?: string;
+  last_response_id: number;
+  last_synced_at: string | null;
+  sheet_name: string;
+  spreadsheet_id: string;
+  survey_id: number;
+}
+
+export interface SlowQueryGroup {
+  count: number;
+  last_seen: string;
+  max_ms: number;
+  mean_ms: number;
+  query: string;
+  route: string;
+}
+
+export interface SlowQueryReport {
+  groups: SlowQueryGroup[];
+  since: string | null;
+  threshold_ms: number;
+}
+
+export interface SubmittedMetadata {
+  channel: string;
+  completion_seconds: number | null;
+  country: string;
+  device: string;
+}
+
+export interface SubscribeRESTHookRequest {
+  event: string;
+  survey_id: number | null;
+  target_url: string;
+}
+
+export interface SummaryEmailSettings {
+  email_question: string;
+  enabled: boolean;
+  subject: string;
+  template: string;
+}
+
+export interface Survey {
+  accepting_responses: boolean;
+  allow_multiple_responses: boolean;
+  anonymous: boolean;
+  closes_at?: string | null;
+  created_at: string;
+  description: string;
+  discoverable: boolean;
+  edit_window_minutes?: number | null;
+  global_id: string;
+  id: number;
+  indexable: boolean;
+  logic?: LogicRule[];
+  max_responses?: number | null;
+  opens_at?: string | null;
+  organization_id?: number | null;
+  owner_id?: number | null;
+  pages?: Page[];
+  publish_at?: string | null;
+  quality_rules?: QualityRules;
+  questions?: Question[];
+  responses_count: number;
+  status: string;
+  title: string;
+  translations?: Record<string, SurveyTranslation>;
+  updated_at: string;
+}
+
+export interface SurveyImpact {
+  invitations: number;
+  partial_responses: number;
+  references: SurveyReference[];
+  responses: number;
+  scanned_responses: number;
+  snapshots: number;
+  survey_id: number;
+}
+
+export interface SurveyIssue {
+  code: string;
+  message: string;
+}
+
+export interface SurveyLint {
+  survey_id: number;
+  warnings: LintWarning[];
+}
+
+export interface SurveyReference {
+  id?: number;
+  name: string;
+  type: string;
+}
+
+export interface SurveyResponse {
+  created_at: string;
+  editable: boolean;
+  global_id: string;
+  id: number;
+  metadata: ResponseMetadata;
+  response_data: unknown;
+  survey_id: number;
+  updated_at: string;
+  user_identifier: string;
+  version: number;
+}
+
+export interface SurveyResults {
+  computed_at: string;
+  formulas?: FormulaResult[];
+  questions: QuestionResult[];
+  responses_count: number;
+  survey_id: number;
+}
+
+export interface SurveyRollups {
+  computed_at: string;
+  min_n: number;
+  question: string;
+  survey_id: number;
+  unassigned: number;
+  units: OrgUnitRollup[];
+}
+
+export interface SurveySnapshot {
+  created_at: string;
+  day: string;
+  formulas?: FormulaResult[];
+  questions: QuestionResult[];
+  responses_count: number;
+}
+
+export interface SurveyToken {
+  created_at: string;
+  id: number;
+  last_used_at: string | null;
+  name: string;
+  prefix: string;
+  revoked_at?: string | null;
+  survey_id: number;
+  token?: string;
+}
+
+export interface SurveyTranslation {
+  description: string;
+  pages?: Record<string, string>;
+  questions?: Record<string, QuestionTranslation>;
+  title: string;
+}
+
+export interface SurveyValidation {
+  issues: SurveyIssue[];
+  survey_id: number;
+  valid: boolean;
+}
+
+export interface SyncResult {
+  acknowledgements: UploadAcknowledgement[];
+  removed: number[];
+  surveys: Survey[];
+}
+
+export interface UpdateResponseRequest {
+  survey_response: { conflict_policy: string; response_data: unknown; version: number | null; };
+}
+
+export interface UploadAcknowledgement {
+  global_id?: string;
+  idempotency_key: string;
+  received_at?: string | null;
+  response_id?: number;
+  status: string;
+  survey_id?: number;
+}
+
+export interface UserDataExport {
+  exported_at: string;
+  responses: UserDataResponse[];
+  scanned_responses: ScannedResponse[];
+  surveys: UserDataSurvey[];
+  user_identifier: string;
+}
+
+export interface UserDataReport {
+  audit_entries_redacted: number;
+  invitations_deleted: number;
+  jobs_deleted: number;
+  mode: string;
+  partial_responses_deleted: number;
+  responses_anonymized: number;
+  responses_deleted: number;
+  revisions_deleted: number;
+  scanned_responses_anonymized: number;
+  scanned_responses_deleted: number;
+  survey_ids: number[];
+}
+
+export interface UserDataResponse {
+  channel?: string;
+  country?: string;
+  created_at: string;
+  deleted_at?: string | null;
+  device?: string;
+  id: number;
+  response_data: unknown;
+  revisions: ResponseRevision[];
+  survey_id: number;
+  updated_at: string;
+}
+
+export interface UserDataSurvey {
+  description: string;
+  id: number;
+  questions?: Question[];
+  title: string;
+}
+
+export interface UserResponse {
+  created_at: string;
+  editable: boolean;
+  id: number;
+  response_data: unknown;
+  survey: Survey;
+  updated_at: string;
+  user_identifier: string;
+}
+
+export interface Viewer {
+  attributes: Record<string, string>;
+  created_at: string;
+  id: number;
+  last_used_at: string | null;
+  name: string;
+  prefix: string;
+  revoked_at?: string | null;
+  token?: string;
+}
+
+export interface WebhookSubscription {
+  created_at: string;
+  events: string[];
+  filter: Record<string, string>;
+  id: number;
+  rest_hook: boolean;
+  sample_rate?: number | null;
+  secret?: string;
+  survey_id?: number | null;
+  template: string;
+  url: string;
+}
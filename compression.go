@@ -0,0 +1,248 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/gin-gonic/gin"
+)
+
+// compressedPrefix marks gzipped response_data; JSON never starts with it
+const compressedPrefix = "gz:v1:"
+
+// responseDataCompression gzips response_data at rest when on; it is read
+// from RESPONSE_DATA_COMPRESSION by initResponseCompression
+var responseDataCompression bool
+
+// Answers smaller than this aren't worth compressing
+var compressMinBytes = envInt("RESPONSE_DATA_COMPRESS_MIN_BYTES", 1024)
+
+// initResponseCompression turns on response_data compression when
+// RESPONSE_DATA_COMPRESSION is gzip
+func initResponseCompression() error {
+	switch value := os.Getenv("RESPONSE_DATA_COMPRESSION"); value {
+	case "", "off":
+		responseDataCompression = false
+	case "gzip":
+		responseDataCompression = true
+	default:
+		return fmt.Errorf("RESPONSE_DATA_COMPRESSION must be gzip or off, not %q", value)
+	}
+	return nil
+}
+
+// responseDataOpaque reports whether stored response_data may not be JSON,
+// leaving SQLite unable to look into the answers
+func responseDataOpaque() bool {
+	return responseDataCipher != nil || responseDataCompression
+}
+
+// compressResponseData gzips response_data for storage while compression is
+// on. Small answers, and those gzip doesn't shrink, are returned as is.
+func compressResponseData(data []byte) []byte {
+	if !responseDataCompression || len(data) < compressMinBytes || bytes.HasPrefix(data, []byte(compressedPrefix)) {
+		return data
+	}
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	w.Write(data)
+	w.Close()
+	compressed := append([]byte(compressedPrefix), base64.StdEncoding.EncodeToString(buf.Bytes())...)
+	if len(compressed) >= len(data) {
+		return data
+	}
+	return compressed
+}
+
+// decompressResponseData reverses compressResponseData; uncompressed data is
+// returned as is, whether compression is on or not
+func decompressResponseData(stored []byte) ([]byte, error) {
+	if !bytes.HasPrefix(stored, []byte(compressedPrefix)) {
+		return stored, nil
+	}
+	compressed, err := base64.StdEncoding.DecodeString(string(stored[len(compressedPrefix):]))
+	if err != nil {
+		return nil, err
+	}
+	r, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
+
+// ResponseDataStats describes how the response_data of a table is stored
+type ResponseDataStats struct {
+	Table          string `json:"table"`
+	Rows           int    `json:"rows"`
+	CompressedRows int    `json:"compressed_rows"`
+	// StoredBytes is the size as stored, after compression and encryption
+	StoredBytes int64 `json:"stored_bytes"`
+	// DataBytes is the size of the JSON answers
+	DataBytes int64 `json:"data_bytes"`
+	// SavedBytes is what compression saves
+	SavedBytes int64 `json:"saved_bytes"`
+}
+
+// ResponseDataReport is the storage of response_data in responses and revisions
+type ResponseDataReport struct {
+	Compression bool                `json:"compression"`
+	MinBytes    int                 `json:"min_bytes"`
+	Tables      []ResponseDataStats `json:"tables"`
+}
+
+// CompressionResult is what compressing stored response data did
+type CompressionResult struct {
+	Compressed int   `json:"compressed"`
+	SavedBytes int64 `json:"saved_bytes"`
+}
+
+// responseDataStats measures the response_data of table, decrypting each row
+func responseDataStats(ctx context.Context, table string) (ResponseDataStats, error) {
+	stats := ResponseDataStats{Table: table}
+	rows, err := db.QueryContext(ctx, "SELECT response_data FROM "+table)
+	if err != nil {
+		return stats, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var stored []byte
+		if err := rows.Scan(&stored); err != nil {
+			return stats, err
+		}
+		inner, err := decryptResponseData(stored)
+		if err != nil {
+			return stats, err
+		}
+		data, err := decompressResponseData(inner)
+		if err != nil {
+			return stats, err
+		}
+		stats.Rows++
+		stats.StoredBytes += int64(len(stored))
+		stats.DataBytes += int64(len(data))
+		if bytes.HasPrefix(inner, []byte(compressedPrefix)) {
+			stats.CompressedRows++
+			stats.SavedBytes += int64(len(data) - len(inner))
+		}
+	}
+	return stats, rows.Err()
+}
+
+// compressStoredResponses compresses the response_data of responses and
+// revisions stored uncompressed, returning how many rows were and the bytes saved
+func compressStoredResponses(ctx context.Context) (int, int64, error) {
+	total, saved := 0, int64(0)
+	for _, table := range []string{"survey_responses", "response_revisions"} {
+		lastID := 0
+		for {
+			n, s, last, err := compressStoredBatch(ctx, table, lastID)
+			if err != nil {
+				return total, saved, err
+			}
+			total, saved = total+n, saved+s
+			if last == lastID {
+				break
+			}
+			lastID = last
+		}
+	}
+	return total, saved, nil
+}
+
+// compressStoredBatch compresses the rows of table among the encryptBatchSize
+// after lastID, returning the last ID read. Encrypted rows are decrypted,
+// compressed and encrypted again.
+func compressStoredBatch(ctx context.Context, table string, lastID int) (int, int64, int, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, 0, lastID, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, "SELECT id, response_data FROM "+table+" WHERE id > ? ORDER BY id LIMIT ?", lastID, encryptBatchSize)
+	if err != nil {
+		return 0, 0, lastID, err
+	}
+	stored := map[int][]byte{}
+	for rows.Next() {
+		var id int
+		var data []byte
+		if err := rows.Scan(&id, &data); err != nil {
+			rows.Close()
+			return 0, 0, lastID, err
+		}
+		stored[id] = data
+		lastID = id
+	}
+	rows.Close()
+
+	n, saved := 0, int64(0)
+	for id, data := range stored {
+		inner, err := decryptResponseData(data)
+		if err != nil {
+			return 0, 0, lastID, err
+		}
+		compressed := compressResponseData(inner)
+		if bytes.Equal(compressed, inner) {
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, "UPDATE "+table+" SET response_data = ? WHERE id = ?", encryptResponseData(compressed), id); err != nil {
+			return 0, 0, lastID, err
+		}
+		n++
+		saved += int64(len(inner) - len(compressed))
+	}
+	return n, saved, lastID, tx.Commit()
+}
+
+// getResponseDataStats reports the storage of response_data and what compression saves
+func getResponseDataStats(c *gin.Context) {
+	report := ResponseDataReport{Compression: responseDataCompression, MinBytes: compressMinBytes}
+	for _, table := range []string{"survey_responses", "response_revisions"} {
+		stats, err := responseDataStats(c.Request.Context(), table)
+		if err != nil {
+			abortWithError(c, errInternal("Failed to measure response data", err))
+			return
+		}
+		report.Tables = append(report.Tables, stats)
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Status: "success",
+		Data:   report,
+	})
+}
+
+// compressResponseDataNow compresses the responses and revisions stored
+// before compression was turned on
+func compressResponseDataNow(c *gin.Context) {
+	if !responseDataCompression {
+		abortWithError(c, &APIError{
+			Status:  http.StatusConflict,
+			Code:    CodeCompressionOff,
+			Message: "Response data compression is off; set RESPONSE_DATA_COMPRESSION=gzip",
+		})
+		return
+	}
+
+	n, saved, err := compressStoredResponses(c.Request.Context())
+	if err != nil {
+		abortWithError(c, errInternal("Failed to compress response data", err))
+		return
+	}
+	log.Printf("Compressed the response data of %d responses and revisions, saving %d bytes", n, saved)
+
+	c.JSON(http.StatusOK, APIResponse{
+		Status:  "success",
+		Message: fmt.Sprintf("Compressed %d responses and revisions", n),
+		Data:    CompressionResult{Compressed: n, SavedBytes: saved},
+	})
+}
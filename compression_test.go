@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInitResponseCompression(t *testing.T) {
+	defer func() { responseDataCompression = false }()
+	t.Setenv("RESPONSE_DATA_COMPRESSION", "gzip")
+	assert.NoError(t, initResponseCompression())
+	assert.True(t, responseDataCompression)
+	t.Setenv("RESPONSE_DATA_COMPRESSION", "off")
+	assert.NoError(t, initResponseCompression())
+	assert.False(t, responseDataCompression)
+	t.Setenv("RESPONSE_DATA_COMPRESSION", "zstd")
+	assert.Error(t, initResponseCompression())
+}
+
+func TestCompressResponseData(t *testing.T) {
+	defer func() { responseDataCompression = false }()
+	large := []byte(`{"comment": "` + strings.Repeat("The onboarding was thorough. ", 100) + `"}`)
+	assert.Equal(t, large, compressResponseData(large))
+
+	responseDataCompression = true
+	small := []byte(`{"comment": "Short"}`)
+	assert.Equal(t, small, compressResponseData(small))
+	compressed := compressResponseData(large)
+	assert.True(t, bytes.HasPrefix(compressed, []byte(compressedPrefix)))
+	assert.Less(t, len(compressed), len(large)/4)
+	// Compressing twice changes nothing
+	assert.Equal(t, compressed, compressResponseData(compressed))
+
+	// Answers gzip can't shrink are stored as they are
+	random := make([]byte, 2048)
+	rand.Read(random)
+	incompressible := []byte(`"` + base64.StdEncoding.EncodeToString(random) + `"`)
+	assert.Equal(t, incompressible, compressResponseData(incompressible))
+
+	for _, data := range [][]byte{small, large} {
+		opened, err := openResponseData(sealResponseData(data))
+		assert.NoError(t, err)
+		assert.Equal(t, data, opened)
+	}
+
+	// Compression stays readable after it is turned off, and under encryption
+	responseDataCompression = false
+	opened, err := openResponseData(compressed)
+	assert.NoError(t, err)
+	assert.Equal(t, large, opened)
+	responseDataCompression = true
+	defer func() { responseDataCipher = nil }()
+	responseDataCipher, err = newResponseDataCipher(bytes.Repeat([]byte{7}, 32))
+	assert.NoError(t, err)
+	sealed := sealResponseData(large)
+	assert.True(t, bytes.HasPrefix(sealed, []byte(encryptedPrefix)))
+	assert.Less(t, len(sealed), len(large)/2)
+	opened, err = openResponseData(sealed)
+	assert.NoError(t, err)
+	assert.Equal(t, large, opened)
+
+	_, err = openResponseData([]byte(compressedPrefix + "not gzip"))
+	assert.Error(t, err)
+}
+
+func TestCompressedResponses(t *testing.T) {
+	setupTestDB()
+	defer testDB.Close()
+	router := setupTestRouter()
+	defer func() { responseDataCompression = false }()
+
+	result, err := testDB.Exec("INSERT INTO surveys (title, description) VALUES ('Feedback', 'd')")
+	assert.NoError(t, err)
+	surveyID, _ := result.LastInsertId()
+	essay := strings.Repeat("Stored before compression. ", 100)
+	testDB.Exec("INSERT INTO survey_responses (survey_id, user_identifier, response_data) VALUES (?, 'user1', ?)",
+		surveyID, `{"comment": "`+essay+`"}`)
+	testDB.Exec("INSERT INTO survey_responses (survey_id, user_identifier, response_data) VALUES (?, 'user2', ?)",
+		surveyID, `{"comment": "Short"}`)
+
+	send := func(method, url, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, adminRequest(method, url, []byte(body)))
+		return w
+	}
+	stats := func() ResponseDataReport {
+		var response struct {
+			Data ResponseDataReport `json:"data"`
+		}
+		w := send("GET", "/api/admin/response-data/stats", "")
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		json.Unmarshal(w.Body.Bytes(), &response)
+		return response.Data
+	}
+
+	w := send("POST", "/api/admin/response-data/compress", "")
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Contains(t, w.Body.String(), CodeCompressionOff)
+	report := stats()
+	assert.False(t, report.Compression)
+	assert.Equal(t, 2, report.Tables[0].Rows)
+	assert.Equal(t, 0, report.Tables[0].CompressedRows)
+	assert.Equal(t, report.Tables[0].DataBytes, report.Tables[0].StoredBytes)
+
+	responseDataCompression = true
+	base := fmt.Sprintf("/api/surveys/%d", surveyID)
+	w = send("POST", base+"/responses", `{"survey_response":{"user_identifier":"user3","response_data":{"comment":"`+strings.Repeat("Submitted compressed. ", 100)+`"}}}`)
+	assert.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+	// Only the large response stored earlier needs compressing
+	w = send("POST", "/api/admin/response-data/compress", "")
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var compressed struct {
+		Data CompressionResult `json:"data"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &compressed)
+	assert.Equal(t, 1, compressed.Data.Compressed)
+	assert.Greater(t, compressed.Data.SavedBytes, int64(len(essay)/2))
+	n, _, err := compressStoredResponses(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 0, n)
+
+	report = stats()
+	assert.True(t, report.Compression)
+	responses := report.Tables[0]
+	assert.Equal(t, 3, responses.Rows)
+	assert.Equal(t, 2, responses.CompressedRows)
+	assert.Equal(t, responses.DataBytes-responses.SavedBytes, responses.StoredBytes)
+	var stored string
+	testDB.QueryRow("SELECT response_data FROM survey_responses WHERE user_identifier = 'user1'").Scan(&stored)
+	assert.True(t, strings.HasPrefix(stored, compressedPrefix), stored)
+
+	// API consumers see the answers as they were submitted
+	w = send("GET", base+"/responses", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), essay)
+	assert.Contains(t, w.Body.String(), "Submitted compressed.")
+	assert.Contains(t, w.Body.String(), `"comment":"Short"`)
+
+	assert.Equal(t, http.StatusBadRequest, send("GET", base+"/responses?answer[comment]=x", "").Code)
+	assert.Equal(t, http.StatusBadRequest, send("GET", base+"/responses/search?q=stored", "").Code)
+}
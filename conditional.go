@@ -0,0 +1,61 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// etagMatches reports whether an If-None-Match header lists etag. Weak and
+// strong tags compare alike, as GET only needs them to be equivalent.
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// respondConditional responds with body, tagged with an ETag of its content and
+// a Last-Modified of lastModified when it isn't zero. Clients that already hold
+// it get 304 Not Modified instead. If-None-Match takes precedence over
+// If-Modified-Since, so changes that don't touch updated_at, such as a new
+// response counted by a survey, are still seen by clients sending the ETag.
+func respondConditional(c *gin.Context, lastModified time.Time, body APIResponse) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		abortWithError(c, errInternal("Failed to encode response", err))
+		return
+	}
+	sum := sha256.Sum256(data)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+
+	// Clients may reuse the copy they have, but must check it is still current first
+	c.Header("Cache-Control", "no-cache")
+	c.Header("ETag", etag)
+	if !lastModified.IsZero() {
+		c.Header("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+
+	notModified := false
+	if header := c.GetHeader("If-None-Match"); header != "" {
+		notModified = etagMatches(header, etag)
+	} else if header := c.GetHeader("If-Modified-Since"); header != "" && !lastModified.IsZero() {
+		since, err := http.ParseTime(header)
+		// HTTP dates have whole seconds
+		notModified = err == nil && !lastModified.Truncate(time.Second).After(since)
+	}
+	if notModified {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	c.Data(http.StatusOK, "application/json; charset=utf-8", data)
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEtagMatches(t *testing.T) {
+	assert.True(t, etagMatches(`"abc"`, `"abc"`))
+	assert.True(t, etagMatches(`W/"abc"`, `"abc"`))
+	assert.True(t, etagMatches(`"x", "abc"`, `"abc"`))
+	assert.True(t, etagMatches(`*`, `"abc"`))
+	assert.False(t, etagMatches(`"abcd"`, `"abc"`))
+	assert.False(t, etagMatches(`abc`, `"abc"`))
+}
+
+func TestConditionalGet(t *testing.T) {
+	setupTestDB()
+	defer testDB.Close()
+
+	result, err := testDB.Exec("INSERT INTO surveys (title, description, updated_at) VALUES ('Polled', 'd', '2024-01-15 10:30:00')")
+	assert.NoError(t, err)
+	surveyID, _ := result.LastInsertId()
+	router := setupTestRouter()
+	surveyURL := fmt.Sprintf("/api/surveys/%d", surveyID)
+
+	get := func(url string, headers map[string]string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", url, nil)
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := get(surveyURL, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	etag := w.Header().Get("ETag")
+	assert.NotEmpty(t, etag)
+	assert.Equal(t, "Mon, 15 Jan 2024 10:30:00 GMT", w.Header().Get("Last-Modified"))
+	assert.Equal(t, "no-cache", w.Header().Get("Cache-Control"))
+
+	w = get(surveyURL, map[string]string{"If-None-Match": etag})
+	assert.Equal(t, http.StatusNotModified, w.Code)
+	assert.Empty(t, w.Body.String())
+	assert.Equal(t, etag, w.Header().Get("ETag"))
+
+	w = get(surveyURL, map[string]string{"If-Modified-Since": "Mon, 15 Jan 2024 10:30:00 GMT"})
+	assert.Equal(t, http.StatusNotModified, w.Code)
+	w = get(surveyURL, map[string]string{"If-Modified-Since": "Mon, 15 Jan 2024 10:29:59 GMT"})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// A new response changes the survey's count but not its updated_at
+	result, err = testDB.Exec("INSERT INTO survey_responses (survey_id, user_identifier, response_data, updated_at) VALUES (?, 'u', '{}', '2024-01-16 08:00:00')", surveyID)
+	assert.NoError(t, err)
+	responseID, _ := result.LastInsertId()
+	w = get(surveyURL, map[string]string{"If-None-Match": etag})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotEqual(t, etag, w.Header().Get("ETag"))
+
+	// If-None-Match wins over If-Modified-Since
+	w = get(surveyURL, map[string]string{"If-None-Match": etag, "If-Modified-Since": time.Now().UTC().Format(http.TimeFormat)})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	responseURL := fmt.Sprintf("%s/responses/%d", surveyURL, responseID)
+	w = get(responseURL, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "Tue, 16 Jan 2024 08:00:00 GMT", w.Header().Get("Last-Modified"))
+	w = get(responseURL, map[string]string{"If-None-Match": w.Header().Get("ETag")})
+	assert.Equal(t, http.StatusNotModified, w.Code)
+
+	// Lists are only tagged
+	w = get(surveyURL+"/responses", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get("Last-Modified"))
+	w = get(surveyURL+"/responses", map[string]string{"If-None-Match": w.Header().Get("ETag")})
+	assert.Equal(t, http.StatusNotModified, w.Code)
+	testDB.Exec("UPDATE survey_responses SET deleted_at = CURRENT_TIMESTAMP WHERE id = ?", responseID)
+	w = get(surveyURL+"/responses", map[string]string{"If-None-Match": w.Header().Get("ETag")})
+	assert.Equal(t, http.StatusOK, w.Code)
+}
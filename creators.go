@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// creatorTokenPrefix marks bearer tokens of creator accounts
+const creatorTokenPrefix = "crt_"
+
+// Creator is an account of a team creating surveys; the surveys it creates
+// are owned by it and listed to it alone
+type Creator struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+	// Prefix identifies the token without revealing it
+	Prefix string `json:"prefix"`
+	// Token is only returned when the account is created
+	Token      string     `json:"token,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+// CreateCreatorRequest represents the request body for creating a creator account
+type CreateCreatorRequest struct {
+	Creator struct {
+		Name string `json:"name" binding:"required"`
+	} `json:"creator" binding:"required"`
+}
+
+// creatorColumns lists the columns read by scanCreator
+const creatorColumns = "id, name, prefix, created_at, last_used_at, revoked_at"
+
+// scanCreator scans a row selected with creatorColumns
+func scanCreator(row rowScanner) (Creator, error) {
+	var cr Creator
+	var lastUsedAt, revokedAt sql.NullTime
+	err := row.Scan(&cr.ID, &cr.Name, &cr.Prefix, &cr.CreatedAt, &lastUsedAt, &revokedAt)
+	cr.LastUsedAt = nullTimePtr(lastUsedAt)
+	cr.RevokedAt = nullTimePtr(revokedAt)
+	return cr, err
+}
+
+// findCreatorByToken loads the active account matching a bearer token
+func findCreatorByToken(ctx context.Context, token string) (Creator, error) {
+	return scanCreator(db.QueryRowContext(ctx,
+		"SELECT "+creatorColumns+" FROM creators WHERE token_hash = ? AND revoked_at IS NULL",
+		hashSurveyToken(token)))
+}
+
+// creatorAuth identifies requests authenticated with a creator token. Creators
+// may make any request but admin ones. Other requests pass through.
+func creatorAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		token := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if !strings.HasPrefix(token, creatorTokenPrefix) {
+			c.Next()
+			return
+		}
+
+		cr, err := findCreatorByToken(ctx, token)
+		if err != nil {
+			abortWithError(c, &APIError{
+				Status:  http.StatusUnauthorized,
+				Code:    CodeInvalidCreatorToken,
+				Message: "Invalid or revoked creator token",
+			})
+			return
+		}
+
+		db.ExecContext(ctx, "UPDATE creators SET last_used_at = CURRENT_TIMESTAMP WHERE id = ?", cr.ID)
+		c.Set("actor", fmt.Sprintf("creator:%d", cr.ID))
+		c.Set("creator_id", cr.ID)
+		c.Next()
+	}
+}
+
+// currentCreator returns the ID of the creator making the request, if any
+func currentCreator(c *gin.Context) (int, bool) {
+	id, ok := c.Value("creator_id").(int)
+	return id, ok
+}
+
+// findActiveCreator loads an unrevoked creator account, responding 404 when there is none
+func findActiveCreator(c *gin.Context, creatorID int) (Creator, bool) {
+	cr, err := scanCreator(db.QueryRowContext(c.Request.Context(),
+		"SELECT "+creatorColumns+" FROM creators WHERE id = ? AND revoked_at IS NULL", creatorID))
+	if err == sql.ErrNoRows {
+		abortWithError(c, errNotFound(CodeCreatorNotFound, "Creator not found"))
+		return cr, false
+	}
+	if err != nil {
+		abortWithError(c, errInternal("Failed to fetch creator", err))
+		return cr, false
+	}
+	return cr, true
+}
+
+// getCreators lists the creator accounts, including revoked ones, without their tokens
+func getCreators(c *gin.Context) {
+	rows, err := db.QueryContext(c.Request.Context(), "SELECT "+creatorColumns+" FROM creators ORDER BY id")
+	if err != nil {
+		abortWithError(c, errInternal("Failed to fetch creators", err))
+		return
+	}
+	defer rows.Close()
+
+	creators := []Creator{}
+	for rows.Next() {
+		cr, err := scanCreator(rows)
+		if err != nil {
+			abortWithError(c, errInternal("Failed to scan creator data", err))
+			return
+		}
+		creators = append(creators, cr)
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Status: "success",
+		Data:   creators,
+	})
+}
+
+// createCreator creates a creator account; its token is shown once
+func createCreator(c *gin.Context) {
+	var req CreateCreatorRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		abortWithError(c, errInvalidRequest(err))
+		return
+	}
+	if len(req.Creator.Name) > 100 {
+		abortWithError(c, errValidation("Failed to create creator", []string{"Name must be less than 100 characters"}))
+		return
+	}
+
+	token := creatorTokenPrefix + randomHex(24)
+	result, err := db.ExecContext(c.Request.Context(), `
+		INSERT INTO creators (name, prefix, token_hash, created_at)
+		VALUES (?, ?, ?, CURRENT_TIMESTAMP)
+	`, req.Creator.Name, token[:len(creatorTokenPrefix)+8], hashSurveyToken(token))
+	if err != nil {
+		abortWithError(c, errInternal("Failed to create creator", err))
+		return
+	}
+
+	id, _ := result.LastInsertId()
+	cr, ok := findActiveCreator(c, int(id))
+	if !ok {
+		return
+	}
+	auditChange(c, "create", "creator", cr.ID, nil, cr)
+
+	cr.Token = token
+	c.JSON(http.StatusCreated, APIResponse{
+		Status:  "success",
+		Message: "Creator created successfully",
+		Data:    cr,
+	})
+}
+
+// revokeCreator disables a creator account; its surveys keep their owner
+func revokeCreator(c *gin.Context) {
+	creatorID, err := strconv.Atoi(c.Param("creator_id"))
+	if err != nil {
+		abortWithError(c, errInvalidID("creator"))
+		return
+	}
+	before, ok := findActiveCreator(c, creatorID)
+	if !ok {
+		return
+	}
+
+	if _, err := db.ExecContext(c.Request.Context(), "UPDATE creators SET revoked_at = CURRENT_TIMESTAMP WHERE id = ?", creatorID); err != nil {
+		abortWithError(c, errInternal("Failed to revoke creator", err))
+		return
+	}
+	auditChange(c, "delete", "creator", creatorID, before, nil)
+
+	c.JSON(http.StatusOK, APIResponse{
+		Status:  "success",
+		Message: "Creator revoked successfully",
+	})
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSurveyOwnership(t *testing.T) {
+	setupTestDB()
+	defer testDB.Close()
+	router := setupTestRouter()
+	testDB.Exec("INSERT INTO surveys (title, description) VALUES ('Unowned', 'd')")
+
+	createCreator := func(name string) Creator {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, adminRequest("POST", "/api/admin/creators", []byte(fmt.Sprintf(`{"creator":{"name":%q}}`, name))))
+		assert.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+		var created struct {
+			Data Creator `json:"data"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &created)
+		assert.Contains(t, created.Data.Token, creatorTokenPrefix)
+		return created.Data
+	}
+	marketing, support := createCreator("Marketing"), createCreator("Support")
+
+	send := func(token, method, url, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, url, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		router.ServeHTTP(w, req)
+		return w
+	}
+	titles := func(w *httptest.ResponseRecorder) []string {
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var response struct {
+			Data []Survey `json:"data"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		titles := []string{}
+		for _, s := range response.Data {
+			titles = append(titles, s.Title)
+		}
+		return titles
+	}
+
+	w := send(marketing.Token, "POST", "/api/surveys", `{"survey":{"title":"Campaign","description":"d"}}`)
+	assert.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var created struct {
+		Data Survey `json:"data"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &created)
+	assert.Equal(t, marketing.ID, *created.Data.OwnerID)
+	send(marketing.Token, "POST", "/api/surveys", `{"survey":{"title":"Launch draft","description":"d","status":"draft"}}`)
+	send(support.Token, "POST", "/api/surveys", `{"survey":{"title":"Tickets","description":"d"}}`)
+
+	// Each caller lists their own surveys; creators their drafts too
+	assert.Equal(t, []string{"Campaign"}, titles(send(marketing.Token, "GET", "/api/surveys", "")))
+	assert.ElementsMatch(t, []string{"Campaign", "Launch draft"}, titles(send(marketing.Token, "GET", "/api/surveys?status=all", "")))
+	assert.Equal(t, []string{"Tickets"}, titles(send(support.Token, "GET", "/api/surveys", "")))
+	assert.Equal(t, []string{"Unowned"}, titles(send("", "GET", "/api/surveys", "")))
+	assert.Equal(t, []string{"Unowned"}, titles(send(adminToken, "GET", "/api/surveys", "")))
+	assert.ElementsMatch(t, []string{"Unowned", "Campaign", "Tickets"}, titles(send(adminToken, "GET", "/api/surveys?all=true", "")))
+
+	assert.Equal(t, http.StatusForbidden, send(marketing.Token, "GET", "/api/surveys?all=true", "").Code)
+	assert.Equal(t, http.StatusForbidden, send("", "GET", "/api/surveys?status=draft", "").Code)
+	assert.Equal(t, http.StatusForbidden, send(marketing.Token, "GET", "/api/admin/creators", "").Code)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, adminRequest("DELETE", fmt.Sprintf("/api/admin/creators/%d", support.ID), nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, http.StatusUnauthorized, send(support.Token, "GET", "/api/surveys", "").Code)
+}
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/XSAM/otelsql"
+	"github.com/mattn/go-sqlite3"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Database settings
+var (
+	// dbPath is the SQLite database file
+	dbPath = envString("DB_PATH", "./survey_form.db")
+	// dbBusyTimeout is how long SQLite waits for a lock held by another connection
+	dbBusyTimeout = envDuration("DB_BUSY_TIMEOUT", 5*time.Second)
+	// dbMaxOpenConns bounds the connection pool; with WAL readers don't block the writer
+	dbMaxOpenConns = envInt("DB_MAX_OPEN_CONNS", 4)
+	// dbBusyRetries is how often a statement still finding the database locked after
+	// the busy timeout is retried
+	dbBusyRetries = envInt("DB_BUSY_RETRIES", 3)
+	// dbQueryTimeout bounds statements whose context has no deadline of its own;
+	// a query's rows must be read within it
+	dbQueryTimeout = envDuration("DB_QUERY_TIMEOUT", 10*time.Second)
+	// dbExportConns bounds the separate pool exports run on, so a burst of long
+	// export streams can't take the connections submissions need
+	dbExportConns = envInt("DB_EXPORT_CONNS", 2)
+	// dbExportConnsPerOrg bounds the export connections of one organization,
+	// so its exports queue among themselves and leave the rest of the pool to
+	// other organizations
+	dbExportConnsPerOrg = envInt("DB_EXPORT_CONNS_PER_ORG", 1)
+	// dbExportQueueTimeout is how long an export waits for a connection of its pool
+	dbExportQueueTimeout = envDuration("DB_EXPORT_QUEUE_TIMEOUT", 30*time.Second)
+)
+
+// exportDB is the connection pool of exports; they use db while it is nil
+var exportDB *sql.DB
+
+// exportSlots are the export connections each organization may take, by
+// org_id; surveys outside organizations share the slots of 0
+var exportSlots = struct {
+	sync.Mutex
+	byOrg map[int]chan struct{}
+}{byOrg: map[int]chan struct{}{}}
+
+// orgExportSlots returns the semaphore of an organization's export connections
+func orgExportSlots(orgID int) chan struct{} {
+	exportSlots.Lock()
+	defer exportSlots.Unlock()
+	slots, ok := exportSlots.byOrg[orgID]
+	if !ok {
+		slots = make(chan struct{}, dbExportConnsPerOrg)
+		exportSlots.byOrg[orgID] = slots
+	}
+	return slots
+}
+
+// exportConnection is a connection of the export pool holding one of its
+// organization's slots; Close gives both back
+type exportConnection struct {
+	*sql.Conn
+	slots chan struct{}
+}
+
+// Close returns the connection to the pool and frees the organization's slot
+func (c *exportConnection) Close() error {
+	err := c.Conn.Close()
+	<-c.slots
+	return err
+}
+
+// dbBusyBackoff is the first pause before retrying a locked statement; it doubles with every retry
+const dbBusyBackoff = 50 * time.Millisecond
+
+// busyRetryDriverName is the SQLite driver retrying statements that find the database locked
+const busyRetryDriverName = "sqlite3_busy_retry"
+
+func init() {
+	sql.Register(busyRetryDriverName, busyRetryDriver{&sqlite3.SQLiteDriver{}})
+}
+
+// envString reads a setting from the environment, falling back to def
+func envString(name, def string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return def
+}
+
+// sqliteDSN returns the data source name of a database file. WAL lets reads run
+// alongside a write, and transactions take the write lock when they begin so
+// two of them can't deadlock upgrading a read lock.
+func sqliteDSN(path string) string {
+	return fmt.Sprintf("%s?_journal_mode=WAL&_synchronous=NORMAL&_busy_timeout=%d&_txlock=immediate",
+		path, dbBusyTimeout.Milliseconds())
+}
+
+// openDatabase opens a SQLite database file whose queries are recorded as spans
+func openDatabase(path string) (*sql.DB, error) {
+	db, err := otelsql.Open(busyRetryDriverName, sqliteDSN(path),
+		otelsql.WithAttributes(attribute.String("db.system", "sqlite")),
+		otelsql.WithSpanOptions(otelsql.SpanOptions{
+			OmitConnResetSession: true,
+			OmitRows:             true,
+		}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	// Idle connections are kept so their pragmas aren't set up again
+	db.SetMaxOpenConns(dbMaxOpenConns)
+	db.SetMaxIdleConns(dbMaxOpenConns)
+	return db, nil
+}
+
+// openExportDatabase opens the export pool of a database file
+func openExportDatabase(path string) (*sql.DB, error) {
+	pool, err := openDatabase(path)
+	if err != nil {
+		return nil, err
+	}
+	pool.SetMaxOpenConns(dbExportConns)
+	pool.SetMaxIdleConns(dbExportConns)
+	return pool, nil
+}
+
+// exportConn takes a connection of the export pool for an export of orgID,
+// queueing for at most dbExportQueueTimeout: first behind the organization's
+// other exports while it uses all of its slots, then for the pool while every
+// connection is busy. The connection must be closed.
+func exportConn(ctx context.Context, orgID int) (*exportConnection, error) {
+	pool := exportDB
+	if pool == nil {
+		pool = db
+	}
+	waitCtx, cancel := context.WithTimeout(ctx, dbExportQueueTimeout)
+	defer cancel()
+
+	slots := orgExportSlots(orgID)
+	select {
+	case slots <- struct{}{}:
+	case <-waitCtx.Done():
+		return nil, waitCtx.Err()
+	}
+	conn, err := pool.Conn(waitCtx)
+	if err != nil {
+		<-slots
+		return nil, err
+	}
+	return &exportConnection{Conn: conn, slots: slots}, nil
+}
+
+// isBusy reports whether err is SQLite finding the database locked
+func isBusy(err error) bool {
+	var sqliteErr sqlite3.Error
+	return errors.As(err, &sqliteErr) && (sqliteErr.Code == sqlite3.ErrBusy || sqliteErr.Code == sqlite3.ErrLocked)
+}
+
+// retryBusy runs op, retrying with exponential backoff while the database is locked
+func retryBusy(ctx context.Context, op func() error) error {
+	backoff := dbBusyBackoff
+	for attempt := 0; ; attempt++ {
+		err := op()
+		if !isBusy(err) || attempt >= dbBusyRetries {
+			return err
+		}
+		log.Printf("Database is locked, retrying in %s", backoff)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// busyRetryDriver opens SQLite connections that retry locked statements
+type busyRetryDriver struct {
+	*sqlite3.SQLiteDriver
+}
+
+func (d busyRetryDriver) Open(dsn string) (driver.Conn, error) {
+	conn, err := d.SQLiteDriver.Open(dsn)
+	if err != nil {
+		return nil, err
+	}
+	return busyRetryConn{conn.(*sqlite3.SQLiteConn)}, nil
+}
+
+// busyRetryConn retries beginning transactions and executing statements while the
+// database is locked. Queries aren't retried: with WAL they don't wait for locks,
+// and their rows are stepped through after the query returns. Statements without
+// a deadline are given dbQueryTimeout, and are timed for recordQuery.
+type busyRetryConn struct {
+	*sqlite3.SQLiteConn
+}
+
+// withQueryTimeout applies dbQueryTimeout to ctx unless it already has a deadline
+func withQueryTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if _, ok := ctx.Deadline(); ok || dbQueryTimeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, dbQueryTimeout)
+}
+
+func (c busyRetryConn) BeginTx(ctx context.Context, opts driver.TxOptions) (tx driver.Tx, err error) {
+	err = retryBusy(ctx, func() error {
+		tx, err = c.SQLiteConn.BeginTx(ctx, opts)
+		return err
+	})
+	return tx, err
+}
+
+func (c busyRetryConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (result driver.Result, err error) {
+	start := time.Now()
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	err = retryBusy(ctx, func() error {
+		result, err = c.SQLiteConn.ExecContext(ctx, query, args)
+		return err
+	})
+	recordQuery(ctx, query, time.Since(start), err)
+	return result, err
+}
+
+func (c busyRetryConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	start := time.Now()
+	ctx, cancel := withQueryTimeout(ctx)
+	rows, err := c.SQLiteConn.QueryContext(ctx, query, args)
+	if err != nil {
+		recordQuery(ctx, query, time.Since(start), err)
+		cancel()
+		return nil, err
+	}
+	return &timeoutRows{SQLiteRows: rows.(*sqlite3.SQLiteRows), cancel: cancel, ctx: ctx, query: query, elapsed: time.Since(start)}, nil
+}
+
+// timeoutRows releases the timeout of its query when closed. As SQLite does
+// most of a query's work stepping through its rows, the time spent in Next
+// counts towards the query's duration; the time between rows doesn't.
+type timeoutRows struct {
+	*sqlite3.SQLiteRows
+	cancel  context.CancelFunc
+	ctx     context.Context
+	query   string
+	elapsed time.Duration
+	err     error
+}
+
+func (r *timeoutRows) Next(dest []driver.Value) error {
+	start := time.Now()
+	err := r.SQLiteRows.Next(dest)
+	r.elapsed += time.Since(start)
+	if err != nil && err != io.EOF {
+		r.err = err
+	}
+	return err
+}
+
+func (r *timeoutRows) Close() error {
+	defer r.cancel()
+	recordQuery(r.ctx, r.query, r.elapsed, r.err)
+	return r.SQLiteRows.Close()
+}
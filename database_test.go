@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryBusy(t *testing.T) {
+	busy := fmt.Errorf("insert: %w", sqlite3.Error{Code: sqlite3.ErrBusy})
+	assert.True(t, isBusy(busy))
+	assert.False(t, isBusy(sqlite3.Error{Code: sqlite3.ErrConstraint}))
+
+	attempts := 0
+	err := retryBusy(context.Background(), func() error {
+		if attempts++; attempts < 3 {
+			return busy
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, attempts)
+
+	// Other errors aren't retried, and retries stop after dbBusyRetries
+	attempts = 0
+	retryBusy(context.Background(), func() error { attempts++; return sqlite3.Error{Code: sqlite3.ErrConstraint} })
+	assert.Equal(t, 1, attempts)
+
+	attempts = 0
+	err = retryBusy(context.Background(), func() error { attempts++; return busy })
+	assert.True(t, isBusy(err))
+	assert.Equal(t, dbBusyRetries+1, attempts)
+}
+
+func TestConcurrentWrites(t *testing.T) {
+	fileDB, err := openDatabase(filepath.Join(t.TempDir(), "survey_form.db"))
+	assert.NoError(t, err)
+	defer fileDB.Close()
+	assert.NoError(t, migrate(fileDB))
+
+	var mode string
+	fileDB.QueryRow("PRAGMA journal_mode").Scan(&mode)
+	assert.Equal(t, "wal", mode)
+
+	// Transactions and single statements race for the write lock
+	var wg sync.WaitGroup
+	errs := make(chan error, 200)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 5; j++ {
+				tx, err := fileDB.Begin()
+				if err != nil {
+					errs <- err
+					continue
+				}
+				if _, err := tx.Exec("INSERT INTO surveys (title, description) VALUES (?, 'd')", fmt.Sprintf("Survey %d-%d", i, j)); err != nil {
+					tx.Rollback()
+					errs <- err
+					continue
+				}
+				if err := tx.Commit(); err != nil {
+					errs <- err
+				}
+				if _, err := fileDB.Exec("UPDATE surveys SET updated_at = CURRENT_TIMESTAMP WHERE id = 1"); err != nil {
+					errs <- err
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		assert.NoError(t, err)
+	}
+
+	var count int
+	fileDB.QueryRow("SELECT COUNT(*) FROM surveys").Scan(&count)
+	assert.Equal(t, 100, count)
+}
+
+func TestQueryTimeout(t *testing.T) {
+	fileDB, err := openDatabase(filepath.Join(t.TempDir(), "survey_form.db"))
+	assert.NoError(t, err)
+	defer fileDB.Close()
+	assert.NoError(t, migrate(fileDB))
+
+	defer func(d time.Duration) { dbQueryTimeout = d }(dbQueryTimeout)
+	dbQueryTimeout = 20 * time.Millisecond
+
+	// Statements without a deadline get dbQueryTimeout
+	const slow = "WITH RECURSIVE n(x) AS (SELECT 1 UNION ALL SELECT x + 1 FROM n WHERE x < 100000000) SELECT COUNT(*) FROM n"
+	var count int
+	err = fileDB.QueryRow(slow).Scan(&count)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// A deadline of the caller's own is kept
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	_, err = fileDB.ExecContext(ctx, "INSERT INTO surveys (title, description) VALUES ('Timed', 'd')")
+	assert.NoError(t, err)
+	rows, err := fileDB.QueryContext(ctx, "SELECT id FROM surveys")
+	if assert.NoError(t, err) {
+		assert.True(t, rows.Next())
+		assert.NoError(t, rows.Close())
+	}
+
+	// Cancelled requests stop their queries
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = fileDB.QueryContext(cancelled, "SELECT id FROM surveys")
+	assert.ErrorIs(t, err, context.Canceled)
+}
@@ -0,0 +1,278 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Public survey directory settings. The directory is off unless
+// DISCOVER_ENABLED is set, and only lists surveys an admin marked discoverable.
+var (
+	discoverEnabled       = os.Getenv("DISCOVER_ENABLED") == "true"
+	discoverCacheTTL      = envDuration("DISCOVER_CACHE_TTL", time.Minute)
+	discoverIPRateLimit   = envRateLimit("RATE_LIMIT_DISCOVER_IP", RateLimit{Requests: 30, Per: time.Minute})
+	maxDiscoverCachePages = 1000
+)
+
+// DiscoverableSurvey is an open survey as listed in the public directory
+type DiscoverableSurvey struct {
+	ID          int        `json:"id"`
+	GlobalID    string     `json:"global_id"`
+	Title       string     `json:"title"`
+	Description string     `json:"description"`
+	OpensAt     *time.Time `json:"opens_at"`
+	ClosesAt    *time.Time `json:"closes_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// SaveDiscoverableRequest marks a survey as listed in the public directory, or not
+type SaveDiscoverableRequest struct {
+	Discoverable *bool `json:"discoverable" binding:"required"`
+}
+
+// discoverPage is a cached page of the directory
+type discoverPage struct {
+	surveys []DiscoverableSurvey
+	meta    PageMeta
+	expires time.Time
+}
+
+// discoverCache keeps directory pages for discoverCacheTTL, so the public
+// endpoint doesn't query the database on every request. Surveys opening,
+// closing or being marked discoverable show up once the pages expire.
+type discoverCache struct {
+	mu    sync.Mutex
+	pages map[string]discoverPage
+}
+
+func newDiscoverCache() *discoverCache {
+	return &discoverCache{pages: map[string]discoverPage{}}
+}
+
+// get returns the cached page for key, if it hasn't expired at now
+func (dc *discoverCache) get(key string, now time.Time) (discoverPage, bool) {
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+	page, ok := dc.pages[key]
+	if ok && !now.Before(page.expires) {
+		delete(dc.pages, key)
+		return page, false
+	}
+	return page, ok
+}
+
+// put caches a page; the cache starts over once it holds too many pages,
+// since clients choose the cursors and limits that make up keys
+func (dc *discoverCache) put(key string, page discoverPage) {
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+	if len(dc.pages) >= maxDiscoverCachePages {
+		dc.pages = map[string]discoverPage{}
+	}
+	dc.pages[key] = page
+}
+
+// openDiscoverableSurveys selects the discoverable surveys open for responses;
+// its arguments are the published status and the current time, twice
+const openDiscoverableSurveys = `s.discoverable AND s.status = ?
+	AND (s.opens_at IS NULL OR s.opens_at <= ?) AND (s.closes_at IS NULL OR s.closes_at > ?)
+	AND (s.max_responses IS NULL OR s.responses_count < s.max_responses)`
+
+// findDiscoverableSurveys lists the discoverable surveys open for responses at
+// now, newest first, after the survey with ID cursor when it isn't zero
+func findDiscoverableSurveys(c *gin.Context, now time.Time, cursor, limit int) (discoverPage, error) {
+	rows, err := db.QueryContext(c.Request.Context(), `
+		SELECT s.id, s.global_id, s.title, s.description, s.opens_at, s.closes_at, s.created_at
+		FROM surveys s
+		WHERE `+openDiscoverableSurveys+`
+			AND (? = 0 OR s.id < ?)
+		ORDER BY s.id DESC
+		LIMIT ?
+	`, SurveyStatusPublished, now.UTC(), now.UTC(), cursor, cursor, limit+1)
+	if err != nil {
+		return discoverPage{}, err
+	}
+	defer rows.Close()
+
+	page := discoverPage{surveys: []DiscoverableSurvey{}, meta: PageMeta{Limit: limit}}
+	for rows.Next() {
+		var survey DiscoverableSurvey
+		var opensAt, closesAt sql.NullTime
+		if err := rows.Scan(&survey.ID, &survey.GlobalID, &survey.Title, &survey.Description, &opensAt, &closesAt, &survey.CreatedAt); err != nil {
+			return page, err
+		}
+		survey.OpensAt = nullTimePtr(opensAt)
+		survey.ClosesAt = nullTimePtr(closesAt)
+		page.surveys = append(page.surveys, survey)
+	}
+	if len(page.surveys) > limit {
+		page.surveys = page.surveys[:limit]
+		page.meta.NextCursor = strconv.Itoa(page.surveys[limit-1].ID)
+	}
+	return page, rows.Err()
+}
+
+// getDiscoverableSurveys serves the public directory of open, discoverable
+// surveys, paginated by ?cursor= and ?limit=
+func getDiscoverableSurveys(cache *discoverCache) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !discoverEnabled {
+			abortWithError(c, errNotFound(CodeDiscoveryDisabled, "The survey directory is not enabled"))
+			return
+		}
+
+		var errors []string
+		limit := defaultPageSize
+		if value := c.Query("limit"); value != "" {
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 || n > maxPageSize {
+				errors = append(errors, fmt.Sprintf("Limit must be between 1 and %d", maxPageSize))
+			}
+			limit = n
+		}
+		// The cursor is the ID of the last survey of the previous page
+		cursor := 0
+		if value := c.Query("cursor"); value != "" {
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 {
+				errors = append(errors, "Cursor is invalid")
+			}
+			cursor = n
+		}
+		if len(errors) > 0 {
+			abortWithError(c, errInvalidQuery(errors))
+			return
+		}
+
+		now := time.Now()
+		key := fmt.Sprintf("%d:%d", cursor, limit)
+		page, ok := cache.get(key, now)
+		if !ok {
+			var err error
+			page, err = findDiscoverableSurveys(c, now, cursor, limit)
+			if err != nil {
+				abortWithError(c, errInternal("Failed to fetch surveys", err))
+				return
+			}
+			page.expires = now.Add(discoverCacheTTL)
+			cache.put(key, page)
+		}
+
+		c.Header("Cache-Control", fmt.Sprintf("public, max-age=%d", int(page.expires.Sub(now).Seconds())))
+		c.JSON(http.StatusOK, APIResponse{
+			Status: "success",
+			Data:   page.surveys,
+			Meta:   page.meta,
+		})
+	}
+}
+
+// getDirectoryForm renders the form of a discoverable survey, found by its
+// global ID, so the directory and the sitemap can link to it without a share
+// link. Other surveys look like unknown ones.
+func getDirectoryForm(c *gin.Context) {
+	var view formView
+	status := http.StatusOK
+	survey, err := findDirectorySurvey(c, c.Param("global_id"))
+	switch {
+	case err == sql.ErrNoRows:
+		status = http.StatusNotFound
+		view.Title, view.Notice = "Survey not found", "This survey doesn't exist or isn't listed publicly."
+	case err != nil:
+		log.Printf("Failed to fetch directory survey: %v", err)
+		status = http.StatusInternalServerError
+		view.Title, view.Notice = "Something went wrong", "The survey couldn't be loaded. Please try again later."
+	default:
+		view = newFormView(survey, c.Query("lang"), time.Now())
+		view.CanonicalURL = organizationBaseURL(c.Request.Context(), survey.OrganizationID) + "/f/" + survey.GlobalID
+		view.Channel = "directory"
+	}
+	view.NoIndex = err != nil || !survey.Indexable
+	renderSurveyForm(c, status, view)
+}
+
+// findDirectorySurvey returns the discoverable survey of a global ID, when the
+// directory is enabled and the survey is served on the request's host
+func findDirectorySurvey(c *gin.Context, globalID string) (Survey, error) {
+	if !discoverEnabled || globalIDType(globalID) != GlobalIDSurvey {
+		return Survey{}, sql.ErrNoRows
+	}
+	ctx := c.Request.Context()
+	var id int
+	if err := db.QueryRowContext(ctx, "SELECT id FROM surveys WHERE global_id = ? AND discoverable", globalID).Scan(&id); err != nil {
+		return Survey{}, err
+	}
+	survey, err := findSurvey(ctx, id)
+	if err == nil && !servesSurvey(c, survey) {
+		return Survey{}, sql.ErrNoRows
+	}
+	return survey, err
+}
+
+// saveDiscoverable lists a survey in the public directory, or takes it out
+func saveDiscoverable(c *gin.Context) {
+	var req SaveDiscoverableRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		abortWithError(c, errInvalidRequest(err))
+		return
+	}
+	saveSurveyFlag(c, "discoverable", *req.Discoverable, "Survey directory listing saved successfully")
+}
+
+// saveSurveyFlag sets a boolean column of the survey of the :id parameter,
+// auditing the change and responding with the updated survey
+func saveSurveyFlag(c *gin.Context, column string, value bool, message string) {
+	ctx := c.Request.Context()
+	surveyID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		abortWithError(c, errInvalidID("survey"))
+		return
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		abortWithError(c, errInternal("Failed to save survey", err))
+		return
+	}
+	defer tx.Rollback()
+
+	before, err := querySurvey(ctx, tx, surveyID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			abortWithError(c, errNotFound(CodeSurveyNotFound, "Survey not found"))
+			return
+		}
+		abortWithError(c, errInternal("Failed to fetch survey", err))
+		return
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE surveys SET "+column+" = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?", value, surveyID); err != nil {
+		abortWithError(c, errInternal("Failed to save survey", err))
+		return
+	}
+	survey, err := querySurvey(ctx, tx, surveyID)
+	if err != nil {
+		abortWithError(c, errInternal("Failed to fetch updated survey", err))
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		abortWithError(c, errInternal("Failed to save survey", err))
+		return
+	}
+
+	auditChange(c, "update", "survey", survey.ID, before, survey)
+	emitEvent(EventSurveyUpdated, survey.ID, survey)
+
+	c.JSON(http.StatusOK, APIResponse{
+		Status:  "success",
+		Message: message,
+		Data:    survey,
+	})
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiscoverableSurveys(t *testing.T) {
+	setupTestDB()
+	defer testDB.Close()
+	router := setupTestRouter()
+	defer func(enabled bool, ttl time.Duration) { discoverEnabled, discoverCacheTTL = enabled, ttl }(discoverEnabled, discoverCacheTTL)
+	discoverEnabled, discoverCacheTTL = true, 0
+
+	future := time.Now().Add(time.Hour).UTC()
+	testDB.Exec("INSERT INTO surveys (title, description) VALUES ('Open', 'd'), ('Unlisted', 'd'), ('Also open', 'd')")
+	testDB.Exec("INSERT INTO surveys (title, description, status) VALUES ('Draft', 'd', 'draft')")
+	testDB.Exec("INSERT INTO surveys (title, description, opens_at) VALUES ('Not yet', 'd', ?)", future)
+	testDB.Exec("INSERT INTO surveys (title, description, max_responses, responses_count) VALUES ('Full', 'd', 1, 1)")
+
+	send := func(req *http.Request) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	get := func(url string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", url, nil)
+		return send(req)
+	}
+
+	// Only admins list surveys in the directory
+	req, _ := http.NewRequest("PUT", "/api/surveys/1/discoverable", nil)
+	assert.Equal(t, http.StatusForbidden, send(req).Code)
+	for _, id := range []string{"1", "3", "4", "5", "6"} {
+		w := send(adminRequest("PUT", "/api/surveys/"+id+"/discoverable", []byte(`{"discoverable": true}`)))
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	}
+	assert.Equal(t, http.StatusNotFound, send(adminRequest("PUT", "/api/surveys/9/discoverable", []byte(`{"discoverable": true}`))).Code)
+	assert.Equal(t, http.StatusBadRequest, send(adminRequest("PUT", "/api/surveys/1/discoverable", []byte(`{}`))).Code)
+
+	// Open surveys are listed newest first, a page at a time
+	w := get("/api/discover?limit=1")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "public, max-age=0", w.Header().Get("Cache-Control"))
+	var page struct {
+		Data []DiscoverableSurvey `json:"data"`
+		Meta PageMeta             `json:"meta"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &page)
+	if assert.Len(t, page.Data, 1) {
+		assert.Equal(t, "Also open", page.Data[0].Title)
+		assert.Regexp(t, `^srv_`, page.Data[0].GlobalID)
+	}
+	assert.Equal(t, "3", page.Meta.NextCursor)
+	page.Meta = PageMeta{}
+	json.Unmarshal(get("/api/discover?limit=1&cursor=3").Body.Bytes(), &page)
+	if assert.Len(t, page.Data, 1) {
+		assert.Equal(t, "Open", page.Data[0].Title)
+	}
+	assert.Empty(t, page.Meta.NextCursor)
+	assert.Equal(t, http.StatusBadRequest, get("/api/discover?limit=0").Code)
+	assert.Equal(t, http.StatusBadRequest, get("/api/discover?cursor=abc").Code)
+
+	// Pages are cached until they expire
+	discoverCacheTTL = time.Minute
+	assert.Contains(t, get("/api/discover").Body.String(), "Also open")
+	send(adminRequest("PUT", "/api/surveys/3/discoverable", []byte(`{"discoverable": false}`)))
+	w = get("/api/discover")
+	assert.Contains(t, w.Body.String(), "Also open")
+	assert.Regexp(t, `^public, max-age=(59|60)$`, w.Header().Get("Cache-Control"))
+
+	// The directory is opt-in
+	discoverEnabled = false
+	w = get("/api/discover")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Contains(t, w.Body.String(), "DISCOVERY_DISABLED")
+}
@@ -0,0 +1,328 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// Custom domain settings. An organization may serve its public forms on a
+// domain of its own; certificates for those domains are requested from Let's
+// Encrypt when AUTOCERT_CACHE_DIR is set.
+var (
+	domainCacheTTL      = envDuration("DOMAIN_CACHE_TTL", time.Minute)
+	maxDomainCacheHosts = 10000
+	autocertCacheDir    = envString("AUTOCERT_CACHE_DIR", "")
+	autocertEmail       = envString("AUTOCERT_EMAIL", "")
+	tlsListenAddr       = envString("TLS_ADDR", ":443")
+	publicHost          = publicURLHost(publicURL)
+	customDomainRoutes  = map[string]bool{
+		"GET /s/:token":                   true,
+		"GET /s/:token/form":              true,
+		"GET /i/:token":                   true,
+		"GET /f/:global_id":               true,
+		"GET /sitemap.xml":                true,
+		"POST /api/surveys/:id/responses": true,
+	}
+)
+
+// errUnknownDomain refuses certificates for hosts that aren't custom domains
+var errUnknownDomain = errors.New("host is not a custom domain")
+
+// SaveDomainRequest sets the custom domain of an organization
+type SaveDomainRequest struct {
+	Domain string `json:"domain" binding:"required"`
+}
+
+// publicURLHost returns the host name of a URL, or "" when it has none
+func publicURLHost(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(u.Hostname())
+}
+
+// requestHost returns the lowercased host name a request was sent to, without its port
+func requestHost(r *http.Request) string {
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.TrimSuffix(strings.ToLower(host), ".")
+}
+
+// validateDomain normalizes a custom domain, returning the problems that keep
+// it from being one: it must be a DNS name of two labels or more, not an IP
+// address, and not the host of PUBLIC_URL
+func validateDomain(domain string) (string, []string) {
+	domain = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(domain)), ".")
+	switch {
+	case len(domain) > 253:
+		return domain, []string{"Domain must be at most 253 characters"}
+	case net.ParseIP(domain) != nil:
+		return domain, []string{"Domain must be a name, not an IP address"}
+	case domain == publicHost:
+		return domain, []string{"Domain is already the public URL of the server"}
+	}
+	labels := strings.Split(domain, ".")
+	if len(labels) < 2 {
+		return domain, []string{"Domain must have at least two labels, e.g. forms.example.com"}
+	}
+	for _, label := range labels {
+		if len(label) == 0 || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' ||
+			strings.Trim(label, "abcdefghijklmnopqrstuvwxyz0123456789-") != "" {
+			return domain, []string{"Domain is not a valid host name"}
+		}
+	}
+	return domain, nil
+}
+
+// findDomainOrganization returns the organization serving its forms on host,
+// or 0 when there is none
+func findDomainOrganization(ctx context.Context, host string) (int, error) {
+	var orgID int
+	err := db.QueryRowContext(ctx, "SELECT id FROM organizations WHERE custom_domain = ?", host).Scan(&orgID)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return orgID, err
+}
+
+// organizationBaseURL is where respondents reach the forms of an organization:
+// its custom domain when it has one, PUBLIC_URL otherwise
+func organizationBaseURL(ctx context.Context, orgID *int) string {
+	if orgID == nil {
+		return publicURL
+	}
+	var domain sql.NullString
+	if err := db.QueryRowContext(ctx, "SELECT custom_domain FROM organizations WHERE id = ?", *orgID).Scan(&domain); err != nil {
+		if err != sql.ErrNoRows {
+			log.Printf("Failed to fetch the custom domain of organization %d: %v", *orgID, err)
+		}
+		return publicURL
+	}
+	if !domain.Valid {
+		return publicURL
+	}
+	return "https://" + domain.String
+}
+
+// domainEntry is the organization of a host, 0 when the host isn't a custom domain
+type domainEntry struct {
+	orgID   int
+	expires time.Time
+}
+
+// domainCache keeps the organization of each host requests arrive on for
+// domainCacheTTL, so routing by host doesn't query the database on every
+// request. Domains saved on another instance take effect once entries expire.
+type domainCache struct {
+	mu    sync.Mutex
+	hosts map[string]domainEntry
+}
+
+func newDomainCache() *domainCache {
+	return &domainCache{hosts: map[string]domainEntry{}}
+}
+
+// organization returns the organization serving its forms on host, or 0
+func (dc *domainCache) organization(ctx context.Context, host string, now time.Time) (int, error) {
+	dc.mu.Lock()
+	entry, ok := dc.hosts[host]
+	dc.mu.Unlock()
+	if ok && now.Before(entry.expires) {
+		return entry.orgID, nil
+	}
+
+	orgID, err := findDomainOrganization(ctx, host)
+	if err != nil {
+		return 0, err
+	}
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+	// Clients choose the Host header, so the cache starts over once it holds too many
+	if len(dc.hosts) >= maxDomainCacheHosts {
+		dc.hosts = map[string]domainEntry{}
+	}
+	dc.hosts[host] = domainEntry{orgID: orgID, expires: now.Add(domainCacheTTL)}
+	return orgID, nil
+}
+
+// forget drops the cached organization of hosts, after their domain changed
+func (dc *domainCache) forget(hosts ...string) {
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+	for _, host := range hosts {
+		delete(dc.hosts, host)
+	}
+}
+
+// customDomains routes requests arriving on the custom domain of an
+// organization: only the public forms and their submissions are served there,
+// and only for the surveys of that organization. Other hosts are served as usual.
+func customDomains(cache *domainCache) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		host := requestHost(c.Request)
+		if host == "" || host == publicHost {
+			c.Next()
+			return
+		}
+		orgID, err := cache.organization(c.Request.Context(), host, time.Now())
+		if err != nil {
+			abortWithError(c, errInternal("Failed to fetch custom domain", err))
+			return
+		}
+		if orgID == 0 {
+			c.Next()
+			return
+		}
+		if !customDomainRoutes[c.Request.Method+" "+c.FullPath()] {
+			c.AbortWithStatus(http.StatusNotFound)
+			return
+		}
+		c.Set("domain_org_id", orgID)
+
+		if surveyID, err := strconv.Atoi(c.Param("id")); err == nil {
+			var surveyOrg sql.NullInt64
+			err := db.QueryRowContext(c.Request.Context(), "SELECT org_id FROM surveys WHERE id = ?", surveyID).Scan(&surveyOrg)
+			if err != nil && err != sql.ErrNoRows {
+				abortWithError(c, errInternal("Failed to fetch survey", err))
+				return
+			}
+			if err == nil && (!surveyOrg.Valid || int(surveyOrg.Int64) != orgID) {
+				abortWithError(c, errNotFound(CodeSurveyNotFound, "Survey not found"))
+				return
+			}
+		}
+		c.Next()
+	}
+}
+
+// servesSurvey reports whether a public form of survey may be served on the
+// host of the request: custom domains only serve their organization's surveys
+func servesSurvey(c *gin.Context, survey Survey) bool {
+	orgID, ok := c.Value("domain_org_id").(int)
+	return !ok || (survey.OrganizationID != nil && *survey.OrganizationID == orgID)
+}
+
+// newCertManager returns the manager of the TLS certificates of PUBLIC_URL's
+// host and of the custom domains, or nil when AUTOCERT_CACHE_DIR isn't set.
+// Certificates are only requested for hosts saved as custom domains, so
+// clients can't make the server request them for any name.
+func newCertManager() *autocert.Manager {
+	if autocertCacheDir == "" {
+		return nil
+	}
+	return &autocert.Manager{
+		Prompt: autocert.AcceptTOS,
+		Cache:  autocert.DirCache(autocertCacheDir),
+		Email:  autocertEmail,
+		HostPolicy: func(ctx context.Context, host string) error {
+			host = strings.ToLower(host)
+			if host == publicHost {
+				return nil
+			}
+			orgID, err := findDomainOrganization(ctx, host)
+			if err != nil {
+				return err
+			}
+			if orgID == 0 {
+				return errUnknownDomain
+			}
+			return nil
+		},
+	}
+}
+
+// saveOrganizationDomain sets the custom domain serving an organization's
+// public forms, replacing its previous one
+func saveOrganizationDomain(cache *domainCache) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		before, ok := organizationParam(c)
+		if !ok {
+			return
+		}
+		var req SaveDomainRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			abortWithError(c, errInvalidRequest(err))
+			return
+		}
+		domain, problems := validateDomain(req.Domain)
+		if len(problems) > 0 {
+			abortWithError(c, errValidation("Failed to save custom domain", problems))
+			return
+		}
+
+		_, err := db.ExecContext(c.Request.Context(), "UPDATE organizations SET custom_domain = ? WHERE id = ?", domain, before.ID)
+		if isUniqueViolation(err) {
+			abortWithError(c, &APIError{
+				Status:  http.StatusConflict,
+				Code:    CodeDomainTaken,
+				Message: "Domain is already used by another organization",
+			})
+			return
+		}
+		if err != nil {
+			abortWithError(c, errInternal("Failed to save custom domain", err))
+			return
+		}
+		cache.forget(before.CustomDomain, domain)
+
+		org, ok := findOrganization(c, before.ID)
+		if !ok {
+			return
+		}
+		auditChange(c, "update", "organization", org.ID, before, org)
+
+		c.JSON(http.StatusOK, APIResponse{
+			Status:  "success",
+			Message: "Custom domain saved successfully",
+			Data:    org,
+		})
+	}
+}
+
+// deleteOrganizationDomain stops serving an organization's public forms on its
+// custom domain; its links go back to PUBLIC_URL
+func deleteOrganizationDomain(cache *domainCache) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		before, ok := organizationParam(c)
+		if !ok {
+			return
+		}
+		if before.CustomDomain == "" {
+			abortWithError(c, errNotFound(CodeDomainNotFound, "Organization has no custom domain"))
+			return
+		}
+
+		if _, err := db.ExecContext(c.Request.Context(), "UPDATE organizations SET custom_domain = NULL WHERE id = ?", before.ID); err != nil {
+			abortWithError(c, errInternal("Failed to remove custom domain", err))
+			return
+		}
+		cache.forget(before.CustomDomain)
+
+		org, ok := findOrganization(c, before.ID)
+		if !ok {
+			return
+		}
+		auditChange(c, "update", "organization", org.ID, before, org)
+
+		c.JSON(http.StatusOK, APIResponse{
+			Status:  "success",
+			Message: "Custom domain removed successfully",
+			Data:    org,
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCustomDomains(t *testing.T) {
+	setupTestDB()
+	defer testDB.Close()
+	router := setupTestRouter()
+
+	testDB.Exec("INSERT INTO organizations (name) VALUES ('Acme'), ('Globex')")
+	testDB.Exec("INSERT INTO surveys (title, description, org_id) VALUES ('Acme pulse', 'd', 1), ('Globex pulse', 'd', 2)")
+
+	send := func(req *http.Request) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	onHost := func(host, method, url, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, url, strings.NewReader(body))
+		req.Host = host
+		req.Header.Set("Content-Type", "application/json")
+		return send(req)
+	}
+	saveDomain := func(orgID int, domain string) *httptest.ResponseRecorder {
+		return send(adminRequest("PUT", fmt.Sprintf("/api/admin/organizations/%d/domain", orgID), []byte(`{"domain":"`+domain+`"}`)))
+	}
+	shareLink := func(surveyID int) ShareLink {
+		var created struct {
+			Data ShareLink `json:"data"`
+		}
+		json.Unmarshal(onHost("", "POST", fmt.Sprintf("/api/surveys/%d/share", surveyID), "").Body.Bytes(), &created)
+		return created.Data
+	}
+
+	w := saveDomain(1, "Forms.Acme.test.")
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), `"custom_domain":"forms.acme.test"`)
+	for _, domain := range []string{"localhost", "127.0.0.1", "-bad.acme.test", "forms_acme.test", publicHost} {
+		assert.Equal(t, http.StatusUnprocessableEntity, saveDomain(2, domain).Code, domain)
+	}
+	w = saveDomain(2, "forms.acme.test")
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Contains(t, w.Body.String(), CodeDomainTaken)
+
+	// Links of the organization's surveys are canonical on its domain
+	acme, globex := shareLink(1), shareLink(2)
+	assert.Equal(t, "https://forms.acme.test"+acme.URL, acme.CanonicalURL)
+	assert.Equal(t, publicURL+globex.URL, globex.CanonicalURL)
+	w = onHost("", "GET", acme.URL+"/form", "")
+	assert.Contains(t, w.Body.String(), `<link rel="canonical" href="https://forms.acme.test`+acme.URL+`/form">`)
+
+	// The domain only serves the organization's public forms
+	assert.Equal(t, http.StatusOK, onHost("forms.acme.test", "GET", acme.URL, "").Code)
+	assert.Equal(t, http.StatusOK, onHost("forms.acme.test:443", "GET", acme.URL+"/form", "").Code)
+	assert.Equal(t, http.StatusNotFound, onHost("forms.acme.test", "GET", globex.URL, "").Code)
+	assert.Equal(t, http.StatusNotFound, onHost("forms.acme.test", "GET", globex.URL+"/form", "").Code)
+	assert.Equal(t, http.StatusNotFound, onHost("forms.acme.test", "GET", "/api/surveys/1", "").Code)
+	w = onHost("forms.acme.test", "POST", "/api/surveys/1/responses", `{"survey_response":{"user_identifier":"user1","response_data":{}}}`)
+	assert.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	w = onHost("forms.acme.test", "POST", "/api/surveys/2/responses", `{"survey_response":{"user_identifier":"user1","response_data":{}}}`)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	// Other hosts are served as usual
+	assert.Equal(t, http.StatusOK, onHost("other.test", "GET", globex.URL, "").Code)
+	assert.Equal(t, http.StatusOK, onHost("other.test", "GET", "/api/surveys/1", "").Code)
+
+	w = send(adminRequest("DELETE", "/api/admin/organizations/1/domain", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), "custom_domain")
+	assert.Equal(t, http.StatusNotFound, send(adminRequest("DELETE", "/api/admin/organizations/1/domain", nil)).Code)
+	assert.Equal(t, publicURL+"/s/", shareLink(1).CanonicalURL[:len(publicURL)+3])
+	assert.Equal(t, http.StatusOK, onHost("forms.acme.test", "GET", "/api/surveys/1", "").Code)
+}
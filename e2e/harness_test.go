@@ -0,0 +1,171 @@
+// Package e2e runs the server binary against a throwaway database and drives
+// it over HTTP, as clients and webhook receivers do. The database is SQLite,
+// the only store the server has; there is no Postgres run until it gains one.
+package e2e
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// adminToken is the admin token of every server started
+const adminToken = "e2e-admin-token"
+
+// binary is the server built by TestMain
+var binary string
+
+func TestMain(m *testing.M) {
+	flag.Parse()
+	if testing.Short() {
+		fmt.Println("Skipping end-to-end tests in short mode")
+		os.Exit(0)
+	}
+
+	dir, err := os.MkdirTemp("", "survey-form-e2e")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	binary = filepath.Join(dir, "survey_form")
+	if err := build(binary); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.RemoveAll(dir)
+		os.Exit(1)
+	}
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// build compiles the server
+func build(out string) error {
+	cmd := exec.Command("go", "build", "-o", out, "..")
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("building the server: %v\n%s", err, output)
+	}
+	return nil
+}
+
+// server is a running server binary
+type server struct {
+	t      *testing.T
+	URL    string
+	dbPath string
+	cmd    *exec.Cmd
+	logs   bytes.Buffer
+}
+
+// startServer runs the binary on a database file of the test, extra being
+// added to its environment, and waits for it to be healthy. It is stopped
+// when the test ends.
+func startServer(t *testing.T, dbPath string, extra ...string) *server {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	s := &server{t: t, URL: "http://" + addr, dbPath: dbPath}
+	s.cmd = exec.Command(binary)
+	s.cmd.Env = append(os.Environ(), "ADDR="+addr, "DB_PATH="+dbPath, "ADMIN_TOKEN="+adminToken)
+	s.cmd.Env = append(s.cmd.Env, extra...)
+	s.cmd.Stdout, s.cmd.Stderr = &s.logs, &s.logs
+	if err := s.cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(s.stop)
+
+	deadline := time.Now().Add(15 * time.Second)
+	for {
+		resp, err := http.Get(s.URL + "/up")
+		if err == nil {
+			resp.Body.Close()
+			if resp.StatusCode == http.StatusOK {
+				return s
+			}
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("server didn't become healthy: %v\n%s", err, s.logs.String())
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// stop shuts the server down gracefully, as a deploy would
+func (s *server) stop() {
+	if s.cmd.ProcessState != nil {
+		return
+	}
+	s.cmd.Process.Signal(syscall.SIGTERM)
+	done := make(chan error, 1)
+	go func() { done <- s.cmd.Wait() }()
+	select {
+	case err := <-done:
+		if err != nil {
+			s.t.Errorf("server exited with %v\n%s", err, s.logs.String())
+		}
+	case <-time.After(20 * time.Second):
+		s.cmd.Process.Kill()
+		<-done
+		s.t.Errorf("server didn't shut down\n%s", s.logs.String())
+	}
+}
+
+// do sends a request with the admin token and returns the status and body
+func (s *server) do(method, path string, body interface{}) (int, []byte) {
+	s.t.Helper()
+	var payload io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			s.t.Fatal(err)
+		}
+		payload = bytes.NewReader(b)
+	}
+	req, err := http.NewRequest(method, s.URL+path, payload)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+adminToken)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	return resp.StatusCode, b
+}
+
+// call sends a request expecting status and decodes the data of the response envelope into data
+func (s *server) call(method, path string, body interface{}, status int, data interface{}) {
+	s.t.Helper()
+	got, b := s.do(method, path, body)
+	if got != status {
+		s.t.Fatalf("%s %s: got %d, want %d: %s", method, path, got, status, b)
+	}
+	if data != nil {
+		envelope := struct {
+			Data interface{} `json:"data"`
+		}{data}
+		if err := json.Unmarshal(b, &envelope); err != nil {
+			s.t.Fatalf("%s %s: %v: %s", method, path, err, b)
+		}
+	}
+}
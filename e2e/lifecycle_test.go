@@ -0,0 +1,134 @@
+package e2e
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"survey_form_go/webhook"
+)
+
+// delivery is a webhook delivery whose signature checked out
+type delivery struct {
+	Event    string `json:"event"`
+	SurveyID int    `json:"survey_id"`
+}
+
+// receiver collects the webhook deliveries signed with its secret
+type receiver struct {
+	mu         sync.Mutex
+	secret     []byte
+	deliveries chan delivery
+}
+
+func (rc *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rc.mu.Lock()
+	secret := rc.secret
+	rc.mu.Unlock()
+	body, err := webhook.VerifyRequest(r, secret, webhook.DefaultTolerance)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	var d delivery
+	if err := json.Unmarshal(body, &d); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	rc.deliveries <- d
+}
+
+// await waits for a delivery of event
+func (rc *receiver) await(t *testing.T, event string) delivery {
+	t.Helper()
+	timeout := time.After(10 * time.Second)
+	for {
+		select {
+		case d := <-rc.deliveries:
+			if d.Event == event {
+				return d
+			}
+		case <-timeout:
+			t.Fatalf("no %s delivery", event)
+		}
+	}
+}
+
+// TestSurveyLifecycle creates a survey, publishes it, submits a response,
+// exports it and receives the webhooks, then checks everything survives a restart
+func TestSurveyLifecycle(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "survey_form.db")
+	s := startServer(t, dbPath)
+
+	rc := &receiver{deliveries: make(chan delivery, 16)}
+	hooks := httptest.NewServer(rc)
+	defer hooks.Close()
+	var hook struct {
+		Secret string `json:"secret"`
+	}
+	s.call("POST", "/api/admin/webhooks", map[string]interface{}{
+		"webhook": map[string]interface{}{"url": hooks.URL, "events": []string{"survey.published", "response.created"}},
+	}, http.StatusCreated, &hook)
+	rc.mu.Lock()
+	rc.secret = []byte(hook.Secret)
+	rc.mu.Unlock()
+
+	var survey struct {
+		ID             int    `json:"id"`
+		Status         string `json:"status"`
+		ResponsesCount int    `json:"responses_count"`
+	}
+	s.call("POST", "/api/surveys", map[string]interface{}{
+		"survey": map[string]interface{}{
+			"title":       "Onboarding",
+			"description": "How was your first week?",
+			"status":      "draft",
+			"questions":   []map[string]interface{}{{"id": "rating", "type": "rating", "label": "Rate your first week", "required": true}},
+		},
+	}, http.StatusCreated, &survey)
+	assert.Equal(t, "draft", survey.Status)
+	base := fmt.Sprintf("/api/surveys/%d", survey.ID)
+
+	// Drafts don't take responses until published
+	status, _ := s.do("POST", base+"/responses", map[string]interface{}{
+		"survey_response": map[string]interface{}{"user_identifier": "early", "response_data": map[string]interface{}{"rating": 4}},
+	})
+	assert.Equal(t, http.StatusUnprocessableEntity, status)
+
+	s.call("POST", base+"/publish", nil, http.StatusOK, &survey)
+	assert.Equal(t, "published", survey.Status)
+	assert.Equal(t, survey.ID, rc.await(t, "survey.published").SurveyID)
+
+	s.call("POST", base+"/responses", map[string]interface{}{
+		"survey_response": map[string]interface{}{"user_identifier": "new-hire", "response_data": map[string]interface{}{"rating": 5}},
+	}, http.StatusCreated, nil)
+	assert.Equal(t, survey.ID, rc.await(t, "response.created").SurveyID)
+
+	status, body := s.do("GET", base+"/responses/export.ndjson", nil)
+	assert.Equal(t, http.StatusOK, status, string(body))
+	var exported []map[string]interface{}
+	lines := bufio.NewScanner(bytes.NewReader(body))
+	for lines.Scan() {
+		var line map[string]interface{}
+		assert.NoError(t, json.Unmarshal(lines.Bytes(), &line))
+		exported = append(exported, line)
+	}
+	if assert.Len(t, exported, 1) {
+		assert.Equal(t, "new-hire", exported[0]["user_identifier"])
+	}
+
+	// A restart on the same database keeps the survey and its response
+	s.stop()
+	s = startServer(t, dbPath)
+	s.call("GET", base, nil, http.StatusOK, &survey)
+	assert.Equal(t, "published", survey.Status)
+	assert.Equal(t, 1, survey.ResponsesCount)
+}
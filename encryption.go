@@ -0,0 +1,181 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// encryptedPrefix marks encrypted response_data; JSON never starts with it
+const encryptedPrefix = "enc:v1:"
+
+// encryptBatchSize is how many stored responses are encrypted per transaction
+const encryptBatchSize = 500
+
+// responseDataCipher encrypts response_data at rest; nil leaves it in plain text
+var responseDataCipher cipher.AEAD
+
+// errNoResponseDataKey is returned reading encrypted response_data without a key
+var errNoResponseDataKey = errors.New("response_data is encrypted but no key is configured")
+
+// loadResponseDataKey reads the response_data key, base64 encoded, from
+// RESPONSE_DATA_KEY or the output of RESPONSE_DATA_KEY_COMMAND, e.g. a KMS
+// decrypt call. It returns nil when neither is set.
+func loadResponseDataKey() ([]byte, error) {
+	encoded := os.Getenv("RESPONSE_DATA_KEY")
+	if command := os.Getenv("RESPONSE_DATA_KEY_COMMAND"); encoded == "" && command != "" {
+		out, err := exec.Command("sh", "-c", command).Output()
+		if err != nil {
+			return nil, fmt.Errorf("RESPONSE_DATA_KEY_COMMAND: %w", err)
+		}
+		encoded = string(out)
+	}
+	if encoded = strings.TrimSpace(encoded); encoded == "" {
+		return nil, nil
+	}
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(key) != 32 {
+		return nil, errors.New("response_data key must be 32 bytes, base64 encoded")
+	}
+	return key, nil
+}
+
+// newResponseDataCipher returns the AES-256-GCM cipher of a 32 byte key
+func newResponseDataCipher(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// initResponseEncryption turns on response_data encryption when a key is configured
+func initResponseEncryption() error {
+	key, err := loadResponseDataKey()
+	if err != nil || key == nil {
+		return err
+	}
+	responseDataCipher, err = newResponseDataCipher(key)
+	return err
+}
+
+// sealResponseData compresses and encrypts response_data for storage, as configured
+func sealResponseData(data []byte) []byte {
+	return encryptResponseData(compressResponseData(data))
+}
+
+// openResponseData decrypts and decompresses stored response_data
+func openResponseData(stored []byte) ([]byte, error) {
+	data, err := decryptResponseData(stored)
+	if err != nil {
+		return nil, err
+	}
+	return decompressResponseData(data)
+}
+
+// encryptResponseData encrypts response_data with a random nonce; it is
+// returned as is while encryption is off
+func encryptResponseData(data []byte) []byte {
+	if responseDataCipher == nil {
+		return data
+	}
+	nonce := make([]byte, responseDataCipher.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		panic(err)
+	}
+	sealed := responseDataCipher.Seal(nonce, nonce, data, nil)
+	return append([]byte(encryptedPrefix), base64.StdEncoding.EncodeToString(sealed)...)
+}
+
+// decryptResponseData decrypts stored response_data; plain text, stored before
+// encryption was turned on, is returned as is
+func decryptResponseData(stored []byte) ([]byte, error) {
+	if !bytes.HasPrefix(stored, []byte(encryptedPrefix)) {
+		return stored, nil
+	}
+	if responseDataCipher == nil {
+		return nil, errNoResponseDataKey
+	}
+	sealed, err := base64.StdEncoding.DecodeString(string(stored[len(encryptedPrefix):]))
+	if err != nil {
+		return nil, err
+	}
+	n := responseDataCipher.NonceSize()
+	if len(sealed) < n {
+		return nil, errors.New("encrypted response_data is truncated")
+	}
+	return responseDataCipher.Open(nil, sealed[:n], sealed[n:], nil)
+}
+
+// encryptedColumns hold answers, so they are encrypted with response_data:
+// audit snapshots and job payloads, such as webhook deliveries, copy responses
+var encryptedColumns = []struct{ table, column string }{
+	{"survey_responses", "response_data"},
+	{"response_revisions", "response_data"},
+	{"audit_log", "before_data"},
+	{"audit_log", "after_data"},
+	{"jobs", "payload"},
+}
+
+// encryptStoredResponses encrypts the response_data of responses and revisions,
+// audit snapshots and job payloads stored in plain text, so turning encryption
+// on covers existing data
+func encryptStoredResponses(ctx context.Context) (int, error) {
+	if responseDataCipher == nil {
+		return 0, nil
+	}
+	total := 0
+	for _, c := range encryptedColumns {
+		for {
+			n, err := encryptStoredBatch(ctx, c.table, c.column)
+			if err != nil {
+				return total, err
+			}
+			if total += n; n < encryptBatchSize {
+				break
+			}
+		}
+	}
+	return total, nil
+}
+
+// encryptStoredBatch encrypts column in up to encryptBatchSize plain text rows of table
+func encryptStoredBatch(ctx context.Context, table, column string) (int, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, "SELECT id, "+column+" FROM "+table+" WHERE "+column+" IS NOT NULL AND "+column+" NOT LIKE ? LIMIT ?",
+		encryptedPrefix+"%", encryptBatchSize)
+	if err != nil {
+		return 0, err
+	}
+	plain := map[int][]byte{}
+	for rows.Next() {
+		var id int
+		var data []byte
+		if err := rows.Scan(&id, &data); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		plain[id] = data
+	}
+	rows.Close()
+
+	for id, data := range plain {
+		if _, err := tx.ExecContext(ctx, "UPDATE "+table+" SET "+column+" = ? WHERE id = ?", encryptResponseData(data), id); err != nil {
+			return 0, err
+		}
+	}
+	return len(plain), tx.Commit()
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadResponseDataKey(t *testing.T) {
+	key := bytes.Repeat([]byte{7}, 32)
+	t.Setenv("RESPONSE_DATA_KEY", "")
+	t.Setenv("RESPONSE_DATA_KEY_COMMAND", "")
+	loaded, err := loadResponseDataKey()
+	assert.NoError(t, err)
+	assert.Nil(t, loaded)
+
+	t.Setenv("RESPONSE_DATA_KEY_COMMAND", "echo "+base64.StdEncoding.EncodeToString(key))
+	loaded, err = loadResponseDataKey()
+	assert.NoError(t, err)
+	assert.Equal(t, key, loaded)
+
+	t.Setenv("RESPONSE_DATA_KEY", base64.StdEncoding.EncodeToString(key[:16]))
+	_, err = loadResponseDataKey()
+	assert.Error(t, err)
+}
+
+func TestSealResponseData(t *testing.T) {
+	defer func() { responseDataCipher = nil }()
+	data := []byte(`{"comment": "Secret"}`)
+	assert.Equal(t, data, sealResponseData(data))
+
+	var err error
+	responseDataCipher, err = newResponseDataCipher(bytes.Repeat([]byte{7}, 32))
+	assert.NoError(t, err)
+	sealed := sealResponseData(data)
+	assert.True(t, bytes.HasPrefix(sealed, []byte(encryptedPrefix)))
+	assert.NotContains(t, string(sealed), "Secret")
+	// Nonces are random, so equal answers don't look alike
+	assert.NotEqual(t, sealed, sealResponseData(data))
+
+	opened, err := openResponseData(sealed)
+	assert.NoError(t, err)
+	assert.Equal(t, data, opened)
+	opened, err = openResponseData(data)
+	assert.NoError(t, err)
+	assert.Equal(t, data, opened)
+
+	sealed[len(sealed)-2] ^= 1
+	_, err = openResponseData(sealed)
+	assert.Error(t, err)
+
+	responseDataCipher = nil
+	_, err = openResponseData(sealResponseData(data))
+	assert.NoError(t, err)
+	_, err = openResponseData([]byte(encryptedPrefix + "AAAA"))
+	assert.Equal(t, errNoResponseDataKey, err)
+}
+
+func TestEncryptedResponses(t *testing.T) {
+	setupTestDB()
+	defer testDB.Close()
+	router := setupTestRouter()
+
+	result, err := testDB.Exec("INSERT INTO surveys (title, description) VALUES ('Private', 'd')")
+	assert.NoError(t, err)
+	surveyID, _ := result.LastInsertId()
+	result, err = testDB.Exec("INSERT INTO survey_responses (survey_id, user_identifier, response_data) VALUES (?, 'user1', ?)",
+		surveyID, `{"comment": "Stored before encryption"}`)
+	assert.NoError(t, err)
+	oldID, _ := result.LastInsertId()
+	old := map[string]interface{}{"id": oldID, "response_data": map[string]string{"comment": "Stored before encryption"}}
+	assert.NoError(t, recordChange(context.Background(), testDB, "admin", "create", "survey_response", int(oldID), nil, old))
+	_, err = enqueueJob(context.Background(), JobKindWebhook, old, 1)
+	assert.NoError(t, err)
+	testDB.Exec("INSERT INTO webhook_subscriptions (url, secret, events, created_at) VALUES ('https://example.com/hook', 's', '[\"response.created\"]', CURRENT_TIMESTAMP)")
+
+	defer func() { responseDataCipher = nil }()
+	responseDataCipher, err = newResponseDataCipher(bytes.Repeat([]byte{7}, 32))
+	assert.NoError(t, err)
+	n, err := encryptStoredResponses(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 3, n, "the response, its audit snapshot and the queued job")
+
+	send := func(method, url, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, url, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		return w
+	}
+	base := fmt.Sprintf("/api/surveys/%d", surveyID)
+	w := send("POST", base+"/responses", `{"survey_response":{"user_identifier":"user2","response_data":{"comment":"Submitted encrypted"}}}`)
+	assert.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+	// Nothing readable is stored, the search index included
+	rows, err := testDB.Query("SELECT response_data FROM survey_responses")
+	assert.NoError(t, err)
+	for rows.Next() {
+		var data string
+		rows.Scan(&data)
+		assert.True(t, strings.HasPrefix(data, encryptedPrefix), data)
+		assert.NotContains(t, data, "encrypt")
+	}
+	rows.Close()
+	var indexed int
+	testDB.QueryRow("SELECT COUNT(*) FROM response_search WHERE body IS NOT NULL").Scan(&indexed)
+	assert.Equal(t, 0, indexed)
+
+	// Nor are answers copied in plain text to audit snapshots or queued
+	// webhook deliveries
+	for _, query := range []string{
+		"SELECT after_data FROM audit_log WHERE entity_type = 'survey_response' AND after_data IS NOT NULL",
+		"SELECT payload FROM jobs",
+	} {
+		rows, err := testDB.Query(query)
+		assert.NoError(t, err)
+		stored := 0
+		for rows.Next() {
+			var data string
+			rows.Scan(&data)
+			assert.True(t, strings.HasPrefix(data, encryptedPrefix), data)
+			stored++
+		}
+		rows.Close()
+		assert.Equal(t, 2, stored, query)
+	}
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, adminRequest("GET", fmt.Sprintf("/api/admin/audit?entity_type=survey_response&entity_id=%d", oldID), nil))
+	assert.Contains(t, w.Body.String(), "Stored before encryption")
+
+	// API consumers see the answers as they were submitted
+	w = send("GET", base+"/responses", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "Stored before encryption")
+	assert.Contains(t, w.Body.String(), "Submitted encrypted")
+	w = send("GET", base+"/results", "")
+	assert.Contains(t, w.Body.String(), `"Submitted encrypted":1`)
+
+	assert.Equal(t, http.StatusBadRequest, send("GET", base+"/responses?answer[comment]=x", "").Code)
+	assert.Equal(t, http.StatusBadRequest, send("GET", base+"/responses/search?q=stored", "").Code)
+}
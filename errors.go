@@ -0,0 +1,240 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// Error codes sent as "code" in error payloads. Clients branch on these; the
+// messages are for people and may change.
+const (
+	CodeInvalidID      = "INVALID_ID"
+	CodeInvalidRequest = "INVALID_REQUEST"
+	CodeInvalidQuery   = "INVALID_QUERY"
+	CodeValidation     = "VALIDATION_FAILED"
+	CodeGraphQLInvalid = "GRAPHQL_INVALID"
+
+	CodeSurveyNotFound          = "SURVEY_NOT_FOUND"
+	CodeResponseNotFound        = "RESPONSE_NOT_FOUND"
+	CodePartialResponseNotFound = "PARTIAL_RESPONSE_NOT_FOUND"
+	CodeScannedResponseNotFound = "SCANNED_RESPONSE_NOT_FOUND"
+	CodeReviewItemNotFound      = "REVIEW_ITEM_NOT_FOUND"
+	CodeViewNotFound            = "VIEW_NOT_FOUND"
+	CodeFormulaNotFound         = "FORMULA_NOT_FOUND"
+	CodeWebhookNotFound         = "WEBHOOK_NOT_FOUND"
+	CodeSurveyTokenNotFound     = "SURVEY_TOKEN_NOT_FOUND"
+	CodeViewerNotFound          = "VIEWER_NOT_FOUND"
+	CodeAPIKeyNotFound          = "API_KEY_NOT_FOUND"
+	CodeSuppressionNotFound     = "SUPPRESSION_NOT_FOUND"
+	CodeCreatorNotFound         = "CREATOR_NOT_FOUND"
+	CodeOrganizationNotFound    = "ORGANIZATION_NOT_FOUND"
+	CodeOrgUnitNotFound         = "ORG_UNIT_NOT_FOUND"
+	CodeShareLinkNotFound       = "SHARE_LINK_NOT_FOUND"
+	CodeInvitationNotFound      = "INVITATION_NOT_FOUND"
+	CodeSheetSyncNotFound       = "SHEET_SYNC_NOT_FOUND"
+	CodeExportJobNotFound       = "EXPORT_JOB_NOT_FOUND"
+	CodeExportRunNotFound       = "EXPORT_RUN_NOT_FOUND"
+	CodeJobNotFound             = "JOB_NOT_FOUND"
+	CodeQuestionNotFound        = "QUESTION_NOT_FOUND"
+	CodeResumeCodeInvalid       = "RESUME_CODE_INVALID"
+	CodeDiscoveryDisabled       = "DISCOVERY_DISABLED"
+	CodeDomainNotFound          = "DOMAIN_NOT_FOUND"
+
+	CodeSurveyNotOpen        = "SURVEY_NOT_OPEN"
+	CodeSurveyInvalid        = "SURVEY_INVALID"
+	CodeSurveyFull           = "SURVEY_FULL"
+	CodeAlreadyResponded     = "ALREADY_RESPONDED"
+	CodeAlreadySubmitted     = "ALREADY_SUBMITTED"
+	CodeIdempotencyKeyReused = "IDEMPOTENCY_KEY_REUSED"
+	CodeAlreadyReviewed      = "ALREADY_REVIEWED"
+	CodeOrgUnitHasChildren   = "ORG_UNIT_HAS_CHILDREN"
+	CodeSurveyInUse          = "SURVEY_IN_USE"
+	CodeJobNotDead           = "JOB_NOT_DEAD"
+	CodeDomainTaken          = "DOMAIN_TAKEN"
+	CodeEditWindowClosed     = "EDIT_WINDOW_CLOSED"
+	CodeAnswerConflict       = "ANSWER_CONFLICT"
+	CodeConcurrentSave       = "CONCURRENT_SAVE"
+	CodeFiltersRequired      = "FILTERS_REQUIRED"
+	CodeQueryTooLarge        = "QUERY_TOO_LARGE"
+	CodeGraphQLTooComplex    = "GRAPHQL_TOO_COMPLEX"
+	CodeAnalyticsTimeout     = "ANALYTICS_TIMEOUT"
+	CodeExportsBusy          = "EXPORTS_BUSY"
+	CodeLiveResultsFull      = "LIVE_RESULTS_FULL"
+	CodeCompressionOff       = "COMPRESSION_OFF"
+	CodeRateLimited          = "RATE_LIMITED"
+	CodeAdminRequired        = "ADMIN_REQUIRED"
+	CodeInvalidSurveyToken   = "INVALID_SURVEY_TOKEN"
+	CodeSurveyTokenDenied    = "SURVEY_TOKEN_FORBIDDEN"
+	CodeInvalidViewerToken   = "INVALID_VIEWER_TOKEN"
+	CodeViewerTokenDenied    = "VIEWER_TOKEN_FORBIDDEN"
+	CodeInvalidAPIKey        = "INVALID_API_KEY"
+	CodeAPIKeyDenied         = "API_KEY_FORBIDDEN"
+	CodeInvalidCreatorToken  = "INVALID_CREATOR_TOKEN"
+	CodeInvalidInboundSecret = "INVALID_INBOUND_SECRET"
+	CodeCreatorRequired      = "CREATOR_REQUIRED"
+	CodeOrganizationRequired = "ORGANIZATION_REQUIRED"
+	CodeOrganizationDenied   = "ORGANIZATION_FORBIDDEN"
+	CodeSheetsUnavailable    = "SHEETS_UNAVAILABLE"
+	CodeInternal             = "INTERNAL_ERROR"
+)
+
+// APIError is an error answered to the client. Handlers stop with
+// abortWithError and handleErrors renders it in the response envelope.
+type APIError struct {
+	Status  int
+	Code    string
+	Message string
+	Errors  []string
+	Data    interface{}
+	// Err is the underlying cause; it is logged, never sent to clients
+	Err error
+}
+
+func (e *APIError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %s: %v", e.Code, e.Message, e.Err)
+	}
+	return e.Code + ": " + e.Message
+}
+
+func (e *APIError) Unwrap() error {
+	return e.Err
+}
+
+// errInvalidID reports a malformed ID path parameter, e.g. errInvalidID("survey")
+func errInvalidID(entity string) *APIError {
+	return &APIError{Status: http.StatusBadRequest, Code: CodeInvalidID, Message: "Invalid " + entity + " ID"}
+}
+
+// errInvalidRequest reports a request body that couldn't be bound
+func errInvalidRequest(err error) *APIError {
+	return &APIError{Status: http.StatusBadRequest, Code: CodeInvalidRequest, Message: "Invalid request data", Errors: bindingErrors(err)}
+}
+
+// errInvalidQuery reports invalid query parameters
+func errInvalidQuery(errors []string) *APIError {
+	return &APIError{Status: http.StatusBadRequest, Code: CodeInvalidQuery, Message: "Invalid query parameters", Errors: errors}
+}
+
+// errValidation reports a request body that failed validation
+func errValidation(message string, errors []string) *APIError {
+	return &APIError{Status: http.StatusUnprocessableEntity, Code: CodeValidation, Message: message, Errors: errors}
+}
+
+// errNotFound reports a missing entity
+func errNotFound(code, message string) *APIError {
+	return &APIError{Status: http.StatusNotFound, Code: code, Message: message}
+}
+
+// errInternal reports a server failure; err is logged but not sent to clients
+func errInternal(message string, err error) *APIError {
+	return &APIError{Status: http.StatusInternalServerError, Code: CodeInternal, Message: message, Err: err}
+}
+
+// abortWithError stops the request with err, which handleErrors renders
+func abortWithError(c *gin.Context, err *APIError) {
+	c.Error(err)
+	c.Abort()
+}
+
+// handleErrors renders the error a handler or middleware stopped with. Errors
+// other than *APIError are answered as internal errors.
+func handleErrors() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+		if len(c.Errors) == 0 || c.Writer.Written() {
+			return
+		}
+		var apiErr *APIError
+		if err := c.Errors.Last().Err; !errors.As(err, &apiErr) {
+			apiErr = errInternal("Internal server error", err)
+		}
+		renderError(c, apiErr)
+	}
+}
+
+// renderError writes err in the response envelope, or as a JSON:API error
+// document to the clients that asked for one
+func renderError(c *gin.Context, err *APIError) {
+	if c.GetBool("jsonapi") {
+		renderJSONAPIError(c, err)
+		return
+	}
+	c.AbortWithStatusJSON(err.Status, APIResponse{
+		Status:    "error",
+		Code:      err.Code,
+		Message:   err.Message,
+		Errors:    err.Errors,
+		Data:      err.Data,
+		RequestID: requestID(c),
+	})
+}
+
+// bindingErrors describes why a request body couldn't be bound, naming fields
+// by their JSON keys rather than Go struct fields
+func bindingErrors(err error) []string {
+	var validationErrors validator.ValidationErrors
+	var typeError *json.UnmarshalTypeError
+	var syntaxError *json.SyntaxError
+	switch {
+	case errors.As(err, &validationErrors):
+		var messages []string
+		for _, fe := range validationErrors {
+			field := strings.SplitN(fe.Namespace(), ".", 2)
+			name := fe.Field()
+			if len(field) == 2 {
+				name = field[1]
+			}
+			if fe.Tag() == "required" {
+				messages = append(messages, name+" is required")
+			} else {
+				messages = append(messages, name+" is invalid")
+			}
+		}
+		return messages
+	case errors.As(err, &typeError):
+		return []string{fmt.Sprintf("%s must be %s", typeError.Field, jsonTypeName(typeError.Type))}
+	case errors.As(err, &syntaxError), errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return []string{"Request body must be valid JSON"}
+	}
+	return []string{"Request body could not be read"}
+}
+
+// jsonTypeName describes a Go type as the JSON value it decodes from
+func jsonTypeName(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.String:
+		return "a string"
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	}
+	return "an object"
+}
+
+// init names validation errors after the JSON keys of request bodies
+func init() {
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		v.RegisterTagNameFunc(func(field reflect.StructField) string {
+			name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+			if name == "-" {
+				return ""
+			}
+			return name
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErrorCodes(t *testing.T) {
+	setupTestDB()
+	defer testDB.Close()
+
+	router := setupTestRouter()
+
+	tests := []struct {
+		method, url, body string
+		status            int
+		code              string
+		errors            []string
+	}{
+		{"GET", "/api/surveys/abc", "", http.StatusBadRequest, CodeInvalidID, nil},
+		{"GET", "/api/surveys/999", "", http.StatusNotFound, CodeSurveyNotFound, nil},
+		{"POST", "/api/surveys", `{"survey":`, http.StatusBadRequest, CodeInvalidRequest, []string{"Request body must be valid JSON"}},
+		{"POST", "/api/surveys", `{"survey":{"title":1,"description":"d"}}`, http.StatusBadRequest, CodeInvalidRequest, []string{"survey.title must be a string"}},
+		{"POST", "/api/surveys", `{"survey":{"description":"d"}}`, http.StatusBadRequest, CodeInvalidRequest, []string{"survey.title is required"}},
+		{"POST", "/api/surveys", `{"survey":{"title":"ab","description":"d"}}`, http.StatusUnprocessableEntity, CodeValidation, nil},
+		{"GET", "/api/admin/audit", "", http.StatusForbidden, CodeAdminRequired, nil},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(tt.method, tt.url, bytes.NewBufferString(tt.body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+
+		var response APIResponse
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, tt.status, w.Code, tt.url)
+		assert.Equal(t, "error", response.Status)
+		assert.Equal(t, tt.code, response.Code, tt.body)
+		if tt.errors != nil {
+			assert.Equal(t, tt.errors, response.Errors)
+		}
+		assert.NotEmpty(t, response.RequestID)
+	}
+}
+
+func TestInternalErrorsAreNotLeaked(t *testing.T) {
+	var logs bytes.Buffer
+	defer slog.SetDefault(slog.Default())
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&logs, nil)))
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(requestIDs(), requestLogger(), gin.CustomRecoveryWithWriter(&bytes.Buffer{}, recoverPanic), handleErrors())
+	router.GET("/api", func(c *gin.Context) {
+		abortWithError(c, errInternal("Failed to fetch surveys", errors.New("no such table: surveys")))
+	})
+	router.GET("/plain", func(c *gin.Context) {
+		c.Error(errors.New("disk I/O error"))
+	})
+	router.GET("/panic", func(c *gin.Context) {
+		panic("nil map")
+	})
+
+	for url, message := range map[string]string{"/api": "Failed to fetch surveys", "/plain": "Internal server error", "/panic": "Internal server error"} {
+		logs.Reset()
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", url, nil)
+		router.ServeHTTP(w, req)
+
+		var response APIResponse
+		json.Unmarshal(w.Body.Bytes(), &response)
+		assert.Equal(t, http.StatusInternalServerError, w.Code, url)
+		assert.Equal(t, CodeInternal, response.Code)
+		assert.Equal(t, message, response.Message)
+		assert.Empty(t, response.Errors)
+		assert.NotContains(t, w.Body.String(), "no such table")
+		assert.NotContains(t, w.Body.String(), "disk I/O")
+		assert.Contains(t, logs.String(), `"level":"ERROR"`)
+	}
+	// The cause is logged with the request
+	logs.Reset()
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api", nil)
+	router.ServeHTTP(w, req)
+	assert.Contains(t, logs.String(), "no such table: surveys")
+}
@@ -0,0 +1,290 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Types of experience events reported by survey embeds
+const (
+	ExperienceRender      = "render"
+	ExperienceRenderError = "render_error"
+)
+
+// experienceDefaultWindow is the period covered by the metrics when ?from= is not given
+const experienceDefaultWindow = 7 * 24 * time.Hour
+
+// maxExperienceErrorLength bounds the stored error message of a failed render
+const maxExperienceErrorLength = 500
+
+// CreateExperienceEventRequest represents the request body for reporting an event
+type CreateExperienceEventRequest struct {
+	ExperienceEvent struct {
+		Type string `json:"type" binding:"required"`
+		// LoadMs is how long the form took to render, as measured by the client
+		LoadMs *int `json:"load_ms"`
+		// Device and Browser default to what the User-Agent tells
+		Device  string `json:"device"`
+		Browser string `json:"browser"`
+		Error   string `json:"error"`
+	} `json:"experience_event" binding:"required"`
+}
+
+// ExperienceStats summarizes the renders of a survey, or of one device or browser
+type ExperienceStats struct {
+	// Name is the device or browser, empty when unknown
+	Name         string  `json:"name,omitempty"`
+	Renders      int     `json:"renders"`
+	RenderErrors int     `json:"render_errors"`
+	ErrorRate    float64 `json:"error_rate"`
+	MedianLoadMs *int    `json:"median_load_ms"`
+}
+
+// ExperienceMetrics describes how a survey's form behaves for respondents
+type ExperienceMetrics struct {
+	SurveyID int       `json:"survey_id"`
+	From     time.Time `json:"from"`
+	To       time.Time `json:"to"`
+	ExperienceStats
+	Devices  []ExperienceStats `json:"devices"`
+	Browsers []ExperienceStats `json:"browsers"`
+}
+
+// browserFromUserAgent roughly names the browser of a User-Agent. Order matters:
+// Edge and Opera mention Chrome, and Chrome mentions Safari.
+func browserFromUserAgent(userAgent string) string {
+	switch {
+	case userAgent == "":
+		return ""
+	case strings.Contains(userAgent, "Edg/"):
+		return "edge"
+	case strings.Contains(userAgent, "OPR/"):
+		return "opera"
+	case strings.Contains(userAgent, "Firefox/") || strings.Contains(userAgent, "FxiOS/"):
+		return "firefox"
+	case strings.Contains(userAgent, "Chrome/") || strings.Contains(userAgent, "CriOS/"):
+		return "chrome"
+	case strings.Contains(userAgent, "Safari/"):
+		return "safari"
+	}
+	return "other"
+}
+
+// createExperienceEvent records a render or render error reported by a survey embed
+func createExperienceEvent(c *gin.Context) {
+	ctx := c.Request.Context()
+	surveyID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		abortWithError(c, errInvalidID("survey"))
+		return
+	}
+
+	var exists bool
+	err = db.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM surveys WHERE id = ?)", surveyID).Scan(&exists)
+	if err != nil || !exists {
+		abortWithError(c, errNotFound(CodeSurveyNotFound, "Survey not found"))
+		return
+	}
+
+	var req CreateExperienceEventRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		abortWithError(c, errInvalidRequest(err))
+		return
+	}
+	event := req.ExperienceEvent
+
+	var errors []string
+	if event.Type != ExperienceRender && event.Type != ExperienceRenderError {
+		errors = append(errors, "Type must be either render or render_error")
+	}
+	if event.LoadMs != nil && (*event.LoadMs < 0 || *event.LoadMs > 600000) {
+		errors = append(errors, "Load time must be between 0 and 600000 milliseconds")
+	}
+	if len(event.Device) > 50 {
+		errors = append(errors, "Device must be less than 50 characters")
+	}
+	if len(event.Browser) > 50 {
+		errors = append(errors, "Browser must be less than 50 characters")
+	}
+	if len(errors) > 0 {
+		abortWithError(c, errValidation("Failed to record experience event", errors))
+		return
+	}
+
+	if event.Device == "" {
+		event.Device = deviceFromUserAgent(c.GetHeader("User-Agent"))
+	}
+	if event.Browser == "" {
+		event.Browser = browserFromUserAgent(c.GetHeader("User-Agent"))
+	}
+	if len(event.Error) > maxExperienceErrorLength {
+		event.Error = event.Error[:maxExperienceErrorLength]
+	}
+
+	_, err = db.ExecContext(ctx, `
+		INSERT INTO experience_events (survey_id, type, load_ms, device, browser, error, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
+	`, surveyID, event.Type, event.LoadMs, strings.ToLower(event.Device), strings.ToLower(event.Browser), event.Error)
+	if err != nil {
+		abortWithError(c, errInternal("Failed to record experience event", err))
+		return
+	}
+
+	c.JSON(http.StatusCreated, APIResponse{
+		Status:  "success",
+		Message: "Experience event recorded",
+	})
+}
+
+// experienceStats computes the stats of the events matching where, grouped by
+// column, or as one group when column is empty
+func experienceStats(ctx context.Context, column, where string, args []interface{}) ([]ExperienceStats, error) {
+	group := "''"
+	if column != "" {
+		group = column
+	}
+
+	stats := map[string]*ExperienceStats{}
+	rows, err := db.QueryContext(ctx, `
+		SELECT `+group+`, SUM(type = 'render'), SUM(type = 'render_error')
+		FROM experience_events
+		WHERE `+where+`
+		GROUP BY 1
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		s := &ExperienceStats{}
+		if err := rows.Scan(&s.Name, &s.Renders, &s.RenderErrors); err != nil {
+			return nil, err
+		}
+		if total := s.Renders + s.RenderErrors; total > 0 {
+			s.ErrorRate = float64(s.RenderErrors) / float64(total)
+		}
+		stats[s.Name] = s
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// The lower median of each group's load times
+	rows, err = db.QueryContext(ctx, `
+		SELECT name, load_ms FROM (
+			SELECT `+group+` AS name, load_ms,
+				ROW_NUMBER() OVER (PARTITION BY `+group+` ORDER BY load_ms) AS n,
+				COUNT(*) OVER (PARTITION BY `+group+`) AS total
+			FROM experience_events
+			WHERE `+where+` AND type = 'render' AND load_ms IS NOT NULL
+		)
+		WHERE n = (total + 1) / 2
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name string
+		var median int
+		if err := rows.Scan(&name, &median); err != nil {
+			return nil, err
+		}
+		if s, ok := stats[name]; ok {
+			s.MedianLoadMs = &median
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Most renders first, so the devices and browsers that matter lead
+	list := []ExperienceStats{}
+	for _, s := range stats {
+		list = append(list, *s)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if a, b := list[i].Renders+list[i].RenderErrors, list[j].Renders+list[j].RenderErrors; a != b {
+			return a > b
+		}
+		return list[i].Name < list[j].Name
+	})
+	return list, nil
+}
+
+// getExperienceMetrics returns the render error rate, median load time and device
+// and browser breakdown of a survey's embeds between ?from= (default a week ago)
+// and ?to= (default now)
+func getExperienceMetrics(c *gin.Context) {
+	ctx := c.Request.Context()
+	surveyID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		abortWithError(c, errInvalidID("survey"))
+		return
+	}
+
+	now := time.Now().UTC()
+	metrics := ExperienceMetrics{SurveyID: surveyID, From: now.Add(-experienceDefaultWindow), To: now}
+	var errors []string
+	for _, bound := range []struct {
+		param, label string
+		dest         *time.Time
+	}{
+		{"from", "From", &metrics.From},
+		{"to", "To", &metrics.To},
+	} {
+		value := c.Query(bound.param)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			errors = append(errors, bound.label+" must be an RFC 3339 timestamp")
+			continue
+		}
+		*bound.dest = t.UTC()
+	}
+	if len(errors) > 0 {
+		abortWithError(c, errInvalidQuery(errors))
+		return
+	}
+
+	var exists bool
+	err = db.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM surveys WHERE id = ?)", surveyID).Scan(&exists)
+	if err != nil || !exists {
+		abortWithError(c, errNotFound(CodeSurveyNotFound, "Survey not found"))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, analyticsTimeout)
+	defer cancel()
+
+	where := "survey_id = ? AND created_at >= ? AND created_at <= ?"
+	args := []interface{}{surveyID, metrics.From.Format(cursorTimeFormat), metrics.To.Format(cursorTimeFormat)}
+
+	overall, err := experienceStats(ctx, "", where, args)
+	if err == nil && len(overall) > 0 {
+		metrics.ExperienceStats = overall[0]
+	}
+	if err == nil {
+		metrics.Devices, err = experienceStats(ctx, "device", where, args)
+	}
+	if err == nil {
+		metrics.Browsers, err = experienceStats(ctx, "browser", where, args)
+	}
+	if err != nil {
+		analyticsQueryFailed(ctx, c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Status: "success",
+		Data:   metrics,
+	})
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBrowserFromUserAgent(t *testing.T) {
+	tests := map[string]string{
+		"": "",
+		"Mozilla/5.0 (iPhone) Version/17.0 Mobile Safari/604.1":                  "safari",
+		"Mozilla/5.0 (Windows NT 10.0) Chrome/120.0 Safari/537.36":               "chrome",
+		"Mozilla/5.0 (Windows NT 10.0) Chrome/120.0 Safari/537.36 Edg/120.0":     "edge",
+		"Mozilla/5.0 (X11; Linux x86_64; rv:121.0) Gecko/20100101 Firefox/121.0": "firefox",
+		"curl/8.0": "other",
+	}
+	for userAgent, want := range tests {
+		assert.Equal(t, want, browserFromUserAgent(userAgent), userAgent)
+	}
+}
+
+func TestExperienceMetrics(t *testing.T) {
+	setupTestDB()
+	defer testDB.Close()
+
+	result, err := testDB.Exec("INSERT INTO surveys (title, description) VALUES ('Embedded', 'd')")
+	assert.NoError(t, err)
+	surveyID, _ := result.LastInsertId()
+
+	router := setupTestRouter()
+	report := func(body, userAgent string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", fmt.Sprintf("/api/surveys/%d/experience-events", surveyID), bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("User-Agent", userAgent)
+		req.RemoteAddr = "192.0.2.1:1234"
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	const iphone = "Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) Version/17.0 Mobile Safari/604.1"
+	const desktop = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) Chrome/120.0 Safari/537.36"
+	for _, ms := range []int{300, 500, 400} {
+		w := report(fmt.Sprintf(`{"experience_event":{"type":"render","load_ms":%d}}`, ms), desktop)
+		assert.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	}
+	report(`{"experience_event":{"type":"render","load_ms":2000}}`, iphone)
+	report(`{"experience_event":{"type":"render_error","error":"TypeError: x is undefined"}}`, iphone)
+	report(`{"experience_event":{"type":"render_error"}}`, iphone)
+
+	w := report(`{"experience_event":{"type":"crash"}}`, desktop)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+	get := func(url string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", url, nil)
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w = get(fmt.Sprintf("/api/surveys/%d/experience", surveyID))
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var response struct {
+		Data ExperienceMetrics `json:"data"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	metrics := response.Data
+	assert.Equal(t, 4, metrics.Renders)
+	assert.Equal(t, 2, metrics.RenderErrors)
+	assert.InDelta(t, 1.0/3, metrics.ErrorRate, 0.001)
+	assert.Equal(t, 400, *metrics.MedianLoadMs)
+
+	// Mobile is where the embed breaks
+	if assert.Len(t, metrics.Devices, 2) {
+		assert.Equal(t, "desktop", metrics.Devices[0].Name)
+		assert.Equal(t, 0.0, metrics.Devices[0].ErrorRate)
+		assert.Equal(t, "mobile", metrics.Devices[1].Name)
+		assert.InDelta(t, 2.0/3, metrics.Devices[1].ErrorRate, 0.001)
+		assert.Equal(t, 2000, *metrics.Devices[1].MedianLoadMs)
+	}
+	if assert.Len(t, metrics.Browsers, 2) {
+		assert.Equal(t, "chrome", metrics.Browsers[0].Name)
+		assert.Equal(t, "safari", metrics.Browsers[1].Name)
+	}
+
+	// Nothing was reported before the window
+	w = get(fmt.Sprintf("/api/surveys/%d/experience?to=2020-01-01T00:00:00Z", surveyID))
+	json.Unmarshal(w.Body.Bytes(), &response)
+	assert.Equal(t, 0, response.Data.Renders)
+	assert.Nil(t, response.Data.MedianLoadMs)
+	assert.Empty(t, response.Data.Devices)
+
+	w = get(fmt.Sprintf("/api/surveys/%d/experience?from=yesterday", surveyID))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = get("/api/surveys/999/experience")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
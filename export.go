@@ -0,0 +1,174 @@
+package main
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// exportFlushRows is how many exported responses are buffered before they are sent
+const exportFlushRows = 100
+
+// exportPasswordHeader carries the password protecting an export, kept out
+// of URLs and logs. Each download sets its own, so every recipient can get theirs.
+const exportPasswordHeader = "X-Export-Password"
+
+// exportPassword reads the password of an export, if any, responding 422 when
+// it is too short
+func exportPassword(c *gin.Context) (string, bool) {
+	password := c.GetHeader(exportPasswordHeader)
+	if password != "" && len(password) < minExportPasswordLength {
+		abortWithError(c, errValidation("Invalid export password",
+			[]string{fmt.Sprintf("%s must be at least %d characters", exportPasswordHeader, minExportPasswordLength)}))
+		return "", false
+	}
+	return password, true
+}
+
+// exportTimeout bounds an export; a stream takes longer than DB_QUERY_TIMEOUT allows
+var exportTimeout = envDuration("EXPORT_TIMEOUT", 10*time.Minute)
+
+// exportResponses streams every response matching the analytics filters as
+// newline-delimited JSON, oldest first, with answers recoded by ?recode= and
+// PII masked for non-admins. Rows are written as they are read, so
+// memory use doesn't grow with the survey. With an X-Export-Password the
+// export is sent as a password-protected ZIP instead, once it is complete.
+func exportResponses(c *gin.Context) {
+	ctx := c.Request.Context()
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		abortWithError(c, errInvalidID("survey"))
+		return
+	}
+
+	q, errors := parseAnalyticsQuery(c, id)
+	if len(errors) > 0 {
+		abortWithError(c, errInvalidQuery(errors))
+		return
+	}
+
+	survey, err := findSurvey(ctx, id)
+	if err != nil {
+		abortWithError(c, errNotFound(CodeSurveyNotFound, "Survey not found"))
+		return
+	}
+	recodes, errors := parseRecode(c, survey)
+	if len(errors) > 0 {
+		abortWithError(c, errInvalidQuery(errors))
+		return
+	}
+
+	pii := piiMaskFor(c, survey)
+	password, ok := exportPassword(c)
+	if !ok {
+		return
+	}
+
+	orgID := 0
+	if survey.OrganizationID != nil {
+		orgID = *survey.OrganizationID
+	}
+	conn, err := exportConn(ctx, orgID)
+	if err != nil {
+		if err == context.DeadlineExceeded {
+			c.Header("Retry-After", strconv.Itoa(int(dbExportQueueTimeout.Seconds())))
+			abortWithError(c, &APIError{
+				Status:  http.StatusServiceUnavailable,
+				Code:    CodeExportsBusy,
+				Message: "Too many exports are running, try again later",
+			})
+			return
+		}
+		abortWithError(c, errInternal("Failed to export responses", err))
+		return
+	}
+	defer conn.Close()
+
+	// The request context stops the scan when the client goes away. The
+	// server's WriteTimeout would cut the stream off long before exportTimeout,
+	// so the write deadline is moved to match it.
+	ctx, cancel := context.WithTimeout(ctx, exportTimeout)
+	defer cancel()
+	http.NewResponseController(c.Writer).SetWriteDeadline(time.Now().Add(exportTimeout))
+	where, args := q.where()
+	rows, err := conn.QueryContext(ctx, `
+		SELECT `+responseColumns+`
+		FROM `+responsesFrom+`
+		WHERE `+where+`
+		ORDER BY sr.created_at ASC, sr.id ASC
+	`, args...)
+	if err != nil {
+		abortWithError(c, errInternal("Failed to export responses", err))
+		return
+	}
+	defer rows.Close()
+
+	// writeRows encodes the responses, one per line, calling flush every exportFlushRows
+	writeRows := func(w io.Writer, flush func()) error {
+		enc := json.NewEncoder(w)
+		n := 0
+		for rows.Next() {
+			response, err := scanResponse(rows)
+			if err != nil {
+				return err
+			}
+			if recodes != nil {
+				response.ResponseData = recodeResponseData(response.ResponseData, recodes)
+			}
+			response.ResponseData = maskResponseData(response.ResponseData, pii)
+			if err := enc.Encode(response); err != nil {
+				return err
+			}
+			if n++; n%exportFlushRows == 0 {
+				flush()
+			}
+		}
+		return rows.Err()
+	}
+
+	name := fmt.Sprintf("survey-%d-responses", id)
+	if password != "" {
+		entry, err := newAESZipEntry(password, func(w io.Writer) error { return writeRows(w, func() {}) })
+		if err != nil {
+			abortWithError(c, errInternal("Failed to export responses", err))
+			return
+		}
+		defer entry.Close()
+		c.Header("Content-Type", "application/zip")
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.zip"`, name))
+		c.Status(http.StatusOK)
+		zw := zip.NewWriter(c.Writer)
+		if err := entry.addTo(zw, name+".ndjson"); err == nil {
+			err = zw.Close()
+		}
+		if err != nil {
+			c.Error(fmt.Errorf("export of survey %d failed: %w", id, err))
+		}
+		return
+	}
+
+	// Without a Content-Length the response is sent with chunked transfer encoding
+	c.Header("Content-Type", "application/x-ndjson")
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.ndjson"`, name))
+	c.Status(http.StatusOK)
+
+	err = writeRows(c.Writer, c.Writer.Flush)
+	// The status is already sent; a trailing error line tells clients the export is incomplete
+	if err != nil && c.Request.Context().Err() == nil {
+		c.Error(fmt.Errorf("export of survey %d failed: %w", id, err))
+		json.NewEncoder(c.Writer).Encode(APIResponse{
+			Status:    "error",
+			Code:      CodeInternal,
+			Message:   "Failed to export responses",
+			RequestID: requestID(c),
+		})
+	}
+	c.Writer.Flush()
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExportResponses(t *testing.T) {
+	setupTestDB()
+	defer testDB.Close()
+
+	surveyID, ids := insertTestResponses(t, exportFlushRows+5)
+	_, err := testDB.Exec("UPDATE survey_responses SET channel = 'email' WHERE id = ?", ids[0])
+	assert.NoError(t, err)
+	_, err = testDB.Exec("UPDATE survey_responses SET deleted_at = CURRENT_TIMESTAMP WHERE id = ?", ids[1])
+	assert.NoError(t, err)
+
+	router := setupTestRouter()
+
+	export := func(query string) (*httptest.ResponseRecorder, []SurveyResponse) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", fmt.Sprintf("/api/surveys/%d/responses/export.ndjson%s", surveyID, query), nil)
+		router.ServeHTTP(w, req)
+
+		var responses []SurveyResponse
+		scanner := bufio.NewScanner(w.Body)
+		for scanner.Scan() {
+			var response SurveyResponse
+			assert.NoError(t, json.Unmarshal(scanner.Bytes(), &response))
+			responses = append(responses, response)
+		}
+		return w, responses
+	}
+
+	// One response per line, oldest first, without deleted responses
+	w, responses := export("")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/x-ndjson", w.Header().Get("Content-Type"))
+	assert.True(t, w.Flushed)
+	if assert.Len(t, responses, len(ids)-1) {
+		assert.Equal(t, ids[0], responses[0].ID)
+		assert.Equal(t, ids[2], responses[1].ID)
+		assert.JSONEq(t, `{"rating":"5"}`, string(responses[1].ResponseData))
+	}
+
+	// Filters match the analytics endpoints
+	_, responses = export("?channel=email")
+	if assert.Len(t, responses, 1) {
+		assert.Equal(t, ids[0], responses[0].ID)
+	}
+	w, _ = export("?from=yesterday")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/surveys/999/responses/export.ndjson", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestExportResponsesWithPassword(t *testing.T) {
+	setupTestDB()
+	defer testDB.Close()
+	surveyID, ids := insertTestResponses(t, 3)
+	router := setupTestRouter()
+
+	export := func(password string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", fmt.Sprintf("/api/surveys/%d/responses/export.ndjson", surveyID), nil)
+		req.Header.Set(exportPasswordHeader, password)
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := export("short")
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Contains(t, w.Body.String(), "at least 8 characters")
+
+	w = export("correct horse")
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, "application/zip", w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Header().Get("Content-Disposition"), fmt.Sprintf(`filename="survey-%d-responses.zip"`, surveyID))
+	archive, err := zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+	if !assert.NoError(t, err) || !assert.Len(t, archive.File, 1) {
+		return
+	}
+	assert.Equal(t, fmt.Sprintf("survey-%d-responses.ndjson", surveyID), archive.File[0].Name)
+	content, err := openAESZipFile(archive.File[0], "correct horse")
+	assert.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	if assert.Len(t, lines, len(ids)) {
+		var response SurveyResponse
+		assert.NoError(t, json.Unmarshal([]byte(lines[0]), &response))
+		assert.Equal(t, ids[0], response.ID)
+	}
+}
+
+func TestExportQueue(t *testing.T) {
+	setupTestDB()
+	defer testDB.Close()
+	router := setupTestRouter()
+	result, err := testDB.Exec("INSERT INTO surveys (title, description) VALUES ('Exported', 'd')")
+	assert.NoError(t, err)
+	surveyID, _ := result.LastInsertId()
+
+	defer func(conns int, timeout time.Duration) {
+		dbExportConns, dbExportQueueTimeout, exportDB = conns, timeout, nil
+	}(dbExportConns, dbExportQueueTimeout)
+	dbExportConns, dbExportQueueTimeout = 1, 50*time.Millisecond
+	exportDB, err = openExportDatabase(filepath.Join(t.TempDir(), "survey_form.db"))
+	assert.NoError(t, err)
+	defer exportDB.Close()
+	assert.NoError(t, migrate(exportDB))
+
+	// While the only export connection is busy, exports queue and then give up
+	busy, err := exportConn(context.Background(), 0)
+	assert.NoError(t, err)
+	url := fmt.Sprintf("/api/surveys/%d/responses/export.ndjson", surveyID)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", url, nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Contains(t, w.Body.String(), CodeExportsBusy)
+	assert.NotEmpty(t, w.Header().Get("Retry-After"))
+
+	// Other requests still get connections of their own pool
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", fmt.Sprintf("/api/surveys/%d/responses", surveyID), nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	busy.Close()
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", url, nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+}
+
+func TestExportQueuePerOrganization(t *testing.T) {
+	setupTestDB()
+	defer testDB.Close()
+	router := setupTestRouter()
+	testDB.Exec("INSERT INTO organizations (name) VALUES ('Acme'), ('Globex')")
+	testDB.Exec("INSERT INTO surveys (title, description, org_id) VALUES ('Acme export', 'd', 1), ('Globex export', 'd', 2)")
+
+	defer func(timeout time.Duration) {
+		dbExportQueueTimeout, exportDB = timeout, nil
+	}(dbExportQueueTimeout)
+	dbExportQueueTimeout = 50 * time.Millisecond
+	var err error
+	exportDB, err = openExportDatabase(filepath.Join(t.TempDir(), "survey_form.db"))
+	assert.NoError(t, err)
+	defer exportDB.Close()
+	assert.NoError(t, migrate(exportDB))
+
+	export := func(org string, surveyID int) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", fmt.Sprintf("/api/surveys/%d/responses/export.ndjson", surveyID), nil)
+		req.Header.Set(organizationHeader, org)
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// Acme uses all of its export slots, not the whole pool: its next export
+	// queues behind them, while Globex's goes ahead
+	var busy []*exportConnection
+	for i := 0; i < dbExportConnsPerOrg; i++ {
+		conn, err := exportConn(context.Background(), 1)
+		assert.NoError(t, err)
+		busy = append(busy, conn)
+	}
+	assert.Less(t, dbExportConnsPerOrg, dbExportConns)
+	w := export("1", 1)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Contains(t, w.Body.String(), CodeExportsBusy)
+	w = export("2", 2)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	for _, conn := range busy {
+		conn.Close()
+	}
+	w = export("1", 1)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+}
+
+func TestExportOutlastsWriteTimeout(t *testing.T) {
+	setupTestDB()
+	defer testDB.Close()
+
+	surveyID, ids := insertTestResponses(t, exportFlushRows+5)
+	router := setupTestRouter()
+
+	// Requests reach the router after the server's write deadline has passed,
+	// as a long export would stream past it
+	const writeTimeout = 100 * time.Millisecond
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(2 * writeTimeout)
+		router.ServeHTTP(w, r)
+	}))
+	server.Config.WriteTimeout = writeTimeout
+	server.Start()
+	defer server.Close()
+
+	// Other responses are cut off
+	if res, err := http.Get(fmt.Sprintf("%s/api/surveys/%d", server.URL, surveyID)); err == nil {
+		res.Body.Close()
+		t.Fatal("expected the write timeout to cut off a regular response")
+	}
+
+	res, err := http.Get(fmt.Sprintf("%s/api/surveys/%d/responses/export.ndjson", server.URL, surveyID))
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer res.Body.Close()
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	lines := 0
+	scanner := bufio.NewScanner(res.Body)
+	for scanner.Scan() {
+		lines++
+	}
+	assert.NoError(t, scanner.Err())
+	assert.Equal(t, len(ids), lines)
+}
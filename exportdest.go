@@ -0,0 +1,504 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// exportUploadClient uploads exports to S3
+var exportUploadClient = &http.Client{Timeout: 10 * time.Minute}
+
+// S3Destination is an S3 bucket, or a bucket of an S3-compatible store at
+// Endpoint, that exports are put in under Prefix. SecretAccessKey is write-only.
+type S3Destination struct {
+	Bucket          string `json:"bucket"`
+	Region          string `json:"region"`
+	Prefix          string `json:"prefix,omitempty"`
+	Endpoint        string `json:"endpoint,omitempty"`
+	AccessKeyID     string `json:"access_key_id"`
+	SecretAccessKey string `json:"secret_access_key,omitempty"`
+}
+
+// SFTPDestination is a directory on an SFTP server that exports are written
+// to. HostKey pins the server's public key, in authorized_keys format.
+// Password and PrivateKey are write-only.
+type SFTPDestination struct {
+	Host       string `json:"host"`
+	User       string `json:"user"`
+	Path       string `json:"path,omitempty"`
+	HostKey    string `json:"host_key"`
+	Password   string `json:"password,omitempty"`
+	PrivateKey string `json:"private_key,omitempty"`
+}
+
+// validate returns the problems with an S3 destination
+func (d S3Destination) validate() []string {
+	var errors []string
+	if d.Bucket == "" {
+		errors = append(errors, "S3 bucket is required")
+	}
+	if d.Region == "" {
+		errors = append(errors, "S3 region is required")
+	}
+	if d.AccessKeyID == "" || d.SecretAccessKey == "" {
+		errors = append(errors, "S3 access key ID and secret access key are required")
+	}
+	if d.Endpoint != "" {
+		if u, err := url.Parse(d.Endpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errors = append(errors, "S3 endpoint must be an http or https URL")
+		}
+	}
+	return errors
+}
+
+// validate returns the problems with an SFTP destination
+func (d SFTPDestination) validate() []string {
+	var errors []string
+	if d.Host == "" {
+		errors = append(errors, "SFTP host is required")
+	}
+	if d.User == "" {
+		errors = append(errors, "SFTP user is required")
+	}
+	if _, _, _, _, err := ssh.ParseAuthorizedKey([]byte(d.HostKey)); err != nil {
+		errors = append(errors, "SFTP host key must be a public key in authorized_keys format")
+	}
+	if d.Password == "" && d.PrivateKey == "" {
+		errors = append(errors, "SFTP password or private key is required")
+	}
+	if d.PrivateKey != "" {
+		if _, err := ssh.ParsePrivateKey([]byte(d.PrivateKey)); err != nil {
+			errors = append(errors, "SFTP private key must be an unencrypted PEM or OpenSSH key")
+		}
+	}
+	return errors
+}
+
+// redacted returns the destination without its secret
+func (d S3Destination) redacted() *S3Destination {
+	d.SecretAccessKey = ""
+	return &d
+}
+
+// redacted returns the destination without its credentials
+func (d SFTPDestination) redacted() *SFTPDestination {
+	d.Password, d.PrivateKey = "", ""
+	return &d
+}
+
+// put uploads size bytes from body as the object named name under the
+// destination's prefix, returning the object's key. sum is the hex SHA-256 of
+// the body, which SigV4 signs.
+func (d S3Destination) put(ctx context.Context, name string, body io.Reader, size int64, sum string) (string, error) {
+	key := d.Prefix + name
+	endpoint := fmt.Sprintf("https://%s.s3.%s.amazonaws.com/%s", d.Bucket, d.Region, s3Escape(key))
+	if d.Endpoint != "" {
+		// S3-compatible stores are addressed path-style
+		endpoint = fmt.Sprintf("%s/%s/%s", strings.TrimRight(d.Endpoint, "/"), s3Escape(d.Bucket), s3Escape(key))
+	}
+	if size == 0 {
+		// An empty body would otherwise be sent chunked, which S3 rejects
+		body = http.NoBody
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, endpoint, body)
+	if err != nil {
+		return "", err
+	}
+	req.ContentLength = size
+	req.Header.Set("x-amz-content-sha256", sum)
+	signS3Request(req, d.AccessKeyID, d.SecretAccessKey, d.Region, time.Now())
+	resp, err := exportUploadClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return "", fmt.Errorf("S3 put failed with status %d %s", resp.StatusCode, strings.TrimSpace(string(detail)))
+	}
+	return key, nil
+}
+
+// s3Escape percent-encodes an object key as SigV4 canonical URIs need,
+// keeping its slashes
+func s3Escape(key string) string {
+	var b strings.Builder
+	for _, c := range []byte(key) {
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || strings.IndexByte("-_.~/", c) >= 0 {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// signS3Request signs req with AWS Signature Version 4, covering the host and
+// every header set on req. The x-amz-content-sha256 header must already hold
+// the hex SHA-256 of the body.
+func signS3Request(req *http.Request, accessKeyID, secretAccessKey, region string, now time.Time) {
+	stamp := now.UTC().Format("20060102T150405Z")
+	day := stamp[:8]
+	req.Header.Set("x-amz-date", stamp)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	query := req.URL.Query()
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var params []string
+	for _, key := range keys {
+		for _, value := range query[key] {
+			params = append(params, s3Escape(key)+"="+strings.ReplaceAll(s3Escape(value), "/", "%2F"))
+		}
+	}
+
+	canonicalRequest := strings.Join([]string{
+		req.Method, req.URL.EscapedPath(), strings.Join(params, "&"),
+		canonicalHeaders.String(), signedHeaders, req.Header.Get("x-amz-content-sha256"),
+	}, "\n")
+	scope := day + "/" + region + "/s3/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + stamp + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := []byte("AWS4" + secretAccessKey)
+	for _, part := range []string{day, region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		accessKeyID, scope, signedHeaders, signature))
+}
+
+// hmacSHA256 returns the HMAC-SHA256 of data under key
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// put writes body to the file named name in the destination's directory,
+// returning the file's path. The file is written under a .part name and
+// renamed when complete, so readers never pick up a partial export.
+func (d SFTPDestination) put(ctx context.Context, name string, body io.Reader) (string, error) {
+	hostKey, _, _, _, err := ssh.ParseAuthorizedKey([]byte(d.HostKey))
+	if err != nil {
+		return "", err
+	}
+	var auth []ssh.AuthMethod
+	if d.PrivateKey != "" {
+		signer, err := ssh.ParsePrivateKey([]byte(d.PrivateKey))
+		if err != nil {
+			return "", err
+		}
+		auth = append(auth, ssh.PublicKeys(signer))
+	}
+	if d.Password != "" {
+		auth = append(auth, ssh.Password(d.Password))
+	}
+	addr := d.Host
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, "22")
+	}
+
+	dialer := net.Dialer{Timeout: 30 * time.Second}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return "", err
+	}
+	// Closing the connection unblocks the upload when ctx is cancelled
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+	sshConn, chans, reqs, err := ssh.NewClientConn(conn, addr, &ssh.ClientConfig{
+		User:            d.User,
+		Auth:            auth,
+		HostKeyCallback: ssh.FixedHostKey(hostKey),
+		Timeout:         30 * time.Second,
+	})
+	if err != nil {
+		conn.Close()
+		return "", err
+	}
+	client := ssh.NewClient(sshConn, chans, reqs)
+	defer client.Close()
+
+	session, err := client.NewSession()
+	if err != nil {
+		return "", err
+	}
+	defer session.Close()
+	w, err := session.StdinPipe()
+	if err != nil {
+		return "", err
+	}
+	r, err := session.StdoutPipe()
+	if err != nil {
+		return "", err
+	}
+	if err := session.RequestSubsystem("sftp"); err != nil {
+		return "", err
+	}
+
+	dir := d.Path
+	if dir == "" {
+		dir = "."
+	}
+	target := path.Join(dir, name)
+	s := &sftpConn{r: r, w: w}
+	if err := s.upload(target, body); err != nil {
+		return "", err
+	}
+	return target, nil
+}
+
+// SFTP version 3 packet types and flags used by sftpConn
+const (
+	sftpInit     = 1
+	sftpVersion  = 2
+	sftpOpen     = 3
+	sftpClose    = 4
+	sftpWrite    = 6
+	sftpRemove   = 13
+	sftpRename   = 18
+	sftpStatus   = 101
+	sftpHandle   = 102
+	sftpExtended = 200
+
+	sftpOpenWrite    = 0x02
+	sftpOpenCreate   = 0x08
+	sftpOpenTruncate = 0x10
+
+	sftpStatusOK = 0
+	// sftpPosixRename is OpenSSH's extension for a rename that atomically
+	// replaces an existing file
+	sftpPosixRename = "posix-rename@openssh.com"
+	// sftpChunkSize stays under the 32 KiB packets every server accepts
+	sftpChunkSize = 32000
+)
+
+// sftpConn speaks just enough SFTP version 3 to write a file, one request at
+// a time. It is hand-rolled, like signS3Request, to keep exports to the
+// golang.org/x/crypto dependency the server already has rather than pulling
+// in pkg/sftp and the AWS SDK for one upload each.
+type sftpConn struct {
+	r      io.Reader
+	w      io.Writer
+	nextID uint32
+}
+
+// sftpPacket builds a packet payload
+type sftpPacket []byte
+
+func (p sftpPacket) uint32(v uint32) sftpPacket { return binary.BigEndian.AppendUint32(p, v) }
+func (p sftpPacket) uint64(v uint64) sftpPacket { return binary.BigEndian.AppendUint64(p, v) }
+func (p sftpPacket) string(s []byte) sftpPacket { return append(p.uint32(uint32(len(s))), s...) }
+
+// send writes a packet of type kind
+func (s *sftpConn) send(kind byte, payload sftpPacket) error {
+	packet := binary.BigEndian.AppendUint32(nil, uint32(len(payload)+1))
+	packet = append(packet, kind)
+	_, err := s.w.Write(append(packet, payload...))
+	return err
+}
+
+// receive reads the next packet, returning its type and payload
+func (s *sftpConn) receive() (byte, []byte, error) {
+	var header [5]byte
+	if _, err := io.ReadFull(s.r, header[:]); err != nil {
+		return 0, nil, err
+	}
+	length := binary.BigEndian.Uint32(header[:4])
+	if length < 1 || length > 256*1024 {
+		return 0, nil, fmt.Errorf("sftp: packet of %d bytes", length)
+	}
+	payload := make([]byte, length-1)
+	_, err := io.ReadFull(s.r, payload)
+	return header[4], payload, err
+}
+
+// request sends a request with a new ID and returns the type and payload of
+// the reply to it, after the ID
+func (s *sftpConn) request(kind byte, payload sftpPacket) (byte, []byte, error) {
+	s.nextID++
+	id := s.nextID
+	if err := s.send(kind, append(sftpPacket(nil).uint32(id), payload...)); err != nil {
+		return 0, nil, err
+	}
+	replyKind, reply, err := s.receive()
+	if err != nil {
+		return 0, nil, err
+	}
+	if len(reply) < 4 || binary.BigEndian.Uint32(reply) != id {
+		return 0, nil, fmt.Errorf("sftp: unexpected reply to request %d", id)
+	}
+	return replyKind, reply[4:], nil
+}
+
+// sftpString reads a string from the front of b, returning it and the rest
+func sftpString(b []byte) ([]byte, []byte, error) {
+	if len(b) < 4 || uint32(len(b)-4) < binary.BigEndian.Uint32(b) {
+		return nil, nil, fmt.Errorf("sftp: truncated string")
+	}
+	n := binary.BigEndian.Uint32(b)
+	return b[4 : 4+n], b[4+n:], nil
+}
+
+// sftpStatusError checks that a reply is an OK status, returning the server's error otherwise
+func sftpStatusError(kind byte, reply []byte) error {
+	if kind != sftpStatus || len(reply) < 4 {
+		return fmt.Errorf("sftp: unexpected reply of type %d", kind)
+	}
+	if code := binary.BigEndian.Uint32(reply); code != sftpStatusOK {
+		message, _, _ := sftpString(reply[4:])
+		return fmt.Errorf("sftp: status %d %s", code, message)
+	}
+	return nil
+}
+
+// status sends a request whose reply is a status, returning the server's
+// error if it isn't OK
+func (s *sftpConn) status(kind byte, payload sftpPacket) error {
+	replyKind, reply, err := s.request(kind, payload)
+	if err != nil {
+		return err
+	}
+	return sftpStatusError(replyKind, reply)
+}
+
+// init starts the session, returning the extensions the server supports
+func (s *sftpConn) init() (map[string]bool, error) {
+	if err := s.send(sftpInit, sftpPacket(nil).uint32(3)); err != nil {
+		return nil, err
+	}
+	kind, reply, err := s.receive()
+	if err != nil {
+		return nil, err
+	}
+	if kind != sftpVersion || len(reply) < 4 {
+		return nil, fmt.Errorf("sftp: unexpected reply of type %d to init", kind)
+	}
+	extensions := map[string]bool{}
+	for rest := reply[4:]; len(rest) > 0; {
+		var name []byte
+		if name, rest, err = sftpString(rest); err != nil {
+			return nil, err
+		}
+		if _, rest, err = sftpString(rest); err != nil {
+			return nil, err
+		}
+		extensions[string(name)] = true
+	}
+	return extensions, nil
+}
+
+// upload writes body to the file at target through a .part file, which is
+// removed again if the upload fails
+func (s *sftpConn) upload(target string, body io.Reader) error {
+	extensions, err := s.init()
+	if err != nil {
+		return err
+	}
+	part := target + ".part"
+	if err := s.write(part, body); err != nil {
+		s.request(sftpRemove, sftpPacket(nil).string([]byte(part)))
+		return err
+	}
+	if err := s.replace(part, target, extensions[sftpPosixRename]); err != nil {
+		s.request(sftpRemove, sftpPacket(nil).string([]byte(part)))
+		return err
+	}
+	return nil
+}
+
+// write writes body to a new file, or over the file, at name
+func (s *sftpConn) write(name string, body io.Reader) error {
+	kind, reply, err := s.request(sftpOpen, sftpPacket(nil).string([]byte(name)).
+		uint32(sftpOpenWrite|sftpOpenCreate|sftpOpenTruncate).uint32(0))
+	if err != nil {
+		return err
+	}
+	if kind != sftpHandle {
+		return sftpStatusError(kind, reply)
+	}
+	handle, _, err := sftpString(reply)
+	if err != nil {
+		return err
+	}
+
+	var offset uint64
+	chunk := make([]byte, sftpChunkSize)
+	for {
+		n, readErr := io.ReadFull(body, chunk)
+		if n > 0 {
+			if err := s.status(sftpWrite, sftpPacket(nil).string(handle).uint64(offset).string(chunk[:n])); err != nil {
+				s.request(sftpClose, sftpPacket(nil).string(handle))
+				return err
+			}
+			offset += uint64(n)
+		}
+		if readErr == io.EOF || readErr == io.ErrUnexpectedEOF {
+			break
+		}
+		if readErr != nil {
+			s.request(sftpClose, sftpPacket(nil).string(handle))
+			return readErr
+		}
+	}
+	return s.status(sftpClose, sftpPacket(nil).string(handle))
+}
+
+// replace renames part to target, replacing any file already there without
+// readers ever finding target missing when the server supports posix-rename.
+// Version 3 renames fail when the target exists, so otherwise the older file
+// is moved aside first, put back if part can't take its place, and only
+// removed once part has.
+func (s *sftpConn) replace(part, target string, posixRename bool) error {
+	if posixRename {
+		return s.status(sftpExtended, sftpPacket(nil).string([]byte(sftpPosixRename)).
+			string([]byte(part)).string([]byte(target)))
+	}
+	backup := target + ".old"
+	s.request(sftpRemove, sftpPacket(nil).string([]byte(backup)))
+	// Fails when there's no older file, which leaves nothing to move aside
+	movedAside := s.status(sftpRename, sftpPacket(nil).string([]byte(target)).string([]byte(backup))) == nil
+	if err := s.status(sftpRename, sftpPacket(nil).string([]byte(part)).string([]byte(target))); err != nil {
+		if movedAside {
+			s.request(sftpRename, sftpPacket(nil).string([]byte(backup)).string([]byte(target)))
+		}
+		return err
+	}
+	if movedAside {
+		s.request(sftpRemove, sftpPacket(nil).string([]byte(backup)))
+	}
+	return nil
+}
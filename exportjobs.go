@@ -0,0 +1,491 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Export job formats
+const (
+	ExportFormatCSV    = "csv"
+	ExportFormatNDJSON = "ndjson"
+)
+
+// Export job destinations
+const (
+	ExportDestinationS3   = "s3"
+	ExportDestinationSFTP = "sftp"
+)
+
+// Export run statuses; a job that has not run yet is pending
+const (
+	ExportStatusPending   = "pending"
+	ExportStatusRunning   = "running"
+	ExportStatusSucceeded = "succeeded"
+	ExportStatusFailed    = "failed"
+)
+
+// Bounds of an export job's interval
+const (
+	minExportIntervalMinutes = 15
+	maxExportIntervalMinutes = 31 * 24 * 60
+)
+
+// ExportJob periodically exports all of a survey's responses to an S3 bucket
+// or SFTP server. Destination credentials are write-only and stored
+// encrypted when RESPONSE_DATA_KEY is set.
+type ExportJob struct {
+	ID              int              `json:"id"`
+	SurveyID        int              `json:"survey_id"`
+	Format          string           `json:"format"`
+	Destination     string           `json:"destination"`
+	S3              *S3Destination   `json:"s3,omitempty"`
+	SFTP            *SFTPDestination `json:"sftp,omitempty"`
+	IntervalMinutes int              `json:"interval_minutes"`
+	NextRunAt       time.Time        `json:"next_run_at"`
+	// Status is the status of the last run, pending before the first
+	Status    string     `json:"status"`
+	LastRun   *ExportRun `json:"last_run"`
+	CreatedAt time.Time  `json:"created_at"`
+
+	destinations exportDestinations
+}
+
+// exportDestinations is the stored, unredacted destination of a job
+type exportDestinations struct {
+	S3   *S3Destination   `json:"s3,omitempty"`
+	SFTP *SFTPDestination `json:"sftp,omitempty"`
+}
+
+// ExportRun reports one run of an export job
+type ExportRun struct {
+	Status     string     `json:"status"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at"`
+	Rows       int        `json:"rows"`
+	Bytes      int64      `json:"bytes"`
+	// Location is the S3 key or SFTP path the export was written to
+	Location string `json:"location,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// CreateExportJobRequest represents the request body for scheduling an export
+type CreateExportJobRequest struct {
+	ExportJob struct {
+		Format          string           `json:"format" binding:"required"`
+		IntervalMinutes int              `json:"interval_minutes" binding:"required"`
+		S3              *S3Destination   `json:"s3"`
+		SFTP            *SFTPDestination `json:"sftp"`
+	} `json:"export_job" binding:"required"`
+}
+
+// exportJobColumns lists the columns read by scanExportJob
+const exportJobColumns = "id, survey_id, format, destination, config, interval_minutes, next_run_at, " +
+	"last_run_status, last_run_started_at, last_run_finished_at, last_run_rows, last_run_bytes, last_run_location, last_run_error, created_at"
+
+// scanExportJob scans a row selected with exportJobColumns, decrypting its destination
+func scanExportJob(row rowScanner) (ExportJob, error) {
+	var job ExportJob
+	var config []byte
+	var status, location, runError sql.NullString
+	var startedAt, finishedAt sql.NullTime
+	var rows, size sql.NullInt64
+	err := row.Scan(&job.ID, &job.SurveyID, &job.Format, &job.Destination, &config, &job.IntervalMinutes, &job.NextRunAt,
+		&status, &startedAt, &finishedAt, &rows, &size, &location, &runError, &job.CreatedAt)
+	if err != nil {
+		return job, err
+	}
+	if config, err = decryptResponseData(config); err != nil {
+		return job, err
+	}
+	if err := json.Unmarshal(config, &job.destinations); err != nil {
+		return job, err
+	}
+	if d := job.destinations.S3; d != nil {
+		job.S3 = d.redacted()
+	}
+	if d := job.destinations.SFTP; d != nil {
+		job.SFTP = d.redacted()
+	}
+	job.Status = ExportStatusPending
+	if status.Valid {
+		job.Status = status.String
+		job.LastRun = &ExportRun{
+			Status:     status.String,
+			StartedAt:  startedAt.Time,
+			FinishedAt: nullTimePtr(finishedAt),
+			Rows:       int(rows.Int64),
+			Bytes:      size.Int64,
+			Location:   location.String,
+			Error:      runError.String,
+		}
+	}
+	return job, nil
+}
+
+// findExportJob loads an export job of a survey
+func findExportJob(ctx context.Context, surveyID, jobID int) (ExportJob, error) {
+	return scanExportJob(db.QueryRowContext(ctx, "SELECT "+exportJobColumns+" FROM export_jobs WHERE id = ? AND survey_id = ?", jobID, surveyID))
+}
+
+// csvRecord formats a sheetRow as CSV fields
+func csvRecord(row []interface{}) []string {
+	record := make([]string, len(row))
+	for i, value := range row {
+		switch v := value.(type) {
+		case string:
+			record[i] = v
+		case float64:
+			record[i] = strconv.FormatFloat(v, 'f', -1, 64)
+		default:
+			record[i] = fmt.Sprint(v)
+		}
+	}
+	return record
+}
+
+// writeExport writes every response of the job's survey to w in the job's
+// format, oldest first, returning how many
+func writeExport(ctx context.Context, job ExportJob, w io.Writer) (int, error) {
+	survey, err := findSurvey(ctx, job.SurveyID)
+	if err != nil {
+		return 0, err
+	}
+	rows, err := db.QueryContext(ctx, `
+		SELECT `+responseColumns+` FROM `+responsesFrom+`
+		WHERE sr.survey_id = ? AND sr.deleted_at IS NULL
+		ORDER BY sr.id
+	`, job.SurveyID)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	var csvWriter *csv.Writer
+	enc := json.NewEncoder(w)
+	if job.Format == ExportFormatCSV {
+		csvWriter = csv.NewWriter(w)
+		csvWriter.Write(csvRecord(sheetHeader(survey.Questions)))
+	}
+	n := 0
+	for rows.Next() {
+		response, err := scanResponse(rows)
+		if err != nil {
+			return n, err
+		}
+		if csvWriter != nil {
+			err = csvWriter.Write(csvRecord(sheetRow(survey.Questions, response)))
+		} else {
+			err = enc.Encode(response)
+		}
+		if err != nil {
+			return n, err
+		}
+		n++
+	}
+	if err := rows.Err(); err != nil {
+		return n, err
+	}
+	if csvWriter != nil {
+		csvWriter.Flush()
+		return n, csvWriter.Error()
+	}
+	return n, nil
+}
+
+// runExportJob exports the job's survey to a temporary file and uploads it,
+// recording the run on the job
+func runExportJob(ctx context.Context, job ExportJob) ExportRun {
+	run := ExportRun{Status: ExportStatusRunning, StartedAt: time.Now().UTC().Truncate(time.Second)}
+	db.ExecContext(ctx, `
+		UPDATE export_jobs SET last_run_status = ?, last_run_started_at = ?, last_run_finished_at = NULL,
+			last_run_rows = NULL, last_run_bytes = NULL, last_run_location = NULL, last_run_error = NULL
+		WHERE id = ?
+	`, run.Status, run.StartedAt, job.ID)
+
+	err := func() error {
+		f, err := os.CreateTemp("", "survey-export-*")
+		if err != nil {
+			return err
+		}
+		defer os.Remove(f.Name())
+		defer f.Close()
+
+		hash := sha256.New()
+		if run.Rows, err = writeExport(ctx, job, io.MultiWriter(f, hash)); err != nil {
+			return err
+		}
+		if run.Bytes, err = f.Seek(0, io.SeekCurrent); err != nil {
+			return err
+		}
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+
+		name := fmt.Sprintf("survey-%d-responses-%s.%s", job.SurveyID, run.StartedAt.Format("20060102T150405Z"), job.Format)
+		if d := job.destinations.S3; d != nil {
+			run.Location, err = d.put(ctx, name, f, run.Bytes, hex.EncodeToString(hash.Sum(nil)))
+		} else if d := job.destinations.SFTP; d != nil {
+			run.Location, err = d.put(ctx, name, f)
+		} else {
+			err = fmt.Errorf("export job %d has no destination", job.ID)
+		}
+		return err
+	}()
+
+	finishedAt := time.Now().UTC()
+	run.FinishedAt = &finishedAt
+	run.Status = ExportStatusSucceeded
+	if err != nil {
+		run.Status, run.Error, run.Location = ExportStatusFailed, err.Error(), ""
+		log.Printf("Exports: job %d of survey %d failed: %v", job.ID, job.SurveyID, err)
+	}
+	_, err = db.ExecContext(ctx, `
+		UPDATE export_jobs SET last_run_status = ?, last_run_finished_at = ?, last_run_rows = ?,
+			last_run_bytes = ?, last_run_location = ?, last_run_error = ?
+		WHERE id = ?
+	`, run.Status, finishedAt, run.Rows, run.Bytes, run.Location, run.Error, job.ID)
+	if err != nil {
+		log.Printf("Exports: failed to record run of job %d: %v", job.ID, err)
+	}
+	return run
+}
+
+// runDueExportJobs runs every export job whose next run is due, returning
+// how many ran. A job is claimed by moving its next run on by its interval
+// before it runs, so a failed run is retried at the next interval.
+func runDueExportJobs(ctx context.Context, now time.Time) (int, error) {
+	rows, err := db.QueryContext(ctx, "SELECT "+exportJobColumns+" FROM export_jobs WHERE next_run_at <= ?", now.UTC())
+	if err != nil {
+		return 0, err
+	}
+	var jobs []ExportJob
+	for rows.Next() {
+		job, err := scanExportJob(rows)
+		if err != nil {
+			rows.Close()
+			return 0, err
+		}
+		jobs = append(jobs, job)
+	}
+	rows.Close()
+
+	ran := 0
+	for _, job := range jobs {
+		next := now.UTC().Add(time.Duration(job.IntervalMinutes) * time.Minute)
+		result, err := db.ExecContext(ctx, "UPDATE export_jobs SET next_run_at = ? WHERE id = ? AND next_run_at <= ?", next, job.ID, now.UTC())
+		if err != nil {
+			return ran, err
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			continue
+		}
+		runExportJob(ctx, job)
+		ran++
+	}
+	return ran, nil
+}
+
+// exportJobParams reads the survey and job IDs of an export job route
+func exportJobParams(c *gin.Context) (int, int, bool) {
+	surveyID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		abortWithError(c, errInvalidID("survey"))
+		return 0, 0, false
+	}
+	jobID, err := strconv.Atoi(c.Param("job_id"))
+	if err != nil {
+		abortWithError(c, errInvalidID("export job"))
+		return 0, 0, false
+	}
+	return surveyID, jobID, true
+}
+
+// findExportJobOr404 loads an export job, responding 404 when there is none
+func findExportJobOr404(c *gin.Context, surveyID, jobID int) (ExportJob, bool) {
+	job, err := findExportJob(c.Request.Context(), surveyID, jobID)
+	if err == sql.ErrNoRows {
+		abortWithError(c, errNotFound(CodeExportJobNotFound, "Export job not found"))
+		return job, false
+	}
+	if err != nil {
+		abortWithError(c, errInternal("Failed to fetch export job", err))
+		return job, false
+	}
+	return job, true
+}
+
+// getExportJobs lists a survey's export jobs with their status
+func getExportJobs(c *gin.Context) {
+	surveyID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		abortWithError(c, errInvalidID("survey"))
+		return
+	}
+	rows, err := db.QueryContext(c.Request.Context(), "SELECT "+exportJobColumns+" FROM export_jobs WHERE survey_id = ? ORDER BY id", surveyID)
+	if err != nil {
+		abortWithError(c, errInternal("Failed to fetch export jobs", err))
+		return
+	}
+	defer rows.Close()
+
+	jobs := []ExportJob{}
+	for rows.Next() {
+		job, err := scanExportJob(rows)
+		if err != nil {
+			abortWithError(c, errInternal("Failed to scan export job data", err))
+			return
+		}
+		jobs = append(jobs, job)
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Status: "success",
+		Data:   jobs,
+	})
+}
+
+// createExportJob schedules a survey's responses to be exported every
+// interval, starting now
+func createExportJob(c *gin.Context) {
+	ctx := c.Request.Context()
+	surveyID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		abortWithError(c, errInvalidID("survey"))
+		return
+	}
+	var req CreateExportJobRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		abortWithError(c, errInvalidRequest(err))
+		return
+	}
+	if _, err := findSurvey(ctx, surveyID); err != nil {
+		abortWithError(c, errNotFound(CodeSurveyNotFound, "Survey not found"))
+		return
+	}
+
+	spec := req.ExportJob
+	var errors []string
+	if spec.Format != ExportFormatCSV && spec.Format != ExportFormatNDJSON {
+		errors = append(errors, "Format must be csv or ndjson")
+	}
+	if spec.IntervalMinutes < minExportIntervalMinutes || spec.IntervalMinutes > maxExportIntervalMinutes {
+		errors = append(errors, fmt.Sprintf("Interval must be between %d and %d minutes", minExportIntervalMinutes, maxExportIntervalMinutes))
+	}
+	destination := ""
+	switch {
+	case spec.S3 != nil && spec.SFTP != nil:
+		errors = append(errors, "Only one of s3 and sftp can be set")
+	case spec.S3 != nil:
+		destination = ExportDestinationS3
+		errors = append(errors, spec.S3.validate()...)
+	case spec.SFTP != nil:
+		destination = ExportDestinationSFTP
+		errors = append(errors, spec.SFTP.validate()...)
+	default:
+		errors = append(errors, "A destination, s3 or sftp, is required")
+	}
+	if len(errors) > 0 {
+		abortWithError(c, errValidation("Failed to create export job", errors))
+		return
+	}
+
+	config, err := json.Marshal(exportDestinations{S3: spec.S3, SFTP: spec.SFTP})
+	if err != nil {
+		abortWithError(c, errInternal("Failed to create export job", err))
+		return
+	}
+	result, err := db.ExecContext(ctx, `
+		INSERT INTO export_jobs (survey_id, format, destination, config, interval_minutes, next_run_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, surveyID, spec.Format, destination, encryptResponseData(config), spec.IntervalMinutes, time.Now().UTC())
+	if err != nil {
+		abortWithError(c, errInternal("Failed to create export job", err))
+		return
+	}
+	id, _ := result.LastInsertId()
+	job, ok := findExportJobOr404(c, surveyID, int(id))
+	if !ok {
+		return
+	}
+	auditChange(c, "create", "export_job", job.ID, nil, job)
+
+	c.JSON(http.StatusCreated, APIResponse{
+		Status:  "success",
+		Message: "Export job created successfully",
+		Data:    job,
+	})
+}
+
+// getExportJob returns an export job with its status and next run
+func getExportJob(c *gin.Context) {
+	surveyID, jobID, ok := exportJobParams(c)
+	if !ok {
+		return
+	}
+	job, ok := findExportJobOr404(c, surveyID, jobID)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Status: "success",
+		Data:   job,
+	})
+}
+
+// getExportJobLastRun returns the last run of an export job
+func getExportJobLastRun(c *gin.Context) {
+	surveyID, jobID, ok := exportJobParams(c)
+	if !ok {
+		return
+	}
+	job, ok := findExportJobOr404(c, surveyID, jobID)
+	if !ok {
+		return
+	}
+	if job.LastRun == nil {
+		abortWithError(c, errNotFound(CodeExportRunNotFound, "Export job has not run yet"))
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Status: "success",
+		Data:   job.LastRun,
+	})
+}
+
+// deleteExportJob stops a scheduled export. Files already exported are kept.
+func deleteExportJob(c *gin.Context) {
+	surveyID, jobID, ok := exportJobParams(c)
+	if !ok {
+		return
+	}
+	job, ok := findExportJobOr404(c, surveyID, jobID)
+	if !ok {
+		return
+	}
+	if _, err := db.ExecContext(c.Request.Context(), "DELETE FROM export_jobs WHERE id = ?", jobID); err != nil {
+		abortWithError(c, errInternal("Failed to delete export job", err))
+		return
+	}
+	auditChange(c, "delete", "export_job", jobID, job, nil)
+
+	c.JSON(http.StatusOK, APIResponse{
+		Status:  "success",
+		Message: "Export job deleted successfully",
+	})
+}
@@ -0,0 +1,303 @@
+package main
+
+import (
+	"database/sql"
+	"html/template"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// formImageURL is the absolute URL of the image link previews of hosted forms
+// show, e.g. the deployment's logo; previews have no image when it's empty
+var formImageURL = envString("FORM_IMAGE_URL", "")
+
+// formView is what the HTML form of a shared survey renders
+type formView struct {
+	// Lang is the language of a translation, if one is shown
+	Lang        string
+	Title       string
+	Description string
+	SurveyID    int
+	// Anonymous surveys don't ask who is answering
+	Anonymous bool
+	// InvitationToken is submitted with the answers of an invitation's form,
+	// which doesn't ask who is answering either
+	InvitationToken string
+	// Channel is recorded in the metadata of the responses submitted
+	Channel string
+	// Notice replaces the form when the survey can't be answered
+	Notice string
+	Pages  []formPage
+	// CanonicalURL is the form's URL on the custom domain of the survey's
+	// organization, or on PUBLIC_URL
+	CanonicalURL string
+	// NoIndex keeps search engines from indexing the page
+	NoIndex bool
+	// ImageURL is shown in link previews of the page
+	ImageURL string
+}
+
+// formPage is a group of questions shown under an optional title
+type formPage struct {
+	Title     string
+	Questions []formQuestion
+}
+
+// formQuestion is a question as rendered; Scale holds the points of rating questions
+type formQuestion struct {
+	ID       string
+	Type     string
+	Label    string
+	Required bool
+	Options  []formOption
+	Min, Max *float64
+	Scale    []int
+}
+
+// formOption is a choice: Value is submitted, Label shown
+type formOption struct {
+	Value string
+	Label string
+}
+
+// newFormView lays out a survey for its form, in the translation of lang when
+// the survey has one. Logic rules aren't applied: every question is shown.
+func newFormView(survey Survey, lang string, now time.Time) formView {
+	translation, translated := survey.Translations[lang]
+	if !translated {
+		lang = ""
+	}
+	view := formView{Lang: lang, Title: survey.Title, Description: survey.Description, SurveyID: survey.ID, Anonymous: survey.Anonymous, ImageURL: formImageURL}
+	if translated {
+		view.Title, view.Description = translation.Title, translation.Description
+	}
+
+	switch {
+	case survey.Embargoed():
+		view.Notice = "This survey is coming soon."
+		return view
+	case survey.Full():
+		view.Notice = "This survey is no longer accepting responses."
+		return view
+	}
+	if reason := survey.responseWindowError(now); reason != "" {
+		view.Notice = reason + "."
+		return view
+	}
+
+	// Questions without a known page come first, untitled
+	pageIndex := map[string]int{"": 0}
+	view.Pages = []formPage{{}}
+	for _, p := range survey.Pages {
+		title := p.Title
+		if t, ok := translation.Pages[p.ID]; ok && translated {
+			title = t
+		}
+		pageIndex[p.ID] = len(view.Pages)
+		view.Pages = append(view.Pages, formPage{Title: title})
+	}
+
+	for _, q := range survey.Questions {
+		fq := formQuestion{ID: q.ID, Type: q.Type, Label: q.Label, Required: q.Required, Min: q.Min, Max: q.Max}
+		qt, ok := translation.Questions[q.ID]
+		ok = ok && translated
+		if ok && qt.Label != "" {
+			fq.Label = qt.Label
+		}
+		for i, option := range q.Options {
+			label := option
+			if ok && i < len(qt.Options) {
+				label = qt.Options[i]
+			}
+			fq.Options = append(fq.Options, formOption{Value: option, Label: label})
+		}
+		if q.Type == QuestionRating {
+			min, max := defaultRatingMin, defaultRatingMax
+			if q.Min != nil {
+				min = int(*q.Min)
+			}
+			if q.Max != nil {
+				max = int(*q.Max)
+			}
+			for point := min; point <= max; point++ {
+				fq.Scale = append(fq.Scale, point)
+			}
+		}
+
+		i, known := pageIndex[q.Page]
+		if !known {
+			i = 0
+		}
+		view.Pages[i].Questions = append(view.Pages[i].Questions, fq)
+	}
+	return view
+}
+
+// surveyForm renders a formView; its script posts the answers to the API as
+// JSON. Open Graph and Twitter card tags give shared links a rich preview.
+var surveyForm = template.Must(template.New("form").Parse(`<!DOCTYPE html>
+<html{{with .Lang}} lang="{{.}}"{{end}}>
+<head>
+  <meta charset="utf-8">
+  <meta name="viewport" content="width=device-width, initial-scale=1">
+  <title>{{.Title}}</title>
+  {{with .CanonicalURL}}<link rel="canonical" href="{{.}}">{{end}}
+  {{if .NoIndex}}<meta name="robots" content="noindex">{{end}}
+  <meta property="og:type" content="website">
+  <meta property="og:title" content="{{.Title}}">
+  {{with .Description}}<meta property="og:description" content="{{.}}">{{end}}
+  {{with .CanonicalURL}}<meta property="og:url" content="{{.}}">{{end}}
+  {{with .ImageURL}}<meta property="og:image" content="{{.}}">{{end}}
+  <meta name="twitter:card" content="{{if .ImageURL}}summary_large_image{{else}}summary{{end}}">
+  <meta name="twitter:title" content="{{.Title}}">
+  {{with .Description}}<meta name="twitter:description" content="{{.}}">{{end}}
+  {{with .ImageURL}}<meta name="twitter:image" content="{{.}}">{{end}}
+  <style>
+    body { font-family: system-ui, sans-serif; max-width: 40rem; margin: 2rem auto; padding: 0 1rem; line-height: 1.5; }
+    fieldset { border: 1px solid #ccc; border-radius: 4px; margin: 0 0 1rem; padding: 0.75rem 1rem; }
+    fieldset.page { border: none; padding: 0; }
+    label { display: block; }
+    input[type=text], input[type=number], textarea { width: 100%; box-sizing: border-box; padding: 0.4rem; }
+    .required { color: #b00; }
+    #status { margin-top: 1rem; }
+    #status.error { color: #b00; }
+  </style>
+</head>
+<body>
+  <h1>{{.Title}}</h1>
+  {{with .Description}}<p>{{.}}</p>{{end}}
+  {{if .Notice}}
+  <p>{{.Notice}}</p>
+  {{else}}
+  <form id="survey-form" data-survey="{{.SurveyID}}" data-channel="{{.Channel}}"{{with .InvitationToken}} data-invitation="{{.}}"{{end}}>
+    {{if not (or .Anonymous .InvitationToken)}}
+    <fieldset>
+      <label for="user_identifier">Your email or name <span class="required">*</span></label>
+      <input type="text" id="user_identifier" name="user_identifier" required maxlength="255">
+    </fieldset>
+    {{end}}
+    {{range .Pages}}{{if .Questions}}
+    <fieldset class="page">
+      {{with .Title}}<h2>{{.}}</h2>{{end}}
+      {{range .Questions}}
+      <fieldset data-question="{{.ID}}" data-type="{{.Type}}">
+        <legend>{{.Label}}{{if .Required}} <span class="required">*</span>{{end}}</legend>
+        {{if eq .Type "text"}}
+        <textarea name="q_{{.ID}}" rows="3"{{if .Required}} required{{end}}></textarea>
+        {{else if eq .Type "number"}}
+        <input type="number" step="any" name="q_{{.ID}}"{{with .Min}} min="{{.}}"{{end}}{{with .Max}} max="{{.}}"{{end}}{{if .Required}} required{{end}}>
+        {{else if eq .Type "boolean"}}
+        <label><input type="radio" name="q_{{.ID}}" value="true"{{if .Required}} required{{end}}> Yes</label>
+        <label><input type="radio" name="q_{{.ID}}" value="false"> No</label>
+        {{else if eq .Type "rating"}}
+        {{$q := .}}
+        {{range .Scale}}<label><input type="radio" name="q_{{$q.ID}}" value="{{.}}"{{if $q.Required}} required{{end}}> {{.}}</label>{{end}}
+        {{else if eq .Type "single_choice"}}
+        {{$q := .}}
+        {{range .Options}}<label><input type="radio" name="q_{{$q.ID}}" value="{{.Value}}"{{if $q.Required}} required{{end}}> {{.Label}}</label>{{end}}
+        {{else if eq .Type "multiple_choice"}}
+        {{$q := .}}
+        {{range .Options}}<label><input type="checkbox" name="q_{{$q.ID}}" value="{{.Value}}"> {{.Label}}</label>{{end}}
+        {{end}}
+      </fieldset>
+      {{end}}
+    </fieldset>
+    {{end}}{{end}}
+    <button type="submit">Submit</button>
+    <p id="status" role="status"></p>
+  </form>
+  <script>
+    (function () {
+      var form = document.getElementById("survey-form");
+      var status = document.getElementById("status");
+      var started = Date.now();
+      form.addEventListener("submit", function (event) {
+        event.preventDefault();
+        var data = {};
+        form.querySelectorAll("[data-question]").forEach(function (fieldset) {
+          var id = fieldset.dataset.question, type = fieldset.dataset.type;
+          var inputs = Array.prototype.slice.call(fieldset.querySelectorAll("input, textarea"));
+          if (type === "multiple_choice") {
+            var chosen = inputs.filter(function (i) { return i.checked; }).map(function (i) { return i.value; });
+            if (chosen.length) data[id] = chosen;
+            return;
+          }
+          var input = inputs.filter(function (i) { return (i.type !== "radio" && i.value.trim() !== "") || i.checked; })[0];
+          if (!input) return;
+          var value = input.value.trim();
+          if (type === "number" || type === "rating") data[id] = Number(value);
+          else if (type === "boolean") data[id] = value === "true";
+          else data[id] = value;
+        });
+        var body = {survey_response: {response_data: data, metadata: {
+          channel: form.dataset.channel,
+          completion_seconds: Math.round((Date.now() - started) / 1000)
+        }}};
+        var identifier = form.querySelector("#user_identifier");
+        if (identifier) body.survey_response.user_identifier = identifier.value.trim();
+        if (form.dataset.invitation) body.survey_response.invitation_token = form.dataset.invitation;
+
+        status.className = "";
+        status.textContent = "Submitting…";
+        fetch("/api/surveys/" + form.dataset.survey + "/responses", {
+          method: "POST",
+          headers: {"Content-Type": "application/json"},
+          body: JSON.stringify(body)
+        }).then(function (resp) {
+          return resp.json().then(function (payload) { return {ok: resp.ok, payload: payload}; });
+        }).then(function (result) {
+          if (result.ok) {
+            form.replaceWith(Object.assign(document.createElement("p"), {textContent: "Thank you, your response was recorded."}));
+            return;
+          }
+          status.className = "error";
+          status.textContent = (result.payload.errors || [result.payload.message]).join(" ");
+        }).catch(function () {
+          status.className = "error";
+          status.textContent = "Your response couldn't be sent. Please try again.";
+        });
+      });
+    })();
+  </script>
+  {{end}}
+</body>
+</html>
+`))
+
+// getSurveyForm renders the survey of a share link as an HTML form, in the
+// language of ?lang= when the survey is translated into it
+func getSurveyForm(c *gin.Context) {
+	survey, err := findSharedSurvey(c, c.Param("token"))
+	var view formView
+	status := http.StatusOK
+	switch {
+	case err == sql.ErrNoRows:
+		status = http.StatusNotFound
+		view.Title, view.Notice = "Survey not found", "This link is invalid or was revoked."
+	case err != nil:
+		log.Printf("Failed to fetch shared survey: %v", err)
+		status = http.StatusInternalServerError
+		view.Title, view.Notice = "Something went wrong", "The survey couldn't be loaded. Please try again later."
+	default:
+		view = newFormView(survey, c.Query("lang"), time.Now())
+		view.CanonicalURL = organizationBaseURL(c.Request.Context(), survey.OrganizationID) + "/s/" + c.Param("token") + "/form"
+		view.Channel = "share_link"
+	}
+	view.NoIndex = err != nil || !survey.Indexable
+	renderSurveyForm(c, status, view)
+}
+
+// renderSurveyForm responds with the HTML of a form view
+func renderSurveyForm(c *gin.Context, status int, view formView) {
+	if view.NoIndex {
+		noIndex(c)
+	}
+	c.Status(status)
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	if err := surveyForm.Execute(c.Writer, view); err != nil {
+		log.Printf("Failed to render survey form: %v", err)
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewFormView(t *testing.T) {
+	min, max := 0.0, 10.0
+	survey := Survey{
+		ID:    1,
+		Title: "Feedback",
+		Pages: []Page{{ID: "p1", Title: "About you"}},
+		Questions: []Question{
+			{ID: "nps", Type: QuestionRating, Label: "How likely?", Min: &min, Max: &max, Required: true},
+			{ID: "role", Type: QuestionSingleChoice, Label: "Role", Options: []string{"Dev", "Ops"}, Page: "p1"},
+		},
+		Translations: map[string]SurveyTranslation{"fr": {
+			Title:     "Avis",
+			Pages:     map[string]string{"p1": "À propos de vous"},
+			Questions: map[string]QuestionTranslation{"role": {Label: "Rôle", Options: []string{"Dév", "Ops"}}},
+		}},
+	}
+
+	view := newFormView(survey, "", time.Now())
+	assert.Equal(t, "", view.Lang)
+	assert.Len(t, view.Pages, 2)
+	assert.Equal(t, "nps", view.Pages[0].Questions[0].ID)
+	assert.Len(t, view.Pages[0].Questions[0].Scale, 11)
+	assert.Equal(t, "About you", view.Pages[1].Title)
+
+	// Translations change what is shown, not the values submitted
+	view = newFormView(survey, "fr", time.Now())
+	assert.Equal(t, "Avis", view.Title)
+	assert.Equal(t, "À propos de vous", view.Pages[1].Title)
+	assert.Equal(t, []formOption{{Value: "Dev", Label: "Dév"}, {Value: "Ops", Label: "Ops"}}, view.Pages[1].Questions[0].Options)
+
+	survey.Status = SurveyStatusDraft
+	view = newFormView(survey, "", time.Now())
+	assert.Equal(t, "This survey is coming soon.", view.Notice)
+	assert.Empty(t, view.Pages)
+}
+
+func TestSurveyForm(t *testing.T) {
+	setupTestDB()
+	defer testDB.Close()
+	router := setupTestRouter()
+
+	result, _ := testDB.Exec(`INSERT INTO surveys (title, description, questions) VALUES ('<script>alert(1)</script>', 'd', ?)`,
+		`[{"id":"comment","type":"text","label":"Anything else?","required":true},{"id":"ok","type":"boolean","label":"Happy?"}]`)
+	surveyID, _ := result.LastInsertId()
+	token := randomHex(16)
+	testDB.Exec("INSERT INTO survey_share_links (survey_id, prefix, token_hash) VALUES (?, ?, ?)", surveyID, token[:8], hashSurveyToken(token))
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/s/"+token+"/form", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/html; charset=utf-8", w.Header().Get("Content-Type"))
+	body := w.Body.String()
+	assert.Contains(t, body, `&lt;script&gt;alert(1)&lt;/script&gt;`)
+	assert.NotContains(t, body, `<script>alert(1)`)
+	assert.Contains(t, body, `<textarea name="q_comment" rows="3" required>`)
+	assert.Contains(t, body, `name="q_ok" value="true"`)
+	assert.Contains(t, body, `id="user_identifier"`)
+	assert.Contains(t, body, `data-survey="1"`)
+	assert.Contains(t, body, `data-channel="share_link"`)
+
+	// Shared links render a rich preview
+	assert.Contains(t, body, `<meta property="og:title" content="&lt;script&gt;alert(1)&lt;/script&gt;">`)
+	assert.Contains(t, body, `<meta property="og:description" content="d">`)
+	assert.Contains(t, body, `<meta property="og:url" content="`+publicURL+`/s/`+token+`/form">`)
+	assert.Contains(t, body, `<meta name="twitter:card" content="summary">`)
+	assert.NotContains(t, body, `og:image`)
+	defer func(url string) { formImageURL = url }(formImageURL)
+	formImageURL = "https://cdn.example.com/brand.png"
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Contains(t, w.Body.String(), `<meta property="og:image" content="https://cdn.example.com/brand.png">`)
+	assert.Contains(t, w.Body.String(), `<meta name="twitter:card" content="summary_large_image">`)
+	assert.Contains(t, w.Body.String(), `<meta name="twitter:image" content="https://cdn.example.com/brand.png">`)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/s/unknown/form", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Contains(t, w.Body.String(), "This link is invalid or was revoked.")
+	assert.NotContains(t, w.Body.String(), "<form")
+}
@@ -0,0 +1,375 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// maxFormulaLength bounds the expression of a saved formula
+const maxFormulaLength = 500
+
+// formulaFunc describes a function of the formula language
+type formulaFunc struct {
+	// question is set when the first argument is a question ID, value when a
+	// quoted answer value follows it
+	question, value bool
+	eval            func(env formulaEnv, call formulaCall) (float64, error)
+}
+
+// formulaFuncs are the functions formulas can call. Shares are fractions, 0-1.
+var formulaFuncs = map[string]formulaFunc{
+	"responses":  {eval: evalResponses},
+	"count":      {question: true, eval: evalCount},
+	"mean":       {question: true, eval: evalMean},
+	"share":      {question: true, value: true, eval: evalShare},
+	"top2box":    {question: true, eval: evalBox(true)},
+	"bottom2box": {question: true, eval: evalBox(false)},
+	"nps":        {question: true, eval: evalNPS},
+}
+
+// formulaEnv is what formulas are evaluated against
+type formulaEnv struct {
+	results   SurveyResults
+	questions map[string]Question
+}
+
+// question returns the aggregates of a question
+func (env formulaEnv) question(id string) QuestionResult {
+	for _, q := range env.results.Questions {
+		if q.ID == id {
+			return q
+		}
+	}
+	return QuestionResult{ID: id, Values: map[string]int{}}
+}
+
+// errNoAnswers is returned by functions of questions nobody answered
+var errNoAnswers = errors.New("no answers")
+
+// formulaNode is a parsed formula expression
+type formulaNode interface {
+	eval(env formulaEnv) (float64, error)
+}
+
+type formulaNumber float64
+
+func (n formulaNumber) eval(formulaEnv) (float64, error) { return float64(n), nil }
+
+type formulaNeg struct{ x formulaNode }
+
+func (n formulaNeg) eval(env formulaEnv) (float64, error) {
+	x, err := n.x.eval(env)
+	return -x, err
+}
+
+type formulaBinary struct {
+	op          byte
+	left, right formulaNode
+}
+
+func (n formulaBinary) eval(env formulaEnv) (float64, error) {
+	x, err := n.left.eval(env)
+	if err != nil {
+		return 0, err
+	}
+	y, err := n.right.eval(env)
+	if err != nil {
+		return 0, err
+	}
+	switch n.op {
+	case '+':
+		return x + y, nil
+	case '-':
+		return x - y, nil
+	case '*':
+		return x * y, nil
+	}
+	if y == 0 {
+		return 0, errors.New("division by zero")
+	}
+	return x / y, nil
+}
+
+// formulaCall calls a function on a question, and an answer value for share
+type formulaCall struct {
+	fn       string
+	question string
+	value    string
+}
+
+func (n formulaCall) eval(env formulaEnv) (float64, error) {
+	v, err := formulaFuncs[n.fn].eval(env, n)
+	if err == errNoAnswers {
+		return 0, fmt.Errorf("%s has no answers", n.question)
+	}
+	return v, err
+}
+
+// questions lists the question IDs an expression refers to
+func formulaQuestions(node formulaNode) []string {
+	switch n := node.(type) {
+	case formulaNeg:
+		return formulaQuestions(n.x)
+	case formulaBinary:
+		return append(formulaQuestions(n.left), formulaQuestions(n.right)...)
+	case formulaCall:
+		if n.question != "" {
+			return []string{n.question}
+		}
+	}
+	return nil
+}
+
+func evalResponses(env formulaEnv, call formulaCall) (float64, error) {
+	return float64(env.results.ResponsesCount), nil
+}
+
+func evalCount(env formulaEnv, call formulaCall) (float64, error) {
+	return float64(env.question(call.question).Answered), nil
+}
+
+// evalMean averages the numeric answers of a question
+func evalMean(env formulaEnv, call formulaCall) (float64, error) {
+	sum, n := 0.0, 0
+	for value, count := range env.question(call.question).Values {
+		if x, err := strconv.ParseFloat(value, 64); err == nil {
+			sum += x * float64(count)
+			n += count
+		}
+	}
+	if n == 0 {
+		return 0, errNoAnswers
+	}
+	return sum / float64(n), nil
+}
+
+func evalShare(env formulaEnv, call formulaCall) (float64, error) {
+	q := env.question(call.question)
+	if q.Answered == 0 {
+		return 0, errNoAnswers
+	}
+	return float64(q.Values[call.value]) / float64(q.Answered), nil
+}
+
+// evalBox returns the share of answers in the two highest, or lowest, points
+// of a rating question's scale
+func evalBox(top bool) func(env formulaEnv, call formulaCall) (float64, error) {
+	return func(env formulaEnv, call formulaCall) (float64, error) {
+		def, ok := env.questions[call.question]
+		if !ok || def.Type != QuestionRating {
+			return 0, fmt.Errorf("%s is not a rating question", call.question)
+		}
+		min, max := float64(defaultRatingMin), float64(defaultRatingMax)
+		if def.Min != nil {
+			min = *def.Min
+		}
+		if def.Max != nil {
+			max = *def.Max
+		}
+		low, high := min, min+1
+		if top {
+			low, high = max-1, max
+		}
+		return shareBetween(env.question(call.question), low, high)
+	}
+}
+
+// evalNPS returns the Net Promoter Score of a 0-10 question: the percentage of
+// promoters (9-10) minus that of detractors (0-6)
+func evalNPS(env formulaEnv, call formulaCall) (float64, error) {
+	q := env.question(call.question)
+	promoters, err := shareBetween(q, 9, 10)
+	if err != nil {
+		return 0, err
+	}
+	detractors, _ := shareBetween(q, 0, 6)
+	return (promoters - detractors) * 100, nil
+}
+
+// shareBetween returns the share of a question's answers from low to high
+func shareBetween(q QuestionResult, low, high float64) (float64, error) {
+	if q.Answered == 0 {
+		return 0, errNoAnswers
+	}
+	n := 0
+	for value, count := range q.Values {
+		if x, err := strconv.ParseFloat(value, 64); err == nil && x >= low && x <= high {
+			n += count
+		}
+	}
+	return float64(n) / float64(q.Answered), nil
+}
+
+// formulaParser parses expressions such as top2box(q3) - top2box(q4):
+//
+//	expr    = term { ("+" | "-") term }
+//	term    = unary { ("*" | "/") unary }
+//	unary   = "-" unary | primary
+//	primary = number | "(" expr ")" | name "(" [question ["," "value"]] ")"
+type formulaParser struct {
+	src string
+	pos int
+}
+
+// parseFormula parses a formula expression
+func parseFormula(src string) (formulaNode, error) {
+	if len(src) > maxFormulaLength {
+		return nil, fmt.Errorf("must be at most %d characters", maxFormulaLength)
+	}
+	p := &formulaParser{src: src}
+	node, err := p.expr()
+	if err != nil {
+		return nil, err
+	}
+	if p.skipSpace(); p.pos < len(p.src) {
+		return nil, p.errorf("unexpected %q", p.src[p.pos])
+	}
+	return node, nil
+}
+
+func (p *formulaParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("at position %d: %s", p.pos+1, fmt.Sprintf(format, args...))
+}
+
+func (p *formulaParser) skipSpace() {
+	for p.pos < len(p.src) && p.src[p.pos] == ' ' {
+		p.pos++
+	}
+}
+
+// peek returns the next character after spaces, or 0 at the end
+func (p *formulaParser) peek() byte {
+	if p.skipSpace(); p.pos < len(p.src) {
+		return p.src[p.pos]
+	}
+	return 0
+}
+
+func (p *formulaParser) expect(c byte) error {
+	if p.peek() != c {
+		if p.pos >= len(p.src) {
+			return p.errorf("expected %q at the end", c)
+		}
+		return p.errorf("expected %q", c)
+	}
+	p.pos++
+	return nil
+}
+
+func (p *formulaParser) expr() (formulaNode, error) {
+	node, err := p.term()
+	for err == nil && (p.peek() == '+' || p.peek() == '-') {
+		op := p.src[p.pos]
+		p.pos++
+		var right formulaNode
+		if right, err = p.term(); err == nil {
+			node = formulaBinary{op, node, right}
+		}
+	}
+	return node, err
+}
+
+func (p *formulaParser) term() (formulaNode, error) {
+	node, err := p.unary()
+	for err == nil && (p.peek() == '*' || p.peek() == '/') {
+		op := p.src[p.pos]
+		p.pos++
+		var right formulaNode
+		if right, err = p.unary(); err == nil {
+			node = formulaBinary{op, node, right}
+		}
+	}
+	return node, err
+}
+
+func (p *formulaParser) unary() (formulaNode, error) {
+	if p.peek() == '-' {
+		p.pos++
+		x, err := p.unary()
+		return formulaNeg{x}, err
+	}
+	return p.primary()
+}
+
+func (p *formulaParser) primary() (formulaNode, error) {
+	c := p.peek()
+	switch {
+	case c == '(':
+		p.pos++
+		node, err := p.expr()
+		if err == nil {
+			err = p.expect(')')
+		}
+		return node, err
+	case c >= '0' && c <= '9' || c == '.':
+		start := p.pos
+		for p.pos < len(p.src) && (p.src[p.pos] >= '0' && p.src[p.pos] <= '9' || p.src[p.pos] == '.') {
+			p.pos++
+		}
+		n, err := strconv.ParseFloat(p.src[start:p.pos], 64)
+		if err != nil || math.IsInf(n, 0) {
+			p.pos = start
+			return nil, p.errorf("invalid number")
+		}
+		return formulaNumber(n), nil
+	case isFormulaNameChar(c):
+		return p.call()
+	case c == 0:
+		return nil, p.errorf("expected a number, function or \"(\" at the end")
+	}
+	return nil, p.errorf("unexpected %q", c)
+}
+
+func isFormulaNameChar(c byte) bool {
+	return c < unicode.MaxASCII && (unicode.IsLetter(rune(c)) || unicode.IsDigit(rune(c))) || c == '_' || c == '-'
+}
+
+// name reads a function name or question ID
+func (p *formulaParser) name() string {
+	p.skipSpace()
+	start := p.pos
+	for p.pos < len(p.src) && isFormulaNameChar(p.src[p.pos]) {
+		p.pos++
+	}
+	return p.src[start:p.pos]
+}
+
+func (p *formulaParser) call() (formulaNode, error) {
+	start := p.pos
+	call := formulaCall{fn: p.name()}
+	fn, ok := formulaFuncs[call.fn]
+	if !ok {
+		p.pos = start
+		return nil, p.errorf("unknown function %s", call.fn)
+	}
+	if err := p.expect('('); err != nil {
+		return nil, err
+	}
+	if fn.question {
+		if call.question = p.name(); !questionIDPattern.MatchString(call.question) {
+			return nil, p.errorf("%s needs a question ID", call.fn)
+		}
+	}
+	if fn.value {
+		if err := p.expect(','); err != nil {
+			return nil, err
+		}
+		if err := p.expect('"'); err != nil {
+			return nil, err
+		}
+		end := strings.IndexByte(p.src[p.pos:], '"')
+		if end < 0 {
+			return nil, p.errorf("unterminated value")
+		}
+		call.value = p.src[p.pos : p.pos+end]
+		p.pos += end + 1
+	}
+	if err := p.expect(')'); err != nil {
+		return nil, err
+	}
+	return call, nil
+}
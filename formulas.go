@@ -0,0 +1,276 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Formula is a saved composite KPI, evaluated by the results endpoint
+type Formula struct {
+	ID         int       `json:"id"`
+	SurveyID   int       `json:"survey_id"`
+	Name       string    `json:"name"`
+	Expression string    `json:"expression"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// FormulaResult is the value of a formula over a survey's results; Value is
+// null and Error set when it can't be computed, e.g. dividing by zero
+type FormulaResult struct {
+	Name       string   `json:"name"`
+	Expression string   `json:"expression"`
+	Value      *float64 `json:"value"`
+	Error      string   `json:"error,omitempty"`
+}
+
+// CreateFormulaRequest represents the request body for saving a formula
+type CreateFormulaRequest struct {
+	Formula struct {
+		Name       string `json:"name" binding:"required"`
+		Expression string `json:"expression" binding:"required"`
+	} `json:"formula" binding:"required"`
+}
+
+// formulaColumns lists the columns read by scanFormula
+const formulaColumns = "id, survey_id, name, expression, created_at, updated_at"
+
+// scanFormula scans a row selected with formulaColumns
+func scanFormula(row rowScanner) (Formula, error) {
+	var f Formula
+	err := row.Scan(&f.ID, &f.SurveyID, &f.Name, &f.Expression, &f.CreatedAt, &f.UpdatedAt)
+	return f, err
+}
+
+// findFormula loads a saved formula belonging to a survey
+func findFormula(ctx context.Context, surveyID, formulaID int) (Formula, error) {
+	return scanFormula(db.QueryRowContext(ctx, `
+		SELECT `+formulaColumns+`
+		FROM survey_formulas
+		WHERE id = ? AND survey_id = ?
+	`, formulaID, surveyID))
+}
+
+// listFormulas loads the saved formulas of a survey, by name
+func listFormulas(ctx context.Context, surveyID int) ([]Formula, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT `+formulaColumns+`
+		FROM survey_formulas
+		WHERE survey_id = ?
+		ORDER BY name
+	`, surveyID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	formulas := []Formula{}
+	for rows.Next() {
+		f, err := scanFormula(rows)
+		if err != nil {
+			return nil, err
+		}
+		formulas = append(formulas, f)
+	}
+	return formulas, rows.Err()
+}
+
+// evaluateFormulas computes formulas over a survey's results
+func evaluateFormulas(survey Survey, results SurveyResults, formulas []Formula) []FormulaResult {
+	env := formulaEnv{results: results, questions: map[string]Question{}}
+	for _, q := range survey.Questions {
+		env.questions[q.ID] = q
+	}
+
+	evaluated := make([]FormulaResult, len(formulas))
+	for i, f := range formulas {
+		evaluated[i] = FormulaResult{Name: f.Name, Expression: f.Expression}
+		node, err := parseFormula(f.Expression)
+		var value float64
+		if err == nil {
+			value, err = node.eval(env)
+		}
+		if err != nil {
+			evaluated[i].Error = err.Error()
+			continue
+		}
+		evaluated[i].Value = &value
+	}
+	return evaluated
+}
+
+// getFormulas returns the saved formulas of a survey
+func getFormulas(c *gin.Context) {
+	ctx := c.Request.Context()
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		abortWithError(c, errInvalidID("survey"))
+		return
+	}
+
+	var exists bool
+	err = db.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM surveys WHERE id = ?)", id).Scan(&exists)
+	if err != nil || !exists {
+		abortWithError(c, errNotFound(CodeSurveyNotFound, "Survey not found"))
+		return
+	}
+
+	formulas, err := listFormulas(ctx, id)
+	if err != nil {
+		abortWithError(c, errInternal("Failed to fetch formulas", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Status: "success",
+		Data:   formulas,
+	})
+}
+
+// getFormula returns a specific saved formula
+func getFormula(c *gin.Context) {
+	ctx := c.Request.Context()
+	sID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		abortWithError(c, errInvalidID("survey"))
+		return
+	}
+	fID, err := strconv.Atoi(c.Param("formula_id"))
+	if err != nil {
+		abortWithError(c, errInvalidID("formula"))
+		return
+	}
+
+	formula, err := findFormula(ctx, sID, fID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			abortWithError(c, errNotFound(CodeFormulaNotFound, "Formula not found"))
+			return
+		}
+		abortWithError(c, errInternal("Failed to fetch formula", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Status: "success",
+		Data:   formula,
+	})
+}
+
+// createFormula saves a named formula for a survey
+func createFormula(c *gin.Context) {
+	ctx := c.Request.Context()
+	sID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		abortWithError(c, errInvalidID("survey"))
+		return
+	}
+
+	survey, err := findSurvey(ctx, sID)
+	if err != nil {
+		abortWithError(c, errNotFound(CodeSurveyNotFound, "Survey not found"))
+		return
+	}
+
+	var req CreateFormulaRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		abortWithError(c, errInvalidRequest(err))
+		return
+	}
+	formula := req.Formula
+
+	// Validation
+	var errors []string
+	if len(formula.Name) > 100 {
+		errors = append(errors, "Name must be less than 100 characters")
+	}
+	node, err := parseFormula(formula.Expression)
+	if err != nil {
+		errors = append(errors, "Expression is invalid "+err.Error())
+	} else if len(survey.Questions) > 0 {
+		// Surveys without question definitions may have answers to any key
+		seen := map[string]bool{}
+		for _, id := range formulaQuestions(node) {
+			known := false
+			for _, q := range survey.Questions {
+				known = known || q.ID == id
+			}
+			if !known && !seen[id] {
+				errors = append(errors, "Expression refers to question "+id+", which doesn't exist")
+			}
+			seen[id] = true
+		}
+	}
+
+	var taken bool
+	err = db.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM survey_formulas WHERE survey_id = ? AND name = ?)", sID, formula.Name).Scan(&taken)
+	if err == nil && taken {
+		errors = append(errors, "Name has already been taken")
+	}
+
+	if len(errors) > 0 {
+		abortWithError(c, errValidation("Failed to save formula", errors))
+		return
+	}
+
+	result, err := db.ExecContext(ctx, `
+		INSERT INTO survey_formulas (survey_id, name, expression, created_at, updated_at)
+		VALUES (?, ?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+	`, sID, formula.Name, formula.Expression)
+	if err != nil {
+		abortWithError(c, errInternal("Failed to save formula", err))
+		return
+	}
+
+	id, _ := result.LastInsertId()
+	saved, err := findFormula(ctx, sID, int(id))
+	if err != nil {
+		abortWithError(c, errInternal("Failed to fetch saved formula", err))
+		return
+	}
+	auditChange(c, "create", "survey_formula", saved.ID, nil, saved)
+
+	c.JSON(http.StatusCreated, APIResponse{
+		Status:  "success",
+		Message: "Formula saved successfully",
+		Data:    saved,
+	})
+}
+
+// deleteFormula removes a saved formula
+func deleteFormula(c *gin.Context) {
+	ctx := c.Request.Context()
+	sID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		abortWithError(c, errInvalidID("survey"))
+		return
+	}
+	fID, err := strconv.Atoi(c.Param("formula_id"))
+	if err != nil {
+		abortWithError(c, errInvalidID("formula"))
+		return
+	}
+
+	formula, err := findFormula(ctx, sID, fID)
+	if err != nil {
+		abortWithError(c, errNotFound(CodeFormulaNotFound, "Formula not found"))
+		return
+	}
+
+	_, err = db.ExecContext(ctx, "DELETE FROM survey_formulas WHERE id = ? AND survey_id = ?", fID, sID)
+	if err != nil {
+		abortWithError(c, errInternal("Failed to delete formula", err))
+		return
+	}
+	auditChange(c, "delete", "survey_formula", fID, formula, nil)
+
+	c.JSON(http.StatusOK, APIResponse{
+		Status:  "success",
+		Message: "Formula deleted successfully",
+	})
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseFormula(t *testing.T) {
+	for _, src := range []string{
+		"top2box(q3) - top2box(q4)",
+		"-(mean(rating) - 1) / 4 * 100",
+		`share(plan, "Pro") * responses()`,
+		"nps(recommend)",
+		"1.5",
+	} {
+		_, err := parseFormula(src)
+		assert.NoError(t, err, src)
+	}
+	for _, src := range []string{
+		"",
+		"top2box(q3) -",
+		"median(q3)",
+		"top2box()",
+		`share(plan)`,
+		`share(plan, "Pro"`,
+		"(1 + 2",
+		"1 2",
+		"1..2",
+	} {
+		_, err := parseFormula(src)
+		assert.Error(t, err, src)
+	}
+}
+
+func TestEvaluateFormulas(t *testing.T) {
+	survey := Survey{Questions: []Question{
+		{ID: "q3", Type: QuestionRating},
+		{ID: "q4", Type: QuestionRating},
+		{ID: "plan", Type: QuestionSingleChoice},
+	}}
+	results := SurveyResults{ResponsesCount: 4, Questions: []QuestionResult{
+		{ID: "q3", Answered: 4, Values: map[string]int{"1": 1, "4": 1, "5": 2}},
+		{ID: "q4", Answered: 4, Values: map[string]int{"1": 2, "2": 1, "5": 1}},
+		{ID: "plan", Answered: 2, Values: map[string]int{"Pro": 1, "Free": 1}},
+		{ID: "recommend", Answered: 4, Values: map[string]int{"10": 2, "8": 1, "3": 1}},
+	}}
+	evaluated := evaluateFormulas(survey, results, []Formula{
+		{Name: "gap", Expression: "top2box(q3) - top2box(q4)"},
+		{Name: "low", Expression: "bottom2box(q4)"},
+		{Name: "mean", Expression: "mean(q3)"},
+		{Name: "pro", Expression: `share(plan, "Pro") * count(plan) / responses()`},
+		{Name: "nps", Expression: "nps(recommend)"},
+		{Name: "zero", Expression: "1 / (count(q3) - 4)"},
+		{Name: "unanswered", Expression: "mean(q9)"},
+		{Name: "text", Expression: "top2box(plan)"},
+	})
+
+	values := map[string]float64{}
+	errors := map[string]string{}
+	for _, r := range evaluated {
+		if r.Value != nil {
+			values[r.Name] = *r.Value
+		}
+		errors[r.Name] = r.Error
+	}
+	assert.InDelta(t, 0.5, values["gap"], 1e-9)
+	assert.InDelta(t, 0.75, values["low"], 1e-9)
+	assert.InDelta(t, 3.75, values["mean"], 1e-9)
+	assert.InDelta(t, 0.25, values["pro"], 1e-9)
+	assert.InDelta(t, 25, values["nps"], 1e-9)
+	assert.Equal(t, "division by zero", errors["zero"])
+	assert.Equal(t, "q9 has no answers", errors["unanswered"])
+	assert.Equal(t, "plan is not a rating question", errors["text"])
+}
+
+func TestSurveyFormulas(t *testing.T) {
+	setupTestDB()
+	defer testDB.Close()
+
+	result, err := testDB.Exec(`INSERT INTO surveys (title, description, questions) VALUES ('Formulas', 'd',
+		'[{"id":"q3","type":"rating","label":"Quality"},{"id":"q4","type":"rating","label":"Price"}]')`)
+	assert.NoError(t, err)
+	surveyID, _ := result.LastInsertId()
+	for i, answers := range []string{`{"q3": 5, "q4": 1}`, `{"q3": 4, "q4": 5}`} {
+		testDB.Exec("INSERT INTO survey_responses (survey_id, user_identifier, response_data) VALUES (?, ?, ?)",
+			surveyID, fmt.Sprintf("user%d", i), answers)
+	}
+	router := setupTestRouter()
+	base := fmt.Sprintf("/api/surveys/%d", surveyID)
+	send := func(method, url, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, url, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := send("POST", base+"/formulas", `{"formula":{"name":"gap","expression":"top2box(q3) - top2box(q4)"}}`)
+	assert.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var created struct {
+		Data Formula `json:"data"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &created)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, send("POST", base+"/formulas", `{"formula":{"name":"gap","expression":"mean(q3)"}}`).Code)
+	assert.Equal(t, http.StatusUnprocessableEntity, send("POST", base+"/formulas", `{"formula":{"name":"bad","expression":"mean(q3"}}`).Code)
+	assert.Equal(t, http.StatusUnprocessableEntity, send("POST", base+"/formulas", `{"formula":{"name":"unknown","expression":"mean(q9)"}}`).Code)
+
+	w = send("GET", base+"/results", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var results struct {
+		Data SurveyResults `json:"data"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &results)
+	if assert.Len(t, results.Data.Formulas, 1) && assert.NotNil(t, results.Data.Formulas[0].Value) {
+		assert.InDelta(t, 0.5, *results.Data.Formulas[0].Value, 1e-9)
+	}
+
+	assert.Equal(t, http.StatusOK, send("GET", fmt.Sprintf("%s/formulas/%d", base, created.Data.ID), "").Code)
+	assert.Equal(t, http.StatusOK, send("DELETE", fmt.Sprintf("%s/formulas/%d", base, created.Data.ID), "").Code)
+	assert.Equal(t, http.StatusNotFound, send("GET", fmt.Sprintf("%s/formulas/%d", base, created.Data.ID), "").Code)
+}
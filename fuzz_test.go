@@ -0,0 +1,352 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// fuzzSpec is the part of the OpenAPI document requests are generated from
+type fuzzSpec struct {
+	Paths map[string]map[string]struct {
+		Parameters []struct {
+			Name string `json:"name"`
+			In   string `json:"in"`
+		} `json:"parameters"`
+		RequestBody *struct {
+			Content map[string]struct {
+				Schema map[string]interface{} `json:"schema"`
+			} `json:"content"`
+		} `json:"requestBody"`
+	} `json:"paths"`
+	Components struct {
+		Schemas map[string]map[string]interface{} `json:"schemas"`
+	} `json:"components"`
+}
+
+// fuzzOperation is a documented route with what its requests may carry
+type fuzzOperation struct {
+	Method     string
+	Path       string // OpenAPI syntax, e.g. /api/surveys/{id}
+	PathParams []string
+	Query      []string
+	Body       map[string]interface{} // schema of the body, nil without one
+}
+
+// fuzzRequest is one generated request
+type fuzzRequest struct {
+	Method string
+	URL    string
+	Body   []byte
+}
+
+// Values sent in place of path and query parameters
+var fuzzParamValues = []string{"abc", "-1", "0", "99999999999999999999", "1.5", "%00", strings.Repeat("9", 4096)}
+
+// Bodies that aren't the JSON of any request
+var fuzzInvalidBodies = [][]byte{
+	nil,
+	[]byte("{"),
+	[]byte(`{"survey":`),
+	[]byte("[1, 2"),
+	[]byte(`{"a": 1}{"b": 2}`),
+	[]byte("\xff\xfe\xfd"),
+	[]byte("null"),
+	[]byte("[]"),
+	[]byte(`"text"`),
+	[]byte("42"),
+	[]byte(strings.Repeat("[", 5000) + strings.Repeat("]", 5000)),
+}
+
+// fuzzHugeString is longer than any field accepts
+var fuzzHugeString = strings.Repeat("é", 64*1024)
+
+// loadFuzzSpec fetches the OpenAPI document and lists its operations in a stable order
+func loadFuzzSpec(t testing.TB, router *gin.Engine) (fuzzSpec, []fuzzOperation) {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/openapi.json", nil)
+	router.ServeHTTP(w, req)
+	var spec fuzzSpec
+	if err := json.Unmarshal(w.Body.Bytes(), &spec); err != nil {
+		t.Fatal(err)
+	}
+
+	var ops []fuzzOperation
+	for path, methods := range spec.Paths {
+		for method, operation := range methods {
+			op := fuzzOperation{Method: strings.ToUpper(method), Path: path}
+			for _, p := range operation.Parameters {
+				if p.In == "path" {
+					op.PathParams = append(op.PathParams, p.Name)
+				} else if p.In == "query" {
+					op.Query = append(op.Query, p.Name)
+				}
+			}
+			if operation.RequestBody != nil {
+				op.Body = operation.RequestBody.Content["application/json"].Schema
+			}
+			ops = append(ops, op)
+		}
+	}
+	sort.Slice(ops, func(i, j int) bool { return ops[i].Path+ops[i].Method < ops[j].Path+ops[j].Method })
+	return spec, ops
+}
+
+// resolve follows a schema's $ref
+func (s fuzzSpec) resolve(schema map[string]interface{}) map[string]interface{} {
+	if ref, ok := schema["$ref"].(string); ok {
+		return s.Components.Schemas[strings.TrimPrefix(ref, "#/components/schemas/")]
+	}
+	return schema
+}
+
+// example returns a value matching schema, with objects nested at most depth deep
+func (s fuzzSpec) example(schema map[string]interface{}, depth int) interface{} {
+	schema = s.resolve(schema)
+	switch schema["type"] {
+	case "object":
+		obj := map[string]interface{}{}
+		properties, _ := schema["properties"].(map[string]interface{})
+		if depth == 0 {
+			return obj
+		}
+		for name, p := range properties {
+			obj[name] = s.example(p.(map[string]interface{}), depth-1)
+		}
+		return obj
+	case "array":
+		if items, ok := schema["items"].(map[string]interface{}); ok && depth > 0 {
+			return []interface{}{s.example(items, depth-1)}
+		}
+		return []interface{}{}
+	case "string":
+		if schema["format"] == "date-time" {
+			return "2024-01-15T10:30:00Z"
+		}
+		return "text"
+	case "integer":
+		return 1
+	case "number":
+		return 1.5
+	case "boolean":
+		return true
+	}
+	return map[string]interface{}{}
+}
+
+// wrongValues returns values of other types than schema's, plus null and a huge string
+func (s fuzzSpec) wrongValues(schema map[string]interface{}) []interface{} {
+	values := []interface{}{nil, fuzzHugeString}
+	switch s.resolve(schema)["type"] {
+	case "string":
+		values = append(values, 12, []interface{}{"a"}, "")
+	case "integer", "number":
+		values = append(values, "12", -1, 1e308, 1.5)
+	case "boolean":
+		values = append(values, "yes", 0)
+	case "array":
+		values = append(values, "a", map[string]interface{}{}, []interface{}{nil, 1, "a", fuzzHugeString})
+	default:
+		values = append(values, "a", 12, []interface{}{})
+	}
+	return values
+}
+
+// variants returns values for schema with one field at a time, here or
+// nested in objects and the first item of arrays, replaced by a wrong value
+func (s fuzzSpec) variants(schema map[string]interface{}, depth int) []interface{} {
+	schema = s.resolve(schema)
+	variants := s.wrongValues(schema)
+	if depth == 0 {
+		return variants
+	}
+	switch schema["type"] {
+	case "object":
+		properties, _ := schema["properties"].(map[string]interface{})
+		names := make([]string, 0, len(properties))
+		for name := range properties {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			for _, v := range s.variants(properties[name].(map[string]interface{}), depth-1) {
+				obj := s.example(schema, depth).(map[string]interface{})
+				obj[name] = v
+				variants = append(variants, obj)
+			}
+		}
+	case "array":
+		if items, ok := schema["items"].(map[string]interface{}); ok {
+			for _, v := range s.variants(items, depth-1) {
+				variants = append(variants, []interface{}{v})
+			}
+		}
+	}
+	return variants
+}
+
+// bodies returns an example body followed by its variants
+func (s fuzzSpec) bodies(schema map[string]interface{}) [][]byte {
+	values := append([]interface{}{s.example(schema, 4)}, s.variants(schema, 4)...)
+	bodies := make([][]byte, len(values))
+	for i, v := range values {
+		bodies[i], _ = json.Marshal(v)
+	}
+	return bodies
+}
+
+// requests generates the requests of an operation: a valid baseline, each path
+// and query parameter replaced with odd values, and malformed bodies
+func (s fuzzSpec) requests(op fuzzOperation) []fuzzRequest {
+	path := func(bad string, value string) string {
+		p := op.Path
+		for _, name := range op.PathParams {
+			v := "1"
+			if name == bad {
+				v = value
+			}
+			p = strings.Replace(p, "{"+name+"}", url.PathEscape(v), 1)
+		}
+		return p
+	}
+	var body []byte
+	if op.Body != nil {
+		body, _ = json.Marshal(s.example(op.Body, 4))
+	}
+
+	requests := []fuzzRequest{{op.Method, path("", ""), body}}
+	for _, name := range op.PathParams {
+		for _, v := range fuzzParamValues {
+			requests = append(requests, fuzzRequest{op.Method, path(name, v), body})
+		}
+	}
+	for _, name := range op.Query {
+		for _, v := range fuzzParamValues {
+			requests = append(requests, fuzzRequest{op.Method, path("", "") + "?" + url.Values{name: {v}}.Encode(), body})
+		}
+	}
+	if op.Body != nil {
+		for _, b := range append(fuzzInvalidBodies, s.bodies(op.Body)...) {
+			requests = append(requests, fuzzRequest{op.Method, path("", ""), b})
+		}
+	}
+	return requests
+}
+
+// seedFuzzData adds a survey with a question of each type and a response, so
+// requests get past their lookups
+func seedFuzzData(t testing.TB) {
+	_, err := testDB.Exec(`INSERT INTO surveys (title, description, questions) VALUES ('Fuzzed', 'd', ?)`, `[
+		{"id":"comment","type":"text","label":"Comment"},
+		{"id":"age","type":"number","label":"Age","min":0,"max":120},
+		{"id":"ok","type":"boolean","label":"OK?"},
+		{"id":"nps","type":"rating","label":"NPS","min":0,"max":10},
+		{"id":"role","type":"single_choice","label":"Role","options":["Dev","Ops"]},
+		{"id":"tools","type":"multiple_choice","label":"Tools","options":["Go","SQL"]}
+	]`)
+	if err == nil {
+		_, err = testDB.Exec(`INSERT INTO survey_responses (survey_id, user_identifier, response_data) VALUES (1, '1', '{"comment":"Fine","age":30,"ok":true,"nps":9,"role":"Dev","tools":["Go"]}')`)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// serveFuzzRequest sends a request as an admin from its own client IP, so
+// rate limits don't stop the requests that follow
+func serveFuzzRequest(router *gin.Engine, r fuzzRequest, n int) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(r.Method, r.URL, bytes.NewReader(r.Body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+adminToken)
+	req.RemoteAddr = fmt.Sprintf("10.%d.%d.%d:1234", n>>16&255, n>>8&255, n&255)
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// checkFuzzResponse asserts a request failed, if it did, with a structured client error
+func checkFuzzResponse(t testing.TB, r fuzzRequest, w *httptest.ResponseRecorder) {
+	t.Helper()
+	desc := fmt.Sprintf("%s %.200s %.200q", r.Method, r.URL, r.Body)
+	if w.Code >= 500 && w.Code != http.StatusServiceUnavailable {
+		t.Errorf("%s: got %d: %.500s", desc, w.Code, w.Body.String())
+		return
+	}
+	if w.Code < 400 {
+		return
+	}
+	var response APIResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil || response.Status != "error" || response.Code == "" {
+		t.Errorf("%s: got %d without a structured error: %.500s", desc, w.Code, w.Body.String())
+	}
+}
+
+// TestFuzzAPI sends the requests generated from the OpenAPI document to every
+// operation, each on a fresh database
+func TestFuzzAPI(t *testing.T) {
+	if testing.Short() {
+		t.Skip("fuzzing every operation is slow")
+	}
+	defer func(token string) { adminToken = token }(adminToken)
+	adminToken = "fuzz-admin-token"
+
+	setupTestDB()
+	spec, ops := loadFuzzSpec(t, setupTestRouter())
+	testDB.Close()
+	assert.NotEmpty(t, ops)
+
+	n := 0
+	for _, op := range ops {
+		setupTestDB()
+		router := setupTestRouter()
+		seedFuzzData(t)
+		for _, r := range spec.requests(op) {
+			n++
+			checkFuzzResponse(t, r, serveFuzzRequest(router, r, n))
+		}
+		testDB.Close()
+	}
+	t.Logf("sent %d requests to %d operations", n, len(ops))
+}
+
+// FuzzAPIBody sends arbitrary bodies to the operations taking one; the seed
+// corpus has the bodies generated from the OpenAPI document
+func FuzzAPIBody(f *testing.F) {
+	defer func(token string) { adminToken = token }(adminToken)
+	adminToken = "fuzz-admin-token"
+
+	setupTestDB()
+	defer testDB.Close()
+	router := setupTestRouter()
+	seedFuzzData(f)
+	spec, ops := loadFuzzSpec(f, router)
+	var withBody []fuzzOperation
+	for _, op := range ops {
+		if op.Body != nil {
+			withBody = append(withBody, op)
+		}
+	}
+	for i, op := range withBody {
+		for _, b := range append(fuzzInvalidBodies[:4], spec.bodies(op.Body)[:1]...) {
+			f.Add(uint16(i), b)
+		}
+	}
+
+	n := 0
+	f.Fuzz(func(t *testing.T, i uint16, body []byte) {
+		op := withBody[int(i)%len(withBody)]
+		r := spec.requests(op)[0]
+		r.Body = body
+		n++
+		checkFuzzResponse(t, r, serveFuzzRequest(router, r, n))
+	})
+}
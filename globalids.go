@@ -0,0 +1,77 @@
+package main
+
+import (
+	"database/sql"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Global IDs are opaque, random IDs given to surveys and responses alongside
+// their integer keys. Their type prefix keeps them unambiguous in logs and
+// webhook payloads, and unlike integer keys they can't be enumerated.
+const (
+	GlobalIDSurvey   = "srv"
+	GlobalIDResponse = "rsp"
+)
+
+// globalIDType returns the type prefix of a global ID, or "" when it isn't one
+func globalIDType(globalID string) string {
+	prefix, rest, ok := strings.Cut(globalID, "_")
+	if !ok || len(rest) != 16 || strings.Trim(rest, "0123456789abcdef") != "" {
+		return ""
+	}
+	switch prefix {
+	case GlobalIDSurvey, GlobalIDResponse:
+		return prefix
+	}
+	return ""
+}
+
+// lookupGlobalID resolves a global ID and serves the resource as its own
+// route does, e.g. a survey as GET /api/surveys/:id. The route has no :id, so
+// tenantScope can't hide other organizations' surveys: that's checked here.
+func lookupGlobalID(c *gin.Context) {
+	ctx := c.Request.Context()
+	globalID := c.Param("global_id")
+
+	switch globalIDType(globalID) {
+	case GlobalIDSurvey:
+		var id int
+		err := db.QueryRowContext(ctx, "SELECT id FROM surveys WHERE global_id = ?", globalID).Scan(&id)
+		if err == sql.ErrNoRows {
+			abortWithError(c, errNotFound(CodeSurveyNotFound, "Survey not found"))
+			return
+		}
+		if err != nil {
+			abortWithError(c, errInternal("Failed to look up survey", err))
+			return
+		}
+		if !checkSurveyOrganization(c, id) {
+			return
+		}
+		c.Params = gin.Params{{Key: "id", Value: strconv.Itoa(id)}}
+		getSurvey(c)
+
+	case GlobalIDResponse:
+		var id, surveyID int
+		err := db.QueryRowContext(ctx, "SELECT id, survey_id FROM survey_responses WHERE global_id = ?", globalID).Scan(&id, &surveyID)
+		if err == sql.ErrNoRows {
+			abortWithError(c, errNotFound(CodeResponseNotFound, "Survey response not found"))
+			return
+		}
+		if err != nil {
+			abortWithError(c, errInternal("Failed to look up response", err))
+			return
+		}
+		if !checkSurveyOrganization(c, surveyID) {
+			return
+		}
+		c.Params = gin.Params{{Key: "id", Value: strconv.Itoa(surveyID)}, {Key: "response_id", Value: strconv.Itoa(id)}}
+		getSurveyResponse(c)
+
+	default:
+		abortWithError(c, errInvalidID("global"))
+	}
+}
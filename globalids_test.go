@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLookupGlobalID(t *testing.T) {
+	setupTestDB()
+	defer testDB.Close()
+	router := setupTestRouter()
+
+	testDB.Exec("INSERT INTO surveys (title, description) VALUES ('Pulse', 'd')")
+	testDB.Exec("INSERT INTO surveys (title, description, status) VALUES ('Draft', 'd', 'draft')")
+	testDB.Exec(`INSERT INTO survey_responses (survey_id, user_identifier, response_data) VALUES (1, 'user1', '{"rating": 4}')`)
+
+	get := func(url string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", url, nil)
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	var survey struct {
+		Data Survey `json:"data"`
+	}
+	json.Unmarshal(get("/api/surveys/1").Body.Bytes(), &survey)
+	assert.Regexp(t, `^srv_[0-9a-f]{16}$`, survey.Data.GlobalID)
+	var response struct {
+		Data SurveyResponse `json:"data"`
+	}
+	json.Unmarshal(get("/api/surveys/1/responses/1").Body.Bytes(), &response)
+	assert.Regexp(t, `^rsp_[0-9a-f]{16}$`, response.Data.GlobalID)
+
+	// Lookups answer as the resource's own route does
+	w := get("/api/lookup/" + survey.Data.GlobalID)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, get("/api/surveys/1").Body.String(), w.Body.String())
+	w = get("/api/lookup/" + response.Data.GlobalID)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, get("/api/surveys/1/responses/1").Body.String(), w.Body.String())
+
+	var draftID string
+	testDB.QueryRow("SELECT global_id FROM surveys WHERE id = 2").Scan(&draftID)
+	assert.Contains(t, get("/api/lookup/"+draftID).Body.String(), `"coming_soon":true`)
+
+	assert.Equal(t, http.StatusNotFound, get("/api/lookup/srv_0000000000000000").Code)
+	assert.Equal(t, http.StatusNotFound, get("/api/lookup/rsp_0000000000000000").Code)
+	for _, id := range []string{"1", "srv_1", "usr_0123456789abcdef", "srv_0123456789ABCDEF"} {
+		assert.Equal(t, http.StatusBadRequest, get("/api/lookup/"+id).Code, id)
+	}
+}
+
+func TestLookupGlobalIDAcrossOrganizations(t *testing.T) {
+	setupTestDB()
+	defer testDB.Close()
+	router := setupTestRouter()
+
+	testDB.Exec("INSERT INTO organizations (name) VALUES ('Acme'), ('Globex')")
+	testDB.Exec("INSERT INTO surveys (title, description, org_id) VALUES ('Acme roadmap', 'd', 1), ('Globex pricing', 'd', 2)")
+	testDB.Exec(`INSERT INTO survey_responses (survey_id, user_identifier, response_data) VALUES (2, 'user1', '{}')`)
+	var surveyID, responseID string
+	testDB.QueryRow("SELECT global_id FROM surveys WHERE id = 2").Scan(&surveyID)
+	testDB.QueryRow("SELECT global_id FROM survey_responses WHERE id = 1").Scan(&responseID)
+
+	get := func(org, url string) int {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", url, nil)
+		req.Header.Set(organizationHeader, org)
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// Globex's survey and response don't exist for Acme, by global ID either
+	assert.Equal(t, http.StatusNotFound, get("1", "/api/surveys/2"))
+	assert.Equal(t, http.StatusNotFound, get("1", "/api/lookup/"+surveyID))
+	assert.Equal(t, http.StatusNotFound, get("1", "/api/lookup/"+responseID))
+	assert.Equal(t, http.StatusOK, get("2", "/api/lookup/"+surveyID))
+	assert.Equal(t, http.StatusOK, get("2", "/api/lookup/"+responseID))
+}
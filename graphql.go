@@ -0,0 +1,519 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// This file parses the GraphQL query language: queries with variables,
+// aliases, arguments, fragments and the @include and @skip directives.
+// Mutations and subscriptions are refused; see graphqlschema.go for the schema.
+
+// gqlDocument is a parsed GraphQL request document
+type gqlDocument struct {
+	operations []*gqlOperation
+	fragments  map[string]*gqlFragment
+}
+
+// gqlOperation is an operation of a document
+type gqlOperation struct {
+	kind      string
+	name      string
+	variables []gqlVariable
+	selection []gqlSelection
+}
+
+// gqlVariable declares a variable of an operation
+type gqlVariable struct {
+	name     string
+	typ      string
+	defValue interface{}
+	hasDef   bool
+}
+
+// gqlFragment is a named fragment of a document
+type gqlFragment struct {
+	typeCondition string
+	selection     []gqlSelection
+}
+
+// gqlSelection is a field, a fragment spread (fragment set) or an inline
+// fragment (selection set)
+type gqlSelection struct {
+	alias      string
+	name       string
+	args       map[string]interface{}
+	directives []gqlDirective
+	selection  []gqlSelection
+	fragment   string
+	// typeCondition is set on inline fragments with "on Type"
+	typeCondition string
+	inline        bool
+	line, column  int
+}
+
+// responseKey is the key of a field in the result
+func (s gqlSelection) responseKey() string {
+	if s.alias != "" {
+		return s.alias
+	}
+	return s.name
+}
+
+// gqlDirective is a directive applied to a selection
+type gqlDirective struct {
+	name string
+	args map[string]interface{}
+}
+
+// gqlVariableRef refers to a variable in an argument value
+type gqlVariableRef string
+
+// gqlEnumValue is an enum value as written
+type gqlEnumValue string
+
+// gqlSyntaxError is an error in a document, at a 1-based line and column
+type gqlSyntaxError struct {
+	message      string
+	line, column int
+}
+
+func (e *gqlSyntaxError) Error() string {
+	return fmt.Sprintf("Syntax error at %d:%d: %s", e.line, e.column, e.message)
+}
+
+// Token kinds
+const (
+	gqlEOF = iota
+	gqlPunct
+	gqlName
+	gqlInt
+	gqlFloat
+	gqlString
+)
+
+type gqlToken struct {
+	kind         int
+	value        string
+	line, column int
+}
+
+// gqlParser is a recursive descent parser over a document's tokens
+type gqlParser struct {
+	src          string
+	pos          int
+	line, column int
+	tok          gqlToken
+}
+
+// parseGraphQL parses a request document
+func parseGraphQL(src string) (doc *gqlDocument, err error) {
+	p := &gqlParser{src: src, line: 1, column: 1}
+	defer func() {
+		if r := recover(); r != nil {
+			syntaxErr, ok := r.(*gqlSyntaxError)
+			if !ok {
+				panic(r)
+			}
+			doc, err = nil, syntaxErr
+		}
+	}()
+	p.next()
+	doc = &gqlDocument{fragments: map[string]*gqlFragment{}}
+	for p.tok.kind != gqlEOF {
+		switch {
+		case p.peek(gqlPunct, "{"):
+			doc.operations = append(doc.operations, &gqlOperation{kind: "query", selection: p.selectionSet()})
+		case p.peek(gqlName, "fragment"):
+			p.next()
+			name := p.expect(gqlName, "").value
+			if name == "on" {
+				p.fail(p.tok, `Fragments can't be named "on"`)
+			}
+			if _, ok := doc.fragments[name]; ok {
+				p.fail(p.tok, "Fragment "+name+" is defined twice")
+			}
+			p.expect(gqlName, "on")
+			fragment := &gqlFragment{typeCondition: p.expect(gqlName, "").value}
+			p.directives()
+			fragment.selection = p.selectionSet()
+			doc.fragments[name] = fragment
+		case p.peek(gqlName, "query"), p.peek(gqlName, "mutation"), p.peek(gqlName, "subscription"):
+			doc.operations = append(doc.operations, p.operation())
+		default:
+			p.fail(p.tok, "Unexpected "+p.describe(p.tok))
+		}
+	}
+	if len(doc.operations) == 0 {
+		p.fail(p.tok, "The document has no operation")
+	}
+	return doc, nil
+}
+
+// fail aborts parsing with an error at tok
+func (p *gqlParser) fail(tok gqlToken, message string) {
+	panic(&gqlSyntaxError{message: message, line: tok.line, column: tok.column})
+}
+
+func (p *gqlParser) describe(tok gqlToken) string {
+	switch tok.kind {
+	case gqlEOF:
+		return "end of document"
+	case gqlString:
+		return "string"
+	}
+	return strconv.Quote(tok.value)
+}
+
+// peek reports whether the current token is of kind, and value unless it's empty
+func (p *gqlParser) peek(kind int, value string) bool {
+	return p.tok.kind == kind && (value == "" || p.tok.value == value)
+}
+
+// expect consumes the current token, which must be of kind (and value, unless empty)
+func (p *gqlParser) expect(kind int, value string) gqlToken {
+	tok := p.tok
+	if !p.peek(kind, value) {
+		want := map[int]string{gqlPunct: "punctuator", gqlName: "name", gqlInt: "integer", gqlString: "string"}[kind]
+		if value != "" {
+			want = strconv.Quote(value)
+		}
+		p.fail(tok, "Expected "+want+", found "+p.describe(tok))
+	}
+	p.next()
+	return tok
+}
+
+// skip consumes the current token if it is the punctuator value
+func (p *gqlParser) skip(value string) bool {
+	if p.peek(gqlPunct, value) {
+		p.next()
+		return true
+	}
+	return false
+}
+
+// advance moves past n bytes of the source on the current line
+func (p *gqlParser) advance(n int) {
+	p.pos += n
+	p.column += n
+}
+
+// next reads the next token, skipping whitespace, commas and comments
+func (p *gqlParser) next() {
+	for p.pos < len(p.src) {
+		switch c := p.src[p.pos]; {
+		case c == '\n':
+			p.pos++
+			p.line, p.column = p.line+1, 1
+		case c == ' ' || c == '\t' || c == '\r' || c == ',':
+			p.advance(1)
+		case c == '#':
+			for p.pos < len(p.src) && p.src[p.pos] != '\n' {
+				p.advance(1)
+			}
+		case strings.HasPrefix(p.src[p.pos:], "\ufeff"):
+			p.advance(len("\ufeff"))
+		default:
+			p.tok = p.lex()
+			return
+		}
+	}
+	p.tok = gqlToken{kind: gqlEOF, line: p.line, column: p.column}
+}
+
+// lex reads the token at the current position
+func (p *gqlParser) lex() gqlToken {
+	tok := gqlToken{line: p.line, column: p.column}
+	start := p.pos
+	c := p.src[p.pos]
+	switch {
+	case strings.HasPrefix(p.src[p.pos:], "..."):
+		p.advance(3)
+		tok.kind, tok.value = gqlPunct, "..."
+	case strings.ContainsRune("!$()[]{}:=@", rune(c)):
+		p.advance(1)
+		tok.kind, tok.value = gqlPunct, string(c)
+	case c == '_' || isLetter(c):
+		for p.pos < len(p.src) && (p.src[p.pos] == '_' || isLetter(p.src[p.pos]) || isDigit(p.src[p.pos])) {
+			p.advance(1)
+		}
+		tok.kind, tok.value = gqlName, p.src[start:p.pos]
+	case c == '-' || isDigit(c):
+		tok.kind = gqlInt
+		p.advance(1)
+		for p.pos < len(p.src) && isDigit(p.src[p.pos]) {
+			p.advance(1)
+		}
+		if p.pos < len(p.src) && p.src[p.pos] == '.' {
+			tok.kind = gqlFloat
+			p.advance(1)
+			for p.pos < len(p.src) && isDigit(p.src[p.pos]) {
+				p.advance(1)
+			}
+		}
+		if p.pos < len(p.src) && (p.src[p.pos] == 'e' || p.src[p.pos] == 'E') {
+			tok.kind = gqlFloat
+			p.advance(1)
+			if p.pos < len(p.src) && (p.src[p.pos] == '+' || p.src[p.pos] == '-') {
+				p.advance(1)
+			}
+			for p.pos < len(p.src) && isDigit(p.src[p.pos]) {
+				p.advance(1)
+			}
+		}
+		tok.value = p.src[start:p.pos]
+		if tok.value == "-" {
+			p.fail(tok, "Invalid number")
+		}
+	case c == '"':
+		tok.kind, tok.value = gqlString, p.lexString(tok)
+	default:
+		r, _ := utf8.DecodeRuneInString(p.src[p.pos:])
+		p.fail(tok, fmt.Sprintf("Unexpected character %q", r))
+	}
+	return tok
+}
+
+// lexString reads a string or block string
+func (p *gqlParser) lexString(tok gqlToken) string {
+	if strings.HasPrefix(p.src[p.pos:], `"""`) {
+		p.advance(3)
+		end := strings.Index(p.src[p.pos:], `"""`)
+		if end < 0 {
+			p.fail(tok, "Unterminated string")
+		}
+		value := p.src[p.pos : p.pos+end]
+		for _, c := range value {
+			if c == '\n' {
+				p.line, p.column = p.line+1, 0
+			}
+			p.column++
+		}
+		p.pos += end + 3
+		p.column += 3
+		return strings.TrimSpace(value)
+	}
+
+	p.advance(1)
+	var b strings.Builder
+	for {
+		if p.pos >= len(p.src) || p.src[p.pos] == '\n' {
+			p.fail(tok, "Unterminated string")
+		}
+		c := p.src[p.pos]
+		switch {
+		case c == '"':
+			p.advance(1)
+			return b.String()
+		case c == '\\':
+			if p.pos+1 >= len(p.src) {
+				p.fail(tok, "Unterminated string")
+			}
+			escape := p.src[p.pos+1]
+			p.advance(2)
+			switch escape {
+			case '"', '\\', '/':
+				b.WriteByte(escape)
+			case 'b':
+				b.WriteByte('\b')
+			case 'f':
+				b.WriteByte('\f')
+			case 'n':
+				b.WriteByte('\n')
+			case 'r':
+				b.WriteByte('\r')
+			case 't':
+				b.WriteByte('\t')
+			case 'u':
+				if p.pos+4 > len(p.src) {
+					p.fail(tok, "Invalid unicode escape")
+				}
+				code, err := strconv.ParseUint(p.src[p.pos:p.pos+4], 16, 32)
+				if err != nil {
+					p.fail(tok, "Invalid unicode escape")
+				}
+				b.WriteRune(rune(code))
+				p.advance(4)
+			default:
+				p.fail(tok, fmt.Sprintf("Invalid escape \\%c", escape))
+			}
+		default:
+			r, size := utf8.DecodeRuneInString(p.src[p.pos:])
+			b.WriteRune(r)
+			p.advance(size)
+		}
+	}
+}
+
+func isLetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// operation parses a query, mutation or subscription with its name and variables
+func (p *gqlParser) operation() *gqlOperation {
+	op := &gqlOperation{kind: p.expect(gqlName, "").value}
+	if p.peek(gqlName, "") {
+		op.name = p.expect(gqlName, "").value
+	}
+	if p.skip("(") {
+		for !p.skip(")") {
+			p.expect(gqlPunct, "$")
+			v := gqlVariable{name: p.expect(gqlName, "").value}
+			p.expect(gqlPunct, ":")
+			v.typ = p.typeRef()
+			if p.skip("=") {
+				v.defValue, v.hasDef = p.value(true), true
+			}
+			p.directives()
+			op.variables = append(op.variables, v)
+		}
+	}
+	p.directives()
+	op.selection = p.selectionSet()
+	return op
+}
+
+// typeRef parses a type such as [ID!]!, returned as written without spaces
+func (p *gqlParser) typeRef() string {
+	var typ string
+	if p.skip("[") {
+		typ = "[" + p.typeRef() + "]"
+		p.expect(gqlPunct, "]")
+	} else {
+		typ = p.expect(gqlName, "").value
+	}
+	if p.skip("!") {
+		typ += "!"
+	}
+	return typ
+}
+
+// selectionSet parses the fields and fragments between braces
+func (p *gqlParser) selectionSet() []gqlSelection {
+	p.expect(gqlPunct, "{")
+	var selections []gqlSelection
+	for !p.skip("}") {
+		tok := p.tok
+		if p.skip("...") {
+			sel := gqlSelection{line: tok.line, column: tok.column}
+			switch {
+			case p.peek(gqlName, "on"):
+				p.next()
+				sel.inline, sel.typeCondition = true, p.expect(gqlName, "").value
+				sel.directives = p.directives()
+				sel.selection = p.selectionSet()
+			case p.peek(gqlName, ""):
+				sel.fragment = p.expect(gqlName, "").value
+				sel.directives = p.directives()
+			default:
+				sel.inline = true
+				sel.directives = p.directives()
+				sel.selection = p.selectionSet()
+			}
+			selections = append(selections, sel)
+			continue
+		}
+
+		sel := gqlSelection{name: p.expect(gqlName, "").value, line: tok.line, column: tok.column}
+		if p.skip(":") {
+			sel.alias, sel.name = sel.name, p.expect(gqlName, "").value
+		}
+		sel.args = p.arguments(false)
+		sel.directives = p.directives()
+		if p.peek(gqlPunct, "{") {
+			sel.selection = p.selectionSet()
+		}
+		selections = append(selections, sel)
+	}
+	if len(selections) == 0 {
+		p.fail(p.tok, "Selection sets can't be empty")
+	}
+	return selections
+}
+
+// arguments parses an optional list of arguments
+func (p *gqlParser) arguments(constant bool) map[string]interface{} {
+	if !p.skip("(") {
+		return nil
+	}
+	args := map[string]interface{}{}
+	for !p.skip(")") {
+		tok := p.tok
+		name := p.expect(gqlName, "").value
+		if _, ok := args[name]; ok {
+			p.fail(tok, "Argument "+name+" is given twice")
+		}
+		p.expect(gqlPunct, ":")
+		args[name] = p.value(constant)
+	}
+	return args
+}
+
+// directives parses the directives applied to a definition or selection
+func (p *gqlParser) directives() []gqlDirective {
+	var directives []gqlDirective
+	for p.skip("@") {
+		directives = append(directives, gqlDirective{name: p.expect(gqlName, "").value, args: p.arguments(false)})
+	}
+	return directives
+}
+
+// value parses an argument value; constant values can't hold variables
+func (p *gqlParser) value(constant bool) interface{} {
+	tok := p.tok
+	switch {
+	case !constant && p.skip("$"):
+		return gqlVariableRef(p.expect(gqlName, "").value)
+	case p.skip("["):
+		list := []interface{}{}
+		for !p.skip("]") {
+			list = append(list, p.value(constant))
+		}
+		return list
+	case p.skip("{"):
+		object := map[string]interface{}{}
+		for !p.skip("}") {
+			name := p.expect(gqlName, "").value
+			p.expect(gqlPunct, ":")
+			object[name] = p.value(constant)
+		}
+		return object
+	}
+
+	p.next()
+	switch tok.kind {
+	case gqlInt:
+		n, err := strconv.ParseInt(tok.value, 10, 64)
+		if err != nil {
+			p.fail(tok, "Invalid integer "+tok.value)
+		}
+		return n
+	case gqlFloat:
+		f, err := strconv.ParseFloat(tok.value, 64)
+		if err != nil {
+			p.fail(tok, "Invalid number "+tok.value)
+		}
+		return f
+	case gqlString:
+		return tok.value
+	case gqlName:
+		switch tok.value {
+		case "true":
+			return true
+		case "false":
+			return false
+		case "null":
+			return nil
+		}
+		return gqlEnumValue(tok.value)
+	}
+	p.fail(tok, "Expected a value, found "+p.describe(tok))
+	return nil
+}
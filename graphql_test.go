@@ -0,0 +1,216 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGraphQL(t *testing.T) {
+	setupTestDB()
+	defer testDB.Close()
+	router := setupTestRouter()
+
+	testDB.Exec(`INSERT INTO surveys (title, description, questions) VALUES ('Feedback', 'd',
+		'[{"id": "color", "type": "text", "label": "Favorite color"}, {"id": "rating", "type": "rating", "label": "Rating", "required": true}]')`)
+	testDB.Exec("INSERT INTO surveys (title, description, status) VALUES ('Draft', 'd', 'draft')")
+	testDB.Exec(`INSERT INTO survey_responses (survey_id, user_identifier, response_data) VALUES (1, 'user1', '{"rating": 5, "color": "red"}')`)
+	testDB.Exec(`INSERT INTO survey_responses (survey_id, user_identifier, response_data) VALUES (1, 'user2', '{"rating": 3}')`)
+
+	query := func(req *http.Request) (int, string) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code, w.Body.String()
+	}
+	post := func(body GraphQLRequest) (int, string) {
+		b, _ := json.Marshal(body)
+		req, _ := http.NewRequest("POST", "/graphql", bytes.NewReader(b))
+		req.Header.Set("Content-Type", "application/json")
+		return query(req)
+	}
+
+	// Nested surveys, responses and answers come back in the shape asked for
+	code, body := post(GraphQLRequest{Query: `{
+		survey(id: 1) {
+			title
+			questions { id required }
+			responses(first: 1, order: "asc") {
+				nodes { userIdentifier answers { questionId value question { label } } }
+				nextCursor
+			}
+		}
+	}`})
+	assert.Equal(t, http.StatusOK, code)
+	var result struct {
+		Data struct {
+			Survey struct {
+				Title     string `json:"title"`
+				Questions []struct {
+					ID       string `json:"id"`
+					Required bool   `json:"required"`
+				} `json:"questions"`
+				Responses struct {
+					Nodes []struct {
+						UserIdentifier string `json:"userIdentifier"`
+						Answers        []struct {
+							QuestionID string      `json:"questionId"`
+							Value      interface{} `json:"value"`
+							Question   struct {
+								Label string `json:"label"`
+							} `json:"question"`
+						} `json:"answers"`
+					} `json:"nodes"`
+					NextCursor *string `json:"nextCursor"`
+				} `json:"responses"`
+			} `json:"survey"`
+		} `json:"data"`
+		Errors []gqlError `json:"errors"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(body), &result), body)
+	assert.Empty(t, result.Errors)
+	survey := result.Data.Survey
+	assert.Equal(t, "Feedback", survey.Title)
+	require.Len(t, survey.Questions, 2)
+	assert.True(t, survey.Questions[1].Required)
+	assert.False(t, survey.Questions[0].Required)
+	require.Len(t, survey.Responses.Nodes, 1)
+	response := survey.Responses.Nodes[0]
+	assert.Equal(t, "user1", response.UserIdentifier)
+	require.Len(t, response.Answers, 2)
+	assert.Equal(t, "color", response.Answers[0].QuestionID)
+	assert.Equal(t, "red", response.Answers[0].Value)
+	assert.Equal(t, "Favorite color", response.Answers[0].Question.Label)
+	assert.Equal(t, float64(5), response.Answers[1].Value)
+	require.NotNil(t, survey.Responses.NextCursor)
+	// Fields keep the order they were selected in
+	assert.Regexp(t, `^\{"data":\{"survey":\{"title":.*"questions":.*"responses":`, body)
+
+	// Variables, aliases, fragments, directives and GET requests
+	params := url.Values{
+		"query": {`query Pair($first: ID!, $skipTitle: Boolean = false) {
+			first: survey(id: $first) { ...Basics }
+			missing: survey(id: 999) { id }
+		}
+		fragment Basics on Survey { __typename id title @skip(if: $skipTitle) ... on Survey { comingSoon } }`},
+		"variables": {`{"first": 1, "skipTitle": true}`},
+	}
+	req, _ := http.NewRequest("GET", "/graphql?"+params.Encode(), nil)
+	code, body = query(req)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Contains(t, body, `"first":{"__typename":"Survey","id":"1","comingSoon":false}`)
+	assert.Contains(t, body, `"missing":null`)
+	assert.Contains(t, body, `"code":"SURVEY_NOT_FOUND"`)
+	assert.Contains(t, body, `"path":["missing"]`)
+
+	// Requests act with the caller's credentials
+	code, body = post(GraphQLRequest{Query: `{ surveys(status: "draft") { title } }`})
+	assert.Equal(t, http.StatusOK, code)
+	assert.Contains(t, body, `"data":null`)
+	assert.Contains(t, body, `"code":"ADMIN_REQUIRED"`)
+	b, _ := json.Marshal(GraphQLRequest{Query: `{ surveys(status: "draft") { title } }`})
+	code, body = query(adminRequest("POST", "/graphql", b))
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, `{"data":{"surveys":[{"title":"Draft"}]}}`, body)
+
+	// Documents that can't be executed are refused whole
+	for _, invalid := range []string{
+		`{ survey(id: 1) { title `,
+		`{ survey(id: 1) { secret } }`,
+		`{ survey { title } }`,
+		`{ survey(id: 1) }`,
+		`{ survey(id: $id) { title } }`,
+		`mutation { deleteSurvey(id: 1) }`,
+		`{ ...Loop } fragment Loop on Query { ...Loop }`,
+	} {
+		code, body = post(GraphQLRequest{Query: invalid})
+		assert.Equal(t, http.StatusBadRequest, code, invalid)
+		assert.Contains(t, body, `"code":"GRAPHQL_INVALID"`, invalid)
+	}
+	code, body = post(GraphQLRequest{Query: "{\n  survey(id: 1) { title ) }"})
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Contains(t, body, `"locations":[{"line":2,"column":25}]`)
+
+	// Queries can't fan out into unbounded API requests
+	defer func(max int) { graphQLMaxRequests = max }(graphQLMaxRequests)
+	graphQLMaxRequests = 2
+	code, body = post(GraphQLRequest{Query: `{ a: survey(id: 1) { id } b: survey(id: 2) { id } c: survey(id: 3) { id } }`})
+	assert.Equal(t, http.StatusOK, code)
+	assert.Contains(t, body, `"code":"GRAPHQL_TOO_COMPLEX"`)
+
+	req, _ = http.NewRequest("GET", "/graphql/schema", nil)
+	code, body = query(req)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Contains(t, body, "type Query {\n")
+	assert.Contains(t, body, "  responses(first: Int, after: String, sort: String, order: String): ResponsePage!\n")
+}
+
+func TestGraphQLFragmentBomb(t *testing.T) {
+	setupTestDB()
+	defer testDB.Close()
+	router := setupTestRouter()
+	testDB.Exec("INSERT INTO surveys (title, description) VALUES ('Feedback', 'd')")
+
+	post := func(query string) (int, string) {
+		b, _ := json.Marshal(GraphQLRequest{Query: query})
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/graphql", bytes.NewReader(b))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		return w.Code, w.Body.String()
+	}
+
+	// Each fragment spreads the previous one twice: 2^40 fields in well under 2 KB
+	var query strings.Builder
+	query.WriteString("{ survey(id: 1) { ...F40 } }\nfragment F0 on Survey { title }\n")
+	for i := 1; i <= 40; i++ {
+		fmt.Fprintf(&query, "fragment F%d on Survey { ...F%d ...F%d }\n", i, i-1, i-1)
+	}
+	start := time.Now()
+	code, body := post(query.String())
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Contains(t, body, "more than 1000 fields")
+	assert.Less(t, time.Since(start), time.Second)
+
+	// A fragment spread again deeper down still counts toward the depth limit
+	defer func(max int) { graphQLMaxDepth = max }(graphQLMaxDepth)
+	graphQLMaxDepth = 4
+	code, body = post(`{
+		survey(id: 1) { ...Deep }
+		response(surveyId: 1, id: 1) { survey { ...Deep } }
+	}
+	fragment Deep on Survey { responses { nodes { id } } }`)
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Contains(t, body, "nested deeper than 4 levels")
+
+	// Fragments spread a few times are fine
+	code, body = post(`{ survey(id: 1) { ...Basics } first: survey(id: 1) { ...Basics } } fragment Basics on Survey { id title }`)
+	assert.Equal(t, http.StatusOK, code, body)
+	assert.Contains(t, body, `"title":"Feedback"`)
+}
+
+func TestGraphQLRateLimit(t *testing.T) {
+	setupTestDB()
+	defer testDB.Close()
+	defer func(limit RateLimit) { apiRateLimit = limit }(apiRateLimit)
+	apiRateLimit = RateLimit{Requests: 1, Per: time.Hour}
+	router := setupTestRouter()
+
+	codes := []int{}
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/graphql/schema", nil)
+		req.RemoteAddr = "192.0.2.1:1234"
+		router.ServeHTTP(w, req)
+		codes = append(codes, w.Code)
+	}
+	assert.Equal(t, []int{http.StatusOK, http.StatusTooManyRequests}, codes)
+}
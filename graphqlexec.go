@@ -0,0 +1,796 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GraphQL limits. Every REST API request a query makes counts against the
+// API rate limit; GRAPHQL_MAX_REQUESTS caps them per query,
+// GRAPHQL_MAX_DEPTH caps how deeply fields can be nested and
+// GRAPHQL_MAX_FIELDS caps the fields selected once fragments are expanded.
+var (
+	graphQLMaxRequests = envInt("GRAPHQL_MAX_REQUESTS", 100)
+	graphQLMaxDepth    = envInt("GRAPHQL_MAX_DEPTH", 10)
+	graphQLMaxFields   = envInt("GRAPHQL_MAX_FIELDS", 1000)
+)
+
+// graphQLMaxBytes caps the size of GraphQL request bodies
+const graphQLMaxBytes = 64 << 10
+
+// graphQLForwardedHeaders are the headers passed on to the REST API, so
+// queries act with the caller's credentials, organization and address
+var graphQLForwardedHeaders = []string{"Authorization", organizationHeader, "X-Forwarded-For", "X-Real-Ip"}
+
+// GraphQLRequest is a GraphQL request, sent as the JSON body of a POST or the
+// query parameters of a GET (with variables as JSON)
+type GraphQLRequest struct {
+	Query         string                 `json:"query" binding:"required"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+// gqlError is an error in a GraphQL result, with the error code in its extensions
+type gqlError struct {
+	Message    string            `json:"message"`
+	Locations  []gqlLocation     `json:"locations,omitempty"`
+	Path       []interface{}     `json:"path,omitempty"`
+	Extensions map[string]string `json:"extensions,omitempty"`
+}
+
+type gqlLocation struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+// gqlResult is the result of a GraphQL request that was executed
+type gqlResult struct {
+	Data   interface{} `json:"data"`
+	Errors []gqlError  `json:"errors,omitempty"`
+}
+
+// gqlRequestErrors answers a GraphQL request that couldn't be executed
+type gqlRequestErrors struct {
+	Errors []gqlError `json:"errors"`
+}
+
+// gqlResultMap is a selection set's result, keeping the order of its fields
+type gqlResultMap struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+func (m *gqlResultMap) MarshalJSON() ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i, key := range m.keys {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		k, _ := json.Marshal(key)
+		v, err := json.Marshal(m.values[key])
+		if err != nil {
+			return nil, err
+		}
+		b.Write(k)
+		b.WriteByte(':')
+		b.Write(v)
+	}
+	b.WriteByte('}')
+	return b.Bytes(), nil
+}
+
+// gqlExecution is the execution of a query. Resolvers fetch the data through
+// the REST API with the caller's headers, each resource once per query.
+type gqlExecution struct {
+	ctx        context.Context
+	router     http.Handler
+	header     http.Header
+	remoteAddr string
+	doc        *gqlDocument
+	variables  map[string]interface{}
+	errors     []gqlError
+	fetched    map[string]*restResult
+}
+
+// fetch GETs a REST API resource, returning its decoded data and its meta
+func (e *gqlExecution) fetch(path string, query url.Values) (interface{}, json.RawMessage, error) {
+	call := restCall{method: "GET", path: path, query: query, header: e.header, remoteAddr: e.remoteAddr}
+	result, ok := e.fetched[call.target()]
+	if !ok {
+		if len(e.fetched) >= graphQLMaxRequests {
+			return nil, nil, &APIError{
+				Code:    CodeGraphQLTooComplex,
+				Message: fmt.Sprintf("Query needs more than %d API requests; split it up", graphQLMaxRequests),
+			}
+		}
+		var err error
+		if result, err = call.serve(e.ctx, e.router); err != nil {
+			return nil, nil, err
+		}
+		e.fetched[call.target()] = result
+	}
+	if !result.ok() {
+		return nil, nil, &APIError{Status: result.HTTPStatus, Code: result.Code, Message: result.Message, Errors: result.Errors}
+	}
+	var data interface{}
+	if err := json.Unmarshal(result.Data, &data); err != nil && len(result.Data) > 0 {
+		return nil, nil, err
+	}
+	return data, result.Meta, nil
+}
+
+// addError records an error at a field
+func (e *gqlExecution) addError(err error, path []interface{}, sel gqlSelection) {
+	gqlErr := gqlError{
+		Message:    "Internal error",
+		Locations:  []gqlLocation{{Line: sel.line, Column: sel.column}},
+		Path:       append([]interface{}{}, path...),
+		Extensions: map[string]string{"code": CodeInternal},
+	}
+	if apiErr, ok := err.(*APIError); ok {
+		gqlErr.Message = apiErr.Message
+		if len(apiErr.Errors) > 0 {
+			gqlErr.Message += ": " + strings.Join(apiErr.Errors, "; ")
+		}
+		gqlErr.Extensions["code"] = apiErr.Code
+	}
+	e.errors = append(e.errors, gqlErr)
+}
+
+// gqlInvalid is a request error
+func gqlInvalid(message string, sel *gqlSelection) gqlError {
+	err := gqlError{Message: message, Extensions: map[string]string{"code": CodeGraphQLInvalid}}
+	if sel != nil {
+		err.Locations = []gqlLocation{{Line: sel.line, Column: sel.column}}
+	}
+	return err
+}
+
+// namedType strips the list and non-null wrappers of a type
+func namedType(typ string) string {
+	return strings.Trim(typ, "[]!")
+}
+
+// selectOperation picks the operation to execute
+func selectOperation(doc *gqlDocument, name string) (*gqlOperation, error) {
+	if name == "" {
+		if len(doc.operations) > 1 {
+			return nil, fmt.Errorf("The document has several operations; set operationName")
+		}
+		return doc.operations[0], nil
+	}
+	for _, op := range doc.operations {
+		if op.name == name {
+			return op, nil
+		}
+	}
+	return nil, fmt.Errorf("Unknown operation %s", name)
+}
+
+// gqlValidator checks an operation against the schema before it is executed
+type gqlValidator struct {
+	doc       *gqlDocument
+	variables map[string]bool
+	errors    []gqlError
+	// spreading holds the fragments being expanded, to catch cycles
+	spreading map[string]bool
+	// fragments holds the size of the fragments validated so far, so each is
+	// validated once however often it is spread
+	fragments map[string]gqlSize
+}
+
+// gqlSize is the size of a selection set with its fragments expanded: the
+// fields selected, at most graphQLMaxFields+1, and how many levels of objects
+// they nest
+type gqlSize struct {
+	fields, height int
+}
+
+// add adds the size of a selection set selected alongside
+func (s *gqlSize) add(other gqlSize) {
+	s.fields += other.fields
+	if s.fields > graphQLMaxFields {
+		s.fields = graphQLMaxFields + 1
+	}
+	if other.height > s.height {
+		s.height = other.height
+	}
+}
+
+// validateGraphQL checks that an operation only selects fields of the
+// schema, with known arguments and declared variables, within the depth limit
+func validateGraphQL(doc *gqlDocument, op *gqlOperation) []gqlError {
+	v := &gqlValidator{doc: doc, variables: map[string]bool{}, spreading: map[string]bool{}, fragments: map[string]gqlSize{}}
+	if op.kind != "query" {
+		return []gqlError{gqlInvalid("Only queries are supported; make changes through the REST or gRPC API", nil)}
+	}
+	for _, variable := range op.variables {
+		if _, ok := gqlScalars[namedType(variable.typ)]; !ok {
+			v.errors = append(v.errors, gqlInvalid(fmt.Sprintf("Variable $%s has unknown type %s", variable.name, variable.typ), nil))
+		}
+		v.variables[variable.name] = true
+	}
+	if size := v.selectionSet(graphQLSchema["Query"], op.selection, 1); size.fields > graphQLMaxFields {
+		v.errors = append(v.errors, gqlInvalid(fmt.Sprintf("Query selects more than %d fields once its fragments are expanded", graphQLMaxFields), nil))
+	}
+	return v.errors
+}
+
+func (v *gqlValidator) fail(sel gqlSelection, format string, args ...interface{}) {
+	v.errors = append(v.errors, gqlInvalid(fmt.Sprintf(format, args...), &sel))
+}
+
+// values checks that an argument value only refers to declared variables
+func (v *gqlValidator) values(sel gqlSelection, value interface{}) {
+	switch value := value.(type) {
+	case gqlVariableRef:
+		if !v.variables[string(value)] {
+			v.fail(sel, "Variable $%s is not declared", value)
+		}
+	case []interface{}:
+		for _, item := range value {
+			v.values(sel, item)
+		}
+	case map[string]interface{}:
+		for _, item := range value {
+			v.values(sel, item)
+		}
+	}
+}
+
+func (v *gqlValidator) directives(sel gqlSelection) {
+	for _, d := range sel.directives {
+		if d.name != "include" && d.name != "skip" {
+			v.fail(sel, "Unknown directive @%s", d.name)
+			continue
+		}
+		if _, ok := d.args["if"]; !ok || len(d.args) != 1 {
+			v.fail(sel, "Directive @%s takes one argument, if", d.name)
+		}
+		for _, value := range d.args {
+			v.values(sel, value)
+		}
+	}
+}
+
+// selectionSet checks the selections of an object of type t nested depth
+// levels deep, returning their size
+func (v *gqlValidator) selectionSet(t *gqlObjectType, selections []gqlSelection, depth int) gqlSize {
+	var size gqlSize
+	for _, sel := range selections {
+		v.directives(sel)
+		switch {
+		case sel.fragment != "":
+			fragment, ok := v.doc.fragments[sel.fragment]
+			if !ok {
+				v.fail(sel, "Unknown fragment %s", sel.fragment)
+				continue
+			}
+			if fragment.typeCondition != t.name {
+				v.fail(sel, "Fragment %s on %s can't be spread on %s", sel.fragment, fragment.typeCondition, t.name)
+				continue
+			}
+			if v.spreading[sel.fragment] {
+				v.fail(sel, "Fragment %s spreads itself", sel.fragment)
+				continue
+			}
+			fragmentSize, validated := v.fragments[sel.fragment]
+			if !validated {
+				v.spreading[sel.fragment] = true
+				fragmentSize = v.selectionSet(t, fragment.selection, depth)
+				delete(v.spreading, sel.fragment)
+				v.fragments[sel.fragment] = fragmentSize
+			} else if fragmentSize.height > 0 && depth+fragmentSize.height-1 >= graphQLMaxDepth {
+				v.fail(sel, "Query is nested deeper than %d levels", graphQLMaxDepth)
+			}
+			size.add(fragmentSize)
+
+		case sel.inline:
+			if sel.typeCondition != "" && sel.typeCondition != t.name {
+				v.fail(sel, "Fragment on %s can't be spread on %s", sel.typeCondition, t.name)
+				continue
+			}
+			size.add(v.selectionSet(t, sel.selection, depth))
+
+		case sel.name == "__typename":
+			size.add(gqlSize{fields: 1})
+			if sel.selection != nil || len(sel.args) > 0 {
+				v.fail(sel, "Field __typename takes no arguments or selections")
+			}
+
+		default:
+			field := t.field(sel.name)
+			if field == nil {
+				v.fail(sel, "Cannot query field %s on type %s", sel.name, t.name)
+				continue
+			}
+			for name, value := range sel.args {
+				if field.arg(name) == nil {
+					v.fail(sel, "Unknown argument %s on field %s.%s", name, t.name, sel.name)
+				}
+				v.values(sel, value)
+			}
+			for _, arg := range field.args {
+				if _, ok := sel.args[arg.name]; !ok && strings.HasSuffix(arg.typ, "!") {
+					v.fail(sel, "Field %s.%s requires argument %s", t.name, sel.name, arg.name)
+				}
+			}
+			object := graphQLSchema[namedType(field.typ)]
+			fieldSize := gqlSize{fields: 1}
+			switch {
+			case object == nil && sel.selection != nil:
+				v.fail(sel, "Field %s.%s of type %s has no fields to select", t.name, sel.name, field.typ)
+			case object != nil && sel.selection == nil:
+				v.fail(sel, "Field %s.%s of type %s needs a selection of its fields", t.name, sel.name, field.typ)
+			case object != nil && depth >= graphQLMaxDepth:
+				v.fail(sel, "Query is nested deeper than %d levels", graphQLMaxDepth)
+			case object != nil:
+				fieldSize = v.selectionSet(object, sel.selection, depth+1)
+				fieldSize.height++
+				fieldSize.add(gqlSize{fields: 1})
+			}
+			size.add(fieldSize)
+		}
+	}
+	return size
+}
+
+// coerceInput converts an argument or variable value to a value of typ: Int
+// to int64, Float to float64, ID and String to string
+func coerceInput(typ string, value interface{}) (interface{}, error) {
+	if strings.HasSuffix(typ, "!") {
+		if value == nil {
+			return nil, fmt.Errorf("Expected a value of type %s, found null", typ)
+		}
+		return coerceInput(strings.TrimSuffix(typ, "!"), value)
+	}
+	if value == nil {
+		return nil, nil
+	}
+	if strings.HasPrefix(typ, "[") {
+		inner := typ[1 : len(typ)-1]
+		items, ok := value.([]interface{})
+		if !ok {
+			items = []interface{}{value}
+		}
+		list := make([]interface{}, len(items))
+		for i, item := range items {
+			coerced, err := coerceInput(inner, item)
+			if err != nil {
+				return nil, err
+			}
+			list[i] = coerced
+		}
+		return list, nil
+	}
+
+	invalid := fmt.Errorf("Expected a value of type %s", typ)
+	switch typ {
+	case "Int":
+		var n int64
+		switch value := value.(type) {
+		case int64:
+			n = value
+		case float64:
+			if value != math.Trunc(value) {
+				return nil, invalid
+			}
+			n = int64(value)
+		default:
+			return nil, invalid
+		}
+		if n < math.MinInt32 || n > math.MaxInt32 {
+			return nil, fmt.Errorf("Int %d is out of range", n)
+		}
+		return n, nil
+	case "Float":
+		switch value := value.(type) {
+		case int64:
+			return float64(value), nil
+		case float64:
+			return value, nil
+		}
+	case "String", "DateTime":
+		if s, ok := value.(string); ok {
+			return s, nil
+		}
+	case "ID":
+		switch value := value.(type) {
+		case string:
+			return value, nil
+		case int64:
+			return strconv.FormatInt(value, 10), nil
+		case float64:
+			if value == math.Trunc(value) {
+				return strconv.FormatFloat(value, 'f', -1, 64), nil
+			}
+		}
+	case "Boolean":
+		if b, ok := value.(bool); ok {
+			return b, nil
+		}
+	case "JSON":
+		return value, nil
+	}
+	return nil, invalid
+}
+
+// coerceVariables applies the defaults and types of an operation's variables
+func coerceVariables(op *gqlOperation, given map[string]interface{}) (map[string]interface{}, []gqlError) {
+	variables := map[string]interface{}{}
+	var errs []gqlError
+	for _, v := range op.variables {
+		value, ok := given[v.name]
+		if !ok && v.hasDef {
+			value, ok = v.defValue, true
+		}
+		if !ok && !strings.HasSuffix(v.typ, "!") {
+			continue
+		}
+		coerced, err := coerceInput(v.typ, value)
+		if err != nil {
+			errs = append(errs, gqlInvalid(fmt.Sprintf("Variable $%s: %v", v.name, err), nil))
+			continue
+		}
+		variables[v.name] = coerced
+	}
+	return variables, errs
+}
+
+// resolveValue replaces the variables in an argument value
+func (e *gqlExecution) resolveValue(value interface{}) interface{} {
+	switch value := value.(type) {
+	case gqlVariableRef:
+		return e.variables[string(value)]
+	case []interface{}:
+		list := make([]interface{}, len(value))
+		for i, item := range value {
+			list[i] = e.resolveValue(item)
+		}
+		return list
+	case map[string]interface{}:
+		object := map[string]interface{}{}
+		for key, item := range value {
+			object[key] = e.resolveValue(item)
+		}
+		return object
+	}
+	return value
+}
+
+// included applies the @include and @skip directives of a selection
+func (e *gqlExecution) included(sel gqlSelection) bool {
+	for _, d := range sel.directives {
+		condition, _ := e.resolveValue(d.args["if"]).(bool)
+		if (d.name == "include") != condition {
+			return false
+		}
+	}
+	return true
+}
+
+// gqlFieldGroup is the selections of a response key, merged
+type gqlFieldGroup struct {
+	key        string
+	selections []gqlSelection
+}
+
+// collectFields expands the fragments of a selection set, grouping the
+// fields by response key in the order they first appear
+func (e *gqlExecution) collectFields(selections []gqlSelection, groups []*gqlFieldGroup) []*gqlFieldGroup {
+	for _, sel := range selections {
+		if !e.included(sel) {
+			continue
+		}
+		switch {
+		case sel.fragment != "":
+			groups = e.collectFields(e.doc.fragments[sel.fragment].selection, groups)
+		case sel.inline:
+			groups = e.collectFields(sel.selection, groups)
+		default:
+			var group *gqlFieldGroup
+			for _, g := range groups {
+				if g.key == sel.responseKey() {
+					group = g
+				}
+			}
+			if group == nil {
+				group = &gqlFieldGroup{key: sel.responseKey()}
+				groups = append(groups, group)
+			}
+			group.selections = append(group.selections, sel)
+		}
+	}
+	return groups
+}
+
+// executeSelection resolves the selected fields of an object. It returns nil
+// when a non-null field is null, nulling the object.
+func (e *gqlExecution) executeSelection(t *gqlObjectType, parent map[string]interface{}, selections []gqlSelection, path []interface{}) *gqlResultMap {
+	result := &gqlResultMap{values: map[string]interface{}{}}
+	for _, group := range e.collectFields(selections, nil) {
+		sel := group.selections[0]
+		result.keys = append(result.keys, group.key)
+		if sel.name == "__typename" {
+			result.values[group.key] = t.name
+			continue
+		}
+		field := t.field(sel.name)
+		fieldPath := append(append([]interface{}{}, path...), group.key)
+
+		var subselection []gqlSelection
+		for _, s := range group.selections {
+			subselection = append(subselection, s.selection...)
+		}
+		value, err := e.resolveField(field, parent, sel)
+		if err != nil {
+			e.addError(err, fieldPath, sel)
+			value = nil
+		} else {
+			value, _ = e.complete(field.typ, value, subselection, fieldPath, sel)
+		}
+		if value == nil && strings.HasSuffix(field.typ, "!") {
+			return nil
+		}
+		result.values[group.key] = value
+	}
+	return result
+}
+
+// resolveField coerces a field's arguments and resolves its value
+func (e *gqlExecution) resolveField(field *gqlFieldDef, parent map[string]interface{}, sel gqlSelection) (interface{}, error) {
+	args := map[string]interface{}{}
+	for _, arg := range field.args {
+		value, ok := sel.args[arg.name]
+		if !ok {
+			continue
+		}
+		coerced, err := coerceInput(arg.typ, e.resolveValue(value))
+		if err != nil {
+			return nil, &APIError{Code: CodeGraphQLInvalid, Message: fmt.Sprintf("Argument %s: %v", arg.name, err)}
+		}
+		if coerced != nil {
+			args[arg.name] = coerced
+		}
+	}
+	if field.resolve != nil {
+		return field.resolve(e, parent, args)
+	}
+	return parent[snakeCase(field.name)], nil
+}
+
+// complete converts a resolved value to the field's type, resolving the
+// selections of objects. nulled reports a null caused by an error in a
+// non-null field within the value, which was already recorded.
+func (e *gqlExecution) complete(typ string, value interface{}, selections []gqlSelection, path []interface{}, sel gqlSelection) (result interface{}, nulled bool) {
+	if strings.HasSuffix(typ, "!") {
+		inner := strings.TrimSuffix(typ, "!")
+		result, nulled := e.complete(inner, value, selections, path, sel)
+		if result == nil && !nulled {
+			e.addError(fmt.Errorf("null for non-null field %s", path[len(path)-1]), path, sel)
+		}
+		return result, result == nil
+	}
+	if value == nil {
+		return nil, false
+	}
+
+	if strings.HasPrefix(typ, "[") {
+		inner := typ[1 : len(typ)-1]
+		items, ok := value.([]interface{})
+		if !ok {
+			e.addError(fmt.Errorf("expected a list for %s, got %T", typ, value), path, sel)
+			return nil, true
+		}
+		list := make([]interface{}, len(items))
+		for i, item := range items {
+			completed, _ := e.complete(inner, item, selections, append(append([]interface{}{}, path...), i), sel)
+			if completed == nil && strings.HasSuffix(inner, "!") {
+				return nil, true
+			}
+			list[i] = completed
+		}
+		return list, false
+	}
+
+	if object := graphQLSchema[typ]; object != nil {
+		parent, ok := value.(map[string]interface{})
+		if !ok {
+			e.addError(fmt.Errorf("expected an object for %s, got %T", typ, value), path, sel)
+			return nil, true
+		}
+		if result := e.executeSelection(object, parent, selections, path); result != nil {
+			return result, false
+		}
+		return nil, true
+	}
+
+	coerced, err := coerceOutput(typ, value)
+	if err != nil {
+		e.addError(err, path, sel)
+		return nil, true
+	}
+	return coerced, false
+}
+
+// coerceOutput converts a scalar from the REST API's JSON to its GraphQL type
+func coerceOutput(typ string, value interface{}) (interface{}, error) {
+	switch typ {
+	case "ID":
+		switch value := value.(type) {
+		case float64:
+			return strconv.FormatFloat(value, 'f', -1, 64), nil
+		case string:
+			return value, nil
+		}
+	case "Int":
+		if n, ok := value.(float64); ok && n == math.Trunc(n) {
+			return int64(n), nil
+		}
+	case "Float":
+		if _, ok := value.(float64); ok {
+			return value, nil
+		}
+	case "String", "DateTime":
+		if _, ok := value.(string); ok {
+			return value, nil
+		}
+	case "Boolean":
+		if _, ok := value.(bool); ok {
+			return value, nil
+		}
+	case "JSON":
+		return value, nil
+	}
+	return nil, fmt.Errorf("expected %s, got %T", typ, value)
+}
+
+// snakeCase converts a field name to its REST API JSON key, e.g. responsesCount to responses_count
+func snakeCase(name string) string {
+	var b strings.Builder
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if c >= 'A' && c <= 'Z' {
+			b.WriteByte('_')
+			c += 'a' - 'A'
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
+
+// readGraphQLRequest reads a GraphQL request from a POST body or GET query parameters
+func readGraphQLRequest(c *gin.Context) (GraphQLRequest, error) {
+	var req GraphQLRequest
+	if c.Request.Method == http.MethodGet {
+		req.Query = c.Query("query")
+		req.OperationName = c.Query("operationName")
+		if variables := c.Query("variables"); variables != "" {
+			if err := json.Unmarshal([]byte(variables), &req.Variables); err != nil {
+				return req, fmt.Errorf("Variables must be a JSON object")
+			}
+		}
+		if req.Query == "" {
+			return req, fmt.Errorf("Query is required")
+		}
+		return req, nil
+	}
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, graphQLMaxBytes)
+	if err := c.ShouldBindJSON(&req); err != nil {
+		return req, fmt.Errorf("Body must be a JSON object with a query")
+	}
+	return req, nil
+}
+
+// serveGraphQL executes GraphQL queries over the REST API served by router.
+// Requests that can't be executed are answered 400 with their errors; others
+// 200 with their data and the errors of the fields that failed.
+func serveGraphQL(router http.Handler) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		req, err := readGraphQLRequest(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gqlRequestErrors{Errors: []gqlError{gqlInvalid(err.Error(), nil)}})
+			return
+		}
+		doc, err := parseGraphQL(req.Query)
+		if err != nil {
+			gqlErr := gqlInvalid(err.Error(), nil)
+			if syntaxErr, ok := err.(*gqlSyntaxError); ok {
+				gqlErr.Message = syntaxErr.message
+				gqlErr.Locations = []gqlLocation{{Line: syntaxErr.line, Column: syntaxErr.column}}
+			}
+			c.JSON(http.StatusBadRequest, gqlRequestErrors{Errors: []gqlError{gqlErr}})
+			return
+		}
+		op, err := selectOperation(doc, req.OperationName)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gqlRequestErrors{Errors: []gqlError{gqlInvalid(err.Error(), nil)}})
+			return
+		}
+		if errs := validateGraphQL(doc, op); len(errs) > 0 {
+			c.JSON(http.StatusBadRequest, gqlRequestErrors{Errors: errs})
+			return
+		}
+		variables, errs := coerceVariables(op, req.Variables)
+		if len(errs) > 0 {
+			c.JSON(http.StatusBadRequest, gqlRequestErrors{Errors: errs})
+			return
+		}
+
+		e := &gqlExecution{
+			ctx:        c.Request.Context(),
+			router:     router,
+			header:     http.Header{},
+			remoteAddr: c.Request.RemoteAddr,
+			doc:        doc,
+			variables:  variables,
+			fetched:    map[string]*restResult{},
+		}
+		for _, name := range graphQLForwardedHeaders {
+			if value := c.GetHeader(name); value != "" {
+				e.header.Set(name, value)
+			}
+		}
+		result := gqlResult{}
+		if data := e.executeSelection(graphQLSchema["Query"], nil, op.selection, nil); data != nil {
+			result.Data = data
+		}
+		result.Errors = e.errors
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+// serveGraphQLSchema serves the schema in the GraphQL schema language
+func serveGraphQLSchema(c *gin.Context) {
+	c.Data(http.StatusOK, "text/plain; charset=utf-8", []byte(graphQLSchemaSDL()))
+}
+
+// graphQLSchemaSDL writes the schema in the GraphQL schema language
+func graphQLSchemaSDL() string {
+	var b strings.Builder
+	description := func(indent, text string) {
+		if text != "" {
+			fmt.Fprintf(&b, "%s\"\"\"%s\"\"\"\n", indent, text)
+		}
+	}
+	scalars := make([]string, 0, len(gqlScalars))
+	for name := range gqlScalars {
+		scalars = append(scalars, name)
+	}
+	sort.Strings(scalars)
+	for _, name := range scalars {
+		if gqlScalars[name] != "" {
+			description("", gqlScalars[name])
+			fmt.Fprintf(&b, "scalar %s\n\n", name)
+		}
+	}
+	for _, t := range graphQLTypes {
+		description("", t.description)
+		fmt.Fprintf(&b, "type %s {\n", t.name)
+		for _, field := range t.fields {
+			description("  ", field.description)
+			fmt.Fprintf(&b, "  %s", field.name)
+			if len(field.args) > 0 {
+				args := make([]string, len(field.args))
+				for i, arg := range field.args {
+					args[i] = arg.name + ": " + arg.typ
+				}
+				fmt.Fprintf(&b, "(%s)", strings.Join(args, ", "))
+			}
+			fmt.Fprintf(&b, ": %s\n", field.typ)
+		}
+		b.WriteString("}\n\n")
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
@@ -0,0 +1,294 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+)
+
+// gqlObjectType is an object type of the GraphQL schema
+type gqlObjectType struct {
+	name        string
+	description string
+	fields      []*gqlFieldDef
+}
+
+// field returns the field named name, or nil
+func (t *gqlObjectType) field(name string) *gqlFieldDef {
+	for _, f := range t.fields {
+		if f.name == name {
+			return f
+		}
+	}
+	return nil
+}
+
+// gqlResolver resolves a field's value on its parent object, the REST API's
+// JSON for it, with the field's coerced arguments
+type gqlResolver func(e *gqlExecution, parent map[string]interface{}, args map[string]interface{}) (interface{}, error)
+
+// gqlFieldDef is a field of an object type. Fields without a resolver read
+// the parent's key of the same name in snake_case.
+type gqlFieldDef struct {
+	name        string
+	typ         string
+	description string
+	args        []gqlArgDef
+	resolve     gqlResolver
+}
+
+// arg returns the argument named name, or nil
+func (f *gqlFieldDef) arg(name string) *gqlArgDef {
+	for i := range f.args {
+		if f.args[i].name == name {
+			return &f.args[i]
+		}
+	}
+	return nil
+}
+
+// gqlArgDef is an argument of a field
+type gqlArgDef struct {
+	name string
+	typ  string
+}
+
+// gqlScalars are the schema's scalar types, with the description of the custom ones
+var gqlScalars = map[string]string{
+	"ID":       "",
+	"String":   "",
+	"Int":      "",
+	"Float":    "",
+	"Boolean":  "",
+	"DateTime": "An RFC 3339 timestamp",
+	"JSON":     "Any JSON value, such as an answer",
+}
+
+// graphQLTypes are the object types of the schema, in the order of the SDL
+var graphQLTypes = []*gqlObjectType{
+	{name: "Query", fields: []*gqlFieldDef{
+		{name: "survey", typ: "Survey", description: "A survey, like GET /api/surveys/{id}",
+			args: []gqlArgDef{{"id", "ID!"}}, resolve: resolveSurvey},
+		{name: "surveys", typ: "[Survey!]!", description: "Surveys, like GET /api/surveys",
+			args:    []gqlArgDef{{"q", "String"}, {"status", "String"}, {"sort", "String"}, {"order", "String"}, {"all", "Boolean"}},
+			resolve: resolveSurveys},
+		{name: "response", typ: "Response", description: "A response, like GET /api/surveys/{id}/responses/{response_id}",
+			args: []gqlArgDef{{"surveyId", "ID!"}, {"id", "ID!"}}, resolve: resolveResponse},
+	}},
+	{name: "Survey", description: "Drafts fetched by callers who can't see them only have id, globalId, status, publishAt and comingSoon", fields: []*gqlFieldDef{
+		{name: "id", typ: "ID!"},
+		{name: "globalId", typ: "ID"},
+		{name: "title", typ: "String"},
+		{name: "description", typ: "String"},
+		{name: "status", typ: "String!"},
+		{name: "publishAt", typ: "DateTime"},
+		{name: "allowMultipleResponses", typ: "Boolean"},
+		{name: "anonymous", typ: "Boolean"},
+		{name: "editWindowMinutes", typ: "Int"},
+		{name: "opensAt", typ: "DateTime"},
+		{name: "closesAt", typ: "DateTime"},
+		{name: "maxResponses", typ: "Int"},
+		{name: "organizationId", typ: "ID"},
+		{name: "createdAt", typ: "DateTime"},
+		{name: "updatedAt", typ: "DateTime"},
+		{name: "responsesCount", typ: "Int"},
+		{name: "acceptingResponses", typ: "Boolean"},
+		{name: "comingSoon", typ: "Boolean!", resolve: resolveFlag("coming_soon")},
+		{name: "questions", typ: "[Question!]!", resolve: resolveList("questions")},
+		{name: "question", typ: "Question", args: []gqlArgDef{{"id", "String!"}}, resolve: resolveSurveyQuestion},
+		{name: "pages", typ: "[Page!]!", resolve: resolveList("pages")},
+		{name: "responses", typ: "ResponsePage!", description: "A page of responses, like GET /api/surveys/{id}/responses",
+			args:    []gqlArgDef{{"first", "Int"}, {"after", "String"}, {"sort", "String"}, {"order", "String"}},
+			resolve: resolveSurveyResponses},
+		{name: "response", typ: "Response", args: []gqlArgDef{{"id", "ID!"}}, resolve: resolveSurveyResponse},
+	}},
+	{name: "Question", fields: []*gqlFieldDef{
+		{name: "id", typ: "String!"},
+		{name: "type", typ: "String!"},
+		{name: "label", typ: "String!"},
+		{name: "required", typ: "Boolean!", resolve: resolveFlag("required")},
+		{name: "options", typ: "[String!]"},
+		{name: "min", typ: "Float"},
+		{name: "max", typ: "Float"},
+		{name: "page", typ: "String"},
+	}},
+	{name: "Page", fields: []*gqlFieldDef{
+		{name: "id", typ: "String!"},
+		{name: "title", typ: "String"},
+	}},
+	{name: "ResponsePage", fields: []*gqlFieldDef{
+		{name: "nodes", typ: "[Response!]!"},
+		{name: "nextCursor", typ: "String", description: "The after argument of the next page; null on the last page"},
+	}},
+	{name: "Response", fields: []*gqlFieldDef{
+		{name: "id", typ: "ID!"},
+		{name: "globalId", typ: "ID"},
+		{name: "surveyId", typ: "ID!"},
+		{name: "userIdentifier", typ: "String"},
+		{name: "createdAt", typ: "DateTime!"},
+		{name: "updatedAt", typ: "DateTime!"},
+		{name: "editable", typ: "Boolean!"},
+		{name: "responseData", typ: "JSON", description: "The answers by question ID"},
+		{name: "answers", typ: "[Answer!]!", description: "The answers, ordered by question ID", resolve: resolveAnswers},
+		{name: "answer", typ: "Answer", args: []gqlArgDef{{"questionId", "String!"}}, resolve: resolveAnswer},
+		{name: "metadata", typ: "ResponseMetadata"},
+		{name: "survey", typ: "Survey", resolve: resolveResponseSurvey},
+	}},
+	{name: "Answer", fields: []*gqlFieldDef{
+		{name: "questionId", typ: "String!"},
+		{name: "value", typ: "JSON"},
+		{name: "question", typ: "Question", description: "The question answered, unless it was removed from the survey", resolve: resolveAnswerQuestion},
+	}},
+	{name: "ResponseMetadata", fields: []*gqlFieldDef{
+		{name: "channel", typ: "String"},
+		{name: "country", typ: "String"},
+		{name: "device", typ: "String"},
+		{name: "moderationStatus", typ: "String"},
+		{name: "botScore", typ: "Float"},
+		{name: "warnings", typ: "[String!]"},
+		{name: "qualityFlags", typ: "[String!]"},
+		{name: "completionSeconds", typ: "Int"},
+		{name: "reviewedAt", typ: "DateTime"},
+	}},
+}
+
+// graphQLSchema indexes graphQLTypes by name
+var graphQLSchema = func() map[string]*gqlObjectType {
+	schema := map[string]*gqlObjectType{}
+	for _, t := range graphQLTypes {
+		schema[t.name] = t
+	}
+	return schema
+}()
+
+// idPath formats an ID argument, or a JSON number ID, as a path segment
+func idPath(id interface{}) string {
+	if n, ok := id.(float64); ok {
+		return strconv.FormatFloat(n, 'f', -1, 64)
+	}
+	return url.PathEscape(fmt.Sprint(id))
+}
+
+// queryArgs sets the string arguments given as query parameters
+func queryArgs(args map[string]interface{}, params map[string]string) url.Values {
+	query := url.Values{}
+	for arg, param := range params {
+		if value, ok := args[arg]; ok {
+			query.Set(param, fmt.Sprint(value))
+		}
+	}
+	return query
+}
+
+// resolveFlag reads a boolean that the REST API leaves out when false
+func resolveFlag(key string) gqlResolver {
+	return func(e *gqlExecution, parent map[string]interface{}, args map[string]interface{}) (interface{}, error) {
+		flag, _ := parent[key].(bool)
+		return flag, nil
+	}
+}
+
+// resolveList reads a list that the REST API leaves out when empty
+func resolveList(key string) gqlResolver {
+	return func(e *gqlExecution, parent map[string]interface{}, args map[string]interface{}) (interface{}, error) {
+		if list, ok := parent[key].([]interface{}); ok {
+			return list, nil
+		}
+		return []interface{}{}, nil
+	}
+}
+
+func resolveSurvey(e *gqlExecution, parent map[string]interface{}, args map[string]interface{}) (interface{}, error) {
+	survey, _, err := e.fetch("/api/surveys/"+idPath(args["id"]), nil)
+	return survey, err
+}
+
+func resolveSurveys(e *gqlExecution, parent map[string]interface{}, args map[string]interface{}) (interface{}, error) {
+	query := queryArgs(args, map[string]string{"q": "q", "status": "status", "sort": "sort", "order": "order", "all": "all"})
+	surveys, _, err := e.fetch("/api/surveys", query)
+	return surveys, err
+}
+
+func resolveResponse(e *gqlExecution, parent map[string]interface{}, args map[string]interface{}) (interface{}, error) {
+	response, _, err := e.fetch(fmt.Sprintf("/api/surveys/%s/responses/%s", idPath(args["surveyId"]), idPath(args["id"])), nil)
+	return response, err
+}
+
+func resolveSurveyQuestion(e *gqlExecution, parent map[string]interface{}, args map[string]interface{}) (interface{}, error) {
+	return findQuestionJSON(parent, args["id"].(string)), nil
+}
+
+// findQuestionJSON finds a question in a survey's JSON, or returns nil
+func findQuestionJSON(survey map[string]interface{}, id string) interface{} {
+	questions, _ := survey["questions"].([]interface{})
+	for _, question := range questions {
+		if q, ok := question.(map[string]interface{}); ok && q["id"] == id {
+			return q
+		}
+	}
+	return nil
+}
+
+func resolveSurveyResponses(e *gqlExecution, parent map[string]interface{}, args map[string]interface{}) (interface{}, error) {
+	query := queryArgs(args, map[string]string{"first": "limit", "after": "cursor", "sort": "sort", "order": "order"})
+	responses, meta, err := e.fetch("/api/surveys/"+idPath(parent["id"])+"/responses", query)
+	if err != nil {
+		return nil, err
+	}
+	page := map[string]interface{}{"nodes": responses}
+	var pageMeta PageMeta
+	if len(meta) > 0 && json.Unmarshal(meta, &pageMeta) == nil && pageMeta.NextCursor != "" {
+		page["next_cursor"] = pageMeta.NextCursor
+	}
+	return page, nil
+}
+
+func resolveSurveyResponse(e *gqlExecution, parent map[string]interface{}, args map[string]interface{}) (interface{}, error) {
+	response, _, err := e.fetch(fmt.Sprintf("/api/surveys/%s/responses/%s", idPath(parent["id"]), idPath(args["id"])), nil)
+	return response, err
+}
+
+// answerJSON is an answer of a response, with the survey ID to find its question
+func answerJSON(response map[string]interface{}, questionID string, value interface{}) map[string]interface{} {
+	return map[string]interface{}{"question_id": questionID, "value": value, "survey_id": response["survey_id"]}
+}
+
+func resolveAnswers(e *gqlExecution, parent map[string]interface{}, args map[string]interface{}) (interface{}, error) {
+	data, _ := parent["response_data"].(map[string]interface{})
+	ids := make([]string, 0, len(data))
+	for id := range data {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	answers := make([]interface{}, len(ids))
+	for i, id := range ids {
+		answers[i] = answerJSON(parent, id, data[id])
+	}
+	return answers, nil
+}
+
+func resolveAnswer(e *gqlExecution, parent map[string]interface{}, args map[string]interface{}) (interface{}, error) {
+	data, _ := parent["response_data"].(map[string]interface{})
+	questionID := args["questionId"].(string)
+	value, ok := data[questionID]
+	if !ok {
+		return nil, nil
+	}
+	return answerJSON(parent, questionID, value), nil
+}
+
+func resolveResponseSurvey(e *gqlExecution, parent map[string]interface{}, args map[string]interface{}) (interface{}, error) {
+	survey, _, err := e.fetch("/api/surveys/"+idPath(parent["survey_id"]), nil)
+	return survey, err
+}
+
+func resolveAnswerQuestion(e *gqlExecution, parent map[string]interface{}, args map[string]interface{}) (interface{}, error) {
+	survey, _, err := e.fetch("/api/surveys/"+idPath(parent["survey_id"]), nil)
+	if err != nil {
+		return nil, err
+	}
+	surveyJSON, _ := survey.(map[string]interface{})
+	return findQuestionJSON(surveyJSON, parent["question_id"].(string)), nil
+}
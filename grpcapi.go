@@ -0,0 +1,267 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/emptypb"
+
+	"survey_form_go/surveypb"
+)
+
+//go:generate protoc -I proto --go_out=. --go_opt=module=survey_form_go --go-grpc_out=. --go-grpc_opt=module=survey_form_go proto/survey/v1/survey.proto
+
+// grpcListenAddr is the address the gRPC API listens on; it is off unless set
+var grpcListenAddr = envString("GRPC_ADDR", "")
+
+// grpcForwardedMetadata are the metadata keys passed on to the REST API as headers
+var grpcForwardedMetadata = []string{"authorization", strings.ToLower(organizationHeader)}
+
+// grpcErrorDomain is the ErrorInfo domain of the gRPC API's errors
+const grpcErrorDomain = "survey_form_go"
+
+// surveyService implements surveypb.SurveyService over the REST API: each
+// call is served in-process by its REST route, so both APIs share the
+// storage, validation, access rules, rate limits and events
+type surveyService struct {
+	surveypb.UnimplementedSurveyServiceServer
+	router http.Handler
+}
+
+// newGRPCServer returns a gRPC server with the survey service serving calls through router
+func newGRPCServer(router http.Handler) *grpc.Server {
+	s := grpc.NewServer()
+	surveypb.RegisterSurveyServiceServer(s, &surveyService{router: router})
+	return s
+}
+
+// serve runs a REST call with the credentials in the call's metadata,
+// unmarshaling its data into out unless out is nil, and returns its meta.
+// wrap, if set, names the field of out the data goes into.
+func (s *surveyService) serve(ctx context.Context, call restCall, out proto.Message, wrap string) (json.RawMessage, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if call.header == nil {
+		call.header = http.Header{}
+	}
+	for _, key := range grpcForwardedMetadata {
+		if values := md.Get(key); len(values) > 0 {
+			call.header.Set(key, values[0])
+		}
+	}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		call.remoteAddr = p.Addr.String()
+	}
+
+	result, err := call.serve(ctx, s.router)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	if !result.ok() {
+		return nil, grpcError(result.HTTPStatus, result.APIResponse)
+	}
+	if out != nil {
+		data := result.Data
+		if wrap != "" {
+			data, _ = json.Marshal(map[string]json.RawMessage{wrap: data})
+		}
+		if err := (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal(data, out); err != nil {
+			return nil, status.Errorf(codes.Internal, "failed to convert response: %v", err)
+		}
+	}
+	return result.Meta, nil
+}
+
+// grpcCode maps a REST API status to the gRPC code closest to it
+func grpcCode(httpStatus int) codes.Code {
+	switch httpStatus {
+	case http.StatusBadRequest, http.StatusUnprocessableEntity:
+		return codes.InvalidArgument
+	case http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusNotFound:
+		return codes.NotFound
+	case http.StatusConflict:
+		return codes.Aborted
+	case http.StatusTooManyRequests:
+		return codes.ResourceExhausted
+	case http.StatusServiceUnavailable:
+		return codes.Unavailable
+	case http.StatusGatewayTimeout:
+		return codes.DeadlineExceeded
+	}
+	return codes.Internal
+}
+
+// grpcError converts a REST API error, keeping its code as the reason of an ErrorInfo detail
+func grpcError(httpStatus int, resp APIResponse) error {
+	message := resp.Message
+	if len(resp.Errors) > 0 {
+		message += ": " + strings.Join(resp.Errors, "; ")
+	}
+	st := status.New(grpcCode(httpStatus), message)
+	if resp.Code == "" {
+		return st.Err()
+	}
+	info := &errdetails.ErrorInfo{Reason: resp.Code, Domain: grpcErrorDomain}
+	if resp.RequestID != "" {
+		info.Metadata = map[string]string{"request_id": resp.RequestID}
+	}
+	if detailed, err := st.WithDetails(info); err == nil {
+		return detailed.Err()
+	}
+	return st.Err()
+}
+
+// protoBody marshals a request message to a JSON object with the REST API's field names
+func protoBody(m proto.Message) (json.RawMessage, error) {
+	b, err := (protojson.MarshalOptions{UseProtoNames: true}).Marshal(m)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid request: %v", err)
+	}
+	return b, nil
+}
+
+func surveyPath(id int64) string {
+	return "/api/surveys/" + strconv.FormatInt(id, 10)
+}
+
+func responsePath(surveyID, id int64) string {
+	return fmt.Sprintf("/api/surveys/%d/responses/%d", surveyID, id)
+}
+
+func (s *surveyService) ListSurveys(ctx context.Context, req *surveypb.ListSurveysRequest) (*surveypb.ListSurveysResponse, error) {
+	query := url.Values{}
+	for key, value := range map[string]string{"q": req.Q, "status": req.Status, "sort": req.Sort, "order": req.Order} {
+		if value != "" {
+			query.Set(key, value)
+		}
+	}
+	if req.All {
+		query.Set("all", "true")
+	}
+	out := &surveypb.ListSurveysResponse{}
+	_, err := s.serve(ctx, restCall{method: "GET", path: "/api/surveys", query: query}, out, "surveys")
+	return out, err
+}
+
+func (s *surveyService) GetSurvey(ctx context.Context, req *surveypb.GetSurveyRequest) (*surveypb.Survey, error) {
+	out := &surveypb.Survey{}
+	_, err := s.serve(ctx, restCall{method: "GET", path: surveyPath(req.Id)}, out, "")
+	return out, err
+}
+
+func (s *surveyService) CreateSurvey(ctx context.Context, req *surveypb.CreateSurveyRequest) (*surveypb.Survey, error) {
+	survey, err := protoBody(req)
+	if err != nil {
+		return nil, err
+	}
+	out := &surveypb.Survey{}
+	_, err = s.serve(ctx, restCall{method: "POST", path: "/api/surveys", body: map[string]json.RawMessage{"survey": survey}}, out, "")
+	return out, err
+}
+
+func (s *surveyService) PublishSurvey(ctx context.Context, req *surveypb.PublishSurveyRequest) (*surveypb.Survey, error) {
+	out := &surveypb.Survey{}
+	_, err := s.serve(ctx, restCall{method: "POST", path: surveyPath(req.Id) + "/publish"}, out, "")
+	return out, err
+}
+
+func (s *surveyService) DeleteSurvey(ctx context.Context, req *surveypb.DeleteSurveyRequest) (*emptypb.Empty, error) {
+	query := url.Values{}
+	if req.Cascade {
+		query.Set("mode", SurveyDeleteModeCascade)
+	}
+	path := "/api/admin/surveys/" + strconv.FormatInt(req.Id, 10)
+	if _, err := s.serve(ctx, restCall{method: "DELETE", path: path, query: query}, nil, ""); err != nil {
+		return nil, err
+	}
+	return &emptypb.Empty{}, nil
+}
+
+func (s *surveyService) ListResponses(ctx context.Context, req *surveypb.ListResponsesRequest) (*surveypb.ListResponsesResponse, error) {
+	query := url.Values{}
+	for key, value := range map[string]string{"cursor": req.Cursor, "sort": req.Sort, "order": req.Order} {
+		if value != "" {
+			query.Set(key, value)
+		}
+	}
+	if req.Limit != 0 {
+		query.Set("limit", strconv.Itoa(int(req.Limit)))
+	}
+	out := &surveypb.ListResponsesResponse{}
+	raw, err := s.serve(ctx, restCall{method: "GET", path: surveyPath(req.SurveyId) + "/responses", query: query}, out, "responses")
+	if err != nil {
+		return nil, err
+	}
+	var meta PageMeta
+	if len(raw) > 0 {
+		json.Unmarshal(raw, &meta)
+	}
+	out.NextCursor = meta.NextCursor
+	return out, nil
+}
+
+func (s *surveyService) GetResponse(ctx context.Context, req *surveypb.GetResponseRequest) (*surveypb.SurveyResponse, error) {
+	out := &surveypb.SurveyResponse{}
+	_, err := s.serve(ctx, restCall{method: "GET", path: responsePath(req.SurveyId, req.Id)}, out, "")
+	return out, err
+}
+
+func (s *surveyService) CreateResponse(ctx context.Context, req *surveypb.CreateResponseRequest) (*surveypb.SurveyResponse, error) {
+	submission := proto.Clone(req).(*surveypb.CreateResponseRequest)
+	submission.SurveyId, submission.IdempotencyKey = 0, ""
+	response, err := protoBody(submission)
+	if err != nil {
+		return nil, err
+	}
+	call := restCall{
+		method: "POST",
+		path:   surveyPath(req.SurveyId) + "/responses",
+		body:   map[string]json.RawMessage{"survey_response": response},
+		header: http.Header{},
+	}
+	if req.IdempotencyKey != "" {
+		call.header.Set(idempotencyKeyHeader, req.IdempotencyKey)
+	}
+	out := &surveypb.SurveyResponse{}
+	_, err = s.serve(ctx, call, out, "")
+	return out, err
+}
+
+func (s *surveyService) UpdateResponse(ctx context.Context, req *surveypb.UpdateResponseRequest) (*surveypb.SurveyResponse, error) {
+	update := &surveypb.UpdateResponseRequest{ResponseData: req.ResponseData}
+	response, err := protoBody(update)
+	if err != nil {
+		return nil, err
+	}
+	call := restCall{
+		method: "PATCH",
+		path:   responsePath(req.SurveyId, req.Id),
+		body:   map[string]json.RawMessage{"survey_response": response},
+	}
+	out := &surveypb.SurveyResponse{}
+	_, err = s.serve(ctx, call, out, "")
+	return out, err
+}
+
+func (s *surveyService) DeleteResponse(ctx context.Context, req *surveypb.DeleteResponseRequest) (*emptypb.Empty, error) {
+	if _, err := s.serve(ctx, restCall{method: "DELETE", path: responsePath(req.SurveyId, req.Id)}, nil, ""); err != nil {
+		return nil, err
+	}
+	return &emptypb.Empty{}, nil
+}
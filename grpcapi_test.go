@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"survey_form_go/surveypb"
+)
+
+func TestGRPCAPI(t *testing.T) {
+	setupTestDB()
+	defer testDB.Close()
+	adminToken = "test-admin-token"
+
+	lis := bufconn.Listen(1 << 20)
+	srv := newGRPCServer(setupTestRouter())
+	go srv.Serve(lis)
+	defer srv.Stop()
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer conn.Close()
+	client := surveypb.NewSurveyServiceClient(conn)
+	ctx := context.Background()
+
+	question, _ := structpb.NewStruct(map[string]interface{}{"id": "color", "type": "text", "label": "Favorite color"})
+	survey, err := client.CreateSurvey(ctx, &surveypb.CreateSurveyRequest{
+		Title:        "Poll",
+		Description:  "d",
+		MaxResponses: proto.Int32(10),
+		Questions:    []*structpb.Struct{question},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "Poll", survey.Title)
+	assert.True(t, survey.AllowMultipleResponses)
+	assert.Equal(t, int32(10), survey.GetMaxResponses())
+	assert.Nil(t, survey.EditWindowMinutes)
+	require.Len(t, survey.Questions, 1)
+	assert.Equal(t, "color", survey.Questions[0].Fields["id"].GetStringValue())
+	assert.NotEmpty(t, survey.GlobalId)
+
+	got, err := client.GetSurvey(ctx, &surveypb.GetSurveyRequest{Id: survey.Id})
+	require.NoError(t, err)
+	assert.Equal(t, survey.GlobalId, got.GlobalId)
+	list, err := client.ListSurveys(ctx, &surveypb.ListSurveysRequest{Q: "Poll"})
+	require.NoError(t, err)
+	require.Len(t, list.Surveys, 1)
+
+	// Validation and not found errors keep the REST API's error codes
+	_, err = client.CreateSurvey(ctx, &surveypb.CreateSurveyRequest{Description: "d"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	_, err = client.GetSurvey(ctx, &surveypb.GetSurveyRequest{Id: 999})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	require.Len(t, status.Convert(err).Details(), 1)
+	assert.Equal(t, CodeSurveyNotFound, status.Convert(err).Details()[0].(*errdetails.ErrorInfo).Reason)
+
+	answers, _ := structpb.NewStruct(map[string]interface{}{"color": "red"})
+	create := &surveypb.CreateResponseRequest{SurveyId: survey.Id, UserIdentifier: "user1", ResponseData: answers, IdempotencyKey: "key-1"}
+	response, err := client.CreateResponse(ctx, create)
+	require.NoError(t, err)
+	assert.Equal(t, "red", response.ResponseData.Fields["color"].GetStringValue())
+	assert.True(t, response.Editable)
+	retried, err := client.CreateResponse(ctx, create)
+	require.NoError(t, err)
+	assert.Equal(t, response.Id, retried.Id)
+
+	answers, _ = structpb.NewStruct(map[string]interface{}{"color": "blue"})
+	updated, err := client.UpdateResponse(ctx, &surveypb.UpdateResponseRequest{SurveyId: survey.Id, Id: response.Id, ResponseData: answers})
+	require.NoError(t, err)
+	assert.Equal(t, "blue", updated.ResponseData.Fields["color"].GetStringValue())
+	got2, err := client.GetResponse(ctx, &surveypb.GetResponseRequest{SurveyId: survey.Id, Id: response.Id})
+	require.NoError(t, err)
+	assert.Equal(t, "blue", got2.ResponseData.Fields["color"].GetStringValue())
+
+	_, err = client.CreateResponse(ctx, &surveypb.CreateResponseRequest{SurveyId: survey.Id, UserIdentifier: "user2", ResponseData: answers})
+	require.NoError(t, err)
+	page, err := client.ListResponses(ctx, &surveypb.ListResponsesRequest{SurveyId: survey.Id, Limit: 1})
+	require.NoError(t, err)
+	require.Len(t, page.Responses, 1)
+	require.NotEmpty(t, page.NextCursor)
+	page, err = client.ListResponses(ctx, &surveypb.ListResponsesRequest{SurveyId: survey.Id, Limit: 1, Cursor: page.NextCursor})
+	require.NoError(t, err)
+	require.Len(t, page.Responses, 1)
+
+	_, err = client.DeleteResponse(ctx, &surveypb.DeleteResponseRequest{SurveyId: survey.Id, Id: response.Id})
+	require.NoError(t, err)
+	_, err = client.GetResponse(ctx, &surveypb.GetResponseRequest{SurveyId: survey.Id, Id: response.Id})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	// Drafts are published like in the REST API
+	draft, err := client.CreateSurvey(ctx, &surveypb.CreateSurveyRequest{Title: "Draft", Description: "d", Status: SurveyStatusDraft, Questions: []*structpb.Struct{question}})
+	require.NoError(t, err)
+	published, err := client.PublishSurvey(ctx, &surveypb.PublishSurveyRequest{Id: draft.Id})
+	require.NoError(t, err)
+	assert.Equal(t, SurveyStatusPublished, published.Status)
+
+	// Admin operations take the admin token as authorization metadata
+	_, err = client.DeleteSurvey(ctx, &surveypb.DeleteSurveyRequest{Id: survey.Id, Cascade: true})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	admin := metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+adminToken)
+	_, err = client.DeleteSurvey(admin, &surveypb.DeleteSurveyRequest{Id: survey.Id})
+	assert.Equal(t, codes.Aborted, status.Code(err))
+	_, err = client.DeleteSurvey(admin, &surveypb.DeleteSurveyRequest{Id: survey.Id, Cascade: true})
+	require.NoError(t, err)
+	_, err = client.GetSurvey(ctx, &surveypb.GetSurveyRequest{Id: survey.Id})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
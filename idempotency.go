@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"regexp"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mattn/go-sqlite3"
+)
+
+// idempotencyKeyHeader lets clients retry a submission without creating it twice
+const idempotencyKeyHeader = "Idempotency-Key"
+
+// idempotencyKeyPattern accepts UUIDs and other keys of visible ASCII characters
+var idempotencyKeyPattern = regexp.MustCompile(`^[\x21-\x7e]{1,255}$`)
+
+// idempotencyKey reads the Idempotency-Key of a submission and fingerprints its
+// body, so a key reused for a different submission can be told apart from a
+// retry. The body is left for binding.
+func idempotencyKey(c *gin.Context) (key, fingerprint string, err error) {
+	key = c.GetHeader(idempotencyKeyHeader)
+	if key == "" {
+		return "", "", nil
+	}
+	body, err := c.GetRawData()
+	if err != nil {
+		return "", "", err
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+	sum := sha256.Sum256(body)
+	return key, hex.EncodeToString(sum[:]), nil
+}
+
+// isUniqueViolation reports whether err is SQLite rejecting a duplicate in a unique index
+func isUniqueViolation(err error) bool {
+	var sqliteErr sqlite3.Error
+	return errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique
+}
+
+// replaySubmission answers a submission whose Idempotency-Key was already used
+// with the response it created, and reports whether it did. A key used for a
+// different submission is rejected.
+func replaySubmission(c *gin.Context, surveyID int, key, fingerprint string) bool {
+	ctx := c.Request.Context()
+	var id int
+	var storedFingerprint string
+	var deleted bool
+	err := db.QueryRowContext(ctx, `
+		SELECT id, idempotency_fingerprint, deleted_at IS NOT NULL
+		FROM survey_responses
+		WHERE survey_id = ? AND idempotency_key = ?
+	`, surveyID, key).Scan(&id, &storedFingerprint, &deleted)
+	if err == sql.ErrNoRows {
+		return false
+	}
+	if err != nil {
+		abortWithError(c, errInternal("Failed to submit survey response", err))
+		return true
+	}
+
+	if storedFingerprint != fingerprint {
+		abortWithError(c, &APIError{
+			Status:  http.StatusUnprocessableEntity,
+			Code:    CodeIdempotencyKeyReused,
+			Message: "Idempotency key was already used for a different submission",
+		})
+		return true
+	}
+	if deleted {
+		abortWithError(c, errNotFound(CodeResponseNotFound, "Survey response was deleted"))
+		return true
+	}
+
+	response, err := queryResponse(ctx, db, id)
+	if err != nil {
+		abortWithError(c, errInternal("Failed to fetch response", err))
+		return true
+	}
+	c.Header("Idempotent-Replayed", "true")
+	c.JSON(http.StatusCreated, APIResponse{
+		Status:  "success",
+		Message: "Survey response submitted successfully",
+		Data:    response,
+	})
+	return true
+}
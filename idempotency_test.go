@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIdempotentSubmission(t *testing.T) {
+	setupTestDB()
+	defer testDB.Close()
+
+	result, err := testDB.Exec("INSERT INTO surveys (title, description, max_responses) VALUES ('Mobile', 'd', 1)")
+	assert.NoError(t, err)
+	surveyID, _ := result.LastInsertId()
+	router := setupTestRouter()
+
+	submit := func(key, body string) (*httptest.ResponseRecorder, SurveyResponse) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", fmt.Sprintf("/api/surveys/%d/responses", surveyID), bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.RemoteAddr = "192.0.2.1:1234"
+		if key != "" {
+			req.Header.Set("Idempotency-Key", key)
+		}
+		router.ServeHTTP(w, req)
+		var response struct {
+			Data SurveyResponse `json:"data"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		return w, response.Data
+	}
+	body := `{"survey_response":{"user_identifier":"phone1","response_data":{"rating":5}}}`
+
+	w, first := submit("3f2b6c1e-key", body)
+	assert.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	assert.Empty(t, w.Header().Get("Idempotent-Replayed"))
+
+	// The retry returns the same response, although the survey is now full
+	w, retried := submit("3f2b6c1e-key", body)
+	assert.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	assert.Equal(t, "true", w.Header().Get("Idempotent-Replayed"))
+	assert.Equal(t, first.ID, retried.ID)
+	var count int
+	testDB.QueryRow("SELECT COUNT(*) FROM survey_responses").Scan(&count)
+	assert.Equal(t, 1, count)
+
+	// The key can't be reused for another submission
+	w, _ = submit("3f2b6c1e-key", `{"survey_response":{"user_identifier":"phone2","response_data":{"rating":1}}}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Contains(t, w.Body.String(), CodeIdempotencyKeyReused)
+
+	w, _ = submit("bad key", body)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+	// Without a key the quota applies as usual
+	w, _ = submit("", body)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	testDB.Exec("UPDATE survey_responses SET deleted_at = CURRENT_TIMESTAMP")
+	w, _ = submit("3f2b6c1e-key", body)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestIsUniqueViolation(t *testing.T) {
+	setupTestDB()
+	defer testDB.Close()
+
+	testDB.Exec("INSERT INTO surveys (title, description) VALUES ('Unique', 'd')")
+	insert := "INSERT INTO survey_responses (survey_id, user_identifier, response_data, idempotency_key) VALUES (1, 'u', '{}', 'k')"
+	_, err := testDB.Exec(insert)
+	assert.NoError(t, err)
+	_, err = testDB.Exec(insert)
+	assert.True(t, isUniqueViolation(err))
+	assert.False(t, isUniqueViolation(nil))
+}
@@ -0,0 +1,325 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/mail"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Inbound email settings: invitations to single-question surveys can be
+// answered by replying to reply+<token>@INBOUND_EMAIL_DOMAIN, whose mail the
+// email provider posts to /inbound/email with INBOUND_EMAIL_SECRET. The
+// gateway is off unless both are set.
+var (
+	inboundEmailDomain = strings.ToLower(envString("INBOUND_EMAIL_DOMAIN", ""))
+	inboundEmailSecret = envString("INBOUND_EMAIL_SECRET", "")
+)
+
+// Outcomes of an inbound email
+const (
+	InboundRecorded = "recorded"
+	InboundIgnored  = "ignored"
+)
+
+// InboundEmail is a message posted by the email provider, as JSON or as the
+// form fields of SendGrid's Inbound Parse
+type InboundEmail struct {
+	From    string `json:"from" form:"from"`
+	To      string `json:"to" form:"to"`
+	Subject string `json:"subject" form:"subject"`
+	Text    string `json:"text" form:"text"`
+}
+
+// InboundEmailResult reports what became of an inbound email. Ignored emails
+// are answered 200 too, so providers don't retry them.
+type InboundEmailResult struct {
+	Outcome    string `json:"outcome"`
+	Reason     string `json:"reason,omitempty"`
+	ResponseID int    `json:"response_id,omitempty"`
+}
+
+// inboundReplyPattern matches a reply address, capturing its token and domain
+var inboundReplyPattern = regexp.MustCompile(`(?i)reply\+([0-9a-f]{32})@([a-z0-9.-]+)`)
+
+// replyQuoteStart matches the line above the quoted message in a reply, such
+// as "On Mon, Jan 15, 2024, Surveys wrote:" or Outlook's original message header
+var replyQuoteStart = regexp.MustCompile(`^(>|On .*wrote:$|-- ?$|-----\s*Original Message|_{10,}|From: )`)
+
+// inboundEmailEnabled reports whether replies to invitations are answered
+func inboundEmailEnabled() bool {
+	return inboundEmailDomain != "" && inboundEmailSecret != ""
+}
+
+// inboundReplyAddress is the address replies to the email carrying token go to
+func inboundReplyAddress(token string) string {
+	return "reply+" + token + "@" + inboundEmailDomain
+}
+
+// inboundToken finds the token of the reply address among the recipients
+func inboundToken(to string) string {
+	for _, m := range inboundReplyPattern.FindAllStringSubmatch(to, -1) {
+		if strings.EqualFold(m[2], inboundEmailDomain) {
+			return strings.ToLower(m[1])
+		}
+	}
+	return ""
+}
+
+// emailQuestion returns the question of a survey answerable by replying to
+// its invitation: the only one
+func emailQuestion(survey Survey) (Question, bool) {
+	if len(survey.Questions) != 1 {
+		return Question{}, false
+	}
+	return survey.Questions[0], true
+}
+
+// emailAnswerInstructions tells respondents how to answer q by email
+func emailAnswerInstructions(q Question) string {
+	var text strings.Builder
+	switch q.Type {
+	case QuestionNumber:
+		text.WriteString("Reply with a number.")
+	case QuestionRating:
+		min, max := float64(defaultRatingMin), float64(defaultRatingMax)
+		if q.Min != nil {
+			min = *q.Min
+		}
+		if q.Max != nil {
+			max = *q.Max
+		}
+		fmt.Fprintf(&text, "Reply with a number from %g to %g.", min, max)
+	case QuestionBoolean:
+		text.WriteString("Reply with yes or no.")
+	case QuestionSingleChoice:
+		text.WriteString("Reply with one of these options, or its number:")
+	case QuestionMultipleChoice:
+		text.WriteString("Reply with the options you choose, or their numbers, separated by commas:")
+	default:
+		text.WriteString("Reply with your answer.")
+	}
+	for i, option := range q.Options {
+		fmt.Fprintf(&text, "\n  %d. %s", i+1, option)
+	}
+	return text.String()
+}
+
+// replyText returns the new text of a reply, without the quoted message and
+// signature below it
+func replyText(text string) string {
+	var lines []string
+	for _, line := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		if replyQuoteStart.MatchString(strings.TrimSpace(line)) {
+			break
+		}
+		lines = append(lines, line)
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+// emailChoice matches a reply to one of the options of q, by text or number
+func emailChoice(q Question, reply string) (string, error) {
+	reply = strings.Trim(strings.TrimSpace(reply), ".!")
+	if n, err := strconv.Atoi(reply); err == nil && n >= 1 && n <= len(q.Options) {
+		return q.Options[n-1], nil
+	}
+	for _, option := range q.Options {
+		if strings.EqualFold(option, reply) {
+			return option, nil
+		}
+	}
+	return "", fmt.Errorf("%q is not one of the options", reply)
+}
+
+// parseEmailAnswer reads the answer to q in the text of a reply. Text answers
+// are the whole reply; other answers are read from its first line.
+func parseEmailAnswer(q Question, text string) (interface{}, error) {
+	reply := replyText(text)
+	if reply == "" {
+		return nil, errors.New("the reply has no answer")
+	}
+	if q.Type == QuestionText {
+		return reply, nil
+	}
+	line := strings.TrimSpace(strings.SplitN(reply, "\n", 2)[0])
+
+	switch q.Type {
+	case QuestionNumber, QuestionRating:
+		fields := strings.Fields(line)
+		n, err := strconv.ParseFloat(strings.Trim(fields[0], ".!"), 64)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a number", fields[0])
+		}
+		return n, nil
+	case QuestionBoolean:
+		switch strings.ToLower(strings.Trim(strings.Fields(line)[0], ".,!")) {
+		case "yes", "y", "true":
+			return true, nil
+		case "no", "n", "false":
+			return false, nil
+		}
+		return nil, fmt.Errorf("%q is neither yes nor no", line)
+	case QuestionSingleChoice:
+		return emailChoice(q, line)
+	case QuestionMultipleChoice:
+		var choices []string
+		for _, item := range strings.FieldsFunc(line, func(r rune) bool { return r == ',' || r == ';' }) {
+			if strings.TrimSpace(item) == "" {
+				continue
+			}
+			choice, err := emailChoice(q, item)
+			if err != nil {
+				return nil, err
+			}
+			if !containsString(choices, choice) {
+				choices = append(choices, choice)
+			}
+		}
+		if len(choices) == 0 {
+			return nil, errors.New("the reply has no answer")
+		}
+		return choices, nil
+	}
+	return nil, fmt.Errorf("%s questions can't be answered by email", q.Type)
+}
+
+// inboundEmailAuthorized checks the secret the provider sends, as the
+// password of basic authentication or as a bearer token
+func inboundEmailAuthorized(r *http.Request) bool {
+	secret, ok := "", false
+	if _, password, basic := r.BasicAuth(); basic {
+		secret, ok = password, true
+	} else if token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "); token != r.Header.Get("Authorization") {
+		secret, ok = token, true
+	}
+	return ok && subtle.ConstantTimeCompare([]byte(secret), []byte(inboundEmailSecret)) == 1
+}
+
+// sendAnswerHelp answers a reply that couldn't be recorded with how to answer
+func sendAnswerHelp(c *gin.Context, survey Survey, q Question, inv Invitation, token, problem string) {
+	text := fmt.Sprintf("We couldn't record your answer to \"%s\": %s.\n\n%s\n%s\n", survey.Title, problem, q.Label, emailAnswerInstructions(q))
+	email := Email{To: inv.Email, Subject: "Re: " + survey.Title, Text: text, ReplyTo: inboundReplyAddress(token)}
+	if err := mailer.Send(c.Request.Context(), email); err != nil {
+		log.Printf("Inbound email: failed to answer invitation %d: %v", inv.ID, err)
+	}
+}
+
+// receiveInboundEmail records a reply to an invitation email as a response,
+// submitting it through router as the invitation's form would. Replies that
+// can't be read get an email explaining how to answer; rate-limited and
+// failed submissions are answered with their error status, for the provider
+// to retry them.
+func receiveInboundEmail(router http.Handler) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		if !inboundEmailEnabled() {
+			c.AbortWithStatus(http.StatusNotFound)
+			return
+		}
+		if !inboundEmailAuthorized(c.Request) {
+			abortWithError(c, &APIError{
+				Status:  http.StatusUnauthorized,
+				Code:    CodeInvalidInboundSecret,
+				Message: "Invalid inbound email secret",
+			})
+			return
+		}
+
+		var msg InboundEmail
+		if err := c.ShouldBind(&msg); err != nil {
+			abortWithError(c, errInvalidRequest(err))
+			return
+		}
+
+		ignore := func(reason string) {
+			log.Printf("Inbound email: ignored a message from %q: %s", msg.From, reason)
+			c.JSON(http.StatusOK, APIResponse{
+				Status:  "success",
+				Message: "Email ignored",
+				Data:    InboundEmailResult{Outcome: InboundIgnored, Reason: reason},
+			})
+		}
+
+		token := inboundToken(msg.To)
+		if token == "" {
+			ignore("No reply address")
+			return
+		}
+		inv, err := findInvitationByToken(ctx, token)
+		if err != nil {
+			ignore("Invitation not found")
+			return
+		}
+		if from, err := mail.ParseAddress(msg.From); err != nil || !strings.EqualFold(from.Address, inv.Email) {
+			ignore("Sender isn't the invited address")
+			return
+		}
+		if inv.RespondedAt != nil {
+			ignore("Invitation was already answered")
+			return
+		}
+		survey, err := findSurvey(ctx, inv.SurveyID)
+		if err != nil {
+			ignore("Survey not found")
+			return
+		}
+		q, ok := emailQuestion(survey)
+		if !ok {
+			ignore("Survey can't be answered by email")
+			return
+		}
+
+		answer, err := parseEmailAnswer(q, msg.Text)
+		if err != nil {
+			sendAnswerHelp(c, survey, q, inv, token, err.Error())
+			ignore(err.Error())
+			return
+		}
+		data, _ := json.Marshal(map[string]interface{}{q.ID: answer})
+		call := restCall{
+			method: http.MethodPost,
+			path:   fmt.Sprintf("/api/surveys/%d/responses", survey.ID),
+			body: map[string]interface{}{"survey_response": map[string]interface{}{
+				"invitation_token": token,
+				"response_data":    json.RawMessage(data),
+				"metadata":         map[string]string{"channel": "email"},
+			}},
+			remoteAddr: c.Request.RemoteAddr,
+		}
+		result, err := call.serve(ctx, router)
+		if err != nil {
+			abortWithError(c, errInternal("Failed to record reply", err))
+			return
+		}
+
+		switch {
+		case result.ok():
+			var response struct {
+				ID int `json:"id"`
+			}
+			json.Unmarshal(result.Data, &response)
+			c.JSON(http.StatusOK, APIResponse{
+				Status:  "success",
+				Message: "Reply recorded",
+				Data:    InboundEmailResult{Outcome: InboundRecorded, ResponseID: response.ID},
+			})
+		case result.HTTPStatus == http.StatusTooManyRequests || result.HTTPStatus >= 500:
+			abortWithError(c, &APIError{Status: result.HTTPStatus, Code: result.Code, Message: result.Message})
+		case result.Code == CodeValidation:
+			problem := strings.Join(result.Errors, "; ")
+			sendAnswerHelp(c, survey, q, inv, token, problem)
+			ignore(problem)
+		default:
+			ignore(result.Message)
+		}
+	}
+}
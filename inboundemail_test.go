@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInboundEmail(t *testing.T) {
+	setupTestDB()
+	defer testDB.Close()
+	router := setupTestRouter()
+	defer func(m Mailer, domain, secret string) {
+		mailer, inboundEmailDomain, inboundEmailSecret = m, domain, secret
+	}(mailer, inboundEmailDomain, inboundEmailSecret)
+	sent := &recordingMailer{}
+	mailer = sent
+
+	testDB.Exec(`INSERT INTO surveys (title, description, status, questions) VALUES ('Lunch', 'd', 'published',
+		'[{"id":"lunch","type":"single_choice","label":"What should we order?","options":["Pizza","Sushi"]}]')`)
+	testDB.Exec("INSERT INTO survey_invitations (survey_id, email, status) VALUES (1, 'ana@example.com', 'pending'), (1, 'ben@example.com', 'pending')")
+
+	post := func(form url.Values, secret string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", "/inbound/email", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.SetBasicAuth("inbound", secret)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	outcome := func(w *httptest.ResponseRecorder) InboundEmailResult {
+		var body struct {
+			Data InboundEmailResult `json:"data"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body), w.Body.String())
+		return body.Data
+	}
+
+	// The gateway is off until configured
+	inboundEmailDomain, inboundEmailSecret = "", ""
+	assert.Equal(t, http.StatusNotFound, post(url.Values{}, "").Code)
+	inboundEmailDomain, inboundEmailSecret = "reply.example.com", "s3cret"
+	assert.Equal(t, http.StatusUnauthorized, post(url.Values{}, "wrong").Code)
+
+	// Invitations to single-question surveys can be answered by replying
+	_, err := sendPendingInvitations(context.Background(), time.Now())
+	require.NoError(t, err)
+	require.Len(t, sent.sent, 2)
+	invite := sent.sent[0]
+	token := invitationLink.FindStringSubmatch(invite.Text)[1]
+	assert.Equal(t, "reply+"+token+"@reply.example.com", invite.ReplyTo)
+	assert.Contains(t, invite.Text, "What should we order?\nReply with one of these options, or its number:\n  1. Pizza\n  2. Sushi")
+	reply := url.Values{"from": {"Ana <Ana@Example.com>"}, "to": {"Surveys <" + invite.ReplyTo + ">"}, "subject": {"Re: " + invite.Subject}}
+
+	// Replies must come from the invited address
+	reply.Set("from", "eve@example.com")
+	reply.Set("text", "2")
+	w := post(reply, "s3cret")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, InboundEmailResult{Outcome: InboundIgnored, Reason: "Sender isn't the invited address"}, outcome(w))
+	reply.Set("from", "Ana <Ana@Example.com>")
+
+	// Unreadable replies are answered with instructions
+	reply.Set("text", "Tacos please\n\nOn Mon, Jan 15, 2024, Surveys wrote:\n> What should we order?")
+	w = post(reply, "s3cret")
+	assert.Equal(t, InboundIgnored, outcome(w).Outcome)
+	require.Len(t, sent.sent, 3)
+	assert.Equal(t, "ana@example.com", sent.sent[2].To)
+	assert.Contains(t, sent.sent[2].Text, `"Tacos please" is not one of the options`)
+	assert.Equal(t, invite.ReplyTo, sent.sent[2].ReplyTo)
+
+	reply.Set("text", "sushi!\n\n-- \nAna")
+	w = post(reply, "s3cret")
+	result := outcome(w)
+	assert.Equal(t, InboundRecorded, result.Outcome, w.Body.String())
+	response, err := queryResponse(context.Background(), testDB, result.ResponseID)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"lunch": "Sushi"}`, string(response.ResponseData))
+	assert.Equal(t, "ana@example.com", response.UserIdentifier)
+	assert.Equal(t, "email", response.Metadata.Channel)
+	var status string
+	testDB.QueryRow("SELECT status FROM survey_invitations WHERE id = 1").Scan(&status)
+	assert.Equal(t, InvitationResponded, status)
+
+	// Providers redelivering a reply don't record it twice
+	w = post(reply, "s3cret")
+	assert.Equal(t, InboundEmailResult{Outcome: InboundIgnored, Reason: "Invitation was already answered"}, outcome(w))
+	assert.Len(t, sent.sent, 3)
+
+	// Providers posting JSON are accepted too, with a bearer secret
+	body, _ := json.Marshal(InboundEmail{From: "ben@example.com", To: sent.sent[1].ReplyTo, Text: "1"})
+	req, _ := http.NewRequest("POST", "/inbound/email", strings.NewReader(string(body)))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer s3cret")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, InboundRecorded, outcome(w).Outcome, w.Body.String())
+}
+
+func TestParseEmailAnswer(t *testing.T) {
+	ten := 10.0
+	for _, tc := range []struct {
+		question Question
+		text     string
+		answer   interface{}
+	}{
+		{Question{Type: QuestionText}, "Great week.\nThanks!\n\n> quoted", "Great week.\nThanks!"},
+		{Question{Type: QuestionRating, Max: &ten}, "8 out of 10\r\nCheers", 8.0},
+		{Question{Type: QuestionNumber}, "3.5.", 3.5},
+		{Question{Type: QuestionBoolean}, "Yes, of course", true},
+		{Question{Type: QuestionBoolean}, "no.", false},
+		{Question{Type: QuestionMultipleChoice, Options: []string{"Mon", "Tue", "Wed"}}, "mon, 3; Mon", []string{"Mon", "Wed"}},
+	} {
+		answer, err := parseEmailAnswer(tc.question, tc.text)
+		assert.NoError(t, err, tc.text)
+		assert.Equal(t, tc.answer, answer, tc.text)
+	}
+
+	for _, tc := range []struct {
+		question Question
+		text     string
+	}{
+		{Question{Type: QuestionText}, "\n> only a quote"},
+		{Question{Type: QuestionNumber}, "many"},
+		{Question{Type: QuestionBoolean}, "maybe"},
+		{Question{Type: QuestionSingleChoice, Options: []string{"A"}}, "2"},
+		{Question{Type: QuestionMultipleChoice, Options: []string{"A"}}, ", ;"},
+	} {
+		_, err := parseEmailAnswer(tc.question, tc.text)
+		assert.Error(t, err, tc.text)
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRESTCallServe(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/echo", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status": "success",
+			"data":   json.RawMessage(body),
+			"meta":   map[string]string{"query": r.URL.RawQuery, "content_type": r.Header.Get("Content-Type"), "remote_addr": r.RemoteAddr},
+		})
+	})
+	mux.HandleFunc("/created", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`{"status":"success"}`))
+	})
+	mux.HandleFunc("/empty", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	mux.HandleFunc("/text", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("not json"))
+	})
+
+	ctx := context.Background()
+	call := restCall{method: "POST", path: "/echo", query: map[string][]string{"a": {"1"}}, body: map[string]int{"x": 1}, remoteAddr: "192.0.2.1:1234"}
+	result, err := call.serve(ctx, mux)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, result.HTTPStatus)
+	assert.True(t, result.ok())
+	assert.JSONEq(t, `{"x":1}`, string(result.Data))
+	assert.JSONEq(t, `{"query":"a=1","content_type":"application/json","remote_addr":"192.0.2.1:1234"}`, string(result.Meta))
+
+	// The first status written is kept
+	result, err = restCall{method: "POST", path: "/created"}.serve(ctx, mux)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusCreated, result.HTTPStatus)
+
+	result, err = restCall{method: "DELETE", path: "/empty"}.serve(ctx, mux)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNoContent, result.HTTPStatus)
+
+	_, err = restCall{method: "GET", path: "/text"}.serve(ctx, mux)
+	assert.EqualError(t, err, "unexpected response (HTTP 200)")
+}
@@ -0,0 +1,455 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"net/mail"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Invitation statuses, in the order an invitation moves through them
+const (
+	InvitationPending   = "pending"
+	InvitationSent      = "sent"
+	InvitationFailed    = "failed"
+	InvitationOpened    = "opened"
+	InvitationResponded = "responded"
+)
+
+// invitationStatuses lists the statuses invitations can be filtered on
+var invitationStatuses = []string{InvitationPending, InvitationSent, InvitationFailed, InvitationOpened, InvitationResponded}
+
+// Sending settings: each scheduler tick sends a batch, and failed sends are
+// retried with exponential backoff until they are marked failed
+var (
+	invitationBatchSize   = 100
+	invitationMaxAttempts = 5
+	invitationBackoff     = time.Minute
+	// invitationLease keeps other instances off an invitation while it is sent
+	invitationLease = 5 * time.Minute
+)
+
+// maxInvitationUpload caps the emails of one upload
+const maxInvitationUpload = 1000
+
+// publicURL is where respondents reach the server; links to the forms of
+// organizations without a custom domain start with it
+var publicURL = strings.TrimSuffix(envString("PUBLIC_URL", "http://localhost:8081"), "/")
+
+// Invitation is an email inviting one respondent to a survey through a personal link
+type Invitation struct {
+	ID       int    `json:"id"`
+	SurveyID int    `json:"survey_id"`
+	Email    string `json:"email"`
+	Status   string `json:"status"`
+	// Prefix identifies the link of the last email sent, without revealing it
+	Prefix    string `json:"prefix,omitempty"`
+	Attempts  int    `json:"attempts"`
+	LastError string `json:"last_error,omitempty"`
+	// ResponseID is the response submitted through the invitation; responses
+	// to anonymous surveys aren't linked
+	ResponseID    *int       `json:"response_id,omitempty"`
+	RemindersSent int        `json:"reminders_sent"`
+	CreatedAt     time.Time  `json:"created_at"`
+	SentAt        *time.Time `json:"sent_at,omitempty"`
+	RemindedAt    *time.Time `json:"reminded_at,omitempty"`
+	OpenedAt      *time.Time `json:"opened_at,omitempty"`
+	RespondedAt   *time.Time `json:"responded_at,omitempty"`
+}
+
+// CreateInvitationsRequest represents the request body for inviting respondents
+type CreateInvitationsRequest struct {
+	Invitations struct {
+		Emails []string `json:"emails" binding:"required"`
+	} `json:"invitations" binding:"required"`
+}
+
+// InvitationUpload reports what an upload of emails did
+type InvitationUpload struct {
+	Created int `json:"created"`
+	// Skipped lists the emails already invited to the survey
+	Skipped []string `json:"skipped"`
+}
+
+// invitationColumns lists the columns read by scanInvitation
+const invitationColumns = `id, survey_id, email, status, COALESCE(prefix, ''), attempts, COALESCE(last_error, ''),
+	response_id, reminders_sent, created_at, sent_at, reminded_at, opened_at, responded_at`
+
+// scanInvitation scans a row selected with invitationColumns
+func scanInvitation(row rowScanner) (Invitation, error) {
+	var inv Invitation
+	var responseID sql.NullInt64
+	var sentAt, remindedAt, openedAt, respondedAt sql.NullTime
+	err := row.Scan(&inv.ID, &inv.SurveyID, &inv.Email, &inv.Status, &inv.Prefix, &inv.Attempts, &inv.LastError,
+		&responseID, &inv.RemindersSent, &inv.CreatedAt, &sentAt, &remindedAt, &openedAt, &respondedAt)
+	inv.ResponseID = nullIntPtr(responseID)
+	inv.SentAt = nullTimePtr(sentAt)
+	inv.RemindedAt = nullTimePtr(remindedAt)
+	inv.OpenedAt = nullTimePtr(openedAt)
+	inv.RespondedAt = nullTimePtr(respondedAt)
+	return inv, err
+}
+
+// findInvitationByToken loads the invitation whose last email or last reminder carried token
+func findInvitationByToken(ctx context.Context, token string) (Invitation, error) {
+	hash := hashSurveyToken(token)
+	return scanInvitation(db.QueryRowContext(ctx,
+		"SELECT "+invitationColumns+" FROM survey_invitations WHERE token_hash = ? OR reminder_token_hash = ?", hash, hash))
+}
+
+// invitationParams reads the survey ID and, when present, the invitation ID of
+// an invitation route, responding with the error when they are invalid
+func invitationParams(c *gin.Context) (surveyID, invitationID int, ok bool) {
+	surveyID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		abortWithError(c, errInvalidID("survey"))
+		return 0, 0, false
+	}
+	if c.Param("invitation_id") == "" {
+		return surveyID, 0, true
+	}
+	invitationID, err = strconv.Atoi(c.Param("invitation_id"))
+	if err != nil {
+		abortWithError(c, errInvalidID("invitation"))
+		return 0, 0, false
+	}
+	return surveyID, invitationID, true
+}
+
+// findInvitation loads a survey's invitation, responding 404 when there is none
+func findInvitation(c *gin.Context, surveyID, invitationID int) (Invitation, bool) {
+	inv, err := scanInvitation(db.QueryRowContext(c.Request.Context(),
+		"SELECT "+invitationColumns+" FROM survey_invitations WHERE id = ? AND survey_id = ?", invitationID, surveyID))
+	if err == sql.ErrNoRows {
+		abortWithError(c, errNotFound(CodeInvitationNotFound, "Invitation not found"))
+		return inv, false
+	}
+	if err != nil {
+		abortWithError(c, errInternal("Failed to fetch invitation", err))
+		return inv, false
+	}
+	return inv, true
+}
+
+// validInvitationStatus reports whether status is a known invitation status
+func validInvitationStatus(status string) bool {
+	for _, s := range invitationStatuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// getInvitations lists a survey's invitations, optionally those with ?status=
+func getInvitations(c *gin.Context) {
+	surveyID, _, ok := invitationParams(c)
+	if !ok {
+		return
+	}
+	status := c.Query("status")
+	if status != "" && !validInvitationStatus(status) {
+		abortWithError(c, errInvalidQuery([]string{"Status must be one of " + strings.Join(invitationStatuses, ", ")}))
+		return
+	}
+
+	rows, err := db.QueryContext(c.Request.Context(), `
+		SELECT `+invitationColumns+` FROM survey_invitations
+		WHERE survey_id = ? AND (? = '' OR status = ?)
+		ORDER BY id
+	`, surveyID, status, status)
+	if err != nil {
+		abortWithError(c, errInternal("Failed to fetch invitations", err))
+		return
+	}
+	defer rows.Close()
+
+	invitations := []Invitation{}
+	for rows.Next() {
+		inv, err := scanInvitation(rows)
+		if err != nil {
+			abortWithError(c, errInternal("Failed to scan invitation data", err))
+			return
+		}
+		invitations = append(invitations, inv)
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Status: "success",
+		Data:   invitations,
+	})
+}
+
+// createInvitations invites a list of emails to a survey. The emails are sent
+// in the background once the survey is published; emails already invited are skipped.
+func createInvitations(c *gin.Context) {
+	ctx := c.Request.Context()
+	surveyID, _, ok := invitationParams(c)
+	if !ok {
+		return
+	}
+	var req CreateInvitationsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		abortWithError(c, errInvalidRequest(err))
+		return
+	}
+
+	var exists bool
+	if err := db.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM surveys WHERE id = ?)", surveyID).Scan(&exists); err != nil || !exists {
+		abortWithError(c, errNotFound(CodeSurveyNotFound, "Survey not found"))
+		return
+	}
+
+	// Validation
+	var errors []string
+	if len(req.Invitations.Emails) == 0 {
+		errors = append(errors, "Emails are required")
+	}
+	if len(req.Invitations.Emails) > maxInvitationUpload {
+		errors = append(errors, fmt.Sprintf("At most %d emails can be uploaded at once", maxInvitationUpload))
+	}
+	// Emails become the user identifiers of responses, so they share their length limit
+	var emails []string
+	seen := map[string]bool{}
+	for i, raw := range req.Invitations.Emails {
+		email := strings.ToLower(strings.TrimSpace(raw))
+		if addr, err := mail.ParseAddress(email); err != nil || addr.Address != email || len(email) > 100 {
+			errors = append(errors, fmt.Sprintf("Email %d is not a valid address", i+1))
+			continue
+		}
+		if !seen[email] {
+			seen[email] = true
+			emails = append(emails, email)
+		}
+	}
+
+	if len(errors) > 0 {
+		abortWithError(c, errValidation("Failed to create invitations", errors))
+		return
+	}
+
+	upload := InvitationUpload{Skipped: []string{}}
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		abortWithError(c, errInternal("Failed to create invitations", err))
+		return
+	}
+	defer tx.Rollback()
+	for _, email := range emails {
+		n, err := rowsAffected(ctx, tx, `
+			INSERT OR IGNORE INTO survey_invitations (survey_id, email, status, created_at)
+			VALUES (?, ?, ?, CURRENT_TIMESTAMP)
+		`, surveyID, email, InvitationPending)
+		if err != nil {
+			abortWithError(c, errInternal("Failed to create invitations", err))
+			return
+		}
+		if n == 0 {
+			upload.Skipped = append(upload.Skipped, email)
+		}
+		upload.Created += n
+	}
+	if err := tx.Commit(); err != nil {
+		abortWithError(c, errInternal("Failed to create invitations", err))
+		return
+	}
+	auditChange(c, "invite", "survey", surveyID, nil, upload)
+
+	c.JSON(http.StatusCreated, APIResponse{
+		Status:  "success",
+		Message: fmt.Sprintf("%d invitations created", upload.Created),
+		Data:    upload,
+	})
+}
+
+// resendInvitation queues an invitation to be sent again, with a new link
+// replacing the previous ones, and its reminders start over. Invitations
+// already answered can't be resent.
+func resendInvitation(c *gin.Context) {
+	ctx := c.Request.Context()
+	surveyID, invitationID, ok := invitationParams(c)
+	if !ok {
+		return
+	}
+	before, ok := findInvitation(c, surveyID, invitationID)
+	if !ok {
+		return
+	}
+	if before.Status == InvitationResponded {
+		abortWithError(c, errValidation("Failed to resend invitation", []string{"Invitation was already answered"}))
+		return
+	}
+
+	_, err := db.ExecContext(ctx, `
+		UPDATE survey_invitations
+		SET status = ?, attempts = 0, last_error = NULL, next_attempt_at = NULL,
+			reminders_sent = 0, reminded_at = NULL, reminder_token_hash = NULL
+		WHERE id = ?
+	`, InvitationPending, invitationID)
+	if err != nil {
+		abortWithError(c, errInternal("Failed to resend invitation", err))
+		return
+	}
+	after, ok := findInvitation(c, surveyID, invitationID)
+	if !ok {
+		return
+	}
+	auditChange(c, "update", "survey_invitation", invitationID, before, after)
+
+	c.JSON(http.StatusOK, APIResponse{
+		Status:  "success",
+		Message: "Invitation queued to be sent again",
+		Data:    after,
+	})
+}
+
+// invitationEmail is the email of an invitation whose link carries token;
+// the link starts with baseURL. Single-question surveys can also be answered
+// by replying, when the inbound email gateway is on.
+func invitationEmail(survey Survey, baseURL, email, token string) Email {
+	var text strings.Builder
+	fmt.Fprintf(&text, "You're invited to answer the survey \"%s\".\n\n", survey.Title)
+	if survey.Description != "" {
+		fmt.Fprintf(&text, "%s\n\n", survey.Description)
+	}
+	fmt.Fprintf(&text, "Answer it here: %s/i/%s\n\nThis link is personal, please don't share it.\n", baseURL, token)
+	invitation := Email{To: email, Subject: "You're invited: " + survey.Title}
+	if q, ok := emailQuestion(survey); ok && inboundEmailEnabled() {
+		fmt.Fprintf(&text, "\nYou can also answer by replying to this email.\n\n%s\n%s\n", q.Label, emailAnswerInstructions(q))
+		invitation.ReplyTo = inboundReplyAddress(token)
+	}
+	invitation.Text = text.String()
+	return invitation
+}
+
+// sendPendingInvitations sends a batch of the pending invitations of published
+// surveys, each with a new personal link. An invitation is leased before it is
+// sent, so instances sharing the database don't send it twice.
+func sendPendingInvitations(ctx context.Context, now time.Time) (int, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT i.id, i.survey_id, i.email, i.attempts FROM survey_invitations i
+		JOIN surveys s ON s.id = i.survey_id
+		WHERE i.status = ? AND s.status = ? AND (i.next_attempt_at IS NULL OR i.next_attempt_at <= ?)
+		ORDER BY i.id LIMIT ?
+	`, InvitationPending, SurveyStatusPublished, now.UTC(), invitationBatchSize)
+	if err != nil {
+		return 0, err
+	}
+	type pending struct {
+		id, surveyID, attempts int
+		email                  string
+	}
+	var batch []pending
+	for rows.Next() {
+		var p pending
+		if err := rows.Scan(&p.id, &p.surveyID, &p.email, &p.attempts); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		batch = append(batch, p)
+	}
+	rows.Close()
+
+	sent := 0
+	surveys := map[int]Survey{}
+	for _, p := range batch {
+		survey, ok := surveys[p.surveyID]
+		if !ok {
+			if survey, err = findSurvey(ctx, p.surveyID); err != nil {
+				return sent, err
+			}
+			surveys[p.surveyID] = survey
+		}
+
+		token := randomHex(16)
+		leased, err := rowsAffected(ctx, db, `
+			UPDATE survey_invitations SET prefix = ?, token_hash = ?, next_attempt_at = ?
+			WHERE id = ? AND status = ? AND (next_attempt_at IS NULL OR next_attempt_at <= ?)
+		`, token[:8], hashSurveyToken(token), now.Add(invitationLease).UTC(), p.id, InvitationPending, now.UTC())
+		if err != nil {
+			return sent, err
+		}
+		if leased == 0 {
+			continue
+		}
+
+		if err := mailer.Send(ctx, invitationEmail(survey, organizationBaseURL(ctx, survey.OrganizationID), p.email, token)); err != nil {
+			status, next := InvitationPending, now.Add(invitationBackoff<<p.attempts).UTC()
+			if p.attempts+1 >= invitationMaxAttempts {
+				status = InvitationFailed
+			}
+			log.Printf("Invitations: failed to send invitation %d (attempt %d): %v", p.id, p.attempts+1, err)
+			_, err = db.ExecContext(ctx, `
+				UPDATE survey_invitations SET status = ?, attempts = attempts + 1, last_error = ?, next_attempt_at = ?
+				WHERE id = ?
+			`, status, err.Error(), next, p.id)
+			if err != nil {
+				return sent, err
+			}
+			continue
+		}
+
+		_, err = db.ExecContext(ctx, `
+			UPDATE survey_invitations
+			SET status = ?, attempts = attempts + 1, last_error = NULL, next_attempt_at = NULL, sent_at = CURRENT_TIMESTAMP
+			WHERE id = ?
+		`, InvitationSent, p.id)
+		if err != nil {
+			return sent, err
+		}
+		sent++
+	}
+	return sent, nil
+}
+
+// getInvitationForm renders the form of an invitation link, recording that the
+// invitation was opened. Answered invitations show a thank-you note instead.
+func getInvitationForm(c *gin.Context) {
+	ctx := c.Request.Context()
+	token := c.Param("token")
+	inv, err := findInvitationByToken(ctx, token)
+	var survey Survey
+	if err == nil {
+		survey, err = findSurvey(ctx, inv.SurveyID)
+	}
+	if err == nil && !servesSurvey(c, survey) {
+		err = sql.ErrNoRows
+	}
+
+	var view formView
+	status := http.StatusOK
+	switch {
+	case err == sql.ErrNoRows:
+		status = http.StatusNotFound
+		view.Title, view.Notice = "Invitation not found", "This link is invalid or was replaced by a newer invitation."
+	case err != nil:
+		log.Printf("Failed to fetch invitation: %v", err)
+		status = http.StatusInternalServerError
+		view.Title, view.Notice = "Something went wrong", "The survey couldn't be loaded. Please try again later."
+	case inv.RespondedAt != nil:
+		view.Title, view.Notice = survey.Title, "You have already answered this survey. Thank you!"
+	default:
+		_, err := db.ExecContext(ctx, `
+			UPDATE survey_invitations
+			SET opened_at = COALESCE(opened_at, CURRENT_TIMESTAMP), status = CASE WHEN status = ? THEN ? ELSE status END
+			WHERE id = ?
+		`, InvitationSent, InvitationOpened, inv.ID)
+		if err != nil {
+			log.Printf("Failed to record invitation %d as opened: %v", inv.ID, err)
+		}
+		view = newFormView(survey, c.Query("lang"), time.Now())
+		view.InvitationToken = token
+		view.Channel = "email"
+		view.CanonicalURL = organizationBaseURL(ctx, survey.OrganizationID) + "/i/" + token
+	}
+	// Invitation links are personal, so never indexed
+	view.NoIndex = true
+	renderSurveyForm(c, status, view)
+}
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// recordingMailer keeps the emails it is asked to send, failing them while err is set
+type recordingMailer struct {
+	sent []Email
+	err  error
+}
+
+// Send implements Mailer
+func (m *recordingMailer) Send(ctx context.Context, email Email) error {
+	if m.err != nil {
+		return m.err
+	}
+	m.sent = append(m.sent, email)
+	return nil
+}
+
+// invitationLink matches the token of an invitation email's link
+var invitationLink = regexp.MustCompile(`/i/([0-9a-f]{32})`)
+
+func TestCreateInvitations(t *testing.T) {
+	setupTestDB()
+	defer testDB.Close()
+	router := setupTestRouter()
+	testDB.Exec("INSERT INTO surveys (title, description) VALUES ('Onboarding', 'd')")
+
+	send := func(method, url, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, url, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := send("POST", "/api/surveys/1/invitations", `{"invitations":{"emails":["ana@example.com","Ana Lima <ana@example.com>","not an email"]}}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Contains(t, w.Body.String(), "Email 2 is not a valid address")
+	assert.Contains(t, w.Body.String(), "Email 3 is not a valid address")
+	assert.Equal(t, http.StatusUnprocessableEntity, send("POST", "/api/surveys/1/invitations", `{"invitations":{"emails":[]}}`).Code)
+	assert.Equal(t, http.StatusNotFound, send("POST", "/api/surveys/99/invitations", `{"invitations":{"emails":["ana@example.com"]}}`).Code)
+
+	// Emails are normalized, so duplicates in the list and across uploads are skipped
+	w = send("POST", "/api/surveys/1/invitations", `{"invitations":{"emails":["ana@example.com"," Ben@Example.com ","ben@example.com"]}}`)
+	assert.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var upload struct {
+		Data InvitationUpload `json:"data"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &upload)
+	assert.Equal(t, InvitationUpload{Created: 2, Skipped: []string{}}, upload.Data)
+	w = send("POST", "/api/surveys/1/invitations", `{"invitations":{"emails":["ben@example.com","cy@example.com"]}}`)
+	json.Unmarshal(w.Body.Bytes(), &upload)
+	assert.Equal(t, InvitationUpload{Created: 1, Skipped: []string{"ben@example.com"}}, upload.Data)
+
+	var list struct {
+		Data []Invitation `json:"data"`
+	}
+	w = send("GET", "/api/surveys/1/invitations", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	json.Unmarshal(w.Body.Bytes(), &list)
+	if assert.Len(t, list.Data, 3) {
+		assert.Equal(t, "ben@example.com", list.Data[1].Email)
+		assert.Equal(t, InvitationPending, list.Data[1].Status)
+		assert.Nil(t, list.Data[1].SentAt)
+	}
+	w = send("GET", "/api/surveys/1/invitations?status=sent", "")
+	json.Unmarshal(w.Body.Bytes(), &list)
+	assert.Empty(t, list.Data)
+	assert.Equal(t, http.StatusBadRequest, send("GET", "/api/surveys/1/invitations?status=bounced", "").Code)
+}
+
+func TestInvitationLifecycle(t *testing.T) {
+	setupTestDB()
+	defer testDB.Close()
+	router := setupTestRouter()
+	defer func(m Mailer) { mailer = m }(mailer)
+	sent := &recordingMailer{}
+	mailer = sent
+
+	testDB.Exec(`INSERT INTO surveys (title, description, status, questions) VALUES ('Onboarding', 'How was your first week?', 'draft',
+		'[{"id":"rating","type":"rating","label":"Rate your first week"}]')`)
+	testDB.Exec("INSERT INTO survey_invitations (survey_id, email, status) VALUES (1, 'ana@example.com', 'pending')")
+
+	send := func(method, url, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, url, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		return w
+	}
+	invitation := func() Invitation {
+		inv, err := scanInvitation(testDB.QueryRow("SELECT " + invitationColumns + " FROM survey_invitations WHERE id = 1"))
+		assert.NoError(t, err)
+		return inv
+	}
+	ctx := context.Background()
+
+	// Invitations wait for their survey to be published
+	n, err := sendPendingInvitations(ctx, time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, 0, n)
+	testDB.Exec("UPDATE surveys SET status = 'published'")
+	n, err = sendPendingInvitations(ctx, time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+	if !assert.Len(t, sent.sent, 1) {
+		return
+	}
+	assert.Equal(t, "ana@example.com", sent.sent[0].To)
+	assert.Equal(t, "You're invited: Onboarding", sent.sent[0].Subject)
+	assert.Contains(t, sent.sent[0].Text, "How was your first week?")
+	token := invitationLink.FindStringSubmatch(sent.sent[0].Text)[1]
+	inv := invitation()
+	assert.Equal(t, InvitationSent, inv.Status)
+	assert.Equal(t, token[:8], inv.Prefix)
+	assert.NotNil(t, inv.SentAt)
+	n, _ = sendPendingInvitations(ctx, time.Now())
+	assert.Equal(t, 0, n)
+
+	// The link opens the form, which doesn't ask who is answering
+	w := send("GET", "/i/"+token, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `data-invitation="`+token+`"`)
+	assert.NotContains(t, w.Body.String(), `id="user_identifier"`)
+	assert.Equal(t, InvitationOpened, invitation().Status)
+	assert.NotNil(t, invitation().OpenedAt)
+	assert.Equal(t, http.StatusNotFound, send("GET", "/i/"+strings.Repeat("0", 32), "").Code)
+
+	w = send("POST", "/api/surveys/1/responses", `{"survey_response":{"invitation_token":"nope","user_identifier":"ana@example.com","response_data":{"rating":4}}}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Contains(t, w.Body.String(), "Invitation is invalid")
+
+	// Answering through the invitation identifies the respondent by its email
+	w = send("POST", "/api/surveys/1/responses", `{"survey_response":{"invitation_token":"`+token+`","response_data":{"rating":5}}}`)
+	assert.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var created struct {
+		Data SurveyResponse `json:"data"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &created)
+	assert.Equal(t, "ana@example.com", created.Data.UserIdentifier)
+	inv = invitation()
+	assert.Equal(t, InvitationResponded, inv.Status)
+	assert.Equal(t, &created.Data.ID, inv.ResponseID)
+	assert.NotNil(t, inv.RespondedAt)
+
+	assert.Contains(t, send("GET", "/i/"+token, "").Body.String(), "You have already answered this survey")
+	testDB.Exec("UPDATE surveys SET allow_multiple_responses = 1")
+	w = send("POST", "/api/surveys/1/responses", `{"survey_response":{"invitation_token":"`+token+`","response_data":{"rating":1}}}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Contains(t, w.Body.String(), "Invitation was already answered")
+	assert.Equal(t, http.StatusUnprocessableEntity, send("POST", "/api/surveys/1/invitations/1/resend", "").Code)
+}
+
+func TestInvitationRetries(t *testing.T) {
+	setupTestDB()
+	defer testDB.Close()
+	router := setupTestRouter()
+	defer func(m Mailer) { mailer = m }(mailer)
+	failing := &recordingMailer{err: errors.New("connection refused")}
+	mailer = failing
+
+	testDB.Exec("INSERT INTO surveys (title, description) VALUES ('Onboarding', 'd')")
+	testDB.Exec("INSERT INTO survey_invitations (survey_id, email, status) VALUES (1, 'ana@example.com', 'pending')")
+	invitation := func() Invitation {
+		inv, err := scanInvitation(testDB.QueryRow("SELECT " + invitationColumns + " FROM survey_invitations WHERE id = 1"))
+		assert.NoError(t, err)
+		return inv
+	}
+	ctx := context.Background()
+
+	// Failed sends back off exponentially, then give up
+	now := time.Now()
+	for attempt := 1; attempt <= invitationMaxAttempts; attempt++ {
+		n, err := sendPendingInvitations(ctx, now)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, n)
+		assert.Equal(t, attempt, invitation().Attempts)
+		assert.Equal(t, "connection refused", invitation().LastError)
+		n, _ = sendPendingInvitations(ctx, now.Add(invitationBackoff<<(attempt-1)-time.Second))
+		assert.Equal(t, attempt, invitation().Attempts, "retried before its backoff")
+		now = now.Add(invitationBackoff << (attempt - 1))
+	}
+	assert.Equal(t, InvitationFailed, invitation().Status)
+	sendPendingInvitations(ctx, now.Add(time.Hour))
+	assert.Equal(t, invitationMaxAttempts, invitation().Attempts)
+
+	// Resending starts over, and the new link replaces the old one
+	failing.err = nil
+	resend := func() {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("POST", "/api/surveys/1/invitations/1/resend", nil))
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Equal(t, InvitationPending, invitation().Status)
+		assert.Equal(t, 0, invitation().Attempts)
+		n, err := sendPendingInvitations(ctx, time.Now())
+		assert.NoError(t, err)
+		assert.Equal(t, 1, n)
+	}
+	resend()
+	resend()
+	if assert.Len(t, failing.sent, 2) {
+		first := invitationLink.FindStringSubmatch(failing.sent[0].Text)[1]
+		second := invitationLink.FindStringSubmatch(failing.sent[1].Text)[1]
+		assert.NotEqual(t, first, second)
+		_, err := findInvitationByToken(ctx, first)
+		assert.Error(t, err)
+		inv, err := findInvitationByToken(ctx, second)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, inv.ID)
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("POST", "/api/surveys/1/invitations/9/resend", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Contains(t, w.Body.String(), CodeInvitationNotFound)
+}
+
+func TestInitMailer(t *testing.T) {
+	defer func(m Mailer) { mailer = m }(mailer)
+	t.Setenv("MAIL_SENDER", "")
+	assert.NoError(t, initMailer())
+	assert.Equal(t, logMailer{}, mailer)
+
+	t.Setenv("MAIL_SENDER", "smtp")
+	t.Setenv("SMTP_ADDR", "")
+	assert.Error(t, initMailer())
+	t.Setenv("SMTP_ADDR", "smtp.example.com:587")
+	assert.NoError(t, initMailer())
+	assert.Equal(t, "smtp.example.com:587", mailer.(smtpMailer).Addr)
+
+	t.Setenv("MAIL_SENDER", "sendgrid")
+	t.Setenv("SENDGRID_API_KEY", "")
+	assert.Error(t, initMailer())
+	t.Setenv("MAIL_SENDER", "pigeon")
+	assert.Error(t, initMailer())
+}
+
+func TestSendGridMailer(t *testing.T) {
+	var got map[string]interface{}
+	var auth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		json.NewDecoder(r.Body).Decode(&got)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	m := sendGridMailer{APIKey: "SG.key", From: "surveys@example.com", URL: server.URL}
+	assert.NoError(t, m.Send(context.Background(), Email{To: "ana@example.com", Subject: "Hi", Text: "Body"}))
+	assert.Equal(t, "Bearer SG.key", auth)
+	assert.Equal(t, "Hi", got["subject"])
+	assert.Equal(t, `[map[to:[map[email:ana@example.com]]]]`, fmt.Sprint(got["personalizations"]))
+	assert.Equal(t, `[map[type:text/plain value:Body]]`, fmt.Sprint(got["content"]))
+
+	m.URL = server.URL + "/missing"
+	server.Config.Handler = http.NotFoundHandler()
+	assert.Error(t, m.Send(context.Background(), Email{To: "ana@example.com"}))
+}
+
+func TestHeaderValue(t *testing.T) {
+	assert.Equal(t, "Survey  Bcc: x", headerValue("Survey\r\nBcc: x"))
+	assert.Equal(t, "=?utf-8?q?Enqu=C3=AAte?=", headerValue("Enquête"))
+}
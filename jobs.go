@@ -0,0 +1,696 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+)
+
+// Job statuses. Jobs that succeed are deleted; jobs out of attempts are kept
+// as dead letters until an admin requeues or deletes them.
+const (
+	JobStatusQueued  = "queued"
+	JobStatusRunning = "running"
+	JobStatusDead    = "dead"
+)
+
+// Job kinds
+const (
+	JobKindWebhook = "webhook.deliver"
+)
+
+// Worker pool settings; a claimed job whose worker died is run again once
+// its lease runs out
+var (
+	jobWorkers      = envInt("JOB_WORKERS", 4)
+	jobPollInterval = 5 * time.Second
+	jobLease        = 5 * time.Minute
+	jobRetryBackoff = 2 * time.Second
+)
+
+// Job is a unit of background work, run by a worker with the handler of its kind
+type Job struct {
+	ID          int             `json:"id"`
+	Kind        string          `json:"kind"`
+	Payload     json.RawMessage `json:"payload"`
+	Status      string          `json:"status"`
+	Attempts    int             `json:"attempts"`
+	MaxAttempts int             `json:"max_attempts"`
+	// RunAt is when a queued job is due, or when a running job's lease ends
+	RunAt     time.Time `json:"run_at"`
+	LastError string    `json:"last_error,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// withoutPayload returns the job without its payload, which may hold
+// response data, for the audit log
+func (j Job) withoutPayload() Job {
+	j.Payload = nil
+	return j
+}
+
+var (
+	errJobNotFound = errors.New("job not found")
+	errJobNotDead  = errors.New("job is not dead")
+)
+
+// JobStore keeps the job queue; implementations must be safe for concurrent use
+type JobStore interface {
+	Enqueue(ctx context.Context, job Job) (Job, error)
+	// Claim takes the job due first, if any, marking it running until now+lease
+	Claim(ctx context.Context, now time.Time, lease time.Duration) (Job, bool, error)
+	Complete(ctx context.Context, job Job) error
+	Retry(ctx context.Context, job Job, runAt time.Time, reason string) error
+	Bury(ctx context.Context, job Job, now time.Time, reason string) error
+	Get(ctx context.Context, id int) (Job, error)
+	List(ctx context.Context, status, kind string, limit int) ([]Job, error)
+	// Requeue queues a dead job again with its attempts reset
+	Requeue(ctx context.Context, id int, now time.Time) (Job, error)
+	Delete(ctx context.Context, id int) error
+}
+
+// jobQueue is the store jobs are enqueued to and run from
+var jobQueue JobStore = sqliteJobStore{}
+
+// newJobStore returns a Redis-backed store when REDIS_URL is set, and one
+// keeping jobs in the database otherwise
+func newJobStore() JobStore {
+	if url := os.Getenv("REDIS_URL"); url != "" {
+		opts, err := redis.ParseURL(url)
+		if err != nil {
+			log.Fatal(err)
+		}
+		return &redisJobStore{client: redis.NewClient(opts)}
+	}
+	return sqliteJobStore{}
+}
+
+// JobHandler runs a job's payload; a returned error fails the attempt
+type JobHandler func(ctx context.Context, payload json.RawMessage) error
+
+// jobHandlers maps job kinds to their handlers
+var jobHandlers = map[string]JobHandler{
+	JobKindWebhook:         deliverWebhook,
+	JobKindResponseSummary: sendResponseSummary,
+}
+
+// jobWake tells an idle worker a job was enqueued
+var jobWake = make(chan struct{}, 1)
+
+// wakeJobWorker wakes an idle worker, if one is waiting
+func wakeJobWorker() {
+	select {
+	case jobWake <- struct{}{}:
+	default:
+	}
+}
+
+// enqueueJob queues payload as a job of kind, due now and tried up to maxAttempts times
+func enqueueJob(ctx context.Context, kind string, payload interface{}, maxAttempts int) (Job, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return Job{}, err
+	}
+	now := time.Now().UTC()
+	job, err := jobQueue.Enqueue(ctx, Job{
+		Kind:        kind,
+		Payload:     body,
+		Status:      JobStatusQueued,
+		MaxAttempts: maxAttempts,
+		RunAt:       now,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	})
+	if err == nil {
+		wakeJobWorker()
+	}
+	return job, err
+}
+
+// jobRetryDelay returns how long to wait before the attempt after attempt
+func jobRetryDelay(attempt int) time.Duration {
+	if attempt > 16 {
+		attempt = 16
+	}
+	return jobRetryBackoff << (attempt - 1)
+}
+
+// runNextJob claims and runs the job due first at now, reporting whether there was one
+func runNextJob(ctx context.Context, now time.Time) (bool, error) {
+	job, ok, err := jobQueue.Claim(ctx, now, jobLease)
+	if err != nil || !ok {
+		return false, err
+	}
+
+	handler, ok := jobHandlers[job.Kind]
+	if !ok {
+		err = fmt.Errorf("unknown job kind %q", job.Kind)
+	} else {
+		err = handler(ctx, job.Payload)
+	}
+	switch {
+	case err == nil:
+		return true, jobQueue.Complete(ctx, job)
+	case job.Attempts >= job.MaxAttempts || handler == nil:
+		log.Printf("Jobs: giving up on %s job %d after %d attempts: %v", job.Kind, job.ID, job.Attempts, err)
+		return true, jobQueue.Bury(ctx, job, now, err.Error())
+	default:
+		return true, jobQueue.Retry(ctx, job, now.Add(jobRetryDelay(job.Attempts)), err.Error())
+	}
+}
+
+// runDueJobs runs every job due at now, one at a time, returning how many ran
+func runDueJobs(ctx context.Context, now time.Time) (int, error) {
+	n := 0
+	for {
+		ran, err := runNextJob(ctx, now)
+		if err != nil || !ran {
+			return n, err
+		}
+		n++
+	}
+}
+
+// jobsRunning tracks the workers so shutdown can wait for the jobs they run
+var jobsRunning sync.WaitGroup
+
+// startJobWorkers runs n workers until ctx is done. Idle workers wait to be
+// woken by an enqueued job, or poll every interval for retries coming due.
+// Jobs aren't cancelled with ctx: a worker finishes its job before stopping.
+func startJobWorkers(ctx context.Context, n int, interval time.Duration) {
+	for i := 0; i < n; i++ {
+		jobsRunning.Add(1)
+		go func() {
+			defer jobsRunning.Done()
+			for ctx.Err() == nil {
+				ran, err := runNextJob(context.WithoutCancel(ctx), time.Now())
+				if err != nil {
+					log.Println("Jobs: failed to run job:", err)
+				}
+				if ran {
+					// Another job may be waiting for a worker too
+					wakeJobWorker()
+					continue
+				}
+				select {
+				case <-ctx.Done():
+				case <-jobWake:
+				case <-time.After(interval):
+				}
+			}
+		}()
+	}
+}
+
+// waitForJobs waits for the workers to finish their jobs until ctx is done
+func waitForJobs(ctx context.Context) {
+	done := make(chan struct{})
+	go func() {
+		jobsRunning.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		log.Println("Jobs: shutting down with jobs running; they run again once their lease ends")
+	}
+}
+
+// jobColumns lists the columns read by scanJob
+const jobColumns = "id, kind, payload, status, attempts, max_attempts, run_at, last_error, created_at, updated_at"
+
+// scanJob scans a row selected with jobColumns, decrypting the payload
+func scanJob(row rowScanner) (Job, error) {
+	var job Job
+	var payload []byte
+	var lastError sql.NullString
+	err := row.Scan(&job.ID, &job.Kind, &payload, &job.Status, &job.Attempts, &job.MaxAttempts,
+		&job.RunAt, &lastError, &job.CreatedAt, &job.UpdatedAt)
+	if err != nil {
+		return job, err
+	}
+	job.LastError = lastError.String
+	job.Payload, err = decryptResponseData(payload)
+	return job, err
+}
+
+// sqliteJobStore keeps jobs in the jobs table. Payloads are encrypted like
+// response data, since webhook deliveries carry responses.
+type sqliteJobStore struct{}
+
+func (sqliteJobStore) Enqueue(ctx context.Context, job Job) (Job, error) {
+	result, err := db.ExecContext(ctx, `
+		INSERT INTO jobs (kind, payload, status, attempts, max_attempts, run_at, created_at, updated_at)
+		VALUES (?, ?, ?, 0, ?, ?, ?, ?)
+	`, job.Kind, encryptResponseData(job.Payload), job.Status, job.MaxAttempts, job.RunAt, job.CreatedAt, job.UpdatedAt)
+	if err != nil {
+		return job, err
+	}
+	id, err := result.LastInsertId()
+	job.ID = int(id)
+	return job, err
+}
+
+func (s sqliteJobStore) Claim(ctx context.Context, now time.Time, lease time.Duration) (Job, bool, error) {
+	now = now.UTC()
+	for {
+		var id, attempts int
+		err := db.QueryRowContext(ctx, `
+			SELECT id, attempts FROM jobs WHERE status != ? AND run_at <= ? ORDER BY run_at, id LIMIT 1
+		`, JobStatusDead, now).Scan(&id, &attempts)
+		if err == sql.ErrNoRows {
+			return Job{}, false, nil
+		}
+		if err != nil {
+			return Job{}, false, err
+		}
+
+		// Another worker may claim the job first; the attempts tell
+		n, err := rowsAffected(ctx, db, `
+			UPDATE jobs SET status = ?, attempts = attempts + 1, run_at = ?, updated_at = ?
+			WHERE id = ? AND attempts = ? AND status != ?
+		`, JobStatusRunning, now.Add(lease), now, id, attempts, JobStatusDead)
+		if err != nil {
+			return Job{}, false, err
+		}
+		if n == 1 {
+			job, err := s.Get(ctx, id)
+			return job, err == nil, err
+		}
+	}
+}
+
+func (sqliteJobStore) Complete(ctx context.Context, job Job) error {
+	_, err := db.ExecContext(ctx, "DELETE FROM jobs WHERE id = ?", job.ID)
+	return err
+}
+
+func (sqliteJobStore) Retry(ctx context.Context, job Job, runAt time.Time, reason string) error {
+	_, err := db.ExecContext(ctx, "UPDATE jobs SET status = ?, run_at = ?, last_error = ?, updated_at = ? WHERE id = ?",
+		JobStatusQueued, runAt.UTC(), reason, time.Now().UTC(), job.ID)
+	return err
+}
+
+func (sqliteJobStore) Bury(ctx context.Context, job Job, now time.Time, reason string) error {
+	_, err := db.ExecContext(ctx, "UPDATE jobs SET status = ?, last_error = ?, updated_at = ? WHERE id = ?",
+		JobStatusDead, reason, now.UTC(), job.ID)
+	return err
+}
+
+func (sqliteJobStore) Get(ctx context.Context, id int) (Job, error) {
+	job, err := scanJob(db.QueryRowContext(ctx, "SELECT "+jobColumns+" FROM jobs WHERE id = ?", id))
+	if err == sql.ErrNoRows {
+		err = errJobNotFound
+	}
+	return job, err
+}
+
+func (sqliteJobStore) List(ctx context.Context, status, kind string, limit int) ([]Job, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT `+jobColumns+` FROM jobs
+		WHERE (? = '' OR status = ?) AND (? = '' OR kind = ?)
+		ORDER BY id LIMIT ?
+	`, status, status, kind, kind, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	jobs := []Job{}
+	for rows.Next() {
+		job, err := scanJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, rows.Err()
+}
+
+func (s sqliteJobStore) Requeue(ctx context.Context, id int, now time.Time) (Job, error) {
+	n, err := rowsAffected(ctx, db, "UPDATE jobs SET status = ?, attempts = 0, run_at = ?, updated_at = ? WHERE id = ? AND status = ?",
+		JobStatusQueued, now.UTC(), now.UTC(), id, JobStatusDead)
+	if err != nil {
+		return Job{}, err
+	}
+	job, err := s.Get(ctx, id)
+	if err == nil && n == 0 {
+		err = errJobNotDead
+	}
+	return job, err
+}
+
+func (sqliteJobStore) Delete(ctx context.Context, id int) error {
+	n, err := rowsAffected(ctx, db, "DELETE FROM jobs WHERE id = ?", id)
+	if err == nil && n == 0 {
+		err = errJobNotFound
+	}
+	return err
+}
+
+// Redis keys of the job queue: a hash per job, the queued and running jobs
+// scored by run_at and the dead ones scored by when they died (Unix ms)
+const (
+	redisJobSeq  = "jobs:seq"
+	redisJobKey  = "jobs:job:"
+	redisJobsDue = "jobs:due"
+	redisJobDead = "jobs:dead"
+)
+
+// redisClaimJob atomically moves the job due first to running
+var redisClaimJob = redis.NewScript(`
+local ids = redis.call("ZRANGEBYSCORE", KEYS[1], "-inf", ARGV[1], "LIMIT", 0, 1)
+if #ids == 0 then
+  return false
+end
+local key = ARGV[4] .. ids[1]
+redis.call("ZADD", KEYS[1], ARGV[2], ids[1])
+redis.call("HINCRBY", key, "attempts", 1)
+redis.call("HSET", key, "status", "running", "run_at", ARGV[2], "updated_at", ARGV[3])
+return ids[1]
+`)
+
+// redisRequeueJob atomically queues a dead job again, answering its status
+var redisRequeueJob = redis.NewScript(`
+local status = redis.call("HGET", KEYS[1], "status")
+if not status then
+  return ""
+end
+if status ~= "dead" then
+  return status
+end
+redis.call("HSET", KEYS[1], "status", "queued", "attempts", 0, "run_at", ARGV[2], "updated_at", ARGV[2])
+redis.call("ZREM", KEYS[3], ARGV[1])
+redis.call("ZADD", KEYS[2], ARGV[2], ARGV[1])
+return "requeued"
+`)
+
+// redisJobStore shares the job queue between API instances through Redis
+type redisJobStore struct {
+	client *redis.Client
+}
+
+// redisJob reads a job from its hash; Redis returns an empty hash for a missing job
+func redisJob(id int, fields map[string]string) (Job, error) {
+	if len(fields) == 0 {
+		return Job{}, errJobNotFound
+	}
+	millis := func(name string) time.Time {
+		ms, _ := strconv.ParseInt(fields[name], 10, 64)
+		return time.UnixMilli(ms).UTC()
+	}
+	job := Job{
+		ID:        id,
+		Kind:      fields["kind"],
+		Status:    fields["status"],
+		RunAt:     millis("run_at"),
+		LastError: fields["last_error"],
+		CreatedAt: millis("created_at"),
+		UpdatedAt: millis("updated_at"),
+	}
+	job.Attempts, _ = strconv.Atoi(fields["attempts"])
+	job.MaxAttempts, _ = strconv.Atoi(fields["max_attempts"])
+	payload, err := decryptResponseData([]byte(fields["payload"]))
+	job.Payload = payload
+	return job, err
+}
+
+func (s *redisJobStore) Enqueue(ctx context.Context, job Job) (Job, error) {
+	id, err := s.client.Incr(ctx, redisJobSeq).Result()
+	if err != nil {
+		return job, err
+	}
+	job.ID = int(id)
+	member := strconv.Itoa(job.ID)
+	_, err = s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, redisJobKey+member,
+			"kind", job.Kind,
+			"payload", encryptResponseData(job.Payload),
+			"status", job.Status,
+			"attempts", 0,
+			"max_attempts", job.MaxAttempts,
+			"run_at", job.RunAt.UnixMilli(),
+			"created_at", job.CreatedAt.UnixMilli(),
+			"updated_at", job.UpdatedAt.UnixMilli())
+		pipe.ZAdd(ctx, redisJobsDue, redis.Z{Score: float64(job.RunAt.UnixMilli()), Member: member})
+		return nil
+	})
+	return job, err
+}
+
+func (s *redisJobStore) Claim(ctx context.Context, now time.Time, lease time.Duration) (Job, bool, error) {
+	member, err := redisClaimJob.Run(ctx, s.client, []string{redisJobsDue},
+		now.UnixMilli(), now.Add(lease).UnixMilli(), now.UnixMilli(), redisJobKey).Text()
+	if err == redis.Nil {
+		return Job{}, false, nil
+	}
+	if err != nil {
+		return Job{}, false, err
+	}
+	id, err := strconv.Atoi(member)
+	if err != nil {
+		return Job{}, false, err
+	}
+	job, err := s.Get(ctx, id)
+	return job, err == nil, err
+}
+
+func (s *redisJobStore) Complete(ctx context.Context, job Job) error {
+	member := strconv.Itoa(job.ID)
+	_, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, redisJobKey+member)
+		pipe.ZRem(ctx, redisJobsDue, member)
+		return nil
+	})
+	return err
+}
+
+func (s *redisJobStore) Retry(ctx context.Context, job Job, runAt time.Time, reason string) error {
+	member := strconv.Itoa(job.ID)
+	_, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, redisJobKey+member, "status", JobStatusQueued, "run_at", runAt.UnixMilli(),
+			"last_error", reason, "updated_at", time.Now().UnixMilli())
+		pipe.ZAdd(ctx, redisJobsDue, redis.Z{Score: float64(runAt.UnixMilli()), Member: member})
+		return nil
+	})
+	return err
+}
+
+func (s *redisJobStore) Bury(ctx context.Context, job Job, now time.Time, reason string) error {
+	member := strconv.Itoa(job.ID)
+	_, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, redisJobKey+member, "status", JobStatusDead, "last_error", reason, "updated_at", now.UnixMilli())
+		pipe.ZRem(ctx, redisJobsDue, member)
+		pipe.ZAdd(ctx, redisJobDead, redis.Z{Score: float64(now.UnixMilli()), Member: member})
+		return nil
+	})
+	return err
+}
+
+func (s *redisJobStore) Get(ctx context.Context, id int) (Job, error) {
+	fields, err := s.client.HGetAll(ctx, redisJobKey+strconv.Itoa(id)).Result()
+	if err != nil {
+		return Job{}, err
+	}
+	return redisJob(id, fields)
+}
+
+func (s *redisJobStore) List(ctx context.Context, status, kind string, limit int) ([]Job, error) {
+	var members []string
+	for _, key := range []string{redisJobsDue, redisJobDead} {
+		if (key == redisJobDead) != (status == JobStatusDead) && status != "" {
+			continue
+		}
+		ids, err := s.client.ZRange(ctx, key, 0, -1).Result()
+		if err != nil {
+			return nil, err
+		}
+		members = append(members, ids...)
+	}
+
+	jobs := []Job{}
+	for _, member := range members {
+		id, err := strconv.Atoi(member)
+		if err != nil {
+			return nil, err
+		}
+		job, err := s.Get(ctx, id)
+		if err == errJobNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if (status == "" || job.Status == status) && (kind == "" || job.Kind == kind) {
+			jobs = append(jobs, job)
+		}
+		if len(jobs) == limit {
+			break
+		}
+	}
+	return jobs, nil
+}
+
+func (s *redisJobStore) Requeue(ctx context.Context, id int, now time.Time) (Job, error) {
+	member := strconv.Itoa(id)
+	result, err := redisRequeueJob.Run(ctx, s.client, []string{redisJobKey + member, redisJobsDue, redisJobDead},
+		member, now.UnixMilli()).Text()
+	if err != nil {
+		return Job{}, err
+	}
+	job, err := s.Get(ctx, id)
+	if err == nil && result != "requeued" {
+		err = errJobNotDead
+	}
+	return job, err
+}
+
+func (s *redisJobStore) Delete(ctx context.Context, id int) error {
+	member := strconv.Itoa(id)
+	var deleted *redis.IntCmd
+	_, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		deleted = pipe.Del(ctx, redisJobKey+member)
+		pipe.ZRem(ctx, redisJobsDue, member)
+		pipe.ZRem(ctx, redisJobDead, member)
+		return nil
+	})
+	if err == nil && deleted.Val() == 0 {
+		err = errJobNotFound
+	}
+	return err
+}
+
+// jobParam reads the job ID of a job route
+func jobParam(c *gin.Context) (int, bool) {
+	id, err := strconv.Atoi(c.Param("job_id"))
+	if err != nil {
+		abortWithError(c, errInvalidID("job"))
+		return 0, false
+	}
+	return id, true
+}
+
+// getJobs lists background jobs, e.g. the dead letters with ?status=dead
+func getJobs(c *gin.Context) {
+	status, kind := c.Query("status"), c.Query("kind")
+	var errors []string
+	if status != "" && status != JobStatusQueued && status != JobStatusRunning && status != JobStatusDead {
+		errors = append(errors, "Status must be queued, running or dead")
+	}
+	limit := defaultPageSize
+	if value := c.Query("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > maxPageSize {
+			errors = append(errors, fmt.Sprintf("Limit must be between 1 and %d", maxPageSize))
+		}
+		limit = n
+	}
+	if len(errors) > 0 {
+		abortWithError(c, errInvalidQuery(errors))
+		return
+	}
+
+	jobs, err := jobQueue.List(c.Request.Context(), status, kind, limit)
+	if err != nil {
+		abortWithError(c, errInternal("Failed to fetch jobs", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Status: "success",
+		Data:   jobs,
+	})
+}
+
+// getJob returns a background job with its payload and last error
+func getJob(c *gin.Context) {
+	id, ok := jobParam(c)
+	if !ok {
+		return
+	}
+	job, err := jobQueue.Get(c.Request.Context(), id)
+	if err == errJobNotFound {
+		abortWithError(c, errNotFound(CodeJobNotFound, "Job not found"))
+		return
+	}
+	if err != nil {
+		abortWithError(c, errInternal("Failed to fetch job", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Status: "success",
+		Data:   job,
+	})
+}
+
+// requeueJob queues a dead job again, with all its attempts
+func requeueJob(c *gin.Context) {
+	id, ok := jobParam(c)
+	if !ok {
+		return
+	}
+	job, err := jobQueue.Requeue(c.Request.Context(), id, time.Now())
+	switch {
+	case err == errJobNotFound:
+		abortWithError(c, errNotFound(CodeJobNotFound, "Job not found"))
+		return
+	case err == errJobNotDead:
+		abortWithError(c, &APIError{
+			Status:  http.StatusConflict,
+			Code:    CodeJobNotDead,
+			Message: "Only dead jobs can be requeued",
+			Data:    job,
+		})
+		return
+	case err != nil:
+		abortWithError(c, errInternal("Failed to requeue job", err))
+		return
+	}
+	wakeJobWorker()
+	auditChange(c, "requeue", "job", job.ID, nil, job.withoutPayload())
+
+	c.JSON(http.StatusOK, APIResponse{
+		Status:  "success",
+		Message: "Job requeued successfully",
+		Data:    job,
+	})
+}
+
+// deleteJob discards a job, e.g. a dead letter that shouldn't be retried
+func deleteJob(c *gin.Context) {
+	id, ok := jobParam(c)
+	if !ok {
+		return
+	}
+	job, err := jobQueue.Get(c.Request.Context(), id)
+	if err == nil {
+		err = jobQueue.Delete(c.Request.Context(), id)
+	}
+	if err == errJobNotFound {
+		abortWithError(c, errNotFound(CodeJobNotFound, "Job not found"))
+		return
+	}
+	if err != nil {
+		abortWithError(c, errInternal("Failed to delete job", err))
+		return
+	}
+	auditChange(c, "delete", "job", job.ID, job.withoutPayload(), nil)
+
+	c.JSON(http.StatusOK, APIResponse{
+		Status:  "success",
+		Message: "Job deleted successfully",
+	})
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJobQueue(t *testing.T) {
+	setupTestDB()
+	defer testDB.Close()
+	router := setupTestRouter()
+	defer delete(jobHandlers, "test.flaky")
+
+	failures := 0
+	jobHandlers["test.flaky"] = func(ctx context.Context, payload json.RawMessage) error {
+		if failures > 0 {
+			failures--
+			return errors.New("receiver unavailable")
+		}
+		return nil
+	}
+	send := func(method, url string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, adminRequest(method, url, nil))
+		return w
+	}
+	ctx := context.Background()
+
+	// A job out of attempts is kept as a dead letter
+	failures = 2
+	job, err := enqueueJob(ctx, "test.flaky", map[string]int{"n": 1}, 2)
+	assert.NoError(t, err)
+	now := time.Now()
+	n, _ := runDueJobs(ctx, now)
+	assert.Equal(t, 1, n)
+	n, _ = runDueJobs(ctx, now.Add(jobRetryBackoff))
+	assert.Equal(t, 1, n)
+	n, _ = runDueJobs(ctx, now.Add(time.Hour))
+	assert.Equal(t, 0, n)
+
+	w := send("GET", "/api/admin/jobs?status=dead")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var listed struct {
+		Data []Job `json:"data"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &listed)
+	if assert.Len(t, listed.Data, 1) {
+		assert.Equal(t, job.ID, listed.Data[0].ID)
+		assert.Equal(t, 2, listed.Data[0].Attempts)
+		assert.Equal(t, "receiver unavailable", listed.Data[0].LastError)
+		assert.JSONEq(t, `{"n": 1}`, string(listed.Data[0].Payload))
+	}
+	assert.Equal(t, http.StatusBadRequest, send("GET", "/api/admin/jobs?status=done").Code)
+	assert.Equal(t, http.StatusNotFound, send("GET", "/api/admin/jobs/9").Code)
+
+	// Requeued jobs get all their attempts again
+	w = send("POST", "/api/admin/jobs/1/requeue")
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var requeued struct {
+		Data Job `json:"data"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &requeued)
+	assert.Equal(t, JobStatusQueued, requeued.Data.Status)
+	assert.Zero(t, requeued.Data.Attempts)
+	assert.Equal(t, http.StatusConflict, send("POST", "/api/admin/jobs/1/requeue").Code)
+	n, _ = runDueJobs(ctx, time.Now())
+	assert.Equal(t, 1, n)
+	assert.Equal(t, http.StatusNotFound, send("GET", "/api/admin/jobs/1").Code)
+
+	// Jobs of unknown kinds fail for good at once
+	enqueueJob(ctx, "test.unknown", nil, 5)
+	runDueJobs(ctx, time.Now())
+	dead, err := jobQueue.Get(ctx, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, JobStatusDead, dead.Status)
+	assert.Equal(t, http.StatusOK, send("DELETE", "/api/admin/jobs/2").Code)
+	assert.Equal(t, http.StatusNotFound, send("DELETE", "/api/admin/jobs/2").Code)
+
+	// A job whose worker died runs again once its lease ends
+	enqueueJob(ctx, "test.flaky", nil, 3)
+	now = time.Now()
+	claimed, ok, err := jobQueue.Claim(ctx, now, jobLease)
+	assert.True(t, ok)
+	assert.NoError(t, err)
+	_, ok, _ = jobQueue.Claim(ctx, now.Add(time.Minute), jobLease)
+	assert.False(t, ok)
+	reclaimed, ok, _ := jobQueue.Claim(ctx, now.Add(jobLease), jobLease)
+	assert.True(t, ok)
+	assert.Equal(t, claimed.ID, reclaimed.ID)
+	assert.Equal(t, 2, reclaimed.Attempts)
+
+	assert.Equal(t, http.StatusForbidden, func() int {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/api/admin/jobs", nil)
+		router.ServeHTTP(w, req)
+		return w.Code
+	}())
+}
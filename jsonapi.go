@@ -0,0 +1,297 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// jsonAPIMediaType is the media type of JSON:API documents (jsonapi.org)
+const jsonAPIMediaType = "application/vnd.api+json"
+
+// jsonAPIResources maps the routes that serve surveys and responses to the
+// JSON:API type of their data. Other routes always answer in the envelope.
+var jsonAPIResources = map[string]string{
+	"/api/surveys":                            "surveys",
+	"/api/surveys/:id":                        "surveys",
+	"/api/surveys/:id/schedule":               "surveys",
+	"/api/surveys/:id/publish":                "surveys",
+	"/api/surveys/:id/responses":              "responses",
+	"/api/surveys/:id/responses/search":       "responses",
+	"/api/surveys/:id/responses/:response_id": "responses",
+	"/api/users/:user_identifier/responses":   "responses",
+}
+
+// jsonAPIDocument is a JSON:API top-level document
+type jsonAPIDocument struct {
+	JSONAPI  map[string]string      `json:"jsonapi"`
+	Data     interface{}            `json:"data,omitempty"`
+	Errors   []jsonAPIError         `json:"errors,omitempty"`
+	Included []jsonAPIResource      `json:"included,omitempty"`
+	Meta     map[string]interface{} `json:"meta,omitempty"`
+	Links    map[string]string      `json:"links,omitempty"`
+}
+
+// jsonAPIResource is a resource object: the fields of a survey or response
+// other than its ID and the IDs of related resources are attributes
+type jsonAPIResource struct {
+	Type          string                         `json:"type"`
+	ID            string                         `json:"id"`
+	Attributes    map[string]json.RawMessage     `json:"attributes"`
+	Relationships map[string]jsonAPIRelationship `json:"relationships,omitempty"`
+	Links         map[string]string              `json:"links,omitempty"`
+}
+
+// jsonAPIIdentifier identifies a related resource
+type jsonAPIIdentifier struct {
+	Type string `json:"type"`
+	ID   string `json:"id"`
+}
+
+// jsonAPIRelationship links a resource to others. Data is null for an empty
+// to-one relationship, and left out when only the related link is given.
+type jsonAPIRelationship struct {
+	Data  json.RawMessage   `json:"data,omitempty"`
+	Links map[string]string `json:"links,omitempty"`
+}
+
+// jsonAPIError is an error object; ID is the request ID
+type jsonAPIError struct {
+	ID     string `json:"id,omitempty"`
+	Status string `json:"status"`
+	Code   string `json:"code"`
+	Title  string `json:"title"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// jsonAPIWriter holds back the body a handler writes, for jsonAPIFormat to
+// reshape before sending it
+type jsonAPIWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *jsonAPIWriter) Write(data []byte) (int, error) {
+	return w.body.Write(data)
+}
+
+func (w *jsonAPIWriter) WriteString(s string) (int, error) {
+	return w.body.WriteString(s)
+}
+
+// WriteHeaderNow is deferred until the body is sent
+func (w *jsonAPIWriter) WriteHeaderNow() {}
+
+// wantsJSONAPI reports whether a request asks for JSON:API documents, with
+// ?format=jsonapi or an Accept header listing the JSON:API media type
+func wantsJSONAPI(r *http.Request) bool {
+	return r.URL.Query().Get("format") == "jsonapi" || strings.Contains(r.Header.Get("Accept"), jsonAPIMediaType)
+}
+
+// jsonAPIFormat serves the surveys and responses of the routes in
+// jsonAPIResources as JSON:API documents to the clients asking for them.
+// Errors are rendered by renderError, which checks the "jsonapi" flag.
+func jsonAPIFormat() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		typ, ok := jsonAPIResources[c.FullPath()]
+		if !ok {
+			c.Next()
+			return
+		}
+		c.Header("Vary", "Accept")
+		if !wantsJSONAPI(c.Request) {
+			c.Next()
+			return
+		}
+		c.Set("jsonapi", true)
+
+		w := &jsonAPIWriter{ResponseWriter: c.Writer}
+		c.Writer = w
+		c.Next()
+		c.Writer = w.ResponseWriter
+
+		if w.body.Len() == 0 {
+			if len(c.Errors) == 0 {
+				c.Writer.WriteHeaderNow()
+			}
+			return
+		}
+		body := w.body.Bytes()
+		var envelope struct {
+			APIResponse
+			Data json.RawMessage `json:"data"`
+			Meta json.RawMessage `json:"meta"`
+		}
+		if !strings.HasPrefix(c.Writer.Header().Get("Content-Type"), "application/json") || json.Unmarshal(body, &envelope) != nil {
+			c.Writer.Write(body)
+			return
+		}
+		doc, err := newJSONAPIDocument(c, typ, envelope.Message, envelope.Data, envelope.Meta)
+		if err != nil {
+			log.Printf("Failed to convert response to JSON:API: %v", err)
+			c.Writer.Write(body)
+			return
+		}
+		if body, err = json.Marshal(doc); err != nil {
+			log.Printf("Failed to encode JSON:API document: %v", err)
+			return
+		}
+		c.Writer.Header().Set("Content-Type", jsonAPIMediaType)
+		c.Writer.Write(body)
+	}
+}
+
+// newJSONAPIDocument reshapes the data and meta of an envelope into a document
+// of resources of typ. The message and meta go into the document's meta; a
+// next_cursor becomes the next link.
+func newJSONAPIDocument(c *gin.Context, typ, message string, data, meta json.RawMessage) (jsonAPIDocument, error) {
+	doc := jsonAPIDocument{
+		JSONAPI: map[string]string{"version": "1.0"},
+		Meta:    map[string]interface{}{},
+		Links:   map[string]string{"self": c.Request.URL.RequestURI()},
+	}
+	if message != "" {
+		doc.Meta["message"] = message
+	}
+	if len(meta) > 0 {
+		if err := json.Unmarshal(meta, &doc.Meta); err != nil {
+			return doc, err
+		}
+		if cursor, ok := doc.Meta["next_cursor"].(string); ok && cursor != "" {
+			query := c.Request.URL.Query()
+			query.Set("cursor", cursor)
+			doc.Links["next"] = c.Request.URL.Path + "?" + query.Encode()
+		}
+	}
+
+	included := map[string]bool{}
+	convert := func(raw json.RawMessage) (jsonAPIResource, error) {
+		resource, related, err := newJSONAPIResource(typ, raw)
+		for _, r := range related {
+			if key := r.Type + ":" + r.ID; !included[key] {
+				included[key] = true
+				doc.Included = append(doc.Included, r)
+			}
+		}
+		return resource, err
+	}
+
+	switch {
+	case len(data) == 0 || string(data) == "null":
+	case data[0] == '[':
+		var items []json.RawMessage
+		if err := json.Unmarshal(data, &items); err != nil {
+			return doc, err
+		}
+		resources := []jsonAPIResource{}
+		for _, item := range items {
+			resource, err := convert(item)
+			if err != nil {
+				return doc, err
+			}
+			resources = append(resources, resource)
+		}
+		doc.Data = resources
+	default:
+		resource, err := convert(data)
+		if err != nil {
+			return doc, err
+		}
+		doc.Data = resource
+	}
+	if len(doc.Meta) == 0 {
+		doc.Meta = nil
+	}
+	return doc, nil
+}
+
+// newJSONAPIResource turns a survey or response into a resource object,
+// returning the resources it embeds (the survey of a user's response) to be
+// included alongside
+func newJSONAPIResource(typ string, raw json.RawMessage) (jsonAPIResource, []jsonAPIResource, error) {
+	var attributes map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &attributes); err != nil {
+		return jsonAPIResource{}, nil, err
+	}
+	var id int
+	json.Unmarshal(attributes["id"], &id)
+	delete(attributes, "id")
+	resource := jsonAPIResource{Type: typ, ID: strconv.Itoa(id), Attributes: attributes, Relationships: map[string]jsonAPIRelationship{}}
+
+	// takeID removes the ID of a related resource from the attributes
+	takeID := func(key string) (string, bool) {
+		var relatedID *int
+		json.Unmarshal(attributes[key], &relatedID)
+		delete(attributes, key)
+		if relatedID == nil {
+			return "", false
+		}
+		return strconv.Itoa(*relatedID), true
+	}
+	toOne := func(name, relatedType, key string) {
+		relationship := jsonAPIRelationship{Data: json.RawMessage("null")}
+		if relatedID, ok := takeID(key); ok {
+			relationship.Data, _ = json.Marshal(jsonAPIIdentifier{Type: relatedType, ID: relatedID})
+		}
+		resource.Relationships[name] = relationship
+	}
+
+	var included []jsonAPIResource
+	switch typ {
+	case "surveys":
+		resource.Links = map[string]string{"self": "/api/surveys/" + resource.ID}
+		toOne("organization", "organizations", "organization_id")
+		toOne("owner", "creators", "owner_id")
+		resource.Relationships["responses"] = jsonAPIRelationship{Links: map[string]string{"related": "/api/surveys/" + resource.ID + "/responses"}}
+
+	case "responses":
+		surveyID, ok := takeID("survey_id")
+		// Responses of a user embed their survey
+		if survey, embedded := attributes["survey"]; embedded {
+			delete(attributes, "survey")
+			surveyResource, _, err := newJSONAPIResource("surveys", survey)
+			if err != nil {
+				return resource, nil, err
+			}
+			surveyID, ok = surveyResource.ID, true
+			included = append(included, surveyResource)
+		}
+		if ok {
+			data, _ := json.Marshal(jsonAPIIdentifier{Type: "surveys", ID: surveyID})
+			resource.Relationships["survey"] = jsonAPIRelationship{Data: data, Links: map[string]string{"related": "/api/surveys/" + surveyID}}
+			resource.Links = map[string]string{"self": "/api/surveys/" + surveyID + "/responses/" + resource.ID}
+		}
+	}
+	return resource, included, nil
+}
+
+// renderJSONAPIError writes err as a JSON:API error document: one error per
+// detail in err.Errors, or one with the message alone
+func renderJSONAPIError(c *gin.Context, err *APIError) {
+	doc := jsonAPIDocument{JSONAPI: map[string]string{"version": "1.0"}}
+	base := jsonAPIError{ID: requestID(c), Status: strconv.Itoa(err.Status), Code: err.Code, Title: err.Message}
+	for _, detail := range err.Errors {
+		e := base
+		e.Detail = detail
+		doc.Errors = append(doc.Errors, e)
+	}
+	if len(doc.Errors) == 0 {
+		doc.Errors = []jsonAPIError{base}
+	}
+	if err.Data != nil {
+		doc.Meta = map[string]interface{}{"data": err.Data}
+	}
+	body, marshalErr := json.Marshal(doc)
+	if marshalErr != nil {
+		log.Printf("Failed to encode JSON:API error: %v", marshalErr)
+		c.AbortWithStatus(err.Status)
+		return
+	}
+	c.Abort()
+	c.Data(err.Status, jsonAPIMediaType, body)
+}
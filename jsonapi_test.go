@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJSONAPIFormat(t *testing.T) {
+	setupTestDB()
+	defer testDB.Close()
+	router := setupTestRouter()
+
+	testDB.Exec("INSERT INTO organizations (name) VALUES ('Acme')")
+	testDB.Exec(`INSERT INTO surveys (title, description, org_id) VALUES ('Feedback', 'd', 1)`)
+	testDB.Exec(`INSERT INTO survey_responses (survey_id, user_identifier, response_data) VALUES (1, 'user1', '{"q": 1}'), (1, 'user1', '{"q": 2}')`)
+
+	get := func(url, accept string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", url, nil)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	type resource struct {
+		Type          string                     `json:"type"`
+		ID            string                     `json:"id"`
+		Attributes    map[string]json.RawMessage `json:"attributes"`
+		Relationships map[string]struct {
+			Data  json.RawMessage   `json:"data"`
+			Links map[string]string `json:"links"`
+		} `json:"relationships"`
+		Links map[string]string `json:"links"`
+	}
+
+	// Surveys are resources, related to their organization and responses
+	w := get("/api/surveys/1", jsonAPIMediaType)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, jsonAPIMediaType, w.Header().Get("Content-Type"))
+	assert.Equal(t, "Accept", w.Header().Get("Vary"))
+	var single struct {
+		Data resource `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &single))
+	survey := single.Data
+	assert.Equal(t, "surveys", survey.Type)
+	assert.Equal(t, "1", survey.ID)
+	assert.JSONEq(t, `"Feedback"`, string(survey.Attributes["title"]))
+	assert.NotContains(t, survey.Attributes, "id")
+	assert.NotContains(t, survey.Attributes, "organization_id")
+	assert.JSONEq(t, `{"type":"organizations","id":"1"}`, string(survey.Relationships["organization"].Data))
+	assert.JSONEq(t, `null`, string(survey.Relationships["owner"].Data))
+	assert.Equal(t, "/api/surveys/1/responses", survey.Relationships["responses"].Links["related"])
+	assert.Equal(t, "/api/surveys/1", survey.Links["self"])
+
+	// Pages link to the next one
+	w = get("/api/surveys/1/responses?format=jsonapi&limit=1", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var list struct {
+		Data  []resource             `json:"data"`
+		Meta  map[string]interface{} `json:"meta"`
+		Links map[string]string      `json:"links"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+	require.Len(t, list.Data, 1)
+	assert.Equal(t, "responses", list.Data[0].Type)
+	assert.JSONEq(t, `{"type":"surveys","id":"1"}`, string(list.Data[0].Relationships["survey"].Data))
+	assert.Equal(t, "/api/surveys/1/responses/"+list.Data[0].ID, list.Data[0].Links["self"])
+	assert.NotContains(t, list.Data[0].Attributes, "survey_id")
+	assert.Contains(t, list.Links["next"], "cursor=")
+	assert.Contains(t, list.Links["next"], "format=jsonapi")
+	assert.NotEmpty(t, list.Meta["next_cursor"])
+
+	// A user's responses include their survey once
+	w = get("/api/users/user1/responses", jsonAPIMediaType)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var compound struct {
+		Data     []resource `json:"data"`
+		Included []resource `json:"included"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &compound))
+	assert.Len(t, compound.Data, 2)
+	if assert.Len(t, compound.Included, 1) {
+		assert.Equal(t, "surveys", compound.Included[0].Type)
+	}
+	assert.NotContains(t, compound.Data[0].Attributes, "survey")
+
+	// Errors are error objects
+	w = get("/api/surveys/999", jsonAPIMediaType)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, jsonAPIMediaType, w.Header().Get("Content-Type"))
+	var failed struct {
+		Errors []jsonAPIError `json:"errors"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &failed))
+	if assert.Len(t, failed.Errors, 1) {
+		assert.Equal(t, "404", failed.Errors[0].Status)
+		assert.Equal(t, CodeSurveyNotFound, failed.Errors[0].Code)
+		assert.NotEmpty(t, failed.Errors[0].ID)
+	}
+	w = get("/api/surveys?format=jsonapi&sort=bogus", "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), `"detail":`)
+
+	// Other clients and routes keep the envelope
+	w = get("/api/surveys/1", "")
+	assert.Equal(t, "application/json; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Body.String(), `"status":"success"`)
+	w = get("/api/surveys/1/results", jsonAPIMediaType)
+	assert.Contains(t, w.Body.String(), `"status":"success"`)
+}
@@ -0,0 +1,293 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Advisory warnings found by lintSurvey; unlike validation issues they don't
+// keep a survey from being published
+const (
+	LintDoubleBarreled  = "double_barreled"
+	LintTooManyRequired = "too_many_required"
+	LintPreferNotToSay  = "missing_prefer_not_to_say"
+	LintUnreachable     = "unreachable_question"
+)
+
+// Surveys requiring more than lintMaxRequired questions, or more than
+// lintRequiredRatio of at least lintRequiredMinQuestions, get a warning
+const (
+	lintMaxRequired          = 10
+	lintRequiredRatio        = 0.8
+	lintRequiredMinQuestions = 5
+)
+
+// doubleBarreledPattern finds labels that likely ask two things at once
+var doubleBarreledPattern = regexp.MustCompile(`(?i)\w\s+(and|&|as well as)\s+\w`)
+
+// sensitivePattern finds questions about demographics respondents may not want to share
+var sensitivePattern = regexp.MustCompile(`(?i)\b(age|gender|sex|sexual orientation|income|salary|ethnicity|ethnic|race|religion|religious|disability|disabilities|marital)\b`)
+
+// preferNotToSayPattern finds options letting respondents decline to answer
+var preferNotToSayPattern = regexp.MustCompile(`(?i)prefer not|rather not|decline|don't want to say`)
+
+// LintWarning is advice on improving a survey's design
+type LintWarning struct {
+	Code string `json:"code"`
+	// Question is the ID of the question the warning is about, if any
+	Question string `json:"question,omitempty"`
+	Message  string `json:"message"`
+}
+
+// SurveyLint lists the advisory warnings of a survey
+type SurveyLint struct {
+	SurveyID int           `json:"survey_id"`
+	Warnings []LintWarning `json:"warnings"`
+}
+
+// finiteAnswers lists every answer to q when there are few enough to reason
+// about logic rules, or returns nil
+func finiteAnswers(q Question) []json.RawMessage {
+	var answers []json.RawMessage
+	switch q.Type {
+	case QuestionBoolean:
+		answers = []json.RawMessage{json.RawMessage(`true`), json.RawMessage(`false`)}
+	case QuestionSingleChoice:
+		for _, option := range q.Options {
+			answer, _ := json.Marshal(option)
+			answers = append(answers, answer)
+		}
+	case QuestionRating:
+		min, max := defaultRatingMin, defaultRatingMax
+		if q.Min != nil {
+			min = int(*q.Min)
+		}
+		if q.Max != nil {
+			max = int(*q.Max)
+		}
+		if max-min > 100 {
+			return nil
+		}
+		for n := min; n <= max; n++ {
+			answers = append(answers, json.RawMessage(strconv.Itoa(n)))
+		}
+	}
+	return answers
+}
+
+// ruleMatches reports whether an answer to a single-valued question satisfies
+// a logic rule's condition. Values compare as text, like answer filters, so
+// "5" and 5 are equal.
+func ruleMatches(rule LogicRule, answer json.RawMessage) bool {
+	a, v := answerText(answer), answerText(rule.Value)
+	switch rule.Operator {
+	case "eq":
+		return a == v
+	case "ne":
+		return a != v
+	case "gt", "gte", "lt", "lte":
+		x, errA := strconv.ParseFloat(a, 64)
+		y, errV := strconv.ParseFloat(v, 64)
+		if errA != nil || errV != nil {
+			return false
+		}
+		switch rule.Operator {
+		case "gt":
+			return x > y
+		case "gte":
+			return x >= y
+		case "lt":
+			return x < y
+		}
+		return x <= y
+	}
+	return false
+}
+
+// unreachableQuestions finds the questions no respondent can get to: those
+// hidden or skipped past whatever the answer to a required question is, and
+// those only shown by questions that are unreachable themselves. Broken rules
+// are left to validateSurvey.
+func unreachableQuestions(survey Survey) map[string]bool {
+	questions := map[string]Question{}
+	for _, q := range survey.Questions {
+		questions[q.ID] = q
+	}
+	pageIndex := map[string]int{}
+	for i, p := range survey.Pages {
+		pageIndex[p.ID] = i
+	}
+
+	unreachable := map[string]bool{}
+	// Each pass may make questions depending on newly unreachable ones unreachable
+	for changed := true; changed; {
+		changed = false
+		mark := func(id string) {
+			if _, ok := questions[id]; ok && !unreachable[id] {
+				unreachable[id] = true
+				changed = true
+			}
+		}
+
+		shownBy := map[string][]string{}
+		rulesOf := map[string][]LogicRule{}
+		for _, rule := range survey.Logic {
+			if _, ok := questions[rule.Question]; !ok {
+				continue
+			}
+			if rule.Action == LogicShowQuestion {
+				shownBy[rule.Target] = append(shownBy[rule.Target], rule.Question)
+			}
+			rulesOf[rule.Question] = append(rulesOf[rule.Question], rule)
+		}
+
+		for target, sources := range shownBy {
+			all := true
+			for _, source := range sources {
+				all = all && unreachable[source]
+			}
+			if all {
+				mark(target)
+			}
+		}
+
+		for _, source := range survey.Questions {
+			answers := finiteAnswers(source)
+			// Optional questions may be left blank, which matches no rule
+			if unreachable[source.ID] || !source.Required || len(answers) == 0 {
+				continue
+			}
+
+			hiddenAlways := map[string]bool{}
+			for _, rule := range rulesOf[source.ID] {
+				if rule.Action == LogicHideQuestion {
+					hiddenAlways[rule.Target] = true
+				}
+			}
+			// The earliest page every answer skips to
+			skipTo, skipsAlways := len(survey.Pages), len(survey.Pages) > 0
+			for _, answer := range answers {
+				hidden := map[string]bool{}
+				skip := -1
+				for _, rule := range rulesOf[source.ID] {
+					if !ruleMatches(rule, answer) {
+						continue
+					}
+					switch rule.Action {
+					case LogicHideQuestion:
+						hidden[rule.Target] = true
+					case LogicSkipToPage:
+						if page, ok := pageIndex[rule.Target]; ok && (skip < 0 || page < skip) {
+							skip = page
+						}
+					}
+				}
+				for target := range hiddenAlways {
+					hiddenAlways[target] = hiddenAlways[target] && hidden[target]
+				}
+				if skip < 0 {
+					skipsAlways = false
+				} else if skip < skipTo {
+					skipTo = skip
+				}
+			}
+			for target, always := range hiddenAlways {
+				if always {
+					mark(target)
+				}
+			}
+
+			if page, ok := pageIndex[source.Page]; ok && skipsAlways {
+				for _, q := range survey.Questions {
+					if p, ok := pageIndex[q.Page]; ok && p > page && p < skipTo {
+						mark(q.ID)
+					}
+				}
+			}
+		}
+	}
+	return unreachable
+}
+
+// lintSurvey finds design problems worth a second look: questions asking two
+// things at once, too many required questions, sensitive questions respondents
+// can't decline, and questions the logic makes unreachable
+func lintSurvey(survey Survey) SurveyLint {
+	warnings := []LintWarning{}
+	add := func(code, question, format string, args ...interface{}) {
+		warnings = append(warnings, LintWarning{Code: code, Question: question, Message: fmt.Sprintf(format, args...)})
+	}
+
+	required := 0
+	for _, q := range survey.Questions {
+		if q.Required {
+			required++
+		}
+
+		if q.Type != QuestionMultipleChoice && (doubleBarreledPattern.MatchString(q.Label) || strings.Count(q.Label, "?") > 1) {
+			add(LintDoubleBarreled, q.ID, "Question %s may ask two things at once; consider splitting it", q.ID)
+		}
+
+		if sensitivePattern.MatchString(q.Label) || sensitivePattern.MatchString(strings.ReplaceAll(q.ID, "_", " ")) {
+			switch q.Type {
+			case QuestionSingleChoice, QuestionMultipleChoice:
+				declinable := false
+				for _, option := range q.Options {
+					declinable = declinable || preferNotToSayPattern.MatchString(option)
+				}
+				if !declinable {
+					add(LintPreferNotToSay, q.ID, `Question %s asks about a sensitive topic; add a "Prefer not to say" option`, q.ID)
+				}
+			default:
+				if q.Required {
+					add(LintPreferNotToSay, q.ID, "Question %s asks about a sensitive topic; consider making it optional", q.ID)
+				}
+			}
+		}
+	}
+
+	if required > lintMaxRequired ||
+		(len(survey.Questions) >= lintRequiredMinQuestions && float64(required) > lintRequiredRatio*float64(len(survey.Questions))) {
+		add(LintTooManyRequired, "", "%d of %d questions are required; respondents facing many required questions drop out or answer carelessly",
+			required, len(survey.Questions))
+	}
+
+	unreachable := unreachableQuestions(survey)
+	for _, q := range survey.Questions {
+		if unreachable[q.ID] {
+			add(LintUnreachable, q.ID, "Question %s can never be shown because of the survey's logic", q.ID)
+		}
+	}
+
+	return SurveyLint{SurveyID: survey.ID, Warnings: warnings}
+}
+
+// getSurveyLint returns advisory warnings on a survey's design
+func getSurveyLint(c *gin.Context) {
+	surveyID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		abortWithError(c, errInvalidID("survey"))
+		return
+	}
+	survey, err := findSurvey(c.Request.Context(), surveyID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			abortWithError(c, errNotFound(CodeSurveyNotFound, "Survey not found"))
+			return
+		}
+		abortWithError(c, errInternal("Failed to fetch survey", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Status: "success",
+		Data:   lintSurvey(survey),
+	})
+}
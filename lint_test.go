@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLintSurvey(t *testing.T) {
+	codes := func(lint SurveyLint) map[string][]string {
+		codes := map[string][]string{}
+		for _, w := range lint.Warnings {
+			codes[w.Code] = append(codes[w.Code], w.Question)
+		}
+		return codes
+	}
+
+	clean := Survey{Questions: []Question{
+		{ID: "rating", Type: QuestionRating, Label: "How satisfied are you?", Required: true},
+		{ID: "age", Type: QuestionSingleChoice, Label: "Your age", Options: []string{"Under 30", "30 or over", "Prefer not to say"}},
+		{ID: "why", Type: QuestionText, Label: "What could we improve?"},
+	}}
+	assert.Empty(t, lintSurvey(clean).Warnings)
+
+	survey := Survey{Questions: []Question{
+		{ID: "speed", Type: QuestionRating, Label: "How fast and friendly was our support?"},
+		{ID: "again", Type: QuestionBoolean, Label: "Did it work? Would you come back?"},
+		{ID: "gender", Type: QuestionSingleChoice, Label: "Gender", Options: []string{"Woman", "Man", "Non-binary"}},
+		{ID: "household_income", Type: QuestionNumber, Label: "Yearly household earnings", Required: true},
+		{ID: "tools", Type: QuestionMultipleChoice, Label: "Which do you use: email and chat?", Options: []string{"Email", "Chat"}},
+	}}
+	lint := lintSurvey(survey)
+	assert.Equal(t, map[string][]string{
+		LintDoubleBarreled: {"speed", "again"},
+		LintPreferNotToSay: {"gender", "household_income"},
+	}, codes(lint))
+
+	for i := range survey.Questions {
+		survey.Questions[i].Required = true
+	}
+	assert.Contains(t, codes(lintSurvey(survey)), LintTooManyRequired)
+}
+
+func TestUnreachableQuestions(t *testing.T) {
+	survey := Survey{
+		Pages: []Page{{ID: "p1"}, {ID: "p2"}, {ID: "p3"}},
+		Questions: []Question{
+			{ID: "member", Type: QuestionBoolean, Label: "Member?", Required: true, Page: "p1"},
+			{ID: "score", Type: QuestionRating, Label: "Score", Required: true, Page: "p1"},
+			{ID: "plan", Type: QuestionSingleChoice, Label: "Plan", Options: []string{"Basic", "Pro"}, Page: "p1"},
+			{ID: "upgrade", Type: QuestionBoolean, Label: "Upgrade?", Page: "p2"},
+			{ID: "reason", Type: QuestionText, Label: "Why?", Page: "p3"},
+			{ID: "followup", Type: QuestionText, Label: "More?", Page: "p3"},
+		},
+		Logic: []LogicRule{
+			// Every score skips page 2
+			{Question: "score", Operator: "lte", Value: json.RawMessage(`3`), Action: LogicSkipToPage, Target: "p3"},
+			{Question: "score", Operator: "gt", Value: json.RawMessage(`"3"`), Action: LogicSkipToPage, Target: "p3"},
+			// Shown only by a question that is never shown
+			{Question: "upgrade", Operator: "eq", Value: json.RawMessage(`true`), Action: LogicShowQuestion, Target: "reason"},
+			// Hidden whatever the member answers
+			{Question: "member", Operator: "eq", Value: json.RawMessage(`true`), Action: LogicHideQuestion, Target: "followup"},
+			{Question: "member", Operator: "ne", Value: json.RawMessage(`true`), Action: LogicHideQuestion, Target: "followup"},
+			// Optional questions can be left blank, so their rules never always apply
+			{Question: "plan", Operator: "ne", Value: json.RawMessage(`"Enterprise"`), Action: LogicHideQuestion, Target: "reason"},
+		},
+	}
+	assert.Equal(t, map[string]bool{"upgrade": true, "reason": true, "followup": true}, unreachableQuestions(survey))
+
+	// Answers that don't skip keep the pages in between reachable
+	survey.Logic = survey.Logic[1:]
+	assert.Equal(t, map[string]bool{"followup": true}, unreachableQuestions(survey))
+}
+
+func TestGetSurveyLint(t *testing.T) {
+	setupTestDB()
+	defer testDB.Close()
+
+	result, err := testDB.Exec(`INSERT INTO surveys (title, description, questions) VALUES ('Lint', 'd',
+		'[{"id":"price","type":"rating","label":"Rate our price and quality"}]')`)
+	assert.NoError(t, err)
+	surveyID, _ := result.LastInsertId()
+	router := setupTestRouter()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", fmt.Sprintf("/api/surveys/%d/lint", surveyID), nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response struct {
+		Data SurveyLint `json:"data"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	assert.Equal(t, int(surveyID), response.Data.SurveyID)
+	if assert.Len(t, response.Data.Warnings, 1) {
+		assert.Equal(t, LintDoubleBarreled, response.Data.Warnings[0].Code)
+		assert.Equal(t, "price", response.Data.Warnings[0].Question)
+	}
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/surveys/999/lint", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
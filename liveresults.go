@@ -0,0 +1,233 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"reflect"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/net/websocket"
+)
+
+// Live results settings. A survey's live results are recomputed at most every
+// LIVE_RESULTS_INTERVAL after a response event, and every LIVE_RESULTS_POLL
+// regardless, to pick up responses submitted through other instances.
+var (
+	liveResultsInterval      = envDuration("LIVE_RESULTS_INTERVAL", time.Second)
+	liveResultsPoll          = envDuration("LIVE_RESULTS_POLL", 10*time.Second)
+	liveResultsMaxConns      = envInt("LIVE_RESULTS_MAX_CONNECTIONS", 1000)
+	liveResultsMaxPerSurvey  = envInt("LIVE_RESULTS_MAX_PER_SURVEY", 250)
+	liveResultsWriteDeadline = 10 * time.Second
+)
+
+// errLiveResultsFull refuses subscriptions beyond the connection limits
+var errLiveResultsFull = errors.New("too many live results connections")
+
+// liveResults broadcasts the results of the surveys being watched;
+// emitEvent wakes it on response events
+var liveResults = newLiveResultsHub()
+
+// liveSubscriber is one connection watching a survey. Its channel holds the
+// latest results only: a slow client skips intermediate ones.
+type liveSubscriber struct {
+	results chan SurveyResults
+}
+
+// push replaces the results waiting to be sent, if any
+func (s *liveSubscriber) push(results SurveyResults) {
+	select {
+	case <-s.results:
+	default:
+	}
+	s.results <- results
+}
+
+// liveChannel is a survey being watched, with the results last broadcast
+type liveChannel struct {
+	subscribers map[*liveSubscriber]bool
+	wake        chan struct{}
+	stop        context.CancelFunc
+	last        *SurveyResults
+}
+
+// liveResultsHub keeps a channel per watched survey, each run by a goroutine
+// that recomputes the results and sends them to the subscribers when they change
+type liveResultsHub struct {
+	mu           sync.Mutex
+	channels     map[int]*liveChannel
+	conns        int
+	interval     time.Duration
+	poll         time.Duration
+	maxConns     int
+	maxPerSurvey int
+	compute      func(ctx context.Context, surveyID int) (SurveyResults, error)
+}
+
+// newLiveResultsHub returns a hub computing unfiltered results with computeSurveyResults
+func newLiveResultsHub() *liveResultsHub {
+	return &liveResultsHub{
+		channels:     map[int]*liveChannel{},
+		interval:     liveResultsInterval,
+		poll:         liveResultsPoll,
+		maxConns:     liveResultsMaxConns,
+		maxPerSurvey: liveResultsMaxPerSurvey,
+		compute: func(ctx context.Context, surveyID int) (SurveyResults, error) {
+			ctx, cancel := context.WithTimeout(ctx, analyticsTimeout)
+			defer cancel()
+			return computeSurveyResults(ctx, responseListQuery{SurveyID: surveyID})
+		},
+	}
+}
+
+// subscribe watches a survey's results, starting its channel when it's the
+// first subscriber. The subscriber gets the results last broadcast right
+// away; unsubscribe must be called once it's done.
+func (h *liveResultsHub) subscribe(surveyID int) (sub *liveSubscriber, unsubscribe func(), err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	ch := h.channels[surveyID]
+	if h.conns >= h.maxConns || (ch != nil && len(ch.subscribers) >= h.maxPerSurvey) {
+		return nil, nil, errLiveResultsFull
+	}
+	if ch == nil {
+		ctx, stop := context.WithCancel(context.Background())
+		ch = &liveChannel{subscribers: map[*liveSubscriber]bool{}, wake: make(chan struct{}, 1), stop: stop}
+		h.channels[surveyID] = ch
+		go h.run(ctx, surveyID, ch)
+	}
+
+	sub = &liveSubscriber{results: make(chan SurveyResults, 1)}
+	ch.subscribers[sub] = true
+	h.conns++
+	if ch.last != nil {
+		sub.push(*ch.last)
+	}
+	return sub, func() { h.unsubscribe(surveyID, sub) }, nil
+}
+
+// unsubscribe stops watching, stopping the survey's channel with its last subscriber
+func (h *liveResultsHub) unsubscribe(surveyID int, sub *liveSubscriber) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	ch := h.channels[surveyID]
+	if ch == nil || !ch.subscribers[sub] {
+		return
+	}
+	delete(ch.subscribers, sub)
+	h.conns--
+	if len(ch.subscribers) == 0 {
+		ch.stop()
+		delete(h.channels, surveyID)
+	}
+}
+
+// notify wakes the channel of a survey, if it is watched, to recompute its results
+func (h *liveResultsHub) notify(surveyID int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if ch := h.channels[surveyID]; ch != nil {
+		select {
+		case ch.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// run recomputes a survey's results when woken or polled, broadcasting them
+// when they changed, until the channel is stopped
+func (h *liveResultsHub) run(ctx context.Context, surveyID int, ch *liveChannel) {
+	poll := time.NewTicker(h.poll)
+	defer poll.Stop()
+	for {
+		results, err := h.compute(ctx, surveyID)
+		if err != nil && ctx.Err() == nil {
+			log.Printf("Live results: failed to compute survey %d: %v", surveyID, err)
+		}
+		if err == nil {
+			h.broadcast(ch, results)
+		}
+
+		// Bursts of responses are broadcast once per interval
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(h.interval):
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ch.wake:
+		case <-poll.C:
+		}
+	}
+}
+
+// broadcast sends results to a channel's subscribers, unless they didn't change
+func (h *liveResultsHub) broadcast(ch *liveChannel, results SurveyResults) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if ch.last != nil && ch.last.ResponsesCount == results.ResponsesCount && reflect.DeepEqual(ch.last.Questions, results.Questions) {
+		return
+	}
+	ch.last = &results
+	for sub := range ch.subscribers {
+		sub.push(results)
+	}
+}
+
+// streamLiveResults upgrades to a WebSocket sending a survey's unfiltered
+// results, as served by GET /api/surveys/:id/results, whenever they change.
+// Messages from the client are ignored; it watches until it disconnects.
+func streamLiveResults(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		abortWithError(c, errInvalidID("survey"))
+		return
+	}
+	if _, err := findSurvey(c.Request.Context(), id); err != nil {
+		abortWithError(c, errNotFound(CodeSurveyNotFound, "Survey not found"))
+		return
+	}
+
+	hub := liveResults
+	sub, unsubscribe, err := hub.subscribe(id)
+	if err != nil {
+		c.Header("Retry-After", strconv.Itoa(int(hub.poll.Seconds())))
+		abortWithError(c, &APIError{
+			Status:  http.StatusServiceUnavailable,
+			Code:    CodeLiveResultsFull,
+			Message: "Too many clients are watching live results, try again later",
+		})
+		return
+	}
+	defer unsubscribe()
+
+	websocket.Server{Handler: func(ws *websocket.Conn) {
+		// The server's read and write timeouts would close long-lived connections
+		ws.SetDeadline(time.Time{})
+		closed := make(chan struct{})
+		go func() {
+			defer close(closed)
+			var msg []byte
+			for websocket.Message.Receive(ws, &msg) == nil {
+			}
+		}()
+
+		for {
+			select {
+			case <-closed:
+				return
+			case results := <-sub.results:
+				ws.SetWriteDeadline(time.Now().Add(liveResultsWriteDeadline))
+				if err := websocket.JSON.Send(ws, APIResponse{Status: "success", Data: results}); err != nil {
+					return
+				}
+			}
+		}
+	}}.ServeHTTP(c.Writer, c.Request)
+}
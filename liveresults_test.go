@@ -0,0 +1,75 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/websocket"
+)
+
+func TestLiveResults(t *testing.T) {
+	setupTestDB()
+	defer testDB.Close()
+	server := httptest.NewServer(setupTestRouter())
+	defer server.Close()
+
+	hub := newLiveResultsHub()
+	hub.interval, hub.maxPerSurvey = 10*time.Millisecond, 1
+	liveResults = hub
+	defer func() { liveResults = newLiveResultsHub() }()
+
+	testDB.Exec("INSERT INTO surveys (title, description) VALUES ('Poll', 'd')")
+	testDB.Exec(`INSERT INTO survey_responses (survey_id, user_identifier, response_data) VALUES (1, 'user1', '{"color": "red"}')`)
+
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/api/surveys/1/results/live"
+	ws, err := websocket.Dial(wsURL, "", server.URL)
+	require.NoError(t, err)
+	defer ws.Close()
+
+	receive := func() SurveyResults {
+		var msg struct {
+			Data SurveyResults `json:"data"`
+		}
+		ws.SetReadDeadline(time.Now().Add(5 * time.Second))
+		require.NoError(t, websocket.JSON.Receive(ws, &msg))
+		return msg.Data
+	}
+	results := receive()
+	assert.Equal(t, 1, results.ResponsesCount)
+	assert.Equal(t, map[string]int{"red": 1}, results.Questions[0].Values)
+
+	// Submissions are broadcast to the survey's watchers
+	resp, err := http.Post(server.URL+"/api/surveys/1/responses", "application/json",
+		strings.NewReader(`{"survey_response":{"user_identifier":"user2","response_data":{"color":"blue"}}}`))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	results = receive()
+	assert.Equal(t, 2, results.ResponsesCount)
+	assert.Equal(t, map[string]int{"red": 1, "blue": 1}, results.Questions[0].Values)
+
+	// Connections beyond the limit are refused
+	resp, err = http.Get(server.URL + "/api/surveys/1/results/live")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	assert.NotEmpty(t, resp.Header.Get("Retry-After"))
+
+	resp, err = http.Get(server.URL + "/api/surveys/999/results/live")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	// Channels stop with their last watcher
+	ws.Close()
+	assert.Eventually(t, func() bool {
+		hub.mu.Lock()
+		defer hub.mu.Unlock()
+		return len(hub.channels) == 0 && hub.conns == 0
+	}, 5*time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"log/slog"
+	"os"
+	"regexp"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// requestIDHeader carries the request ID in requests and responses
+const requestIDHeader = "X-Request-ID"
+
+// requestIDPattern limits the request IDs accepted from clients or proxies
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// initLogging makes slog, and the standard log package through it, write JSON lines to stdout
+func initLogging() {
+	level := slog.LevelInfo
+	if os.Getenv("LOG_LEVEL") == "debug" {
+		level = slog.LevelDebug
+	}
+	slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: level})))
+}
+
+// requestIDs keeps the X-Request-ID of the request, or generates one, and echoes
+// it in the response so errors can be matched with the logs
+func requestIDs() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(requestIDHeader)
+		if !requestIDPattern.MatchString(id) {
+			id = randomHex(16)
+		}
+		c.Set("request_id", id)
+		c.Header(requestIDHeader, id)
+		c.Next()
+	}
+}
+
+// requestID returns the ID of the current request
+func requestID(c *gin.Context) string {
+	return c.GetString("request_id")
+}
+
+// requestLogger logs one structured line per request, at warn level for client
+// errors and error level for server errors
+func requestLogger() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		status := c.Writer.Status()
+		attrs := []slog.Attr{
+			slog.String("request_id", requestID(c)),
+			slog.String("method", c.Request.Method),
+			slog.String("path", c.Request.URL.Path),
+			slog.String("route", c.FullPath()),
+			slog.Int("status", status),
+			slog.Int("bytes", c.Writer.Size()),
+			slog.Float64("duration_ms", float64(time.Since(start).Microseconds())/1000),
+			slog.String("client_ip", c.ClientIP()),
+		}
+		if span := trace.SpanContextFromContext(c.Request.Context()); span.HasTraceID() {
+			attrs = append(attrs, slog.String("trace_id", span.TraceID().String()))
+		}
+		if len(c.Errors) > 0 {
+			attrs = append(attrs, slog.Any("errors", c.Errors.Errors()))
+		}
+
+		level := slog.LevelInfo
+		switch {
+		case status >= 500:
+			level = slog.LevelError
+		case status >= 400:
+			level = slog.LevelWarn
+		}
+		slog.LogAttrs(c.Request.Context(), level, "request", attrs...)
+	}
+}
+
+// recoverPanic answers requests whose handler panicked with a 500 and logs the panic
+func recoverPanic(c *gin.Context, err interface{}) {
+	slog.Error("panic", "request_id", requestID(c), "path", c.Request.URL.Path, "error", err)
+	renderError(c, errInternal("Internal server error", nil))
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestIDs(t *testing.T) {
+	setupTestDB()
+	defer testDB.Close()
+
+	router := setupTestRouter()
+
+	// Generated IDs are returned in the header and error payloads
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/surveys/999", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	id := w.Header().Get(requestIDHeader)
+	assert.Len(t, id, 32)
+	var response APIResponse
+	json.Unmarshal(w.Body.Bytes(), &response)
+	assert.Equal(t, id, response.RequestID)
+
+	// IDs from clients or proxies are kept when well-formed
+	for header, kept := range map[string]bool{"edge-42.a": true, "not valid!": false} {
+		w = httptest.NewRecorder()
+		req, _ = http.NewRequest("GET", "/api/surveys", nil)
+		req.Header.Set(requestIDHeader, header)
+		router.ServeHTTP(w, req)
+		assert.Equal(t, kept, w.Header().Get(requestIDHeader) == header, header)
+		assert.NotContains(t, w.Body.String(), "request_id")
+	}
+}
+
+func TestRequestLogger(t *testing.T) {
+	setupTestDB()
+	defer testDB.Close()
+
+	var logs bytes.Buffer
+	defer slog.SetDefault(slog.Default())
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&logs, nil)))
+
+	router := setupTestRouter()
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/surveys/999", nil)
+	req.Header.Set(requestIDHeader, "abc-123")
+	router.ServeHTTP(w, req)
+
+	var entry map[string]interface{}
+	assert.NoError(t, json.Unmarshal(logs.Bytes(), &entry))
+	assert.Equal(t, "WARN", entry["level"])
+	assert.Equal(t, "abc-123", entry["request_id"])
+	assert.Equal(t, "/api/surveys/:id", entry["route"])
+	assert.Equal(t, float64(http.StatusNotFound), entry["status"])
+}
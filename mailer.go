@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"mime"
+	"net"
+	"net/http"
+	"net/smtp"
+	"os"
+	"strings"
+	"time"
+)
+
+// Email is a plain text message to one recipient
+type Email struct {
+	To      string
+	Subject string
+	Text    string
+	// ReplyTo is where replies go instead of the sender, if set
+	ReplyTo string
+}
+
+// Mailer sends email; MAIL_SENDER picks the implementation
+type Mailer interface {
+	Send(ctx context.Context, email Email) error
+}
+
+// mailer sends invitations; it logs them until initMailer configures a sender
+var mailer Mailer = logMailer{}
+
+// mailFrom is the sender address of outgoing email
+var mailFrom = envString("MAIL_FROM", "surveys@localhost")
+
+// initMailer configures the sender named by MAIL_SENDER: log (the default),
+// smtp or sendgrid
+func initMailer() error {
+	switch sender := os.Getenv("MAIL_SENDER"); sender {
+	case "", "log":
+		mailer = logMailer{}
+	case "smtp":
+		addr := os.Getenv("SMTP_ADDR")
+		if addr == "" {
+			return errors.New("MAIL_SENDER=smtp needs SMTP_ADDR")
+		}
+		mailer = smtpMailer{Addr: addr, Username: os.Getenv("SMTP_USERNAME"), Password: os.Getenv("SMTP_PASSWORD"), From: mailFrom}
+	case "sendgrid":
+		key := os.Getenv("SENDGRID_API_KEY")
+		if key == "" {
+			return errors.New("MAIL_SENDER=sendgrid needs SENDGRID_API_KEY")
+		}
+		mailer = sendGridMailer{APIKey: key, From: mailFrom, URL: sendGridURL}
+	default:
+		return fmt.Errorf("MAIL_SENDER must be log, smtp or sendgrid, not %q", sender)
+	}
+	return nil
+}
+
+// logMailer writes email to the log instead of sending it, for development
+type logMailer struct{}
+
+// Send implements Mailer
+func (logMailer) Send(ctx context.Context, email Email) error {
+	log.Printf("Mail to %s: %s\n%s", email.To, email.Subject, email.Text)
+	return nil
+}
+
+// headerValue keeps line breaks out of a header and encodes non-ASCII text
+func headerValue(value string) string {
+	value = strings.NewReplacer("\r", " ", "\n", " ").Replace(value)
+	return mime.QEncoding.Encode("utf-8", value)
+}
+
+// smtpMailer sends email through an SMTP server, authenticating when a username is set
+type smtpMailer struct {
+	Addr     string
+	Username string
+	Password string
+	From     string
+}
+
+// Send implements Mailer; net/smtp takes no context, so ctx is only checked before sending
+func (m smtpMailer) Send(ctx context.Context, email Email) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	var auth smtp.Auth
+	if m.Username != "" {
+		host, _, _ := net.SplitHostPort(m.Addr)
+		auth = smtp.PlainAuth("", m.Username, m.Password, host)
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", m.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", email.To)
+	if email.ReplyTo != "" {
+		fmt.Fprintf(&msg, "Reply-To: %s\r\n", headerValue(email.ReplyTo))
+	}
+	fmt.Fprintf(&msg, "Subject: %s\r\n", headerValue(email.Subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n")
+	msg.WriteString(strings.ReplaceAll(email.Text, "\n", "\r\n"))
+	return smtp.SendMail(m.Addr, auth, m.From, []string{email.To}, msg.Bytes())
+}
+
+// sendGridURL is SendGrid's mail send endpoint
+var sendGridURL = "https://api.sendgrid.com/v3/mail/send"
+
+// sendGridMailer sends email through SendGrid's v3 API
+type sendGridMailer struct {
+	APIKey string
+	From   string
+	URL    string
+}
+
+// Send implements Mailer
+func (m sendGridMailer) Send(ctx context.Context, email Email) error {
+	type address struct {
+		Email string `json:"email"`
+	}
+	type content struct {
+		Type  string `json:"type"`
+		Value string `json:"value"`
+	}
+	message := map[string]interface{}{
+		"personalizations": []map[string]interface{}{{"to": []address{{email.To}}}},
+		"from":             address{m.From},
+		"subject":          email.Subject,
+		"content":          []content{{"text/plain", email.Text}},
+	}
+	if email.ReplyTo != "" {
+		message["reply_to"] = address{email.ReplyTo}
+	}
+	body, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, m.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+m.APIKey)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := webhookClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("SendGrid answered %d", resp.StatusCode)
+	}
+	return nil
+}
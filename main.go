@@ -1,37 +1,150 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
+	"flag"
 	"fmt"
+	"io"
 	"log"
+	"log/slog"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
 	"strconv"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	_ "github.com/mattn/go-sqlite3"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+	"google.golang.org/grpc"
 )
 
 // Survey represents a survey in the database
 type Survey struct {
-	ID             int       `json:"id" db:"id"`
-	Title          string    `json:"title" db:"title"`
-	Description    string    `json:"description" db:"description"`
-	CreatedAt      time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt      time.Time `json:"updated_at" db:"updated_at"`
-	ResponsesCount int       `json:"responses_count"`
+	ID                     int        `json:"id" db:"id"`
+	GlobalID               string     `json:"global_id" db:"global_id"`
+	Title                  string     `json:"title" db:"title"`
+	Description            string     `json:"description" db:"description"`
+	Status                 string     `json:"status" db:"status"`
+	PublishAt              *time.Time `json:"publish_at,omitempty" db:"publish_at"`
+	AllowMultipleResponses bool       `json:"allow_multiple_responses" db:"allow_multiple_responses"`
+	// Anonymous surveys store a server-generated token instead of user identifiers
+	Anonymous bool `json:"anonymous" db:"anonymous"`
+	// Discoverable surveys are listed in the public directory while open
+	Discoverable bool `json:"discoverable" db:"discoverable"`
+	// Indexable surveys let search engines index their hosted forms; others are link-only
+	Indexable         bool        `json:"indexable" db:"indexable"`
+	EditWindowMinutes *int        `json:"edit_window_minutes,omitempty" db:"edit_window_minutes"`
+	OpensAt           *time.Time  `json:"opens_at,omitempty" db:"opens_at"`
+	ClosesAt          *time.Time  `json:"closes_at,omitempty" db:"closes_at"`
+	MaxResponses      *int        `json:"max_responses,omitempty" db:"max_responses"`
+	Questions         []Question  `json:"questions,omitempty" db:"questions"`
+	Pages             []Page      `json:"pages,omitempty" db:"pages"`
+	Logic             []LogicRule `json:"logic,omitempty" db:"logic"`
+	// Translations maps language tags to the survey's texts in that language
+	Translations map[string]SurveyTranslation `json:"translations,omitempty" db:"translations"`
+	// QualityRules overrides the default data quality checks
+	QualityRules *QualityRules `json:"quality_rules,omitempty" db:"quality_rules"`
+	// OwnerID is the creator account that created the survey, if any
+	OwnerID *int `json:"owner_id,omitempty" db:"owner_id"`
+	// OrganizationID is the organization the survey was created in, if any
+	OrganizationID     *int      `json:"organization_id,omitempty" db:"org_id"`
+	CreatedAt          time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt          time.Time `json:"updated_at" db:"updated_at"`
+	ResponsesCount     int       `json:"responses_count"`
+	AcceptingResponses bool      `json:"accepting_responses"`
+}
+
+// Survey statuses
+const (
+	SurveyStatusDraft     = "draft"
+	SurveyStatusPublished = "published"
+)
+
+// Embargoed reports whether the survey content must be hidden from public fetches
+func (s Survey) Embargoed() bool {
+	return s.Status == SurveyStatusDraft
+}
+
+// responseWindowError explains why the survey does not accept responses at now, or returns ""
+func (s Survey) responseWindowError(now time.Time) string {
+	switch {
+	case s.Status == SurveyStatusDraft:
+		return "Survey is not published yet"
+	case s.OpensAt != nil && now.Before(*s.OpensAt):
+		return "Survey is not open for responses yet"
+	case s.ClosesAt != nil && !now.Before(*s.ClosesAt):
+		return "Survey is closed for responses"
+	}
+	return ""
+}
+
+// Full reports whether the survey has reached its response quota
+func (s Survey) Full() bool {
+	return s.MaxResponses != nil && s.ResponsesCount >= *s.MaxResponses
+}
+
+// defaultEditWindow applies to surveys without their own edit window
+var defaultEditWindow = envDuration("EDIT_WINDOW", 24*time.Hour)
+
+// maxEditWindowMinutes caps per-survey edit windows at one year
+const maxEditWindowMinutes = 365 * 24 * 60
+
+// editWindow returns the effective edit window for a survey's edit_window_minutes
+func editWindow(minutes *int) time.Duration {
+	if minutes == nil {
+		return defaultEditWindow
+	}
+	return time.Duration(*minutes) * time.Minute
+}
+
+// responseEditable reports whether a response can still be edited under the survey's edit window
+func responseEditable(createdAt time.Time, editWindowMinutes *int) bool {
+	return time.Since(createdAt) < editWindow(editWindowMinutes)
+}
+
+// envDuration reads a duration such as "24h" from the environment, falling back to def
+func envDuration(name string, def time.Duration) time.Duration {
+	value := os.Getenv(name)
+	if value == "" {
+		return def
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		log.Printf("Ignoring %s: invalid duration %q", name, value)
+		return def
+	}
+	return d
+}
+
+// ComingSoon is returned in place of an embargoed survey's content
+type ComingSoon struct {
+	ID         int        `json:"id"`
+	GlobalID   string     `json:"global_id"`
+	Status     string     `json:"status"`
+	PublishAt  *time.Time `json:"publish_at,omitempty"`
+	ComingSoon bool       `json:"coming_soon"`
 }
 
 // SurveyResponse represents a survey response in the database
 type SurveyResponse struct {
 	ID             int             `json:"id" db:"id"`
+	GlobalID       string          `json:"global_id" db:"global_id"`
 	SurveyID       int             `json:"survey_id" db:"survey_id"`
 	UserIdentifier string          `json:"user_identifier" db:"user_identifier"`
 	ResponseData   json.RawMessage `json:"response_data" db:"response_data"`
 	CreatedAt      time.Time       `json:"created_at" db:"created_at"`
 	UpdatedAt      time.Time       `json:"updated_at" db:"updated_at"`
 	Editable       bool            `json:"editable"`
+	// Version increases with every edit; send it back as the base of an edit to detect conflicts
+	Version  int              `json:"version" db:"version"`
+	Metadata ResponseMetadata `json:"metadata"`
 }
 
 // UserResponse represents a response with survey information
@@ -48,16 +161,41 @@ type UserResponse struct {
 // CreateSurveyRequest represents the request body for creating a survey
 type CreateSurveyRequest struct {
 	Survey struct {
-		Title       string `json:"title" binding:"required"`
-		Description string `json:"description" binding:"required"`
+		Title                  string        `json:"title" binding:"required"`
+		Description            string        `json:"description" binding:"required"`
+		Status                 string        `json:"status"`
+		PublishAt              *time.Time    `json:"publish_at"`
+		AllowMultipleResponses *bool         `json:"allow_multiple_responses"`
+		Anonymous              bool          `json:"anonymous"`
+		EditWindowMinutes      *int          `json:"edit_window_minutes"`
+		OpensAt                *time.Time    `json:"opens_at"`
+		ClosesAt               *time.Time    `json:"closes_at"`
+		MaxResponses           *int          `json:"max_responses"`
+		Questions              []Question    `json:"questions"`
+		QualityRules           *QualityRules `json:"quality_rules"`
+	} `json:"survey" binding:"required"`
+}
+
+// ScheduleSurveyRequest represents the request body for scheduling a draft survey
+type ScheduleSurveyRequest struct {
+	Survey struct {
+		PublishAt *time.Time `json:"publish_at" binding:"required"`
 	} `json:"survey" binding:"required"`
 }
 
 // CreateResponseRequest represents the request body for creating a response
 type CreateResponseRequest struct {
 	SurveyResponse struct {
-		UserIdentifier string          `json:"user_identifier" binding:"required"`
-		ResponseData   json.RawMessage `json:"response_data" binding:"required"`
+		// UserIdentifier is required unless the survey is anonymous
+		UserIdentifier string          `json:"user_identifier"`
+		ResponseData   json.RawMessage `json:"response_data"`
+		// PartialResponseID submits the answers autosaved in a partial response;
+		// answers in ResponseData take precedence over them
+		PartialResponseID string `json:"partial_response_id"`
+		// InvitationToken is the token of an invitation link; the response is
+		// recorded against the invitation and, unless given, identified by its email
+		InvitationToken string            `json:"invitation_token"`
+		Metadata        SubmittedMetadata `json:"metadata"`
 	} `json:"survey_response" binding:"required"`
 }
 
@@ -65,45 +203,399 @@ type CreateResponseRequest struct {
 type UpdateResponseRequest struct {
 	SurveyResponse struct {
 		ResponseData json.RawMessage `json:"response_data"`
+		// Version is the version the edit was made to, e.g. by an offline client;
+		// omit it to overwrite unconditionally
+		Version *int `json:"version"`
+		// ConflictPolicy resolves an edit to an older version: reject (default),
+		// server_wins, client_wins or merge
+		ConflictPolicy string `json:"conflict_policy"`
 	} `json:"survey_response"`
 }
 
 // APIResponse represents a standard API response
 type APIResponse struct {
-	Status  string      `json:"status"`
+	Status string `json:"status"`
+	// Code identifies the error for clients; see errors.go
+	Code    string      `json:"code,omitempty"`
 	Message string      `json:"message,omitempty"`
 	Data    interface{} `json:"data,omitempty"`
+	Meta    interface{} `json:"meta,omitempty"`
 	Errors  []string    `json:"errors,omitempty"`
+	// RequestID is set on errors, matching the X-Request-ID header and the logs
+	RequestID string `json:"request_id,omitempty"`
 }
 
 // Database connection
 var db *sql.DB
 
+// listenAddr is the address the HTTP server listens on
+var listenAddr = envString("ADDR", ":8081")
+
+// main runs the command named by the first argument: serve, the default, or
+// seed (see runSeed)
 func main() {
+	command, args := "serve", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command, args = args[0], args[1:]
+	}
+	switch command {
+	case "serve":
+		if len(args) > 0 {
+			log.Fatalf("serve takes no arguments, got %q", args)
+		}
+		serve()
+	case "seed":
+		if err := runSeed(args); err != nil {
+			if err == flag.ErrHelp {
+				return
+			}
+			log.Fatal(err)
+		}
+	case "tsclient":
+		if err := runTSClient(args); err != nil {
+			if err == flag.ErrHelp {
+				return
+			}
+			log.Fatal(err)
+		}
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown command %q\nUsage: %s [serve | seed [-fixtures file] [-count n] [-reset] | tsclient [-out dir] [-spec url]]\n", command, filepath.Base(os.Args[0]))
+		os.Exit(2)
+	}
+}
+
+// serve runs the HTTP server, and the gRPC server when configured, until
+// SIGINT or SIGTERM
+func serve() {
+	initLogging()
+
+	// Initialize tracing
+	shutdownTracing, err := initTracing(context.Background())
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	// Initialize database
 	initDatabase()
-	defer db.Close()
 
-	// Create Gin router
-	r := gin.Default()
+	// HTTP server with timeouts so slow clients can't hold connections forever
+	router := setupRouter()
+	srv := &http.Server{
+		Addr:              listenAddr,
+		Handler:           router,
+		ReadHeaderTimeout: 5 * time.Second,
+		ReadTimeout:       10 * time.Second,
+		WriteTimeout:      30 * time.Second,
+		IdleTimeout:       120 * time.Second,
+	}
+
+	// With autocert, the server also serves TLS for PUBLIC_URL's host and the
+	// custom domains, and answers ACME challenges on ADDR (port 80 in production)
+	var tlsSrv *http.Server
+	if certs := newCertManager(); certs != nil {
+		tlsSrv = &http.Server{
+			Addr:              tlsListenAddr,
+			Handler:           srv.Handler,
+			TLSConfig:         certs.TLSConfig(),
+			ReadHeaderTimeout: srv.ReadHeaderTimeout,
+			ReadTimeout:       srv.ReadTimeout,
+			WriteTimeout:      srv.WriteTimeout,
+			IdleTimeout:       srv.IdleTimeout,
+		}
+		srv.Handler = certs.HTTPHandler(srv.Handler)
+	}
+
+	// The gRPC API serves its calls through the REST routes
+	var grpcSrv *grpc.Server
+	if grpcListenAddr != "" {
+		grpcSrv = newGRPCServer(router)
+	}
+
+	// Background scheduler (scheduled publishing, daily snapshots)
+	schedulerCtx, stopScheduler := context.WithCancel(context.Background())
+	go runScheduler(schedulerCtx, schedulerInterval)
+
+	// Job workers (webhook deliveries)
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	startJobWorkers(jobsCtx, jobWorkers, jobPollInterval)
+
+	// Run the server
+	go func() {
+		slog.Info("Server running", "addr", listenAddr)
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatal(err)
+		}
+	}()
+	if tlsSrv != nil {
+		go func() {
+			slog.Info("TLS server running", "addr", tlsListenAddr)
+			if err := tlsSrv.ListenAndServeTLS("", ""); err != nil && err != http.ErrServerClosed {
+				log.Fatal(err)
+			}
+		}()
+	}
+	if grpcSrv != nil {
+		lis, err := net.Listen("tcp", grpcListenAddr)
+		if err != nil {
+			log.Fatal(err)
+		}
+		go func() {
+			slog.Info("gRPC server running", "addr", grpcListenAddr)
+			if err := grpcSrv.Serve(lis); err != nil {
+				log.Fatal(err)
+			}
+		}()
+	}
+
+	// Wait for SIGINT/SIGTERM, then drain in-flight requests
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	<-quit
+
+	slog.Info("Shutting down server")
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		log.Println("Server forced to shutdown:", err)
+	}
+	if tlsSrv != nil {
+		if err := tlsSrv.Shutdown(ctx); err != nil {
+			log.Println("TLS server forced to shutdown:", err)
+		}
+	}
+	if grpcSrv != nil {
+		grpcSrv.GracefulStop()
+	}
+	stopScheduler()
+	stopJobs()
+	waitForJobs(ctx)
+
+	if err := exportDB.Close(); err != nil {
+		log.Println("Failed to close export database pool:", err)
+	}
+	if err := db.Close(); err != nil {
+		log.Println("Failed to close database:", err)
+	}
+	if err := shutdownTracing(ctx); err != nil {
+		log.Println("Failed to flush traces:", err)
+	}
+	slog.Info("Server stopped")
+}
+
+// setupRouter creates the Gin router and registers all routes
+func setupRouter() *gin.Engine {
+	domains := newDomainCache()
+	r := gin.New()
+	trustProxies(r)
+	r.Use(otelgin.Middleware(serviceName), requestIDs(), queryOrigins(), requestLogger(), gin.CustomRecoveryWithWriter(io.Discard, recoverPanic), handleErrors(), customDomains(domains))
+
+	limiter := newRateLimitStore()
+	results := newResultsCache(resultsCacheTTL, resultsStaleTTL)
+	directory := newDiscoverCache()
 
 	// API routes
-	api := r.Group("/api")
+	api := r.Group("/api", jsonAPIFormat(), rateLimit(limiter, "api", apiRateLimit, clientIPKey), surveyTokenAuth(), viewerAuth(), apiKeyAuth(), creatorAuth(), tenantScope())
 	{
+		// Rate limit discovery
+		api.GET("/limits", getRateLimits)
+
+		// API description
+		api.GET("/openapi.json", getOpenAPI)
+		api.GET("/docs", getAPIDocs)
+
+		// Global ID lookup
+		api.GET("/lookup/:global_id", lookupGlobalID)
+
+		// Public survey directory
+		api.GET("/discover", rateLimit(limiter, "discover_ip", discoverIPRateLimit, clientIPKey), getDiscoverableSurveys(directory))
+
 		// Survey routes
 		api.GET("/surveys", getSurveys)
 		api.POST("/surveys", createSurvey)
 		api.GET("/surveys/:id", getSurvey)
+		api.POST("/surveys/:id/schedule", scheduleSurvey)
+
+		api.GET("/surveys/:id/results", getSurveyResults(results))
+		api.GET("/surveys/:id/results/live", streamLiveResults)
+		api.GET("/surveys/:id/rollups", getSurveyRollups)
+		api.GET("/surveys/:id/nps", getSurveyNPS)
+		api.GET("/surveys/:id/history", getSurveyHistory)
+		api.GET("/surveys/:id/response-schema", getResponseSchema)
+
+		// Share link routes
+		api.GET("/surveys/:id/share", getShareLinks)
+		api.POST("/surveys/:id/share", createShareLink)
+		api.DELETE("/surveys/:id/share/:share_id", revokeShareLink)
+		api.GET("/surveys/:id/invitations", getInvitations)
+		api.POST("/surveys/:id/invitations", createInvitations)
+		api.POST("/surveys/:id/invitations/:invitation_id/resend", resendInvitation)
+		api.GET("/surveys/:id/invitations/reminders", getReminderPreview)
+		api.PUT("/surveys/:id/invitations/reminders", saveReminderSettings)
+		api.GET("/surveys/:id/summary-email", getSummaryEmailSettings)
+		api.PUT("/surveys/:id/summary-email", saveSummaryEmailSettings)
+
+		// Google Sheets sync routes
+		api.GET("/surveys/:id/sheets", getSheetSync)
+		api.PUT("/surveys/:id/sheets", saveSheetSync)
+		api.DELETE("/surveys/:id/sheets", deleteSheetSync)
+		api.POST("/surveys/:id/sheets/backfill", backfillSheet)
+
+		// Creation wizard routes, on draft surveys
+		api.PUT("/surveys/:id/pages", savePages)
+		api.POST("/surveys/:id/questions", addQuestion)
+		api.DELETE("/surveys/:id/questions/:question_id", removeQuestion)
+		api.PUT("/surveys/:id/questions/:question_id/recodes", saveRecodes)
+		api.PUT("/surveys/:id/questions/:question_id/pii", requireAdmin(), savePII)
+		api.PUT("/surveys/:id/discoverable", requireAdmin(), saveDiscoverable)
+		api.PUT("/surveys/:id/indexing", requireAdmin(), saveIndexing)
+		api.PUT("/surveys/:id/logic", saveLogic)
+		api.PUT("/surveys/:id/translations", saveTranslations)
+		api.GET("/surveys/:id/validation", getSurveyValidation)
+		api.GET("/surveys/:id/lint", getSurveyLint)
+		api.POST("/surveys/:id/publish", publishSurvey)
 
 		// Survey response routes
 		api.GET("/surveys/:id/responses", getSurveyResponses)
-		api.POST("/surveys/:id/responses", createSurveyResponse)
+		api.POST("/surveys/:id/responses",
+			rateLimit(limiter, "submit_ip", submitIPRateLimit, clientIPKey),
+			rateLimit(limiter, "submit_user", submitUserRateLimit, userIdentifierKey),
+			createSurveyResponse)
+		api.GET("/surveys/:id/responses/search", searchSurveyResponses)
+		api.GET("/surveys/:id/responses/export.ndjson", exportResponses)
 		api.GET("/surveys/:id/responses/:response_id", getSurveyResponse)
+		api.GET("/surveys/:id/responses/:response_id/neighbors", getResponseNeighbors)
+		api.GET("/surveys/:id/questions/:question_id/answers", getQuestionAnswers)
+
+		// Review queue routes
+		api.GET("/surveys/:id/review-queue", requireAdmin(), getReviewQueue)
+		api.POST("/surveys/:id/review-queue/:item_id/accept", requireAdmin(), resolveReviewItem("accept"))
+		api.POST("/surveys/:id/review-queue/:item_id/fix", requireAdmin(), resolveReviewItem("fix"))
+		api.POST("/surveys/:id/review-queue/:item_id/reject", requireAdmin(), resolveReviewItem("reject"))
+
+		// Autosave routes
+		api.POST("/surveys/:id/partial-responses", createPartialResponse)
+		api.GET("/surveys/:id/partial-responses/:partial_id", getPartialResponse)
+		api.PATCH("/surveys/:id/partial-responses/:partial_id", savePartialResponse)
+		api.POST("/surveys/:id/partial-responses/:partial_id/resume-code", createResumeCode)
+		api.POST("/surveys/:id/partial-responses/resume",
+			rateLimit(limiter, "resume_ip", resumeIPRateLimit, clientIPKey),
+			rateLimit(limiter, "resume_survey", resumeSurveyRateLimit, surveyKey),
+			redeemResumeCode)
+		api.GET("/surveys/:id/dropout", getDropoutReport)
+		api.GET("/surveys/:id/quality", getQualityReport)
+
+		// Survey taker experience routes
+		api.POST("/surveys/:id/experience-events",
+			rateLimit(limiter, "experience_ip", experienceIPRateLimit, clientIPKey),
+			createExperienceEvent)
+		api.GET("/surveys/:id/experience", getExperienceMetrics)
+		api.POST("/client-errors",
+			rateLimit(limiter, "client_errors_ip", clientErrorsIPRateLimit, clientIPKey),
+			createClientError)
+
+		// Saved response view routes
+		api.GET("/surveys/:id/views", getResponseViews)
+		api.POST("/surveys/:id/views", createResponseView)
+		api.GET("/surveys/:id/views/:view_id", getResponseView)
+		api.DELETE("/surveys/:id/views/:view_id", deleteResponseView)
+
+		// Saved formula routes
+		api.GET("/surveys/:id/formulas", getFormulas)
+		api.POST("/surveys/:id/formulas", createFormula)
+		api.GET("/surveys/:id/formulas/:formula_id", getFormula)
+		api.DELETE("/surveys/:id/formulas/:formula_id", deleteFormula)
+
 		api.PATCH("/surveys/:id/responses/:response_id", updateSurveyResponse)
+		api.DELETE("/surveys/:id/responses/:response_id", deleteSurveyResponse)
+		api.GET("/surveys/:id/responses/:response_id/revisions", getResponseRevisions)
+
+		// Offline sync route, for mobile data collection apps
+		api.GET("/sync", getSync)
+
+		// Notification preference routes, for creators
+		api.GET("/notification-preferences", getNotificationPreferences)
+		api.PUT("/notification-preferences", saveNotificationPreferences)
 
 		// User response routes
 		api.GET("/users/:user_identifier/responses", getUserResponses)
+		api.GET("/users/:user_identifier/export", requireAdmin(), exportUserData)
+		api.DELETE("/users/:user_identifier/data", requireAdmin(), eraseUserData)
+
+		// Admin routes
+		admin := api.Group("/admin", requireAdmin())
+		{
+			admin.DELETE("/surveys/:id", deleteSurvey)
+			admin.GET("/surveys/:id/impact", getSurveyImpact)
+			admin.POST("/surveys/:id/anonymize", anonymizeSurveyResponses)
+			admin.POST("/surveys/:id/responses/:response_id/restore", restoreSurveyResponse)
+			admin.GET("/surveys/:id/responses/:response_id/thread", getResponseThread)
+			admin.GET("/audit", getAuditLog)
+			admin.GET("/client-errors", getClientErrors)
+			admin.GET("/client-errors/summary", getClientErrorSummary)
+			admin.GET("/slow-queries", getSlowQueries)
+			admin.GET("/response-data/stats", getResponseDataStats)
+			admin.POST("/response-data/compress", compressResponseDataNow)
+			admin.GET("/webhooks", getWebhooks)
+			admin.POST("/webhooks", createWebhook)
+			admin.DELETE("/webhooks/:webhook_id", deleteWebhook)
+			admin.GET("/jobs", getJobs)
+			admin.GET("/jobs/:job_id", getJob)
+			admin.POST("/jobs/:job_id/requeue", requeueJob)
+			admin.DELETE("/jobs/:job_id", deleteJob)
+			admin.POST("/hooks", subscribeRESTHook)
+			admin.GET("/hooks/sample", getRESTHookSample)
+			admin.DELETE("/hooks/:webhook_id", deleteWebhook)
+			admin.GET("/surveys/:id/tokens", getSurveyTokens)
+			admin.POST("/surveys/:id/tokens", createSurveyToken)
+			admin.POST("/surveys/:id/tokens/:token_id/rotate", rotateSurveyToken)
+			admin.DELETE("/surveys/:id/tokens/:token_id", revokeSurveyToken)
+			admin.GET("/viewers", getViewers)
+			admin.POST("/viewers", createViewer)
+			admin.DELETE("/viewers/:viewer_id", revokeViewer)
+			admin.GET("/creators", getCreators)
+			admin.GET("/surveys/:id/export-jobs", getExportJobs)
+			admin.POST("/surveys/:id/export-jobs", createExportJob)
+			admin.GET("/surveys/:id/export-jobs/:job_id", getExportJob)
+			admin.GET("/surveys/:id/export-jobs/:job_id/last-run", getExportJobLastRun)
+			admin.DELETE("/surveys/:id/export-jobs/:job_id", deleteExportJob)
+			admin.POST("/creators", createCreator)
+			admin.DELETE("/creators/:creator_id", revokeCreator)
+			admin.GET("/organizations", getOrganizations)
+			admin.POST("/organizations", createOrganization)
+			admin.GET("/organizations/:org_id/members", getOrganizationMembers)
+			admin.POST("/organizations/:org_id/members", addOrganizationMember)
+			admin.DELETE("/organizations/:org_id/members/:creator_id", removeOrganizationMember)
+			admin.PUT("/organizations/:org_id/domain", saveOrganizationDomain(domains))
+			admin.DELETE("/organizations/:org_id/domain", deleteOrganizationDomain(domains))
+			admin.GET("/api-keys", getAPIKeys)
+			admin.POST("/api-keys", createAPIKey)
+			admin.DELETE("/api-keys/:key_id", revokeAPIKey)
+			admin.GET("/org-units", getOrgUnits)
+			admin.POST("/org-units", createOrgUnit)
+			admin.DELETE("/org-units/:unit_id", deleteOrgUnit)
+			admin.POST("/surveys/:id/scanned-responses", createScannedResponse)
+			admin.GET("/scanned-responses", getScanReviewQueue)
+			admin.POST("/scanned-responses/:scan_id/finalize", finalizeScan)
+			admin.POST("/scanned-responses/:scan_id/reject", rejectScan)
+			admin.GET("/email-suppressions", getEmailSuppressions)
+			admin.POST("/email-suppressions", createEmailSuppression)
+			admin.DELETE("/email-suppressions/:email", deleteEmailSuppression)
+		}
+	}
+
+	// Public survey links
+	shares := r.Group("/s", rateLimit(limiter, "share_ip", shareIPRateLimit, clientIPKey))
+	{
+		shares.GET("/:token", getSharedSurvey)
+		shares.GET("/:token/form", getSurveyForm)
+	}
+	invitations := r.Group("/i", rateLimit(limiter, "share_ip", shareIPRateLimit, clientIPKey))
+	{
+		invitations.GET("/:token", getInvitationForm)
 	}
+	// Hosted forms of the surveys in the public directory
+	r.GET("/f/:global_id", rateLimit(limiter, "discover_ip", discoverIPRateLimit, clientIPKey), getDirectoryForm)
+	r.GET("/sitemap.xml", rateLimit(limiter, "discover_ip", discoverIPRateLimit, clientIPKey), getSitemap)
 
 	// Root route
 	r.GET("/", func(c *gin.Context) {
@@ -114,40 +606,77 @@ func main() {
 				"surveys":        "/api/surveys",
 				"responses":      "/api/surveys/{id}/responses",
 				"user_responses": "/api/users/{user_identifier}/responses",
+				"openapi":        "/api/openapi.json",
+				"docs":           "/api/docs",
+				"graphql":        "/graphql",
 			},
 		})
 	})
 
+	// GraphQL, resolved through the routes above; requests count against the
+	// API rate limit like the REST requests they make
+	graphql := r.Group("/graphql", rateLimit(limiter, "api", apiRateLimit, clientIPKey))
+	graphql.GET("", serveGraphQL(r))
+	graphql.POST("", serveGraphQL(r))
+	graphql.GET("/schema", serveGraphQLSchema)
+
+	// Replies to invitation emails, posted by the email provider
+	r.POST("/inbound/email", receiveInboundEmail(r))
+
 	// Health check
 	r.GET("/up", func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{"status": "healthy"})
 	})
 
-	// Run the server
-	fmt.Println("Server running on http://localhost:8081")
-	r.Run(":8081")
+	return r
 }
 
 // initDatabase initializes the SQLite database and creates tables
 func initDatabase() {
 	var err error
-	db, err = sql.Open("sqlite3", "./survey_form.db")
+	db, err = openDatabase(dbPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := migrate(db); err != nil {
+		log.Fatal(err)
+	}
+	if exportDB, err = openExportDatabase(dbPath); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := initResponseEncryption(); err != nil {
+		log.Fatal(err)
+	}
+	if err := initResponseCompression(); err != nil {
+		log.Fatal(err)
+	}
+	if err := initMailer(); err != nil {
+		log.Fatal(err)
+	}
+	jobQueue = newJobStore()
+	n, err := encryptStoredResponses(context.Background())
 	if err != nil {
 		log.Fatal(err)
 	}
+	if n > 0 {
+		log.Printf("Encrypted %d stored responses, revisions, audit snapshots and job payloads", n)
+	}
+}
 
-	// Create surveys table
-	createSurveysTable := `
+// migrations are applied in order; the index of the last applied migration
+// is tracked in SQLite's user_version pragma.
+var migrations = []string{
+	// 1: surveys and survey_responses tables
+	`
 	CREATE TABLE IF NOT EXISTS surveys (
 		id INTEGER PRIMARY KEY AUTOINCREMENT,
 		title TEXT NOT NULL,
 		description TEXT NOT NULL,
 		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
-	);`
-
-	// Create survey_responses table
-	createResponsesTable := `
+	);
 	CREATE TABLE IF NOT EXISTS survey_responses (
 		id INTEGER PRIMARY KEY AUTOINCREMENT,
 		survey_id INTEGER NOT NULL,
@@ -156,114 +685,907 @@ func initDatabase() {
 		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 		FOREIGN KEY (survey_id) REFERENCES surveys (id) ON DELETE CASCADE
-	);`
+	);`,
+	// 2: draft/published status and scheduled publishing
+	`
+	ALTER TABLE surveys ADD COLUMN status TEXT NOT NULL DEFAULT 'published';
+	ALTER TABLE surveys ADD COLUMN publish_at DATETIME;`,
+	// 3: saved response views
+	`
+	CREATE TABLE IF NOT EXISTS response_views (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		survey_id INTEGER NOT NULL,
+		name TEXT NOT NULL,
+		filters TEXT NOT NULL DEFAULT '{}',
+		columns TEXT NOT NULL DEFAULT '[]',
+		sort TEXT NOT NULL DEFAULT 'updated_at',
+		sort_order TEXT NOT NULL DEFAULT 'desc',
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE (survey_id, name),
+		FOREIGN KEY (survey_id) REFERENCES surveys (id) ON DELETE CASCADE
+	);`,
+	// 4: audit log of administrative operations
+	`
+	CREATE TABLE IF NOT EXISTS audit_log (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		actor TEXT NOT NULL,
+		action TEXT NOT NULL,
+		entity_type TEXT NOT NULL,
+		entity_id INTEGER NOT NULL,
+		details TEXT NOT NULL DEFAULT '{}',
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);`,
+	// 5: one-response-per-user enforcement
+	`
+	ALTER TABLE surveys ADD COLUMN allow_multiple_responses BOOLEAN NOT NULL DEFAULT 1;
+	CREATE INDEX IF NOT EXISTS index_survey_responses_on_survey_id_and_user_identifier
+		ON survey_responses (survey_id, user_identifier);`,
+	// 6: per-survey edit window (NULL uses the global default)
+	`
+	ALTER TABLE surveys ADD COLUMN edit_window_minutes INTEGER;`,
+	// 7: response window
+	`
+	ALTER TABLE surveys ADD COLUMN opens_at DATETIME;
+	ALTER TABLE surveys ADD COLUMN closes_at DATETIME;`,
+	// 8: response quota (NULL is unlimited)
+	`
+	ALTER TABLE surveys ADD COLUMN max_responses INTEGER;`,
+	// 9: soft-deleted responses
+	`
+	ALTER TABLE survey_responses ADD COLUMN deleted_at DATETIME;`,
+	// 10: previous versions of edited responses
+	`
+	CREATE TABLE IF NOT EXISTS response_revisions (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		response_id INTEGER NOT NULL,
+		response_data TEXT NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (response_id) REFERENCES survey_responses (id) ON DELETE CASCADE
+	);
+	CREATE INDEX IF NOT EXISTS index_response_revisions_on_response_id
+		ON response_revisions (response_id);`,
+	// 11: before/after snapshots in the audit log
+	`
+	ALTER TABLE audit_log ADD COLUMN before_data TEXT;
+	ALTER TABLE audit_log ADD COLUMN after_data TEXT;
+	CREATE INDEX IF NOT EXISTS index_audit_log_on_entity_type_and_entity_id
+		ON audit_log (entity_type, entity_id);
+	CREATE INDEX IF NOT EXISTS index_audit_log_on_created_at
+		ON audit_log (created_at);`,
+	// 12: respondent metadata for triage
+	`
+	ALTER TABLE survey_responses ADD COLUMN channel TEXT NOT NULL DEFAULT '';
+	ALTER TABLE survey_responses ADD COLUMN country TEXT NOT NULL DEFAULT '';
+	ALTER TABLE survey_responses ADD COLUMN device TEXT NOT NULL DEFAULT '';
+	ALTER TABLE survey_responses ADD COLUMN moderation_status TEXT NOT NULL DEFAULT 'approved';
+	ALTER TABLE survey_responses ADD COLUMN bot_score REAL;
+	CREATE INDEX IF NOT EXISTS index_survey_responses_on_survey_id_and_channel
+		ON survey_responses (survey_id, channel);
+	CREATE INDEX IF NOT EXISTS index_survey_responses_on_survey_id_and_country
+		ON survey_responses (survey_id, country);
+	CREATE INDEX IF NOT EXISTS index_survey_responses_on_survey_id_and_device
+		ON survey_responses (survey_id, device);
+	CREATE INDEX IF NOT EXISTS index_survey_responses_on_survey_id_and_moderation_status
+		ON survey_responses (survey_id, moderation_status);
+	CREATE INDEX IF NOT EXISTS index_survey_responses_on_survey_id_and_bot_score
+		ON survey_responses (survey_id, bot_score);`,
+	// 13: question definitions describing response_data
+	`
+	ALTER TABLE surveys ADD COLUMN questions TEXT NOT NULL DEFAULT '[]';`,
+	// 14: webhook subscriptions and the lifecycle events already sent per survey
+	`
+	CREATE TABLE IF NOT EXISTS webhook_subscriptions (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		url TEXT NOT NULL,
+		secret TEXT NOT NULL,
+		events TEXT NOT NULL DEFAULT '[]',
+		survey_id INTEGER,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (survey_id) REFERENCES surveys (id) ON DELETE CASCADE
+	);
+	CREATE TABLE IF NOT EXISTS survey_lifecycle_events (
+		survey_id INTEGER NOT NULL,
+		event TEXT NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (survey_id, event)
+	);`,
+	// 15: survey-scoped tokens for embedded forms
+	`
+	CREATE TABLE IF NOT EXISTS survey_tokens (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		survey_id INTEGER NOT NULL,
+		name TEXT NOT NULL,
+		prefix TEXT NOT NULL,
+		token_hash TEXT NOT NULL UNIQUE,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		last_used_at DATETIME,
+		revoked_at DATETIME,
+		FOREIGN KEY (survey_id) REFERENCES surveys (id) ON DELETE CASCADE
+	);
+	CREATE INDEX IF NOT EXISTS index_survey_tokens_on_survey_id ON survey_tokens (survey_id);`,
+	// 16: full-text index of the text answers of responses, kept in sync by triggers.
+	// FTS4 ships with the default go-sqlite3 build; FTS5 needs the sqlite_fts5 tag.
+	`
+	CREATE VIRTUAL TABLE IF NOT EXISTS response_search USING fts4(body, tokenize=unicode61);
+	INSERT INTO response_search (rowid, body)
+		SELECT id, (SELECT group_concat(value, ' ') FROM json_tree(response_data) WHERE type = 'text')
+		FROM survey_responses;
+	CREATE TRIGGER IF NOT EXISTS survey_responses_search_insert AFTER INSERT ON survey_responses BEGIN
+		INSERT INTO response_search (rowid, body)
+			VALUES (new.id, (SELECT group_concat(value, ' ') FROM json_tree(new.response_data) WHERE type = 'text'));
+	END;
+	CREATE TRIGGER IF NOT EXISTS survey_responses_search_update AFTER UPDATE OF response_data ON survey_responses BEGIN
+		UPDATE response_search
+			SET body = (SELECT group_concat(value, ' ') FROM json_tree(new.response_data) WHERE type = 'text')
+			WHERE rowid = new.id;
+	END;
+	CREATE TRIGGER IF NOT EXISTS survey_responses_search_delete AFTER DELETE ON survey_responses BEGIN
+		DELETE FROM response_search WHERE rowid = old.id;
+	END;`,
+	// 17: answers autosaved while a respondent fills in a form
+	`
+	CREATE TABLE IF NOT EXISTS partial_responses (
+		id TEXT PRIMARY KEY,
+		survey_id INTEGER NOT NULL,
+		answers TEXT NOT NULL DEFAULT '{}',
+		answer_versions TEXT NOT NULL DEFAULT '{}',
+		version INTEGER NOT NULL DEFAULT 0,
+		response_id INTEGER,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (survey_id) REFERENCES surveys (id) ON DELETE CASCADE
+	);
+	CREATE INDEX IF NOT EXISTS index_partial_responses_on_survey_id ON partial_responses (survey_id);`,
+	// 18: short codes for resuming a partial response on another device
+	`
+	ALTER TABLE partial_responses ADD COLUMN resume_code TEXT;
+	ALTER TABLE partial_responses ADD COLUMN resume_code_expires_at DATETIME;
+	CREATE UNIQUE INDEX IF NOT EXISTS index_partial_responses_on_survey_id_and_resume_code
+		ON partial_responses (survey_id, resume_code);`,
+	// 19: paper responses imported from the scanning service, held for review
+	`
+	CREATE TABLE IF NOT EXISTS scanned_responses (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		survey_id INTEGER NOT NULL,
+		user_identifier TEXT NOT NULL,
+		source TEXT NOT NULL DEFAULT '',
+		fields TEXT NOT NULL,
+		status TEXT NOT NULL,
+		response_id INTEGER,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		reviewed_at DATETIME,
+		FOREIGN KEY (survey_id) REFERENCES surveys (id) ON DELETE CASCADE
+	);
+	CREATE INDEX IF NOT EXISTS index_scanned_responses_on_status ON scanned_responses (status, survey_id);`,
+	// 20: review queue state of responses
+	`
+	ALTER TABLE survey_responses ADD COLUMN validation_warnings TEXT NOT NULL DEFAULT '[]';
+	ALTER TABLE survey_responses ADD COLUMN reviewed_at DATETIME;
+	CREATE INDEX IF NOT EXISTS index_survey_responses_on_survey_id_and_reviewed_at
+		ON survey_responses (survey_id, reviewed_at);`,
+	// 21: data quality flags of responses
+	`
+	ALTER TABLE survey_responses ADD COLUMN quality_flags TEXT NOT NULL DEFAULT '[]';
+	ALTER TABLE survey_responses ADD COLUMN completion_seconds INTEGER;
+	ALTER TABLE survey_responses ADD COLUMN answers_hash TEXT;
+	CREATE INDEX IF NOT EXISTS index_survey_responses_on_survey_id_and_answers_hash
+		ON survey_responses (survey_id, answers_hash);`,
+	// 22: per-survey data quality rules
+	`
+	ALTER TABLE surveys ADD COLUMN quality_rules TEXT;`,
+	// 23: form render events reported by survey embeds
+	`
+	CREATE TABLE IF NOT EXISTS experience_events (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		survey_id INTEGER NOT NULL,
+		type TEXT NOT NULL,
+		load_ms INTEGER,
+		device TEXT NOT NULL DEFAULT '',
+		browser TEXT NOT NULL DEFAULT '',
+		error TEXT NOT NULL DEFAULT '',
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (survey_id) REFERENCES surveys (id) ON DELETE CASCADE
+	);
+	CREATE INDEX IF NOT EXISTS index_experience_events_on_survey_id_and_created_at
+		ON experience_events (survey_id, created_at);`,
+	// 24: failures reported by survey forms
+	`
+	CREATE TABLE IF NOT EXISTS client_errors (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		survey_id INTEGER,
+		kind TEXT NOT NULL,
+		message TEXT NOT NULL,
+		detail TEXT NOT NULL DEFAULT '',
+		page_url TEXT NOT NULL DEFAULT '',
+		request_id TEXT NOT NULL DEFAULT '',
+		user_agent TEXT NOT NULL DEFAULT '',
+		device TEXT NOT NULL DEFAULT '',
+		browser TEXT NOT NULL DEFAULT '',
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (survey_id) REFERENCES surveys (id) ON DELETE CASCADE
+	);
+	CREATE INDEX IF NOT EXISTS index_client_errors_on_created_at ON client_errors (created_at);
+	CREATE INDEX IF NOT EXISTS index_client_errors_on_survey_id_and_created_at
+		ON client_errors (survey_id, created_at);`,
+	// 25: pages, logic and translations set up in the creation wizard
+	`
+	ALTER TABLE surveys ADD COLUMN pages TEXT NOT NULL DEFAULT '[]';
+	ALTER TABLE surveys ADD COLUMN logic TEXT NOT NULL DEFAULT '[]';
+	ALTER TABLE surveys ADD COLUMN translations TEXT NOT NULL DEFAULT '{}';`,
+	// 26: responses count kept on surveys by triggers, so survey lists don't
+	// count responses. Soft-deleted responses don't count.
+	`
+	ALTER TABLE surveys ADD COLUMN responses_count INTEGER NOT NULL DEFAULT 0;
+	UPDATE surveys SET responses_count = (
+		SELECT COUNT(*) FROM survey_responses WHERE survey_id = surveys.id AND deleted_at IS NULL
+	);
+	CREATE TRIGGER IF NOT EXISTS survey_responses_count_insert AFTER INSERT ON survey_responses
+	WHEN new.deleted_at IS NULL BEGIN
+		UPDATE surveys SET responses_count = responses_count + 1 WHERE id = new.survey_id;
+	END;
+	CREATE TRIGGER IF NOT EXISTS survey_responses_count_update AFTER UPDATE OF survey_id, deleted_at ON survey_responses BEGIN
+		UPDATE surveys SET responses_count = responses_count - 1 WHERE id = old.survey_id AND old.deleted_at IS NULL;
+		UPDATE surveys SET responses_count = responses_count + 1 WHERE id = new.survey_id AND new.deleted_at IS NULL;
+	END;
+	CREATE TRIGGER IF NOT EXISTS survey_responses_count_delete AFTER DELETE ON survey_responses
+	WHEN old.deleted_at IS NULL BEGIN
+		UPDATE surveys SET responses_count = responses_count - 1 WHERE id = old.survey_id;
+	END;`,
+	// 27: idempotency keys of submissions, so retried submissions return the
+	// response they created
+	`
+	ALTER TABLE survey_responses ADD COLUMN idempotency_key TEXT;
+	ALTER TABLE survey_responses ADD COLUMN idempotency_fingerprint TEXT;
+	CREATE UNIQUE INDEX IF NOT EXISTS index_survey_responses_on_survey_id_and_idempotency_key
+		ON survey_responses (survey_id, idempotency_key) WHERE idempotency_key IS NOT NULL;`,
+	// 28: saved formulas combining question aggregates into KPIs
+	`
+	CREATE TABLE IF NOT EXISTS survey_formulas (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		survey_id INTEGER NOT NULL,
+		name TEXT NOT NULL,
+		expression TEXT NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE (survey_id, name),
+		FOREIGN KEY (survey_id) REFERENCES surveys (id) ON DELETE CASCADE
+	);`,
+	// 29: anonymous surveys, whose responses store a random token as user_identifier
+	`
+	ALTER TABLE surveys ADD COLUMN anonymous BOOLEAN NOT NULL DEFAULT 0;`,
+	// 30: viewer accounts, whose attributes scope the analytics they see
+	`
+	CREATE TABLE IF NOT EXISTS viewers (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL,
+		attributes TEXT NOT NULL DEFAULT '{}',
+		prefix TEXT NOT NULL,
+		token_hash TEXT NOT NULL UNIQUE,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		last_used_at DATETIME,
+		revoked_at DATETIME
+	);`,
+	// 31: org hierarchy for rollups, e.g. teams within departments within the company
+	`
+	CREATE TABLE IF NOT EXISTS org_units (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		parent_id INTEGER,
+		name TEXT NOT NULL,
+		code TEXT NOT NULL UNIQUE,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (parent_id) REFERENCES org_units (id)
+	);`,
+	// 32: daily snapshots of survey aggregates, kept when responses are purged
+	`
+	CREATE TABLE IF NOT EXISTS survey_snapshots (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		survey_id INTEGER NOT NULL,
+		day TEXT NOT NULL,
+		responses_count INTEGER NOT NULL,
+		questions TEXT NOT NULL,
+		formulas TEXT NOT NULL DEFAULT '[]',
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE (survey_id, day),
+		FOREIGN KEY (survey_id) REFERENCES surveys (id) ON DELETE CASCADE
+	);`,
+	// 33: API keys, limited to the routes of their scope
+	`
+	CREATE TABLE IF NOT EXISTS api_keys (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL,
+		scope TEXT NOT NULL,
+		prefix TEXT NOT NULL,
+		token_hash TEXT NOT NULL UNIQUE,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		last_used_at DATETIME,
+		revoked_at DATETIME
+	);`,
+	// 34: keep encrypted response_data out of the search index
+	`
+	DROP TRIGGER IF EXISTS survey_responses_search_insert;
+	DROP TRIGGER IF EXISTS survey_responses_search_update;
+	CREATE TRIGGER survey_responses_search_insert AFTER INSERT ON survey_responses BEGIN
+		INSERT INTO response_search (rowid, body)
+			VALUES (new.id, CASE WHEN json_valid(new.response_data) THEN
+				(SELECT group_concat(value, ' ') FROM json_tree(new.response_data) WHERE type = 'text') END);
+	END;
+	CREATE TRIGGER survey_responses_search_update AFTER UPDATE OF response_data ON survey_responses BEGIN
+		UPDATE response_search
+			SET body = CASE WHEN json_valid(new.response_data) THEN
+				(SELECT group_concat(value, ' ') FROM json_tree(new.response_data) WHERE type = 'text') END
+			WHERE rowid = new.id;
+	END;`,
+	// 35: creator accounts, owning the surveys they create
+	`
+	CREATE TABLE IF NOT EXISTS creators (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL,
+		prefix TEXT NOT NULL,
+		token_hash TEXT NOT NULL UNIQUE,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		last_used_at DATETIME,
+		revoked_at DATETIME
+	);
+	ALTER TABLE surveys ADD COLUMN owner_id INTEGER REFERENCES creators (id);
+	CREATE INDEX IF NOT EXISTS index_surveys_on_owner_id ON surveys (owner_id);`,
+	// 36: organizations, scoping the surveys of their members
+	`
+	CREATE TABLE IF NOT EXISTS organizations (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE TABLE IF NOT EXISTS organization_members (
+		organization_id INTEGER NOT NULL REFERENCES organizations (id),
+		creator_id INTEGER NOT NULL REFERENCES creators (id),
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (organization_id, creator_id)
+	);
+	CREATE INDEX IF NOT EXISTS index_organization_members_on_creator_id ON organization_members (creator_id);
+	ALTER TABLE surveys ADD COLUMN org_id INTEGER REFERENCES organizations (id);
+	CREATE INDEX IF NOT EXISTS index_surveys_on_org_id ON surveys (org_id);`,
+	// 37: public share links of surveys
+	`
+	CREATE TABLE IF NOT EXISTS survey_share_links (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		survey_id INTEGER NOT NULL,
+		prefix TEXT NOT NULL,
+		token_hash TEXT NOT NULL UNIQUE,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		revoked_at DATETIME,
+		FOREIGN KEY (survey_id) REFERENCES surveys (id) ON DELETE CASCADE
+	);
+	CREATE INDEX IF NOT EXISTS index_survey_share_links_on_survey_id ON survey_share_links (survey_id);`,
+	// 38: email invitations with personal links
+	`
+	CREATE TABLE IF NOT EXISTS survey_invitations (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		survey_id INTEGER NOT NULL,
+		email TEXT NOT NULL,
+		status TEXT NOT NULL,
+		prefix TEXT,
+		token_hash TEXT UNIQUE,
+		attempts INTEGER NOT NULL DEFAULT 0,
+		last_error TEXT,
+		next_attempt_at DATETIME,
+		response_id INTEGER,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		sent_at DATETIME,
+		opened_at DATETIME,
+		responded_at DATETIME,
+		UNIQUE (survey_id, email),
+		FOREIGN KEY (survey_id) REFERENCES surveys (id) ON DELETE CASCADE
+	);
+	CREATE INDEX IF NOT EXISTS index_survey_invitations_on_status ON survey_invitations (status, next_attempt_at);`,
+	// 39: invitation reminders
+	`
+	ALTER TABLE surveys ADD COLUMN reminder_after_days INTEGER;
+	ALTER TABLE surveys ADD COLUMN max_reminders INTEGER NOT NULL DEFAULT 1;
+	ALTER TABLE survey_invitations ADD COLUMN reminders_sent INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE survey_invitations ADD COLUMN reminded_at DATETIME;
+	ALTER TABLE survey_invitations ADD COLUMN reminder_token_hash TEXT;
+	CREATE UNIQUE INDEX IF NOT EXISTS index_survey_invitations_on_reminder_token_hash ON survey_invitations (reminder_token_hash);`,
+	// 40: sampling and response filters of webhook subscriptions
+	`
+	ALTER TABLE webhook_subscriptions ADD COLUMN sample_rate REAL;
+	ALTER TABLE webhook_subscriptions ADD COLUMN response_filter TEXT NOT NULL DEFAULT '{}';`,
+	// 41: notification preferences of creators and their queued notifications
+	`
+	CREATE TABLE IF NOT EXISTS notification_preferences (
+		creator_id INTEGER PRIMARY KEY REFERENCES creators (id),
+		events TEXT NOT NULL DEFAULT '[]',
+		channels TEXT NOT NULL DEFAULT '[]',
+		email TEXT NOT NULL DEFAULT '',
+		slack_webhook_url TEXT NOT NULL DEFAULT '',
+		delivery TEXT NOT NULL DEFAULT 'immediate',
+		quiet_start TEXT,
+		quiet_end TEXT,
+		time_zone TEXT NOT NULL DEFAULT 'UTC',
+		last_digest_at DATETIME,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE TABLE IF NOT EXISTS notifications (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		creator_id INTEGER NOT NULL REFERENCES creators (id),
+		survey_id INTEGER NOT NULL,
+		event TEXT NOT NULL,
+		message TEXT NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		sent_at DATETIME,
+		last_error TEXT
+	);
+	CREATE INDEX IF NOT EXISTS index_notifications_on_creator_id_and_sent_at ON notifications (creator_id, sent_at);`,
+	// 42: webhook subscriptions made through REST Hooks
+	`
+	ALTER TABLE webhook_subscriptions ADD COLUMN rest_hook INTEGER NOT NULL DEFAULT 0;`,
+	// 43: Google Sheets syncs of survey responses
+	`
+	CREATE TABLE IF NOT EXISTS survey_sheets (
+		survey_id INTEGER PRIMARY KEY REFERENCES surveys (id) ON DELETE CASCADE,
+		spreadsheet_id TEXT NOT NULL,
+		sheet_name TEXT NOT NULL,
+		client_id TEXT NOT NULL,
+		client_secret BLOB NOT NULL,
+		refresh_token BLOB NOT NULL,
+		last_response_id INTEGER NOT NULL DEFAULT 0,
+		backfill_through INTEGER NOT NULL DEFAULT 0,
+		backfill_after INTEGER NOT NULL DEFAULT 0,
+		header_written INTEGER NOT NULL DEFAULT 0,
+		last_synced_at DATETIME,
+		last_error TEXT,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);`,
+	// 44: scheduled exports of survey responses to S3 or SFTP
+	`
+	CREATE TABLE IF NOT EXISTS export_jobs (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		survey_id INTEGER NOT NULL REFERENCES surveys (id) ON DELETE CASCADE,
+		format TEXT NOT NULL,
+		destination TEXT NOT NULL,
+		config BLOB NOT NULL,
+		interval_minutes INTEGER NOT NULL,
+		next_run_at DATETIME NOT NULL,
+		last_run_status TEXT,
+		last_run_started_at DATETIME,
+		last_run_finished_at DATETIME,
+		last_run_rows INTEGER,
+		last_run_bytes INTEGER,
+		last_run_location TEXT,
+		last_run_error TEXT,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE INDEX IF NOT EXISTS index_export_jobs_on_survey_id ON export_jobs (survey_id);
+	CREATE INDEX IF NOT EXISTS index_export_jobs_on_next_run_at ON export_jobs (next_run_at);`,
+
+	// 45: background job queue, with failed jobs kept as dead letters
+	`
+	CREATE TABLE IF NOT EXISTS jobs (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		kind TEXT NOT NULL,
+		payload BLOB NOT NULL,
+		status TEXT NOT NULL,
+		attempts INTEGER NOT NULL DEFAULT 0,
+		max_attempts INTEGER NOT NULL,
+		run_at DATETIME NOT NULL,
+		last_error TEXT,
+		created_at DATETIME NOT NULL,
+		updated_at DATETIME NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS index_jobs_on_status_and_run_at ON jobs (status, run_at);`,
+
+	// 46: opaque global IDs of surveys and responses, set on insert
+	`
+	ALTER TABLE surveys ADD COLUMN global_id TEXT;
+	ALTER TABLE survey_responses ADD COLUMN global_id TEXT;
+	UPDATE surveys SET global_id = 'srv_' || lower(hex(randomblob(8)));
+	UPDATE survey_responses SET global_id = 'rsp_' || lower(hex(randomblob(8)));
+	CREATE UNIQUE INDEX IF NOT EXISTS index_surveys_on_global_id ON surveys (global_id);
+	CREATE UNIQUE INDEX IF NOT EXISTS index_survey_responses_on_global_id ON survey_responses (global_id);
+	CREATE TRIGGER IF NOT EXISTS surveys_global_id_insert AFTER INSERT ON surveys WHEN NEW.global_id IS NULL BEGIN
+		UPDATE surveys SET global_id = 'srv_' || lower(hex(randomblob(8))) WHERE id = NEW.id;
+	END;
+	CREATE TRIGGER IF NOT EXISTS survey_responses_global_id_insert AFTER INSERT ON survey_responses WHEN NEW.global_id IS NULL BEGIN
+		UPDATE survey_responses SET global_id = 'rsp_' || lower(hex(randomblob(8))) WHERE id = NEW.id;
+	END;`,
+
+	// 47: surveys listed in the public directory
+	`
+	ALTER TABLE surveys ADD COLUMN discoverable BOOLEAN NOT NULL DEFAULT 0;
+	CREATE INDEX IF NOT EXISTS index_surveys_on_discoverable ON surveys (id) WHERE discoverable;`,
+	// 48: custom domains serving the public forms of organizations
+	`ALTER TABLE organizations ADD COLUMN custom_domain TEXT;
+	CREATE UNIQUE INDEX IF NOT EXISTS index_organizations_on_custom_domain ON organizations (custom_domain) WHERE custom_domain IS NOT NULL;`,
+	// 49: search engine indexing of hosted forms, off unless enabled per survey
+	`ALTER TABLE surveys ADD COLUMN indexable BOOLEAN NOT NULL DEFAULT 0;`,
+	// 50: copies of their answers emailed to respondents, and addresses never emailed
+	`
+	ALTER TABLE surveys ADD COLUMN summary_email_enabled BOOLEAN NOT NULL DEFAULT 0;
+	ALTER TABLE surveys ADD COLUMN summary_email_question TEXT NOT NULL DEFAULT '';
+	ALTER TABLE surveys ADD COLUMN summary_email_subject TEXT NOT NULL DEFAULT '';
+	ALTER TABLE surveys ADD COLUMN summary_email_template TEXT NOT NULL DEFAULT '';
+	CREATE TABLE IF NOT EXISTS response_summary_emails (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		survey_id INTEGER NOT NULL,
+		response_id INTEGER NOT NULL,
+		email TEXT NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (survey_id) REFERENCES surveys (id) ON DELETE CASCADE
+	);
+	CREATE INDEX IF NOT EXISTS index_response_summary_emails_on_email ON response_summary_emails (email, created_at);
+	CREATE TABLE IF NOT EXISTS email_suppressions (
+		email TEXT PRIMARY KEY,
+		reason TEXT NOT NULL DEFAULT '',
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);`,
+	// 51: templates reshaping the deliveries of webhook subscriptions
+	`ALTER TABLE webhook_subscriptions ADD COLUMN payload_template TEXT NOT NULL DEFAULT '';`,
+	// 52: the latest change of each survey, deletions included, for offline clients to sync
+	`
+	CREATE TABLE IF NOT EXISTS survey_changes (
+		seq INTEGER PRIMARY KEY AUTOINCREMENT,
+		survey_id INTEGER NOT NULL UNIQUE,
+		owner_id INTEGER,
+		org_id INTEGER,
+		deleted BOOLEAN NOT NULL DEFAULT 0,
+		changed_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+	INSERT INTO survey_changes (survey_id, owner_id, org_id)
+		SELECT id, owner_id, org_id FROM surveys ORDER BY updated_at, id;
+	CREATE TRIGGER IF NOT EXISTS surveys_changes_insert AFTER INSERT ON surveys BEGIN
+		INSERT OR REPLACE INTO survey_changes (survey_id, owner_id, org_id)
+			VALUES (new.id, new.owner_id, new.org_id);
+	END;
+	CREATE TRIGGER IF NOT EXISTS surveys_changes_update AFTER UPDATE ON surveys
+	WHEN new.updated_at IS NOT old.updated_at BEGIN
+		INSERT OR REPLACE INTO survey_changes (survey_id, owner_id, org_id)
+			VALUES (new.id, new.owner_id, new.org_id);
+	END;
+	CREATE TRIGGER IF NOT EXISTS surveys_changes_delete AFTER DELETE ON surveys BEGIN
+		INSERT OR REPLACE INTO survey_changes (survey_id, owner_id, org_id, deleted)
+			VALUES (old.id, old.owner_id, old.org_id, 1);
+	END;
+	CREATE INDEX IF NOT EXISTS index_survey_responses_on_idempotency_key
+		ON survey_responses (idempotency_key) WHERE idempotency_key IS NOT NULL;`,
+	// 53: versions of responses, one more than their revisions, for edits to detect conflicts
+	`
+	ALTER TABLE survey_responses ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+	UPDATE survey_responses
+		SET version = 1 + (SELECT COUNT(*) FROM response_revisions WHERE response_id = survey_responses.id);`,
+}
 
-	_, err = db.Exec(createSurveysTable)
-	if err != nil {
-		log.Fatal(err)
+// migrate brings the database schema up to date
+func migrate(db *sql.DB) error {
+	var version int
+	if err := db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return err
 	}
 
-	_, err = db.Exec(createResponsesTable)
-	if err != nil {
-		log.Fatal(err)
+	// Each migration is applied with its version in one transaction, so a failed
+	// or interrupted migration is tried again in full
+	for i := version; i < len(migrations); i++ {
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(migrations[i]); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migration %d: %w", i+1, err)
+		}
+		if _, err := tx.Exec(fmt.Sprintf("PRAGMA user_version = %d", i+1)); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// queryer runs single-row queries on the database or within a transaction
+type queryer interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// surveyColumns lists the survey columns read by scanSurvey
+const surveyColumns = "s.id, s.title, s.description, s.status, s.publish_at, s.allow_multiple_responses, s.edit_window_minutes, s.opens_at, s.closes_at, s.max_responses, s.questions, s.pages, s.logic, s.translations, s.quality_rules, s.created_at, s.updated_at, s.responses_count, s.anonymous, s.owner_id, s.org_id, s.global_id, s.discoverable, s.indexable"
+
+// scanSurvey scans a row selected with surveyColumns
+func scanSurvey(row rowScanner) (Survey, error) {
+	var survey Survey
+	var publishAt sql.NullTime
+	var editWindowMinutes sql.NullInt64
+	var opensAt, closesAt sql.NullTime
+	var maxResponses, ownerID, orgID sql.NullInt64
+	var questions, pages, logic, translations, qualityRules []byte
+	err := row.Scan(&survey.ID, &survey.Title, &survey.Description, &survey.Status, &publishAt, &survey.AllowMultipleResponses, &editWindowMinutes, &opensAt, &closesAt, &maxResponses, &questions, &pages, &logic, &translations, &qualityRules, &survey.CreatedAt, &survey.UpdatedAt, &survey.ResponsesCount, &survey.Anonymous, &ownerID, &orgID, &survey.GlobalID, &survey.Discoverable, &survey.Indexable)
+	if err == nil {
+		err = json.Unmarshal(questions, &survey.Questions)
+	}
+	if err == nil {
+		err = json.Unmarshal(pages, &survey.Pages)
 	}
+	if err == nil {
+		err = json.Unmarshal(logic, &survey.Logic)
+	}
+	if err == nil {
+		err = json.Unmarshal(translations, &survey.Translations)
+	}
+	if err == nil && qualityRules != nil {
+		err = json.Unmarshal(qualityRules, &survey.QualityRules)
+	}
+	survey.PublishAt = nullTimePtr(publishAt)
+	survey.EditWindowMinutes = nullIntPtr(editWindowMinutes)
+	survey.OpensAt = nullTimePtr(opensAt)
+	survey.ClosesAt = nullTimePtr(closesAt)
+	survey.MaxResponses = nullIntPtr(maxResponses)
+	survey.OwnerID = nullIntPtr(ownerID)
+	survey.OrganizationID = nullIntPtr(orgID)
+	survey.AcceptingResponses = survey.responseWindowError(time.Now()) == "" && !survey.Full()
+	return survey, err
+}
+
+// nullIntPtr converts a nullable integer column to a pointer
+func nullIntPtr(n sql.NullInt64) *int {
+	if !n.Valid {
+		return nil
+	}
+	v := int(n.Int64)
+	return &v
+}
+
+// nullTimePtr converts a nullable time column to a pointer
+func nullTimePtr(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
+
+// utcTimePtr returns t in UTC, as timestamps are stored
+func utcTimePtr(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	u := t.UTC()
+	return &u
+}
+
+// respondentColumn is a response's user_identifier, left blank for anonymous
+// surveys so their tokens are never listed or exported
+const respondentColumn = "CASE WHEN s.anonymous THEN '' ELSE sr.user_identifier END"
+
+// responseColumns lists the response columns read by scanResponse; queries
+// select them FROM responsesFrom so the survey's edit window is available
+const responseColumns = "sr.id, sr.survey_id, " + respondentColumn + ", sr.response_data, sr.created_at, sr.updated_at, " +
+	"sr.channel, sr.country, sr.device, sr.moderation_status, sr.bot_score, sr.validation_warnings, sr.reviewed_at, " +
+	"sr.quality_flags, sr.completion_seconds, s.edit_window_minutes, sr.global_id, sr.version"
+
+// responsesFrom joins responses to their survey
+const responsesFrom = "survey_responses sr JOIN surveys s ON s.id = sr.survey_id"
+
+// queryResponse loads a response, deleted or not, through q
+func queryResponse(ctx context.Context, q queryer, id int) (SurveyResponse, error) {
+	return scanResponse(q.QueryRowContext(ctx, "SELECT "+responseColumns+" FROM "+responsesFrom+" WHERE sr.id = ?", id))
+}
+
+// scanResponse scans a row selected with responseColumns and works out whether it is editable
+func scanResponse(row rowScanner) (SurveyResponse, error) {
+	var response SurveyResponse
+	var data []byte
+	var editWindowMinutes sql.NullInt64
+	var botScore sql.NullFloat64
+	var warnings []byte
+	var reviewedAt sql.NullTime
+	var qualityFlags []byte
+	var completionSeconds sql.NullInt64
+	m := &response.Metadata
+	err := row.Scan(&response.ID, &response.SurveyID, &response.UserIdentifier, &data, &response.CreatedAt, &response.UpdatedAt,
+		&m.Channel, &m.Country, &m.Device, &m.ModerationStatus, &botScore, &warnings, &reviewedAt,
+		&qualityFlags, &completionSeconds, &editWindowMinutes, &response.GlobalID, &response.Version)
+	if err == nil {
+		response.ResponseData, err = openResponseData(data)
+	}
+	if botScore.Valid {
+		m.BotScore = &botScore.Float64
+	}
+	json.Unmarshal(warnings, &m.Warnings)
+	m.ReviewedAt = nullTimePtr(reviewedAt)
+	json.Unmarshal(qualityFlags, &m.QualityFlags)
+	m.CompletionSeconds = nullIntPtr(completionSeconds)
+	response.Editable = responseEditable(response.CreatedAt, nullIntPtr(editWindowMinutes))
+	return response, err
+}
+
+// surveySorts maps the ?sort= values of the survey list to their ORDER BY expression
+var surveySorts = map[string]string{
+	"created_at":      "s.created_at",
+	"responses_count": "s.responses_count",
+	"title":           "s.title COLLATE NOCASE",
 }
 
-// getSurveys returns all surveys
+// likeEscaper escapes LIKE wildcards in user input; patterns use ESCAPE '\'
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// getSurveys returns the published surveys of the caller, filtered by ?q= (title
+// and description) and ?status=, ordered by ?sort= and ?order=. Drafts are only
+// listed for admins and their creators.
 func getSurveys(c *gin.Context) {
-	rows, err := db.Query(`
-		SELECT s.id, s.title, s.description, s.created_at, s.updated_at,
-		       COUNT(sr.id) as responses_count
-		FROM surveys s
-		LEFT JOIN survey_responses sr ON s.id = sr.survey_id
-		GROUP BY s.id
-		ORDER BY s.created_at DESC
-	`)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, APIResponse{
-			Status:  "error",
-			Message: "Failed to fetch surveys",
-			Errors:  []string{err.Error()},
+	ctx := c.Request.Context()
+	var errors []string
+	conditions := []string{}
+	var args []interface{}
+
+	status := c.DefaultQuery("status", SurveyStatusPublished)
+	switch status {
+	case SurveyStatusPublished, SurveyStatusDraft:
+		conditions = append(conditions, "s.status = ?")
+		args = append(args, status)
+	case "all":
+	default:
+		errors = append(errors, "Status must be one of published, draft or all")
+	}
+
+	if q := strings.TrimSpace(c.Query("q")); q != "" {
+		pattern := "%" + likeEscaper.Replace(q) + "%"
+		conditions = append(conditions, `(s.title LIKE ? ESCAPE '\' OR s.description LIKE ? ESCAPE '\')`)
+		args = append(args, pattern, pattern)
+	}
+
+	sortColumn, ok := surveySorts[c.DefaultQuery("sort", "created_at")]
+	if !ok {
+		errors = append(errors, "Sort must be one of created_at, responses_count or title")
+	}
+
+	order := c.DefaultQuery("order", "desc")
+	if order != "asc" && order != "desc" {
+		errors = append(errors, "Order must be either asc or desc")
+	}
+
+	if len(errors) > 0 {
+		abortWithError(c, errInvalidQuery(errors))
+		return
+	}
+
+	// Requests acting in an organization see its surveys, drafts included.
+	// Otherwise creators see their own surveys, drafts included, and other
+	// callers the surveys created without a creator token or organization.
+	// Admins acting in no organization may ask for ?all=true.
+	_, isCreator := currentCreator(c)
+	_, inOrg := currentOrganization(c)
+	all := c.Query("all") == "true"
+	if (status != SurveyStatusPublished && !isAdmin(c) && !isCreator) || (all && !isAdmin(c)) {
+		abortWithError(c, &APIError{
+			Status:  http.StatusForbidden,
+			Code:    CodeAdminRequired,
+			Message: "Admin access required",
 		})
 		return
 	}
+
+	if !all || inOrg {
+		scope, scopeArgs := surveyScope(c, "s")
+		conditions = append(conditions, scope)
+		args = append(args, scopeArgs...)
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+	rows, err := db.QueryContext(ctx, `
+		SELECT `+surveyColumns+`
+		FROM surveys s
+		`+where+`
+		ORDER BY `+sortColumn+` `+order+`, s.id `+order, args...)
+	if err != nil {
+		abortWithError(c, errInternal("Failed to fetch surveys", err))
+		return
+	}
 	defer rows.Close()
 
 	var surveys []Survey
 	for rows.Next() {
-		var survey Survey
-		err := rows.Scan(&survey.ID, &survey.Title, &survey.Description, &survey.CreatedAt, &survey.UpdatedAt, &survey.ResponsesCount)
+		survey, err := scanSurvey(rows)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, APIResponse{
-				Status:  "error",
-				Message: "Failed to scan survey data",
-				Errors:  []string{err.Error()},
-			})
+			abortWithError(c, errInternal("Failed to scan survey data", err))
 			return
 		}
 		surveys = append(surveys, survey)
 	}
 
-	c.JSON(http.StatusOK, APIResponse{
+	// Lists change without a newer updated_at, when rows leave them, so they only get an ETag
+	respondConditional(c, time.Time{}, APIResponse{
 		Status: "success",
 		Data:   surveys,
 	})
 }
 
-// getSurvey returns a specific survey
+// surveyScope is the condition on the surveys aliased as table that the
+// caller lists: its organization's, the calling creator's own, or else those
+// created without a creator token or organization
+func surveyScope(c *gin.Context, table string) (string, []interface{}) {
+	creatorID, isCreator := currentCreator(c)
+	orgID, inOrg := currentOrganization(c)
+	switch {
+	case inOrg:
+		return table + ".org_id = ?", []interface{}{orgID}
+	case isCreator:
+		return table + ".owner_id = ? AND " + table + ".org_id IS NULL", []interface{}{creatorID}
+	}
+	return table + ".owner_id IS NULL AND " + table + ".org_id IS NULL", nil
+}
+
+// getSurvey returns a specific survey, or 304 Not Modified when the client's
+// copy is current
 func getSurvey(c *gin.Context) {
+	ctx := c.Request.Context()
 	id := c.Param("id")
 	surveyID, err := strconv.Atoi(id)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, APIResponse{
-			Status:  "error",
-			Message: "Invalid survey ID",
-			Errors:  []string{err.Error()},
-		})
+		abortWithError(c, errInvalidID("survey"))
 		return
 	}
 
-	var survey Survey
-	err = db.QueryRow(`
-		SELECT s.id, s.title, s.description, s.created_at, s.updated_at,
-		       COUNT(sr.id) as responses_count
-		FROM surveys s
-		LEFT JOIN survey_responses sr ON s.id = sr.survey_id
-		WHERE s.id = ?
-		GROUP BY s.id
-	`, surveyID).Scan(&survey.ID, &survey.Title, &survey.Description, &survey.CreatedAt, &survey.UpdatedAt, &survey.ResponsesCount)
+	survey, err := findSurvey(ctx, surveyID)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
-			c.JSON(http.StatusNotFound, APIResponse{
-				Status:  "error",
-				Message: "Survey not found",
-			})
+			abortWithError(c, errNotFound(CodeSurveyNotFound, "Survey not found"))
 			return
 		}
-		c.JSON(http.StatusInternalServerError, APIResponse{
-			Status:  "error",
-			Message: "Failed to fetch survey",
-			Errors:  []string{err.Error()},
+		abortWithError(c, errInternal("Failed to fetch survey", err))
+		return
+	}
+
+	// Embargoed surveys don't tell when they were last edited
+	if survey.Embargoed() {
+		respondConditional(c, time.Time{}, APIResponse{
+			Status:  "success",
+			Message: "Survey coming soon",
+			Data: ComingSoon{
+				ID:         survey.ID,
+				GlobalID:   survey.GlobalID,
+				Status:     survey.Status,
+				PublishAt:  survey.PublishAt,
+				ComingSoon: true,
+			},
 		})
 		return
 	}
 
-	c.JSON(http.StatusOK, APIResponse{
+	respondConditional(c, survey.UpdatedAt, APIResponse{
 		Status: "success",
 		Data:   survey,
 	})
 }
 
+// findSurvey loads a survey with its responses count
+func findSurvey(ctx context.Context, id int) (Survey, error) {
+	return querySurvey(ctx, db, id)
+}
+
+// querySurvey loads a survey with its responses count through q
+func querySurvey(ctx context.Context, q queryer, id int) (Survey, error) {
+	return scanSurvey(q.QueryRowContext(ctx, `
+		SELECT `+surveyColumns+`
+		FROM surveys s
+		WHERE s.id = ?
+	`, id))
+}
+
 // createSurvey creates a new survey
 func createSurvey(c *gin.Context) {
+	ctx := c.Request.Context()
 	var req CreateSurveyRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, APIResponse{
-			Status:  "error",
-			Message: "Invalid request data",
-			Errors:  []string{err.Error()},
-		})
+		abortWithError(c, errInvalidRequest(err))
 		return
 	}
 
@@ -279,43 +1601,105 @@ func createSurvey(c *gin.Context) {
 		errors = append(errors, "Description must be less than 1000 characters")
 	}
 
+	status := req.Survey.Status
+	if status == "" {
+		status = SurveyStatusPublished
+		if req.Survey.PublishAt != nil {
+			status = SurveyStatusDraft
+		}
+	}
+	if status != SurveyStatusDraft && status != SurveyStatusPublished {
+		errors = append(errors, "Status must be either draft or published")
+	}
+	publishAt := utcTimePtr(req.Survey.PublishAt)
+	if publishAt != nil {
+		if status != SurveyStatusDraft {
+			errors = append(errors, "Publish time can only be set on draft surveys")
+		}
+		if !publishAt.After(time.Now()) {
+			errors = append(errors, "Publish time must be in the future")
+		}
+	}
+
+	if m := req.Survey.EditWindowMinutes; m != nil && (*m < 0 || *m > maxEditWindowMinutes) {
+		errors = append(errors, fmt.Sprintf("Edit window must be between 0 and %d minutes", maxEditWindowMinutes))
+	}
+
+	opensAt, closesAt := utcTimePtr(req.Survey.OpensAt), utcTimePtr(req.Survey.ClosesAt)
+	if opensAt != nil && closesAt != nil && !closesAt.After(*opensAt) {
+		errors = append(errors, "Close time must be after open time")
+	}
+
+	if m := req.Survey.MaxResponses; m != nil && *m < 1 {
+		errors = append(errors, "Max responses must be at least 1")
+	}
+
+	errors = append(errors, validateQuestions(req.Survey.Questions)...)
+	questions := req.Survey.Questions
+	if questions == nil {
+		questions = []Question{}
+	}
+	questionsJSON, _ := json.Marshal(questions)
+
+	// Surveys without rules follow the server defaults, even when those change
+	var qualityRules interface{}
+	if rules := req.Survey.QualityRules; rules != nil {
+		errors = append(errors, rules.validate()...)
+		rulesJSON, _ := json.Marshal(rules)
+		qualityRules = string(rulesJSON)
+	}
+
+	// Multiple responses per user are allowed unless turned off
+	allowMultiple := true
+	if req.Survey.AllowMultipleResponses != nil {
+		allowMultiple = *req.Survey.AllowMultipleResponses
+	}
+
 	if len(errors) > 0 {
-		c.JSON(http.StatusUnprocessableEntity, APIResponse{
-			Status:  "error",
-			Message: "Failed to create survey",
-			Errors:  errors,
-		})
+		abortWithError(c, errValidation("Failed to create survey", errors))
 		return
 	}
 
-	result, err := db.Exec(`
-		INSERT INTO surveys (title, description, created_at, updated_at)
-		VALUES (?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
-	`, req.Survey.Title, req.Survey.Description)
+	var ownerID, orgID *int
+	if creatorID, ok := currentCreator(c); ok {
+		ownerID = &creatorID
+	}
+	if id, ok := currentOrganization(c); ok {
+		orgID = &id
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, APIResponse{
-			Status:  "error",
-			Message: "Failed to create survey",
-			Errors:  []string{err.Error()},
-		})
+		abortWithError(c, errInternal("Failed to create survey", err))
 		return
 	}
+	defer tx.Rollback()
 
-	id, _ := result.LastInsertId()
-	var survey Survey
-	err = db.QueryRow(`
-		SELECT id, title, description, created_at, updated_at, 0 as responses_count
-		FROM surveys WHERE id = ?
-	`, id).Scan(&survey.ID, &survey.Title, &survey.Description, &survey.CreatedAt, &survey.UpdatedAt, &survey.ResponsesCount)
+	result, err := tx.ExecContext(ctx, `
+		INSERT INTO surveys (title, description, status, publish_at, allow_multiple_responses, anonymous, edit_window_minutes, opens_at, closes_at, max_responses, questions, quality_rules, owner_id, org_id, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+	`, req.Survey.Title, req.Survey.Description, status, publishAt, allowMultiple, req.Survey.Anonymous, req.Survey.EditWindowMinutes, opensAt, closesAt, req.Survey.MaxResponses, string(questionsJSON), qualityRules, ownerID, orgID)
+	if err != nil {
+		abortWithError(c, errInternal("Failed to create survey", err))
+		return
+	}
 
+	// Read back in the same transaction so the survey returned is the one inserted
+	id, _ := result.LastInsertId()
+	survey, err := querySurvey(ctx, tx, int(id))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, APIResponse{
-			Status:  "error",
-			Message: "Failed to fetch created survey",
-			Errors:  []string{err.Error()},
-		})
+		abortWithError(c, errInternal("Failed to fetch created survey", err))
 		return
 	}
+	if err := tx.Commit(); err != nil {
+		abortWithError(c, errInternal("Failed to create survey", err))
+		return
+	}
+	auditChange(c, "create", "survey", survey.ID, nil, survey)
+	emitEvent(EventSurveyCreated, survey.ID, survey)
+	if survey.Status == SurveyStatusPublished {
+		emitEventOnce(EventSurveyPublished, survey.ID, survey)
+	}
 
 	c.JSON(http.StatusCreated, APIResponse{
 		Status:  "success",
@@ -326,117 +1710,85 @@ func createSurvey(c *gin.Context) {
 
 // getSurveyResponses returns all responses for a survey
 func getSurveyResponses(c *gin.Context) {
+	ctx := c.Request.Context()
 	surveyID := c.Param("id")
 	id, err := strconv.Atoi(surveyID)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, APIResponse{
-			Status:  "error",
-			Message: "Invalid survey ID",
-			Errors:  []string{err.Error()},
-		})
+		abortWithError(c, errInvalidID("survey"))
 		return
 	}
 
-	// Check if survey exists
-	var exists bool
-	err = db.QueryRow("SELECT EXISTS(SELECT 1 FROM surveys WHERE id = ?)", id).Scan(&exists)
-	if err != nil || !exists {
-		c.JSON(http.StatusNotFound, APIResponse{
-			Status:  "error",
-			Message: "Survey not found",
-		})
+	survey, err := findSurvey(ctx, id)
+	if err != nil {
+		abortWithError(c, errNotFound(CodeSurveyNotFound, "Survey not found"))
 		return
 	}
 
-	rows, err := db.Query(`
-		SELECT id, survey_id, user_identifier, response_data, created_at, updated_at
-		FROM survey_responses
-		WHERE survey_id = ?
-		ORDER BY updated_at DESC
-	`, id)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, APIResponse{
-			Status:  "error",
-			Message: "Failed to fetch responses",
-			Errors:  []string{err.Error()},
-		})
+	q, errors := parseResponseListQuery(c, id)
+	if len(errors) > 0 {
+		abortWithError(c, errInvalidQuery(errors))
 		return
 	}
-	defer rows.Close()
 
-	var responses []SurveyResponse
-	for rows.Next() {
-		var response SurveyResponse
-		err := rows.Scan(&response.ID, &response.SurveyID, &response.UserIdentifier, &response.ResponseData, &response.CreatedAt, &response.UpdatedAt)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, APIResponse{
-				Status:  "error",
-				Message: "Failed to scan response data",
-				Errors:  []string{err.Error()},
-			})
-			return
+	responses, nextCursor, err := listResponses(ctx, q)
+	if err != nil {
+		abortWithError(c, errInternal("Failed to fetch responses", err))
+		return
+	}
+	if pii := piiMaskFor(c, survey); pii != nil {
+		for i := range responses {
+			responses[i].ResponseData = maskResponseData(responses[i].ResponseData, pii)
 		}
-		response.Editable = time.Since(response.CreatedAt) < 24*time.Hour
-		responses = append(responses, response)
 	}
 
-	c.JSON(http.StatusOK, APIResponse{
+	respondConditional(c, time.Time{}, APIResponse{
 		Status: "success",
 		Data:   responses,
+		Meta:   PageMeta{Limit: q.Limit, NextCursor: nextCursor},
 	})
 }
 
-// getSurveyResponse returns a specific survey response
+// getSurveyResponse returns a specific survey response, or 304 Not Modified when
+// the client's copy is current
 func getSurveyResponse(c *gin.Context) {
+	ctx := c.Request.Context()
 	surveyID := c.Param("id")
 	responseID := c.Param("response_id")
 
 	sID, err := strconv.Atoi(surveyID)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, APIResponse{
-			Status:  "error",
-			Message: "Invalid survey ID",
-			Errors:  []string{err.Error()},
-		})
+		abortWithError(c, errInvalidID("survey"))
 		return
 	}
 
 	rID, err := strconv.Atoi(responseID)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, APIResponse{
-			Status:  "error",
-			Message: "Invalid response ID",
-			Errors:  []string{err.Error()},
-		})
+		abortWithError(c, errInvalidID("response"))
 		return
 	}
 
-	var response SurveyResponse
-	err = db.QueryRow(`
-		SELECT id, survey_id, user_identifier, response_data, created_at, updated_at
-		FROM survey_responses
-		WHERE id = ? AND survey_id = ?
-	`, rID, sID).Scan(&response.ID, &response.SurveyID, &response.UserIdentifier, &response.ResponseData, &response.CreatedAt, &response.UpdatedAt)
+	response, err := scanResponse(db.QueryRowContext(ctx, `
+		SELECT `+responseColumns+`
+		FROM `+responsesFrom+`
+		WHERE sr.id = ? AND sr.survey_id = ? AND sr.deleted_at IS NULL
+	`, rID, sID))
 
 	if err != nil {
 		if err == sql.ErrNoRows {
-			c.JSON(http.StatusNotFound, APIResponse{
-				Status:  "error",
-				Message: "Survey response not found",
-			})
+			abortWithError(c, errNotFound(CodeResponseNotFound, "Survey response not found"))
 			return
 		}
-		c.JSON(http.StatusInternalServerError, APIResponse{
-			Status:  "error",
-			Message: "Failed to fetch response",
-			Errors:  []string{err.Error()},
-		})
+		abortWithError(c, errInternal("Failed to fetch response", err))
 		return
 	}
+	survey, err := findSurvey(ctx, sID)
+	if err != nil {
+		abortWithError(c, errInternal("Failed to fetch survey", err))
+		return
+	}
+	response.ResponseData = maskResponseData(response.ResponseData, piiMaskFor(c, survey))
 
-	response.Editable = time.Since(response.CreatedAt) < 24*time.Hour
-
-	c.JSON(http.StatusOK, APIResponse{
+	respondConditional(c, response.UpdatedAt, APIResponse{
 		Status: "success",
 		Data:   response,
 	})
@@ -444,86 +1796,236 @@ func getSurveyResponse(c *gin.Context) {
 
 // createSurveyResponse creates a new survey response
 func createSurveyResponse(c *gin.Context) {
+	ctx := c.Request.Context()
 	surveyID := c.Param("id")
 	sID, err := strconv.Atoi(surveyID)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, APIResponse{
-			Status:  "error",
-			Message: "Invalid survey ID",
-			Errors:  []string{err.Error()},
-		})
+		abortWithError(c, errInvalidID("survey"))
 		return
 	}
 
 	// Check if survey exists
-	var exists bool
-	err = db.QueryRow("SELECT EXISTS(SELECT 1 FROM surveys WHERE id = ?)", sID).Scan(&exists)
-	if err != nil || !exists {
-		c.JSON(http.StatusNotFound, APIResponse{
-			Status:  "error",
-			Message: "Survey not found",
+	survey, err := findSurvey(ctx, sID)
+	if err != nil {
+		abortWithError(c, errNotFound(CodeSurveyNotFound, "Survey not found"))
+		return
+	}
+
+	// Retried submissions get the response they created, even once the survey closed or filled up
+	key, fingerprint, err := idempotencyKey(c)
+	if err != nil {
+		abortWithError(c, errInvalidRequest(err))
+		return
+	}
+	if key != "" {
+		if !idempotencyKeyPattern.MatchString(key) {
+			abortWithError(c, errValidation("Failed to submit survey response",
+				[]string{"Idempotency-Key must be 1-255 visible ASCII characters"}))
+			return
+		}
+		if replaySubmission(c, sID, key, fingerprint) {
+			return
+		}
+	}
+
+	if message := survey.responseWindowError(time.Now()); message != "" {
+		abortWithError(c, &APIError{
+			Status:  http.StatusUnprocessableEntity,
+			Code:    CodeSurveyNotOpen,
+			Message: message,
 		})
 		return
 	}
 
+	if survey.Full() {
+		surveyFull(c, *survey.MaxResponses)
+		return
+	}
+
 	var req CreateResponseRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, APIResponse{
-			Status:  "error",
-			Message: "Invalid request data",
-			Errors:  []string{err.Error()},
-		})
+		abortWithError(c, errInvalidRequest(err))
 		return
 	}
 
 	// Validation
 	var errors []string
-	if len(req.SurveyResponse.UserIdentifier) < 3 {
-		errors = append(errors, "User identifier must be at least 3 characters long")
+	var invitation *Invitation
+	if token := req.SurveyResponse.InvitationToken; token != "" {
+		inv, err := findInvitationByToken(ctx, token)
+		switch {
+		case err != nil || inv.SurveyID != sID:
+			errors = append(errors, "Invitation is invalid or was replaced by a newer one")
+		case inv.RespondedAt != nil:
+			errors = append(errors, "Invitation was already answered")
+		default:
+			invitation = &inv
+			if req.SurveyResponse.UserIdentifier == "" {
+				req.SurveyResponse.UserIdentifier = inv.Email
+			}
+		}
 	}
-	if len(req.SurveyResponse.UserIdentifier) > 100 {
+	if survey.Anonymous {
+		// Whatever the client sent, no identifier of the respondent is stored
+		req.SurveyResponse.UserIdentifier = newRespondentToken()
+	} else if len(req.SurveyResponse.UserIdentifier) < 3 {
+		errors = append(errors, "User identifier must be at least 3 characters long")
+	} else if len(req.SurveyResponse.UserIdentifier) > 100 {
 		errors = append(errors, "User identifier must be less than 100 characters")
 	}
+	errors = append(errors, req.SurveyResponse.Metadata.normalize(c.GetHeader("User-Agent"))...)
+
+	var partial *PartialResponse
+	if partialID := req.SurveyResponse.PartialResponseID; partialID != "" {
+		p, err := findPartialResponse(ctx, sID, partialID)
+		switch {
+		case err != nil:
+			errors = append(errors, "Partial response not found")
+		case p.ResponseID != nil:
+			errors = append(errors, "Partial response was already submitted")
+		default:
+			partial = &p
+			data, err := mergeResponseData(p.Answers, req.SurveyResponse.ResponseData)
+			if err != nil {
+				errors = append(errors, "Response data must be a JSON object")
+			}
+			req.SurveyResponse.ResponseData = data
+		}
+	} else if isJSONNull(req.SurveyResponse.ResponseData) {
+		errors = append(errors, "Response data is required")
+	}
 
 	if len(errors) > 0 {
-		c.JSON(http.StatusUnprocessableEntity, APIResponse{
-			Status:  "error",
-			Message: "Failed to submit survey response",
-			Errors:  errors,
-		})
+		abortWithError(c, errValidation("Failed to submit survey response", errors))
+		return
+	}
+
+	// One response per user: point the client at the existing response instead.
+	// Anonymous respondents can't be told apart.
+	if !survey.AllowMultipleResponses && !survey.Anonymous && respondedAlready(c, db, sID, req.SurveyResponse.UserIdentifier) {
 		return
 	}
 
-	result, err := db.Exec(`
-		INSERT INTO survey_responses (survey_id, user_identifier, response_data, created_at, updated_at)
-		VALUES (?, ?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
-	`, sID, req.SurveyResponse.UserIdentifier, req.SurveyResponse.ResponseData)
+	// Responses completed through autosave are timed from the first save
+	completionSeconds := req.SurveyResponse.Metadata.CompletionSeconds
+	if partial != nil {
+		seconds := int(time.Since(partial.CreatedAt).Seconds())
+		completionSeconds = &seconds
+	}
+	quality, err := checkQuality(ctx, survey, 0, req.SurveyResponse.ResponseData, completionSeconds)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, APIResponse{
-			Status:  "error",
-			Message: "Failed to submit survey response",
-			Errors:  []string{err.Error()},
-		})
+		abortWithError(c, errInternal("Failed to submit survey response", err))
 		return
 	}
 
-	id, _ := result.LastInsertId()
-	var response SurveyResponse
-	err = db.QueryRow(`
-		SELECT id, survey_id, user_identifier, response_data, created_at, updated_at
-		FROM survey_responses WHERE id = ?
-	`, id).Scan(&response.ID, &response.SurveyID, &response.UserIdentifier, &response.ResponseData, &response.CreatedAt, &response.UpdatedAt)
+	// The response is read back, and its partial response marked as submitted, in the
+	// transaction of the insert so the result can't reflect a concurrent write
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		abortWithError(c, errInternal("Failed to submit survey response", err))
+		return
+	}
+	defer tx.Rollback()
+
+	// The quota and the one response per user are checked again in the insert
+	// so concurrent submissions can't overfill the survey or answer it twice
+	result, err := tx.ExecContext(ctx, `
+		INSERT INTO survey_responses (survey_id, user_identifier, response_data, channel, country, device, validation_warnings,
+			quality_flags, completion_seconds, answers_hash, idempotency_key, idempotency_fingerprint, created_at, updated_at)
+		SELECT s.id, ?, ?, ?, ?, ?, ?, ?, ?, ?, NULLIF(?, ''), NULLIF(?, ''), CURRENT_TIMESTAMP, CURRENT_TIMESTAMP
+		FROM surveys s
+		WHERE s.id = ? AND (s.max_responses IS NULL OR s.responses_count < s.max_responses)
+			AND (s.allow_multiple_responses OR s.anonymous OR NOT EXISTS (
+				SELECT 1 FROM survey_responses sr
+				WHERE sr.survey_id = s.id AND sr.user_identifier = ? AND sr.deleted_at IS NULL
+			))
+	`, req.SurveyResponse.UserIdentifier, sealResponseData(req.SurveyResponse.ResponseData),
+		req.SurveyResponse.Metadata.Channel, req.SurveyResponse.Metadata.Country, req.SurveyResponse.Metadata.Device,
+		warningsJSON(survey, req.SurveyResponse.ResponseData),
+		quality.flagsJSON(), completionSeconds, quality.AnswersHash, key, fingerprint, sID, req.SurveyResponse.UserIdentifier)
+	if err != nil {
+		// A concurrent retry with the same key got there first
+		if key != "" && isUniqueViolation(err) {
+			tx.Rollback()
+			if replaySubmission(c, sID, key, fingerprint) {
+				return
+			}
+		}
+		abortWithError(c, errInternal("Failed to submit survey response", err))
+		return
+	}
+	if inserted, _ := result.RowsAffected(); inserted == 0 {
+		if !survey.AllowMultipleResponses && !survey.Anonymous && respondedAlready(c, tx, sID, req.SurveyResponse.UserIdentifier) {
+			return
+		}
+		if survey.MaxResponses != nil {
+			surveyFull(c, *survey.MaxResponses)
+			return
+		}
+		abortWithError(c, errInternal("Failed to submit survey response", fmt.Errorf("survey %d changed during submission", sID)))
+		return
+	}
 
+	id, _ := result.LastInsertId()
+	response, err := queryResponse(ctx, tx, int(id))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, APIResponse{
-			Status:  "error",
-			Message: "Failed to fetch created response",
-			Errors:  []string{err.Error()},
-		})
+		abortWithError(c, errInternal("Failed to fetch created response", err))
+		return
+	}
+	if partial != nil {
+		result, err := tx.ExecContext(ctx, `
+			UPDATE partial_responses SET response_id = ?, updated_at = CURRENT_TIMESTAMP
+			WHERE id = ? AND response_id IS NULL
+		`, response.ID, partial.ID)
+		if err != nil {
+			abortWithError(c, errInternal("Failed to submit survey response", err))
+			return
+		}
+		if submitted, _ := result.RowsAffected(); submitted == 0 {
+			abortWithError(c, &APIError{
+				Status:  http.StatusConflict,
+				Code:    CodeAlreadySubmitted,
+				Message: "Partial response was already submitted",
+			})
+			return
+		}
+	}
+	if invitation != nil {
+		// Responses to anonymous surveys aren't linked to whoever was invited
+		var responseID *int
+		if !survey.Anonymous {
+			responseID = &response.ID
+		}
+		answered, err := rowsAffected(ctx, tx, `
+			UPDATE survey_invitations SET status = ?, response_id = ?, responded_at = CURRENT_TIMESTAMP
+			WHERE id = ? AND responded_at IS NULL
+		`, InvitationResponded, responseID, invitation.ID)
+		if err != nil {
+			abortWithError(c, errInternal("Failed to submit survey response", err))
+			return
+		}
+		if answered == 0 {
+			abortWithError(c, &APIError{
+				Status:  http.StatusConflict,
+				Code:    CodeAlreadySubmitted,
+				Message: "Invitation was already answered",
+			})
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		abortWithError(c, errInternal("Failed to submit survey response", err))
 		return
 	}
 
-	response.Editable = true
+	auditChange(c, "create", "survey_response", response.ID, nil, response)
+	emitEvent(EventResponseCreated, sID, response)
+	queueResponseSummary(ctx, survey, response, invitation)
+	if survey.MaxResponses != nil {
+		if full, err := findSurvey(ctx, sID); err == nil && full.Full() {
+			emitEventOnce(EventSurveyQuotaReached, sID, full)
+		}
+	}
 
 	c.JSON(http.StatusCreated, APIResponse{
 		Status:  "success",
@@ -532,131 +2034,346 @@ func createSurveyResponse(c *gin.Context) {
 	})
 }
 
+// respondedAlready responds 409 with the user's existing response to the
+// survey, if any, and reports whether there was one
+func respondedAlready(c *gin.Context, q queryer, surveyID int, userIdentifier string) bool {
+	var existingID int
+	err := q.QueryRowContext(c.Request.Context(), `
+		SELECT id FROM survey_responses
+		WHERE survey_id = ? AND user_identifier = ? AND deleted_at IS NULL
+		ORDER BY id LIMIT 1
+	`, surveyID, userIdentifier).Scan(&existingID)
+	if err == sql.ErrNoRows {
+		return false
+	}
+	if err != nil {
+		abortWithError(c, errInternal("Failed to submit survey response", err))
+		return true
+	}
+	abortWithError(c, &APIError{
+		Status:  http.StatusConflict,
+		Code:    CodeAlreadyResponded,
+		Message: "User has already responded to this survey",
+		Data:    gin.H{"existing_response_id": existingID},
+	})
+	return true
+}
+
+// surveyFull responds that the survey's response quota has been reached
+func surveyFull(c *gin.Context, maxResponses int) {
+	abortWithError(c, &APIError{
+		Status:  http.StatusForbidden,
+		Code:    CodeSurveyFull,
+		Message: "Survey is full",
+		Errors:  []string{fmt.Sprintf("Survey has reached its maximum of %d responses", maxResponses)},
+	})
+}
+
 // updateSurveyResponse updates a survey response
 func updateSurveyResponse(c *gin.Context) {
+	ctx := c.Request.Context()
 	surveyID := c.Param("id")
 	responseID := c.Param("response_id")
 
 	sID, err := strconv.Atoi(surveyID)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, APIResponse{
-			Status:  "error",
-			Message: "Invalid survey ID",
-			Errors:  []string{err.Error()},
-		})
+		abortWithError(c, errInvalidID("survey"))
 		return
 	}
 
 	rID, err := strconv.Atoi(responseID)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, APIResponse{
-			Status:  "error",
-			Message: "Invalid response ID",
-			Errors:  []string{err.Error()},
-		})
+		abortWithError(c, errInvalidID("response"))
 		return
 	}
 
 	// Check if response exists and is editable
-	var response SurveyResponse
-	err = db.QueryRow(`
-		SELECT id, survey_id, user_identifier, response_data, created_at, updated_at
-		FROM survey_responses
-		WHERE id = ? AND survey_id = ?
-	`, rID, sID).Scan(&response.ID, &response.SurveyID, &response.UserIdentifier, &response.ResponseData, &response.CreatedAt, &response.UpdatedAt)
+	response, err := scanResponse(db.QueryRowContext(ctx, `
+		SELECT `+responseColumns+`
+		FROM `+responsesFrom+`
+		WHERE sr.id = ? AND sr.survey_id = ? AND sr.deleted_at IS NULL
+	`, rID, sID))
 
 	if err != nil {
 		if err == sql.ErrNoRows {
-			c.JSON(http.StatusNotFound, APIResponse{
-				Status:  "error",
-				Message: "Survey response not found",
-			})
+			abortWithError(c, errNotFound(CodeResponseNotFound, "Survey response not found"))
 			return
 		}
-		c.JSON(http.StatusInternalServerError, APIResponse{
-			Status:  "error",
-			Message: "Failed to fetch response",
-			Errors:  []string{err.Error()},
-		})
+		abortWithError(c, errInternal("Failed to fetch response", err))
 		return
 	}
 
-	// Check if response is editable (within 24 hours)
-	if time.Since(response.CreatedAt) >= 24*time.Hour {
-		c.JSON(http.StatusUnprocessableEntity, APIResponse{
-			Status:  "error",
-			Message: "Response cannot be edited after 24 hours",
+	// Check if response is editable (within the survey's edit window)
+	if !response.Editable {
+		abortWithError(c, &APIError{
+			Status:  http.StatusUnprocessableEntity,
+			Code:    CodeEditWindowClosed,
+			Message: "Response cannot be edited after the edit window has closed",
 		})
 		return
 	}
 
 	var req UpdateResponseRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, APIResponse{
-			Status:  "error",
-			Message: "Invalid request data",
-			Errors:  []string{err.Error()},
-		})
+		abortWithError(c, errInvalidRequest(err))
+		return
+	}
+	var errors []string
+	data, base, policy := req.SurveyResponse.ResponseData, req.SurveyResponse.Version, req.SurveyResponse.ConflictPolicy
+	if isJSONNull(data) {
+		errors = append(errors, "Response data is required")
+	}
+	if policy == "" {
+		policy = ConflictReject
+	} else if !containsString(conflictPolicies, policy) {
+		errors = append(errors, "Conflict policy must be one of "+strings.Join(conflictPolicies, ", "))
+	}
+	if base != nil && (*base < 1 || *base > response.Version) {
+		errors = append(errors, fmt.Sprintf("Version must be between 1 and %d", response.Version))
+	}
+	if len(errors) > 0 {
+		abortWithError(c, errValidation("Failed to update survey response", errors))
 		return
 	}
 
-	// Update response data
-	_, err = db.Exec(`
-		UPDATE survey_responses 
-		SET response_data = ?, updated_at = CURRENT_TIMESTAMP
-		WHERE id = ? AND survey_id = ?
-	`, req.SurveyResponse.ResponseData, rID, sID)
+	// An edit made to an older version is resolved against the answers it was
+	// made to, from the response's revisions
+	var conflict *ResponseConflict
+	if base != nil && *base < response.Version {
+		baseData, _, err := findRevisionData(ctx, db, rID, *base)
+		if err != nil {
+			abortWithError(c, errInternal("Failed to fetch response revision", err))
+			return
+		}
+		resolved, answers, applied := resolveEdit(policy, baseData, response.ResponseData, data)
+		conflict = &ResponseConflict{Policy: policy, BaseVersion: *base, ServerVersion: response.Version, Applied: applied, Answers: answers}
+		if policy == ConflictReject {
+			for _, answer := range answers {
+				errors = append(errors, fmt.Sprintf("Answer to %s was changed by another edit", answer.QuestionID))
+			}
+			abortWithError(c, &APIError{
+				Status:  http.StatusConflict,
+				Code:    CodeAnswerConflict,
+				Message: fmt.Sprintf("Response was changed since version %d", *base),
+				Errors:  errors,
+				Data:    gin.H{"response": response, "conflict": conflict},
+			})
+			return
+		}
+		if !applied || jsonEqual(resolved, response.ResponseData) {
+			c.JSON(http.StatusOK, APIResponse{
+				Status:  "success",
+				Message: "Survey response unchanged",
+				Data:    response,
+				Meta:    UpdateResponseMeta{Conflict: conflict},
+			})
+			return
+		}
+		data = resolved
+	}
+
+	survey, err := findSurvey(ctx, sID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, APIResponse{
-			Status:  "error",
-			Message: "Failed to update survey response",
-			Errors:  []string{err.Error()},
-		})
+		abortWithError(c, errInternal("Failed to fetch survey", err))
+		return
+	}
+	quality, err := checkQuality(ctx, survey, rID, data, response.Metadata.CompletionSeconds)
+	if err != nil {
+		abortWithError(c, errInternal("Failed to update survey response", err))
+		return
+	}
+
+	// Keep the previous answers as a revision and update response data together.
+	// Edited answers are checked again, so a reviewed response can be flagged anew.
+	tx, err := db.BeginTx(ctx, nil)
+	if err == nil {
+		defer tx.Rollback()
+		err = saveRevision(ctx, tx, response)
+	}
+	var result sql.Result
+	if err == nil {
+		// The edit only applies to the version it was checked against
+		result, err = tx.ExecContext(ctx, `
+			UPDATE survey_responses
+			SET response_data = ?, validation_warnings = ?, quality_flags = ?, answers_hash = ?,
+				reviewed_at = NULL, version = version + 1, updated_at = CURRENT_TIMESTAMP
+			WHERE id = ? AND survey_id = ? AND version = ?
+		`, sealResponseData(data), warningsJSON(survey, data),
+			quality.flagsJSON(), quality.AnswersHash, rID, sID, response.Version)
+	}
+	if err == nil {
+		if n, _ := result.RowsAffected(); n == 0 {
+			abortWithError(c, &APIError{
+				Status:  http.StatusConflict,
+				Code:    CodeConcurrentSave,
+				Message: "Response was changed by a concurrent edit; try again",
+			})
+			return
+		}
+		err = tx.Commit()
+	}
+	if err != nil {
+		abortWithError(c, errInternal("Failed to update survey response", err))
 		return
 	}
 
 	// Fetch updated response
-	err = db.QueryRow(`
-		SELECT id, survey_id, user_identifier, response_data, created_at, updated_at
-		FROM survey_responses WHERE id = ?
-	`, rID).Scan(&response.ID, &response.SurveyID, &response.UserIdentifier, &response.ResponseData, &response.CreatedAt, &response.UpdatedAt)
+	updated, err := scanResponse(db.QueryRowContext(ctx, `
+		SELECT `+responseColumns+`
+		FROM `+responsesFrom+` WHERE sr.id = ?
+	`, rID))
 
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, APIResponse{
-			Status:  "error",
-			Message: "Failed to fetch updated response",
-			Errors:  []string{err.Error()},
-		})
+		abortWithError(c, errInternal("Failed to fetch updated response", err))
+		return
+	}
+	auditChange(c, "update", "survey_response", rID, response, updated)
+	emitEvent(EventResponseUpdated, sID, updated)
+
+	body := APIResponse{
+		Status:  "success",
+		Message: "Survey response updated successfully",
+		Data:    updated,
+	}
+	if conflict != nil {
+		body.Meta = UpdateResponseMeta{Conflict: conflict}
+	}
+	c.JSON(http.StatusOK, body)
+}
+
+// deleteSurveyResponse soft-deletes a survey response so it can still be restored
+func deleteSurveyResponse(c *gin.Context) {
+	ctx := c.Request.Context()
+	surveyID := c.Param("id")
+	responseID := c.Param("response_id")
+
+	sID, err := strconv.Atoi(surveyID)
+	if err != nil {
+		abortWithError(c, errInvalidID("survey"))
+		return
+	}
+
+	rID, err := strconv.Atoi(responseID)
+	if err != nil {
+		abortWithError(c, errInvalidID("response"))
+		return
+	}
+
+	response, err := scanResponse(db.QueryRowContext(ctx, `
+		SELECT `+responseColumns+`
+		FROM `+responsesFrom+`
+		WHERE sr.id = ? AND sr.survey_id = ? AND sr.deleted_at IS NULL
+	`, rID, sID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			abortWithError(c, errNotFound(CodeResponseNotFound, "Survey response not found"))
+			return
+		}
+		abortWithError(c, errInternal("Failed to fetch response", err))
 		return
 	}
 
-	response.Editable = time.Since(response.CreatedAt) < 24*time.Hour
+	_, err = db.ExecContext(ctx, `
+		UPDATE survey_responses
+		SET deleted_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND survey_id = ?
+	`, rID, sID)
+	if err != nil {
+		abortWithError(c, errInternal("Failed to delete survey response", err))
+		return
+	}
+	auditChange(c, "delete", "survey_response", rID, response, nil)
+	emitEvent(EventResponseDeleted, sID, response)
 
 	c.JSON(http.StatusOK, APIResponse{
 		Status:  "success",
-		Message: "Survey response updated successfully",
+		Message: "Survey response deleted successfully",
+	})
+}
+
+// restoreSurveyResponse undoes a soft delete
+func restoreSurveyResponse(c *gin.Context) {
+	ctx := c.Request.Context()
+	surveyID := c.Param("id")
+	responseID := c.Param("response_id")
+
+	sID, err := strconv.Atoi(surveyID)
+	if err != nil {
+		abortWithError(c, errInvalidID("survey"))
+		return
+	}
+
+	rID, err := strconv.Atoi(responseID)
+	if err != nil {
+		abortWithError(c, errInvalidID("response"))
+		return
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		abortWithError(c, errInternal("Failed to restore survey response", err))
+		return
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `
+		UPDATE survey_responses
+		SET deleted_at = NULL
+		WHERE id = ? AND survey_id = ? AND deleted_at IS NOT NULL
+	`, rID, sID)
+	if err != nil {
+		abortWithError(c, errInternal("Failed to restore survey response", err))
+		return
+	}
+
+	if n, _ := result.RowsAffected(); n == 0 {
+		abortWithError(c, errNotFound(CodeResponseNotFound, "Deleted survey response not found"))
+		return
+	}
+
+	response, err := scanResponse(tx.QueryRowContext(ctx, `
+		SELECT `+responseColumns+`
+		FROM `+responsesFrom+` WHERE sr.id = ?
+	`, rID))
+	if err == nil {
+		err = recordChange(ctx, tx, currentActor(c), "restore", "survey_response", rID, nil, response)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		abortWithError(c, errInternal("Failed to restore survey response", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Status:  "success",
+		Message: "Survey response restored successfully",
 		Data:    response,
 	})
 }
 
 // getUserResponses returns all responses for a specific user
 func getUserResponses(c *gin.Context) {
+	ctx := c.Request.Context()
 	userIdentifier := c.Param("user_identifier")
 
-	rows, err := db.Query(`
+	conditions := "sr.user_identifier = ? AND sr.deleted_at IS NULL AND NOT s.anonymous"
+	args := []interface{}{userIdentifier}
+	if orgID, ok := currentOrganization(c); ok {
+		conditions += " AND s.org_id = ?"
+		args = append(args, orgID)
+	}
+	rows, err := db.QueryContext(ctx, `
 		SELECT sr.id, sr.survey_id, sr.user_identifier, sr.response_data, sr.created_at, sr.updated_at,
-		       s.id, s.title, s.description
+		       s.id, s.title, s.description, s.edit_window_minutes
 		FROM survey_responses sr
 		JOIN surveys s ON sr.survey_id = s.id
-		WHERE sr.user_identifier = ?
+		WHERE `+conditions+`
 		ORDER BY sr.updated_at DESC
-	`, userIdentifier)
+	`, args...)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, APIResponse{
-			Status:  "error",
-			Message: "Failed to fetch user responses",
-			Errors:  []string{err.Error()},
-		})
+		abortWithError(c, errInternal("Failed to fetch user responses", err))
 		return
 	}
 	defer rows.Close()
@@ -665,19 +2382,38 @@ func getUserResponses(c *gin.Context) {
 	for rows.Next() {
 		var response UserResponse
 		var survey Survey
-		err := rows.Scan(&response.ID, &response.Survey.ID, &response.UserIdentifier, &response.ResponseData, &response.CreatedAt, &response.UpdatedAt, &survey.ID, &survey.Title, &survey.Description)
+		var data []byte
+		var editWindowMinutes sql.NullInt64
+		err := rows.Scan(&response.ID, &response.Survey.ID, &response.UserIdentifier, &data, &response.CreatedAt, &response.UpdatedAt, &survey.ID, &survey.Title, &survey.Description, &editWindowMinutes)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, APIResponse{
-				Status:  "error",
-				Message: "Failed to scan user response data",
-				Errors:  []string{err.Error()},
-			})
+			abortWithError(c, errInternal("Failed to scan user response data", err))
 			return
 		}
+		if response.ResponseData, err = openResponseData(data); err != nil {
+			abortWithError(c, errInternal("Failed to decrypt user response data", err))
+			return
+		}
+		survey.EditWindowMinutes = nullIntPtr(editWindowMinutes)
 		response.Survey = survey
-		response.Editable = time.Since(response.CreatedAt) < 24*time.Hour
+		response.Editable = responseEditable(response.CreatedAt, survey.EditWindowMinutes)
 		responses = append(responses, response)
 	}
+	rows.Close()
+
+	masks := map[int]map[string]bool{}
+	for i, response := range responses {
+		pii, ok := masks[response.Survey.ID]
+		if !ok {
+			survey, err := findSurvey(ctx, response.Survey.ID)
+			if err != nil {
+				abortWithError(c, errInternal("Failed to fetch survey", err))
+				return
+			}
+			pii = piiMaskFor(c, survey)
+			masks[survey.ID] = pii
+		}
+		responses[i].ResponseData = maskResponseData(response.ResponseData, pii)
+	}
 
 	c.JSON(http.StatusOK, APIResponse{
 		Status: "success",
@@ -2,11 +2,14 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"path/filepath"
+	"sync"
 	"testing"
 	"time"
 
@@ -53,34 +56,7 @@ func setupTestDB() {
 		panic(err)
 	}
 
-	// Create tables
-	createSurveysTable := `
-	CREATE TABLE IF NOT EXISTS surveys (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		title TEXT NOT NULL,
-		description TEXT NOT NULL,
-		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
-	);`
-
-	createResponsesTable := `
-	CREATE TABLE IF NOT EXISTS survey_responses (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		survey_id INTEGER NOT NULL,
-		user_identifier TEXT NOT NULL,
-		response_data TEXT NOT NULL,
-		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-		FOREIGN KEY (survey_id) REFERENCES surveys (id) ON DELETE CASCADE
-	);`
-
-	_, err = testDB.Exec(createSurveysTable)
-	if err != nil {
-		panic(err)
-	}
-
-	_, err = testDB.Exec(createResponsesTable)
-	if err != nil {
+	if err := migrate(testDB); err != nil {
 		panic(err)
 	}
 }
@@ -90,35 +66,7 @@ func setupTestRouter() *gin.Engine {
 	db = testDB
 
 	gin.SetMode(gin.TestMode)
-	r := gin.Default()
-
-	// Root route
-	r.GET("/", func(c *gin.Context) {
-		c.JSON(http.StatusOK, gin.H{
-			"status":  "success",
-			"message": "Survey Form API",
-			"endpoints": gin.H{
-				"surveys":        "/api/surveys",
-				"responses":      "/api/surveys/{id}/responses",
-				"user_responses": "/api/users/{user_identifier}/responses",
-			},
-		})
-	})
-
-	// API routes
-	api := r.Group("/api")
-	{
-		api.GET("/surveys", getSurveys)
-		api.POST("/surveys", createSurvey)
-		api.GET("/surveys/:id", getSurvey)
-		api.GET("/surveys/:id/responses", getSurveyResponses)
-		api.POST("/surveys/:id/responses", createSurveyResponse)
-		api.GET("/surveys/:id/responses/:response_id", getSurveyResponse)
-		api.PATCH("/surveys/:id/responses/:response_id", updateSurveyResponse)
-		api.GET("/users/:user_identifier/responses", getUserResponses)
-	}
-
-	return r
+	return setupRouter()
 }
 
 func TestGetSurveys(t *testing.T) {
@@ -430,3 +378,461 @@ func TestRootEndpoint(t *testing.T) {
 	assert.Equal(t, "success", response["status"])
 	assert.Equal(t, "Survey Form API", response["message"])
 }
+
+func TestCreateSurveyResponseSingleResponseMode(t *testing.T) {
+	setupTestDB()
+	defer testDB.Close()
+
+	// Create a survey that allows one response per user
+	result, err := testDB.Exec("INSERT INTO surveys (title, description, allow_multiple_responses) VALUES (?, ?, ?)", "Test Survey", "Test Description", false)
+	assert.NoError(t, err)
+	surveyID, _ := result.LastInsertId()
+
+	responseData := json.RawMessage(`{"rating": "5"}`)
+	result2, err := testDB.Exec("INSERT INTO survey_responses (survey_id, user_identifier, response_data) VALUES (?, ?, ?)", surveyID, "testuser", responseData)
+	assert.NoError(t, err)
+	responseID, _ := result2.LastInsertId()
+
+	router := setupTestRouter()
+
+	submit := func(user string) *httptest.ResponseRecorder {
+		jsonData := []byte(fmt.Sprintf(`{"survey_response":{"user_identifier":"%s","response_data":{"rating":"4"}}}`, user))
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", fmt.Sprintf("/api/surveys/%d/responses", surveyID), bytes.NewBuffer(jsonData))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// A second submission by the same user is rejected with the existing ID
+	w := submit("testuser")
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	var response struct {
+		Status string `json:"status"`
+		Data   struct {
+			ExistingResponseID int64 `json:"existing_response_id"`
+		} `json:"data"`
+	}
+	err = json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, "error", response.Status)
+	assert.Equal(t, responseID, response.Data.ExistingResponseID)
+
+	// Other users can still respond
+	w = submit("otheruser")
+	assert.Equal(t, http.StatusCreated, w.Code)
+}
+
+func TestCreateSurveyResponseSingleResponseModeConcurrently(t *testing.T) {
+	// Concurrent requests need a database their connections share
+	var err error
+	testDB, err = openDatabase(filepath.Join(t.TempDir(), "survey_form.db"))
+	assert.NoError(t, err)
+	defer testDB.Close()
+	assert.NoError(t, migrate(testDB))
+	testDB.Exec("INSERT INTO surveys (title, description, allow_multiple_responses) VALUES ('Once', 'd', 0)")
+	router := setupTestRouter()
+
+	// A write held open makes the submissions all get past the check for an
+	// earlier response before any is inserted; only one is
+	lock, err := testDB.Begin()
+	assert.NoError(t, err)
+	start := make(chan struct{})
+	codes := make(chan int, submitUserRateLimit.Requests)
+	var wg sync.WaitGroup
+	for i := 0; i < submitUserRateLimit.Requests; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("POST", "/api/surveys/1/responses", bytes.NewBufferString(`{"survey_response":{"user_identifier":"testuser","response_data":{"rating":"4"}}}`))
+			req.Header.Set("Content-Type", "application/json")
+			router.ServeHTTP(w, req)
+			codes <- w.Code
+		}()
+	}
+	close(start)
+	time.Sleep(200 * time.Millisecond)
+	lock.Rollback()
+	wg.Wait()
+	close(codes)
+
+	created := 0
+	for code := range codes {
+		if code == http.StatusCreated {
+			created++
+		} else {
+			assert.Equal(t, http.StatusConflict, code)
+		}
+	}
+	assert.Equal(t, 1, created)
+	var count int
+	testDB.QueryRow("SELECT COUNT(*) FROM survey_responses WHERE user_identifier = 'testuser'").Scan(&count)
+	assert.Equal(t, 1, count)
+}
+
+func TestAnonymousSurvey(t *testing.T) {
+	setupTestDB()
+	defer testDB.Close()
+
+	result, err := testDB.Exec("INSERT INTO surveys (title, description, allow_multiple_responses, anonymous) VALUES (?, ?, ?, ?)", "Anonymous", "d", false, true)
+	assert.NoError(t, err)
+	surveyID, _ := result.LastInsertId()
+	router := setupTestRouter()
+
+	send := func(method, url, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, url, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// No identifier is required, and one sent is not stored
+	w := send("POST", fmt.Sprintf("/api/surveys/%d/responses", surveyID), `{"survey_response":{"response_data":{"rating":"4"}}}`)
+	assert.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	assert.NotContains(t, w.Body.String(), "respondent_")
+	w = send("POST", fmt.Sprintf("/api/surveys/%d/responses", surveyID), `{"survey_response":{"user_identifier":"alice","response_data":{"rating":"5"}}}`)
+	assert.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+	var identifiers []string
+	rows, _ := testDB.Query("SELECT user_identifier FROM survey_responses WHERE survey_id = ?", surveyID)
+	for rows.Next() {
+		var identifier string
+		rows.Scan(&identifier)
+		identifiers = append(identifiers, identifier)
+	}
+	rows.Close()
+	if assert.Len(t, identifiers, 2) {
+		assert.Regexp(t, `^respondent_[0-9a-f]{32}$`, identifiers[0])
+		assert.NotEqual(t, identifiers[0], identifiers[1])
+	}
+
+	for _, url := range []string{
+		fmt.Sprintf("/api/surveys/%d/responses", surveyID),
+		fmt.Sprintf("/api/surveys/%d/responses/export.ndjson", surveyID),
+		fmt.Sprintf("/api/surveys/%d/questions/rating/answers", surveyID),
+	} {
+		w = send("GET", url, "")
+		assert.Equal(t, http.StatusOK, w.Code, url)
+		assert.NotContains(t, w.Body.String(), "respondent_", url)
+		assert.NotContains(t, w.Body.String(), "alice", url)
+	}
+	w = send("GET", "/api/users/"+identifiers[0]+"/responses", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), "respondent_")
+}
+
+func TestUpdateSurveyResponseOutsideEditWindow(t *testing.T) {
+	setupTestDB()
+	defer testDB.Close()
+
+	// A zero-minute edit window closes as soon as the response is created
+	result, err := testDB.Exec("INSERT INTO surveys (title, description, edit_window_minutes) VALUES (?, ?, ?)", "Test Survey", "Test Description", 0)
+	assert.NoError(t, err)
+	surveyID, _ := result.LastInsertId()
+
+	responseData := json.RawMessage(`{"rating": "5"}`)
+	result2, err := testDB.Exec("INSERT INTO survey_responses (survey_id, user_identifier, response_data) VALUES (?, ?, ?)", surveyID, "testuser", responseData)
+	assert.NoError(t, err)
+	responseID, _ := result2.LastInsertId()
+
+	router := setupTestRouter()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", fmt.Sprintf("/api/surveys/%d/responses/%d", surveyID, responseID), nil)
+	router.ServeHTTP(w, req)
+
+	var fetched struct {
+		Data TestSurveyResponse `json:"data"`
+	}
+	err = json.Unmarshal(w.Body.Bytes(), &fetched)
+	assert.NoError(t, err)
+	assert.False(t, fetched.Data.Editable)
+
+	jsonData := []byte(`{"survey_response":{"response_data":{"rating":"4"}}}`)
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("PATCH", fmt.Sprintf("/api/surveys/%d/responses/%d", surveyID, responseID), bytes.NewBuffer(jsonData))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+}
+
+func TestEditWindowDefault(t *testing.T) {
+	assert.Equal(t, defaultEditWindow, editWindow(nil))
+
+	minutes := 90
+	assert.Equal(t, 90*time.Minute, editWindow(&minutes))
+	assert.True(t, responseEditable(time.Now().Add(-time.Hour), &minutes))
+	assert.False(t, responseEditable(time.Now().Add(-2*time.Hour), &minutes))
+}
+
+func TestCreateSurveyResponseOutsideResponseWindow(t *testing.T) {
+	setupTestDB()
+	defer testDB.Close()
+
+	now := time.Now().UTC()
+	result, err := testDB.Exec("INSERT INTO surveys (title, description, opens_at) VALUES (?, ?, ?)", "Upcoming Survey", "Test Description", now.Add(time.Hour))
+	assert.NoError(t, err)
+	upcomingID, _ := result.LastInsertId()
+
+	result, err = testDB.Exec("INSERT INTO surveys (title, description, closes_at) VALUES (?, ?, ?)", "Closed Survey", "Test Description", now.Add(-time.Hour))
+	assert.NoError(t, err)
+	closedID, _ := result.LastInsertId()
+
+	result, err = testDB.Exec("INSERT INTO surveys (title, description, opens_at, closes_at) VALUES (?, ?, ?, ?)", "Open Survey", "Test Description", now.Add(-time.Hour), now.Add(time.Hour))
+	assert.NoError(t, err)
+	openID, _ := result.LastInsertId()
+
+	router := setupTestRouter()
+
+	submit := func(surveyID int64) *httptest.ResponseRecorder {
+		jsonData := []byte(`{"survey_response":{"user_identifier":"testuser","response_data":{"rating":"5"}}}`)
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", fmt.Sprintf("/api/surveys/%d/responses", surveyID), bytes.NewBuffer(jsonData))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := submit(upcomingID)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Contains(t, w.Body.String(), "Survey is not open for responses yet")
+
+	w = submit(closedID)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Contains(t, w.Body.String(), "Survey is closed for responses")
+
+	assert.Equal(t, http.StatusCreated, submit(openID).Code)
+
+	// accepting_responses reflects the window
+	for id, accepting := range map[int64]bool{upcomingID: false, closedID: false, openID: true} {
+		w = httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", fmt.Sprintf("/api/surveys/%d", id), nil)
+		router.ServeHTTP(w, req)
+
+		var response struct {
+			Data struct {
+				AcceptingResponses bool `json:"accepting_responses"`
+			} `json:"data"`
+		}
+		err = json.Unmarshal(w.Body.Bytes(), &response)
+		assert.NoError(t, err)
+		assert.Equal(t, accepting, response.Data.AcceptingResponses)
+	}
+}
+
+func TestCreateSurveyCloseBeforeOpen(t *testing.T) {
+	setupTestDB()
+	defer testDB.Close()
+
+	router := setupTestRouter()
+
+	jsonData := []byte(`{"survey":{"title":"Window Survey","description":"Test Description","opens_at":"2030-01-02T00:00:00Z","closes_at":"2030-01-01T00:00:00Z"}}`)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/surveys", bytes.NewBuffer(jsonData))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Contains(t, w.Body.String(), "Close time must be after open time")
+}
+
+func TestCreateSurveyResponseMaxResponses(t *testing.T) {
+	setupTestDB()
+	defer testDB.Close()
+
+	result, err := testDB.Exec("INSERT INTO surveys (title, description, max_responses) VALUES (?, ?, ?)", "Limited Survey", "Test Description", 2)
+	assert.NoError(t, err)
+	surveyID, _ := result.LastInsertId()
+
+	router := setupTestRouter()
+
+	submit := func(user string) *httptest.ResponseRecorder {
+		jsonData := []byte(fmt.Sprintf(`{"survey_response":{"user_identifier":"%s","response_data":{"rating":"5"}}}`, user))
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", fmt.Sprintf("/api/surveys/%d/responses", surveyID), bytes.NewBuffer(jsonData))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(t, http.StatusCreated, submit("user1").Code)
+	assert.Equal(t, http.StatusCreated, submit("user2").Code)
+
+	w := submit("user3")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), "Survey is full")
+
+	survey, err := findSurvey(context.Background(), int(surveyID))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, survey.ResponsesCount)
+	assert.False(t, survey.AcceptingResponses)
+}
+
+func TestDeleteAndRestoreSurveyResponse(t *testing.T) {
+	setupTestDB()
+	defer testDB.Close()
+
+	result, err := testDB.Exec("INSERT INTO surveys (title, description, max_responses) VALUES (?, ?, ?)", "Test Survey", "Test Description", 1)
+	assert.NoError(t, err)
+	surveyID, _ := result.LastInsertId()
+
+	result, err = testDB.Exec("INSERT INTO survey_responses (survey_id, user_identifier, response_data) VALUES (?, ?, ?)", surveyID, "testuser", `{"rating": "5"}`)
+	assert.NoError(t, err)
+	responseID, _ := result.LastInsertId()
+
+	router := setupTestRouter()
+	responseURL := fmt.Sprintf("/api/surveys/%d/responses/%d", surveyID, responseID)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("DELETE", responseURL, nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// Deleted responses are hidden and no longer count
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", responseURL, nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	survey, err := findSurvey(context.Background(), int(surveyID))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, survey.ResponsesCount)
+	assert.True(t, survey.AcceptingResponses)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("DELETE", responseURL, nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	// Restoring is admin-only
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", fmt.Sprintf("/api/admin/surveys/%d/responses/%d/restore", surveyID, responseID), nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, adminRequest("POST", fmt.Sprintf("/api/admin/surveys/%d/responses/%d/restore", surveyID, responseID), nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", responseURL, nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var count int
+	testDB.QueryRow("SELECT COUNT(*) FROM audit_log WHERE action = 'restore' AND entity_id = ?", responseID).Scan(&count)
+	assert.Equal(t, 1, count)
+}
+
+func TestResponsesCount(t *testing.T) {
+	setupTestDB()
+	defer testDB.Close()
+
+	count := func(id int64) int {
+		var n int
+		testDB.QueryRow("SELECT responses_count FROM surveys WHERE id = ?", id).Scan(&n)
+		return n
+	}
+	result, _ := testDB.Exec("INSERT INTO surveys (title, description) VALUES ('First', 'd')")
+	first, _ := result.LastInsertId()
+	result, _ = testDB.Exec("INSERT INTO surveys (title, description) VALUES ('Second', 'd')")
+	second, _ := result.LastInsertId()
+
+	for i := 0; i < 3; i++ {
+		testDB.Exec("INSERT INTO survey_responses (survey_id, user_identifier, response_data) VALUES (?, ?, '{}')", first, fmt.Sprintf("user%d", i))
+	}
+	testDB.Exec("INSERT INTO survey_responses (survey_id, user_identifier, response_data, deleted_at) VALUES (?, 'gone', '{}', CURRENT_TIMESTAMP)", first)
+	assert.Equal(t, 3, count(first))
+
+	// Soft deletes, restores, moves and hard deletes keep the counter in step
+	testDB.Exec("UPDATE survey_responses SET deleted_at = CURRENT_TIMESTAMP WHERE user_identifier = 'user0'")
+	assert.Equal(t, 2, count(first))
+	testDB.Exec("UPDATE survey_responses SET deleted_at = NULL WHERE user_identifier IN ('user0', 'gone')")
+	assert.Equal(t, 4, count(first))
+	testDB.Exec("UPDATE survey_responses SET survey_id = ? WHERE user_identifier = 'user1'", second)
+	assert.Equal(t, 3, count(first))
+	assert.Equal(t, 1, count(second))
+	testDB.Exec("DELETE FROM survey_responses WHERE survey_id = ?", first)
+	assert.Equal(t, 0, count(first))
+	assert.Equal(t, 1, count(second))
+
+	// Edits don't change it
+	testDB.Exec("UPDATE survey_responses SET response_data = '{\"rating\": 5}'")
+	assert.Equal(t, 1, count(second))
+}
+
+func TestGetSurveysFilterAndSort(t *testing.T) {
+	setupTestDB()
+	defer testDB.Close()
+
+	for _, s := range []struct{ title, description, status, createdAt string }{
+		{"Customer Feedback", "How did we do?", "published", "2024-01-01 00:00:00"},
+		{"employee pulse", "Weekly check-in, 100% anonymous", "published", "2024-01-02 00:00:00"},
+		{"Beta Program", "Feedback on new features", "published", "2024-01-03 00:00:00"},
+		{"Upcoming Launch", "Not public yet", "draft", "2024-01-04 00:00:00"},
+	} {
+		_, err := testDB.Exec("INSERT INTO surveys (title, description, status, created_at) VALUES (?, ?, ?, ?)", s.title, s.description, s.status, s.createdAt)
+		assert.NoError(t, err)
+	}
+	_, err := testDB.Exec(`INSERT INTO survey_responses (survey_id, user_identifier, response_data)
+		VALUES (2, 'a', '{}'), (2, 'b', '{}'), (3, 'c', '{}')`)
+	assert.NoError(t, err)
+
+	router := setupTestRouter()
+	adminToken = "test-admin-token"
+
+	list := func(query string, admin bool) ([]string, int) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/api/surveys?"+query, nil)
+		if admin {
+			req.Header.Set("Authorization", "Bearer "+adminToken)
+		}
+		router.ServeHTTP(w, req)
+
+		var response struct {
+			Data []Survey `json:"data"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		var titles []string
+		for _, s := range response.Data {
+			titles = append(titles, s.Title)
+		}
+		return titles, w.Code
+	}
+
+	titles, _ := list("", false)
+	assert.Equal(t, []string{"Beta Program", "employee pulse", "Customer Feedback"}, titles)
+
+	titles, _ = list("q=feedback", false)
+	assert.Equal(t, []string{"Beta Program", "Customer Feedback"}, titles)
+
+	// LIKE wildcards in the search are matched literally
+	titles, _ = list("q=100%25", false)
+	assert.Equal(t, []string{"employee pulse"}, titles)
+
+	titles, _ = list("sort=title&order=asc", false)
+	assert.Equal(t, []string{"Beta Program", "Customer Feedback", "employee pulse"}, titles)
+
+	titles, _ = list("sort=responses_count", false)
+	assert.Equal(t, []string{"employee pulse", "Beta Program", "Customer Feedback"}, titles)
+
+	_, code := list("status=draft", false)
+	assert.Equal(t, http.StatusForbidden, code)
+
+	titles, _ = list("status=draft", true)
+	assert.Equal(t, []string{"Upcoming Launch"}, titles)
+
+	titles, _ = list("status=all&sort=created_at&order=asc", true)
+	assert.Len(t, titles, 4)
+
+	_, code = list("sort=popularity", false)
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	_, code = list("order=sideways", false)
+	assert.Equal(t, http.StatusBadRequest, code)
+}
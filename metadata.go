@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Moderation statuses of a response
+const (
+	ModerationPending  = "pending"
+	ModerationApproved = "approved"
+	ModerationRejected = "rejected"
+)
+
+// ResponseMetadata describes where a response came from and how it was triaged
+type ResponseMetadata struct {
+	Channel          string   `json:"channel,omitempty"`
+	Country          string   `json:"country,omitempty"`
+	Device           string   `json:"device,omitempty"`
+	ModerationStatus string   `json:"moderation_status"`
+	BotScore         *float64 `json:"bot_score,omitempty"`
+	// Warnings lists where the answers don't match the survey's questions
+	Warnings   []string   `json:"warnings,omitempty"`
+	ReviewedAt *time.Time `json:"reviewed_at,omitempty"`
+	// QualityFlags lists the data quality problems found in the answers; see quality.go
+	QualityFlags []string `json:"quality_flags,omitempty"`
+	// CompletionSeconds is how long the respondent took, when known
+	CompletionSeconds *int `json:"completion_seconds,omitempty"`
+}
+
+// SubmittedMetadata is the metadata a client may send with a new response
+type SubmittedMetadata struct {
+	Channel string `json:"channel"`
+	Country string `json:"country"`
+	Device  string `json:"device"`
+	// CompletionSeconds is how long the respondent took to fill in the form, as timed by the client
+	CompletionSeconds *int `json:"completion_seconds"`
+}
+
+// countryPattern matches ISO 3166-1 alpha-2 country codes
+var countryPattern = regexp.MustCompile(`^[A-Z]{2}$`)
+
+// metadataFilterColumns lists the ?param= filters that match a metadata column exactly
+var metadataFilterColumns = []string{"channel", "country", "device", "moderation_status"}
+
+// metadataFilter matches a metadata column exactly
+type metadataFilter struct {
+	Column string
+	Value  string
+}
+
+// validModerationStatus reports whether status is a known moderation status
+func validModerationStatus(status string) bool {
+	return status == ModerationPending || status == ModerationApproved || status == ModerationRejected
+}
+
+// normalize fills in the device from the User-Agent and validates the submitted metadata
+func (m *SubmittedMetadata) normalize(userAgent string) []string {
+	var errors []string
+
+	m.Country = strings.ToUpper(strings.TrimSpace(m.Country))
+	if m.Country != "" && !countryPattern.MatchString(m.Country) {
+		errors = append(errors, "Country must be a two-letter ISO 3166-1 code")
+	}
+	if len(m.Channel) > 50 {
+		errors = append(errors, "Channel must be less than 50 characters")
+	}
+	if len(m.Device) > 50 {
+		errors = append(errors, "Device must be less than 50 characters")
+	}
+	if m.CompletionSeconds != nil && *m.CompletionSeconds < 0 {
+		errors = append(errors, "Completion seconds must not be negative")
+	}
+	if m.Device == "" {
+		m.Device = deviceFromUserAgent(userAgent)
+	}
+	return errors
+}
+
+// deviceFromUserAgent roughly classifies a User-Agent as mobile, tablet or desktop
+func deviceFromUserAgent(userAgent string) string {
+	switch {
+	case userAgent == "":
+		return ""
+	case strings.Contains(userAgent, "iPad") || strings.Contains(userAgent, "Tablet"):
+		return "tablet"
+	case strings.Contains(userAgent, "Mobi") || strings.Contains(userAgent, "Android"):
+		return "mobile"
+	case strings.Contains(userAgent, "Mozilla"):
+		return "desktop"
+	}
+	return ""
+}
+
+// parseMetadataFilters reads the metadata filters of a response listing into q
+func parseMetadataFilters(params url.Values, q *responseListQuery) []string {
+	var errors []string
+
+	for _, column := range metadataFilterColumns {
+		if value := params.Get(column); value != "" {
+			q.Metadata = append(q.Metadata, metadataFilter{Column: column, Value: value})
+		}
+	}
+	if status := params.Get("moderation_status"); status != "" && !validModerationStatus(status) {
+		errors = append(errors, "Moderation status must be one of pending, approved or rejected")
+	}
+
+	for _, bound := range []struct {
+		param string
+		dest  **float64
+	}{
+		{"bot_score_min", &q.BotScoreMin},
+		{"bot_score_max", &q.BotScoreMax},
+	} {
+		value := params.Get(bound.param)
+		if value == "" {
+			continue
+		}
+		score, err := strconv.ParseFloat(value, 64)
+		if err != nil || score < 0 || score > 1 {
+			errors = append(errors, fmt.Sprintf("%s must be between 0 and 1", bound.param))
+			continue
+		}
+		*bound.dest = &score
+	}
+
+	return append(errors, parseQualityFilters(params, q)...)
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeviceFromUserAgent(t *testing.T) {
+	assert.Equal(t, "mobile", deviceFromUserAgent("Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) Mobile/15E148"))
+	assert.Equal(t, "tablet", deviceFromUserAgent("Mozilla/5.0 (iPad; CPU OS 17_0 like Mac OS X)"))
+	assert.Equal(t, "desktop", deviceFromUserAgent("Mozilla/5.0 (Windows NT 10.0; Win64; x64)"))
+	assert.Equal(t, "", deviceFromUserAgent("curl/8.4.0"))
+}
+
+func TestGetSurveyResponsesMetadataFilters(t *testing.T) {
+	setupTestDB()
+	defer testDB.Close()
+
+	result, err := testDB.Exec("INSERT INTO surveys (title, description) VALUES (?, ?)", "Test Survey", "Test Description")
+	assert.NoError(t, err)
+	surveyID, _ := result.LastInsertId()
+
+	router := setupTestRouter()
+
+	submit := func(user, metadata, userAgent string) *httptest.ResponseRecorder {
+		body := fmt.Sprintf(`{"survey_response":{"user_identifier":"%s","response_data":{"rating":"5"},"metadata":%s}}`, user, metadata)
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", fmt.Sprintf("/api/surveys/%d/responses", surveyID), bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("User-Agent", userAgent)
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(t, http.StatusCreated, submit("user1", `{"channel":"email","country":"de"}`, "Mozilla/5.0 (iPhone) Mobile").Code)
+	assert.Equal(t, http.StatusCreated, submit("user2", `{"channel":"web","country":"US"}`, "Mozilla/5.0 (Windows NT 10.0)").Code)
+	assert.Equal(t, http.StatusCreated, submit("user3", `{"channel":"web","country":"DE"}`, "Mozilla/5.0 (Windows NT 10.0)").Code)
+	assert.Equal(t, http.StatusUnprocessableEntity, submit("user4", `{"country":"Germany"}`, "").Code)
+
+	_, err = testDB.Exec("UPDATE survey_responses SET bot_score = 0.9, moderation_status = 'pending' WHERE user_identifier = 'user3'")
+	assert.NoError(t, err)
+
+	list := func(query string) ([]string, int) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", fmt.Sprintf("/api/surveys/%d/responses?sort=created_at&order=asc&%s", surveyID, query), nil)
+		router.ServeHTTP(w, req)
+
+		var response struct {
+			Data []SurveyResponse `json:"data"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		var users []string
+		for _, r := range response.Data {
+			users = append(users, r.UserIdentifier)
+		}
+		return users, w.Code
+	}
+
+	users, _ := list("country=DE")
+	assert.Equal(t, []string{"user1", "user3"}, users)
+
+	users, _ = list("channel=web&device=desktop")
+	assert.Equal(t, []string{"user2", "user3"}, users)
+
+	users, _ = list("device=mobile")
+	assert.Equal(t, []string{"user1"}, users)
+
+	users, _ = list("moderation_status=pending")
+	assert.Equal(t, []string{"user3"}, users)
+
+	users, _ = list("bot_score_min=0.5")
+	assert.Equal(t, []string{"user3"}, users)
+
+	_, code := list("moderation_status=unknown")
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	_, code = list("bot_score_max=2")
+	assert.Equal(t, http.StatusBadRequest, code)
+}
@@ -0,0 +1,462 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Notification channels
+const (
+	ChannelEmail = "email"
+	ChannelSlack = "slack"
+)
+
+// Notification deliveries
+const (
+	DeliveryImmediate = "immediate"
+	DeliveryDigest    = "digest"
+)
+
+// notificationChannels lists every channel a creator may be notified on
+var notificationChannels = []string{ChannelEmail, ChannelSlack}
+
+// quietHoursFormat is how the bounds of quiet hours are written
+const quietHoursFormat = "15:04"
+
+// notificationDigestInterval is how often a digest is sent at most
+const notificationDigestInterval = 24 * time.Hour
+
+// slackClient posts notifications to Slack incoming webhooks
+var slackClient = &http.Client{Timeout: 10 * time.Second}
+
+// notificationSummaries describe what each event did to the survey it names
+var notificationSummaries = map[string]string{
+	EventSurveyCreated:      "was created",
+	EventSurveyUpdated:      "was updated",
+	EventSurveyPublished:    "was published",
+	EventSurveyClosed:       "closed",
+	EventSurveyQuotaReached: "reached its response quota",
+	EventResponseCreated:    "received a response",
+	EventResponseUpdated:    "had a response edited",
+	EventResponseDeleted:    "had a response deleted",
+}
+
+// QuietHours hold notifications back between Start and End, which may wrap past midnight
+type QuietHours struct {
+	Start string `json:"start"`
+	End   string `json:"end"`
+}
+
+// NotificationPreferences choose how a creator hears about their surveys
+type NotificationPreferences struct {
+	// Events notified about, named as webhook events; empty turns notifications off
+	Events   []string `json:"events"`
+	Channels []string `json:"channels"`
+	// Email and SlackWebhookURL address the channels of the same name
+	Email           string `json:"email,omitempty"`
+	SlackWebhookURL string `json:"slack_webhook_url,omitempty"`
+	// Delivery is immediate, or a digest at most once a day
+	Delivery   string      `json:"delivery"`
+	QuietHours *QuietHours `json:"quiet_hours"`
+	// TimeZone is the IANA zone quiet hours are in, UTC by default
+	TimeZone string `json:"time_zone"`
+}
+
+// SaveNotificationPreferencesRequest represents the request body for saving notification preferences
+type SaveNotificationPreferencesRequest struct {
+	Preferences NotificationPreferences `json:"notification_preferences" binding:"required"`
+}
+
+// findNotificationPreferences loads a creator's preferences, the defaults when none were saved
+func findNotificationPreferences(ctx context.Context, creatorID int) (NotificationPreferences, error) {
+	prefs := NotificationPreferences{Events: []string{}, Channels: []string{}, Delivery: DeliveryImmediate, TimeZone: "UTC"}
+	var events, channels []byte
+	var quietStart, quietEnd sql.NullString
+	err := db.QueryRowContext(ctx, `
+		SELECT events, channels, email, slack_webhook_url, delivery, quiet_start, quiet_end, time_zone
+		FROM notification_preferences WHERE creator_id = ?
+	`, creatorID).Scan(&events, &channels, &prefs.Email, &prefs.SlackWebhookURL, &prefs.Delivery, &quietStart, &quietEnd, &prefs.TimeZone)
+	if err == sql.ErrNoRows {
+		return prefs, nil
+	}
+	if err == nil {
+		err = json.Unmarshal(events, &prefs.Events)
+	}
+	if err == nil {
+		err = json.Unmarshal(channels, &prefs.Channels)
+	}
+	if quietStart.Valid && quietEnd.Valid {
+		prefs.QuietHours = &QuietHours{Start: quietStart.String, End: quietEnd.String}
+	}
+	return prefs, err
+}
+
+// validate returns the problems of the preferences
+func (prefs NotificationPreferences) validate() []string {
+	var errors []string
+	for _, event := range prefs.Events {
+		if !validEvent(event) {
+			errors = append(errors, fmt.Sprintf("Unknown event %q, expected one of %s", event, strings.Join(webhookEvents, ", ")))
+		}
+	}
+	for _, channel := range prefs.Channels {
+		if !containsString(notificationChannels, channel) {
+			errors = append(errors, fmt.Sprintf("Unknown channel %q, expected one of %s", channel, strings.Join(notificationChannels, ", ")))
+		}
+	}
+	if containsString(prefs.Channels, ChannelEmail) {
+		if addr, err := mail.ParseAddress(prefs.Email); err != nil || addr.Address != prefs.Email {
+			errors = append(errors, "Email must be a valid address to notify by email")
+		}
+	}
+	if containsString(prefs.Channels, ChannelSlack) {
+		if u, err := url.Parse(prefs.SlackWebhookURL); err != nil || u.Scheme != "https" || u.Host == "" {
+			errors = append(errors, "Slack webhook URL must be an https URL to notify on Slack")
+		}
+	}
+	if prefs.Delivery != DeliveryImmediate && prefs.Delivery != DeliveryDigest {
+		errors = append(errors, "Delivery must be either immediate or digest")
+	}
+	if qh := prefs.QuietHours; qh != nil {
+		start, startErr := time.Parse(quietHoursFormat, qh.Start)
+		end, endErr := time.Parse(quietHoursFormat, qh.End)
+		if startErr != nil || endErr != nil {
+			errors = append(errors, "Quiet hours must start and end at HH:MM")
+		} else if start.Equal(end) {
+			errors = append(errors, "Quiet hours must end at a different time than they start")
+		}
+	}
+	if _, err := time.LoadLocation(prefs.TimeZone); err != nil {
+		errors = append(errors, fmt.Sprintf("Unknown time zone %q", prefs.TimeZone))
+	}
+	return errors
+}
+
+// quiet reports whether now falls in the preferences' quiet hours
+func (prefs NotificationPreferences) quiet(now time.Time) bool {
+	if prefs.QuietHours == nil {
+		return false
+	}
+	loc, err := time.LoadLocation(prefs.TimeZone)
+	if err != nil {
+		return false
+	}
+	clock := now.In(loc).Format(quietHoursFormat)
+	start, end := prefs.QuietHours.Start, prefs.QuietHours.End
+	if start < end {
+		return clock >= start && clock < end
+	}
+	return clock >= start || clock < end
+}
+
+// requireCreator returns the creator making the request, responding 403 when there is none
+func requireCreator(c *gin.Context) (int, bool) {
+	creatorID, ok := currentCreator(c)
+	if !ok {
+		abortWithError(c, &APIError{
+			Status:  http.StatusForbidden,
+			Code:    CodeCreatorRequired,
+			Message: "Creator token required",
+		})
+	}
+	return creatorID, ok
+}
+
+// getNotificationPreferences returns the notification preferences of the calling creator
+func getNotificationPreferences(c *gin.Context) {
+	creatorID, ok := requireCreator(c)
+	if !ok {
+		return
+	}
+	prefs, err := findNotificationPreferences(c.Request.Context(), creatorID)
+	if err != nil {
+		abortWithError(c, errInternal("Failed to fetch notification preferences", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Status: "success",
+		Data:   prefs,
+	})
+}
+
+// saveNotificationPreferences replaces the notification preferences of the calling creator
+func saveNotificationPreferences(c *gin.Context) {
+	ctx := c.Request.Context()
+	creatorID, ok := requireCreator(c)
+	if !ok {
+		return
+	}
+	var req SaveNotificationPreferencesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		abortWithError(c, errInvalidRequest(err))
+		return
+	}
+
+	prefs := req.Preferences
+	if prefs.Events == nil {
+		prefs.Events = []string{}
+	}
+	if prefs.Channels == nil {
+		prefs.Channels = []string{}
+	}
+	if prefs.Delivery == "" {
+		prefs.Delivery = DeliveryImmediate
+	}
+	if prefs.TimeZone == "" {
+		prefs.TimeZone = "UTC"
+	}
+	if errors := prefs.validate(); len(errors) > 0 {
+		abortWithError(c, errValidation("Failed to save notification preferences", errors))
+		return
+	}
+
+	before, err := findNotificationPreferences(ctx, creatorID)
+	if err != nil {
+		abortWithError(c, errInternal("Failed to fetch notification preferences", err))
+		return
+	}
+	var quietStart, quietEnd interface{}
+	if prefs.QuietHours != nil {
+		quietStart, quietEnd = prefs.QuietHours.Start, prefs.QuietHours.End
+	}
+	events, _ := json.Marshal(prefs.Events)
+	channels, _ := json.Marshal(prefs.Channels)
+	_, err = db.ExecContext(ctx, `
+		INSERT INTO notification_preferences (creator_id, events, channels, email, slack_webhook_url, delivery, quiet_start, quiet_end, time_zone, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
+		ON CONFLICT (creator_id) DO UPDATE SET
+			events = excluded.events, channels = excluded.channels, email = excluded.email,
+			slack_webhook_url = excluded.slack_webhook_url, delivery = excluded.delivery,
+			quiet_start = excluded.quiet_start, quiet_end = excluded.quiet_end,
+			time_zone = excluded.time_zone, updated_at = excluded.updated_at
+	`, creatorID, string(events), string(channels), prefs.Email, prefs.SlackWebhookURL, prefs.Delivery, quietStart, quietEnd, prefs.TimeZone)
+	if err != nil {
+		abortWithError(c, errInternal("Failed to save notification preferences", err))
+		return
+	}
+	auditChange(c, "update", "notification_preferences", creatorID, before, prefs)
+
+	c.JSON(http.StatusOK, APIResponse{
+		Status:  "success",
+		Message: "Notification preferences saved successfully",
+		Data:    prefs,
+	})
+}
+
+// queueNotification queues event for the owner of the survey when their
+// preferences ask for it; the scheduler sends it
+func queueNotification(event string, surveyID int) {
+	var creatorID int
+	var title string
+	err := db.QueryRow(`
+		SELECT s.owner_id, s.title FROM surveys s
+		JOIN creators cr ON cr.id = s.owner_id AND cr.revoked_at IS NULL
+		WHERE s.id = ?
+	`, surveyID).Scan(&creatorID, &title)
+	if err == sql.ErrNoRows {
+		return
+	}
+	if err == nil {
+		var prefs NotificationPreferences
+		if prefs, err = findNotificationPreferences(context.Background(), creatorID); err == nil {
+			if !containsString(prefs.Events, event) || len(prefs.Channels) == 0 {
+				return
+			}
+			message := fmt.Sprintf("Survey %q %s", title, notificationSummaries[event])
+			_, err = db.Exec("INSERT INTO notifications (creator_id, survey_id, event, message) VALUES (?, ?, ?, ?)",
+				creatorID, surveyID, event, message)
+		}
+	}
+	if err != nil {
+		log.Printf("Notifications: failed to queue %s of survey %d: %v", event, surveyID, err)
+	}
+}
+
+// notify sends a message on each of the preferences' channels
+func notify(ctx context.Context, prefs NotificationPreferences, subject, text string) error {
+	var failures []string
+	for _, channel := range prefs.Channels {
+		var err error
+		switch channel {
+		case ChannelEmail:
+			err = mailer.Send(ctx, Email{To: prefs.Email, Subject: subject, Text: text})
+		case ChannelSlack:
+			err = postSlack(ctx, prefs.SlackWebhookURL, "*"+subject+"*\n"+text)
+		}
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", channel, err))
+		}
+	}
+	if len(failures) > 0 {
+		return fmt.Errorf("%s", strings.Join(failures, "; "))
+	}
+	return nil
+}
+
+// postSlack posts text to a Slack incoming webhook
+func postSlack(ctx context.Context, webhookURL, text string) error {
+	body, _ := json.Marshal(map[string]string{"text": text})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := slackClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// sendNotifications sends the queued notifications of each creator outside
+// their quiet hours: one message per notification for immediate delivery, or
+// one digest of them all at most once a day. Notifications are claimed before
+// they are sent and ones that fail aren't retried.
+func sendNotifications(ctx context.Context, now time.Time) (int, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT DISTINCT n.creator_id FROM notifications n
+		JOIN creators cr ON cr.id = n.creator_id AND cr.revoked_at IS NULL
+		WHERE n.sent_at IS NULL ORDER BY n.creator_id
+	`)
+	if err != nil {
+		return 0, err
+	}
+	var creatorIDs []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		creatorIDs = append(creatorIDs, id)
+	}
+	rows.Close()
+
+	sent := 0
+	for _, creatorID := range creatorIDs {
+		prefs, err := findNotificationPreferences(ctx, creatorID)
+		if err != nil {
+			return sent, err
+		}
+		if prefs.quiet(now) {
+			continue
+		}
+		if prefs.Delivery == DeliveryDigest {
+			n, err := sendDigest(ctx, creatorID, prefs, now)
+			if err != nil {
+				return sent, err
+			}
+			sent += n
+			continue
+		}
+
+		pending, err := pendingNotifications(ctx, creatorID)
+		if err != nil {
+			return sent, err
+		}
+		for _, p := range pending {
+			claimed, err := rowsAffected(ctx, db, "UPDATE notifications SET sent_at = ? WHERE id = ? AND sent_at IS NULL", now.UTC(), p.id)
+			if err != nil {
+				return sent, err
+			}
+			if claimed == 0 {
+				continue
+			}
+			if err := notify(ctx, prefs, p.message, fmt.Sprintf("Event %s of survey %d", p.event, p.surveyID)); err != nil {
+				log.Printf("Notifications: failed to send notification %d: %v", p.id, err)
+				db.ExecContext(ctx, "UPDATE notifications SET last_error = ? WHERE id = ?", err.Error(), p.id)
+				continue
+			}
+			sent++
+		}
+	}
+	return sent, nil
+}
+
+// queuedNotification is a notification waiting to be sent
+type queuedNotification struct {
+	id, surveyID   int
+	event, message string
+}
+
+// pendingNotifications lists the unsent notifications of a creator, oldest first
+func pendingNotifications(ctx context.Context, creatorID int) ([]queuedNotification, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT id, survey_id, event, message FROM notifications
+		WHERE creator_id = ? AND sent_at IS NULL ORDER BY id
+	`, creatorID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var pending []queuedNotification
+	for rows.Next() {
+		var p queuedNotification
+		if err := rows.Scan(&p.id, &p.surveyID, &p.event, &p.message); err != nil {
+			return nil, err
+		}
+		pending = append(pending, p)
+	}
+	return pending, rows.Err()
+}
+
+// sendDigest sends a creator's pending notifications as one message, unless
+// their last digest went out less than notificationDigestInterval ago
+func sendDigest(ctx context.Context, creatorID int, prefs NotificationPreferences, now time.Time) (int, error) {
+	var last sql.NullTime
+	err := db.QueryRowContext(ctx, "SELECT last_digest_at FROM notification_preferences WHERE creator_id = ?", creatorID).Scan(&last)
+	if err != nil {
+		return 0, err
+	}
+	if last.Valid && now.Sub(last.Time) < notificationDigestInterval {
+		return 0, nil
+	}
+	pending, err := pendingNotifications(ctx, creatorID)
+	if err != nil || len(pending) == 0 {
+		return 0, err
+	}
+
+	// Claiming the digest keeps instances sharing the database from sending it twice
+	claimed, err := rowsAffected(ctx, db, `
+		UPDATE notification_preferences SET last_digest_at = ?
+		WHERE creator_id = ? AND (last_digest_at IS NULL OR last_digest_at = ?)
+	`, now.UTC(), creatorID, last)
+	if err != nil || claimed == 0 {
+		return 0, err
+	}
+	maxID := pending[len(pending)-1].id
+	if _, err := db.ExecContext(ctx, "UPDATE notifications SET sent_at = ? WHERE creator_id = ? AND sent_at IS NULL AND id <= ?",
+		now.UTC(), creatorID, maxID); err != nil {
+		return 0, err
+	}
+
+	lines := make([]string, len(pending))
+	for i, p := range pending {
+		lines[i] = "- " + p.message
+	}
+	subject := fmt.Sprintf("Survey digest: %d notification(s)", len(pending))
+	if err := notify(ctx, prefs, subject, strings.Join(lines, "\n")); err != nil {
+		log.Printf("Notifications: failed to send the digest of creator %d: %v", creatorID, err)
+		_, err = db.ExecContext(ctx, "UPDATE notifications SET last_error = ? WHERE creator_id = ? AND sent_at = ? AND id <= ?",
+			err.Error(), creatorID, now.UTC(), maxID)
+		return 0, err
+	}
+	return 1, nil
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNotificationPreferences(t *testing.T) {
+	setupTestDB()
+	defer testDB.Close()
+	router := setupTestRouter()
+	defer func(m Mailer, client *http.Client) { mailer, slackClient = m, client }(mailer, slackClient)
+	sent := &recordingMailer{}
+	mailer = sent
+
+	var mu sync.Mutex
+	var posts []string
+	slack := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		defer mu.Unlock()
+		posts = append(posts, string(body))
+	}))
+	defer slack.Close()
+	slackClient = slack.Client()
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, adminRequest("POST", "/api/admin/creators", []byte(`{"creator":{"name":"Research"}}`)))
+	var created struct {
+		Data Creator `json:"data"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &created)
+	creator := created.Data
+	testDB.Exec("INSERT INTO surveys (title, description, owner_id) VALUES ('Pulse', 'd', ?), ('Unowned', 'd', NULL)", creator.ID)
+
+	send := func(token, method, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, "/api/notification-preferences", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// Preferences belong to creators; notifications are off until chosen
+	assert.Equal(t, http.StatusForbidden, send("", "GET", "").Code)
+	w = send(creator.Token, "GET", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"events":[],"channels":[],"delivery":"immediate","quiet_hours":null,"time_zone":"UTC"`)
+	emitEvent(EventResponseCreated, 1, SurveyResponse{ID: 1})
+	n, err := sendNotifications(context.Background(), time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, 0, n)
+
+	w = send(creator.Token, "PUT", `{"notification_preferences":{"events":["survey.exploded"],"channels":["email","slack","pager"],
+		"slack_webhook_url":"http://hooks.example.com","delivery":"hourly","quiet_hours":{"start":"22:00","end":"22:00"},"time_zone":"Mars/Olympus"}}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	for _, message := range []string{
+		`Unknown event \"survey.exploded\"`, `Unknown channel \"pager\"`, "Email must be a valid address",
+		"Slack webhook URL must be an https URL", "Delivery must be either immediate or digest",
+		"Quiet hours must end at a different time", `Unknown time zone \"Mars/Olympus\"`,
+	} {
+		assert.Contains(t, w.Body.String(), message)
+	}
+
+	w = send(creator.Token, "PUT", fmt.Sprintf(`{"notification_preferences":{"events":["response.created","survey.closed"],
+		"channels":["email","slack"],"email":"research@example.com","slack_webhook_url":%q,"quiet_hours":{"start":"22:00","end":"07:00"}}}`, slack.URL))
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	// Only the events chosen, of the creator's own surveys, are sent, and not during quiet hours
+	emitEvent(EventResponseCreated, 1, SurveyResponse{ID: 1})
+	emitEvent(EventResponseUpdated, 1, SurveyResponse{ID: 1})
+	emitEvent(EventResponseCreated, 2, SurveyResponse{ID: 2})
+	emitEvent(EventSurveyClosed, 1, Survey{ID: 1})
+	runDueJobs(context.Background(), time.Now())
+	night := time.Date(2024, 1, 15, 23, 30, 0, 0, time.UTC)
+	n, _ = sendNotifications(context.Background(), night)
+	assert.Equal(t, 0, n)
+	morning := time.Date(2024, 1, 16, 8, 0, 0, 0, time.UTC)
+	n, err = sendNotifications(context.Background(), morning)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n)
+	if assert.Len(t, sent.sent, 2) {
+		assert.Equal(t, "research@example.com", sent.sent[0].To)
+		assert.Equal(t, `Survey "Pulse" received a response`, sent.sent[0].Subject)
+		assert.Equal(t, `Survey "Pulse" closed`, sent.sent[1].Subject)
+	}
+	mu.Lock()
+	assert.Len(t, posts, 2)
+	mu.Unlock()
+	n, _ = sendNotifications(context.Background(), morning)
+	assert.Equal(t, 0, n)
+
+	// Digests gather the notifications of a day into one message
+	w = send(creator.Token, "PUT", `{"notification_preferences":{"events":["response.created"],"channels":["email"],"email":"research@example.com","delivery":"digest"}}`)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	emitEvent(EventResponseCreated, 1, SurveyResponse{ID: 1})
+	emitEvent(EventResponseCreated, 1, SurveyResponse{ID: 2})
+	runDueJobs(context.Background(), time.Now())
+	n, _ = sendNotifications(context.Background(), morning)
+	assert.Equal(t, 1, n)
+	if assert.Len(t, sent.sent, 3) {
+		assert.Equal(t, "Survey digest: 2 notification(s)", sent.sent[2].Subject)
+		assert.Equal(t, "- Survey \"Pulse\" received a response\n- Survey \"Pulse\" received a response", sent.sent[2].Text)
+	}
+	emitEvent(EventResponseCreated, 1, SurveyResponse{ID: 3})
+	runDueJobs(context.Background(), time.Now())
+	n, _ = sendNotifications(context.Background(), morning.Add(time.Hour))
+	assert.Equal(t, 0, n)
+	n, _ = sendNotifications(context.Background(), morning.Add(notificationDigestInterval))
+	assert.Equal(t, 1, n)
+}
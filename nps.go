@@ -0,0 +1,215 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// NPSCounts classifies the 0-10 answers to a "how likely are you to recommend"
+// question: promoters answered 9-10, passives 7-8 and detractors 0-6. Score is
+// the percentage of promoters minus that of detractors, null without answers.
+type NPSCounts struct {
+	Score      *float64 `json:"score"`
+	Promoters  int      `json:"promoters"`
+	Passives   int      `json:"passives"`
+	Detractors int      `json:"detractors"`
+	Total      int      `json:"total"`
+}
+
+// add counts an answer from 0 to 10
+func (n *NPSCounts) add(answer int) {
+	switch {
+	case answer >= 9:
+		n.Promoters++
+	case answer >= 7:
+		n.Passives++
+	default:
+		n.Detractors++
+	}
+	n.Total++
+}
+
+// score works out Score from the counts, rounded to one decimal
+func (n *NPSCounts) score() {
+	n.Score = nil
+	if n.Total > 0 {
+		score := math.Round(float64(n.Promoters-n.Detractors)*1000/float64(n.Total)) / 10
+		n.Score = &score
+	}
+}
+
+// NPSWeek is the NPS of the responses created in the week starting on Monday
+// WeekStart, UTC
+type NPSWeek struct {
+	WeekStart time.Time `json:"week_start"`
+	NPSCounts
+}
+
+// NPSReport is the Net Promoter Score of a survey's question
+type NPSReport struct {
+	SurveyID int    `json:"survey_id"`
+	Question string `json:"question"`
+	NPSCounts
+	// Skipped counts the answers that aren't whole numbers from 0 to 10
+	Skipped int `json:"skipped"`
+	// Weeks are the weeks from the first response to the last, oldest first,
+	// with ?interval=week
+	Weeks      []NPSWeek `json:"weeks,omitempty"`
+	ComputedAt time.Time `json:"computed_at"`
+}
+
+// npsAnswer reads an answer from 0 to 10, given as a number or numeric string
+func npsAnswer(answer interface{}) (int, bool) {
+	var x float64
+	switch v := answer.(type) {
+	case float64:
+		x = v
+	case string:
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return 0, false
+		}
+		x = f
+	default:
+		return 0, false
+	}
+	if x != math.Trunc(x) || x < 0 || x > 10 {
+		return 0, false
+	}
+	return int(x), true
+}
+
+// weekStart returns the Monday starting t's week, UTC
+func weekStart(t time.Time) time.Time {
+	t = t.UTC()
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	return day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+}
+
+// computeNPS classifies the answers to question of the live, non-rejected
+// responses matching q, by week when weekly is set
+func computeNPS(ctx context.Context, q responseListQuery, question string, weekly bool) (NPSReport, error) {
+	report := NPSReport{SurveyID: q.SurveyID, Question: question}
+	where, args := q.where()
+	rows, err := db.QueryContext(ctx, `
+		SELECT sr.response_data, sr.created_at FROM survey_responses sr
+		WHERE `+where+` AND sr.moderation_status != ?
+		ORDER BY sr.created_at ASC, sr.id ASC
+	`, append(args, ModerationRejected)...)
+	if err != nil {
+		return report, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var data []byte
+		var createdAt time.Time
+		if err := rows.Scan(&data, &createdAt); err != nil {
+			return report, err
+		}
+		data, err := openResponseData(data)
+		if err != nil {
+			return report, err
+		}
+		var answers map[string]interface{}
+		if json.Unmarshal(data, &answers) != nil || answers[question] == nil {
+			continue
+		}
+		answer, ok := npsAnswer(answers[question])
+		if !ok {
+			report.Skipped++
+			continue
+		}
+		report.add(answer)
+
+		if !weekly {
+			continue
+		}
+		week := weekStart(createdAt)
+		if len(report.Weeks) == 0 {
+			report.Weeks = []NPSWeek{{WeekStart: week}}
+		}
+		// Weeks without answers in between are listed too
+		for last := report.Weeks[len(report.Weeks)-1].WeekStart; last.Before(week); {
+			last = last.AddDate(0, 0, 7)
+			report.Weeks = append(report.Weeks, NPSWeek{WeekStart: last})
+		}
+		report.Weeks[len(report.Weeks)-1].add(answer)
+	}
+	if err := rows.Err(); err != nil {
+		return report, err
+	}
+
+	report.score()
+	for i := range report.Weeks {
+		report.Weeks[i].score()
+	}
+	return report, nil
+}
+
+// getSurveyNPS returns the Net Promoter Score of the 0-10 question ?question=,
+// filtered like the other analytics endpoints and by week with ?interval=week
+func getSurveyNPS(c *gin.Context) {
+	ctx := c.Request.Context()
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		abortWithError(c, errInvalidID("survey"))
+		return
+	}
+
+	survey, err := findSurvey(ctx, id)
+	if err != nil {
+		abortWithError(c, errNotFound(CodeSurveyNotFound, "Survey not found"))
+		return
+	}
+
+	q, errors := parseAnalyticsQuery(c, id)
+	question := c.Query("question")
+	if !questionIDPattern.MatchString(question) {
+		errors = append(errors, "Question must be the ID of a question answered from 0 to 10")
+	} else if len(survey.Questions) > 0 {
+		var def *Question
+		for i := range survey.Questions {
+			if survey.Questions[i].ID == question {
+				def = &survey.Questions[i]
+			}
+		}
+		if def == nil {
+			errors = append(errors, "Question "+question+" doesn't exist")
+		} else if def.Type != QuestionRating && def.Type != QuestionNumber {
+			errors = append(errors, "Question "+question+" must be a rating or number question")
+		}
+	}
+	interval := c.Query("interval")
+	if interval != "" && interval != "week" {
+		errors = append(errors, "Interval must be week")
+	}
+	if len(errors) > 0 {
+		abortWithError(c, errInvalidQuery(errors))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, analyticsTimeout)
+	defer cancel()
+	if !checkAnalyticsCost(ctx, c, q) {
+		return
+	}
+
+	report, err := computeNPS(ctx, q, question, interval == "week")
+	if err != nil {
+		analyticsQueryFailed(ctx, c, err)
+		return
+	}
+	report.ComputedAt = time.Now().UTC()
+
+	c.JSON(http.StatusOK, APIResponse{
+		Status: "success",
+		Data:   report,
+	})
+}
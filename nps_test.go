@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSurveyNPS(t *testing.T) {
+	setupTestDB()
+	defer testDB.Close()
+	router := setupTestRouter()
+
+	testDB.Exec(`INSERT INTO surveys (title, description, questions) VALUES ('Relationship', 'd',
+		'[{"id":"recommendation_likelihood","type":"rating","label":"Recommend us?","min":0,"max":10},{"id":"comment","type":"text","label":"Why?"}]')`)
+	// Two weeks apart, with nothing in the week between
+	for i, r := range []struct {
+		answer string
+		at     string
+	}{
+		{`10`, "2024-01-01 09:00:00"},
+		{`9`, "2024-01-03 09:00:00"},
+		{`"7"`, "2024-01-07 23:00:00"},
+		{`3`, "2024-01-05 09:00:00"},
+		{`8`, "2024-01-16 09:00:00"},
+		{`10`, "2024-01-21 09:00:00"},
+		{`11`, "2024-01-21 10:00:00"},
+		{`null`, "2024-01-21 11:00:00"},
+	} {
+		testDB.Exec("INSERT INTO survey_responses (survey_id, user_identifier, response_data, created_at) VALUES (1, ?, ?, ?)",
+			fmt.Sprintf("customer%d", i), `{"recommendation_likelihood": `+r.answer+`}`, r.at)
+	}
+
+	get := func(query string) (int, NPSReport, []string) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/api/surveys/1/nps?"+query, nil)
+		router.ServeHTTP(w, req)
+		var body struct {
+			Data   NPSReport `json:"data"`
+			Errors []string  `json:"errors"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body), w.Body.String())
+		return w.Code, body.Data, body.Errors
+	}
+	score := func(f float64) *float64 { return &f }
+
+	code, report, _ := get("question=recommendation_likelihood")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, NPSCounts{Score: score(33.3), Promoters: 3, Passives: 2, Detractors: 1, Total: 6}, report.NPSCounts)
+	assert.Equal(t, 1, report.Skipped)
+	assert.Nil(t, report.Weeks)
+
+	code, report, _ = get("question=recommendation_likelihood&interval=week")
+	assert.Equal(t, http.StatusOK, code)
+	require.Len(t, report.Weeks, 3)
+	week := func(day string) time.Time { d, _ := time.Parse("2006-01-02", day); return d }
+	assert.Equal(t, NPSWeek{WeekStart: week("2024-01-01"), NPSCounts: NPSCounts{Score: score(25), Promoters: 2, Passives: 1, Detractors: 1, Total: 4}}, report.Weeks[0])
+	assert.Equal(t, NPSWeek{WeekStart: week("2024-01-08")}, report.Weeks[1])
+	assert.Equal(t, NPSWeek{WeekStart: week("2024-01-15"), NPSCounts: NPSCounts{Score: score(50), Promoters: 1, Passives: 1, Total: 2}}, report.Weeks[2])
+
+	// Filters apply
+	_, report, _ = get("question=recommendation_likelihood&from=2024-01-15T00:00:00Z")
+	assert.Equal(t, 2, report.Total)
+
+	code, _, errors := get("question=comment&interval=month")
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, []string{"Question comment must be a rating or number question", "Interval must be week"}, errors)
+	_, _, errors = get("question=recommend")
+	assert.Equal(t, []string{"Question recommend doesn't exist"}, errors)
+	_, _, errors = get("")
+	assert.Equal(t, []string{"Question must be the ID of a question answered from 0 to 10"}, errors)
+}
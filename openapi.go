@@ -0,0 +1,526 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// apiOperation documents one route of the API for the OpenAPI document
+type apiOperation struct {
+	Method  string
+	Path    string // Gin syntax, relative to /api
+	Summary string
+	Tag     string
+	Admin   bool
+	Query   []apiParam
+	Headers []apiParam
+	Request interface{} // request body, nil when there is none
+	Data    interface{} // type of the envelope's data, nil when there is none
+	Status  int         // success status, http.StatusOK when zero
+	// ContentType is set on routes answering with a bare document instead of the envelope
+	ContentType string
+}
+
+// apiParam documents a query or header parameter
+type apiParam struct {
+	Name        string
+	Description string
+}
+
+// responseListParams are accepted by the response listing and neighbor navigation
+var responseListParams = []apiParam{
+	{"view", "Saved view ID providing the default ordering"},
+	{"sort", "updated_at (default) or created_at"},
+	{"order", "desc (default) or asc"},
+	{"limit", "Page size, 1-200"},
+	{"cursor", "meta.next_cursor of the previous page"},
+	{"channel", "Respondent channel"},
+	{"country", "Two-letter country code"},
+	{"device", "mobile, tablet or desktop"},
+	{"moderation_status", "pending, approved or rejected"},
+	{"bot_score_min", "Minimum bot score, 0-1"},
+	{"bot_score_max", "Maximum bot score, 0-1"},
+	{"quality_flag", "Only responses with this quality flag: straight_lining, speeder, high_missingness or duplicate_suspect"},
+	{"exclude_quality", "Leave out responses with any of these comma-separated quality flags, or all"},
+	{"answer[question][operator]", "Answer to a question in response_data; operator is eq (default, may be omitted), ne, contains, gt, gte, lt or lte"},
+}
+
+// analyticsParams filter the analytics endpoints
+var analyticsParams = []apiParam{
+	{"from", "RFC 3339 lower bound of created_at"},
+	{"to", "RFC 3339 exclusive upper bound of created_at"},
+	{"channel", "Respondent channel"},
+	{"country", "Two-letter country code"},
+	{"device", "mobile, tablet or desktop"},
+	{"moderation_status", "pending, approved or rejected"},
+	{"bot_score_min", "Minimum bot score, 0-1"},
+	{"bot_score_max", "Maximum bot score, 0-1"},
+	{"quality_flag", "Only responses with this quality flag: straight_lining, speeder, high_missingness or duplicate_suspect"},
+	{"exclude_quality", "Leave out responses with any of these comma-separated quality flags, or all"},
+	{"answer[question][operator]", "Answer to a question in response_data; operator is eq (default, may be omitted), ne, contains, gt, gte, lt or lte"},
+}
+
+// recodeParam applies recode maps to analytics and exports
+var recodeParam = apiParam{"recode", "Name of recode maps defined on the survey's questions to apply to their answers"}
+
+// exportPasswordParam protects an export as an AES-256 encrypted ZIP
+var exportPasswordParam = apiParam{"X-Export-Password", "Password of at least 8 characters; the export is sent as an AES-256 encrypted ZIP"}
+
+// clientErrorParams filter the client error routes
+var clientErrorParams = []apiParam{
+	{"survey_id", "Survey ID"},
+	{"kind", "render, submit or other"},
+	{"device", "mobile, tablet or desktop"},
+	{"from", "RFC 3339 lower bound of created_at; the summary defaults to a day ago"},
+	{"to", "RFC 3339 exclusive upper bound of created_at"},
+}
+
+// apiOperations describes every /api route registered in setupRouter;
+// TestOpenAPIDocumentsAllRoutes keeps the two in sync
+var apiOperations = []apiOperation{
+	{Method: "GET", Path: "/limits", Summary: "List rate limits", Tag: "System", Data: []RateLimitRule{}},
+
+	{Method: "GET", Path: "/lookup/:global_id", Summary: "Get a survey (srv_) or response (rsp_) by its global ID, as its own route returns it", Tag: "Surveys", Data: Survey{}},
+	{Method: "GET", Path: "/discover", Summary: "List the open surveys marked discoverable, when the directory is enabled", Tag: "Surveys", Data: []DiscoverableSurvey{}, Query: []apiParam{
+		{"cursor", "next_cursor of the previous page"},
+		{"limit", "Number of surveys (default 50, at most 200)"},
+	}},
+	{Method: "GET", Path: "/surveys", Summary: "List surveys", Tag: "Surveys", Data: []Survey{}, Query: []apiParam{
+		{"q", "Search in title and description"},
+		{"status", "published (default), or draft or all for admins and creators"},
+		{"sort", "created_at (default), responses_count or title"},
+		{"order", "desc (default) or asc"},
+		{"all", "true lists every owner's surveys (admin only)"},
+	}},
+	{Method: "POST", Path: "/surveys", Summary: "Create a survey", Tag: "Surveys", Request: CreateSurveyRequest{}, Data: Survey{}, Status: http.StatusCreated},
+	{Method: "GET", Path: "/surveys/:id", Summary: "Get a survey; drafts return a coming soon payload", Tag: "Surveys", Data: Survey{}},
+	{Method: "GET", Path: "/surveys/:id/response-schema", Summary: "Get the JSON Schema of response_data", Tag: "Surveys", ContentType: "application/schema+json"},
+	{Method: "GET", Path: "/surveys/:id/share", Summary: "List a survey's share links", Tag: "Surveys", Data: []ShareLink{}},
+	{Method: "POST", Path: "/surveys/:id/share", Summary: "Create a public link to a survey's form", Tag: "Surveys", Data: ShareLink{}, Status: http.StatusCreated},
+	{Method: "DELETE", Path: "/surveys/:id/share/:share_id", Summary: "Revoke a share link", Tag: "Surveys"},
+	{Method: "GET", Path: "/surveys/:id/invitations", Summary: "List a survey's email invitations and their status", Tag: "Surveys", Data: []Invitation{}, Query: []apiParam{
+		{"status", "Only invitations with this status: pending, sent, failed, opened or responded"},
+	}},
+	{Method: "POST", Path: "/surveys/:id/invitations", Summary: "Invite a list of emails to a survey", Tag: "Surveys", Request: CreateInvitationsRequest{}, Data: InvitationUpload{}, Status: http.StatusCreated},
+	{Method: "POST", Path: "/surveys/:id/invitations/:invitation_id/resend", Summary: "Send an invitation again with a new link", Tag: "Surveys", Data: Invitation{}},
+	{Method: "GET", Path: "/surveys/:id/invitations/reminders", Summary: "Get a survey's reminder settings and preview who will be reminded, and when", Tag: "Surveys", Data: ReminderPreview{}},
+	{Method: "GET", Path: "/surveys/:id/sheets", Summary: "Get the Google Sheets sync of a survey", Tag: "Surveys", Data: SheetSync{}},
+	{Method: "PUT", Path: "/surveys/:id/sheets", Summary: "Append a survey's new responses to a Google Sheet", Tag: "Surveys", Request: SaveSheetSyncRequest{}, Data: SheetSync{}},
+	{Method: "DELETE", Path: "/surveys/:id/sheets", Summary: "Stop syncing a survey's responses to Google Sheets", Tag: "Surveys"},
+	{Method: "POST", Path: "/surveys/:id/sheets/backfill", Summary: "Append the responses received before the Google Sheets sync was configured", Tag: "Surveys", Data: SheetBackfill{}},
+	{Method: "GET", Path: "/sync", Summary: "Get the surveys changed since the last sync and acknowledge uploaded responses, for offline clients", Tag: "Surveys", Data: SyncResult{}, Query: []apiParam{
+		{"since", "meta.next_cursor of the previous sync; omit to get every survey"},
+		{"limit", "Changes per page, 1-200"},
+		{"key", "Idempotency-Key of an uploaded response to acknowledge; repeatable, up to 100"},
+	}},
+	{Method: "GET", Path: "/notification-preferences", Summary: "Get the calling creator's notification preferences", Tag: "Surveys", Data: NotificationPreferences{}},
+	{Method: "PUT", Path: "/notification-preferences", Summary: "Choose the events, channels, delivery and quiet hours of the calling creator's notifications", Tag: "Surveys", Request: SaveNotificationPreferencesRequest{}, Data: NotificationPreferences{}},
+	{Method: "PUT", Path: "/surveys/:id/invitations/reminders", Summary: "Configure reminders to invitees who haven't responded", Tag: "Surveys", Request: SaveReminderSettingsRequest{}, Data: ReminderSettings{}},
+	{Method: "GET", Path: "/surveys/:id/summary-email", Summary: "Get the settings of the copy of their answers emailed to respondents", Tag: "Surveys", Data: SummaryEmailSettings{}},
+	{Method: "PUT", Path: "/surveys/:id/summary-email", Summary: "Configure the copy of their answers emailed to respondents", Tag: "Surveys", Request: SaveSummaryEmailSettingsRequest{}, Data: SummaryEmailSettings{}},
+	{Method: "POST", Path: "/surveys/:id/schedule", Summary: "Schedule a draft survey", Tag: "Surveys", Request: ScheduleSurveyRequest{}, Data: Survey{}},
+	{Method: "PUT", Path: "/surveys/:id/pages", Summary: "Set the pages of a draft survey", Tag: "Wizard", Request: SavePagesRequest{}, Data: Survey{}},
+	{Method: "POST", Path: "/surveys/:id/questions", Summary: "Add a question to a draft survey", Tag: "Wizard", Request: AddQuestionRequest{}, Data: Survey{}},
+	{Method: "DELETE", Path: "/surveys/:id/questions/:question_id", Summary: "Remove a question from a draft survey", Tag: "Wizard", Data: Survey{}},
+	{Method: "PUT", Path: "/surveys/:id/questions/:question_id/recodes", Summary: "Set a question's recode maps for analytics", Tag: "Surveys", Request: SaveRecodesRequest{}, Data: Survey{}},
+	{Method: "PUT", Path: "/surveys/:id/questions/:question_id/pii", Summary: "Mark a question as PII, masking its answers for non-admins (admin only)", Tag: "Surveys", Request: SavePIIRequest{}, Data: Survey{}},
+	{Method: "PUT", Path: "/surveys/:id/discoverable", Summary: "List a survey in the public directory, or take it out (admin only)", Tag: "Surveys", Request: SaveDiscoverableRequest{}, Data: Survey{}},
+	{Method: "PUT", Path: "/surveys/:id/indexing", Summary: "Let search engines index a survey's hosted form, or keep it link-only (admin only)", Tag: "Surveys", Request: SaveIndexingRequest{}, Data: Survey{}},
+	{Method: "PUT", Path: "/surveys/:id/logic", Summary: "Set the logic rules of a draft survey", Tag: "Wizard", Request: SaveLogicRequest{}, Data: Survey{}},
+	{Method: "PUT", Path: "/surveys/:id/translations", Summary: "Set the translations of a draft survey", Tag: "Wizard", Request: SaveTranslationsRequest{}, Data: Survey{}},
+	{Method: "GET", Path: "/surveys/:id/validation", Summary: "List every issue blocking the survey's publication", Tag: "Wizard", Data: SurveyValidation{}},
+	{Method: "GET", Path: "/surveys/:id/lint", Summary: "Advisory warnings on the survey's design", Tag: "Wizard", Data: SurveyLint{}},
+	{Method: "POST", Path: "/surveys/:id/publish", Summary: "Publish a draft survey without blocking issues", Tag: "Wizard", Data: Survey{}},
+	{Method: "GET", Path: "/surveys/:id/results", Summary: "Get per-question aggregates, cached briefly when unfiltered", Tag: "Surveys", Query: append([]apiParam{recodeParam}, analyticsParams...), Data: SurveyResults{}},
+	{Method: "GET", Path: "/surveys/:id/results/live", Summary: "Watch per-question aggregates over a WebSocket, sent whenever they change", Tag: "Surveys", Data: SurveyResults{}, Status: http.StatusSwitchingProtocols},
+	{Method: "GET", Path: "/surveys/:id/rollups", Summary: "Get results per org unit, counting its descendants, with small units suppressed", Tag: "Surveys", Query: append([]apiParam{
+		{"question", "ID of the question answered with org unit codes (required)"},
+		{"min_n", "Fewest responses a unit needs to be shown; at least ROLLUP_MIN_N"},
+	}, analyticsParams...), Data: SurveyRollups{}},
+	{Method: "GET", Path: "/surveys/:id/nps", Summary: "Get the Net Promoter Score of a 0-10 question, optionally by week", Tag: "Surveys", Query: append([]apiParam{
+		{"question", "ID of the question answered from 0 to 10 (required)"},
+		{"interval", "week to also report every week, Monday to Sunday UTC"},
+	}, analyticsParams...), Data: NPSReport{}},
+	{Method: "GET", Path: "/surveys/:id/history", Summary: "Get the daily snapshots of a survey's aggregates, oldest first", Tag: "Surveys", Data: []SurveySnapshot{}, Query: []apiParam{
+		{"from", "First day (YYYY-MM-DD)"},
+		{"to", "Last day (YYYY-MM-DD)"},
+	}},
+
+	{Method: "GET", Path: "/surveys/:id/responses", Summary: "List responses", Tag: "Responses", Query: responseListParams, Data: []SurveyResponse{}},
+	{Method: "POST", Path: "/surveys/:id/responses", Summary: "Submit a response", Tag: "Responses", Request: CreateResponseRequest{}, Data: SurveyResponse{}, Status: http.StatusCreated},
+	{Method: "GET", Path: "/surveys/:id/responses/search", Summary: "Search the text answers of responses, with highlighted snippets", Tag: "Responses", Data: []ResponseSearchResult{}, Query: []apiParam{
+		{"q", "Words that must all appear; a trailing * searches by prefix"},
+		{"limit", "Page size, 1-200"},
+	}},
+	{Method: "GET", Path: "/surveys/:id/responses/export.ndjson", Summary: "Stream all responses as newline-delimited JSON, oldest first", Tag: "Responses", Query: append([]apiParam{recodeParam}, analyticsParams...), Headers: []apiParam{exportPasswordParam}, ContentType: "application/x-ndjson"},
+	{Method: "GET", Path: "/surveys/:id/responses/:response_id", Summary: "Get a response", Tag: "Responses", Data: SurveyResponse{}},
+	{Method: "PATCH", Path: "/surveys/:id/responses/:response_id", Summary: "Update a response within the edit window", Tag: "Responses", Request: UpdateResponseRequest{}, Data: SurveyResponse{}},
+	{Method: "DELETE", Path: "/surveys/:id/responses/:response_id", Summary: "Soft-delete a response", Tag: "Responses"},
+	{Method: "GET", Path: "/surveys/:id/responses/:response_id/neighbors", Summary: "Get the previous and next responses", Tag: "Responses", Query: responseListParams, Data: ResponseNeighbors{}},
+	{Method: "GET", Path: "/surveys/:id/responses/:response_id/revisions", Summary: "List previous versions of a response", Tag: "Responses", Data: []ResponseRevision{}},
+
+	{Method: "GET", Path: "/surveys/:id/questions/:question_id/answers", Summary: "Export the answers to one question as CSV, or JSON with format=json", Tag: "Responses", Data: []QuestionAnswer{}, Query: append([]apiParam{
+		{"format", "csv (default) or json"},
+		recodeParam,
+	}, analyticsParams...)},
+
+	{Method: "GET", Path: "/surveys/:id/review-queue", Summary: "List scans and flagged responses awaiting review, oldest first", Tag: "Admin", Admin: true, Data: []ReviewItem{}, Query: []apiParam{
+		{"reason", "low_confidence, bot_suspected, moderation_pending or validation_warning"},
+		{"limit", "Page size"},
+	}},
+	{Method: "POST", Path: "/surveys/:id/review-queue/:item_id/accept", Summary: "Accept a review item as it is", Tag: "Admin", Admin: true, Data: ReviewItem{}},
+	{Method: "POST", Path: "/surveys/:id/review-queue/:item_id/fix", Summary: "Correct a review item's answers and accept it", Tag: "Admin", Admin: true, Request: ReviewRequest{}, Data: ReviewItem{}},
+	{Method: "POST", Path: "/surveys/:id/review-queue/:item_id/reject", Summary: "Reject a review item", Tag: "Admin", Admin: true, Data: ReviewItem{}},
+
+	{Method: "POST", Path: "/surveys/:id/partial-responses", Summary: "Start autosaving a respondent's answers", Tag: "Responses", Data: PartialResponse{}, Status: http.StatusCreated},
+	{Method: "GET", Path: "/surveys/:id/partial-responses/:partial_id", Summary: "Get the autosaved answers", Tag: "Responses", Data: PartialResponse{}},
+	{Method: "PATCH", Path: "/surveys/:id/partial-responses/:partial_id", Summary: "Merge answers into a partial response", Tag: "Responses", Request: SavePartialResponseRequest{}, Data: PartialResponse{}},
+	{Method: "POST", Path: "/surveys/:id/partial-responses/:partial_id/resume-code", Summary: "Issue a short code for resuming on another device", Tag: "Responses", Data: ResumeCode{}, Status: http.StatusCreated},
+	{Method: "POST", Path: "/surveys/:id/partial-responses/resume", Summary: "Redeem a resume code for its partial response", Tag: "Responses", Request: RedeemResumeCodeRequest{}, Data: PartialResponse{}},
+	{Method: "GET", Path: "/surveys/:id/dropout", Summary: "Count where respondents abandon the survey", Tag: "Surveys", Data: DropoutReport{}},
+	{Method: "GET", Path: "/surveys/:id/quality", Summary: "Score the data quality of the responses", Tag: "Surveys", Query: analyticsParams, Data: QualityReport{}},
+	{Method: "POST", Path: "/surveys/:id/experience-events", Summary: "Report a render or render error of the survey form", Tag: "Surveys", Request: CreateExperienceEventRequest{}, Status: http.StatusCreated},
+	{Method: "GET", Path: "/surveys/:id/experience", Summary: "Get render error rates, median load time and device and browser breakdowns", Tag: "Surveys", Data: ExperienceMetrics{}, Query: []apiParam{
+		{"from", "RFC 3339 start of the period, a week ago by default"},
+		{"to", "RFC 3339 end of the period, now by default"},
+	}},
+	{Method: "POST", Path: "/client-errors", Summary: "Report a render or submit failure of a survey form", Tag: "Surveys", Request: CreateClientErrorRequest{}, Status: http.StatusCreated},
+
+	{Method: "GET", Path: "/surveys/:id/views", Summary: "List saved views", Tag: "Views", Data: []ResponseView{}},
+	{Method: "POST", Path: "/surveys/:id/views", Summary: "Save a view", Tag: "Views", Request: CreateViewRequest{}, Data: ResponseView{}, Status: http.StatusCreated},
+	{Method: "GET", Path: "/surveys/:id/views/:view_id", Summary: "Get a saved view", Tag: "Views", Data: ResponseView{}},
+	{Method: "DELETE", Path: "/surveys/:id/views/:view_id", Summary: "Delete a saved view", Tag: "Views"},
+	{Method: "GET", Path: "/surveys/:id/formulas", Summary: "List saved formulas", Tag: "Formulas", Data: []Formula{}},
+	{Method: "POST", Path: "/surveys/:id/formulas", Summary: "Save a formula", Tag: "Formulas", Request: CreateFormulaRequest{}, Data: Formula{}, Status: http.StatusCreated},
+	{Method: "GET", Path: "/surveys/:id/formulas/:formula_id", Summary: "Get a saved formula", Tag: "Formulas", Data: Formula{}},
+	{Method: "DELETE", Path: "/surveys/:id/formulas/:formula_id", Summary: "Delete a saved formula", Tag: "Formulas"},
+
+	{Method: "GET", Path: "/users/:user_identifier/responses", Summary: "List a user's responses", Tag: "Responses", Data: []UserResponse{}},
+	{Method: "GET", Path: "/users/:user_identifier/export", Summary: "Export every response of a user with survey context (data portability request)", Tag: "Admin", Admin: true, Data: UserDataExport{}, Query: []apiParam{
+		{"format", "json (default), or zip for an archive of the JSON and a CSV of the answers"},
+	}, Headers: []apiParam{exportPasswordParam}},
+	{Method: "DELETE", Path: "/users/:user_identifier/data", Summary: "Delete or anonymize every response of a user (data subject request)", Tag: "Admin", Admin: true, Data: UserDataReport{}, Query: []apiParam{
+		{"mode", "delete (default) or anonymize"},
+	}},
+
+	{Method: "GET", Path: "/admin/surveys/:id/impact", Summary: "List what deleting a survey would break or remove", Tag: "Admin", Admin: true, Data: SurveyImpact{}},
+	{Method: "DELETE", Path: "/admin/surveys/:id", Summary: "Delete a survey, blocked while in use unless cascading", Tag: "Admin", Admin: true, Data: SurveyImpact{}, Query: []apiParam{
+		{"mode", "block (default) refuses a survey in use with 409; cascade deletes everything referring to it"},
+	}},
+	{Method: "POST", Path: "/admin/surveys/:id/anonymize", Summary: "Anonymize a survey's responses", Tag: "Admin", Admin: true, Request: AnonymizeRequest{}, Data: AnonymizeReport{}},
+	{Method: "POST", Path: "/admin/surveys/:id/responses/:response_id/restore", Summary: "Restore a deleted response", Tag: "Admin", Admin: true, Data: SurveyResponse{}},
+	{Method: "GET", Path: "/admin/surveys/:id/responses/:response_id/thread", Summary: "Export a response with its revisions and history", Tag: "Admin", Admin: true,
+		Query: []apiParam{{"format", "json (default) or pdf"}}, Data: ResponseThread{}},
+	{Method: "GET", Path: "/admin/webhooks", Summary: "List webhook subscriptions", Tag: "Admin", Admin: true, Data: []WebhookSubscription{}},
+	{Method: "POST", Path: "/admin/webhooks", Summary: "Subscribe a URL to webhook events", Tag: "Admin", Admin: true, Request: CreateWebhookRequest{}, Data: WebhookSubscription{}, Status: http.StatusCreated},
+	{Method: "POST", Path: "/admin/hooks", Summary: "Subscribe a target URL to one event, per REST Hooks", Tag: "Admin", Admin: true, Request: SubscribeRESTHookRequest{}, Data: WebhookSubscription{}, Status: http.StatusCreated},
+	{Method: "GET", Path: "/admin/hooks/sample", Summary: "List recent items shaped like an event's REST hook deliveries", Tag: "Admin", Admin: true, Query: []apiParam{{"event", "Webhook event, such as response.created"}, {"survey_id", "Only items of this survey"}}, Data: []interface{}{}},
+	{Method: "DELETE", Path: "/admin/hooks/:webhook_id", Summary: "Unsubscribe a REST hook", Tag: "Admin", Admin: true},
+	{Method: "DELETE", Path: "/admin/webhooks/:webhook_id", Summary: "Delete a webhook subscription", Tag: "Admin", Admin: true},
+	{Method: "GET", Path: "/admin/jobs", Summary: "List background jobs, such as the dead letters", Tag: "Admin", Admin: true, Data: []Job{}, Query: []apiParam{
+		{"status", "Only jobs with this status: queued, running or dead"},
+		{"kind", "Only jobs of this kind, such as webhook.deliver"},
+		{"limit", "Number of jobs (default 50, at most 200)"},
+	}},
+	{Method: "GET", Path: "/admin/jobs/:job_id", Summary: "Get a background job with its payload and last error", Tag: "Admin", Admin: true, Data: Job{}},
+	{Method: "POST", Path: "/admin/jobs/:job_id/requeue", Summary: "Queue a dead job again with its attempts reset", Tag: "Admin", Admin: true, Data: Job{}},
+	{Method: "DELETE", Path: "/admin/jobs/:job_id", Summary: "Discard a background job", Tag: "Admin", Admin: true},
+	{Method: "GET", Path: "/admin/surveys/:id/tokens", Summary: "List a survey's embed tokens", Tag: "Admin", Admin: true, Data: []SurveyToken{}},
+	{Method: "POST", Path: "/admin/surveys/:id/tokens", Summary: "Issue a token that can only fetch and answer the survey", Tag: "Admin", Admin: true, Request: CreateSurveyTokenRequest{}, Data: SurveyToken{}, Status: http.StatusCreated},
+	{Method: "POST", Path: "/admin/surveys/:id/tokens/:token_id/rotate", Summary: "Replace a survey token's value", Tag: "Admin", Admin: true, Data: SurveyToken{}},
+	{Method: "DELETE", Path: "/admin/surveys/:id/tokens/:token_id", Summary: "Revoke a survey token", Tag: "Admin", Admin: true},
+	{Method: "GET", Path: "/admin/viewers", Summary: "List viewer accounts", Tag: "Admin", Admin: true, Data: []Viewer{}},
+	{Method: "POST", Path: "/admin/viewers", Summary: "Create a viewer whose analytics are scoped to its attributes", Tag: "Admin", Admin: true, Request: CreateViewerRequest{}, Data: Viewer{}, Status: http.StatusCreated},
+	{Method: "DELETE", Path: "/admin/viewers/:viewer_id", Summary: "Revoke a viewer account", Tag: "Admin", Admin: true},
+	{Method: "GET", Path: "/admin/creators", Summary: "List creator accounts", Tag: "Admin", Admin: true, Data: []Creator{}},
+	{Method: "POST", Path: "/admin/creators", Summary: "Create a creator account owning the surveys it creates", Tag: "Admin", Admin: true, Request: CreateCreatorRequest{}, Data: Creator{}, Status: http.StatusCreated},
+	{Method: "DELETE", Path: "/admin/creators/:creator_id", Summary: "Revoke a creator account", Tag: "Admin", Admin: true},
+	{Method: "GET", Path: "/admin/organizations", Summary: "List organizations", Tag: "Admin", Admin: true, Data: []Organization{}},
+	{Method: "POST", Path: "/admin/organizations", Summary: "Create an organization", Tag: "Admin", Admin: true, Request: CreateOrganizationRequest{}, Data: Organization{}, Status: http.StatusCreated},
+	{Method: "PUT", Path: "/admin/organizations/:org_id/domain", Summary: "Serve an organization's public forms on a custom domain", Tag: "Admin", Admin: true, Request: SaveDomainRequest{}, Data: Organization{}},
+	{Method: "DELETE", Path: "/admin/organizations/:org_id/domain", Summary: "Stop serving an organization's public forms on its custom domain", Tag: "Admin", Admin: true, Data: Organization{}},
+	{Method: "GET", Path: "/admin/organizations/:org_id/members", Summary: "List the creator accounts of an organization", Tag: "Admin", Admin: true, Data: []OrganizationMember{}},
+	{Method: "POST", Path: "/admin/organizations/:org_id/members", Summary: "Add a creator account to an organization", Tag: "Admin", Admin: true, Request: AddMemberRequest{}},
+	{Method: "DELETE", Path: "/admin/organizations/:org_id/members/:creator_id", Summary: "Remove a creator account from an organization", Tag: "Admin", Admin: true},
+	{Method: "GET", Path: "/admin/api-keys", Summary: "List API keys", Tag: "Admin", Admin: true, Data: []APIKey{}},
+	{Method: "POST", Path: "/admin/api-keys", Summary: "Create an API key limited to aggregate endpoints", Tag: "Admin", Admin: true, Request: CreateAPIKeyRequest{}, Data: APIKey{}, Status: http.StatusCreated},
+	{Method: "DELETE", Path: "/admin/api-keys/:key_id", Summary: "Revoke an API key", Tag: "Admin", Admin: true},
+	{Method: "GET", Path: "/admin/email-suppressions", Summary: "List the addresses summary emails aren't sent to", Tag: "Admin", Admin: true, Data: []EmailSuppression{}},
+	{Method: "POST", Path: "/admin/email-suppressions", Summary: "Stop summary emails to an address", Tag: "Admin", Admin: true, Request: CreateEmailSuppressionRequest{}, Data: EmailSuppression{}, Status: http.StatusCreated},
+	{Method: "DELETE", Path: "/admin/email-suppressions/:email", Summary: "Let summary emails reach an address again", Tag: "Admin", Admin: true},
+	{Method: "GET", Path: "/admin/org-units", Summary: "List the org hierarchy's units", Tag: "Admin", Admin: true, Data: []OrgUnit{}},
+	{Method: "POST", Path: "/admin/org-units", Summary: "Create an org unit, under a parent or as a root", Tag: "Admin", Admin: true, Request: CreateOrgUnitRequest{}, Data: OrgUnit{}, Status: http.StatusCreated},
+	{Method: "DELETE", Path: "/admin/org-units/:unit_id", Summary: "Delete an org unit without children", Tag: "Admin", Admin: true},
+	{Method: "POST", Path: "/admin/surveys/:id/scanned-responses", Summary: "Import a scanned paper response; low-confidence fields are queued for review", Tag: "Admin", Admin: true, Request: CreateScannedResponseRequest{}, Data: ScannedResponse{}, Status: http.StatusCreated},
+	{Method: "GET", Path: "/admin/scanned-responses", Summary: "List the scanned response review queue", Tag: "Admin", Admin: true, Data: []ScannedResponse{}, Query: []apiParam{
+		{"status", "needs_review (default), finalized or rejected"},
+		{"survey_id", "Only scans of this survey"},
+		{"limit", "Page size"},
+	}},
+	{Method: "POST", Path: "/admin/scanned-responses/:scan_id/finalize", Summary: "Finalize a reviewed scan into a survey response", Tag: "Admin", Admin: true, Request: ReviewRequest{}, Data: ScannedResponse{}},
+	{Method: "POST", Path: "/admin/scanned-responses/:scan_id/reject", Summary: "Reject an unreadable scan", Tag: "Admin", Admin: true, Data: ScannedResponse{}},
+	{Method: "GET", Path: "/admin/audit", Summary: "Query the audit log", Tag: "Admin", Admin: true, Data: []AuditEntry{}, Query: []apiParam{
+		{"entity_type", "survey, survey_response or response_view"},
+		{"entity_id", "Entity ID"},
+		{"action", "Action such as create, update or delete"},
+		{"actor", "admin, anonymous or scheduler"},
+		{"from", "RFC 3339 lower bound of created_at"},
+		{"to", "RFC 3339 exclusive upper bound of created_at"},
+		{"limit", "Page size, 1-200"},
+		{"cursor", "meta.next_cursor of the previous page"},
+	}},
+	{Method: "GET", Path: "/admin/client-errors", Summary: "List failures reported by survey forms, newest first", Tag: "Admin", Admin: true, Data: []ClientError{}, Query: append([]apiParam{
+		{"limit", "Page size, 1-200"},
+		{"cursor", "meta.next_cursor of the previous page"},
+	}, clientErrorParams...)},
+	{Method: "GET", Path: "/admin/client-errors/summary", Summary: "Group recent form failures by survey, kind and message", Tag: "Admin", Admin: true, Data: ClientErrorSummary{}, Query: clientErrorParams},
+	{Method: "GET", Path: "/admin/slow-queries", Summary: "Report this instance's slowest recent SQL statements by route", Tag: "Admin", Admin: true, Data: SlowQueryReport{}, Query: []apiParam{
+		{"limit", "Number of statements, 1-200 (default 20)"},
+	}},
+	{Method: "GET", Path: "/admin/response-data/stats", Summary: "Measure stored response data and the space compression saves", Tag: "Admin", Admin: true, Data: ResponseDataReport{}},
+	{Method: "POST", Path: "/admin/response-data/compress", Summary: "Compress the response data stored before compression was turned on", Tag: "Admin", Admin: true, Data: CompressionResult{}},
+	{Method: "GET", Path: "/admin/surveys/:id/export-jobs", Summary: "List a survey's scheduled exports", Tag: "Admin", Admin: true, Data: []ExportJob{}},
+	{Method: "POST", Path: "/admin/surveys/:id/export-jobs", Summary: "Export a survey's responses to S3 or SFTP every interval", Tag: "Admin", Admin: true, Request: CreateExportJobRequest{}, Data: ExportJob{}, Status: http.StatusCreated},
+	{Method: "GET", Path: "/admin/surveys/:id/export-jobs/:job_id", Summary: "Get a scheduled export with its status", Tag: "Admin", Admin: true, Data: ExportJob{}},
+	{Method: "GET", Path: "/admin/surveys/:id/export-jobs/:job_id/last-run", Summary: "Get the last run of a scheduled export", Tag: "Admin", Admin: true, Data: ExportRun{}},
+	{Method: "DELETE", Path: "/admin/surveys/:id/export-jobs/:job_id", Summary: "Stop a scheduled export", Tag: "Admin", Admin: true},
+}
+
+// openAPIPath converts a Gin route path to OpenAPI syntax, e.g. /surveys/:id to /surveys/{id}
+func openAPIPath(path string) string {
+	parts := strings.Split(path, "/")
+	for i, part := range parts {
+		if strings.HasPrefix(part, ":") {
+			parts[i] = "{" + part[1:] + "}"
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+// schemaBuilder derives JSON schemas from Go types, collecting named types as components
+type schemaBuilder struct {
+	components map[string]interface{}
+}
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+)
+
+// schema returns the schema of t, as a reference for named struct types
+func (b *schemaBuilder) schema(t reflect.Type) map[string]interface{} {
+	switch t {
+	case timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case rawMessageType:
+		return map[string]interface{}{}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		s := b.schema(t.Elem())
+		if _, isRef := s["$ref"]; isRef {
+			return s
+		}
+		s["nullable"] = true
+		return s
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": b.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": b.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return b.object(t)
+		}
+		if _, ok := b.components[t.Name()]; !ok {
+			b.components[t.Name()] = nil // placeholder for recursive types
+			b.components[t.Name()] = b.object(t)
+		}
+		return map[string]interface{}{"$ref": "#/components/schemas/" + t.Name()}
+	}
+	return map[string]interface{}{}
+}
+
+// object returns the schema of a struct's JSON fields; fields without omitempty are required
+func (b *schemaBuilder) object(t reflect.Type) map[string]interface{} {
+	properties := map[string]interface{}{}
+	var required []string
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if !field.IsExported() || tag == "-" {
+			continue
+		}
+		name, options, _ := strings.Cut(tag, ",")
+		if name == "" {
+			name = field.Name
+		}
+		properties[name] = b.schema(field.Type)
+		if !strings.Contains(options, "omitempty") {
+			required = append(required, name)
+		}
+	}
+
+	s := map[string]interface{}{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		s["required"] = required
+	}
+	return s
+}
+
+// buildOpenAPI assembles the OpenAPI 3 document from apiOperations
+func buildOpenAPI() map[string]interface{} {
+	b := &schemaBuilder{components: map[string]interface{}{}}
+	envelope := b.schema(reflect.TypeOf(APIResponse{}))
+
+	paths := map[string]map[string]interface{}{}
+	for _, op := range apiOperations {
+		path := "/api" + openAPIPath(op.Path)
+		if paths[path] == nil {
+			paths[path] = map[string]interface{}{}
+		}
+
+		var params []interface{}
+		for _, part := range strings.Split(op.Path, "/") {
+			if !strings.HasPrefix(part, ":") {
+				continue
+			}
+			paramType := "integer"
+			switch part {
+			case ":user_identifier", ":question_id", ":partial_id", ":item_id", ":global_id", ":email":
+				paramType = "string"
+			}
+			params = append(params, map[string]interface{}{
+				"name": part[1:], "in": "path", "required": true,
+				"schema": map[string]interface{}{"type": paramType},
+			})
+		}
+		for _, q := range op.Query {
+			params = append(params, map[string]interface{}{
+				"name": q.Name, "in": "query", "description": q.Description,
+				"schema": map[string]interface{}{"type": "string"},
+			})
+		}
+		for _, h := range op.Headers {
+			params = append(params, map[string]interface{}{
+				"name": h.Name, "in": "header", "description": h.Description,
+				"schema": map[string]interface{}{"type": "string"},
+			})
+		}
+
+		success := envelope
+		contentType := "application/json"
+		if op.ContentType != "" {
+			success = map[string]interface{}{"type": "object"}
+			contentType = op.ContentType
+		} else if op.Data != nil {
+			success = map[string]interface{}{"allOf": []interface{}{
+				envelope,
+				map[string]interface{}{
+					"type":       "object",
+					"properties": map[string]interface{}{"data": b.schema(reflect.TypeOf(op.Data))},
+				},
+			}}
+		}
+		status := op.Status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		errorResponse := map[string]interface{}{
+			"description": "Error",
+			"content":     map[string]interface{}{"application/json": map[string]interface{}{"schema": envelope}},
+		}
+
+		operation := map[string]interface{}{
+			"summary":     op.Summary,
+			"tags":        []string{op.Tag},
+			"operationId": strings.ToLower(op.Method) + strings.NewReplacer("/", "_", ":", "").Replace(op.Path),
+			"responses": map[string]interface{}{
+				strconv.Itoa(status): map[string]interface{}{
+					"description": http.StatusText(status),
+					"content":     map[string]interface{}{contentType: map[string]interface{}{"schema": success}},
+				},
+				"default": errorResponse,
+			},
+		}
+		if len(params) > 0 {
+			operation["parameters"] = params
+		}
+		if op.Request != nil {
+			operation["requestBody"] = map[string]interface{}{
+				"required": true,
+				"content": map[string]interface{}{"application/json": map[string]interface{}{
+					"schema": b.schema(reflect.TypeOf(op.Request)),
+				}},
+			}
+		}
+		if op.Admin {
+			operation["security"] = []interface{}{map[string]interface{}{"adminToken": []string{}}}
+		}
+		paths[path][strings.ToLower(op.Method)] = operation
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":   "Survey Form API",
+			"version": "1.0.0",
+		},
+		"paths": paths,
+		"components": map[string]interface{}{
+			"schemas": b.components,
+			"securitySchemes": map[string]interface{}{
+				"adminToken": map[string]interface{}{"type": "http", "scheme": "bearer"},
+			},
+		},
+	}
+}
+
+// openAPIDocument is built once, on first request
+var (
+	openAPIOnce     sync.Once
+	openAPIDocument []byte
+)
+
+// getOpenAPI serves the OpenAPI document
+func getOpenAPI(c *gin.Context) {
+	openAPIOnce.Do(func() {
+		openAPIDocument, _ = json.Marshal(buildOpenAPI())
+	})
+	c.Data(http.StatusOK, "application/json; charset=utf-8", openAPIDocument)
+}
+
+// swaggerUI renders the OpenAPI document with Swagger UI
+const swaggerUI = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>Survey Form API</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js"></script>
+  <script>SwaggerUIBundle({ url: "/api/openapi.json", dom_id: "#swagger-ui" });</script>
+</body>
+</html>`
+
+// getAPIDocs serves Swagger UI
+func getAPIDocs(c *gin.Context) {
+	c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(swaggerUI))
+}
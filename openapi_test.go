@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOpenAPIDocumentsAllRoutes(t *testing.T) {
+	setupTestDB()
+	defer testDB.Close()
+
+	router := setupTestRouter()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/openapi.json", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var doc struct {
+		OpenAPI    string                                `json:"openapi"`
+		Paths      map[string]map[string]json.RawMessage `json:"paths"`
+		Components struct {
+			Schemas map[string]json.RawMessage `json:"schemas"`
+		} `json:"components"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &doc))
+	assert.Equal(t, "3.0.3", doc.OpenAPI)
+
+	for _, route := range router.Routes() {
+		if !strings.HasPrefix(route.Path, "/api/") || route.Path == "/api/openapi.json" || route.Path == "/api/docs" {
+			continue
+		}
+		operations := doc.Paths[openAPIPath(route.Path)]
+		assert.Contains(t, operations, strings.ToLower(route.Method), "%s %s is not documented", route.Method, route.Path)
+	}
+
+	for _, name := range []string{"Survey", "SurveyResponse", "ResponseMetadata", "APIResponse"} {
+		assert.Contains(t, doc.Components.Schemas, name)
+	}
+}
+
+func TestOpenAPISchemas(t *testing.T) {
+	b := &schemaBuilder{components: map[string]interface{}{}}
+	assert.Equal(t, map[string]interface{}{"$ref": "#/components/schemas/Survey"}, b.schema(reflect.TypeOf(Survey{})))
+
+	survey := b.components["Survey"].(map[string]interface{})
+	properties := survey["properties"].(map[string]interface{})
+	assert.Equal(t, map[string]interface{}{"type": "string", "format": "date-time", "nullable": true}, properties["publish_at"])
+	assert.Equal(t, map[string]interface{}{"type": "integer"}, properties["id"])
+	assert.Contains(t, survey["required"], "title")
+	assert.NotContains(t, survey["required"], "publish_at")
+}
+
+func TestAPIDocs(t *testing.T) {
+	setupTestDB()
+	defer testDB.Close()
+
+	router := setupTestRouter()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/docs", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Type"), "text/html")
+	assert.Contains(t, w.Body.String(), "/api/openapi.json")
+}
@@ -0,0 +1,365 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// organizationHeader selects the organization a request acts in
+const organizationHeader = "X-Organization-ID"
+
+// Organization is a tenant: its surveys, and their responses, are only seen
+// by requests acting in it
+type Organization struct {
+	ID           int    `json:"id"`
+	Name         string `json:"name"`
+	MembersCount int    `json:"members_count"`
+	// CustomDomain serves the organization's public forms, e.g. forms.example.com
+	CustomDomain string    `json:"custom_domain,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// OrganizationMember is a creator account belonging to an organization
+type OrganizationMember struct {
+	CreatorID int       `json:"creator_id"`
+	Name      string    `json:"name"`
+	AddedAt   time.Time `json:"added_at"`
+}
+
+// CreateOrganizationRequest represents the request body for creating an organization
+type CreateOrganizationRequest struct {
+	Organization struct {
+		Name string `json:"name" binding:"required"`
+	} `json:"organization" binding:"required"`
+}
+
+// AddMemberRequest represents the request body for adding a creator to an organization
+type AddMemberRequest struct {
+	Member struct {
+		CreatorID int `json:"creator_id" binding:"required"`
+	} `json:"member" binding:"required"`
+}
+
+// organizationColumns lists the columns read by scanOrganization
+const organizationColumns = "o.id, o.name, o.created_at, (SELECT COUNT(*) FROM organization_members m WHERE m.organization_id = o.id), o.custom_domain"
+
+// scanOrganization scans a row selected with organizationColumns
+func scanOrganization(row rowScanner) (Organization, error) {
+	var org Organization
+	var domain sql.NullString
+	err := row.Scan(&org.ID, &org.Name, &org.CreatedAt, &org.MembersCount, &domain)
+	org.CustomDomain = domain.String
+	return org, err
+}
+
+// creatorOrganizations returns the IDs of the organizations a creator belongs to
+func creatorOrganizations(ctx context.Context, creatorID int) ([]int, error) {
+	rows, err := db.QueryContext(ctx, "SELECT organization_id FROM organization_members WHERE creator_id = ? ORDER BY organization_id", creatorID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// tenantScope resolves the organization a request acts in: the one named by
+// X-Organization-ID, or for creators belonging to a single organization that
+// one. Creators may only name their own organizations. Requests acting in an
+// organization can't reach the surveys of another; requests acting in none
+// keep seeing every survey by ID, as respondents need to.
+func tenantScope() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		orgID := 0
+		if value := c.GetHeader(organizationHeader); value != "" {
+			id, err := strconv.Atoi(value)
+			if err != nil {
+				abortWithError(c, errInvalidID("organization"))
+				return
+			}
+			orgID = id
+		}
+
+		if creatorID, ok := currentCreator(c); ok {
+			memberOf, err := creatorOrganizations(ctx, creatorID)
+			if err != nil {
+				abortWithError(c, errInternal("Failed to fetch organizations", err))
+				return
+			}
+			switch {
+			case orgID == 0 && len(memberOf) == 1:
+				orgID = memberOf[0]
+			case orgID == 0 && len(memberOf) > 1:
+				abortWithError(c, &APIError{
+					Status:  http.StatusBadRequest,
+					Code:    CodeOrganizationRequired,
+					Message: organizationHeader + " is required for creators of several organizations",
+				})
+				return
+			case orgID != 0 && !containsInt(memberOf, orgID):
+				abortWithError(c, &APIError{
+					Status:  http.StatusForbidden,
+					Code:    CodeOrganizationDenied,
+					Message: "Creator is not a member of this organization",
+				})
+				return
+			}
+		} else if orgID != 0 {
+			var exists bool
+			if err := db.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM organizations WHERE id = ?)", orgID).Scan(&exists); err != nil {
+				abortWithError(c, errInternal("Failed to fetch organization", err))
+				return
+			}
+			if !exists {
+				abortWithError(c, errNotFound(CodeOrganizationNotFound, "Organization not found"))
+				return
+			}
+		}
+		if orgID == 0 {
+			c.Next()
+			return
+		}
+		c.Set("org_id", orgID)
+
+		if surveyID, err := strconv.Atoi(c.Param("id")); err == nil && !checkSurveyOrganization(c, surveyID) {
+			return
+		}
+		c.Next()
+	}
+}
+
+// checkSurveyOrganization responds 404 for a survey of another organization
+// than the one the request acts in, as surveys of other organizations don't
+// exist for it, and reports whether the request may go on. Handlers reaching
+// surveys without an :id parameter, e.g. by global ID, check it themselves.
+func checkSurveyOrganization(c *gin.Context, surveyID int) bool {
+	orgID, ok := currentOrganization(c)
+	if !ok {
+		return true
+	}
+	var surveyOrg sql.NullInt64
+	err := db.QueryRowContext(c.Request.Context(), "SELECT org_id FROM surveys WHERE id = ?", surveyID).Scan(&surveyOrg)
+	if err != nil && err != sql.ErrNoRows {
+		abortWithError(c, errInternal("Failed to fetch survey", err))
+		return false
+	}
+	if err == nil && (!surveyOrg.Valid || int(surveyOrg.Int64) != orgID) {
+		abortWithError(c, errNotFound(CodeSurveyNotFound, "Survey not found"))
+		return false
+	}
+	return true
+}
+
+// currentOrganization returns the ID of the organization the request acts in, if any
+func currentOrganization(c *gin.Context) (int, bool) {
+	id, ok := c.Value("org_id").(int)
+	return id, ok
+}
+
+// containsInt reports whether ids holds id
+func containsInt(ids []int, id int) bool {
+	for _, v := range ids {
+		if v == id {
+			return true
+		}
+	}
+	return false
+}
+
+// organizationParam loads the organization named by the :org_id parameter
+func organizationParam(c *gin.Context) (Organization, bool) {
+	orgID, err := strconv.Atoi(c.Param("org_id"))
+	if err != nil {
+		abortWithError(c, errInvalidID("organization"))
+		return Organization{}, false
+	}
+	return findOrganization(c, orgID)
+}
+
+// findOrganization loads an organization, responding 404 when there is none
+func findOrganization(c *gin.Context, orgID int) (Organization, bool) {
+	org, err := scanOrganization(db.QueryRowContext(c.Request.Context(),
+		"SELECT "+organizationColumns+" FROM organizations o WHERE o.id = ?", orgID))
+	if err == sql.ErrNoRows {
+		abortWithError(c, errNotFound(CodeOrganizationNotFound, "Organization not found"))
+		return org, false
+	}
+	if err != nil {
+		abortWithError(c, errInternal("Failed to fetch organization", err))
+		return org, false
+	}
+	return org, true
+}
+
+// getOrganizations lists the organizations with their member counts
+func getOrganizations(c *gin.Context) {
+	rows, err := db.QueryContext(c.Request.Context(), "SELECT "+organizationColumns+" FROM organizations o ORDER BY o.id")
+	if err != nil {
+		abortWithError(c, errInternal("Failed to fetch organizations", err))
+		return
+	}
+	defer rows.Close()
+
+	orgs := []Organization{}
+	for rows.Next() {
+		org, err := scanOrganization(rows)
+		if err != nil {
+			abortWithError(c, errInternal("Failed to scan organization data", err))
+			return
+		}
+		orgs = append(orgs, org)
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Status: "success",
+		Data:   orgs,
+	})
+}
+
+// createOrganization creates an organization without members
+func createOrganization(c *gin.Context) {
+	var req CreateOrganizationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		abortWithError(c, errInvalidRequest(err))
+		return
+	}
+	if len(req.Organization.Name) > 100 {
+		abortWithError(c, errValidation("Failed to create organization", []string{"Name must be less than 100 characters"}))
+		return
+	}
+
+	result, err := db.ExecContext(c.Request.Context(),
+		"INSERT INTO organizations (name, created_at) VALUES (?, CURRENT_TIMESTAMP)", req.Organization.Name)
+	if err != nil {
+		abortWithError(c, errInternal("Failed to create organization", err))
+		return
+	}
+
+	id, _ := result.LastInsertId()
+	org, ok := findOrganization(c, int(id))
+	if !ok {
+		return
+	}
+	auditChange(c, "create", "organization", org.ID, nil, org)
+
+	c.JSON(http.StatusCreated, APIResponse{
+		Status:  "success",
+		Message: "Organization created successfully",
+		Data:    org,
+	})
+}
+
+// getOrganizationMembers lists the creator accounts of an organization
+func getOrganizationMembers(c *gin.Context) {
+	org, ok := organizationParam(c)
+	if !ok {
+		return
+	}
+
+	rows, err := db.QueryContext(c.Request.Context(), `
+		SELECT m.creator_id, cr.name, m.created_at
+		FROM organization_members m
+		JOIN creators cr ON cr.id = m.creator_id
+		WHERE m.organization_id = ?
+		ORDER BY m.creator_id
+	`, org.ID)
+	if err != nil {
+		abortWithError(c, errInternal("Failed to fetch members", err))
+		return
+	}
+	defer rows.Close()
+
+	members := []OrganizationMember{}
+	for rows.Next() {
+		var m OrganizationMember
+		if err := rows.Scan(&m.CreatorID, &m.Name, &m.AddedAt); err != nil {
+			abortWithError(c, errInternal("Failed to scan member data", err))
+			return
+		}
+		members = append(members, m)
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Status: "success",
+		Data:   members,
+	})
+}
+
+// addOrganizationMember adds a creator account to an organization; adding a
+// member twice is a no-op
+func addOrganizationMember(c *gin.Context) {
+	org, ok := organizationParam(c)
+	if !ok {
+		return
+	}
+	var req AddMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		abortWithError(c, errInvalidRequest(err))
+		return
+	}
+	cr, ok := findActiveCreator(c, req.Member.CreatorID)
+	if !ok {
+		return
+	}
+
+	result, err := db.ExecContext(c.Request.Context(), `
+		INSERT OR IGNORE INTO organization_members (organization_id, creator_id, created_at)
+		VALUES (?, ?, CURRENT_TIMESTAMP)
+	`, org.ID, cr.ID)
+	if err != nil {
+		abortWithError(c, errInternal("Failed to add member", err))
+		return
+	}
+	if n, _ := result.RowsAffected(); n > 0 {
+		auditChange(c, "update", "organization", org.ID, nil, map[string]int{"added_creator_id": cr.ID})
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Status:  "success",
+		Message: "Member added successfully",
+	})
+}
+
+// removeOrganizationMember removes a creator account from an organization;
+// the surveys it created stay in the organization
+func removeOrganizationMember(c *gin.Context) {
+	org, ok := organizationParam(c)
+	if !ok {
+		return
+	}
+	creatorID, err := strconv.Atoi(c.Param("creator_id"))
+	if err != nil {
+		abortWithError(c, errInvalidID("creator"))
+		return
+	}
+
+	result, err := db.ExecContext(c.Request.Context(),
+		"DELETE FROM organization_members WHERE organization_id = ? AND creator_id = ?", org.ID, creatorID)
+	if err != nil {
+		abortWithError(c, errInternal("Failed to remove member", err))
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		abortWithError(c, errNotFound(CodeCreatorNotFound, "Creator is not a member of this organization"))
+		return
+	}
+	auditChange(c, "update", "organization", org.ID, map[string]int{"removed_creator_id": creatorID}, nil)
+
+	c.JSON(http.StatusOK, APIResponse{
+		Status:  "success",
+		Message: "Member removed successfully",
+	})
+}
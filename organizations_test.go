@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOrganizations(t *testing.T) {
+	setupTestDB()
+	defer testDB.Close()
+	router := setupTestRouter()
+	testDB.Exec("INSERT INTO surveys (title, description) VALUES ('Public', 'd')")
+
+	send := func(token, org, method, url, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, url, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		if org != "" {
+			req.Header.Set(organizationHeader, org)
+		}
+		router.ServeHTTP(w, req)
+		return w
+	}
+	created := func(w *httptest.ResponseRecorder, v interface{}) {
+		assert.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+		json.Unmarshal(w.Body.Bytes(), &struct {
+			Data interface{} `json:"data"`
+		}{v})
+	}
+	titles := func(w *httptest.ResponseRecorder) []string {
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var response struct {
+			Data []Survey `json:"data"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		titles := []string{}
+		for _, s := range response.Data {
+			titles = append(titles, s.Title)
+		}
+		return titles
+	}
+
+	var acme, globex Organization
+	created(send(adminToken, "", "POST", "/api/admin/organizations", `{"organization":{"name":"Acme"}}`), &acme)
+	created(send(adminToken, "", "POST", "/api/admin/organizations", `{"organization":{"name":"Globex"}}`), &globex)
+	var alice, bob Creator
+	created(send(adminToken, "", "POST", "/api/admin/creators", `{"creator":{"name":"Alice"}}`), &alice)
+	created(send(adminToken, "", "POST", "/api/admin/creators", `{"creator":{"name":"Bob"}}`), &bob)
+
+	addMember := func(org Organization, cr Creator) {
+		w := send(adminToken, "", "POST", fmt.Sprintf("/api/admin/organizations/%d/members", org.ID), fmt.Sprintf(`{"member":{"creator_id":%d}}`, cr.ID))
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	}
+	addMember(acme, alice)
+	addMember(acme, bob)
+	addMember(globex, bob)
+
+	w := send(adminToken, "", "GET", fmt.Sprintf("/api/admin/organizations/%d/members", acme.ID), "")
+	assert.Contains(t, w.Body.String(), `"name":"Alice"`)
+	assert.Contains(t, w.Body.String(), `"name":"Bob"`)
+
+	// Alice belongs to Acme alone, so her surveys are Acme's
+	var survey Survey
+	created(send(alice.Token, "", "POST", "/api/surveys", `{"survey":{"title":"Acme roadmap","description":"d"}}`), &survey)
+	assert.Equal(t, acme.ID, *survey.OrganizationID)
+	acmeSurvey := fmt.Sprintf("/api/surveys/%d", survey.ID)
+
+	// Bob belongs to both and has to say which one he acts in
+	assert.Equal(t, http.StatusBadRequest, send(bob.Token, "", "GET", "/api/surveys", "").Code)
+	created(send(bob.Token, fmt.Sprint(globex.ID), "POST", "/api/surveys", `{"survey":{"title":"Globex pricing","description":"d","status":"draft"}}`), &survey)
+	globexSurvey := fmt.Sprintf("/api/surveys/%d", survey.ID)
+
+	assert.Equal(t, []string{"Acme roadmap"}, titles(send(bob.Token, fmt.Sprint(acme.ID), "GET", "/api/surveys?status=all", "")))
+	assert.Equal(t, []string{"Globex pricing"}, titles(send(bob.Token, fmt.Sprint(globex.ID), "GET", "/api/surveys?status=all", "")))
+	assert.Equal(t, []string{"Public"}, titles(send("", "", "GET", "/api/surveys", "")))
+	assert.Equal(t, []string{"Acme roadmap"}, titles(send(adminToken, fmt.Sprint(acme.ID), "GET", "/api/surveys", "")))
+
+	// Surveys and responses of other organizations can't be reached
+	assert.Equal(t, http.StatusOK, send(alice.Token, "", "GET", acmeSurvey, "").Code)
+	assert.Equal(t, http.StatusNotFound, send(alice.Token, "", "GET", globexSurvey, "").Code)
+	assert.Equal(t, http.StatusNotFound, send(alice.Token, "", "GET", globexSurvey+"/responses", "").Code)
+	assert.Equal(t, http.StatusForbidden, send(alice.Token, fmt.Sprint(globex.ID), "GET", "/api/surveys", "").Code)
+	assert.Equal(t, http.StatusNotFound, send("", "999", "GET", "/api/surveys", "").Code)
+
+	w = send("", "", "POST", acmeSurvey+"/responses", `{"survey_response":{"user_identifier":"user1","response_data":{}}}`)
+	assert.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	assert.Contains(t, send(alice.Token, "", "GET", "/api/users/user1/responses", "").Body.String(), "Acme roadmap")
+	assert.NotContains(t, send(bob.Token, fmt.Sprint(globex.ID), "GET", "/api/users/user1/responses", "").Body.String(), "Acme roadmap")
+
+	// Members removed lose access to the organization
+	w = send(adminToken, "", "DELETE", fmt.Sprintf("/api/admin/organizations/%d/members/%d", globex.ID, bob.ID), "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, []string{"Acme roadmap"}, titles(send(bob.Token, "", "GET", "/api/surveys", "")))
+	assert.Equal(t, http.StatusNotFound, send(adminToken, "", "DELETE", fmt.Sprintf("/api/admin/organizations/%d/members/%d", globex.ID, bob.ID), "").Code)
+}
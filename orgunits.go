@@ -0,0 +1,407 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// rollupMinN is the fewest responses an org unit needs for its rollup to be
+// shown; ?min_n= can raise it but not lower it
+var rollupMinN = envInt("ROLLUP_MIN_N", 5)
+
+// OrgUnit is a node of the organization hierarchy, e.g. a team within a
+// department within the company. Responses belong to the unit whose code they
+// answer to the question a rollup is requested for.
+type OrgUnit struct {
+	ID        int       `json:"id"`
+	ParentID  *int      `json:"parent_id"`
+	Name      string    `json:"name"`
+	Code      string    `json:"code"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// CreateOrgUnitRequest represents the request body for creating an org unit
+type CreateOrgUnitRequest struct {
+	OrgUnit struct {
+		Name     string `json:"name" binding:"required"`
+		Code     string `json:"code" binding:"required"`
+		ParentID *int   `json:"parent_id"`
+	} `json:"org_unit" binding:"required"`
+}
+
+// OrgUnitRollup aggregates the responses of an org unit and its descendants.
+// Suppressed units only show their place in the hierarchy.
+type OrgUnitRollup struct {
+	ID             int              `json:"id"`
+	Name           string           `json:"name"`
+	Code           string           `json:"code"`
+	Suppressed     bool             `json:"suppressed"`
+	ResponsesCount *int             `json:"responses_count"`
+	Questions      []QuestionResult `json:"questions,omitempty"`
+	Formulas       []FormulaResult  `json:"formulas,omitempty"`
+	Children       []OrgUnitRollup  `json:"children"`
+}
+
+// SurveyRollups holds the rollups of a survey's responses over the org hierarchy
+type SurveyRollups struct {
+	SurveyID int    `json:"survey_id"`
+	Question string `json:"question"`
+	MinN     int    `json:"min_n"`
+	// Unassigned counts the responses whose answer matches no org unit
+	Unassigned int             `json:"unassigned"`
+	Units      []OrgUnitRollup `json:"units"`
+	ComputedAt time.Time       `json:"computed_at"`
+}
+
+// orgUnitColumns lists the columns read by scanOrgUnit
+const orgUnitColumns = "id, parent_id, name, code, created_at"
+
+// scanOrgUnit scans a row selected with orgUnitColumns
+func scanOrgUnit(row rowScanner) (OrgUnit, error) {
+	var u OrgUnit
+	var parentID sql.NullInt64
+	err := row.Scan(&u.ID, &parentID, &u.Name, &u.Code, &u.CreatedAt)
+	if parentID.Valid {
+		id := int(parentID.Int64)
+		u.ParentID = &id
+	}
+	return u, err
+}
+
+// listOrgUnits loads the whole org hierarchy, parents before their children
+func listOrgUnits(ctx context.Context) ([]OrgUnit, error) {
+	rows, err := db.QueryContext(ctx, "SELECT "+orgUnitColumns+" FROM org_units ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	units := []OrgUnit{}
+	for rows.Next() {
+		u, err := scanOrgUnit(rows)
+		if err != nil {
+			return nil, err
+		}
+		units = append(units, u)
+	}
+	return units, rows.Err()
+}
+
+// findOrgUnit loads an org unit, responding 404 when there is none
+func findOrgUnit(c *gin.Context, unitID int) (OrgUnit, bool) {
+	u, err := scanOrgUnit(db.QueryRowContext(c.Request.Context(),
+		"SELECT "+orgUnitColumns+" FROM org_units WHERE id = ?", unitID))
+	if err == sql.ErrNoRows {
+		abortWithError(c, errNotFound(CodeOrgUnitNotFound, "Org unit not found"))
+		return u, false
+	}
+	if err != nil {
+		abortWithError(c, errInternal("Failed to fetch org unit", err))
+		return u, false
+	}
+	return u, true
+}
+
+// getOrgUnits lists the org units; parents come before their children
+func getOrgUnits(c *gin.Context) {
+	units, err := listOrgUnits(c.Request.Context())
+	if err != nil {
+		abortWithError(c, errInternal("Failed to fetch org units", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Status: "success",
+		Data:   units,
+	})
+}
+
+// createOrgUnit adds an org unit under an existing parent, or as a root
+func createOrgUnit(c *gin.Context) {
+	ctx := c.Request.Context()
+	var req CreateOrgUnitRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		abortWithError(c, errInvalidRequest(err))
+		return
+	}
+	unit := req.OrgUnit
+
+	// Validation
+	var errors []string
+	if len(unit.Name) > 100 {
+		errors = append(errors, "Name must be less than 100 characters")
+	}
+	if len(unit.Code) > 100 {
+		errors = append(errors, "Code must be less than 100 characters")
+	}
+	var taken bool
+	err := db.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM org_units WHERE code = ?)", unit.Code).Scan(&taken)
+	if err == nil && taken {
+		errors = append(errors, "Code has already been taken")
+	}
+	if unit.ParentID != nil {
+		var exists bool
+		err := db.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM org_units WHERE id = ?)", *unit.ParentID).Scan(&exists)
+		if err == nil && !exists {
+			errors = append(errors, "Parent org unit doesn't exist")
+		}
+	}
+	if len(errors) > 0 {
+		abortWithError(c, errValidation("Failed to create org unit", errors))
+		return
+	}
+
+	result, err := db.ExecContext(ctx, `
+		INSERT INTO org_units (parent_id, name, code, created_at)
+		VALUES (?, ?, ?, CURRENT_TIMESTAMP)
+	`, unit.ParentID, unit.Name, unit.Code)
+	if err != nil {
+		abortWithError(c, errInternal("Failed to create org unit", err))
+		return
+	}
+
+	id, _ := result.LastInsertId()
+	created, ok := findOrgUnit(c, int(id))
+	if !ok {
+		return
+	}
+	auditChange(c, "create", "org_unit", created.ID, nil, created)
+
+	c.JSON(http.StatusCreated, APIResponse{
+		Status:  "success",
+		Message: "Org unit created successfully",
+		Data:    created,
+	})
+}
+
+// deleteOrgUnit removes an org unit without children; units are deleted bottom-up
+func deleteOrgUnit(c *gin.Context) {
+	ctx := c.Request.Context()
+	unitID, err := strconv.Atoi(c.Param("unit_id"))
+	if err != nil {
+		abortWithError(c, errInvalidID("org unit"))
+		return
+	}
+	before, ok := findOrgUnit(c, unitID)
+	if !ok {
+		return
+	}
+
+	var hasChildren bool
+	if err := db.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM org_units WHERE parent_id = ?)", unitID).Scan(&hasChildren); err != nil {
+		abortWithError(c, errInternal("Failed to delete org unit", err))
+		return
+	}
+	if hasChildren {
+		abortWithError(c, &APIError{
+			Status:  http.StatusConflict,
+			Code:    CodeOrgUnitHasChildren,
+			Message: "Org unit has child units; delete them first",
+		})
+		return
+	}
+
+	if _, err := db.ExecContext(ctx, "DELETE FROM org_units WHERE id = ?", unitID); err != nil {
+		abortWithError(c, errInternal("Failed to delete org unit", err))
+		return
+	}
+	auditChange(c, "delete", "org_unit", unitID, before, nil)
+
+	c.JSON(http.StatusOK, APIResponse{
+		Status:  "success",
+		Message: "Org unit deleted successfully",
+	})
+}
+
+// rollupNode is an org unit with the tally of its subtree's responses
+type rollupNode struct {
+	unit       OrgUnit
+	tally      *resultsTally
+	children   []*rollupNode
+	suppressed bool
+}
+
+// suppress hides units with fewer than minN responses, and whole subtrees once
+// a unit is hidden. A shown unit's count minus those of its shown children must
+// not single out a small group either, so while that remainder is below minN
+// the smallest shown child is hidden too.
+func (n *rollupNode) suppress(minN int, hidden bool) {
+	n.suppressed = hidden || n.tally.responses < minN
+	if !n.suppressed {
+		for _, child := range n.children {
+			child.suppressed = child.tally.responses < minN
+		}
+		for {
+			remainder := n.tally.responses
+			var smallest *rollupNode
+			for _, child := range n.children {
+				if child.suppressed {
+					continue
+				}
+				remainder -= child.tally.responses
+				if smallest == nil || child.tally.responses < smallest.tally.responses {
+					smallest = child
+				}
+			}
+			if remainder == 0 || remainder >= minN || smallest == nil {
+				break
+			}
+			smallest.suppressed = true
+		}
+	}
+	for _, child := range n.children {
+		child.suppress(minN, n.suppressed || child.suppressed)
+	}
+}
+
+// rollup renders the node, evaluating the survey's formulas on shown units
+func (n *rollupNode) rollup(survey Survey, formulas []Formula) OrgUnitRollup {
+	r := OrgUnitRollup{ID: n.unit.ID, Name: n.unit.Name, Code: n.unit.Code, Suppressed: n.suppressed, Children: []OrgUnitRollup{}}
+	if !n.suppressed {
+		results := n.tally.results(survey.ID)
+		r.ResponsesCount = &results.ResponsesCount
+		r.Questions = results.Questions
+		if len(formulas) > 0 {
+			r.Formulas = evaluateFormulas(survey, results, formulas)
+		}
+	}
+	for _, child := range n.children {
+		r.Children = append(r.Children, child.rollup(survey, formulas))
+	}
+	return r
+}
+
+// computeRollups tallies the responses matching q for the org unit whose code
+// they answer to question and every unit above it
+func computeRollups(ctx context.Context, q responseListQuery, question string, units []OrgUnit) ([]*rollupNode, int, error) {
+	nodes := map[int]*rollupNode{}
+	byCode := map[string]*rollupNode{}
+	var roots []*rollupNode
+	for _, u := range units {
+		n := &rollupNode{unit: u, tally: newResultsTally()}
+		nodes[u.ID] = n
+		byCode[u.Code] = n
+		if u.ParentID == nil {
+			roots = append(roots, n)
+		}
+	}
+	// Parents are created first, so they precede their children
+	for _, u := range units {
+		if u.ParentID != nil {
+			if parent := nodes[*u.ParentID]; parent != nil {
+				parent.children = append(parent.children, nodes[u.ID])
+			}
+		}
+	}
+
+	unassigned := 0
+	err := analyzedResponses(ctx, q, func(answers map[string]interface{}) {
+		values := answerValues(answers[question])
+		var n *rollupNode
+		if len(values) == 1 {
+			n = byCode[values[0]]
+		}
+		if n == nil {
+			unassigned++
+			return
+		}
+		for n != nil {
+			n.tally.add(answers)
+			if n.unit.ParentID == nil {
+				break
+			}
+			n = nodes[*n.unit.ParentID]
+		}
+	})
+	return roots, unassigned, err
+}
+
+// getSurveyRollups returns the survey's results for every org unit, counting
+// the responses of its descendants. ?question= names the question answered
+// with unit codes; units with fewer than ?min_n= responses are suppressed.
+func getSurveyRollups(c *gin.Context) {
+	ctx := c.Request.Context()
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		abortWithError(c, errInvalidID("survey"))
+		return
+	}
+
+	survey, err := findSurvey(ctx, id)
+	if err != nil {
+		abortWithError(c, errNotFound(CodeSurveyNotFound, "Survey not found"))
+		return
+	}
+
+	q, errors := parseAnalyticsQuery(c, id)
+	question := c.Query("question")
+	if !questionIDPattern.MatchString(question) {
+		errors = append(errors, "Question must be the ID of the question answered with org unit codes")
+	} else if len(survey.Questions) > 0 {
+		known := false
+		for _, def := range survey.Questions {
+			known = known || def.ID == question
+		}
+		if !known {
+			errors = append(errors, "Question "+question+" doesn't exist")
+		}
+	}
+	minN := rollupMinN
+	if value := c.Query("min_n"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < rollupMinN {
+			errors = append(errors, fmt.Sprintf("Min_n must be a whole number of at least %d", rollupMinN))
+		}
+		minN = n
+	}
+	if len(errors) > 0 {
+		abortWithError(c, errInvalidQuery(errors))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, analyticsTimeout)
+	defer cancel()
+	if !checkAnalyticsCost(ctx, c, q) {
+		return
+	}
+
+	units, err := listOrgUnits(ctx)
+	if err != nil {
+		abortWithError(c, errInternal("Failed to fetch org units", err))
+		return
+	}
+	roots, unassigned, err := computeRollups(ctx, q, question, units)
+	if err != nil {
+		analyticsQueryFailed(ctx, c, err)
+		return
+	}
+	formulas, err := listFormulas(ctx, id)
+	if err != nil {
+		abortWithError(c, errInternal("Failed to fetch formulas", err))
+		return
+	}
+
+	rollups := SurveyRollups{
+		SurveyID:   id,
+		Question:   question,
+		MinN:       minN,
+		Unassigned: unassigned,
+		Units:      []OrgUnitRollup{},
+		ComputedAt: time.Now().UTC(),
+	}
+	for _, root := range roots {
+		root.suppress(minN, false)
+		rollups.Units = append(rollups.Units, root.rollup(survey, formulas))
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Status: "success",
+		Data:   rollups,
+	})
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSurveyRollups(t *testing.T) {
+	setupTestDB()
+	defer testDB.Close()
+	router := setupTestRouter()
+
+	createUnit := func(name, code string, parentID int) int {
+		body := fmt.Sprintf(`{"org_unit":{"name":%q,"code":%q}}`, name, code)
+		if parentID > 0 {
+			body = fmt.Sprintf(`{"org_unit":{"name":%q,"code":%q,"parent_id":%d}}`, name, code, parentID)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, adminRequest("POST", "/api/admin/org-units", []byte(body)))
+		assert.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+		var created struct {
+			Data OrgUnit `json:"data"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &created)
+		return created.Data.ID
+	}
+	company := createUnit("Acme", "acme", 0)
+	sales := createUnit("Sales", "sales", company)
+	createUnit("Sales East", "sales-east", sales)
+	west := createUnit("Sales West", "sales-west", sales)
+	support := createUnit("Support", "support", company)
+	createUnit("Support Tier 1", "support-1", support)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, adminRequest("POST", "/api/admin/org-units", []byte(`{"org_unit":{"name":"Again","code":"sales"}}`)))
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+	result, err := testDB.Exec("INSERT INTO surveys (title, description) VALUES ('Engagement', 'd')")
+	assert.NoError(t, err)
+	surveyID, _ := result.LastInsertId()
+	i := 0
+	for team, n := range map[string]int{"sales-east": 6, "sales-west": 2, "support-1": 5, "unknown": 1} {
+		for j := 0; j < n; j++ {
+			i++
+			testDB.Exec("INSERT INTO survey_responses (survey_id, user_identifier, response_data) VALUES (?, ?, ?)",
+				surveyID, fmt.Sprintf("employee%d", i), fmt.Sprintf(`{"team": %q, "rating": 4}`, team))
+		}
+	}
+	testDB.Exec("INSERT INTO survey_formulas (survey_id, name, expression) VALUES (?, 'Engagement', 'mean(rating)')", surveyID)
+
+	w = httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", fmt.Sprintf("/api/surveys/%d/rollups?question=team", surveyID), nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var response struct {
+		Data SurveyRollups `json:"data"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	rollups := response.Data
+	assert.Equal(t, 5, rollups.MinN)
+	assert.Equal(t, 1, rollups.Unassigned)
+	assert.Len(t, rollups.Units, 1)
+
+	acme := rollups.Units[0]
+	assert.Equal(t, 13, *acme.ResponsesCount)
+	assert.Equal(t, "Engagement", acme.Formulas[0].Name)
+	assert.Equal(t, 4.0, *acme.Formulas[0].Value)
+	assert.Len(t, acme.Children, 2)
+
+	// Sales West has too few responses; Sales East is hidden too, as Sales
+	// minus Sales East would reveal Sales West
+	salesRollup := acme.Children[0]
+	assert.Equal(t, 8, *salesRollup.ResponsesCount)
+	for _, team := range salesRollup.Children {
+		assert.True(t, team.Suppressed, team.Name)
+		assert.Nil(t, team.ResponsesCount)
+		assert.Empty(t, team.Questions)
+	}
+	supportRollup := acme.Children[1]
+	assert.False(t, supportRollup.Children[0].Suppressed)
+	assert.Equal(t, 5, *supportRollup.Children[0].ResponsesCount)
+
+	// Raising min_n hides more units; it can't be lowered
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", fmt.Sprintf("/api/surveys/%d/rollups?question=team&min_n=10", surveyID), nil)
+	router.ServeHTTP(w, req)
+	json.Unmarshal(w.Body.Bytes(), &response)
+	assert.False(t, response.Data.Units[0].Suppressed)
+	assert.True(t, response.Data.Units[0].Children[0].Suppressed)
+
+	for _, query := range []string{"question=team&min_n=2", "min_n=5"} {
+		w = httptest.NewRecorder()
+		req, _ = http.NewRequest("GET", fmt.Sprintf("/api/surveys/%d/rollups?%s", surveyID, query), nil)
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+	}
+
+	// Units are deleted bottom-up
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, adminRequest("DELETE", fmt.Sprintf("/api/admin/org-units/%d", sales), nil))
+	assert.Equal(t, http.StatusConflict, w.Code)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, adminRequest("DELETE", fmt.Sprintf("/api/admin/org-units/%d", west), nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+}
@@ -0,0 +1,370 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Page size limits for response listings
+const (
+	defaultPageSize = 50
+	maxPageSize     = 200
+)
+
+// cursorTimeFormat matches how CURRENT_TIMESTAMP values are stored
+const cursorTimeFormat = "2006-01-02 15:04:05"
+
+// PageMeta describes the current page of a keyset-paginated listing
+type PageMeta struct {
+	Limit      int    `json:"limit"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// ResponseNeighbors identifies the responses around a given response
+type ResponseNeighbors struct {
+	ID       int  `json:"id"`
+	Previous *int `json:"previous"`
+	Next     *int `json:"next"`
+	Position int  `json:"position"`
+	Total    int  `json:"total"`
+}
+
+// responseCursor is the position after which the next page starts
+type responseCursor struct {
+	Value string `json:"v"`
+	ID    int    `json:"id"`
+}
+
+// encode returns the opaque form of the cursor handed to clients
+func (rc responseCursor) encode() string {
+	raw, _ := json.Marshal(rc)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+// decodeResponseCursor parses a cursor produced by encode
+func decodeResponseCursor(s string) (responseCursor, error) {
+	var rc responseCursor
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return rc, err
+	}
+	err = json.Unmarshal(raw, &rc)
+	return rc, err
+}
+
+// responseListQuery holds the filters and ordering shared by the response
+// listing and neighbor navigation, so both walk responses in the same order
+type responseListQuery struct {
+	SurveyID int
+	Sort     string
+	Desc     bool
+	Limit    int
+	Cursor   *responseCursor
+
+	Metadata    []metadataFilter
+	BotScoreMin *float64
+	BotScoreMax *float64
+	Answers     []answerFilter
+
+	// QualityFlag keeps responses carrying the flag, ExcludeQuality drops those carrying any of these
+	QualityFlag    string
+	ExcludeQuality []string
+
+	// CreatedFrom and CreatedTo bound created_at, formatted with cursorTimeFormat
+	CreatedFrom string
+	CreatedTo   string
+
+	// IncludeDeleted keeps soft-deleted responses
+	IncludeDeleted bool
+}
+
+// validResponseSort reports whether responses can be ordered by the given column
+func validResponseSort(sort string) bool {
+	return sort == "updated_at" || sort == "created_at"
+}
+
+// parseResponseListQuery reads ?view=, ?sort=, ?order=, ?limit= and ?cursor= from the request
+func parseResponseListQuery(c *gin.Context, surveyID int) (responseListQuery, []string) {
+	ctx := c.Request.Context()
+	q := responseListQuery{
+		SurveyID: surveyID,
+		Desc:     true,
+		Limit:    defaultPageSize,
+	}
+	sort, order := "updated_at", "desc"
+
+	var errors []string
+	params := c.Request.URL.Query()
+
+	// A saved view provides the defaults, filters included; explicit
+	// parameters still win
+	if viewID := c.Query("view"); viewID != "" {
+		id, err := strconv.Atoi(viewID)
+		if err != nil {
+			errors = append(errors, "View is invalid")
+		} else if view, err := findResponseView(ctx, surveyID, id); err != nil {
+			errors = append(errors, "View not found")
+		} else {
+			sort, order = view.Sort, view.Order
+			filters, problems := viewFilterValues(view.Filters)
+			for _, problem := range problems {
+				errors = append(errors, "Saved view: "+problem)
+			}
+			for key, value := range filters {
+				if _, ok := params[key]; !ok {
+					params[key] = value
+				}
+			}
+		}
+	}
+
+	q.Sort = c.DefaultQuery("sort", sort)
+	if !validResponseSort(q.Sort) {
+		errors = append(errors, "Sort must be either updated_at or created_at")
+	}
+
+	switch c.DefaultQuery("order", order) {
+	case "desc":
+	case "asc":
+		q.Desc = false
+	default:
+		errors = append(errors, "Order must be either asc or desc")
+	}
+
+	if limit := c.Query("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 || n > maxPageSize {
+			errors = append(errors, fmt.Sprintf("Limit must be between 1 and %d", maxPageSize))
+		}
+		q.Limit = n
+	}
+
+	if cursor := c.Query("cursor"); cursor != "" {
+		rc, err := decodeResponseCursor(cursor)
+		if err != nil {
+			errors = append(errors, "Cursor is invalid")
+		}
+		q.Cursor = &rc
+	}
+
+	errors = append(errors, parseMetadataFilters(params, &q)...)
+	errors = append(errors, parseAnswerFilters(params, &q)...)
+
+	return q, errors
+}
+
+// where returns the filter conditions (without the keyset condition)
+func (q responseListQuery) where() (string, []interface{}) {
+	conditions := []string{"sr.survey_id = ?"}
+	args := []interface{}{q.SurveyID}
+
+	if !q.IncludeDeleted {
+		conditions = append(conditions, "sr.deleted_at IS NULL")
+	}
+
+	for _, filter := range q.Metadata {
+		conditions = append(conditions, "sr."+filter.Column+" = ?")
+		args = append(args, filter.Value)
+	}
+	if q.BotScoreMin != nil {
+		conditions = append(conditions, "sr.bot_score >= ?")
+		args = append(args, *q.BotScoreMin)
+	}
+	if q.BotScoreMax != nil {
+		conditions = append(conditions, "sr.bot_score <= ?")
+		args = append(args, *q.BotScoreMax)
+	}
+	if q.QualityFlag != "" {
+		condition, arg := qualityFlagCondition(q.QualityFlag)
+		conditions = append(conditions, condition)
+		args = append(args, arg)
+	}
+	for _, flag := range q.ExcludeQuality {
+		condition, arg := qualityFlagCondition(flag)
+		conditions = append(conditions, "NOT "+condition)
+		args = append(args, arg)
+	}
+	for _, filter := range q.Answers {
+		condition, filterArgs := filter.condition()
+		conditions = append(conditions, condition)
+		args = append(args, filterArgs...)
+	}
+	if q.CreatedFrom != "" {
+		conditions = append(conditions, "sr.created_at >= ?")
+		args = append(args, q.CreatedFrom)
+	}
+	if q.CreatedTo != "" {
+		conditions = append(conditions, "sr.created_at < ?")
+		args = append(args, q.CreatedTo)
+	}
+
+	return strings.Join(conditions, " AND "), args
+}
+
+// orderBy returns the ORDER BY clause, reversed when walking backwards
+func (q responseListQuery) orderBy(reverse bool) string {
+	dir := "DESC"
+	if q.Desc == reverse {
+		dir = "ASC"
+	}
+	return fmt.Sprintf("sr.%s %s, sr.id %s", q.Sort, dir, dir)
+}
+
+// after returns the comparison operator selecting rows that follow a position
+func (q responseListQuery) after(reverse bool) string {
+	if q.Desc != reverse {
+		return "<"
+	}
+	return ">"
+}
+
+// cursorFor returns the cursor positioned on the given response
+func (q responseListQuery) cursorFor(response SurveyResponse) responseCursor {
+	value := response.UpdatedAt
+	if q.Sort == "created_at" {
+		value = response.CreatedAt
+	}
+	return responseCursor{Value: value.UTC().Format(cursorTimeFormat), ID: response.ID}
+}
+
+// listResponses returns one page of responses and the cursor of the next page
+func listResponses(ctx context.Context, q responseListQuery) ([]SurveyResponse, string, error) {
+	where, args := q.where()
+	if q.Cursor != nil {
+		where += fmt.Sprintf(" AND (sr.%s, sr.id) %s (?, ?)", q.Sort, q.after(false))
+		args = append(args, q.Cursor.Value, q.Cursor.ID)
+	}
+	args = append(args, q.Limit+1)
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT `+responseColumns+`
+		FROM `+responsesFrom+`
+		WHERE `+where+`
+		ORDER BY `+q.orderBy(false)+`
+		LIMIT ?
+	`, args...)
+	if err != nil {
+		return nil, "", err
+	}
+	defer rows.Close()
+
+	var responses []SurveyResponse
+	for rows.Next() {
+		response, err := scanResponse(rows)
+		if err != nil {
+			return nil, "", err
+		}
+		responses = append(responses, response)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", err
+	}
+
+	var next string
+	if len(responses) > q.Limit {
+		responses = responses[:q.Limit]
+		next = q.cursorFor(responses[len(responses)-1]).encode()
+	}
+	return responses, next, nil
+}
+
+// findNeighbors locates the responses before and after responseID in the listing order
+func findNeighbors(ctx context.Context, q responseListQuery, responseID int) (ResponseNeighbors, error) {
+	neighbors := ResponseNeighbors{ID: responseID}
+	where, args := q.where()
+
+	// Ensure the response itself matches the current filter
+	var exists bool
+	err := db.QueryRowContext(ctx, `
+		SELECT EXISTS(SELECT 1 FROM survey_responses sr WHERE `+where+` AND sr.id = ?)
+	`, append(args, responseID)...).Scan(&exists)
+	if err != nil {
+		return neighbors, err
+	}
+	if !exists {
+		return neighbors, sql.ErrNoRows
+	}
+
+	// Position of the current row, compared as stored values
+	position := fmt.Sprintf(`(sr.%[1]s, sr.id) %%s (SELECT %[1]s, id FROM survey_responses WHERE id = ?)`, q.Sort)
+
+	neighbor := func(reverse bool) (*int, error) {
+		var id int
+		err := db.QueryRowContext(ctx, `
+			SELECT sr.id FROM survey_responses sr
+			WHERE `+where+` AND `+fmt.Sprintf(position, q.after(reverse))+`
+			ORDER BY `+q.orderBy(reverse)+`
+			LIMIT 1
+		`, append(append([]interface{}{}, args...), responseID)...).Scan(&id)
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		return &id, nil
+	}
+
+	if neighbors.Previous, err = neighbor(true); err != nil {
+		return neighbors, err
+	}
+	if neighbors.Next, err = neighbor(false); err != nil {
+		return neighbors, err
+	}
+
+	err = db.QueryRowContext(ctx, `
+		SELECT COUNT(*),
+		       COALESCE(SUM(CASE WHEN `+fmt.Sprintf(position, q.after(true))+` THEN 1 ELSE 0 END), 0) + 1
+		FROM survey_responses sr
+		WHERE `+where+`
+	`, append([]interface{}{responseID}, args...)...).Scan(&neighbors.Total, &neighbors.Position)
+	return neighbors, err
+}
+
+// getResponseNeighbors returns the previous and next responses under the current filter and ordering
+func getResponseNeighbors(c *gin.Context) {
+	ctx := c.Request.Context()
+	surveyID := c.Param("id")
+	responseID := c.Param("response_id")
+
+	sID, err := strconv.Atoi(surveyID)
+	if err != nil {
+		abortWithError(c, errInvalidID("survey"))
+		return
+	}
+
+	rID, err := strconv.Atoi(responseID)
+	if err != nil {
+		abortWithError(c, errInvalidID("response"))
+		return
+	}
+
+	q, errors := parseResponseListQuery(c, sID)
+	if len(errors) > 0 {
+		abortWithError(c, errInvalidQuery(errors))
+		return
+	}
+
+	neighbors, err := findNeighbors(ctx, q, rID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			abortWithError(c, errNotFound(CodeResponseNotFound, "Survey response not found"))
+			return
+		}
+		abortWithError(c, errInternal("Failed to fetch neighboring responses", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Status: "success",
+		Data:   neighbors,
+	})
+}
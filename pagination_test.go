@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// insertTestResponses creates a survey with n responses and returns their IDs in insertion order
+func insertTestResponses(t *testing.T, n int) (int64, []int) {
+	result, err := testDB.Exec("INSERT INTO surveys (title, description) VALUES (?, ?)", "Test Survey", "Test Description")
+	assert.NoError(t, err)
+	surveyID, _ := result.LastInsertId()
+
+	var ids []int
+	for i := 0; i < n; i++ {
+		result, err := testDB.Exec("INSERT INTO survey_responses (survey_id, user_identifier, response_data) VALUES (?, ?, ?)", surveyID, fmt.Sprintf("user%d", i), `{"rating": "5"}`)
+		assert.NoError(t, err)
+		id, _ := result.LastInsertId()
+		ids = append(ids, int(id))
+	}
+	return surveyID, ids
+}
+
+func TestGetSurveyResponsesKeysetPagination(t *testing.T) {
+	setupTestDB()
+	defer testDB.Close()
+
+	surveyID, ids := insertTestResponses(t, 5)
+	router := setupTestRouter()
+
+	var seen []int
+	cursor := ""
+	for page := 0; page < 5; page++ {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", fmt.Sprintf("/api/surveys/%d/responses?limit=2&cursor=%s", surveyID, cursor), nil)
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var response struct {
+			Data []TestSurveyResponse `json:"data"`
+			Meta PageMeta             `json:"meta"`
+		}
+		err := json.Unmarshal(w.Body.Bytes(), &response)
+		assert.NoError(t, err)
+		assert.LessOrEqual(t, len(response.Data), 2)
+
+		for _, r := range response.Data {
+			seen = append(seen, r.ID)
+		}
+		cursor = response.Meta.NextCursor
+		if cursor == "" {
+			break
+		}
+	}
+
+	// Same timestamps fall back to ID order, newest first
+	assert.Equal(t, []int{ids[4], ids[3], ids[2], ids[1], ids[0]}, seen)
+}
+
+func TestGetSurveyResponsesInvalidQuery(t *testing.T) {
+	setupTestDB()
+	defer testDB.Close()
+
+	surveyID, _ := insertTestResponses(t, 1)
+	router := setupTestRouter()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", fmt.Sprintf("/api/surveys/%d/responses?sort=rating&limit=0", surveyID), nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	var response TestAPIResponse
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Len(t, response.Errors, 2)
+}
+
+func TestGetResponseNeighbors(t *testing.T) {
+	setupTestDB()
+	defer testDB.Close()
+
+	surveyID, ids := insertTestResponses(t, 3)
+	router := setupTestRouter()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", fmt.Sprintf("/api/surveys/%d/responses/%d/neighbors?order=asc", surveyID, ids[1]), nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response struct {
+		Data ResponseNeighbors `json:"data"`
+	}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, ids[0], *response.Data.Previous)
+	assert.Equal(t, ids[2], *response.Data.Next)
+	assert.Equal(t, 2, response.Data.Position)
+	assert.Equal(t, 3, response.Data.Total)
+
+	// The first response has no previous neighbor
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", fmt.Sprintf("/api/surveys/%d/responses/%d/neighbors?order=asc", surveyID, ids[0]), nil)
+	router.ServeHTTP(w, req)
+
+	err = json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Nil(t, response.Data.Previous)
+	assert.Equal(t, 1, response.Data.Position)
+}
@@ -0,0 +1,459 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// partialIdleAfter is how long an unsubmitted partial response goes without
+// changes before dropout analytics count it as abandoned
+var partialIdleAfter = envDuration("PARTIAL_RESPONSE_IDLE", 30*time.Minute)
+
+// maxPartialSaveAttempts bounds the retries of a save racing another session
+const maxPartialSaveAttempts = 3
+
+// PartialResponse holds the answers saved so far while a respondent fills in a form.
+// Its ID is unguessable: it is the only credential of the respondent's session.
+type PartialResponse struct {
+	ID       string                     `json:"id"`
+	SurveyID int                        `json:"survey_id"`
+	Answers  map[string]json.RawMessage `json:"answers"`
+	// Version increases with every save; send it back to detect conflicting saves
+	Version int `json:"version"`
+	// ResponseID is set once the partial response has been submitted
+	ResponseID *int      `json:"response_id,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+
+	// answerVersions records the version in which each answer last changed
+	answerVersions map[string]int
+}
+
+// SavePartialResponseRequest represents the request body for saving answers.
+// A null answer removes it.
+type SavePartialResponseRequest struct {
+	PartialResponse struct {
+		Answers map[string]json.RawMessage `json:"answers" binding:"required"`
+		// Version is the version the client last saw; omit it to overwrite unconditionally
+		Version *int `json:"version"`
+	} `json:"partial_response" binding:"required"`
+}
+
+// DropoutQuestion counts how far respondents got through one question
+type DropoutQuestion struct {
+	ID string `json:"id"`
+	// Answered counts the partial responses that answered the question
+	Answered int `json:"answered"`
+	// DroppedOff counts abandoned partial responses whose first unanswered question this is
+	DroppedOff int `json:"dropped_off"`
+}
+
+// DropoutReport summarizes the progress of a survey's partial responses
+type DropoutReport struct {
+	SurveyID   int               `json:"survey_id"`
+	Started    int               `json:"started"`
+	Submitted  int               `json:"submitted"`
+	InProgress int               `json:"in_progress"`
+	Abandoned  int               `json:"abandoned"`
+	Questions  []DropoutQuestion `json:"questions"`
+}
+
+// partialColumns lists the columns read by scanPartialResponse
+const partialColumns = "id, survey_id, answers, answer_versions, version, response_id, created_at, updated_at"
+
+// scanPartialResponse scans a row selected with partialColumns
+func scanPartialResponse(row rowScanner) (PartialResponse, error) {
+	var p PartialResponse
+	var answers, versions []byte
+	var responseID sql.NullInt64
+	err := row.Scan(&p.ID, &p.SurveyID, &answers, &versions, &p.Version, &responseID, &p.CreatedAt, &p.UpdatedAt)
+	if err != nil {
+		return p, err
+	}
+	p.ResponseID = nullIntPtr(responseID)
+	if err := json.Unmarshal(answers, &p.Answers); err != nil {
+		return p, err
+	}
+	return p, json.Unmarshal(versions, &p.answerVersions)
+}
+
+// findPartialResponse loads a survey's partial response
+func findPartialResponse(ctx context.Context, surveyID int, id string) (PartialResponse, error) {
+	return scanPartialResponse(db.QueryRowContext(ctx, "SELECT "+partialColumns+" FROM partial_responses WHERE id = ? AND survey_id = ?", id, surveyID))
+}
+
+// conflicts returns the answers in a save based on version that another save
+// has changed since, to different values
+func (p PartialResponse) conflicts(answers map[string]json.RawMessage, version int) []string {
+	var conflicts []string
+	for questionID, answer := range answers {
+		if p.answerVersions[questionID] <= version {
+			continue
+		}
+		current, answered := p.Answers[questionID]
+		if isJSONNull(answer) && !answered {
+			continue
+		}
+		if answered && jsonEqual(current, answer) {
+			continue
+		}
+		conflicts = append(conflicts, questionID)
+	}
+	sort.Strings(conflicts)
+	return conflicts
+}
+
+// merge applies a save as a new version; null answers are removed
+func (p *PartialResponse) merge(answers map[string]json.RawMessage) {
+	p.Version++
+	for questionID, answer := range answers {
+		if isJSONNull(answer) {
+			delete(p.Answers, questionID)
+		} else {
+			p.Answers[questionID] = answer
+		}
+		p.answerVersions[questionID] = p.Version
+	}
+}
+
+// mergeResponseData overlays the answers of a submitted response_data object on
+// the autosaved answers
+func mergeResponseData(answers map[string]json.RawMessage, data json.RawMessage) (json.RawMessage, error) {
+	merged := map[string]json.RawMessage{}
+	for questionID, answer := range answers {
+		merged[questionID] = answer
+	}
+	if !isJSONNull(data) {
+		var submitted map[string]json.RawMessage
+		if err := json.Unmarshal(data, &submitted); err != nil {
+			return data, err
+		}
+		for questionID, answer := range submitted {
+			merged[questionID] = answer
+		}
+	}
+	return json.Marshal(merged)
+}
+
+// isJSONNull reports whether raw is the JSON null literal
+func isJSONNull(raw json.RawMessage) bool {
+	return len(raw) == 0 || string(raw) == "null"
+}
+
+// jsonEqual compares two JSON values, ignoring formatting
+func jsonEqual(a, b json.RawMessage) bool {
+	var va, vb interface{}
+	if json.Unmarshal(a, &va) != nil || json.Unmarshal(b, &vb) != nil {
+		return false
+	}
+	ja, _ := json.Marshal(va)
+	jb, _ := json.Marshal(vb)
+	return string(ja) == string(jb)
+}
+
+// validateAnswerKeys checks the question IDs of saved answers; surveys with
+// question definitions only accept answers to those questions
+func validateAnswerKeys(survey Survey, answers map[string]json.RawMessage) []string {
+	var errors []string
+	known := map[string]bool{}
+	for _, q := range survey.Questions {
+		known[q.ID] = true
+	}
+	for questionID := range answers {
+		switch {
+		case !questionIDPattern.MatchString(questionID):
+			errors = append(errors, fmt.Sprintf("Question ID %q must be 1-64 letters, digits, underscores or dashes", questionID))
+		case len(known) > 0 && !known[questionID]:
+			errors = append(errors, fmt.Sprintf("Question %s is not part of this survey", questionID))
+		}
+	}
+	sort.Strings(errors)
+	return errors
+}
+
+// partialResponseSurvey reads the survey of a partial response route and checks
+// that it accepts responses, responding with the error when it doesn't
+func partialResponseSurvey(c *gin.Context) (Survey, bool) {
+	ctx := c.Request.Context()
+	surveyID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		abortWithError(c, errInvalidID("survey"))
+		return Survey{}, false
+	}
+
+	survey, err := findSurvey(ctx, surveyID)
+	if err != nil {
+		abortWithError(c, errNotFound(CodeSurveyNotFound, "Survey not found"))
+		return survey, false
+	}
+	if message := survey.responseWindowError(time.Now()); message != "" {
+		abortWithError(c, &APIError{
+			Status:  http.StatusUnprocessableEntity,
+			Code:    CodeSurveyNotOpen,
+			Message: message,
+		})
+		return survey, false
+	}
+	return survey, true
+}
+
+// createPartialResponse starts a partial response for a respondent
+func createPartialResponse(c *gin.Context) {
+	ctx := c.Request.Context()
+	survey, ok := partialResponseSurvey(c)
+	if !ok {
+		return
+	}
+
+	id := randomHex(16)
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO partial_responses (id, survey_id, created_at, updated_at)
+		VALUES (?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+	`, id, survey.ID)
+	if err != nil {
+		abortWithError(c, errInternal("Failed to start partial response", err))
+		return
+	}
+
+	partial, err := findPartialResponse(ctx, survey.ID, id)
+	if err != nil {
+		abortWithError(c, errInternal("Failed to fetch partial response", err))
+		return
+	}
+
+	c.JSON(http.StatusCreated, APIResponse{
+		Status:  "success",
+		Message: "Partial response started",
+		Data:    partial,
+	})
+}
+
+// getPartialResponse returns the answers saved so far
+func getPartialResponse(c *gin.Context) {
+	ctx := c.Request.Context()
+	surveyID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		abortWithError(c, errInvalidID("survey"))
+		return
+	}
+
+	partial, err := findPartialResponse(ctx, surveyID, c.Param("partial_id"))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			abortWithError(c, errNotFound(CodePartialResponseNotFound, "Partial response not found"))
+			return
+		}
+		abortWithError(c, errInternal("Failed to fetch partial response", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Status: "success",
+		Data:   partial,
+	})
+}
+
+// savePartialResponse merges answers into a partial response. Saves based on an
+// older version still apply unless another save changed the same answers since,
+// in which case nothing is saved and the current state is returned with 409.
+func savePartialResponse(c *gin.Context) {
+	ctx := c.Request.Context()
+	survey, ok := partialResponseSurvey(c)
+	if !ok {
+		return
+	}
+
+	var req SavePartialResponseRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		abortWithError(c, errInvalidRequest(err))
+		return
+	}
+	answers := req.PartialResponse.Answers
+	if errors := validateAnswerKeys(survey, answers); len(errors) > 0 {
+		abortWithError(c, errValidation("Failed to save answers", errors))
+		return
+	}
+
+	// Saves race on the version: the update only applies to the version it was merged into
+	for attempt := 1; ; attempt++ {
+		partial, err := findPartialResponse(ctx, survey.ID, c.Param("partial_id"))
+		if err == sql.ErrNoRows {
+			abortWithError(c, errNotFound(CodePartialResponseNotFound, "Partial response not found"))
+			return
+		}
+		if err != nil {
+			abortWithError(c, errInternal("Failed to fetch partial response", err))
+			return
+		}
+		if partial.ResponseID != nil {
+			abortWithError(c, &APIError{
+				Status:  http.StatusConflict,
+				Code:    CodeAlreadySubmitted,
+				Message: "Partial response was already submitted",
+				Data:    partial,
+			})
+			return
+		}
+
+		if base := req.PartialResponse.Version; base != nil {
+			if conflicts := partial.conflicts(answers, *base); len(conflicts) > 0 {
+				var errors []string
+				for _, questionID := range conflicts {
+					errors = append(errors, fmt.Sprintf("Answer to %s was changed by another session", questionID))
+				}
+				abortWithError(c, &APIError{
+					Status:  http.StatusConflict,
+					Code:    CodeAnswerConflict,
+					Message: "Answers were changed since version " + strconv.Itoa(*base),
+					Errors:  errors,
+					Data:    partial,
+				})
+				return
+			}
+		}
+
+		previous := partial.Version
+		partial.merge(answers)
+		answersJSON, _ := json.Marshal(partial.Answers)
+		versionsJSON, _ := json.Marshal(partial.answerVersions)
+		result, err := db.ExecContext(ctx, `
+			UPDATE partial_responses
+			SET answers = ?, answer_versions = ?, version = ?, updated_at = CURRENT_TIMESTAMP
+			WHERE id = ? AND version = ? AND response_id IS NULL
+		`, string(answersJSON), string(versionsJSON), partial.Version, partial.ID, previous)
+		if err != nil {
+			abortWithError(c, errInternal("Failed to save answers", err))
+			return
+		}
+		if n, _ := result.RowsAffected(); n == 1 {
+			break
+		}
+		if attempt == maxPartialSaveAttempts {
+			abortWithError(c, &APIError{
+				Status:  http.StatusConflict,
+				Code:    CodeConcurrentSave,
+				Message: "Partial response is being saved concurrently, try again",
+			})
+			return
+		}
+	}
+
+	partial, err := findPartialResponse(ctx, survey.ID, c.Param("partial_id"))
+	if err != nil {
+		abortWithError(c, errInternal("Failed to fetch partial response", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Status:  "success",
+		Message: "Answers saved",
+		Data:    partial,
+	})
+}
+
+// dropoutReport summarizes the partial responses of a survey. Questions follow
+// the survey's question order; surveys without definitions list the answered
+// question IDs alphabetically.
+func dropoutReport(ctx context.Context, survey Survey, now time.Time) (DropoutReport, error) {
+	report := DropoutReport{SurveyID: survey.ID, Questions: []DropoutQuestion{}}
+
+	rows, err := db.QueryContext(ctx, "SELECT "+partialColumns+" FROM partial_responses WHERE survey_id = ?", survey.ID)
+	if err != nil {
+		return report, err
+	}
+	defer rows.Close()
+
+	var partials []PartialResponse
+	for rows.Next() {
+		partial, err := scanPartialResponse(rows)
+		if err != nil {
+			return report, err
+		}
+		partials = append(partials, partial)
+	}
+	if err := rows.Err(); err != nil {
+		return report, err
+	}
+
+	var order []string
+	for _, q := range survey.Questions {
+		order = append(order, q.ID)
+	}
+	if len(order) == 0 {
+		seen := map[string]bool{}
+		for _, partial := range partials {
+			for questionID := range partial.Answers {
+				if !seen[questionID] {
+					seen[questionID] = true
+					order = append(order, questionID)
+				}
+			}
+		}
+		sort.Strings(order)
+	}
+	index := map[string]int{}
+	for i, questionID := range order {
+		index[questionID] = i
+		report.Questions = append(report.Questions, DropoutQuestion{ID: questionID})
+	}
+
+	for _, partial := range partials {
+		report.Started++
+		for questionID := range partial.Answers {
+			if i, ok := index[questionID]; ok {
+				report.Questions[i].Answered++
+			}
+		}
+
+		switch {
+		case partial.ResponseID != nil:
+			report.Submitted++
+		case now.Sub(partial.UpdatedAt) < partialIdleAfter:
+			report.InProgress++
+		default:
+			report.Abandoned++
+			for i, questionID := range order {
+				if _, answered := partial.Answers[questionID]; !answered {
+					report.Questions[i].DroppedOff++
+					break
+				}
+			}
+		}
+	}
+	return report, nil
+}
+
+// getDropoutReport returns how far respondents get through a survey before giving up
+func getDropoutReport(c *gin.Context) {
+	ctx := c.Request.Context()
+	surveyID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		abortWithError(c, errInvalidID("survey"))
+		return
+	}
+
+	survey, err := findSurvey(ctx, surveyID)
+	if err != nil {
+		abortWithError(c, errNotFound(CodeSurveyNotFound, "Survey not found"))
+		return
+	}
+
+	report, err := dropoutReport(ctx, survey, time.Now())
+	if err != nil {
+		abortWithError(c, errInternal("Failed to compute dropout report", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Status: "success",
+		Data:   report,
+	})
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// partialRequest sends a JSON request to the partial response routes and decodes the partial response
+func partialRequest(router http.Handler, method, url, body string) (PartialResponse, *httptest.ResponseRecorder) {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(method, url, bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.RemoteAddr = "192.0.2.1:1234"
+	router.ServeHTTP(w, req)
+
+	var response struct {
+		Data PartialResponse `json:"data"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	return response.Data, w
+}
+
+func TestPartialResponseAutosave(t *testing.T) {
+	setupTestDB()
+	defer testDB.Close()
+
+	router := setupTestRouter()
+
+	_, w := partialRequest(router, "POST", "/api/surveys", `{"survey":{"title":"Autosave","description":"d","questions":[
+		{"id":"rating","type":"rating","label":"Rating"},
+		{"id":"comment","type":"text","label":"Comment"}
+	]}}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var survey struct {
+		Data Survey `json:"data"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &survey)
+	surveyID := survey.Data.ID
+
+	partial, w := partialRequest(router, "POST", fmt.Sprintf("/api/surveys/%d/partial-responses", surveyID), "")
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Len(t, partial.ID, 32)
+	assert.Equal(t, 0, partial.Version)
+	url := fmt.Sprintf("/api/surveys/%d/partial-responses/%s", surveyID, partial.ID)
+
+	// Answers are merged one by one
+	partial, w = partialRequest(router, "PATCH", url, `{"partial_response":{"answers":{"rating":4},"version":0}}`)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, 1, partial.Version)
+	partial, _ = partialRequest(router, "PATCH", url, `{"partial_response":{"answers":{"comment":"Good"},"version":1}}`)
+	assert.Equal(t, 2, partial.Version)
+	assert.JSONEq(t, `4`, string(partial.Answers["rating"]))
+
+	// A stale save still applies when it touches other answers
+	partial, w = partialRequest(router, "PATCH", url, `{"partial_response":{"answers":{"rating":5},"version":1}}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, 3, partial.Version)
+
+	// but not when another session changed the same answer since
+	partial, w = partialRequest(router, "PATCH", url, `{"partial_response":{"answers":{"comment":"Bad"},"version":1}}`)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Contains(t, w.Body.String(), "Answer to comment was changed by another session")
+	assert.JSONEq(t, `"Good"`, string(partial.Answers["comment"]))
+
+	// Null removes an answer; unknown questions are rejected
+	partial, _ = partialRequest(router, "PATCH", url, `{"partial_response":{"answers":{"comment":null}}}`)
+	assert.NotContains(t, partial.Answers, "comment")
+	_, w = partialRequest(router, "PATCH", url, `{"partial_response":{"answers":{"color":"red"}}}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+	partial, w = partialRequest(router, "GET", url, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, 4, partial.Version)
+
+	// Submitting uses the saved answers, overridden by the submitted ones
+	submit := fmt.Sprintf(`{"survey_response":{"user_identifier":"user1","partial_response_id":"%s","response_data":{"comment":"Done"}}}`, partial.ID)
+	_, w = partialRequest(router, "POST", fmt.Sprintf("/api/surveys/%d/responses", surveyID), submit)
+	assert.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var created struct {
+		Data SurveyResponse `json:"data"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &created)
+	assert.JSONEq(t, `{"rating":5,"comment":"Done"}`, string(created.Data.ResponseData))
+
+	partial, _ = partialRequest(router, "GET", url, "")
+	if assert.NotNil(t, partial.ResponseID) {
+		assert.Equal(t, created.Data.ID, *partial.ResponseID)
+	}
+	_, w = partialRequest(router, "PATCH", url, `{"partial_response":{"answers":{"rating":1}}}`)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	_, w = partialRequest(router, "POST", fmt.Sprintf("/api/surveys/%d/responses", surveyID), submit)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+	_, w = partialRequest(router, "GET", fmt.Sprintf("/api/surveys/%d/partial-responses/unknown", surveyID), "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestDropoutReport(t *testing.T) {
+	setupTestDB()
+	defer testDB.Close()
+
+	db = testDB
+	result, err := testDB.Exec(`INSERT INTO surveys (title, description, questions) VALUES ('Dropout', 'd', '[{"id":"q1","type":"text","label":"1"},{"id":"q2","type":"text","label":"2"},{"id":"q3","type":"text","label":"3"}]')`)
+	assert.NoError(t, err)
+	surveyID, _ := result.LastInsertId()
+
+	idle := time.Now().Add(-2 * partialIdleAfter).UTC()
+	for i, p := range []struct {
+		answers    string
+		updatedAt  time.Time
+		responseID interface{}
+	}{
+		{`{"q1":"a","q2":"b","q3":"c"}`, idle, 1},
+		{`{"q1":"a"}`, idle, nil},
+		{`{"q1":"a","q3":"c"}`, idle, nil},
+		{`{}`, idle, nil},
+		{`{"q1":"a"}`, time.Now().UTC(), nil},
+	} {
+		_, err := testDB.Exec("INSERT INTO partial_responses (id, survey_id, answers, response_id, updated_at) VALUES (?, ?, ?, ?, ?)",
+			fmt.Sprintf("p%d", i), surveyID, p.answers, p.responseID, p.updatedAt)
+		assert.NoError(t, err)
+	}
+
+	survey, err := findSurvey(context.Background(), int(surveyID))
+	assert.NoError(t, err)
+	report, err := dropoutReport(context.Background(), survey, time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, DropoutReport{
+		SurveyID:   int(surveyID),
+		Started:    5,
+		Submitted:  1,
+		InProgress: 1,
+		Abandoned:  3,
+		Questions: []DropoutQuestion{
+			{ID: "q1", Answered: 4, DroppedOff: 1},
+			{ID: "q2", Answered: 1, DroppedOff: 2},
+			{ID: "q3", Answered: 2, DroppedOff: 0},
+		},
+	}, report)
+}
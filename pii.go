@@ -0,0 +1,186 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// piiMask replaces the hidden part of masked answers
+const piiMask = "***"
+
+// SavePIIRequest represents the request body for marking a question as PII
+type SavePIIRequest struct {
+	PII *bool `json:"pii" binding:"required"`
+}
+
+// maskValue hides most of a text answer: emails keep the first character and
+// the domain (j***@example.com), other text its first character
+func maskValue(value string) string {
+	runes := []rune(value)
+	if len(runes) < 2 {
+		return piiMask
+	}
+	if at := strings.LastIndex(value, "@"); at > 0 {
+		return string([]rune(value[:at])[:1]) + piiMask + value[at:]
+	}
+	return string(runes[:1]) + piiMask
+}
+
+// maskAnswer masks an answer: text with maskValue, lists item by item and
+// anything else, numbers included, entirely
+func maskAnswer(answer interface{}) interface{} {
+	switch v := answer.(type) {
+	case nil:
+		return nil
+	case string:
+		return maskValue(v)
+	case []interface{}:
+		masked := make([]interface{}, len(v))
+		for i, item := range v {
+			masked[i] = maskAnswer(item)
+		}
+		return masked
+	}
+	return piiMask
+}
+
+// maskRawAnswer masks a JSON-encoded answer
+func maskRawAnswer(answer json.RawMessage) json.RawMessage {
+	var v interface{}
+	if json.Unmarshal(answer, &v) != nil {
+		return answer
+	}
+	masked, _ := json.Marshal(maskAnswer(v))
+	return masked
+}
+
+// maskResponseData masks the answers of response_data to the given questions
+func maskResponseData(data json.RawMessage, pii map[string]bool) json.RawMessage {
+	var answers map[string]json.RawMessage
+	if len(pii) == 0 || json.Unmarshal(data, &answers) != nil {
+		return data
+	}
+	for id, answer := range answers {
+		if pii[id] {
+			answers[id] = maskRawAnswer(answer)
+		}
+	}
+	masked, err := json.Marshal(answers)
+	if err != nil {
+		return data
+	}
+	return masked
+}
+
+// piiQuestions returns the IDs of the survey's questions marked as PII
+func piiQuestions(survey Survey) map[string]bool {
+	pii := map[string]bool{}
+	for _, q := range survey.Questions {
+		if q.PII {
+			pii[q.ID] = true
+		}
+	}
+	return pii
+}
+
+// piiMaskFor returns the PII questions whose answers are masked for the caller:
+// all of them, except for admins, whose unmasked reads are audited
+func piiMaskFor(c *gin.Context, survey Survey) map[string]bool {
+	pii := piiQuestions(survey)
+	if len(pii) == 0 {
+		return nil
+	}
+	if !isAdmin(c) {
+		return pii
+	}
+
+	ids := make([]string, 0, len(pii))
+	for id := range pii {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	if err := recordAudit(c.Request.Context(), db, "admin", "unmask", "survey", survey.ID, map[string]interface{}{
+		"route":     c.FullPath(),
+		"questions": ids,
+	}); err != nil {
+		log.Printf("Failed to audit unmask of survey %d: %v", survey.ID, err)
+	}
+	return nil
+}
+
+// savePII marks a question as PII, or unmarks it. Masking only shapes reads,
+// so it can be changed on published surveys too; admins only, as unmarking
+// reveals the answers.
+func savePII(c *gin.Context) {
+	ctx := c.Request.Context()
+	surveyID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		abortWithError(c, errInvalidID("survey"))
+		return
+	}
+	var req SavePIIRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		abortWithError(c, errInvalidRequest(err))
+		return
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		abortWithError(c, errInternal("Failed to save PII marking", err))
+		return
+	}
+	defer tx.Rollback()
+
+	before, err := querySurvey(ctx, tx, surveyID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			abortWithError(c, errNotFound(CodeSurveyNotFound, "Survey not found"))
+			return
+		}
+		abortWithError(c, errInternal("Failed to fetch survey", err))
+		return
+	}
+	questions := append([]Question{}, before.Questions...)
+	found := false
+	for i := range questions {
+		if questions[i].ID == c.Param("question_id") {
+			questions[i].PII = *req.PII
+			found = true
+		}
+	}
+	if !found {
+		abortWithError(c, errNotFound(CodeQuestionNotFound, "Question not found"))
+		return
+	}
+
+	data, _ := json.Marshal(questions)
+	if _, err := tx.ExecContext(ctx, "UPDATE surveys SET questions = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?", string(data), surveyID); err != nil {
+		abortWithError(c, errInternal("Failed to save PII marking", err))
+		return
+	}
+	survey, err := querySurvey(ctx, tx, surveyID)
+	if err != nil {
+		abortWithError(c, errInternal("Failed to fetch updated survey", err))
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		abortWithError(c, errInternal("Failed to save PII marking", err))
+		return
+	}
+
+	auditChange(c, "update", "survey", survey.ID, before, survey)
+	emitEvent(EventSurveyUpdated, survey.ID, survey)
+
+	c.JSON(http.StatusOK, APIResponse{
+		Status:  "success",
+		Message: "PII marking saved successfully",
+		Data:    survey,
+	})
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMaskAnswer(t *testing.T) {
+	assert.Equal(t, "j***@example.com", maskAnswer("jane.doe@example.com"))
+	assert.Equal(t, "J***", maskAnswer("Jane Doe"))
+	assert.Equal(t, "***", maskAnswer("J"))
+	assert.Equal(t, "***", maskAnswer(float64(5551234)))
+	assert.Equal(t, []interface{}{"a***", "***"}, maskAnswer([]interface{}{"abc", true}))
+	assert.Nil(t, maskAnswer(nil))
+
+	data := maskResponseData(json.RawMessage(`{"email":"jo@example.com","rating":5}`), map[string]bool{"email": true})
+	assert.JSONEq(t, `{"email":"j***@example.com","rating":5}`, string(data))
+}
+
+func TestSurveyPII(t *testing.T) {
+	setupTestDB()
+	defer testDB.Close()
+	router := setupTestRouter()
+
+	result, err := testDB.Exec(`INSERT INTO surveys (title, description, questions) VALUES ('Contact', 'd',
+		'[{"id":"email","type":"text","label":"Your email"},{"id":"rating","type":"rating","label":"How was it?"}]')`)
+	assert.NoError(t, err)
+	surveyID, _ := result.LastInsertId()
+	result, _ = testDB.Exec("INSERT INTO survey_responses (survey_id, user_identifier, response_data) VALUES (?, 'user1', ?)",
+		surveyID, `{"email": "jane@example.com", "rating": 4}`)
+	responseID, _ := result.LastInsertId()
+	base := fmt.Sprintf("/api/surveys/%d", surveyID)
+
+	get := func(req *http.Request) string {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		return w.Body.String()
+	}
+	public := func(url string) *http.Request {
+		req, _ := http.NewRequest("GET", url, nil)
+		return req
+	}
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("PUT", base+"/questions/email/pii", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, adminRequest("PUT", base+"/questions/email/pii", []byte(`{"pii": true}`)))
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, adminRequest("PUT", base+"/questions/missing/pii", []byte(`{"pii": true}`)))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	for _, url := range []string{
+		base + "/responses",
+		fmt.Sprintf("%s/responses/%d", base, responseID),
+		base + "/responses/export.ndjson",
+		base + "/questions/email/answers?format=json",
+		"/api/users/user1/responses",
+	} {
+		body := get(public(url))
+		assert.Contains(t, body, "j***@example.com", url)
+		assert.NotContains(t, body, "jane@", url)
+	}
+	// Answers to other questions are left alone
+	assert.Contains(t, get(public(base+"/responses")), `"rating":4`)
+
+	var audited int
+	testDB.QueryRow("SELECT COUNT(*) FROM audit_log WHERE action = 'unmask'").Scan(&audited)
+	assert.Equal(t, 0, audited)
+
+	assert.Contains(t, get(adminRequest("GET", base+"/responses", nil)), "jane@example.com")
+	var details string
+	testDB.QueryRow("SELECT details FROM audit_log WHERE action = 'unmask' AND entity_id = ?", surveyID).Scan(&details)
+	assert.Contains(t, details, `"email"`)
+	assert.Contains(t, details, "/api/surveys/:id/responses")
+}
@@ -0,0 +1,361 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Data quality flags of a response
+const (
+	// QualityStraightLining marks identical answers to every rating question
+	QualityStraightLining = "straight_lining"
+	// QualitySpeeder marks a response completed much faster than is typical
+	QualitySpeeder = "speeder"
+	// QualityHighMissingness marks a response leaving most questions unanswered
+	QualityHighMissingness = "high_missingness"
+	// QualityDuplicate marks a response with the same answers as another one
+	QualityDuplicate = "duplicate_suspect"
+)
+
+// qualityFlagNames lists the quality flags in the order they are reported
+var qualityFlagNames = []string{QualityStraightLining, QualitySpeeder, QualityHighMissingness, QualityDuplicate}
+
+// Thresholds of the quality checks
+var (
+	// qualitySpeederRatio flags responses completed in less than this share of the median time
+	qualitySpeederRatio = envRatio("QUALITY_SPEEDER_RATIO", 0.33)
+	// qualitySpeederMinSample is how many timed responses the median needs before speeders are flagged
+	qualitySpeederMinSample = envInt("QUALITY_SPEEDER_MIN_SAMPLE", 10)
+	// qualityMissingRatio flags responses leaving more than this share of questions unanswered
+	qualityMissingRatio = envRatio("QUALITY_MISSING_RATIO", 0.5)
+)
+
+// Short answer sets are alike by chance, so they aren't checked for straight-lining or duplicates
+const (
+	defaultStraightLiningMin = 3
+	qualityDuplicateMin      = 3
+)
+
+// QualityRules tunes a survey's straight-lining and speeder checks; unset rules
+// use the server defaults and 0 turns a rule off
+type QualityRules struct {
+	// StraightLiningMin is how many questions of a battery must be answered, identically, to flag straight-lining
+	StraightLiningMin *int `json:"straight_lining_min,omitempty"`
+	// SpeederRatio flags responses completed in less than this share of the median time
+	SpeederRatio *float64 `json:"speeder_ratio,omitempty"`
+	// SpeederMinSample is how many timed responses the median needs before speeders are flagged
+	SpeederMinSample *int `json:"speeder_min_sample,omitempty"`
+}
+
+// validate checks the rules set on a survey
+func (r QualityRules) validate() []string {
+	var errors []string
+	if r.StraightLiningMin != nil && (*r.StraightLiningMin < 0 || *r.StraightLiningMin == 1) {
+		errors = append(errors, "Straight-lining minimum must be 0 (off) or at least 2")
+	}
+	if r.SpeederRatio != nil && (*r.SpeederRatio < 0 || *r.SpeederRatio > 1) {
+		errors = append(errors, "Speeder ratio must be between 0 (off) and 1")
+	}
+	if r.SpeederMinSample != nil && *r.SpeederMinSample < 1 {
+		errors = append(errors, "Speeder minimum sample must be at least 1")
+	}
+	return errors
+}
+
+// resolved fills in the server defaults of unset rules
+func (r *QualityRules) resolved() QualityRules {
+	straightLiningMin, speederRatio, speederMinSample := defaultStraightLiningMin, qualitySpeederRatio, qualitySpeederMinSample
+	resolved := QualityRules{StraightLiningMin: &straightLiningMin, SpeederRatio: &speederRatio, SpeederMinSample: &speederMinSample}
+	if r != nil {
+		if r.StraightLiningMin != nil {
+			*resolved.StraightLiningMin = *r.StraightLiningMin
+		}
+		if r.SpeederRatio != nil {
+			*resolved.SpeederRatio = *r.SpeederRatio
+		}
+		if r.SpeederMinSample != nil {
+			*resolved.SpeederMinSample = *r.SpeederMinSample
+		}
+	}
+	return resolved
+}
+
+// responseQuality is the outcome of the quality checks of one response
+type responseQuality struct {
+	Flags []string
+	// AnswersHash identifies the answers, to find duplicates; empty for short answer sets
+	AnswersHash string
+}
+
+// flagsJSON returns the flags as stored in quality_flags
+func (q responseQuality) flagsJSON() string {
+	flags, _ := json.Marshal(q.Flags)
+	return string(flags)
+}
+
+// QualityReport summarizes the data quality of a survey's responses
+type QualityReport struct {
+	SurveyID  int `json:"survey_id"`
+	Responses int `json:"responses"`
+	Flagged   int `json:"flagged"`
+	// Score is the share of responses without any quality flag, null without responses
+	Score *float64 `json:"score"`
+	// Flags counts the responses carrying each flag
+	Flags                   map[string]int `json:"flags"`
+	MedianCompletionSeconds *int           `json:"median_completion_seconds"`
+	// Rules are the survey's straight-lining and speeder rules, defaults included
+	Rules QualityRules `json:"rules"`
+}
+
+// checkQuality runs the quality checks of a response against the survey's other
+// responses. responseID is 0 for a response that isn't stored yet.
+func checkQuality(ctx context.Context, survey Survey, responseID int, data json.RawMessage, completionSeconds *int) (responseQuality, error) {
+	quality := responseQuality{Flags: []string{}}
+	var answers map[string]json.RawMessage
+	if json.Unmarshal(data, &answers) != nil {
+		return quality, nil
+	}
+
+	answered := map[string]json.RawMessage{}
+	for questionID, answer := range answers {
+		if isJSONNull(answer) || string(answer) == `""` {
+			continue
+		}
+		var compact bytes.Buffer
+		if json.Compact(&compact, answer) == nil {
+			answered[questionID] = compact.Bytes()
+		}
+	}
+
+	rules := survey.QualityRules.resolved()
+	if straightLined(survey, answered, *rules.StraightLiningMin) {
+		quality.Flags = append(quality.Flags, QualityStraightLining)
+	}
+
+	if completionSeconds != nil && *rules.SpeederRatio > 0 {
+		median, err := medianCompletionSeconds(ctx, survey.ID, responseID, *rules.SpeederMinSample)
+		if err != nil {
+			return quality, err
+		}
+		if median != nil && float64(*completionSeconds) < float64(*median)**rules.SpeederRatio {
+			quality.Flags = append(quality.Flags, QualitySpeeder)
+		}
+	}
+
+	if len(survey.Questions) > 0 {
+		missing := 0
+		for _, q := range survey.Questions {
+			if _, ok := answered[q.ID]; !ok {
+				missing++
+			}
+		}
+		if float64(missing) > float64(len(survey.Questions))*qualityMissingRatio {
+			quality.Flags = append(quality.Flags, QualityHighMissingness)
+		}
+	}
+
+	if len(answered) >= qualityDuplicateMin {
+		// Marshaling a map sorts its keys, so equal answers hash alike
+		canonical, _ := json.Marshal(answered)
+		sum := sha256.Sum256(canonical)
+		quality.AnswersHash = hex.EncodeToString(sum[:])
+
+		var duplicate bool
+		err := db.QueryRowContext(ctx, `
+			SELECT EXISTS(SELECT 1 FROM survey_responses
+			WHERE survey_id = ? AND answers_hash = ? AND id != ? AND deleted_at IS NULL)
+		`, survey.ID, quality.AnswersHash, responseID).Scan(&duplicate)
+		if err != nil {
+			return quality, err
+		}
+		if duplicate {
+			quality.Flags = append(quality.Flags, QualityDuplicate)
+		}
+	}
+
+	return quality, nil
+}
+
+// straightLined reports whether any battery got at least min answers, all the same.
+// Without batteries the survey's rating questions are checked as one.
+func straightLined(survey Survey, answered map[string]json.RawMessage, min int) bool {
+	if min == 0 {
+		return false
+	}
+	batteries := map[string][]string{}
+	for _, q := range survey.Questions {
+		if q.Battery != "" {
+			batteries[q.Battery] = append(batteries[q.Battery], q.ID)
+		}
+	}
+	if len(batteries) == 0 {
+		for _, q := range survey.Questions {
+			if q.Type == QuestionRating {
+				batteries[""] = append(batteries[""], q.ID)
+			}
+		}
+	}
+
+	for _, questionIDs := range batteries {
+		var answers []json.RawMessage
+		for _, questionID := range questionIDs {
+			if answer, ok := answered[questionID]; ok {
+				answers = append(answers, answer)
+			}
+		}
+		if len(answers) >= min && allEqual(answers) {
+			return true
+		}
+	}
+	return false
+}
+
+// allEqual reports whether the compacted answers are all the same
+func allEqual(answers []json.RawMessage) bool {
+	for _, answer := range answers[1:] {
+		if !bytes.Equal(answer, answers[0]) {
+			return false
+		}
+	}
+	return true
+}
+
+// medianCompletionSeconds returns the median completion time of a survey's responses
+// other than excludeID, or nil while fewer than minSample are timed
+func medianCompletionSeconds(ctx context.Context, surveyID, excludeID, minSample int) (*int, error) {
+	const timed = `FROM survey_responses
+		WHERE survey_id = ? AND id != ? AND completion_seconds IS NOT NULL AND deleted_at IS NULL`
+
+	var count int
+	if err := db.QueryRowContext(ctx, "SELECT COUNT(*) "+timed, surveyID, excludeID).Scan(&count); err != nil {
+		return nil, err
+	}
+	if count < minSample {
+		return nil, nil
+	}
+
+	var median int
+	err := db.QueryRowContext(ctx, "SELECT completion_seconds "+timed+" ORDER BY completion_seconds LIMIT 1 OFFSET ?",
+		surveyID, excludeID, count/2).Scan(&median)
+	if err != nil {
+		return nil, err
+	}
+	return &median, nil
+}
+
+// parseQualityFilters reads ?quality_flag= and ?exclude_quality= (a comma-separated
+// list of flags, or "all") from the request
+func parseQualityFilters(params url.Values, q *responseListQuery) []string {
+	var errors []string
+
+	if flag := params.Get("quality_flag"); flag != "" {
+		if !containsString(qualityFlagNames, flag) {
+			errors = append(errors, "Quality flag must be one of "+strings.Join(qualityFlagNames, ", "))
+		}
+		q.QualityFlag = flag
+	}
+
+	if exclude := params.Get("exclude_quality"); exclude == "all" {
+		q.ExcludeQuality = qualityFlagNames
+	} else if exclude != "" {
+		for _, flag := range strings.Split(exclude, ",") {
+			flag = strings.TrimSpace(flag)
+			if !containsString(qualityFlagNames, flag) {
+				errors = append(errors, "Exclude quality must be all or a comma-separated list of "+strings.Join(qualityFlagNames, ", "))
+				break
+			}
+			q.ExcludeQuality = append(q.ExcludeQuality, flag)
+		}
+	}
+
+	return errors
+}
+
+// qualityFlagCondition matches the responses carrying a quality flag
+func qualityFlagCondition(flag string) (string, interface{}) {
+	return "sr.quality_flags LIKE ?", `%"` + flag + `"%`
+}
+
+// qualityReport computes the quality report of the survey's responses matching q
+func qualityReport(ctx context.Context, survey Survey, q responseListQuery) (QualityReport, error) {
+	report := QualityReport{SurveyID: q.SurveyID, Flags: map[string]int{}, Rules: survey.QualityRules.resolved()}
+	where, args := q.where()
+
+	columns := []string{"COUNT(*)", "COALESCE(SUM(sr.quality_flags != '[]'), 0)"}
+	var flagArgs []interface{}
+	for _, flag := range qualityFlagNames {
+		condition, arg := qualityFlagCondition(flag)
+		columns = append(columns, "COALESCE(SUM("+condition+"), 0)")
+		flagArgs = append(flagArgs, arg)
+	}
+
+	counts := make([]int, len(qualityFlagNames))
+	dest := []interface{}{&report.Responses, &report.Flagged}
+	for i := range counts {
+		dest = append(dest, &counts[i])
+	}
+	err := db.QueryRowContext(ctx, "SELECT "+strings.Join(columns, ", ")+" FROM survey_responses sr WHERE "+where,
+		append(flagArgs, args...)...).Scan(dest...)
+	if err != nil {
+		return report, err
+	}
+	for i, flag := range qualityFlagNames {
+		report.Flags[flag] = counts[i]
+	}
+	if report.Responses > 0 {
+		score := float64(report.Responses-report.Flagged) / float64(report.Responses)
+		report.Score = &score
+	}
+
+	report.MedianCompletionSeconds, err = medianCompletionSeconds(ctx, q.SurveyID, 0, *report.Rules.SpeederMinSample)
+	return report, err
+}
+
+// getQualityReport returns the data quality report of a survey, filtered like
+// the other analytics endpoints
+func getQualityReport(c *gin.Context) {
+	ctx := c.Request.Context()
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		abortWithError(c, errInvalidID("survey"))
+		return
+	}
+
+	q, errors := parseAnalyticsQuery(c, id)
+	if len(errors) > 0 {
+		abortWithError(c, errInvalidQuery(errors))
+		return
+	}
+
+	survey, err := findSurvey(ctx, id)
+	if err != nil {
+		abortWithError(c, errNotFound(CodeSurveyNotFound, "Survey not found"))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, analyticsTimeout)
+	defer cancel()
+	if !checkAnalyticsCost(ctx, c, q) {
+		return
+	}
+
+	report, err := qualityReport(ctx, survey, q)
+	if err != nil {
+		analyticsQueryFailed(ctx, c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Status: "success",
+		Data:   report,
+	})
+}
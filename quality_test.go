@@ -0,0 +1,213 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQualityFlags(t *testing.T) {
+	setupTestDB()
+	defer testDB.Close()
+
+	defer func(n int) { qualitySpeederMinSample = n }(qualitySpeederMinSample)
+	qualitySpeederMinSample = 3
+
+	result, err := testDB.Exec(`INSERT INTO surveys (title, description, questions) VALUES ('Quality', 'd', '[
+		{"id":"q1","type":"rating","label":"Q1"},
+		{"id":"q2","type":"rating","label":"Q2"},
+		{"id":"q3","type":"rating","label":"Q3"},
+		{"id":"comment","type":"text","label":"Comment"}
+	]')`)
+	assert.NoError(t, err)
+	surveyID, _ := result.LastInsertId()
+
+	router := setupTestRouter()
+	submit := func(user, data string, seconds int) SurveyResponse {
+		_, w := partialRequest(router, "POST", fmt.Sprintf("/api/surveys/%d/responses", surveyID),
+			fmt.Sprintf(`{"survey_response":{"user_identifier":%q,"response_data":%s,"metadata":{"completion_seconds":%d}}}`, user, data, seconds))
+		assert.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+		var response struct {
+			Data SurveyResponse `json:"data"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		return response.Data
+	}
+
+	clean := submit("user1", `{"q1":4,"q2":2,"q3":5,"comment":"Fine"}`, 300)
+	assert.Empty(t, clean.Metadata.QualityFlags)
+	assert.Equal(t, 300, *clean.Metadata.CompletionSeconds)
+	submit("user2", `{"q1":3,"q2":4,"q3":1}`, 240)
+
+	straight := submit("user3", `{"q1":5,"q2":5,"q3":5,"comment":"Great"}`, 200)
+	assert.Equal(t, []string{QualityStraightLining}, straight.Metadata.QualityFlags)
+
+	// The median of 200, 240 and 300 seconds is 240
+	speeder := submit("user4", `{"q1":1,"q2":2,"q3":3}`, 30)
+	assert.Equal(t, []string{QualitySpeeder}, speeder.Metadata.QualityFlags)
+
+	missing := submit("user5", `{"q1":2,"comment":""}`, 200)
+	assert.Equal(t, []string{QualityHighMissingness}, missing.Metadata.QualityFlags)
+
+	// Key order and whitespace don't hide a duplicate
+	duplicate := submit("user6", `{"comment": "Fine", "q3":5, "q2":2, "q1":4}`, 310)
+	assert.Equal(t, []string{QualityDuplicate}, duplicate.Metadata.QualityFlags)
+
+	// Edits are checked again
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("PATCH", fmt.Sprintf("/api/surveys/%d/responses/%d", surveyID, duplicate.ID),
+		bytes.NewBufferString(`{"survey_response":{"response_data":{"q1":4,"q2":3,"q3":5,"comment":"Fine"}}}`))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var updated struct {
+		Data SurveyResponse `json:"data"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &updated)
+	assert.Empty(t, updated.Data.Metadata.QualityFlags)
+
+	get := func(url string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", url, nil)
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w = get(fmt.Sprintf("/api/surveys/%d/quality", surveyID))
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var report struct {
+		Data QualityReport `json:"data"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &report)
+	assert.Equal(t, 6, report.Data.Responses)
+	assert.Equal(t, 3, report.Data.Flagged)
+	assert.InDelta(t, 0.5, *report.Data.Score, 0.001)
+	assert.Equal(t, map[string]int{QualityStraightLining: 1, QualitySpeeder: 1, QualityHighMissingness: 1, QualityDuplicate: 0}, report.Data.Flags)
+	assert.Equal(t, 240, *report.Data.MedianCompletionSeconds)
+
+	// Analytics and listings can leave flagged responses out, or list them
+	w = get(fmt.Sprintf("/api/surveys/%d/quality?exclude_quality=all", surveyID))
+	json.Unmarshal(w.Body.Bytes(), &report)
+	assert.Equal(t, 3, report.Data.Responses)
+	assert.Equal(t, 0, report.Data.Flagged)
+
+	w = get(fmt.Sprintf("/api/surveys/%d/results?exclude_quality=speeder,straight_lining", surveyID))
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var results struct {
+		Data SurveyResults `json:"data"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &results)
+	assert.Equal(t, 4, results.Data.ResponsesCount)
+
+	w = get(fmt.Sprintf("/api/surveys/%d/responses?quality_flag=speeder", surveyID))
+	var listed struct {
+		Data []SurveyResponse `json:"data"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &listed)
+	if assert.Len(t, listed.Data, 1) {
+		assert.Equal(t, speeder.ID, listed.Data[0].ID)
+	}
+
+	w = get(fmt.Sprintf("/api/surveys/%d/responses?exclude_quality=bogus", surveyID))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestStraightLined(t *testing.T) {
+	ratings := Survey{Questions: []Question{
+		{ID: "a", Type: QuestionRating}, {ID: "b", Type: QuestionRating}, {ID: "c", Type: QuestionRating},
+	}}
+	matrix := Survey{Questions: []Question{
+		{ID: "a", Type: QuestionRating, Battery: "service"}, {ID: "b", Type: QuestionRating, Battery: "service"},
+		{ID: "c", Type: QuestionSingleChoice, Battery: "product"}, {ID: "d", Type: QuestionSingleChoice, Battery: "product"},
+		{ID: "e", Type: QuestionRating},
+	}}
+	answers := func(values ...string) map[string]json.RawMessage {
+		answered := map[string]json.RawMessage{}
+		for i, v := range values {
+			if v != "" {
+				answered[string(rune('a'+i))] = json.RawMessage(v)
+			}
+		}
+		return answered
+	}
+
+	tests := []struct {
+		name     string
+		survey   Survey
+		answered map[string]json.RawMessage
+		min      int
+		want     bool
+	}{
+		{"identical ratings", ratings, answers("4", "4", "4"), 3, true},
+		{"varied ratings", ratings, answers("4", "4", "5"), 3, false},
+		{"too few answered", ratings, answers("4", "4", ""), 3, false},
+		{"lower minimum", ratings, answers("4", "4", ""), 2, true},
+		{"rule off", ratings, answers("4", "4", "4"), 0, false},
+		{"one identical battery", matrix, answers("1", "2", `"Yes"`, `"Yes"`, "2"), 2, true},
+		{"batteries checked apart", matrix, answers("1", "2", `"Yes"`, `"No"`, "1"), 2, false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, straightLined(tt.survey, tt.answered, tt.min), tt.name)
+	}
+}
+
+func TestSurveyQualityRules(t *testing.T) {
+	setupTestDB()
+	defer testDB.Close()
+
+	router := setupTestRouter()
+	create := func(body string) (*httptest.ResponseRecorder, Survey) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/api/surveys", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		var response struct {
+			Data Survey `json:"data"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		return w, response.Data
+	}
+
+	w, _ := create(`{"survey":{"title":"Rules","description":"d","quality_rules":{"straight_lining_min":1,"speeder_ratio":2}}}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	w, _ = create(`{"survey":{"title":"Rules","description":"d","questions":[{"id":"a","type":"text","label":"A","battery":"x"}]}}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+	w, survey := create(`{"survey":{"title":"Rules","description":"d","quality_rules":{"straight_lining_min":0,"speeder_min_sample":2},
+		"questions":[{"id":"a","type":"rating","label":"A"},{"id":"b","type":"rating","label":"B"},{"id":"c","type":"rating","label":"C"}]}}`)
+	assert.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	assert.Equal(t, 0, *survey.QualityRules.StraightLiningMin)
+	assert.Nil(t, survey.QualityRules.SpeederRatio)
+
+	// Straight-lining is off for this survey and two timed responses make a median;
+	// an extra answer per user keeps the responses from being duplicates
+	submit := func(user string, seconds int) SurveyResponse {
+		_, w := partialRequest(router, "POST", fmt.Sprintf("/api/surveys/%d/responses", survey.ID),
+			fmt.Sprintf(`{"survey_response":{"user_identifier":%q,"response_data":{"a":3,"b":3,"c":3,"%s":1},"metadata":{"completion_seconds":%d}}}`, user, user, seconds))
+		var response struct {
+			Data SurveyResponse `json:"data"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		return response.Data
+	}
+	submit("user1", 100)
+	submit("user2", 120)
+	assert.Equal(t, []string{QualitySpeeder}, submit("user3", 10).Metadata.QualityFlags)
+
+	w = httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", fmt.Sprintf("/api/surveys/%d/quality", survey.ID), nil)
+	router.ServeHTTP(w, req)
+	var report struct {
+		Data QualityReport `json:"data"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &report)
+	assert.Equal(t, 0, *report.Data.Rules.StraightLiningMin)
+	assert.Equal(t, qualitySpeederRatio, *report.Data.Rules.SpeederRatio)
+	assert.Equal(t, 2, *report.Data.Rules.SpeederMinSample)
+	assert.Equal(t, 1, report.Data.Flags[QualitySpeeder])
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Query logging settings
+var (
+	// dbLogQueries logs every statement with its duration
+	dbLogQueries = os.Getenv("DB_LOG_QUERIES") == "true"
+	// dbSlowQuery is the duration from which statements are kept for the slow query report
+	dbSlowQuery = envDuration("DB_SLOW_QUERY", 100*time.Millisecond)
+)
+
+// slowQueryCapacity is how many slow statements are kept; older ones are dropped
+const slowQueryCapacity = 1000
+
+// slowQueries holds the recent slow statements of this instance
+var slowQueries = &slowQueryLog{}
+
+// queryOrigin tells which request a statement was run for
+type queryOrigin struct {
+	Route     string
+	RequestID string
+}
+
+// queryOriginKey is the context key of the queryOrigin
+type queryOriginKey struct{}
+
+// queryOrigins adds the route and request ID to the request context, so the
+// statements run for the request can be attributed to it
+func queryOrigins() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		origin := queryOrigin{Route: c.FullPath(), RequestID: requestID(c)}
+		c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), queryOriginKey{}, origin))
+		c.Next()
+	}
+}
+
+// SlowQuery is a statement that took at least DB_SLOW_QUERY
+type SlowQuery struct {
+	Query string `json:"query"`
+	// Route is empty for statements run outside requests, e.g. by the scheduler
+	Route      string    `json:"route"`
+	DurationMs float64   `json:"duration_ms"`
+	At         time.Time `json:"at"`
+}
+
+// SlowQueryGroup sums up the slow runs of a statement from one route
+type SlowQueryGroup struct {
+	Query    string    `json:"query"`
+	Route    string    `json:"route"`
+	Count    int       `json:"count"`
+	MaxMs    float64   `json:"max_ms"`
+	MeanMs   float64   `json:"mean_ms"`
+	LastSeen time.Time `json:"last_seen"`
+}
+
+// SlowQueryReport is the slow query report of an instance
+type SlowQueryReport struct {
+	ThresholdMs float64 `json:"threshold_ms"`
+	// Since is when the oldest slow statement still kept was run
+	Since  *time.Time       `json:"since"`
+	Groups []SlowQueryGroup `json:"groups"`
+}
+
+// slowQueryLog is a ring of the most recent slow statements
+type slowQueryLog struct {
+	mu      sync.Mutex
+	entries []SlowQuery
+	next    int
+}
+
+// add keeps a slow statement, replacing the oldest one when full
+func (l *slowQueryLog) add(q SlowQuery) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.entries) < slowQueryCapacity {
+		l.entries = append(l.entries, q)
+		return
+	}
+	l.entries[l.next] = q
+	l.next = (l.next + 1) % slowQueryCapacity
+}
+
+// report groups the kept statements by query and route, slowest first
+func (l *slowQueryLog) report(limit int) SlowQueryReport {
+	l.mu.Lock()
+	entries := append([]SlowQuery{}, l.entries...)
+	l.mu.Unlock()
+
+	report := SlowQueryReport{ThresholdMs: durationMs(dbSlowQuery), Groups: []SlowQueryGroup{}}
+	groups := map[[2]string]*SlowQueryGroup{}
+	for _, q := range entries {
+		if report.Since == nil || q.At.Before(*report.Since) {
+			at := q.At
+			report.Since = &at
+		}
+		key := [2]string{q.Query, q.Route}
+		g, ok := groups[key]
+		if !ok {
+			g = &SlowQueryGroup{Query: q.Query, Route: q.Route}
+			groups[key] = g
+		}
+		g.MeanMs = (g.MeanMs*float64(g.Count) + q.DurationMs) / float64(g.Count+1)
+		g.Count++
+		if q.DurationMs > g.MaxMs {
+			g.MaxMs = q.DurationMs
+		}
+		if q.At.After(g.LastSeen) {
+			g.LastSeen = q.At
+		}
+	}
+	for _, g := range groups {
+		report.Groups = append(report.Groups, *g)
+	}
+	sort.Slice(report.Groups, func(i, j int) bool {
+		a, b := report.Groups[i], report.Groups[j]
+		if a.MaxMs != b.MaxMs {
+			return a.MaxMs > b.MaxMs
+		}
+		return a.Count > b.Count
+	})
+	if len(report.Groups) > limit {
+		report.Groups = report.Groups[:limit]
+	}
+	return report
+}
+
+// durationMs converts a duration to milliseconds with microsecond precision
+func durationMs(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+// recordQuery logs a statement when query logging is on and keeps it for the
+// slow query report when it took at least dbSlowQuery
+func recordQuery(ctx context.Context, query string, elapsed time.Duration, err error) {
+	if !dbLogQueries && elapsed < dbSlowQuery {
+		return
+	}
+	origin, _ := ctx.Value(queryOriginKey{}).(queryOrigin)
+	query = strings.Join(strings.Fields(query), " ")
+
+	if dbLogQueries {
+		attrs := []slog.Attr{
+			slog.String("query", query),
+			slog.Float64("duration_ms", durationMs(elapsed)),
+			slog.String("route", origin.Route),
+			slog.String("request_id", origin.RequestID),
+		}
+		if err != nil {
+			attrs = append(attrs, slog.String("error", err.Error()))
+		}
+		slog.LogAttrs(ctx, slog.LevelInfo, "query", attrs...)
+	}
+	if elapsed >= dbSlowQuery {
+		slowQueries.add(SlowQuery{Query: query, Route: origin.Route, DurationMs: durationMs(elapsed), At: time.Now().UTC()})
+	}
+}
+
+// getSlowQueries reports this instance's slowest recent statements, grouped by
+// query and route, to guide indexing
+func getSlowQueries(c *gin.Context) {
+	limit := 20
+	if value := c.Query("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > maxPageSize {
+			abortWithError(c, errInvalidQuery([]string{fmt.Sprintf("Limit must be between 1 and %d", maxPageSize)}))
+			return
+		}
+		limit = n
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Status: "success",
+		Data:   slowQueries.report(limit),
+	})
+}
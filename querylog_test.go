@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSlowQueryLog(t *testing.T) {
+	l := &slowQueryLog{}
+	at := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	l.add(SlowQuery{Query: "SELECT a", Route: "/api/x", DurationMs: 120, At: at})
+	l.add(SlowQuery{Query: "SELECT a", Route: "/api/x", DurationMs: 300, At: at.Add(time.Minute)})
+	l.add(SlowQuery{Query: "SELECT a", Route: "/api/y", DurationMs: 150, At: at})
+	l.add(SlowQuery{Query: "SELECT b", Route: "", DurationMs: 500, At: at})
+
+	report := l.report(2)
+	assert.Equal(t, at, *report.Since)
+	assert.Len(t, report.Groups, 2)
+	assert.Equal(t, "SELECT b", report.Groups[0].Query)
+	assert.Equal(t, SlowQueryGroup{Query: "SELECT a", Route: "/api/x", Count: 2, MaxMs: 300, MeanMs: 210, LastSeen: at.Add(time.Minute)}, report.Groups[1])
+
+	// The oldest statements make room for new ones
+	for i := 0; i < slowQueryCapacity; i++ {
+		l.add(SlowQuery{Query: "SELECT c", DurationMs: 100, At: at.Add(time.Hour)})
+	}
+	report = l.report(10)
+	assert.Len(t, report.Groups, 1)
+	assert.Equal(t, slowQueryCapacity, report.Groups[0].Count)
+}
+
+func TestSlowQueries(t *testing.T) {
+	setupTestDB()
+	defer testDB.Close()
+	router := setupTestRouter()
+
+	// Statements are timed by the driver openDatabase uses
+	fileDB, err := openDatabase(filepath.Join(t.TempDir(), "survey_form.db"))
+	assert.NoError(t, err)
+	defer fileDB.Close()
+	assert.NoError(t, migrate(fileDB))
+
+	defer func(threshold time.Duration, log *slowQueryLog) { dbSlowQuery, slowQueries = threshold, log }(dbSlowQuery, slowQueries)
+	dbSlowQuery, slowQueries = 0, &slowQueryLog{}
+	ctx := context.WithValue(context.Background(), queryOriginKey{}, queryOrigin{Route: "/api/surveys"})
+	rows, err := fileDB.QueryContext(ctx, "SELECT id,\n\t\ttitle FROM surveys")
+	assert.NoError(t, err)
+	for rows.Next() {
+	}
+	rows.Close()
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, adminRequest("GET", "/api/admin/slow-queries", nil))
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var response struct {
+		Data SlowQueryReport `json:"data"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	found := false
+	for _, g := range response.Data.Groups {
+		if strings.HasPrefix(g.Query, "SELECT id, title FROM surveys") {
+			found = true
+			assert.Equal(t, "/api/surveys", g.Route)
+			assert.Equal(t, 1, g.Count)
+		}
+	}
+	assert.True(t, found, w.Body.String())
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, adminRequest("GET", "/api/admin/slow-queries?limit=0", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/admin/slow-queries", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
@@ -0,0 +1,326 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Question types
+const (
+	QuestionText           = "text"
+	QuestionNumber         = "number"
+	QuestionBoolean        = "boolean"
+	QuestionRating         = "rating"
+	QuestionSingleChoice   = "single_choice"
+	QuestionMultipleChoice = "multiple_choice"
+)
+
+// Question describes one answer in response_data; ID is its key
+type Question struct {
+	ID       string   `json:"id"`
+	Type     string   `json:"type"`
+	Label    string   `json:"label"`
+	Required bool     `json:"required,omitempty"`
+	Options  []string `json:"options,omitempty"`
+	Min      *float64 `json:"min,omitempty"`
+	Max      *float64 `json:"max,omitempty"`
+	// Battery groups questions asked as one matrix, checked together for straight-lining
+	Battery string `json:"battery,omitempty"`
+	// Page is the ID of the page the question is shown on
+	Page string `json:"page,omitempty"`
+	// Recodes are named maps from answer values to derived values, such as a
+	// 5-point scale to top-2-box, applied by analytics with ?recode=
+	Recodes map[string]map[string]string `json:"recodes,omitempty"`
+	// PII answers are masked in responses read by anyone but admins
+	PII bool `json:"pii,omitempty"`
+}
+
+// questionIDPattern keeps question IDs usable as JSON keys, URL segments and CSV headers
+var questionIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// Default scale of rating questions
+const (
+	defaultRatingMin = 1
+	defaultRatingMax = 5
+)
+
+// validateQuestions checks a survey's question definitions
+func validateQuestions(questions []Question) []string {
+	var errors []string
+	seen := map[string]bool{}
+
+	for i, q := range questions {
+		label := fmt.Sprintf("Question %d", i+1)
+		if !questionIDPattern.MatchString(q.ID) {
+			errors = append(errors, label+" ID must be 1-64 letters, digits, underscores or dashes")
+		} else if seen[q.ID] {
+			errors = append(errors, label+" ID "+q.ID+" is already used")
+		}
+		seen[q.ID] = true
+
+		if q.Label == "" {
+			errors = append(errors, label+" label is required")
+		}
+
+		switch q.Type {
+		case QuestionSingleChoice, QuestionMultipleChoice:
+			if len(q.Options) == 0 {
+				errors = append(errors, label+" needs at least one option")
+			}
+		case QuestionText, QuestionNumber, QuestionBoolean, QuestionRating:
+			if len(q.Options) > 0 {
+				errors = append(errors, label+" options are only allowed on choice questions")
+			}
+		default:
+			errors = append(errors, label+" type must be one of text, number, boolean, rating, single_choice or multiple_choice")
+		}
+
+		if q.Min != nil && q.Max != nil && *q.Max < *q.Min {
+			errors = append(errors, label+" max must not be less than min")
+		}
+
+		if q.Battery != "" {
+			if !questionIDPattern.MatchString(q.Battery) {
+				errors = append(errors, label+" battery must be 1-64 letters, digits, underscores or dashes")
+			}
+			if q.Type != QuestionRating && q.Type != QuestionSingleChoice {
+				errors = append(errors, label+" battery is only allowed on rating and single choice questions")
+			}
+		}
+
+		errors = append(errors, validateRecodes(label, q.Recodes)...)
+	}
+	return errors
+}
+
+// questionSchema returns the JSON Schema of an answer to q
+func questionSchema(q Question) map[string]interface{} {
+	s := map[string]interface{}{"title": q.Label}
+
+	switch q.Type {
+	case QuestionText:
+		s["type"] = "string"
+		if q.Min != nil {
+			s["minLength"] = int(*q.Min)
+		}
+		if q.Max != nil {
+			s["maxLength"] = int(*q.Max)
+		}
+	case QuestionNumber:
+		s["type"] = "number"
+		if q.Min != nil {
+			s["minimum"] = *q.Min
+		}
+		if q.Max != nil {
+			s["maximum"] = *q.Max
+		}
+	case QuestionRating:
+		min, max := float64(defaultRatingMin), float64(defaultRatingMax)
+		if q.Min != nil {
+			min = *q.Min
+		}
+		if q.Max != nil {
+			max = *q.Max
+		}
+		s["type"] = "integer"
+		s["minimum"] = min
+		s["maximum"] = max
+	case QuestionBoolean:
+		s["type"] = "boolean"
+	case QuestionSingleChoice:
+		s["type"] = "string"
+		s["enum"] = q.Options
+	case QuestionMultipleChoice:
+		s["type"] = "array"
+		s["items"] = map[string]interface{}{"type": "string", "enum": q.Options}
+		s["uniqueItems"] = true
+		if q.Min != nil {
+			s["minItems"] = int(*q.Min)
+		}
+		if q.Max != nil {
+			s["maxItems"] = int(*q.Max)
+		}
+	}
+	return s
+}
+
+// answerWarnings checks response data against the survey's questions. Responses
+// are accepted regardless; the warnings put them in the review queue.
+func answerWarnings(survey Survey, data json.RawMessage) []string {
+	warnings := []string{}
+	if len(survey.Questions) == 0 {
+		return warnings
+	}
+	var answers map[string]json.RawMessage
+	if json.Unmarshal(data, &answers) != nil {
+		return append(warnings, "Response data is not a JSON object")
+	}
+
+	known := map[string]bool{}
+	for _, q := range survey.Questions {
+		known[q.ID] = true
+		answer, ok := answers[q.ID]
+		if !ok || isJSONNull(answer) {
+			if q.Required {
+				warnings = append(warnings, fmt.Sprintf("Answer to %s is required", q.ID))
+			}
+			continue
+		}
+		if problem := answerProblem(q, answer); problem != "" {
+			warnings = append(warnings, fmt.Sprintf("Answer to %s %s", q.ID, problem))
+		}
+	}
+
+	var unknown []string
+	for questionID := range answers {
+		if !known[questionID] {
+			unknown = append(unknown, fmt.Sprintf("%s is not a question of this survey", questionID))
+		}
+	}
+	sort.Strings(unknown)
+	return append(warnings, unknown...)
+}
+
+// warningsJSON returns the answer warnings as stored in validation_warnings
+func warningsJSON(survey Survey, data json.RawMessage) string {
+	warnings, _ := json.Marshal(answerWarnings(survey, data))
+	return string(warnings)
+}
+
+// answerProblem describes how an answer doesn't match questionSchema(q), or returns ""
+func answerProblem(q Question, answer json.RawMessage) string {
+	switch q.Type {
+	case QuestionText:
+		var s string
+		if json.Unmarshal(answer, &s) != nil {
+			return "must be text"
+		}
+		if q.Min != nil && len([]rune(s)) < int(*q.Min) {
+			return fmt.Sprintf("must be at least %d characters", int(*q.Min))
+		}
+		if q.Max != nil && len([]rune(s)) > int(*q.Max) {
+			return fmt.Sprintf("must be at most %d characters", int(*q.Max))
+		}
+	case QuestionNumber:
+		var n float64
+		if json.Unmarshal(answer, &n) != nil {
+			return "must be a number"
+		}
+		if q.Min != nil && n < *q.Min {
+			return fmt.Sprintf("must be at least %g", *q.Min)
+		}
+		if q.Max != nil && n > *q.Max {
+			return fmt.Sprintf("must be at most %g", *q.Max)
+		}
+	case QuestionRating:
+		min, max := float64(defaultRatingMin), float64(defaultRatingMax)
+		if q.Min != nil {
+			min = *q.Min
+		}
+		if q.Max != nil {
+			max = *q.Max
+		}
+		var n float64
+		if json.Unmarshal(answer, &n) != nil || n != math.Trunc(n) || n < min || n > max {
+			return fmt.Sprintf("must be a whole number from %g to %g", min, max)
+		}
+	case QuestionBoolean:
+		var b bool
+		if json.Unmarshal(answer, &b) != nil {
+			return "must be true or false"
+		}
+	case QuestionSingleChoice:
+		var s string
+		if json.Unmarshal(answer, &s) != nil || !containsString(q.Options, s) {
+			return "must be one of the options"
+		}
+	case QuestionMultipleChoice:
+		var choices []string
+		if json.Unmarshal(answer, &choices) != nil {
+			return "must be a list of options"
+		}
+		seen := map[string]bool{}
+		for _, choice := range choices {
+			if !containsString(q.Options, choice) || seen[choice] {
+				return "must list distinct options"
+			}
+			seen[choice] = true
+		}
+		if q.Min != nil && len(choices) < int(*q.Min) {
+			return fmt.Sprintf("must choose at least %d options", int(*q.Min))
+		}
+		if q.Max != nil && len(choices) > int(*q.Max) {
+			return fmt.Sprintf("must choose at most %d options", int(*q.Max))
+		}
+	}
+	return ""
+}
+
+// containsString reports whether list contains s
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// responseSchema returns the JSON Schema of the survey's response_data. Surveys
+// without questions accept any object.
+func responseSchema(survey Survey) map[string]interface{} {
+	s := map[string]interface{}{
+		"$schema": "https://json-schema.org/draft/2020-12/schema",
+		"$id":     fmt.Sprintf("/api/surveys/%d/response-schema", survey.ID),
+		"title":   survey.Title,
+		"type":    "object",
+	}
+	if len(survey.Questions) == 0 {
+		return s
+	}
+
+	properties := map[string]interface{}{}
+	required := []string{}
+	for _, q := range survey.Questions {
+		properties[q.ID] = questionSchema(q)
+		if q.Required {
+			required = append(required, q.ID)
+		}
+	}
+	s["properties"] = properties
+	s["required"] = required
+	s["additionalProperties"] = false
+	return s
+}
+
+// getResponseSchema returns the JSON Schema document of a survey's response_data
+func getResponseSchema(c *gin.Context) {
+	ctx := c.Request.Context()
+	id := c.Param("id")
+	surveyID, err := strconv.Atoi(id)
+	if err != nil {
+		abortWithError(c, errInvalidID("survey"))
+		return
+	}
+
+	survey, err := findSurvey(ctx, surveyID)
+	if err == sql.ErrNoRows || (err == nil && survey.Embargoed()) {
+		abortWithError(c, errNotFound(CodeSurveyNotFound, "Survey not found"))
+		return
+	}
+	if err != nil {
+		abortWithError(c, errInternal("Failed to fetch survey", err))
+		return
+	}
+
+	c.Header("Content-Type", "application/schema+json")
+	c.JSON(http.StatusOK, responseSchema(survey))
+}
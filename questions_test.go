@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateQuestions(t *testing.T) {
+	assert.Empty(t, validateQuestions([]Question{
+		{ID: "rating", Type: QuestionRating, Label: "How was it?", Required: true},
+		{ID: "colors", Type: QuestionMultipleChoice, Label: "Colors", Options: []string{"red", "blue"}},
+	}))
+
+	errors := validateQuestions([]Question{
+		{ID: "bad id", Type: QuestionText, Label: "Text"},
+		{ID: "pick", Type: QuestionSingleChoice, Label: "Pick"},
+		{ID: "pick", Type: "slider"},
+	})
+	assert.Equal(t, []string{
+		"Question 1 ID must be 1-64 letters, digits, underscores or dashes",
+		"Question 2 needs at least one option",
+		"Question 3 ID pick is already used",
+		"Question 3 label is required",
+		"Question 3 type must be one of text, number, boolean, rating, single_choice or multiple_choice",
+	}, errors)
+}
+
+func TestGetResponseSchema(t *testing.T) {
+	setupTestDB()
+	defer testDB.Close()
+
+	router := setupTestRouter()
+
+	body := `{"survey":{"title":"Schema Survey","description":"Has questions","questions":[
+		{"id":"rating","type":"rating","label":"How was it?","required":true},
+		{"id":"plan","type":"single_choice","label":"Plan","options":["free","pro"]},
+		{"id":"comment","type":"text","label":"Comments","max":500}
+	]}}`
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/surveys", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	var created struct {
+		Data Survey `json:"data"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &created)
+	assert.Len(t, created.Data.Questions, 3)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", fmt.Sprintf("/api/surveys/%d/response-schema", created.Data.ID), nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/schema+json", w.Header().Get("Content-Type"))
+	assert.JSONEq(t, fmt.Sprintf(`{
+		"$schema": "https://json-schema.org/draft/2020-12/schema",
+		"$id": "/api/surveys/%d/response-schema",
+		"title": "Schema Survey",
+		"type": "object",
+		"properties": {
+			"rating": {"title": "How was it?", "type": "integer", "minimum": 1, "maximum": 5},
+			"plan": {"title": "Plan", "type": "string", "enum": ["free", "pro"]},
+			"comment": {"title": "Comments", "type": "string", "maxLength": 500}
+		},
+		"required": ["rating"],
+		"additionalProperties": false
+	}`, created.Data.ID), w.Body.String())
+
+	// Invalid question definitions are rejected
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/api/surveys", bytes.NewBufferString(`{"survey":{"title":"Bad Survey","description":"x","questions":[{"id":"q","type":"slider","label":"Q"}]}}`))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/surveys/999/response-schema", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestAnswerWarnings(t *testing.T) {
+	five := 5.0
+	survey := Survey{Questions: []Question{
+		{ID: "rating", Type: QuestionRating, Label: "Rating", Required: true},
+		{ID: "plan", Type: QuestionSingleChoice, Label: "Plan", Options: []string{"free", "pro"}},
+		{ID: "tags", Type: QuestionMultipleChoice, Label: "Tags", Options: []string{"a", "b"}},
+		{ID: "comment", Type: QuestionText, Label: "Comment", Max: &five},
+		{ID: "ok", Type: QuestionBoolean, Label: "OK"},
+	}}
+
+	assert.Empty(t, answerWarnings(survey, json.RawMessage(`{"rating":4,"plan":"pro","tags":["a","b"],"comment":"Nice","ok":true}`)))
+	assert.Equal(t, []string{
+		"Answer to rating is required",
+		"Answer to plan must be one of the options",
+		"Answer to tags must list distinct options",
+		"Answer to comment must be at most 5 characters",
+		"Answer to ok must be true or false",
+		"color is not a question of this survey",
+	}, answerWarnings(survey, json.RawMessage(`{"plan":"team","tags":["a","a"],"comment":"Too long","ok":"yes","color":"red"}`)))
+	assert.Equal(t, []string{"Answer to rating must be a whole number from 1 to 5"}, answerWarnings(survey, json.RawMessage(`{"rating":"4"}`)))
+
+	// Surveys without questions accept anything
+	assert.Empty(t, answerWarnings(Survey{}, json.RawMessage(`{"anything":1}`)))
+}
@@ -0,0 +1,406 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// RateLimit is a token bucket holding Requests tokens, refilled over Per
+type RateLimit struct {
+	Requests int
+	Per      time.Duration
+}
+
+// refillRate returns the number of tokens added per nanosecond
+func (l RateLimit) refillRate() float64 {
+	return float64(l.Requests) / float64(l.Per)
+}
+
+// resetAfter returns how long a bucket holding tokens takes to refill completely
+func (l RateLimit) resetAfter(tokens float64) time.Duration {
+	return time.Duration((float64(l.Requests) - tokens) / l.refillRate())
+}
+
+// parseRateLimit parses limits such as "30/1m" or "1000/1h"
+func parseRateLimit(s string) (RateLimit, error) {
+	parts := strings.SplitN(s, "/", 2)
+	if len(parts) != 2 {
+		return RateLimit{}, fmt.Errorf("invalid rate limit %q, expected requests/duration", s)
+	}
+	requests, err := strconv.Atoi(parts[0])
+	if err != nil || requests < 1 {
+		return RateLimit{}, fmt.Errorf("invalid request count in rate limit %q", s)
+	}
+	per, err := time.ParseDuration(parts[1])
+	if err != nil || per <= 0 {
+		return RateLimit{}, fmt.Errorf("invalid duration in rate limit %q", s)
+	}
+	return RateLimit{Requests: requests, Per: per}, nil
+}
+
+// envRateLimit reads a rate limit from the environment, falling back to def
+func envRateLimit(name string, def RateLimit) RateLimit {
+	value := os.Getenv(name)
+	if value == "" {
+		return def
+	}
+	limit, err := parseRateLimit(value)
+	if err != nil {
+		log.Printf("Ignoring %s: %v", name, err)
+		return def
+	}
+	return limit
+}
+
+// Per-route limits, overridable with the environment variable of the same name
+var (
+	apiRateLimit        = envRateLimit("RATE_LIMIT_API", RateLimit{Requests: 300, Per: time.Minute})
+	submitIPRateLimit   = envRateLimit("RATE_LIMIT_SUBMIT_IP", RateLimit{Requests: 30, Per: time.Minute})
+	submitUserRateLimit = envRateLimit("RATE_LIMIT_SUBMIT_USER", RateLimit{Requests: 5, Per: time.Hour})
+	// Every survey render reports an event, so embeds shown often may need more
+	experienceIPRateLimit = envRateLimit("RATE_LIMIT_EXPERIENCE_IP", RateLimit{Requests: 60, Per: time.Minute})
+	// A form failing in a loop shouldn't flood the diagnostics
+	clientErrorsIPRateLimit = envRateLimit("RATE_LIMIT_CLIENT_ERRORS_IP", RateLimit{Requests: 20, Per: time.Minute})
+)
+
+// rateLimitWarnRatio is the share of a limit used before responses carry a warning,
+// overridable with RATE_LIMIT_WARN_RATIO
+var rateLimitWarnRatio = envRatio("RATE_LIMIT_WARN_RATIO", 0.8)
+
+// envRatio reads a ratio between 0 and 1 from the environment, falling back to def
+func envRatio(name string, def float64) float64 {
+	value := os.Getenv(name)
+	if value == "" {
+		return def
+	}
+	ratio, err := strconv.ParseFloat(value, 64)
+	if err != nil || ratio < 0 || ratio > 1 {
+		log.Printf("Ignoring %s: invalid ratio %q", name, value)
+		return def
+	}
+	return ratio
+}
+
+// warnThreshold returns the remaining requests at or below which clients are warned
+func (l RateLimit) warnThreshold() int {
+	return int(math.Round(float64(l.Requests) * (1 - rateLimitWarnRatio)))
+}
+
+// RateLimitResult is the outcome of taking a token from a bucket
+type RateLimitResult struct {
+	Allowed    bool
+	Remaining  int
+	RetryAfter time.Duration
+	// Reset is how long until the bucket is full again
+	Reset time.Duration
+}
+
+// RateLimitRule describes a limit for the /api/limits discovery endpoint
+type RateLimitRule struct {
+	Name          string   `json:"name"`
+	Key           string   `json:"key"`
+	Limit         int      `json:"limit"`
+	PeriodSeconds int      `json:"period_seconds"`
+	Routes        []string `json:"routes"`
+}
+
+// rateLimitRules lists the limits applied by setupRouter
+func rateLimitRules() []RateLimitRule {
+	rule := func(name, key string, limit RateLimit, routes ...string) RateLimitRule {
+		return RateLimitRule{
+			Name:          name,
+			Key:           key,
+			Limit:         limit.Requests,
+			PeriodSeconds: int(limit.Per.Seconds()),
+			Routes:        routes,
+		}
+	}
+	return []RateLimitRule{
+		rule("api", "client_ip", apiRateLimit, "/api/*"),
+		rule("submit_ip", "client_ip", submitIPRateLimit, "POST /api/surveys/:id/responses"),
+		rule("submit_user", "survey_id+user_identifier", submitUserRateLimit, "POST /api/surveys/:id/responses"),
+		rule("resume_ip", "client_ip", resumeIPRateLimit, "POST /api/surveys/:id/partial-responses/resume"),
+		rule("resume_survey", "survey_id", resumeSurveyRateLimit, "POST /api/surveys/:id/partial-responses/resume"),
+		rule("experience_ip", "client_ip", experienceIPRateLimit, "POST /api/surveys/:id/experience-events"),
+		rule("client_errors_ip", "client_ip", clientErrorsIPRateLimit, "POST /api/client-errors"),
+		rule("share_ip", "client_ip", shareIPRateLimit, "GET /s/:token", "GET /s/:token/form", "GET /i/:token"),
+		rule("discover_ip", "client_ip", discoverIPRateLimit, "GET /api/discover"),
+	}
+}
+
+// getRateLimits describes the rate limits so clients can throttle themselves
+func getRateLimits(c *gin.Context) {
+	c.JSON(http.StatusOK, APIResponse{
+		Status: "success",
+		Data:   rateLimitRules(),
+	})
+}
+
+// RateLimitStore keeps token buckets; implementations must be safe for concurrent use
+type RateLimitStore interface {
+	Take(ctx context.Context, key string, limit RateLimit) (RateLimitResult, error)
+}
+
+// newRateLimitStore returns a Redis-backed store when REDIS_URL is set, and an in-memory one otherwise
+func newRateLimitStore() RateLimitStore {
+	if url := os.Getenv("REDIS_URL"); url != "" {
+		opts, err := redis.ParseURL(url)
+		if err != nil {
+			log.Fatal(err)
+		}
+		return &redisRateLimitStore{client: redis.NewClient(opts)}
+	}
+	return newMemoryRateLimitStore()
+}
+
+// tokenBucket is the state of a single in-memory bucket
+type tokenBucket struct {
+	tokens  float64
+	updated time.Time
+	per     time.Duration
+}
+
+// memoryRateLimitStore keeps buckets in process memory
+type memoryRateLimitStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+func newMemoryRateLimitStore() *memoryRateLimitStore {
+	return &memoryRateLimitStore{
+		buckets:   make(map[string]*tokenBucket),
+		lastSweep: time.Now(),
+		now:       time.Now,
+	}
+}
+
+// Take removes a token from the bucket for key, refilling it for the time elapsed
+func (s *memoryRateLimitStore) Take(ctx context.Context, key string, limit RateLimit) (RateLimitResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.sweep(now)
+
+	capacity := float64(limit.Requests)
+	bucket, ok := s.buckets[key]
+	if !ok {
+		bucket = &tokenBucket{tokens: capacity, updated: now, per: limit.Per}
+		s.buckets[key] = bucket
+	}
+
+	elapsed := now.Sub(bucket.updated)
+	bucket.tokens = math.Min(capacity, bucket.tokens+float64(elapsed)*limit.refillRate())
+	bucket.updated = now
+
+	if bucket.tokens < 1 {
+		wait := time.Duration((1 - bucket.tokens) / limit.refillRate())
+		return RateLimitResult{Allowed: false, Remaining: 0, RetryAfter: wait, Reset: limit.resetAfter(bucket.tokens)}, nil
+	}
+	bucket.tokens--
+	return RateLimitResult{Allowed: true, Remaining: int(bucket.tokens), Reset: limit.resetAfter(bucket.tokens)}, nil
+}
+
+// sweep drops buckets untouched for longer than a full refill, at most once per minute
+func (s *memoryRateLimitStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < time.Minute {
+		return
+	}
+	s.lastSweep = now
+	for key, bucket := range s.buckets {
+		if now.Sub(bucket.updated) > bucket.per {
+			delete(s.buckets, key)
+		}
+	}
+}
+
+// redisTokenBucket atomically refills and takes from a bucket stored as a hash.
+// Token counts are returned as strings since Redis truncates Lua numbers.
+var redisTokenBucket = redis.NewScript(`
+local capacity = tonumber(ARGV[1])
+local rate = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local state = redis.call("HMGET", KEYS[1], "tokens", "updated")
+local tokens = tonumber(state[1]) or capacity
+local updated = tonumber(state[2]) or now
+tokens = math.min(capacity, tokens + math.max(0, now - updated) * rate)
+local allowed = 0
+if tokens >= 1 then
+  tokens = tokens - 1
+  allowed = 1
+end
+redis.call("HSET", KEYS[1], "tokens", tostring(tokens), "updated", now)
+redis.call("PEXPIRE", KEYS[1], math.ceil(capacity / rate))
+return {allowed, tostring(tokens)}
+`)
+
+// redisRateLimitStore shares buckets between API instances through Redis
+type redisRateLimitStore struct {
+	client *redis.Client
+}
+
+// Take removes a token from the bucket for key using redisTokenBucket
+func (s *redisRateLimitStore) Take(ctx context.Context, key string, limit RateLimit) (RateLimitResult, error) {
+	// Times are in milliseconds on the Redis side
+	rate := limit.refillRate() * float64(time.Millisecond)
+	reply, err := redisTokenBucket.Run(ctx, s.client, []string{"ratelimit:" + key},
+		limit.Requests, rate, time.Now().UnixMilli()).Slice()
+	if err != nil {
+		return RateLimitResult{}, err
+	}
+	if len(reply) != 2 {
+		return RateLimitResult{}, fmt.Errorf("unexpected rate limit reply %v", reply)
+	}
+
+	allowed, _ := reply[0].(int64)
+	tokens, err := strconv.ParseFloat(fmt.Sprint(reply[1]), 64)
+	if err != nil {
+		return RateLimitResult{}, err
+	}
+
+	if allowed != 1 {
+		wait := time.Duration((1-tokens)/rate) * time.Millisecond
+		return RateLimitResult{Allowed: false, Remaining: 0, RetryAfter: wait, Reset: limit.resetAfter(tokens)}, nil
+	}
+	return RateLimitResult{Allowed: true, Remaining: int(tokens), Reset: limit.resetAfter(tokens)}, nil
+}
+
+// rateLimitKeyFunc derives the bucket key for a request; an empty key skips limiting
+type rateLimitKeyFunc func(c *gin.Context) string
+
+// trustedProxies are the comma-separated IPs or CIDRs of TRUSTED_PROXIES, the
+// load balancers whose X-Forwarded-For header names the client. None are
+// trusted by default: otherwise any client could send a new X-Forwarded-For
+// with each request, and with it get a fresh per-IP rate limit bucket.
+var trustedProxies = envList("TRUSTED_PROXIES")
+
+// envList reads a comma-separated list from the environment
+func envList(name string) []string {
+	var list []string
+	for _, item := range strings.Split(os.Getenv(name), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// trustProxies makes c.ClientIP() trust X-Forwarded-For from trustedProxies
+// alone, and from no one if they are invalid
+func trustProxies(r *gin.Engine) {
+	if err := r.SetTrustedProxies(trustedProxies); err != nil {
+		log.Printf("Ignoring TRUSTED_PROXIES: %v", err)
+		r.SetTrustedProxies(nil)
+	}
+}
+
+// clientIPKey limits requests per client IP: the peer's, or the one the
+// trusted proxy in front of it forwards
+func clientIPKey(c *gin.Context) string {
+	return c.ClientIP()
+}
+
+// maxPeekedBody bounds how much of a request body is read to find the user identifier
+const maxPeekedBody = 1 << 20
+
+// userIdentifierKey limits response submissions per survey and user_identifier.
+// The body is read and then restored so the handler can still bind it.
+func userIdentifierKey(c *gin.Context) string {
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxPeekedBody))
+	c.Request.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body), c.Request.Body))
+	if err != nil {
+		return ""
+	}
+
+	var req CreateResponseRequest
+	if err := json.Unmarshal(body, &req); err != nil || req.SurveyResponse.UserIdentifier == "" {
+		return ""
+	}
+	return c.Param("id") + ":" + req.SurveyResponse.UserIdentifier
+}
+
+// setRateLimitHeaders reports the most restrictive limit applied to the request
+// as X-RateLimit-Limit, X-RateLimit-Remaining and X-RateLimit-Reset (seconds)
+func setRateLimitHeaders(c *gin.Context, limit RateLimit, result RateLimitResult) {
+	if remaining, ok := c.Get("ratelimit_remaining"); ok && remaining.(int) <= result.Remaining {
+		return
+	}
+	c.Set("ratelimit_remaining", result.Remaining)
+
+	c.Header("X-RateLimit-Limit", strconv.Itoa(limit.Requests))
+	c.Header("X-RateLimit-Remaining", strconv.Itoa(result.Remaining))
+	c.Header("X-RateLimit-Reset", strconv.Itoa(int(math.Ceil(result.Reset.Seconds()))))
+}
+
+// warnRateLimit tells a client approaching a limit with an X-RateLimit-Warning header
+// and emits a rate_limit.warning event, logged once as the threshold is crossed
+func warnRateLimit(c *gin.Context, name, key string, limit RateLimit, result RateLimitResult) {
+	c.Writer.Header().Add("X-RateLimit-Warning", fmt.Sprintf(
+		"%s limit nearly reached: %d of %d requests remaining", name, result.Remaining, limit.Requests))
+
+	trace.SpanFromContext(c.Request.Context()).AddEvent("rate_limit.warning", trace.WithAttributes(
+		attribute.String("rate_limit.name", name),
+		attribute.Int("rate_limit.limit", limit.Requests),
+		attribute.Int("rate_limit.remaining", result.Remaining),
+	))
+	if result.Remaining == limit.warnThreshold() {
+		log.Printf("Rate limit warning: %s for %s has %d of %d requests remaining", name, key, result.Remaining, limit.Requests)
+	}
+}
+
+// rateLimit returns middleware enforcing limit on the buckets named by keyFn.
+// Store failures are logged and the request is let through.
+func rateLimit(store RateLimitStore, name string, limit RateLimit, keyFn rateLimitKeyFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := keyFn(c)
+		if key == "" {
+			c.Next()
+			return
+		}
+
+		result, err := store.Take(c.Request.Context(), name+":"+key, limit)
+		if err != nil {
+			log.Printf("Rate limiter %s failed: %v", name, err)
+			c.Next()
+			return
+		}
+
+		setRateLimitHeaders(c, limit, result)
+
+		if result.Allowed && result.Remaining <= limit.warnThreshold() {
+			warnRateLimit(c, name, key, limit, result)
+		}
+
+		if !result.Allowed {
+			retryAfter := int(math.Ceil(result.RetryAfter.Seconds()))
+			c.Header("Retry-After", strconv.Itoa(retryAfter))
+			abortWithError(c, &APIError{
+				Status:  http.StatusTooManyRequests,
+				Code:    CodeRateLimited,
+				Message: "Too many requests",
+				Errors:  []string{fmt.Sprintf("Rate limit exceeded, retry in %d seconds", retryAfter)},
+			})
+			return
+		}
+
+		c.Next()
+	}
+}
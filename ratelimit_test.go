@@ -0,0 +1,210 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRateLimit(t *testing.T) {
+	limit, err := parseRateLimit("30/1m")
+	assert.NoError(t, err)
+	assert.Equal(t, RateLimit{Requests: 30, Per: time.Minute}, limit)
+
+	for _, invalid := range []string{"30", "abc/1m", "0/1m", "30/forever", "30/-1s"} {
+		_, err := parseRateLimit(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestMemoryRateLimitStoreRefills(t *testing.T) {
+	store := newMemoryRateLimitStore()
+	now := time.Now()
+	store.now = func() time.Time { return now }
+
+	limit := RateLimit{Requests: 2, Per: time.Minute}
+	ctx := context.Background()
+
+	result, _ := store.Take(ctx, "key", limit)
+	assert.True(t, result.Allowed)
+	assert.Equal(t, 1, result.Remaining)
+
+	result, _ = store.Take(ctx, "key", limit)
+	assert.True(t, result.Allowed)
+
+	result, _ = store.Take(ctx, "key", limit)
+	assert.False(t, result.Allowed)
+	assert.Equal(t, 30*time.Second, result.RetryAfter)
+
+	// Other keys have their own bucket
+	result, _ = store.Take(ctx, "other", limit)
+	assert.True(t, result.Allowed)
+
+	// One token is refilled every 30 seconds
+	now = now.Add(30 * time.Second)
+	result, _ = store.Take(ctx, "key", limit)
+	assert.True(t, result.Allowed)
+}
+
+func TestRateLimitMiddlewarePerUserIdentifier(t *testing.T) {
+	setupTestDB()
+	defer testDB.Close()
+
+	result, err := testDB.Exec("INSERT INTO surveys (title, description) VALUES (?, ?)", "Test Survey", "Test Description")
+	assert.NoError(t, err)
+	surveyID, _ := result.LastInsertId()
+
+	db = testDB
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(handleErrors())
+	router.POST("/api/surveys/:id/responses",
+		rateLimit(newMemoryRateLimitStore(), "submit_user", RateLimit{Requests: 1, Per: time.Hour}, userIdentifierKey),
+		createSurveyResponse)
+
+	submit := func(user string) *httptest.ResponseRecorder {
+		body := fmt.Sprintf(`{"survey_response":{"user_identifier":"%s","response_data":{"rating":"5"}}}`, user)
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", fmt.Sprintf("/api/surveys/%d/responses", surveyID), bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// The handler still sees the body after the limiter peeked at it
+	assert.Equal(t, http.StatusCreated, submit("testuser").Code)
+
+	w := submit("testuser")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "3600", w.Header().Get("Retry-After"))
+
+	assert.Equal(t, http.StatusCreated, submit("otheruser").Code)
+}
+
+func TestRateLimitHeaders(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(handleErrors())
+	store := newMemoryRateLimitStore()
+	router.GET("/limited",
+		rateLimit(store, "loose", RateLimit{Requests: 10, Per: time.Minute}, clientIPKey),
+		rateLimit(store, "strict", RateLimit{Requests: 2, Per: time.Minute}, clientIPKey),
+		func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	request := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/limited", nil)
+		req.RemoteAddr = "192.0.2.1:1234"
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// The most restrictive limiter wins
+	w := request()
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "2", w.Header().Get("X-RateLimit-Limit"))
+	assert.Equal(t, "1", w.Header().Get("X-RateLimit-Remaining"))
+	assert.Equal(t, "30", w.Header().Get("X-RateLimit-Reset"))
+
+	request()
+	w = request()
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "0", w.Header().Get("X-RateLimit-Remaining"))
+	assert.Equal(t, "60", w.Header().Get("X-RateLimit-Reset"))
+}
+
+func TestGetRateLimits(t *testing.T) {
+	setupTestDB()
+	defer testDB.Close()
+
+	router := setupTestRouter()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/limits", nil)
+	req.RemoteAddr = "192.0.2.1:1234"
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotEmpty(t, w.Header().Get("X-RateLimit-Limit"))
+
+	var response struct {
+		Data []RateLimitRule `json:"data"`
+	}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Len(t, response.Data, 9)
+	assert.Equal(t, "api", response.Data[0].Name)
+	assert.Equal(t, apiRateLimit.Requests, response.Data[0].Limit)
+}
+
+func TestRateLimitWarningHeader(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(handleErrors())
+	router.GET("/limited",
+		rateLimit(newMemoryRateLimitStore(), "test", RateLimit{Requests: 10, Per: time.Minute}, clientIPKey),
+		func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	request := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/limited", nil)
+		req.RemoteAddr = "192.0.2.1:1234"
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// With the default 80% ratio the warning starts with 2 requests remaining
+	for i := 0; i < 7; i++ {
+		assert.Empty(t, request().Header().Get("X-RateLimit-Warning"))
+	}
+	w := request()
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "test limit nearly reached: 2 of 10 requests remaining", w.Header().Get("X-RateLimit-Warning"))
+
+	request()
+	request()
+	assert.Equal(t, http.StatusTooManyRequests, request().Code)
+}
+
+func TestRateLimitIgnoresSpoofedForwardedFor(t *testing.T) {
+	setupTestDB()
+	defer testDB.Close()
+	testDB.Exec("INSERT INTO surveys (title, description) VALUES ('Poll', 'd')")
+
+	submit := func(router *gin.Engine, i int) int {
+		body := fmt.Sprintf(`{"survey_response":{"user_identifier":"user%d","response_data":{}}}`, i)
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/api/surveys/1/responses", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Forwarded-For", fmt.Sprintf("198.51.100.%d", i))
+		req.RemoteAddr = "192.0.2.1:1234"
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// A new X-Forwarded-For on every request doesn't reset the peer's bucket
+	router := setupTestRouter()
+	limited := 0
+	for i := 0; i <= submitIPRateLimit.Requests; i++ {
+		if submit(router, i) == http.StatusTooManyRequests {
+			limited++
+		}
+	}
+	assert.Equal(t, 1, limited)
+
+	// Behind a trusted proxy, each forwarded client has a bucket of its own
+	trustedProxies = []string{"192.0.2.0/24"}
+	defer func() { trustedProxies = nil }()
+	router = setupTestRouter()
+	for i := 100; i <= 100+submitIPRateLimit.Requests; i++ {
+		assert.NotEqual(t, http.StatusTooManyRequests, submit(router, i))
+	}
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// recodeOtherKey maps every answer value a recode map doesn't list
+const recodeOtherKey = "*"
+
+// maxRecodeLabel bounds the length of recoded values
+const maxRecodeLabel = 100
+
+// SaveRecodesRequest represents the request body for setting a question's recode maps
+type SaveRecodesRequest struct {
+	Recodes map[string]map[string]string `json:"recodes" binding:"required"`
+}
+
+// validateRecodes checks the recode maps of a question
+func validateRecodes(label string, recodes map[string]map[string]string) []string {
+	var errors []string
+	names := make([]string, 0, len(recodes))
+	for name := range recodes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if !questionIDPattern.MatchString(name) {
+			errors = append(errors, fmt.Sprintf("%s recode %q must be 1-64 letters, digits, underscores or dashes", label, name))
+			continue
+		}
+		if len(recodes[name]) == 0 {
+			errors = append(errors, fmt.Sprintf("%s recode %s must map at least one value", label, name))
+		}
+		for value, recoded := range recodes[name] {
+			if value == "" || recoded == "" || len(recoded) > maxRecodeLabel {
+				errors = append(errors, fmt.Sprintf("%s recode %s must map values to 1-%d characters", label, name, maxRecodeLabel))
+				break
+			}
+		}
+	}
+	return errors
+}
+
+// recodeValue maps one answer value with a question's recode map; values the
+// map doesn't list are kept unless it has a "*" entry
+func recodeValue(recode map[string]string, value string) string {
+	if recoded, ok := recode[value]; ok {
+		return recoded
+	}
+	if other, ok := recode[recodeOtherKey]; ok {
+		return other
+	}
+	return value
+}
+
+// recodeAnswer maps an answer with a recode map: single values become the
+// recoded text, lists are recoded item by item
+func recodeAnswer(recode map[string]string, answer interface{}) interface{} {
+	if items, ok := answer.([]interface{}); ok {
+		recoded := make([]interface{}, len(items))
+		for i, item := range items {
+			recoded[i] = recodeAnswer(recode, item)
+		}
+		return recoded
+	}
+	values := answerValues(answer)
+	if len(values) != 1 {
+		return answer
+	}
+	return recodeValue(recode, values[0])
+}
+
+// surveyRecodes returns the recode maps named name of the survey's questions by question ID
+func surveyRecodes(survey Survey, name string) map[string]map[string]string {
+	recodes := map[string]map[string]string{}
+	for _, q := range survey.Questions {
+		if recode, ok := q.Recodes[name]; ok {
+			recodes[q.ID] = recode
+		}
+	}
+	return recodes
+}
+
+// parseRecode reads ?recode=, the name of recode maps defined on the survey's
+// questions, returning the maps to apply by question ID
+func parseRecode(c *gin.Context, survey Survey) (map[string]map[string]string, []string) {
+	name := c.Query("recode")
+	if name == "" {
+		return nil, nil
+	}
+	recodes := surveyRecodes(survey, name)
+	if len(recodes) == 0 {
+		return nil, []string{fmt.Sprintf("Recode %s is not defined on any question", name)}
+	}
+	return recodes, nil
+}
+
+// recodeResults merges the counted values of recoded questions; results are
+// copied, as they may be shared through the cache
+func recodeResults(results SurveyResults, recodes map[string]map[string]string) SurveyResults {
+	questions := make([]QuestionResult, len(results.Questions))
+	for i, q := range results.Questions {
+		recode, ok := recodes[q.ID]
+		if !ok {
+			questions[i] = q
+			continue
+		}
+		values := map[string]int{}
+		for value, count := range q.Values {
+			values[recodeValue(recode, value)] += count
+		}
+		questions[i] = QuestionResult{ID: q.ID, Answered: q.Answered, Values: values}
+	}
+	results.Questions = questions
+	return results
+}
+
+// recodeResponseData recodes the answers of response_data
+func recodeResponseData(data json.RawMessage, recodes map[string]map[string]string) json.RawMessage {
+	var answers map[string]interface{}
+	if json.Unmarshal(data, &answers) != nil {
+		return data
+	}
+	for id, recode := range recodes {
+		if answer, ok := answers[id]; ok && answer != nil {
+			answers[id] = recodeAnswer(recode, answer)
+		}
+	}
+	recoded, err := json.Marshal(answers)
+	if err != nil {
+		return data
+	}
+	return recoded
+}
+
+// saveRecodes replaces the recode maps of a question. They only shape analytics,
+// so they can be changed on published surveys too.
+func saveRecodes(c *gin.Context) {
+	ctx := c.Request.Context()
+	surveyID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		abortWithError(c, errInvalidID("survey"))
+		return
+	}
+	var req SaveRecodesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		abortWithError(c, errInvalidRequest(err))
+		return
+	}
+	if errors := validateRecodes("Question", req.Recodes); len(errors) > 0 {
+		abortWithError(c, errValidation("Failed to save recodes", errors))
+		return
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		abortWithError(c, errInternal("Failed to save recodes", err))
+		return
+	}
+	defer tx.Rollback()
+
+	before, err := querySurvey(ctx, tx, surveyID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			abortWithError(c, errNotFound(CodeSurveyNotFound, "Survey not found"))
+			return
+		}
+		abortWithError(c, errInternal("Failed to fetch survey", err))
+		return
+	}
+	questions := append([]Question{}, before.Questions...)
+	found := false
+	for i := range questions {
+		if questions[i].ID == c.Param("question_id") {
+			questions[i].Recodes = req.Recodes
+			found = true
+		}
+	}
+	if !found {
+		abortWithError(c, errNotFound(CodeQuestionNotFound, "Question not found"))
+		return
+	}
+
+	data, _ := json.Marshal(questions)
+	if _, err := tx.ExecContext(ctx, "UPDATE surveys SET questions = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?", string(data), surveyID); err != nil {
+		abortWithError(c, errInternal("Failed to save recodes", err))
+		return
+	}
+	survey, err := querySurvey(ctx, tx, surveyID)
+	if err != nil {
+		abortWithError(c, errInternal("Failed to fetch updated survey", err))
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		abortWithError(c, errInternal("Failed to save recodes", err))
+		return
+	}
+
+	auditChange(c, "update", "survey", survey.ID, before, survey)
+	emitEvent(EventSurveyUpdated, survey.ID, survey)
+
+	c.JSON(http.StatusOK, APIResponse{
+		Status:  "success",
+		Message: "Recodes saved successfully",
+		Data:    survey,
+	})
+}
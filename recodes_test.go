@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecodeAnswer(t *testing.T) {
+	top2 := map[string]string{"4": "top_2_box", "5": "top_2_box", "*": "other"}
+	assert.Equal(t, "top_2_box", recodeAnswer(top2, float64(5)))
+	assert.Equal(t, "top_2_box", recodeAnswer(top2, "4"))
+	assert.Equal(t, "other", recodeAnswer(top2, float64(2)))
+	assert.Equal(t, []interface{}{"top_2_box", "other"}, recodeAnswer(top2, []interface{}{float64(4), float64(1)}))
+
+	// Without "*" unlisted values are kept
+	yes := map[string]string{"true": "Yes"}
+	assert.Equal(t, "Yes", recodeAnswer(yes, true))
+	assert.Equal(t, "false", recodeAnswer(yes, false))
+
+	results := SurveyResults{Questions: []QuestionResult{
+		{ID: "rating", Answered: 6, Values: map[string]int{"1": 1, "4": 2, "5": 3}},
+		{ID: "comment", Answered: 1, Values: map[string]int{"Nice": 1}},
+	}}
+	recoded := recodeResults(results, map[string]map[string]string{"rating": top2})
+	assert.Equal(t, map[string]int{"top_2_box": 5, "other": 1}, recoded.Questions[0].Values)
+	assert.Equal(t, 6, recoded.Questions[0].Answered)
+	assert.Equal(t, results.Questions[1], recoded.Questions[1])
+	// The original results, possibly cached, are left alone
+	assert.Equal(t, 3, results.Questions[0].Values["5"])
+
+	assert.Len(t, validateRecodes("Question 1", map[string]map[string]string{
+		"bad name": {"1": "x"},
+		"empty":    {},
+		"blank":    {"1": ""},
+	}), 3)
+}
+
+func TestSurveyRecodes(t *testing.T) {
+	setupTestDB()
+	defer testDB.Close()
+
+	result, err := testDB.Exec(`INSERT INTO surveys (title, description, questions) VALUES ('Recoded', 'd',
+		'[{"id":"rating","type":"rating","label":"How likely are you to come back?"},{"id":"comment","type":"text","label":"Why?"}]')`)
+	assert.NoError(t, err)
+	surveyID, _ := result.LastInsertId()
+	for i, rating := range []int{1, 4, 5, 5} {
+		testDB.Exec("INSERT INTO survey_responses (survey_id, user_identifier, response_data) VALUES (?, ?, ?)",
+			surveyID, fmt.Sprintf("user%d", i), fmt.Sprintf(`{"rating": %d, "comment": "ok"}`, rating))
+	}
+	router := setupTestRouter()
+	base := fmt.Sprintf("/api/surveys/%d", surveyID)
+
+	send := func(method, url, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, url, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := send("PUT", base+"/questions/rating/recodes", `{"recodes":{"top2box":{"4":"top","5":"top","*":"rest"}}}`)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, http.StatusNotFound, send("PUT", base+"/questions/missing/recodes", `{"recodes":{"x":{"1":"a"}}}`).Code)
+	assert.Equal(t, http.StatusUnprocessableEntity, send("PUT", base+"/questions/rating/recodes", `{"recodes":{"x":{}}}`).Code)
+
+	w = send("GET", base+"/results?recode=top2box", "")
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var results struct {
+		Data SurveyResults `json:"data"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &results)
+	for _, q := range results.Data.Questions {
+		switch q.ID {
+		case "rating":
+			assert.Equal(t, map[string]int{"top": 3, "rest": 1}, q.Values)
+		case "comment":
+			assert.Equal(t, map[string]int{"ok": 4}, q.Values)
+		}
+	}
+	assert.Equal(t, http.StatusBadRequest, send("GET", base+"/results?recode=nps", "").Code)
+
+	w = send("GET", base+"/questions/rating/answers?recode=top2box", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, []string{"rest", "top", "top", "top"}, csvColumn(t, w.Body.String(), 2))
+
+	w = send("GET", base+"/responses/export.ndjson?recode=top2box", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	scanner := bufio.NewScanner(strings.NewReader(w.Body.String()))
+	var ratings []interface{}
+	for scanner.Scan() {
+		var response SurveyResponse
+		json.Unmarshal(scanner.Bytes(), &response)
+		var data map[string]interface{}
+		json.Unmarshal(response.ResponseData, &data)
+		ratings = append(ratings, data["rating"])
+	}
+	assert.Equal(t, []interface{}{"rest", "top", "top", "top"}, ratings)
+
+	// Stored answers are not changed
+	w = send("GET", base+"/questions/rating/answers", "")
+	assert.Equal(t, []string{"1", "4", "5", "5"}, csvColumn(t, w.Body.String(), 2))
+}
+
+// csvColumn returns a column of a CSV export, without its header
+func csvColumn(t *testing.T, body string, column int) []string {
+	var values []string
+	for i, line := range strings.Split(strings.TrimSpace(body), "\n") {
+		fields := strings.Split(line, ",")
+		if i > 0 && assert.Greater(t, len(fields), column) {
+			values = append(values, fields[column])
+		}
+	}
+	return values
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Limits of reminder settings
+const (
+	maxReminderAfterDays = 365
+	maxRemindersAllowed  = 10
+)
+
+// ReminderSettings configure the reminders emailed to invitees who haven't responded
+type ReminderSettings struct {
+	// AfterDays is how many days after the invitation, and after each
+	// reminder, an invitee is reminded; null turns reminders off
+	AfterDays *int `json:"after_days"`
+	// MaxReminders caps the reminders of one invitation, 1 by default
+	MaxReminders int `json:"max_reminders"`
+}
+
+// SaveReminderSettingsRequest represents the request body for configuring reminders
+type SaveReminderSettingsRequest struct {
+	Reminders ReminderSettings `json:"reminders" binding:"required"`
+}
+
+// ScheduledReminder is the next reminder of an invitation
+type ScheduledReminder struct {
+	Invitation Invitation `json:"invitation"`
+	// Reminder counts the invitation's reminders, 1 for the first
+	Reminder int       `json:"reminder"`
+	RemindAt time.Time `json:"remind_at"`
+	// Due reminders go out with the next scheduler run
+	Due bool `json:"due"`
+}
+
+// ReminderPreview lists who will be reminded under a survey's settings
+type ReminderPreview struct {
+	Settings  ReminderSettings    `json:"settings"`
+	Reminders []ScheduledReminder `json:"reminders"`
+}
+
+// findReminderSettings loads the reminder settings of a survey
+func findReminderSettings(ctx context.Context, surveyID int) (ReminderSettings, error) {
+	var settings ReminderSettings
+	var afterDays sql.NullInt64
+	err := db.QueryRowContext(ctx, "SELECT reminder_after_days, max_reminders FROM surveys WHERE id = ?", surveyID).
+		Scan(&afterDays, &settings.MaxReminders)
+	settings.AfterDays = nullIntPtr(afterDays)
+	return settings, err
+}
+
+// scheduledReminders lists the next reminder of each invitation of survey
+// that will get one, soonest first. Nothing is scheduled while the survey
+// isn't published, nor past its closing time.
+func scheduledReminders(ctx context.Context, survey Survey, settings ReminderSettings, now time.Time) ([]ScheduledReminder, error) {
+	reminders := []ScheduledReminder{}
+	if settings.AfterDays == nil || survey.Status != SurveyStatusPublished {
+		return reminders, nil
+	}
+	rows, err := db.QueryContext(ctx, `
+		SELECT `+invitationColumns+` FROM survey_invitations
+		WHERE survey_id = ? AND status IN (?, ?) AND reminders_sent < ?
+	`, survey.ID, InvitationSent, InvitationOpened, settings.MaxReminders)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		inv, err := scanInvitation(rows)
+		if err != nil {
+			return nil, err
+		}
+		last := inv.SentAt
+		if inv.RemindedAt != nil {
+			last = inv.RemindedAt
+		}
+		if last == nil {
+			continue
+		}
+		remindAt := last.Add(time.Duration(*settings.AfterDays) * 24 * time.Hour).UTC()
+		if survey.ClosesAt != nil && !remindAt.Before(*survey.ClosesAt) {
+			continue
+		}
+		reminders = append(reminders, ScheduledReminder{
+			Invitation: inv,
+			Reminder:   inv.RemindersSent + 1,
+			RemindAt:   remindAt,
+			Due:        !remindAt.After(now),
+		})
+	}
+	sort.SliceStable(reminders, func(i, j int) bool { return reminders[i].RemindAt.Before(reminders[j].RemindAt) })
+	return reminders, rows.Err()
+}
+
+// getReminderPreview returns a survey's reminder settings and who they will remind, and when
+func getReminderPreview(c *gin.Context) {
+	ctx := c.Request.Context()
+	surveyID, _, ok := invitationParams(c)
+	if !ok {
+		return
+	}
+	survey, err := findSurvey(ctx, surveyID)
+	if err != nil {
+		abortWithError(c, errNotFound(CodeSurveyNotFound, "Survey not found"))
+		return
+	}
+	settings, err := findReminderSettings(ctx, surveyID)
+	if err != nil {
+		abortWithError(c, errInternal("Failed to fetch reminder settings", err))
+		return
+	}
+	reminders, err := scheduledReminders(ctx, survey, settings, time.Now())
+	if err != nil {
+		abortWithError(c, errInternal("Failed to preview reminders", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Status: "success",
+		Data:   ReminderPreview{Settings: settings, Reminders: reminders},
+	})
+}
+
+// saveReminderSettings configures when a survey's invitees are reminded
+func saveReminderSettings(c *gin.Context) {
+	ctx := c.Request.Context()
+	surveyID, _, ok := invitationParams(c)
+	if !ok {
+		return
+	}
+	var req SaveReminderSettingsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		abortWithError(c, errInvalidRequest(err))
+		return
+	}
+	before, err := findReminderSettings(ctx, surveyID)
+	if err == sql.ErrNoRows {
+		abortWithError(c, errNotFound(CodeSurveyNotFound, "Survey not found"))
+		return
+	}
+	if err != nil {
+		abortWithError(c, errInternal("Failed to fetch reminder settings", err))
+		return
+	}
+
+	// Validation
+	settings := req.Reminders
+	if settings.MaxReminders == 0 {
+		settings.MaxReminders = 1
+	}
+	var errors []string
+	if d := settings.AfterDays; d != nil && (*d < 1 || *d > maxReminderAfterDays) {
+		errors = append(errors, fmt.Sprintf("After days must be between 1 and %d", maxReminderAfterDays))
+	}
+	if settings.MaxReminders < 1 || settings.MaxReminders > maxRemindersAllowed {
+		errors = append(errors, fmt.Sprintf("Max reminders must be between 1 and %d", maxRemindersAllowed))
+	}
+	if len(errors) > 0 {
+		abortWithError(c, errValidation("Failed to save reminder settings", errors))
+		return
+	}
+
+	_, err = db.ExecContext(ctx, "UPDATE surveys SET reminder_after_days = ?, max_reminders = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?",
+		settings.AfterDays, settings.MaxReminders, surveyID)
+	if err != nil {
+		abortWithError(c, errInternal("Failed to save reminder settings", err))
+		return
+	}
+	auditChange(c, "update", "survey", surveyID, before, settings)
+
+	c.JSON(http.StatusOK, APIResponse{
+		Status:  "success",
+		Message: "Reminder settings saved successfully",
+		Data:    settings,
+	})
+}
+
+// reminderEmail is the reminder of an invitation, whose link carries token
+// and starts with baseURL
+func reminderEmail(survey Survey, baseURL, email, token string) Email {
+	invitation := invitationEmail(survey, baseURL, email, token)
+	return Email{To: email, Subject: "Reminder: " + survey.Title, Text: "We haven't heard from you yet.\n\n" + invitation.Text, ReplyTo: invitation.ReplyTo}
+}
+
+// sendDueReminders reminds a batch of the invitees of published surveys who
+// haven't responded, each with a new link; the link of the invitation keeps
+// working. A reminder is claimed before it is sent, so instances sharing the
+// database don't send it twice, and one that fails isn't retried.
+func sendDueReminders(ctx context.Context, now time.Time) (int, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT i.id, i.survey_id, i.email, i.reminders_sent FROM survey_invitations i
+		JOIN surveys s ON s.id = i.survey_id
+		WHERE i.status IN (?, ?) AND s.status = ? AND s.reminder_after_days IS NOT NULL
+			AND i.reminders_sent < s.max_reminders AND (s.closes_at IS NULL OR s.closes_at > ?)
+			AND datetime(COALESCE(i.reminded_at, i.sent_at), '+' || s.reminder_after_days || ' days') <= datetime(?)
+		ORDER BY i.id LIMIT ?
+	`, InvitationSent, InvitationOpened, SurveyStatusPublished, now.UTC(), now.UTC(), invitationBatchSize)
+	if err != nil {
+		return 0, err
+	}
+	type due struct {
+		id, surveyID, remindersSent int
+		email                       string
+	}
+	var batch []due
+	for rows.Next() {
+		var d due
+		if err := rows.Scan(&d.id, &d.surveyID, &d.email, &d.remindersSent); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		batch = append(batch, d)
+	}
+	rows.Close()
+
+	sent := 0
+	surveys := map[int]Survey{}
+	for _, d := range batch {
+		survey, ok := surveys[d.surveyID]
+		if !ok {
+			if survey, err = findSurvey(ctx, d.surveyID); err != nil {
+				return sent, err
+			}
+			surveys[d.surveyID] = survey
+		}
+
+		token := randomHex(16)
+		claimed, err := rowsAffected(ctx, db, `
+			UPDATE survey_invitations SET reminders_sent = reminders_sent + 1, reminded_at = ?, reminder_token_hash = ?
+			WHERE id = ? AND reminders_sent = ? AND status IN (?, ?)
+		`, now.UTC(), hashSurveyToken(token), d.id, d.remindersSent, InvitationSent, InvitationOpened)
+		if err != nil {
+			return sent, err
+		}
+		if claimed == 0 {
+			continue
+		}
+
+		if err := mailer.Send(ctx, reminderEmail(survey, organizationBaseURL(ctx, survey.OrganizationID), d.email, token)); err != nil {
+			log.Printf("Invitations: failed to send reminder %d of invitation %d: %v", d.remindersSent+1, d.id, err)
+			_, err = db.ExecContext(ctx, "UPDATE survey_invitations SET last_error = ? WHERE id = ?", err.Error(), d.id)
+			if err != nil {
+				return sent, err
+			}
+			continue
+		}
+		sent++
+	}
+	return sent, nil
+}
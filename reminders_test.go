@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInvitationReminders(t *testing.T) {
+	setupTestDB()
+	defer testDB.Close()
+	router := setupTestRouter()
+	defer func(m Mailer) { mailer = m }(mailer)
+	sent := &recordingMailer{}
+	mailer = sent
+
+	testDB.Exec("INSERT INTO surveys (title, description, status) VALUES ('Onboarding', 'd', 'published')")
+	testDB.Exec(`INSERT INTO survey_invitations (survey_id, email, status, sent_at) VALUES
+		(1, 'ana@example.com', 'sent', datetime('now', '-3 days')),
+		(1, 'ben@example.com', 'opened', datetime('now', '-1 days')),
+		(1, 'cy@example.com', 'responded', datetime('now', '-5 days')),
+		(1, 'dee@example.com', 'pending', NULL)`)
+
+	send := func(method, url, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, url, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		return w
+	}
+	preview := func() ReminderPreview {
+		var response struct {
+			Data ReminderPreview `json:"data"`
+		}
+		w := send("GET", "/api/surveys/1/invitations/reminders", "")
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		json.Unmarshal(w.Body.Bytes(), &response)
+		return response.Data
+	}
+	invitation := func(id int) Invitation {
+		inv, err := scanInvitation(testDB.QueryRow("SELECT "+invitationColumns+" FROM survey_invitations WHERE id = ?", id))
+		assert.NoError(t, err)
+		return inv
+	}
+	ctx := context.Background()
+
+	// Reminders are off until configured
+	p := preview()
+	assert.Nil(t, p.Settings.AfterDays)
+	assert.Equal(t, 1, p.Settings.MaxReminders)
+	assert.Empty(t, p.Reminders)
+	n, err := sendDueReminders(ctx, time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, 0, n)
+
+	w := send("PUT", "/api/surveys/1/invitations/reminders", `{"reminders":{"after_days":0,"max_reminders":11}}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Contains(t, w.Body.String(), "After days must be between 1 and 365")
+	assert.Contains(t, w.Body.String(), "Max reminders must be between 1 and 10")
+	assert.Equal(t, http.StatusNotFound, send("PUT", "/api/surveys/99/invitations/reminders", `{"reminders":{"after_days":2}}`).Code)
+	assert.Equal(t, http.StatusNotFound, send("GET", "/api/surveys/99/invitations/reminders", "").Code)
+	w = send("PUT", "/api/surveys/1/invitations/reminders", `{"reminders":{"after_days":2,"max_reminders":2}}`)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	// Only invitees who were sent an invitation and haven't answered are reminded, soonest first
+	p = preview()
+	assert.Equal(t, 2, *p.Settings.AfterDays)
+	if assert.Len(t, p.Reminders, 2) {
+		assert.Equal(t, "ana@example.com", p.Reminders[0].Invitation.Email)
+		assert.True(t, p.Reminders[0].Due)
+		assert.Equal(t, 1, p.Reminders[0].Reminder)
+		assert.Equal(t, "ben@example.com", p.Reminders[1].Invitation.Email)
+		assert.False(t, p.Reminders[1].Due)
+		assert.WithinDuration(t, time.Now().Add(24*time.Hour), p.Reminders[1].RemindAt, time.Minute)
+	}
+
+	now := time.Now()
+	n, err = sendDueReminders(ctx, now)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+	if !assert.Len(t, sent.sent, 1) {
+		return
+	}
+	assert.Equal(t, "ana@example.com", sent.sent[0].To)
+	assert.Equal(t, "Reminder: Onboarding", sent.sent[0].Subject)
+	assert.Contains(t, sent.sent[0].Text, "We haven't heard from you yet.")
+	ana := invitation(1)
+	assert.Equal(t, 1, ana.RemindersSent)
+	assert.NotNil(t, ana.RemindedAt)
+	assert.Equal(t, InvitationSent, ana.Status)
+	n, _ = sendDueReminders(ctx, now)
+	assert.Equal(t, 0, n)
+
+	// The reminder's link works alongside the invitation's
+	testDB.Exec("UPDATE survey_invitations SET token_hash = ? WHERE id = 1", hashSurveyToken(strings.Repeat("a", 32)))
+	reminderToken := invitationLink.FindStringSubmatch(sent.sent[0].Text)[1]
+	for _, token := range []string{strings.Repeat("a", 32), reminderToken} {
+		inv, err := findInvitationByToken(ctx, token)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, inv.ID)
+	}
+
+	// Reminders repeat every after_days, up to max_reminders
+	n, _ = sendDueReminders(ctx, now.Add(2*24*time.Hour+time.Minute))
+	assert.Equal(t, 2, n)
+	n, _ = sendDueReminders(ctx, now.Add(5*24*time.Hour))
+	assert.Equal(t, 1, n)
+	assert.Equal(t, 2, invitation(1).RemindersSent)
+	assert.Equal(t, 2, invitation(2).RemindersSent)
+	assert.Empty(t, preview().Reminders)
+	n, _ = sendDueReminders(ctx, now.Add(30*24*time.Hour))
+	assert.Equal(t, 0, n)
+
+	// Resending an invitation starts its reminders over
+	assert.Equal(t, http.StatusOK, send("POST", "/api/surveys/1/invitations/1/resend", "").Code)
+	ana = invitation(1)
+	assert.Equal(t, 0, ana.RemindersSent)
+	assert.Nil(t, ana.RemindedAt)
+	_, err = findInvitationByToken(ctx, reminderToken)
+	assert.Error(t, err)
+
+	// Failed reminders are counted and not retried
+	sendPendingInvitations(ctx, time.Now())
+	sent.err = errors.New("connection refused")
+	n, err = sendDueReminders(ctx, time.Now().Add(3*24*time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, n)
+	ana = invitation(1)
+	assert.Equal(t, 1, ana.RemindersSent)
+	assert.Equal(t, "connection refused", ana.LastError)
+
+	// Closed surveys send no reminders
+	testDB.Exec("UPDATE surveys SET closes_at = datetime('now', '-1 minutes')")
+	assert.Empty(t, preview().Reminders)
+	sent.err = nil
+	n, _ = sendDueReminders(ctx, time.Now().Add(30*24*time.Hour))
+	assert.Equal(t, 0, n)
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"sort"
+)
+
+// Policies resolving an edit made to an older version of a response, such as
+// one an offline client uploads after the response was changed on the server
+const (
+	// ConflictReject refuses the edit with 409 ANSWER_CONFLICT, the default
+	ConflictReject = "reject"
+	// ConflictServerWins discards the edit, keeping the response as it is
+	ConflictServerWins = "server_wins"
+	// ConflictClientWins applies the edit as is, replacing every answer
+	ConflictClientWins = "client_wins"
+	// ConflictMerge applies the answers the edit changed that the server
+	// didn't, keeping the server's answer where both changed it
+	ConflictMerge = "merge"
+)
+
+// conflictPolicies are the values accepted as conflict_policy
+var conflictPolicies = []string{ConflictReject, ConflictServerWins, ConflictClientWins, ConflictMerge}
+
+// ResponseConflict reports how an edit made to an older version of a response
+// was resolved
+type ResponseConflict struct {
+	Policy        string `json:"policy"`
+	BaseVersion   int    `json:"base_version"`
+	ServerVersion int    `json:"server_version"`
+	// Applied is false when the edit was discarded or rejected
+	Applied bool `json:"applied"`
+	// Answers are the answers the edit and the server both changed, differently
+	Answers []AnswerConflict `json:"answers"`
+}
+
+// AnswerConflict is an answer changed both by an edit and on the server since
+// the edit's base version. Missing answers are null.
+type AnswerConflict struct {
+	QuestionID string          `json:"question_id"`
+	Base       json.RawMessage `json:"base"`
+	Server     json.RawMessage `json:"server"`
+	Client     json.RawMessage `json:"client"`
+	// Kept is whose answer the response now has: server or client
+	Kept string `json:"kept,omitempty"`
+}
+
+// UpdateResponseMeta is the meta of an update resolving a conflict
+type UpdateResponseMeta struct {
+	Conflict *ResponseConflict `json:"conflict,omitempty"`
+}
+
+// findRevisionData loads the answers of a response at a version that has
+// since been replaced; ok is false when its revision is gone, e.g. erased
+func findRevisionData(ctx context.Context, q queryer, responseID, version int) (data json.RawMessage, ok bool, err error) {
+	var stored []byte
+	err = q.QueryRowContext(ctx, `
+		SELECT response_data FROM response_revisions
+		WHERE response_id = ?
+		ORDER BY id
+		LIMIT 1 OFFSET ?
+	`, responseID, version-1).Scan(&stored)
+	if err == sql.ErrNoRows {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	data, err = openResponseData(stored)
+	return data, err == nil, err
+}
+
+// answerMap reads response_data as answers by question ID; data that isn't an
+// object has no answers
+func answerMap(data json.RawMessage) map[string]json.RawMessage {
+	answers := map[string]json.RawMessage{}
+	json.Unmarshal(data, &answers)
+	return answers
+}
+
+// sameAnswer compares two answers, a missing answer being null
+func sameAnswer(a, b json.RawMessage) bool {
+	if isJSONNull(a) || isJSONNull(b) {
+		return isJSONNull(a) && isJSONNull(b)
+	}
+	return jsonEqual(a, b)
+}
+
+// nullAnswer returns a missing answer as null, for conflict reports
+func nullAnswer(a json.RawMessage) json.RawMessage {
+	if len(a) == 0 {
+		return json.RawMessage("null")
+	}
+	return a
+}
+
+// resolveEdit works out the answers of a response after an edit of client
+// made from base, when the server has since changed it to server. It returns
+// the conflicting answers and whether the edit applies at all.
+func resolveEdit(policy string, base, server, client json.RawMessage) (json.RawMessage, []AnswerConflict, bool) {
+	baseAnswers, serverAnswers, clientAnswers := answerMap(base), answerMap(server), answerMap(client)
+	questionIDs := map[string]bool{}
+	for _, answers := range []map[string]json.RawMessage{baseAnswers, serverAnswers, clientAnswers} {
+		for questionID := range answers {
+			questionIDs[questionID] = true
+		}
+	}
+
+	merged := map[string]json.RawMessage{}
+	conflicts := []AnswerConflict{}
+	for questionID := range questionIDs {
+		b, s, k := baseAnswers[questionID], serverAnswers[questionID], clientAnswers[questionID]
+		answer := s
+		switch {
+		case sameAnswer(b, k) || sameAnswer(s, k):
+		case sameAnswer(b, s):
+			answer = k
+		default:
+			conflict := AnswerConflict{QuestionID: questionID, Base: nullAnswer(b), Server: nullAnswer(s), Client: nullAnswer(k)}
+			switch policy {
+			case ConflictServerWins, ConflictMerge:
+				conflict.Kept = "server"
+			case ConflictClientWins:
+				conflict.Kept = "client"
+			}
+			conflicts = append(conflicts, conflict)
+		}
+		if !isJSONNull(answer) {
+			merged[questionID] = answer
+		}
+	}
+	sort.Slice(conflicts, func(i, j int) bool { return conflicts[i].QuestionID < conflicts[j].QuestionID })
+
+	switch policy {
+	case ConflictClientWins:
+		return client, conflicts, true
+	case ConflictMerge:
+		data, _ := json.Marshal(merged)
+		return data, conflicts, true
+	}
+	return server, conflicts, false
+}
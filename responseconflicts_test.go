@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpdateResponseConflicts(t *testing.T) {
+	setupTestDB()
+	defer testDB.Close()
+	router := setupTestRouter()
+
+	testDB.Exec("INSERT INTO surveys (title, description) VALUES ('Field visit', 'd')")
+	testDB.Exec(`INSERT INTO survey_responses (survey_id, user_identifier, response_data) VALUES (1, 'phone1', '{"site": "A", "count": 3, "notes": "ok"}')`)
+
+	type body struct {
+		Code    string          `json:"code"`
+		Errors  []string        `json:"errors"`
+		Data    json.RawMessage `json:"data"`
+		Message string          `json:"message"`
+		Meta    struct {
+			Conflict *ResponseConflict `json:"conflict"`
+		} `json:"meta"`
+	}
+	patch := func(update string) (int, body) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("PATCH", "/api/surveys/1/responses/1", strings.NewReader(`{"survey_response":`+update+`}`))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		var b body
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &b), w.Body.String())
+		return w.Code, b
+	}
+	current := func() SurveyResponse {
+		response, err := queryResponse(context.Background(), testDB, 1)
+		require.NoError(t, err)
+		return response
+	}
+	assert.Equal(t, 1, current().Version)
+
+	// Edits to the current version apply, and bump it
+	code, _ := patch(`{"version": 1, "response_data": {"site": "A", "count": 4, "notes": "ok"}}`)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, 2, current().Version)
+
+	// An offline edit of version 1 conflicts on count, changed to 4 since
+	offline := `{"site": "B", "count": 5, "notes": "ok"}`
+	code, b := patch(`{"version": 1, "response_data": ` + offline + `}`)
+	assert.Equal(t, http.StatusConflict, code)
+	assert.Equal(t, CodeAnswerConflict, b.Code)
+	assert.Equal(t, []string{"Answer to count was changed by another edit"}, b.Errors)
+	assert.Equal(t, 2, current().Version)
+
+	code, b = patch(`{"version": 1, "conflict_policy": "server_wins", "response_data": ` + offline + `}`)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "Survey response unchanged", b.Message)
+	require.NotNil(t, b.Meta.Conflict)
+	assert.False(t, b.Meta.Conflict.Applied)
+	assert.Equal(t, 2, current().Version)
+
+	// Merging applies site, which only the client changed, and keeps the server's count
+	code, b = patch(`{"version": 1, "conflict_policy": "merge", "response_data": ` + offline + `}`)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, &ResponseConflict{
+		Policy: ConflictMerge, BaseVersion: 1, ServerVersion: 2, Applied: true,
+		Answers: []AnswerConflict{{QuestionID: "count", Base: json.RawMessage("3"), Server: json.RawMessage("4"), Client: json.RawMessage("5"), Kept: "server"}},
+	}, b.Meta.Conflict)
+	assert.JSONEq(t, `{"site": "B", "count": 4, "notes": "ok"}`, string(current().ResponseData))
+	assert.Equal(t, 3, current().Version)
+
+	code, _ = patch(`{"version": 2, "conflict_policy": "client_wins", "response_data": {"site": "C", "count": 6}}`)
+	assert.Equal(t, http.StatusOK, code)
+	assert.JSONEq(t, `{"site": "C", "count": 6}`, string(current().ResponseData))
+
+	// Without a version, edits overwrite as before
+	code, _ = patch(`{"response_data": {"site": "D"}}`)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, 5, current().Version)
+
+	code, b = patch(`{"version": 9, "conflict_policy": "newest", "response_data": {}}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, code)
+	assert.Equal(t, []string{"Conflict policy must be one of reject, server_wins, client_wins, merge", "Version must be between 1 and 5"}, b.Errors)
+}
+
+func TestResolveEdit(t *testing.T) {
+	base := json.RawMessage(`{"a": 1, "b": [1, 2], "c": "x", "d": true}`)
+	server := json.RawMessage(`{"a": 2, "b": [1, 2], "d": true, "e": "new"}`)
+	client := json.RawMessage(`{"a": 1, "b": [2], "c": "y", "e": "other"}`)
+
+	merged, conflicts, applied := resolveEdit(ConflictMerge, base, server, client)
+	assert.True(t, applied)
+	// The server changed a, the client b and d; c, removed on the server and
+	// changed by the client, and e, added by both, conflict
+	assert.JSONEq(t, `{"a": 2, "b": [2], "e": "new"}`, string(merged))
+	var ids []string
+	for _, conflict := range conflicts {
+		ids = append(ids, conflict.QuestionID)
+		assert.Equal(t, "server", conflict.Kept)
+	}
+	assert.Equal(t, []string{"c", "e"}, ids)
+	assert.Equal(t, json.RawMessage("null"), conflicts[0].Server)
+
+	data, _, applied := resolveEdit(ConflictClientWins, base, server, client)
+	assert.True(t, applied)
+	assert.Equal(t, client, data)
+	data, _, applied = resolveEdit(ConflictServerWins, base, server, client)
+	assert.False(t, applied)
+	assert.Equal(t, server, data)
+
+	// Without the base, every difference is a conflict
+	_, conflicts, _ = resolveEdit(ConflictMerge, nil, server, server)
+	assert.Empty(t, conflicts)
+	_, conflicts, _ = resolveEdit(ConflictMerge, nil, json.RawMessage(`{"a": 1}`), json.RawMessage(`{"a": 2}`))
+	assert.Len(t, conflicts, 1)
+}
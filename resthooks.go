@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// restHookSampleSize caps the items returned by the sample endpoint
+const restHookSampleSize = 3
+
+// SubscribeRESTHookRequest is the body no-code tools send to subscribe, as
+// REST Hooks define it
+type SubscribeRESTHookRequest struct {
+	TargetURL string `json:"target_url" binding:"required"`
+	Event     string `json:"event" binding:"required"`
+	SurveyID  *int   `json:"survey_id"`
+}
+
+// subscribeRESTHook subscribes a no-code tool's target URL to one event.
+// Deliveries are the event's data, such as the new response, without the
+// webhook envelope.
+func subscribeRESTHook(c *gin.Context) {
+	ctx := c.Request.Context()
+	var req SubscribeRESTHookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		abortWithError(c, errInvalidRequest(err))
+		return
+	}
+
+	sub := WebhookSubscription{URL: req.TargetURL, Events: []string{req.Event}, SurveyID: req.SurveyID, RESTHook: true}
+	if errors := validateWebhook(ctx, sub); len(errors) > 0 {
+		abortWithError(c, errValidation("Failed to subscribe", errors))
+		return
+	}
+	sub, err := insertWebhook(ctx, sub)
+	if err != nil {
+		abortWithError(c, errInternal("Failed to subscribe", err))
+		return
+	}
+
+	snapshot := sub
+	snapshot.Secret = ""
+	auditChange(c, "create", "webhook_subscription", sub.ID, nil, snapshot)
+
+	c.JSON(http.StatusCreated, APIResponse{
+		Status:  "success",
+		Message: "Subscribed successfully",
+		Data:    sub,
+	})
+}
+
+// restHookSample returns up to restHookSampleSize recent items shaped like the
+// deliveries of event, newest first, for no-code tools to map fields from
+func restHookSample(ctx context.Context, event string, surveyID *int) ([]interface{}, error) {
+	items := []interface{}{}
+
+	if isResponseEvent(event) {
+		where, args := "sr.deleted_at IS NULL", []interface{}{}
+		if event == EventResponseDeleted {
+			where = "sr.deleted_at IS NOT NULL"
+		}
+		if surveyID != nil {
+			where += " AND sr.survey_id = ?"
+			args = append(args, *surveyID)
+		}
+		rows, err := db.QueryContext(ctx, `
+			SELECT `+responseColumns+` FROM `+responsesFrom+`
+			WHERE `+where+` ORDER BY sr.id DESC LIMIT ?
+		`, append(args, restHookSampleSize)...)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			response, err := scanResponse(rows)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, response)
+		}
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+		if len(items) == 0 {
+			// An example lets tools map fields before the first response arrives
+			now := time.Now().UTC().Truncate(time.Second)
+			id := 0
+			if surveyID != nil {
+				id = *surveyID
+			}
+			items = append(items, SurveyResponse{
+				ID: 1, SurveyID: id, UserIdentifier: "user@example.com",
+				ResponseData: json.RawMessage(`{"rating": 5}`), CreatedAt: now, UpdatedAt: now,
+			})
+		}
+		return items, nil
+	}
+
+	query, args := "SELECT id FROM surveys ORDER BY id DESC LIMIT ?", []interface{}{restHookSampleSize}
+	if surveyID != nil {
+		query, args = "SELECT id FROM surveys WHERE id = ?", []interface{}{*surveyID}
+	}
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	for _, id := range ids {
+		survey, err := findSurvey(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, survey)
+	}
+	return items, nil
+}
+
+// getRESTHookSample returns sample deliveries of ?event=, limited to ?survey_id= when set
+func getRESTHookSample(c *gin.Context) {
+	event := c.Query("event")
+	var errors []string
+	if !validEvent(event) {
+		errors = append(errors, "Event must be a webhook event, such as response.created")
+	}
+	var surveyID *int
+	if s := c.Query("survey_id"); s != "" {
+		id, err := strconv.Atoi(s)
+		if err != nil {
+			errors = append(errors, "Survey ID is invalid")
+		}
+		surveyID = &id
+	}
+	if len(errors) > 0 {
+		abortWithError(c, errInvalidQuery(errors))
+		return
+	}
+
+	items, err := restHookSample(c.Request.Context(), event, surveyID)
+	if err != nil {
+		abortWithError(c, errInternal("Failed to fetch sample", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Status: "success",
+		Data:   items,
+	})
+}
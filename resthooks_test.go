@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRESTHooks(t *testing.T) {
+	setupTestDB()
+	defer testDB.Close()
+	router := setupTestRouter()
+	testDB.Exec("INSERT INTO surveys (title, description, status) VALUES ('Pulse', 'd', 'published'), ('Other', 'd', 'published')")
+
+	var mu sync.Mutex
+	var bodies []map[string]interface{}
+	status := http.StatusOK
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		mu.Lock()
+		defer mu.Unlock()
+		bodies = append(bodies, body)
+		w.WriteHeader(status)
+	}))
+	defer target.Close()
+
+	send := func(method, url, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, adminRequest(method, url, []byte(body)))
+		return w
+	}
+	sample := func(url string) []map[string]interface{} {
+		w := send("GET", url, "")
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var response struct {
+			Data []map[string]interface{} `json:"data"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		return response.Data
+	}
+
+	assert.Equal(t, http.StatusUnprocessableEntity, send("POST", "/api/admin/hooks", `{"target_url":"https://example.com","event":"response.exploded"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, send("POST", "/api/admin/hooks", `{"event":"response.created"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, send("GET", "/api/admin/hooks/sample", "").Code)
+
+	// Before any response, the sample is an example
+	items := sample("/api/admin/hooks/sample?event=response.created&survey_id=1")
+	if assert.Len(t, items, 1) {
+		assert.Equal(t, "user@example.com", items[0]["user_identifier"])
+		assert.Equal(t, float64(1), items[0]["survey_id"])
+	}
+
+	w := send("POST", "/api/admin/hooks", fmt.Sprintf(`{"target_url":%q,"event":"response.created","survey_id":1}`, target.URL))
+	assert.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var subscribed struct {
+		Data WebhookSubscription `json:"data"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &subscribed)
+	assert.True(t, subscribed.Data.RESTHook)
+	assert.Equal(t, []string{EventResponseCreated}, subscribed.Data.Events)
+
+	// Deliveries are the new response itself
+	for i, surveyID := range []int{1, 2, 1} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", fmt.Sprintf("/api/surveys/%d/responses", surveyID),
+			strings.NewReader(fmt.Sprintf(`{"survey_response":{"user_identifier":"user%d","response_data":{"rating":"4"}}}`, i)))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	}
+	runDueJobs(context.Background(), time.Now())
+	mu.Lock()
+	if assert.Len(t, bodies, 2) {
+		assert.Equal(t, float64(1), bodies[0]["survey_id"])
+		assert.Contains(t, bodies[0], "response_data")
+		assert.NotContains(t, bodies[0], "event")
+	}
+	mu.Unlock()
+
+	items = sample("/api/admin/hooks/sample?event=response.created&survey_id=1")
+	if assert.Len(t, items, 2) {
+		assert.Equal(t, float64(3), items[0]["id"])
+	}
+	items = sample("/api/admin/hooks/sample?event=survey.published")
+	if assert.Len(t, items, 2) {
+		assert.Equal(t, "Other", items[0]["title"])
+	}
+
+	// A 410 Gone answer unsubscribes the hook
+	status = http.StatusGone
+	emitEvent(EventResponseCreated, 1, SurveyResponse{ID: 1, SurveyID: 1})
+	runDueJobs(context.Background(), time.Now())
+	var hooks int
+	testDB.QueryRow("SELECT COUNT(*) FROM webhook_subscriptions").Scan(&hooks)
+	assert.Equal(t, 0, hooks)
+
+	// Unsubscribing deletes the subscription
+	w = send("POST", "/api/admin/hooks", fmt.Sprintf(`{"target_url":%q,"event":"survey.closed"}`, target.URL))
+	json.Unmarshal(w.Body.Bytes(), &subscribed)
+	assert.Equal(t, http.StatusOK, send("DELETE", fmt.Sprintf("/api/admin/hooks/%d", subscribed.Data.ID), "").Code)
+	assert.Equal(t, http.StatusNotFound, send("DELETE", fmt.Sprintf("/api/admin/hooks/%d", subscribed.Data.ID), "").Code)
+}
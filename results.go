@@ -0,0 +1,297 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SurveyResults aggregates a survey's responses question by question
+type SurveyResults struct {
+	SurveyID       int              `json:"survey_id"`
+	ResponsesCount int              `json:"responses_count"`
+	Questions      []QuestionResult `json:"questions"`
+	ComputedAt     time.Time        `json:"computed_at"`
+	// Formulas are the survey's saved formulas evaluated over these results
+	Formulas []FormulaResult `json:"formulas,omitempty"`
+}
+
+// QuestionResult counts the answers to one response_data key. Lists count
+// each of their items; objects only count towards Answered.
+type QuestionResult struct {
+	ID       string         `json:"id"`
+	Answered int            `json:"answered"`
+	Values   map[string]int `json:"values"`
+}
+
+// Results are served from cache for resultsCacheTTL, then served stale while a
+// background refresh runs for up to resultsStaleTTL more
+var (
+	resultsCacheTTL = envDuration("RESULTS_CACHE_TTL", 5*time.Second)
+	resultsStaleTTL = envDuration("RESULTS_STALE_TTL", time.Minute)
+)
+
+// answerValues returns the values an answer counts towards
+func answerValues(answer interface{}) []string {
+	switch v := answer.(type) {
+	case string:
+		return []string{v}
+	case float64:
+		return []string{strconv.FormatFloat(v, 'f', -1, 64)}
+	case bool:
+		return []string{strconv.FormatBool(v)}
+	case []interface{}:
+		var values []string
+		for _, item := range v {
+			values = append(values, answerValues(item)...)
+		}
+		return values
+	}
+	return nil
+}
+
+// resultsTally counts the answers of responses per question
+type resultsTally struct {
+	responses int
+	questions map[string]*QuestionResult
+}
+
+func newResultsTally() *resultsTally {
+	return &resultsTally{questions: map[string]*QuestionResult{}}
+}
+
+// add counts one response; answers is nil when its response_data isn't an object
+func (t *resultsTally) add(answers map[string]interface{}) {
+	t.responses++
+	for id, answer := range answers {
+		if answer == nil {
+			continue
+		}
+		q := t.questions[id]
+		if q == nil {
+			q = &QuestionResult{ID: id, Values: map[string]int{}}
+			t.questions[id] = q
+		}
+		q.Answered++
+		for _, value := range answerValues(answer) {
+			q.Values[value]++
+		}
+	}
+}
+
+// results returns the counts with the questions sorted by ID
+func (t *resultsTally) results(surveyID int) SurveyResults {
+	results := SurveyResults{SurveyID: surveyID, ResponsesCount: t.responses, Questions: []QuestionResult{}}
+	for _, q := range t.questions {
+		results.Questions = append(results.Questions, *q)
+	}
+	sort.Slice(results.Questions, func(i, j int) bool {
+		return results.Questions[i].ID < results.Questions[j].ID
+	})
+	results.ComputedAt = time.Now().UTC()
+	return results
+}
+
+// analyzedResponses calls fn with the answers of each live, non-rejected
+// response matching q; answers is nil when response_data isn't an object
+func analyzedResponses(ctx context.Context, q responseListQuery, fn func(answers map[string]interface{})) error {
+	where, args := q.where()
+	rows, err := db.QueryContext(ctx, `
+		SELECT sr.response_data FROM survey_responses sr
+		WHERE `+where+` AND sr.moderation_status != ?
+	`, append(args, ModerationRejected)...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var data []byte
+		if err := rows.Scan(&data); err != nil {
+			return err
+		}
+		data, err := openResponseData(data)
+		if err != nil {
+			return err
+		}
+		var answers map[string]interface{}
+		if json.Unmarshal(data, &answers) != nil {
+			answers = nil
+		}
+		fn(answers)
+	}
+	return rows.Err()
+}
+
+// computeSurveyResults aggregates the live, non-rejected responses matching q
+func computeSurveyResults(ctx context.Context, q responseListQuery) (SurveyResults, error) {
+	tally := newResultsTally()
+	if err := analyzedResponses(ctx, q, tally.add); err != nil {
+		return SurveyResults{SurveyID: q.SurveyID, Questions: []QuestionResult{}}, err
+	}
+	return tally.results(q.SurveyID), nil
+}
+
+// cachedResults is a cache entry; refreshing is set while a background refresh runs
+type cachedResults struct {
+	results    SurveyResults
+	refreshing bool
+}
+
+// resultsCache caches survey results per survey with stale-while-revalidate
+type resultsCache struct {
+	mu      sync.Mutex
+	entries map[int]*cachedResults
+	ttl     time.Duration
+	stale   time.Duration
+	compute func(surveyID int) (SurveyResults, error)
+}
+
+// newResultsCache returns an empty cache computing unfiltered results with computeSurveyResults
+func newResultsCache(ttl, stale time.Duration) *resultsCache {
+	return &resultsCache{
+		entries: map[int]*cachedResults{},
+		ttl:     ttl,
+		stale:   stale,
+		compute: func(surveyID int) (SurveyResults, error) {
+			ctx, cancel := context.WithTimeout(context.Background(), analyticsTimeout)
+			defer cancel()
+			return computeSurveyResults(ctx, responseListQuery{SurveyID: surveyID})
+		},
+	}
+}
+
+// get returns the survey's results and whether they came from cache ("hit"),
+// from cache while being refreshed ("stale") or were just computed ("miss")
+func (rc *resultsCache) get(surveyID int, now time.Time) (SurveyResults, string, error) {
+	rc.mu.Lock()
+	entry := rc.entries[surveyID]
+	if entry != nil {
+		age := now.Sub(entry.results.ComputedAt)
+		if age < rc.ttl {
+			rc.mu.Unlock()
+			return entry.results, "hit", nil
+		}
+		if age < rc.ttl+rc.stale {
+			if !entry.refreshing {
+				entry.refreshing = true
+				go rc.refresh(surveyID)
+			}
+			rc.mu.Unlock()
+			return entry.results, "stale", nil
+		}
+	}
+	rc.mu.Unlock()
+
+	results, err := rc.compute(surveyID)
+	if err != nil {
+		return results, "miss", err
+	}
+	rc.mu.Lock()
+	rc.entries[surveyID] = &cachedResults{results: results}
+	rc.mu.Unlock()
+	return results, "miss", nil
+}
+
+// has reports whether the survey's results can be served from cache, fresh or stale
+func (rc *resultsCache) has(surveyID int, now time.Time) bool {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	entry := rc.entries[surveyID]
+	return entry != nil && now.Sub(entry.results.ComputedAt) < rc.ttl+rc.stale
+}
+
+// refresh recomputes a survey's results in the background
+func (rc *resultsCache) refresh(surveyID int) {
+	results, err := rc.compute(surveyID)
+
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	entry := rc.entries[surveyID]
+	if err != nil {
+		log.Printf("Results: failed to refresh survey %d: %v", surveyID, err)
+		if entry != nil {
+			entry.refreshing = false
+		}
+		return
+	}
+	rc.entries[surveyID] = &cachedResults{results: results}
+}
+
+// getSurveyResults returns a handler serving per-question aggregates, recoded
+// with ?recode=; unfiltered results come from cache, filtered ones are computed
+// on every request
+func getSurveyResults(cache *resultsCache) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		surveyID := c.Param("id")
+		id, err := strconv.Atoi(surveyID)
+		if err != nil {
+			abortWithError(c, errInvalidID("survey"))
+			return
+		}
+
+		survey, err := findSurvey(ctx, id)
+		if err != nil {
+			abortWithError(c, errNotFound(CodeSurveyNotFound, "Survey not found"))
+			return
+		}
+
+		q, errors := parseAnalyticsQuery(c, id)
+		recodes, recodeErrors := parseRecode(c, survey)
+		errors = append(errors, recodeErrors...)
+		if len(errors) > 0 {
+			abortWithError(c, errInvalidQuery(errors))
+			return
+		}
+
+		// Cached results were already checked when they were computed
+		now := time.Now()
+		ctx, cancel := context.WithTimeout(ctx, analyticsTimeout)
+		defer cancel()
+		if (q.filtered() || !cache.has(id, now)) && !checkAnalyticsCost(ctx, c, q) {
+			return
+		}
+
+		var results SurveyResults
+		if q.filtered() {
+			results, err = computeSurveyResults(ctx, q)
+		} else {
+			var status string
+			results, status, err = cache.get(id, now)
+			c.Header("X-Cache", status)
+			c.Header("Cache-Control", fmt.Sprintf("max-age=%d, stale-while-revalidate=%d",
+				int(cache.ttl.Seconds()), int(cache.stale.Seconds())))
+		}
+		if err != nil {
+			analyticsQueryFailed(ctx, c, err)
+			return
+		}
+		formulas, err := listFormulas(ctx, id)
+		if err != nil {
+			abortWithError(c, errInternal("Failed to fetch formulas", err))
+			return
+		}
+		// Formulas refer to the answers as given, so they're evaluated before recoding
+		if len(formulas) > 0 {
+			results.Formulas = evaluateFormulas(survey, results, formulas)
+		}
+		// Recodes apply to the counts, so recoded results share the cache
+		if recodes != nil {
+			results = recodeResults(results, recodes)
+		}
+
+		c.JSON(http.StatusOK, APIResponse{
+			Status: "success",
+			Data:   results,
+		})
+	}
+}
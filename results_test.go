@@ -0,0 +1,108 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetSurveyResults(t *testing.T) {
+	setupTestDB()
+	defer testDB.Close()
+
+	result, err := testDB.Exec("INSERT INTO surveys (title, description) VALUES (?, ?)", "Test Survey", "Test Description")
+	assert.NoError(t, err)
+	surveyID, _ := result.LastInsertId()
+
+	for _, data := range []string{
+		`{"rating":5,"colors":["red","blue"],"comment":"Nice"}`,
+		`{"rating":3,"colors":["red"],"comment":null}`,
+		`{"rating":5,"details":{"age":30}}`,
+	} {
+		_, err = testDB.Exec("INSERT INTO survey_responses (survey_id, user_identifier, response_data) VALUES (?, 'user', ?)", surveyID, data)
+		assert.NoError(t, err)
+	}
+	_, err = testDB.Exec("INSERT INTO survey_responses (survey_id, user_identifier, response_data, moderation_status) VALUES (?, 'spam', '{\"rating\":1}', 'rejected')", surveyID)
+	assert.NoError(t, err)
+
+	router := setupTestRouter()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", fmt.Sprintf("/api/surveys/%d/results", surveyID), nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "miss", w.Header().Get("X-Cache"))
+
+	var response struct {
+		Data SurveyResults `json:"data"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	results := response.Data
+	assert.Equal(t, 3, results.ResponsesCount)
+	assert.False(t, results.ComputedAt.IsZero())
+	assert.Equal(t, []QuestionResult{
+		{ID: "colors", Answered: 2, Values: map[string]int{"red": 2, "blue": 1}},
+		{ID: "comment", Answered: 1, Values: map[string]int{"Nice": 1}},
+		{ID: "details", Answered: 1, Values: map[string]int{}},
+		{ID: "rating", Answered: 3, Values: map[string]int{"5": 2, "3": 1}},
+	}, results.Questions)
+
+	// A second request within the TTL is served from cache
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, "hit", w.Header().Get("X-Cache"))
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/surveys/999/results", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestResultsCacheStaleWhileRevalidate(t *testing.T) {
+	var mu sync.Mutex
+	computed := 0
+	release := make(chan struct{})
+
+	cache := newResultsCache(time.Second, time.Minute)
+	cache.compute = func(surveyID int) (SurveyResults, error) {
+		mu.Lock()
+		computed++
+		n := computed
+		mu.Unlock()
+		if n == 2 {
+			<-release
+		}
+		return SurveyResults{SurveyID: surveyID, ResponsesCount: n, ComputedAt: time.Now()}, nil
+	}
+
+	now := time.Now()
+	results, status, err := cache.get(1, now)
+	assert.NoError(t, err)
+	assert.Equal(t, "miss", status)
+	assert.Equal(t, 1, results.ResponsesCount)
+
+	// Past the TTL the old results are served while one refresh runs
+	results, status, _ = cache.get(1, now.Add(2*time.Second))
+	assert.Equal(t, "stale", status)
+	assert.Equal(t, 1, results.ResponsesCount)
+	_, status, _ = cache.get(1, now.Add(2*time.Second))
+	assert.Equal(t, "stale", status)
+
+	close(release)
+	assert.Eventually(t, func() bool {
+		results, status, _ = cache.get(1, time.Now())
+		return status == "hit" && results.ResponsesCount == 2
+	}, time.Second, time.Millisecond)
+
+	// Beyond the stale window results are recomputed before responding
+	results, status, _ = cache.get(1, time.Now().Add(2*time.Minute))
+	assert.Equal(t, "miss", status)
+	assert.Equal(t, 3, results.ResponsesCount)
+}
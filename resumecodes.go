@@ -0,0 +1,163 @@
+package main
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"math/big"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// resumeCodeAlphabet leaves out characters that are easily confused when read
+// aloud or typed: 0/O, 1/I/L and U
+const resumeCodeAlphabet = "23456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// resumeCodeLength is the number of characters of a resume code, shown as two groups of three
+const resumeCodeLength = 6
+
+// resumeCodeTTL is how long a resume code can be redeemed
+var resumeCodeTTL = envDuration("RESUME_CODE_TTL", 24*time.Hour)
+
+// Limits of resume code redemption, which is what an attacker guessing codes calls
+var (
+	resumeIPRateLimit     = envRateLimit("RATE_LIMIT_RESUME_IP", RateLimit{Requests: 10, Per: time.Hour})
+	resumeSurveyRateLimit = envRateLimit("RATE_LIMIT_RESUME_SURVEY", RateLimit{Requests: 300, Per: time.Hour})
+)
+
+// ResumeCode lets a respondent continue a partial response on another device
+type ResumeCode struct {
+	Code      string    `json:"code"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// RedeemResumeCodeRequest represents the request body for redeeming a resume code
+type RedeemResumeCodeRequest struct {
+	Resume struct {
+		Code string `json:"code" binding:"required"`
+	} `json:"resume" binding:"required"`
+}
+
+// newResumeCode returns a random code in its stored form, without the separator
+func newResumeCode() string {
+	code := make([]byte, resumeCodeLength)
+	max := big.NewInt(int64(len(resumeCodeAlphabet)))
+	for i := range code {
+		n, _ := rand.Int(rand.Reader, max)
+		code[i] = resumeCodeAlphabet[n.Int64()]
+	}
+	return string(code)
+}
+
+// formatResumeCode splits a stored code into groups for display, e.g. K7M-4QX
+func formatResumeCode(code string) string {
+	return code[:resumeCodeLength/2] + "-" + code[resumeCodeLength/2:]
+}
+
+// normalizeResumeCode turns a typed code into its stored form, ignoring case, spaces and dashes
+func normalizeResumeCode(code string) string {
+	return strings.NewReplacer("-", "", " ", "").Replace(strings.ToUpper(code))
+}
+
+// surveyKey limits requests per survey
+func surveyKey(c *gin.Context) string {
+	return c.Param("id")
+}
+
+// createResumeCode issues a resume code for a partial response, replacing any previous one
+func createResumeCode(c *gin.Context) {
+	ctx := c.Request.Context()
+	surveyID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		abortWithError(c, errInvalidID("survey"))
+		return
+	}
+
+	partial, err := findPartialResponse(ctx, surveyID, c.Param("partial_id"))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			abortWithError(c, errNotFound(CodePartialResponseNotFound, "Partial response not found"))
+			return
+		}
+		abortWithError(c, errInternal("Failed to fetch partial response", err))
+		return
+	}
+	if partial.ResponseID != nil {
+		abortWithError(c, &APIError{
+			Status:  http.StatusConflict,
+			Code:    CodeAlreadySubmitted,
+			Message: "Partial response was already submitted",
+		})
+		return
+	}
+
+	// Codes are unique per survey; retry the rare collision with a fresh code
+	expiresAt := time.Now().UTC().Add(resumeCodeTTL).Truncate(time.Second)
+	for attempt := 1; ; attempt++ {
+		code := newResumeCode()
+		_, err := db.ExecContext(ctx, "UPDATE partial_responses SET resume_code = ?, resume_code_expires_at = ? WHERE id = ?",
+			code, expiresAt, partial.ID)
+		if err == nil {
+			c.JSON(http.StatusCreated, APIResponse{
+				Status:  "success",
+				Message: "Resume code created",
+				Data:    ResumeCode{Code: formatResumeCode(code), ExpiresAt: expiresAt},
+			})
+			return
+		}
+		if attempt == 5 {
+			abortWithError(c, errInternal("Failed to create resume code", err))
+			return
+		}
+	}
+}
+
+// redeemResumeCode returns the partial response a resume code was issued for.
+// Codes are single use: redeeming one hands over the partial response's ID.
+func redeemResumeCode(c *gin.Context) {
+	ctx := c.Request.Context()
+	surveyID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		abortWithError(c, errInvalidID("survey"))
+		return
+	}
+
+	var req RedeemResumeCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		abortWithError(c, errInvalidRequest(err))
+		return
+	}
+
+	code := normalizeResumeCode(req.Resume.Code)
+	partial, err := scanPartialResponse(db.QueryRowContext(ctx, `
+		SELECT `+partialColumns+` FROM partial_responses
+		WHERE survey_id = ? AND resume_code = ? AND resume_code_expires_at > ? AND response_id IS NULL
+	`, surveyID, code, time.Now().UTC()))
+	if err == nil {
+		var result sql.Result
+		result, err = db.ExecContext(ctx, "UPDATE partial_responses SET resume_code = NULL, resume_code_expires_at = NULL WHERE id = ? AND resume_code = ?", partial.ID, code)
+		if err == nil {
+			if n, _ := result.RowsAffected(); n == 0 {
+				// Redeemed concurrently
+				err = sql.ErrNoRows
+			}
+		}
+	}
+	if err == sql.ErrNoRows {
+		abortWithError(c, errNotFound(CodeResumeCodeInvalid, "Resume code is invalid or expired"))
+		return
+	}
+	if err != nil {
+		abortWithError(c, errInternal("Failed to redeem resume code", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Status:  "success",
+		Message: "Resume code redeemed",
+		Data:    partial,
+	})
+}
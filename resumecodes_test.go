@@ -0,0 +1,96 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResumeCodeFormat(t *testing.T) {
+	code := newResumeCode()
+	assert.Len(t, code, resumeCodeLength)
+	for _, r := range code {
+		assert.True(t, strings.ContainsRune(resumeCodeAlphabet, r))
+	}
+	assert.Equal(t, "K7M-4QX", formatResumeCode("K7M4QX"))
+	assert.Equal(t, "K7M4QX", normalizeResumeCode(" k7m-4qx"))
+}
+
+func TestResumeCodes(t *testing.T) {
+	setupTestDB()
+	defer testDB.Close()
+
+	result, err := testDB.Exec("INSERT INTO surveys (title, description) VALUES ('Resume', 'd'), ('Other', 'd')")
+	assert.NoError(t, err)
+	otherID, _ := result.LastInsertId()
+	surveyID := otherID - 1
+
+	router := setupTestRouter()
+
+	partial, _ := partialRequest(router, "POST", fmt.Sprintf("/api/surveys/%d/partial-responses", surveyID), "")
+	partialRequest(router, "PATCH", fmt.Sprintf("/api/surveys/%d/partial-responses/%s", surveyID, partial.ID), `{"partial_response":{"answers":{"rating":"4"}}}`)
+
+	issue := func() ResumeCode {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", fmt.Sprintf("/api/surveys/%d/partial-responses/%s/resume-code", surveyID, partial.ID), nil)
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+		var response struct {
+			Data ResumeCode `json:"data"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		return response.Data
+	}
+	redeem := func(survey int64, code string) (PartialResponse, int) {
+		resumed, w := partialRequest(router, "POST", fmt.Sprintf("/api/surveys/%d/partial-responses/resume", survey), fmt.Sprintf(`{"resume":{"code":%q}}`, code))
+		return resumed, w.Code
+	}
+
+	code := issue()
+	assert.Regexp(t, `^[A-Z2-9]{3}-[A-Z2-9]{3}$`, code.Code)
+	assert.WithinDuration(t, time.Now().Add(resumeCodeTTL), code.ExpiresAt, time.Minute)
+
+	// Codes belong to their survey
+	_, status := redeem(otherID, code.Code)
+	assert.Equal(t, http.StatusNotFound, status)
+
+	// Redeeming hands over the partial response, once
+	resumed, status := redeem(surveyID, strings.ToLower(code.Code))
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, partial.ID, resumed.ID)
+	assert.JSONEq(t, `"4"`, string(resumed.Answers["rating"]))
+	_, status = redeem(surveyID, code.Code)
+	assert.Equal(t, http.StatusNotFound, status)
+
+	// Expired codes are rejected
+	code = issue()
+	_, err = testDB.Exec("UPDATE partial_responses SET resume_code_expires_at = ? WHERE id = ?", time.Now().Add(-time.Minute).UTC(), partial.ID)
+	assert.NoError(t, err)
+	_, status = redeem(surveyID, code.Code)
+	assert.Equal(t, http.StatusNotFound, status)
+}
+
+func TestResumeCodeRateLimit(t *testing.T) {
+	setupTestDB()
+	defer testDB.Close()
+
+	result, err := testDB.Exec("INSERT INTO surveys (title, description) VALUES ('Resume', 'd')")
+	assert.NoError(t, err)
+	surveyID, _ := result.LastInsertId()
+
+	router := setupTestRouter()
+
+	// Guessing is cut off after a few attempts per client
+	var status int
+	for i := 0; i <= resumeIPRateLimit.Requests; i++ {
+		_, w := partialRequest(router, "POST", fmt.Sprintf("/api/surveys/%d/partial-responses/resume", surveyID), `{"resume":{"code":"AAA-AAA"}}`)
+		status = w.Code
+	}
+	assert.Equal(t, http.StatusTooManyRequests, status)
+}
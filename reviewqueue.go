@@ -0,0 +1,403 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Reasons an item is in the review queue
+const (
+	ReviewLowConfidence     = "low_confidence"
+	ReviewBotSuspected      = "bot_suspected"
+	ReviewModerationPending = "moderation_pending"
+	ReviewValidationWarning = "validation_warning"
+)
+
+// Kinds of review queue items
+const (
+	ReviewItemResponse = "response"
+	ReviewItemScan     = "scan"
+)
+
+// botScoreThreshold is the bot score from which a response is suspected to be automated
+var botScoreThreshold = envRatio("BOT_SCORE_THRESHOLD", 0.8)
+
+// ReviewItem is a response or scanned paper response that needs a reviewer
+type ReviewItem struct {
+	// ID combines the kind and ID of the item, e.g. response-12 or scan-3
+	ID        string           `json:"id"`
+	Kind      string           `json:"kind"`
+	Reasons   []string         `json:"reasons"`
+	CreatedAt time.Time        `json:"created_at"`
+	Response  *SurveyResponse  `json:"response,omitempty"`
+	Scan      *ScannedResponse `json:"scan,omitempty"`
+}
+
+// responseReviewItem returns the review queue item of a response
+func responseReviewItem(response SurveyResponse) ReviewItem {
+	m := response.Metadata
+	reasons := []string{}
+	if m.BotScore != nil && *m.BotScore >= botScoreThreshold {
+		reasons = append(reasons, ReviewBotSuspected)
+	}
+	if m.ModerationStatus == ModerationPending {
+		reasons = append(reasons, ReviewModerationPending)
+	}
+	if len(m.Warnings) > 0 {
+		reasons = append(reasons, ReviewValidationWarning)
+	}
+	return ReviewItem{
+		ID:        fmt.Sprintf("%s-%d", ReviewItemResponse, response.ID),
+		Kind:      ReviewItemResponse,
+		Reasons:   reasons,
+		CreatedAt: response.CreatedAt,
+		Response:  &response,
+	}
+}
+
+// scanReviewItem returns the review queue item of a scanned response
+func scanReviewItem(scan ScannedResponse) ReviewItem {
+	reasons := []string{}
+	if len(scan.flagged()) > 0 {
+		reasons = append(reasons, ReviewLowConfidence)
+	}
+	return ReviewItem{
+		ID:        fmt.Sprintf("%s-%d", ReviewItemScan, scan.ID),
+		Kind:      ReviewItemScan,
+		Reasons:   reasons,
+		CreatedAt: scan.CreatedAt,
+		Scan:      &scan,
+	}
+}
+
+// surveyID returns the survey of the item's response or scan
+func (item ReviewItem) surveyID() int {
+	if item.Scan != nil {
+		return item.Scan.SurveyID
+	}
+	return item.Response.SurveyID
+}
+
+// applyCorrections overlays corrected answers on response data; a null correction removes the answer
+func applyCorrections(data json.RawMessage, corrections map[string]json.RawMessage) (json.RawMessage, error) {
+	answers := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &answers); err != nil {
+		return data, err
+	}
+	for questionID, value := range corrections {
+		if isJSONNull(value) {
+			delete(answers, questionID)
+		} else {
+			answers[questionID] = value
+		}
+	}
+	return json.Marshal(answers)
+}
+
+// getReviewQueue lists a survey's unreviewed scans and flagged responses, oldest first
+func getReviewQueue(c *gin.Context) {
+	ctx := c.Request.Context()
+	surveyID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		abortWithError(c, errInvalidID("survey"))
+		return
+	}
+
+	var errors []string
+	reason := c.Query("reason")
+	responseConditions := map[string]string{
+		ReviewBotSuspected:      "sr.bot_score >= ?",
+		ReviewModerationPending: "sr.moderation_status = ?",
+		ReviewValidationWarning: "sr.validation_warnings != ?",
+	}
+	if _, ok := responseConditions[reason]; !ok && reason != "" && reason != ReviewLowConfidence {
+		errors = append(errors, "Reason must be one of low_confidence, bot_suspected, moderation_pending or validation_warning")
+	}
+
+	limit := defaultPageSize
+	if value := c.Query("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > maxPageSize {
+			errors = append(errors, fmt.Sprintf("Limit must be between 1 and %d", maxPageSize))
+		}
+		limit = n
+	}
+
+	if len(errors) > 0 {
+		abortWithError(c, errInvalidQuery(errors))
+		return
+	}
+
+	var exists bool
+	err = db.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM surveys WHERE id = ?)", surveyID).Scan(&exists)
+	if err != nil || !exists {
+		abortWithError(c, errNotFound(CodeSurveyNotFound, "Survey not found"))
+		return
+	}
+
+	items := []ReviewItem{}
+	if reason == "" || reason == ReviewLowConfidence {
+		err = queryRows(ctx, func(row rowScanner) error {
+			scan, err := scanScannedResponse(row)
+			items = append(items, scanReviewItem(scan))
+			return err
+		}, `
+			SELECT `+scanColumns+` FROM scanned_responses
+			WHERE survey_id = ? AND status = ?
+			ORDER BY created_at, id
+			LIMIT ?
+		`, surveyID, ScanNeedsReview, limit)
+	}
+	if err == nil && reason != ReviewLowConfidence {
+		reasonArgs := map[string]interface{}{
+			ReviewBotSuspected:      botScoreThreshold,
+			ReviewModerationPending: ModerationPending,
+			ReviewValidationWarning: "[]",
+		}
+		var conditions []string
+		args := []interface{}{surveyID}
+		for _, r := range []string{ReviewBotSuspected, ReviewModerationPending, ReviewValidationWarning} {
+			if reason == "" || reason == r {
+				conditions = append(conditions, responseConditions[r])
+				args = append(args, reasonArgs[r])
+			}
+		}
+		err = queryRows(ctx, func(row rowScanner) error {
+			response, err := scanResponse(row)
+			items = append(items, responseReviewItem(response))
+			return err
+		}, `
+			SELECT `+responseColumns+`
+			FROM `+responsesFrom+`
+			WHERE sr.survey_id = ? AND sr.deleted_at IS NULL AND sr.reviewed_at IS NULL
+				AND (`+strings.Join(conditions, " OR ")+`)
+			ORDER BY sr.created_at, sr.id
+			LIMIT ?
+		`, append(args, limit)...)
+	}
+	if err != nil {
+		abortWithError(c, errInternal("Failed to fetch review queue", err))
+		return
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].CreatedAt.Before(items[j].CreatedAt)
+	})
+	if len(items) > limit {
+		items = items[:limit]
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Status: "success",
+		Data:   items,
+		Meta:   PageMeta{Limit: limit},
+	})
+}
+
+// queryRows runs a query and passes each row to scan
+func queryRows(ctx context.Context, scan func(rowScanner) error, query string, args ...interface{}) error {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		if err := scan(rows); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// pendingReviewItem loads the item of a review action, responding with the error
+// when it doesn't belong to the survey or was already reviewed
+func pendingReviewItem(c *gin.Context) (ReviewItem, bool) {
+	ctx := c.Request.Context()
+	surveyID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		abortWithError(c, errInvalidID("survey"))
+		return ReviewItem{}, false
+	}
+
+	kind, rawID, _ := strings.Cut(c.Param("item_id"), "-")
+	id, err := strconv.Atoi(rawID)
+	if err != nil || (kind != ReviewItemResponse && kind != ReviewItemScan) {
+		abortWithError(c, &APIError{
+			Status:  http.StatusBadRequest,
+			Code:    CodeInvalidID,
+			Message: "Invalid review item ID",
+			Errors:  []string{"Review item IDs look like response-12 or scan-3"},
+		})
+		return ReviewItem{}, false
+	}
+
+	var item ReviewItem
+	reviewed := false
+	if kind == ReviewItemScan {
+		var scan ScannedResponse
+		scan, err = findScannedResponse(ctx, id)
+		if err == nil && scan.SurveyID != surveyID {
+			err = sql.ErrNoRows
+		}
+		item, reviewed = scanReviewItem(scan), scan.Status != ScanNeedsReview
+	} else {
+		var response SurveyResponse
+		response, err = scanResponse(db.QueryRowContext(ctx, `
+			SELECT `+responseColumns+`
+			FROM `+responsesFrom+`
+			WHERE sr.id = ? AND sr.survey_id = ? AND sr.deleted_at IS NULL
+		`, id, surveyID))
+		item, reviewed = responseReviewItem(response), response.Metadata.ReviewedAt != nil
+	}
+
+	if err == sql.ErrNoRows {
+		abortWithError(c, errNotFound(CodeReviewItemNotFound, "Review item not found"))
+		return item, false
+	}
+	if err != nil {
+		abortWithError(c, errInternal("Failed to fetch review item", err))
+		return item, false
+	}
+	if reviewed {
+		abortWithError(c, &APIError{
+			Status:  http.StatusConflict,
+			Code:    CodeAlreadyReviewed,
+			Message: "Review item was already reviewed",
+			Data:    item,
+		})
+		return item, false
+	}
+	return item, true
+}
+
+// reviewResponse records a reviewer's decision on a response, applying corrections when given
+func reviewResponse(c *gin.Context, response SurveyResponse, moderationStatus string, corrections map[string]json.RawMessage) (SurveyResponse, error) {
+	ctx := c.Request.Context()
+	survey, err := findSurvey(ctx, response.SurveyID)
+	if err != nil {
+		return response, err
+	}
+	data, err := applyCorrections(response.ResponseData, corrections)
+	if err != nil {
+		return response, err
+	}
+	quality, err := checkQuality(ctx, survey, response.ID, data, response.Metadata.CompletionSeconds)
+	if err != nil {
+		return response, err
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return response, err
+	}
+	defer tx.Rollback()
+
+	if len(corrections) > 0 {
+		if err := saveRevision(ctx, tx, response); err != nil {
+			return response, err
+		}
+		_, err = tx.ExecContext(ctx, `
+			UPDATE survey_responses
+			SET response_data = ?, validation_warnings = ?, quality_flags = ?, answers_hash = ?,
+				version = version + 1, updated_at = CURRENT_TIMESTAMP
+			WHERE id = ?
+		`, string(sealResponseData(data)), warningsJSON(survey, data), quality.flagsJSON(), quality.AnswersHash, response.ID)
+		if err != nil {
+			return response, err
+		}
+	}
+	_, err = tx.ExecContext(ctx, "UPDATE survey_responses SET moderation_status = ?, reviewed_at = CURRENT_TIMESTAMP WHERE id = ?",
+		moderationStatus, response.ID)
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		return response, err
+	}
+
+	reviewed, err := queryResponse(ctx, db, response.ID)
+	if err != nil {
+		return response, err
+	}
+	auditChange(c, "update", "survey_response", response.ID, response, reviewed)
+	emitEvent(EventResponseUpdated, response.SurveyID, reviewed)
+	return reviewed, nil
+}
+
+// resolveReviewItem returns the handler of a review action: accept keeps the item
+// as it is, fix applies the reviewer's corrections first and reject discards it
+func resolveReviewItem(action string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		item, ok := pendingReviewItem(c)
+		if !ok {
+			return
+		}
+
+		var req ReviewRequest
+		if action == "fix" {
+			if err := c.ShouldBindJSON(&req); err != nil {
+				abortWithError(c, errInvalidRequest(err))
+				return
+			}
+			errors := []string{}
+			if len(req.Review.Corrections) == 0 {
+				errors = append(errors, "Corrections must not be empty")
+			}
+			if survey, err := findSurvey(ctx, item.surveyID()); err == nil {
+				errors = append(errors, validateAnswerKeys(survey, req.Review.Corrections)...)
+			}
+			if len(errors) > 0 {
+				abortWithError(c, errValidation("Failed to fix review item", errors))
+				return
+			}
+		}
+
+		var err error
+		switch {
+		case item.Scan != nil && action == "reject":
+			var scan ScannedResponse
+			scan, err = rejectScannedResponse(ctx, *item.Scan)
+			item.Scan = &scan
+		case item.Scan != nil:
+			var scan ScannedResponse
+			scan, err = finalizeScannedResponse(c, *item.Scan, req.Review.Corrections)
+			item.Scan = &scan
+		default:
+			status := ModerationApproved
+			if action == "reject" {
+				status = ModerationRejected
+			}
+			var response SurveyResponse
+			response, err = reviewResponse(c, *item.Response, status, req.Review.Corrections)
+			item.Response = &response
+		}
+		if err == sql.ErrNoRows {
+			abortWithError(c, &APIError{
+				Status:  http.StatusConflict,
+				Code:    CodeAlreadyReviewed,
+				Message: "Review item was already reviewed",
+			})
+			return
+		}
+		if err != nil {
+			abortWithError(c, errInternal("Failed to "+action+" review item", err))
+			return
+		}
+
+		c.JSON(http.StatusOK, APIResponse{
+			Status:  "success",
+			Message: "Review item resolved",
+			Data:    item,
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReviewQueue(t *testing.T) {
+	setupTestDB()
+	defer testDB.Close()
+
+	result, err := testDB.Exec(`INSERT INTO surveys (title, description, questions) VALUES ('Review', 'd', '[{"id":"rating","type":"rating","label":"Rating","required":true}]')`)
+	assert.NoError(t, err)
+	surveyID, _ := result.LastInsertId()
+
+	router := setupTestRouter()
+	serve := func(method, url string, body []byte) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, adminRequest(method, url, body))
+		return w
+	}
+	queue := func(query string) []ReviewItem {
+		w := serve("GET", fmt.Sprintf("/api/surveys/%d/review-queue%s", surveyID, query), nil)
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var response struct {
+			Data []ReviewItem `json:"data"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		return response.Data
+	}
+	resolve := func(itemID, action, body string) (ReviewItem, int) {
+		w := serve("POST", fmt.Sprintf("/api/surveys/%d/review-queue/%s/%s", surveyID, itemID, action), []byte(body))
+		var response struct {
+			Data ReviewItem `json:"data"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		return response.Data, w.Code
+	}
+	submit := func(user, data string) int {
+		_, w := partialRequest(router, "POST", fmt.Sprintf("/api/surveys/%d/responses", surveyID),
+			fmt.Sprintf(`{"survey_response":{"user_identifier":%q,"response_data":%s}}`, user, data))
+		assert.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+		var response struct {
+			Data SurveyResponse `json:"data"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		return response.Data.ID
+	}
+
+	// Invalid answers are accepted with warnings
+	invalid := submit("user1", `{"rating":9}`)
+	bot := submit("user2", `{"rating":5}`)
+	pending := submit("user3", `{"rating":3}`)
+	submit("user4", `{"rating":4}`)
+	_, err = testDB.Exec("UPDATE survey_responses SET bot_score = 0.97 WHERE id = ?", bot)
+	assert.NoError(t, err)
+	_, err = testDB.Exec("UPDATE survey_responses SET moderation_status = 'pending' WHERE id = ?", pending)
+	assert.NoError(t, err)
+	_, err = testDB.Exec("UPDATE survey_responses SET created_at = datetime(created_at, '-' || (10 - id) || ' minutes')")
+	assert.NoError(t, err)
+	w := serve("POST", fmt.Sprintf("/api/admin/surveys/%d/scanned-responses", surveyID),
+		[]byte(`{"scanned_response":{"user_identifier":"paper-1","fields":{"rating":{"value":2,"confidence":0.3}}}}`))
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	items := queue("")
+	if assert.Len(t, items, 4) {
+		assert.Equal(t, fmt.Sprintf("response-%d", invalid), items[0].ID)
+		assert.Equal(t, []string{ReviewValidationWarning}, items[0].Reasons)
+		assert.Equal(t, []string{"Answer to rating must be a whole number from 1 to 5"}, items[0].Response.Metadata.Warnings)
+		assert.Equal(t, []string{ReviewBotSuspected}, items[1].Reasons)
+		assert.Equal(t, []string{ReviewModerationPending}, items[2].Reasons)
+		assert.Equal(t, ReviewItemScan, items[3].Kind)
+		assert.Equal(t, []string{ReviewLowConfidence}, items[3].Reasons)
+	}
+	assert.Len(t, queue("?reason=bot_suspected"), 1)
+	assert.Len(t, queue("?reason=low_confidence"), 1)
+
+	// Fixing corrects the answers and keeps the previous ones as a revision
+	_, status := resolve(items[0].ID, "fix", `{"review":{"corrections":{}}}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, status)
+	fixed, status := resolve(items[0].ID, "fix", `{"review":{"corrections":{"rating":4}}}`)
+	assert.Equal(t, http.StatusOK, status)
+	assert.JSONEq(t, `{"rating":4}`, string(fixed.Response.ResponseData))
+	assert.Empty(t, fixed.Response.Metadata.Warnings)
+	assert.NotNil(t, fixed.Response.Metadata.ReviewedAt)
+	var revisions int
+	testDB.QueryRow("SELECT COUNT(*) FROM response_revisions WHERE response_id = ?", invalid).Scan(&revisions)
+	assert.Equal(t, 1, revisions)
+
+	accepted, status := resolve(items[1].ID, "accept", "")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, ModerationApproved, accepted.Response.Metadata.ModerationStatus)
+	rejected, _ := resolve(items[2].ID, "reject", "")
+	assert.Equal(t, ModerationRejected, rejected.Response.Metadata.ModerationStatus)
+	scan, _ := resolve(items[3].ID, "accept", "")
+	assert.Equal(t, ScanFinalized, scan.Scan.Status)
+
+	assert.Empty(t, queue(""))
+	_, status = resolve(items[1].ID, "accept", "")
+	assert.Equal(t, http.StatusConflict, status)
+
+	// Editing a reviewed response checks its answers again
+	_, w = partialRequest(router, "PATCH", fmt.Sprintf("/api/surveys/%d/responses/%d", surveyID, bot), `{"survey_response":{"response_data":{"rating":0}}}`)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	if items = queue(""); assert.Len(t, items, 1) {
+		assert.Equal(t, []string{ReviewBotSuspected, ReviewValidationWarning}, items[0].Reasons)
+	}
+}
+
+func TestReviewQueueErrors(t *testing.T) {
+	setupTestDB()
+	defer testDB.Close()
+
+	surveyID, ids := insertTestResponses(t, 1)
+	router := setupTestRouter()
+
+	for _, tc := range []struct {
+		req    *http.Request
+		status int
+	}{
+		{httptest.NewRequest("GET", fmt.Sprintf("/api/surveys/%d/review-queue", surveyID), nil), http.StatusForbidden},
+		{adminRequest("GET", fmt.Sprintf("/api/surveys/%d/review-queue?reason=typo", surveyID), nil), http.StatusBadRequest},
+		{adminRequest("GET", "/api/surveys/999/review-queue", nil), http.StatusNotFound},
+		{adminRequest("POST", fmt.Sprintf("/api/surveys/%d/review-queue/%d/accept", surveyID, ids[0]), nil), http.StatusBadRequest},
+		{adminRequest("POST", fmt.Sprintf("/api/surveys/%d/review-queue/scan-%d/accept", surveyID, ids[0]), nil), http.StatusNotFound},
+		{adminRequest("POST", fmt.Sprintf("/api/surveys/999/review-queue/response-%d/accept", ids[0]), nil), http.StatusNotFound},
+	} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, tc.req)
+		assert.Equal(t, tc.status, w.Code, tc.req.URL.String())
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ResponseRevision is a previous version of a response's answers
+type ResponseRevision struct {
+	ID           int             `json:"id"`
+	ResponseID   int             `json:"response_id"`
+	Revision     int             `json:"revision"`
+	ResponseData json.RawMessage `json:"response_data"`
+	// ReplacedAt is when an edit replaced these answers
+	ReplacedAt time.Time `json:"replaced_at"`
+}
+
+// saveRevision stores the answers of response before they are overwritten
+func saveRevision(ctx context.Context, exec execer, response SurveyResponse) error {
+	_, err := exec.ExecContext(ctx, `
+		INSERT INTO response_revisions (response_id, response_data, created_at)
+		VALUES (?, ?, CURRENT_TIMESTAMP)
+	`, response.ID, sealResponseData(response.ResponseData))
+	return err
+}
+
+// findRevisions loads the previous versions of a response, oldest first,
+// masking the answers to the questions in pii
+func findRevisions(ctx context.Context, responseID int, pii map[string]bool) ([]ResponseRevision, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT id, response_id, response_data, created_at
+		FROM response_revisions
+		WHERE response_id = ?
+		ORDER BY id
+	`, responseID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := []ResponseRevision{}
+	for rows.Next() {
+		var revision ResponseRevision
+		var data []byte
+		if err := rows.Scan(&revision.ID, &revision.ResponseID, &data, &revision.ReplacedAt); err != nil {
+			return nil, err
+		}
+		if data, err = openResponseData(data); err != nil {
+			return nil, err
+		}
+		revision.ResponseData = maskResponseData(data, pii)
+		revision.Revision = len(revisions) + 1
+		revisions = append(revisions, revision)
+	}
+	return revisions, rows.Err()
+}
+
+// getResponseRevisions returns the previous versions of a response, oldest first
+func getResponseRevisions(c *gin.Context) {
+	ctx := c.Request.Context()
+	surveyID := c.Param("id")
+	responseID := c.Param("response_id")
+
+	sID, err := strconv.Atoi(surveyID)
+	if err != nil {
+		abortWithError(c, errInvalidID("survey"))
+		return
+	}
+
+	rID, err := strconv.Atoi(responseID)
+	if err != nil {
+		abortWithError(c, errInvalidID("response"))
+		return
+	}
+
+	survey, err := findSurvey(ctx, sID)
+	if err != nil {
+		abortWithError(c, errNotFound(CodeResponseNotFound, "Survey response not found"))
+		return
+	}
+	pii := piiMaskFor(c, survey)
+
+	// Check if response exists
+	var exists bool
+	err = db.QueryRowContext(ctx, `
+		SELECT EXISTS(SELECT 1 FROM survey_responses WHERE id = ? AND survey_id = ? AND deleted_at IS NULL)
+	`, rID, sID).Scan(&exists)
+	if err != nil || !exists {
+		abortWithError(c, errNotFound(CodeResponseNotFound, "Survey response not found"))
+		return
+	}
+
+	revisions, err := findRevisions(ctx, rID, pii)
+	if err != nil {
+		abortWithError(c, errInternal("Failed to fetch revisions", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Status: "success",
+		Data:   revisions,
+	})
+}
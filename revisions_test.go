@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResponseRevisions(t *testing.T) {
+	setupTestDB()
+	defer testDB.Close()
+
+	result, err := testDB.Exec("INSERT INTO surveys (title, description) VALUES (?, ?)", "Test Survey", "Test Description")
+	assert.NoError(t, err)
+	surveyID, _ := result.LastInsertId()
+
+	result, err = testDB.Exec("INSERT INTO survey_responses (survey_id, user_identifier, response_data) VALUES (?, ?, ?)", surveyID, "testuser", `{"rating": "5"}`)
+	assert.NoError(t, err)
+	responseID, _ := result.LastInsertId()
+
+	router := setupTestRouter()
+	responseURL := fmt.Sprintf("/api/surveys/%d/responses/%d", surveyID, responseID)
+
+	for _, rating := range []string{"4", "3"} {
+		jsonData := []byte(fmt.Sprintf(`{"survey_response":{"response_data":{"rating":"%s"}}}`, rating))
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("PATCH", responseURL, bytes.NewBuffer(jsonData))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+	}
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", responseURL+"/revisions", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response struct {
+		Data []ResponseRevision `json:"data"`
+	}
+	err = json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Len(t, response.Data, 2)
+	assert.Equal(t, 1, response.Data[0].Revision)
+	assert.JSONEq(t, `{"rating": "5"}`, string(response.Data[0].ResponseData))
+	assert.JSONEq(t, `{"rating": "4"}`, string(response.Data[1].ResponseData))
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", fmt.Sprintf("/api/surveys/%d/responses/999/revisions", surveyID), nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"github.com/gin-gonic/gin"
+)
+
+// SaveIndexingRequest lets search engines index a survey's hosted form, or not
+type SaveIndexingRequest struct {
+	Indexable *bool `json:"indexable" binding:"required"`
+}
+
+// noIndex asks search engines not to index the page being served. Hosted
+// forms are link-only unless their survey is indexable.
+func noIndex(c *gin.Context) {
+	c.Header("X-Robots-Tag", "noindex")
+}
+
+// saveIndexing lets search engines index a survey's hosted form and list it in
+// the sitemap, or keeps it link-only
+func saveIndexing(c *gin.Context) {
+	var req SaveIndexingRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		abortWithError(c, errInvalidRequest(err))
+		return
+	}
+	saveSurveyFlag(c, "indexable", *req.Indexable, "Survey indexing saved successfully")
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSurveyIndexing(t *testing.T) {
+	setupTestDB()
+	defer testDB.Close()
+	router := setupTestRouter()
+
+	testDB.Exec("INSERT INTO surveys (title, description) VALUES ('Public poll', 'd')")
+
+	send := func(req *http.Request) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	get := func(url string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", url, nil)
+		return send(req)
+	}
+	req, _ := http.NewRequest("POST", "/api/surveys/1/share", nil)
+	var created struct {
+		Data ShareLink `json:"data"`
+	}
+	json.Unmarshal(send(req).Body.Bytes(), &created)
+	link := created.Data.URL
+
+	// Hosted forms are link-only by default
+	w := get(link + "/form")
+	assert.Equal(t, "noindex", w.Header().Get("X-Robots-Tag"))
+	assert.Contains(t, w.Body.String(), `<meta name="robots" content="noindex">`)
+	assert.Equal(t, "noindex", get(link).Header().Get("X-Robots-Tag"))
+	assert.Equal(t, "noindex", get("/s/00000000000000000000000000000000/form").Header().Get("X-Robots-Tag"))
+
+	req, _ = http.NewRequest("PUT", "/api/surveys/1/indexing", nil)
+	assert.Equal(t, http.StatusForbidden, send(req).Code)
+	w = send(adminRequest("PUT", "/api/surveys/1/indexing", []byte(`{"indexable": true}`)))
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), `"indexable":true`)
+	assert.Equal(t, http.StatusBadRequest, send(adminRequest("PUT", "/api/surveys/1/indexing", []byte(`{}`))).Code)
+	assert.Equal(t, http.StatusNotFound, send(adminRequest("PUT", "/api/surveys/999/indexing", []byte(`{"indexable": true}`))).Code)
+
+	w = get(link + "/form")
+	assert.Empty(t, w.Header().Get("X-Robots-Tag"))
+	assert.NotContains(t, w.Body.String(), `name="robots"`)
+	assert.Empty(t, get(link).Header().Get("X-Robots-Tag"))
+}
+
+func TestSitemap(t *testing.T) {
+	setupTestDB()
+	defer testDB.Close()
+	router := setupTestRouter()
+
+	testDB.Exec("INSERT INTO organizations (name, custom_domain) VALUES ('Acme', 'forms.acme.test')")
+	testDB.Exec("INSERT INTO surveys (title, description, discoverable, indexable) VALUES ('Indexed', 'd', 1, 1), ('Listed only', 'd', 1, 0), ('Indexable only', 'd', 0, 1)")
+	testDB.Exec("INSERT INTO surveys (title, description, discoverable, indexable, status) VALUES ('Draft', 'd', 1, 1, 'draft')")
+	testDB.Exec("INSERT INTO surveys (title, description, discoverable, indexable, org_id) VALUES ('Acme pulse', 'd', 1, 1, 1)")
+	globalID := func(id int) string {
+		var globalID string
+		testDB.QueryRow("SELECT global_id FROM surveys WHERE id = ?", id).Scan(&globalID)
+		return globalID
+	}
+
+	get := func(host, url string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", url, nil)
+		req.Host = host
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// Without the directory, surveys have no public form and no sitemap
+	assert.Equal(t, http.StatusNotFound, get("", "/sitemap.xml").Code)
+	assert.Equal(t, http.StatusNotFound, get("", "/f/"+globalID(1)).Code)
+
+	defer func(enabled bool) { discoverEnabled = enabled }(discoverEnabled)
+	discoverEnabled = true
+
+	// Only open surveys both discoverable and indexable are listed, on the
+	// host they're served on
+	w := get("", "/sitemap.xml")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/xml; charset=utf-8", w.Header().Get("Content-Type"))
+	var urls sitemap
+	assert.NoError(t, xml.Unmarshal(w.Body.Bytes(), &urls))
+	if assert.Len(t, urls.URLs, 1) {
+		assert.Equal(t, publicURL+"/f/"+globalID(1), urls.URLs[0].Loc)
+		assert.NotEmpty(t, urls.URLs[0].LastMod)
+	}
+	assert.Contains(t, w.Body.String(), `<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">`)
+	urls = sitemap{}
+	xml.Unmarshal(get("forms.acme.test", "/sitemap.xml").Body.Bytes(), &urls)
+	if assert.Len(t, urls.URLs, 1) {
+		assert.Equal(t, "https://forms.acme.test/f/"+globalID(5), urls.URLs[0].Loc)
+	}
+
+	// The listed forms are served without a share link
+	w = get("", "/f/"+globalID(1))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `<meta property="og:url" content="`+publicURL+`/f/`+globalID(1)+`">`)
+	assert.Contains(t, w.Body.String(), `data-channel="directory"`)
+	assert.Empty(t, w.Header().Get("X-Robots-Tag"))
+	w = get("", "/f/"+globalID(2))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "noindex", w.Header().Get("X-Robots-Tag"))
+	assert.Equal(t, http.StatusNotFound, get("", "/f/"+globalID(3)).Code)
+	assert.Equal(t, http.StatusNotFound, get("", "/f/srv_unknown").Code)
+	assert.Equal(t, http.StatusNotFound, get("forms.acme.test", "/f/"+globalID(1)).Code)
+	assert.Equal(t, http.StatusOK, get("forms.acme.test", "/f/"+globalID(5)).Code)
+}
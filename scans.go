@@ -0,0 +1,462 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Review statuses of a scanned response
+const (
+	ScanNeedsReview = "needs_review"
+	ScanFinalized   = "finalized"
+	ScanRejected    = "rejected"
+)
+
+// scanConfidenceThreshold is the OCR confidence below which a field is flagged for review
+var scanConfidenceThreshold = envRatio("SCAN_CONFIDENCE_THRESHOLD", 0.9)
+
+// scanChannel is the metadata channel of responses imported from paper
+const scanChannel = "paper"
+
+// ScannedField is one OCR'd answer
+type ScannedField struct {
+	Value      json.RawMessage `json:"value"`
+	Confidence float64         `json:"confidence"`
+	// Flagged marks fields read with less than the confidence threshold
+	Flagged bool `json:"flagged"`
+}
+
+// ScannedResponse is a paper response received from the scanning service. It
+// becomes a survey response once every flagged field has been reviewed.
+type ScannedResponse struct {
+	ID             int                     `json:"id"`
+	SurveyID       int                     `json:"survey_id"`
+	UserIdentifier string                  `json:"user_identifier"`
+	Source         string                  `json:"source,omitempty"`
+	Fields         map[string]ScannedField `json:"fields"`
+	Status         string                  `json:"status"`
+	ResponseID     *int                    `json:"response_id,omitempty"`
+	CreatedAt      time.Time               `json:"created_at"`
+	ReviewedAt     *time.Time              `json:"reviewed_at,omitempty"`
+}
+
+// CreateScannedResponseRequest represents the request body of the scanning service
+type CreateScannedResponseRequest struct {
+	ScannedResponse struct {
+		// UserIdentifier is required unless the survey is anonymous
+		UserIdentifier string `json:"user_identifier"`
+		// Source identifies the scanned page, e.g. a batch and page number
+		Source string `json:"source"`
+		Fields map[string]struct {
+			Value      json.RawMessage `json:"value"`
+			Confidence *float64        `json:"confidence"`
+		} `json:"fields" binding:"required"`
+	} `json:"scanned_response" binding:"required"`
+}
+
+// ReviewRequest represents the request body for finalizing a reviewed scan or
+// fixing a response in the review queue
+type ReviewRequest struct {
+	Review struct {
+		// Corrections replace the values of fields; a null value drops the field
+		Corrections map[string]json.RawMessage `json:"corrections"`
+	} `json:"review"`
+}
+
+// scanColumns lists the columns read by scanScannedResponse
+const scanColumns = "id, survey_id, user_identifier, source, fields, status, response_id, created_at, reviewed_at"
+
+// scanScannedResponse scans a row selected with scanColumns
+func scanScannedResponse(row rowScanner) (ScannedResponse, error) {
+	var s ScannedResponse
+	var fields []byte
+	var responseID sql.NullInt64
+	var reviewedAt sql.NullTime
+	err := row.Scan(&s.ID, &s.SurveyID, &s.UserIdentifier, &s.Source, &fields, &s.Status, &responseID, &s.CreatedAt, &reviewedAt)
+	if err != nil {
+		return s, err
+	}
+	s.ResponseID = nullIntPtr(responseID)
+	s.ReviewedAt = nullTimePtr(reviewedAt)
+	return s, json.Unmarshal(fields, &s.Fields)
+}
+
+// findScannedResponse loads a scanned response by ID
+func findScannedResponse(ctx context.Context, id int) (ScannedResponse, error) {
+	return scanScannedResponse(db.QueryRowContext(ctx, "SELECT "+scanColumns+" FROM scanned_responses WHERE id = ?", id))
+}
+
+// flagged returns the IDs of the fields that need review
+func (s ScannedResponse) flagged() []string {
+	var flagged []string
+	for questionID, field := range s.Fields {
+		if field.Flagged {
+			flagged = append(flagged, questionID)
+		}
+	}
+	sort.Strings(flagged)
+	return flagged
+}
+
+// responseData returns the answers of the scan with corrections applied
+func (s ScannedResponse) responseData(corrections map[string]json.RawMessage) (json.RawMessage, error) {
+	data := map[string]json.RawMessage{}
+	for questionID, field := range s.Fields {
+		data[questionID] = field.Value
+	}
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return raw, err
+	}
+	return applyCorrections(raw, corrections)
+}
+
+// finalizeScannedResponse turns a scan into a survey response. Paper responses
+// were collected offline, so the response window and quota are not enforced.
+func finalizeScannedResponse(c *gin.Context, scan ScannedResponse, corrections map[string]json.RawMessage) (ScannedResponse, error) {
+	ctx := c.Request.Context()
+	data, err := scan.responseData(corrections)
+	if err != nil {
+		return scan, err
+	}
+	survey, err := findSurvey(ctx, scan.SurveyID)
+	if err != nil {
+		return scan, err
+	}
+	quality, err := checkQuality(ctx, survey, 0, data, nil)
+	if err != nil {
+		return scan, err
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return scan, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `
+		INSERT INTO survey_responses (survey_id, user_identifier, response_data, channel, validation_warnings,
+			quality_flags, answers_hash, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+	`, scan.SurveyID, scan.UserIdentifier, string(sealResponseData(data)), scanChannel, warningsJSON(survey, data),
+		quality.flagsJSON(), quality.AnswersHash)
+	if err != nil {
+		return scan, err
+	}
+	responseID, _ := result.LastInsertId()
+
+	result, err = tx.ExecContext(ctx, `
+		UPDATE scanned_responses SET status = ?, response_id = ?, reviewed_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND status = ?
+	`, ScanFinalized, responseID, scan.ID, ScanNeedsReview)
+	if err != nil {
+		return scan, err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return scan, sql.ErrNoRows
+	}
+	if err := tx.Commit(); err != nil {
+		return scan, err
+	}
+
+	response, err := queryResponse(ctx, db, int(responseID))
+	if err != nil {
+		return scan, err
+	}
+	auditChange(c, "create", "survey_response", response.ID, nil, response)
+	emitEvent(EventResponseCreated, scan.SurveyID, response)
+
+	return findScannedResponse(ctx, scan.ID)
+}
+
+// createScannedResponse ingests OCR'd answers. Scans read confidently are
+// finalized right away; the rest wait in the review queue.
+func createScannedResponse(c *gin.Context) {
+	ctx := c.Request.Context()
+	surveyID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		abortWithError(c, errInvalidID("survey"))
+		return
+	}
+
+	survey, err := findSurvey(ctx, surveyID)
+	if err != nil {
+		abortWithError(c, errNotFound(CodeSurveyNotFound, "Survey not found"))
+		return
+	}
+
+	var req CreateScannedResponseRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		abortWithError(c, errInvalidRequest(err))
+		return
+	}
+
+	// Validation
+	var errors []string
+	in := req.ScannedResponse
+	if survey.Anonymous {
+		in.UserIdentifier = newRespondentToken()
+	} else if len(in.UserIdentifier) < 3 {
+		errors = append(errors, "User identifier must be at least 3 characters long")
+	} else if len(in.UserIdentifier) > 100 {
+		errors = append(errors, "User identifier must be less than 100 characters")
+	}
+	if len(in.Fields) == 0 {
+		errors = append(errors, "Fields must not be empty")
+	}
+
+	scan := ScannedResponse{SurveyID: surveyID, UserIdentifier: in.UserIdentifier, Source: in.Source, Fields: map[string]ScannedField{}}
+	values := map[string]json.RawMessage{}
+	var ids []string
+	for questionID := range in.Fields {
+		ids = append(ids, questionID)
+	}
+	sort.Strings(ids)
+	for _, questionID := range ids {
+		field := in.Fields[questionID]
+		if field.Confidence == nil || *field.Confidence < 0 || *field.Confidence > 1 {
+			errors = append(errors, fmt.Sprintf("Field %s confidence must be between 0 and 1", questionID))
+			continue
+		}
+		values[questionID] = field.Value
+		scan.Fields[questionID] = ScannedField{
+			Value:      field.Value,
+			Confidence: *field.Confidence,
+			Flagged:    *field.Confidence < scanConfidenceThreshold,
+		}
+	}
+	errors = append(errors, validateAnswerKeys(survey, values)...)
+
+	if len(errors) > 0 {
+		abortWithError(c, errValidation("Failed to import scanned response", errors))
+		return
+	}
+
+	fields, _ := json.Marshal(scan.Fields)
+	result, err := db.ExecContext(ctx, `
+		INSERT INTO scanned_responses (survey_id, user_identifier, source, fields, status, created_at)
+		VALUES (?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
+	`, surveyID, scan.UserIdentifier, scan.Source, string(fields), ScanNeedsReview)
+	if err != nil {
+		abortWithError(c, errInternal("Failed to import scanned response", err))
+		return
+	}
+	id, _ := result.LastInsertId()
+	scan, err = findScannedResponse(ctx, int(id))
+	if err == nil && len(scan.flagged()) == 0 {
+		scan, err = finalizeScannedResponse(c, scan, nil)
+	}
+	if err != nil {
+		abortWithError(c, errInternal("Failed to import scanned response", err))
+		return
+	}
+
+	message := "Scanned response imported"
+	if scan.Status == ScanNeedsReview {
+		message = "Scanned response queued for review: " + strings.Join(scan.flagged(), ", ")
+	}
+	c.JSON(http.StatusCreated, APIResponse{
+		Status:  "success",
+		Message: message,
+		Data:    scan,
+	})
+}
+
+// getScanReviewQueue lists scanned responses, by default those awaiting review, oldest first
+func getScanReviewQueue(c *gin.Context) {
+	ctx := c.Request.Context()
+	var errors []string
+	conditions := []string{"status = ?"}
+	status := c.DefaultQuery("status", ScanNeedsReview)
+	if status != ScanNeedsReview && status != ScanFinalized && status != ScanRejected {
+		errors = append(errors, "Status must be one of needs_review, finalized or rejected")
+	}
+	args := []interface{}{status}
+
+	if value := c.Query("survey_id"); value != "" {
+		surveyID, err := strconv.Atoi(value)
+		if err != nil {
+			errors = append(errors, "Survey ID must be a number")
+		}
+		conditions = append(conditions, "survey_id = ?")
+		args = append(args, surveyID)
+	}
+	if orgID, ok := currentOrganization(c); ok {
+		conditions = append(conditions, "survey_id IN (SELECT id FROM surveys WHERE org_id = ?)")
+		args = append(args, orgID)
+	}
+
+	limit := defaultPageSize
+	if value := c.Query("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > maxPageSize {
+			errors = append(errors, fmt.Sprintf("Limit must be between 1 and %d", maxPageSize))
+		}
+		limit = n
+	}
+
+	if len(errors) > 0 {
+		abortWithError(c, errInvalidQuery(errors))
+		return
+	}
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT `+scanColumns+` FROM scanned_responses
+		WHERE `+strings.Join(conditions, " AND ")+`
+		ORDER BY id
+		LIMIT ?
+	`, append(args, limit)...)
+	if err != nil {
+		abortWithError(c, errInternal("Failed to fetch scanned responses", err))
+		return
+	}
+	defer rows.Close()
+
+	scans := []ScannedResponse{}
+	for rows.Next() {
+		scan, err := scanScannedResponse(rows)
+		if err != nil {
+			abortWithError(c, errInternal("Failed to scan scanned response data", err))
+			return
+		}
+		scans = append(scans, scan)
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Status: "success",
+		Data:   scans,
+		Meta:   PageMeta{Limit: limit},
+	})
+}
+
+// pendingScan loads the scanned response of a review route, responding with the
+// error when it doesn't exist or was already reviewed
+func pendingScan(c *gin.Context) (ScannedResponse, bool) {
+	ctx := c.Request.Context()
+	id, err := strconv.Atoi(c.Param("scan_id"))
+	if err != nil {
+		abortWithError(c, errInvalidID("scanned response"))
+		return ScannedResponse{}, false
+	}
+
+	scan, err := findScannedResponse(ctx, id)
+	if err == nil {
+		err = scanInTenant(c, scan)
+	}
+	if err != nil {
+		if err == sql.ErrNoRows {
+			abortWithError(c, errNotFound(CodeScannedResponseNotFound, "Scanned response not found"))
+			return scan, false
+		}
+		abortWithError(c, errInternal("Failed to fetch scanned response", err))
+		return scan, false
+	}
+	if scan.Status != ScanNeedsReview {
+		abortWithError(c, &APIError{
+			Status:  http.StatusConflict,
+			Code:    CodeAlreadyReviewed,
+			Message: "Scanned response was already reviewed",
+			Data:    scan,
+		})
+		return scan, false
+	}
+	return scan, true
+}
+
+// scanInTenant returns sql.ErrNoRows for scans of surveys outside the
+// organization the request acts in
+func scanInTenant(c *gin.Context, scan ScannedResponse) error {
+	orgID, ok := currentOrganization(c)
+	if !ok {
+		return nil
+	}
+	var inOrg bool
+	err := db.QueryRowContext(c.Request.Context(), "SELECT EXISTS (SELECT 1 FROM surveys WHERE id = ? AND org_id = ?)", scan.SurveyID, orgID).Scan(&inOrg)
+	if err == nil && !inOrg {
+		err = sql.ErrNoRows
+	}
+	return err
+}
+
+// finalizeScan creates the survey response of a reviewed scan, applying the reviewer's corrections
+func finalizeScan(c *gin.Context) {
+	ctx := c.Request.Context()
+	scan, ok := pendingScan(c)
+	if !ok {
+		return
+	}
+
+	var req ReviewRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			abortWithError(c, errInvalidRequest(err))
+			return
+		}
+	}
+
+	survey, err := findSurvey(ctx, scan.SurveyID)
+	if err == nil {
+		if errors := validateAnswerKeys(survey, req.Review.Corrections); len(errors) > 0 {
+			abortWithError(c, errValidation("Failed to finalize scanned response", errors))
+			return
+		}
+		scan, err = finalizeScannedResponse(c, scan, req.Review.Corrections)
+	}
+	if err == sql.ErrNoRows {
+		abortWithError(c, &APIError{
+			Status:  http.StatusConflict,
+			Code:    CodeAlreadyReviewed,
+			Message: "Scanned response was already reviewed",
+		})
+		return
+	}
+	if err != nil {
+		abortWithError(c, errInternal("Failed to finalize scanned response", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Status:  "success",
+		Message: "Scanned response finalized",
+		Data:    scan,
+	})
+}
+
+// rejectScannedResponse marks a scan as rejected; no response is created
+func rejectScannedResponse(ctx context.Context, scan ScannedResponse) (ScannedResponse, error) {
+	_, err := db.ExecContext(ctx, "UPDATE scanned_responses SET status = ?, reviewed_at = CURRENT_TIMESTAMP WHERE id = ?", ScanRejected, scan.ID)
+	if err != nil {
+		return scan, err
+	}
+	return findScannedResponse(ctx, scan.ID)
+}
+
+// rejectScan discards a scanned response that can't be read, e.g. a blank or foreign page
+func rejectScan(c *gin.Context) {
+	ctx := c.Request.Context()
+	scan, ok := pendingScan(c)
+	if !ok {
+		return
+	}
+
+	scan, err := rejectScannedResponse(ctx, scan)
+	if err != nil {
+		abortWithError(c, errInternal("Failed to reject scanned response", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Status:  "success",
+		Message: "Scanned response rejected",
+		Data:    scan,
+	})
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScannedResponseReview(t *testing.T) {
+	setupTestDB()
+	defer testDB.Close()
+
+	result, err := testDB.Exec(`INSERT INTO surveys (title, description, questions) VALUES ('Paper', 'd', '[{"id":"rating","type":"rating","label":"Rating"},{"id":"comment","type":"text","label":"Comment"}]')`)
+	assert.NoError(t, err)
+	surveyID, _ := result.LastInsertId()
+
+	router := setupTestRouter()
+
+	serve := func(method, url string, body []byte) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, adminRequest(method, url, body))
+		return w
+	}
+
+	scan := func(method, url, body string) (ScannedResponse, int) {
+		w := serve(method, url, []byte(body))
+		var response struct {
+			Data ScannedResponse `json:"data"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		return response.Data, w.Code
+	}
+	importURL := fmt.Sprintf("/api/admin/surveys/%d/scanned-responses", surveyID)
+
+	// Confidently read scans become responses right away
+	confident, status := scan("POST", importURL, `{"scanned_response":{"user_identifier":"paper-1","source":"batch-1/page-1","fields":{
+		"rating":{"value":"5","confidence":0.99}
+	}}}`)
+	assert.Equal(t, http.StatusCreated, status)
+	assert.Equal(t, ScanFinalized, confident.Status)
+	if assert.NotNil(t, confident.ResponseID) {
+		var channel string
+		testDB.QueryRow("SELECT channel FROM survey_responses WHERE id = ?", *confident.ResponseID).Scan(&channel)
+		assert.Equal(t, scanChannel, channel)
+	}
+
+	// Low-confidence fields wait in the review queue
+	flagged, status := scan("POST", importURL, `{"scanned_response":{"user_identifier":"paper-2","fields":{
+		"rating":{"value":"3","confidence":0.95},
+		"comment":{"value":"Gr8 servise","confidence":0.41}
+	}}}`)
+	assert.Equal(t, http.StatusCreated, status)
+	assert.Equal(t, ScanNeedsReview, flagged.Status)
+	assert.Nil(t, flagged.ResponseID)
+	assert.True(t, flagged.Fields["comment"].Flagged)
+	assert.False(t, flagged.Fields["rating"].Flagged)
+
+	w := serve("GET", "/api/admin/scanned-responses", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var queue struct {
+		Data []ScannedResponse `json:"data"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &queue)
+	if assert.Len(t, queue.Data, 1) {
+		assert.Equal(t, flagged.ID, queue.Data[0].ID)
+	}
+
+	// Finalizing applies the reviewer's corrections
+	finalizeURL := fmt.Sprintf("/api/admin/scanned-responses/%d/finalize", flagged.ID)
+	_, status = scan("POST", finalizeURL, `{"review":{"corrections":{"color":"red"}}}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, status)
+	finalized, status := scan("POST", finalizeURL, `{"review":{"corrections":{"comment":"Great service"}}}`)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, ScanFinalized, finalized.Status)
+	assert.NotNil(t, finalized.ReviewedAt)
+	if assert.NotNil(t, finalized.ResponseID) {
+		var data string
+		testDB.QueryRow("SELECT response_data FROM survey_responses WHERE id = ?", *finalized.ResponseID).Scan(&data)
+		assert.JSONEq(t, `{"rating":"3","comment":"Great service"}`, data)
+	}
+	_, status = scan("POST", finalizeURL, "")
+	assert.Equal(t, http.StatusConflict, status)
+
+	// Unreadable scans are rejected without creating a response
+	blank, _ := scan("POST", importURL, `{"scanned_response":{"user_identifier":"paper-3","fields":{"rating":{"value":"","confidence":0.1}}}}`)
+	rejected, status := scan("POST", fmt.Sprintf("/api/admin/scanned-responses/%d/reject", blank.ID), "")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, ScanRejected, rejected.Status)
+	assert.Nil(t, rejected.ResponseID)
+
+	w = serve("GET", "/api/admin/scanned-responses", nil)
+	json.Unmarshal(w.Body.Bytes(), &queue)
+	assert.Empty(t, queue.Data)
+}
+
+func TestScannedResponseValidation(t *testing.T) {
+	setupTestDB()
+	defer testDB.Close()
+
+	result, err := testDB.Exec(`INSERT INTO surveys (title, description, questions) VALUES ('Paper', 'd', '[{"id":"rating","type":"rating","label":"Rating"}]')`)
+	assert.NoError(t, err)
+	surveyID, _ := result.LastInsertId()
+
+	router := setupTestRouter()
+
+	serve := func(method, url string, body []byte) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, adminRequest(method, url, body))
+		return w
+	}
+	url := fmt.Sprintf("/api/admin/surveys/%d/scanned-responses", surveyID)
+
+	for _, body := range []string{
+		`{"scanned_response":{"user_identifier":"paper-1","fields":{"rating":{"value":"5"}}}}`,
+		`{"scanned_response":{"user_identifier":"paper-1","fields":{"rating":{"value":"5","confidence":1.5}}}}`,
+		`{"scanned_response":{"user_identifier":"paper-1","fields":{"color":{"value":"red","confidence":1}}}}`,
+		`{"scanned_response":{"user_identifier":"p","fields":{"rating":{"value":"5","confidence":1}}}}`,
+	} {
+		w := serve("POST", url, []byte(body))
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code, body)
+	}
+
+	w := serve("POST", "/api/admin/surveys/999/scanned-responses", []byte(`{}`))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	w = serve("GET", "/api/admin/scanned-responses?status=lost", nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
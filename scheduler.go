@@ -0,0 +1,217 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// schedulerInterval is how often the scheduler looks for due work
+const schedulerInterval = 15 * time.Second
+
+// runScheduler performs periodic background work until ctx is cancelled
+func runScheduler(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if n, err := publishDueSurveys(ctx, time.Now()); err != nil {
+				log.Println("Scheduler: failed to publish surveys:", err)
+			} else if n > 0 {
+				log.Printf("Scheduler: published %d survey(s)", n)
+			}
+			if _, err := closeDueSurveys(ctx, time.Now()); err != nil {
+				log.Println("Scheduler: failed to close surveys:", err)
+			}
+			if n, err := snapshotDueSurveys(ctx, time.Now()); err != nil {
+				log.Println("Scheduler: failed to snapshot surveys:", err)
+			} else if n > 0 {
+				log.Printf("Scheduler: snapshotted %d survey(s)", n)
+			}
+			if n, err := sendPendingInvitations(ctx, time.Now()); err != nil {
+				log.Println("Scheduler: failed to send invitations:", err)
+			} else if n > 0 {
+				log.Printf("Scheduler: sent %d invitation(s)", n)
+			}
+			if n, err := sendDueReminders(ctx, time.Now()); err != nil {
+				log.Println("Scheduler: failed to send reminders:", err)
+			} else if n > 0 {
+				log.Printf("Scheduler: sent %d reminder(s)", n)
+			}
+			if n, err := sendNotifications(ctx, time.Now()); err != nil {
+				log.Println("Scheduler: failed to send notifications:", err)
+			} else if n > 0 {
+				log.Printf("Scheduler: sent %d notification(s)", n)
+			}
+			if n, err := syncNewResponses(ctx, time.Now()); err != nil {
+				log.Println("Scheduler: failed to sync Google Sheets:", err)
+			} else if n > 0 {
+				log.Printf("Scheduler: appended %d response(s) to Google Sheets", n)
+			}
+			if n, err := runDueExportJobs(ctx, time.Now()); err != nil {
+				log.Println("Scheduler: failed to run export jobs:", err)
+			} else if n > 0 {
+				log.Printf("Scheduler: ran %d export job(s)", n)
+			}
+		}
+	}
+}
+
+// publishDueSurveys publishes every draft survey whose publish time has passed
+func publishDueSurveys(ctx context.Context, now time.Time) (int64, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT id FROM surveys
+		WHERE status = ? AND publish_at IS NOT NULL AND publish_at <= ?
+	`, SurveyStatusDraft, now.UTC())
+	if err != nil {
+		return 0, err
+	}
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+
+	var published int64
+	for _, id := range ids {
+		before, err := findSurvey(ctx, id)
+		if err != nil {
+			return published, err
+		}
+
+		result, err := db.ExecContext(ctx, `
+			UPDATE surveys
+			SET status = ?, updated_at = CURRENT_TIMESTAMP
+			WHERE id = ? AND status = ?
+		`, SurveyStatusPublished, id, SurveyStatusDraft)
+		if err != nil {
+			return published, err
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			continue
+		}
+		published++
+
+		after, err := findSurvey(ctx, id)
+		if err == nil {
+			err = recordChange(ctx, db, "scheduler", "publish", "survey", id, before, after)
+			emitEventOnce(EventSurveyPublished, id, after)
+		}
+		if err != nil {
+			log.Printf("Scheduler: failed to audit publishing survey %d: %v", id, err)
+		}
+	}
+	return published, nil
+}
+
+// closeDueSurveys sends survey.closed for every published survey whose close time
+// has passed and that has not been reported closed yet
+func closeDueSurveys(ctx context.Context, now time.Time) (int, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT s.id FROM surveys s
+		WHERE s.status = ? AND s.closes_at IS NOT NULL AND s.closes_at <= ?
+		AND NOT EXISTS (SELECT 1 FROM survey_lifecycle_events e WHERE e.survey_id = s.id AND e.event = ?)
+	`, SurveyStatusPublished, now.UTC(), EventSurveyClosed)
+	if err != nil {
+		return 0, err
+	}
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+
+	for _, id := range ids {
+		survey, err := findSurvey(ctx, id)
+		if err != nil {
+			return 0, err
+		}
+		emitEventOnce(EventSurveyClosed, id, survey)
+	}
+	return len(ids), nil
+}
+
+// scheduleSurvey sets the publish time of a draft survey
+func scheduleSurvey(c *gin.Context) {
+	ctx := c.Request.Context()
+	id := c.Param("id")
+	surveyID, err := strconv.Atoi(id)
+	if err != nil {
+		abortWithError(c, errInvalidID("survey"))
+		return
+	}
+
+	var req ScheduleSurveyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		abortWithError(c, errInvalidRequest(err))
+		return
+	}
+
+	survey, err := findSurvey(ctx, surveyID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			abortWithError(c, errNotFound(CodeSurveyNotFound, "Survey not found"))
+			return
+		}
+		abortWithError(c, errInternal("Failed to fetch survey", err))
+		return
+	}
+
+	// Validation
+	var errors []string
+	if survey.Status != SurveyStatusDraft {
+		errors = append(errors, "Only draft surveys can be scheduled")
+	}
+	if !req.Survey.PublishAt.After(time.Now()) {
+		errors = append(errors, "Publish time must be in the future")
+	}
+
+	if len(errors) > 0 {
+		abortWithError(c, errValidation("Failed to schedule survey", errors))
+		return
+	}
+
+	_, err = db.ExecContext(ctx, `
+		UPDATE surveys
+		SET publish_at = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?
+	`, req.Survey.PublishAt.UTC(), surveyID)
+	if err != nil {
+		abortWithError(c, errInternal("Failed to schedule survey", err))
+		return
+	}
+
+	scheduled, err := findSurvey(ctx, surveyID)
+	if err != nil {
+		abortWithError(c, errInternal("Failed to fetch scheduled survey", err))
+		return
+	}
+	auditChange(c, "schedule", "survey", surveyID, survey, scheduled)
+	emitEvent(EventSurveyUpdated, surveyID, scheduled)
+	survey = scheduled
+
+	c.JSON(http.StatusOK, APIResponse{
+		Status:  "success",
+		Message: "Survey scheduled successfully",
+		Data:    survey,
+	})
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScheduledSurveyIsEmbargoedUntilPublished(t *testing.T) {
+	setupTestDB()
+	defer testDB.Close()
+
+	router := setupTestRouter()
+
+	// Create a draft survey scheduled for the future
+	publishAt := time.Now().Add(time.Hour).UTC()
+	surveyData := map[string]interface{}{
+		"survey": map[string]interface{}{
+			"title":       "Launch Survey",
+			"description": "Embargoed until launch",
+			"publish_at":  publishAt,
+		},
+	}
+
+	jsonData, _ := json.Marshal(surveyData)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/surveys", bytes.NewBuffer(jsonData))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	var created struct {
+		Data TestSurvey `json:"data"`
+	}
+	err := json.Unmarshal(w.Body.Bytes(), &created)
+	assert.NoError(t, err)
+	surveyID := created.Data.ID
+
+	// Public fetch returns a coming soon payload without the content
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", fmt.Sprintf("/api/surveys/%d", surveyID), nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"coming_soon":true`)
+	assert.NotContains(t, w.Body.String(), "Launch Survey")
+
+	// Drafts are not listed and don't accept responses
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/surveys", nil)
+	router.ServeHTTP(w, req)
+	assert.NotContains(t, w.Body.String(), "Launch Survey")
+
+	responseData := []byte(`{"survey_response":{"user_identifier":"testuser","response_data":{"rating":"5"}}}`)
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", fmt.Sprintf("/api/surveys/%d/responses", surveyID), bytes.NewBuffer(responseData))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+	// The scheduler publishes it once the publish time has passed
+	published, err := publishDueSurveys(context.Background(), publishAt.Add(time.Minute))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), published)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", fmt.Sprintf("/api/surveys/%d", surveyID), nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "Launch Survey")
+}
+
+func TestScheduleSurveyRequiresDraft(t *testing.T) {
+	setupTestDB()
+	defer testDB.Close()
+
+	result, err := testDB.Exec("INSERT INTO surveys (title, description) VALUES (?, ?)", "Test Survey", "Test Description")
+	assert.NoError(t, err)
+	surveyID, _ := result.LastInsertId()
+
+	router := setupTestRouter()
+
+	scheduleData := map[string]interface{}{
+		"survey": map[string]interface{}{
+			"publish_at": time.Now().Add(time.Hour),
+		},
+	}
+
+	jsonData, _ := json.Marshal(scheduleData)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", fmt.Sprintf("/api/surveys/%d/schedule", surveyID), bytes.NewBuffer(jsonData))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+	var response TestAPIResponse
+	err = json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Contains(t, response.Errors, "Only draft surveys can be scheduled")
+}
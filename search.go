@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Snippet markers; snippets are HTML-escaped before these become <mark> tags
+const (
+	snippetStart = "\x02"
+	snippetEnd   = "\x03"
+)
+
+// maxSearchTerms caps the words of a search query
+const maxSearchTerms = 10
+
+// ResponseSearchResult is a response matching a search, with the matching text highlighted
+type ResponseSearchResult struct {
+	Response SurveyResponse `json:"response"`
+	// Snippet is HTML: the matched terms are wrapped in <mark> and the rest is escaped
+	Snippet string `json:"snippet"`
+}
+
+// searchMatchExpression turns free text into an FTS query matching every word.
+// Words are quoted so FTS operators in user input are searched literally; a
+// trailing * keeps its meaning as a prefix search.
+func searchMatchExpression(q string) (string, []string) {
+	var errors []string
+	var terms []string
+	for _, word := range strings.Fields(q) {
+		prefix := strings.HasSuffix(word, "*")
+		word = strings.Trim(strings.ReplaceAll(word, `"`, ""), "*")
+		if word == "" {
+			continue
+		}
+		term := `"` + word
+		if prefix {
+			term += "*"
+		}
+		terms = append(terms, term+`"`)
+	}
+
+	if len(terms) == 0 {
+		errors = append(errors, "Q is required")
+	}
+	if len(terms) > maxSearchTerms {
+		errors = append(errors, fmt.Sprintf("Q must have at most %d words", maxSearchTerms))
+	}
+	return strings.Join(terms, " "), errors
+}
+
+// highlightSnippet escapes a snippet and turns its match markers into <mark> tags
+func highlightSnippet(snippet string) string {
+	return strings.NewReplacer(snippetStart, "<mark>", snippetEnd, "</mark>").Replace(html.EscapeString(snippet))
+}
+
+// extraScanner scans columns selected after responseColumns into extra
+type extraScanner struct {
+	row   rowScanner
+	extra []interface{}
+}
+
+// Scan implements rowScanner
+func (s extraScanner) Scan(dest ...interface{}) error {
+	return s.row.Scan(append(dest, s.extra...)...)
+}
+
+// searchResponses returns the survey's responses whose text answers match, newest first
+func searchResponses(ctx context.Context, surveyID int, match string, limit int) ([]ResponseSearchResult, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT `+responseColumns+`, snippet(response_search, ?, ?, '…', -1, 15)
+		FROM `+responsesFrom+`
+		JOIN response_search ON response_search.rowid = sr.id
+		WHERE response_search MATCH ? AND sr.survey_id = ? AND sr.deleted_at IS NULL
+		ORDER BY sr.created_at DESC, sr.id DESC
+		LIMIT ?
+	`, snippetStart, snippetEnd, match, surveyID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := []ResponseSearchResult{}
+	for rows.Next() {
+		var snippet string
+		response, err := scanResponse(extraScanner{rows, []interface{}{&snippet}})
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, ResponseSearchResult{Response: response, Snippet: highlightSnippet(snippet)})
+	}
+	return results, rows.Err()
+}
+
+// searchSurveyResponses searches the free-text answers of a survey's responses
+func searchSurveyResponses(c *gin.Context) {
+	ctx := c.Request.Context()
+	surveyID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		abortWithError(c, errInvalidID("survey"))
+		return
+	}
+
+	survey, err := findSurvey(ctx, surveyID)
+	if err != nil {
+		abortWithError(c, errNotFound(CodeSurveyNotFound, "Survey not found"))
+		return
+	}
+
+	match, errors := searchMatchExpression(c.Query("q"))
+	limit := defaultPageSize
+	if value := c.Query("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > maxPageSize {
+			errors = append(errors, fmt.Sprintf("Limit must be between 1 and %d", maxPageSize))
+		}
+		limit = n
+	}
+	// Encrypted and compressed answers are left out of the search index
+	if responseDataOpaque() {
+		errors = append(errors, "Search is unavailable while response data is encrypted or compressed")
+	}
+	if len(errors) > 0 {
+		abortWithError(c, errInvalidQuery(errors))
+		return
+	}
+
+	results, err := searchResponses(ctx, surveyID, match, limit)
+	if err != nil {
+		abortWithError(c, errInternal("Failed to search responses", err))
+		return
+	}
+	// Snippets don't tell which answer they come from, so they're dropped
+	// rather than risk showing PII
+	if pii := piiMaskFor(c, survey); pii != nil {
+		for i := range results {
+			results[i].Response.ResponseData = maskResponseData(results[i].Response.ResponseData, pii)
+			results[i].Snippet = ""
+		}
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Status: "success",
+		Data:   results,
+		Meta:   PageMeta{Limit: limit},
+	})
+}
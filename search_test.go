@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSearchMatchExpression(t *testing.T) {
+	match, errors := searchMatchExpression(`refund "late" deliv* OR`)
+	assert.Empty(t, errors)
+	assert.Equal(t, `"refund" "late" "deliv*" "OR"`, match)
+
+	_, errors = searchMatchExpression(` " * `)
+	assert.Equal(t, []string{"Q is required"}, errors)
+}
+
+func TestSearchSurveyResponses(t *testing.T) {
+	setupTestDB()
+	defer testDB.Close()
+
+	result, err := testDB.Exec("INSERT INTO surveys (title, description) VALUES ('Search', 'd'), ('Other', 'd')")
+	assert.NoError(t, err)
+	otherID, _ := result.LastInsertId()
+	surveyID := otherID - 1
+
+	for _, r := range []struct {
+		survey int64
+		user   string
+		data   string
+	}{
+		{surveyID, "user1", `{"rating":1,"comment":"I want a refund, the <b>delivery</b> was late"}`},
+		{surveyID, "user2", `{"rating":5,"comment":"Great service","tags":["fast","refunded"]}`},
+		{surveyID, "user3", `{"rating":3,"comment":"Refund took too long"}`},
+		{otherID, "user4", `{"comment":"refund please"}`},
+	} {
+		_, err := testDB.Exec("INSERT INTO survey_responses (survey_id, user_identifier, response_data) VALUES (?, ?, ?)", r.survey, r.user, r.data)
+		assert.NoError(t, err)
+	}
+	// Deleted responses are not searched and edits are reindexed
+	_, err = testDB.Exec("UPDATE survey_responses SET deleted_at = CURRENT_TIMESTAMP WHERE user_identifier = 'user3'")
+	assert.NoError(t, err)
+	_, err = testDB.Exec(`UPDATE survey_responses SET response_data = '{"rating":5,"comment":"Fast refund, thanks"}' WHERE user_identifier = 'user2'`)
+	assert.NoError(t, err)
+
+	router := setupTestRouter()
+
+	search := func(q string) ([]ResponseSearchResult, int) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", fmt.Sprintf("/api/surveys/%d/responses/search?q=%s", surveyID, url.QueryEscape(q)), nil)
+		router.ServeHTTP(w, req)
+		var response struct {
+			Data []ResponseSearchResult `json:"data"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		return response.Data, w.Code
+	}
+
+	results, code := search("refund")
+	assert.Equal(t, http.StatusOK, code)
+	if assert.Len(t, results, 2) {
+		assert.Equal(t, "user2", results[0].Response.UserIdentifier)
+		assert.Equal(t, "Fast <mark>refund</mark>, thanks", results[0].Snippet)
+		assert.Equal(t, "user1", results[1].Response.UserIdentifier)
+		assert.Contains(t, results[1].Snippet, "&lt;b&gt;delivery&lt;/b&gt;")
+	}
+
+	results, _ = search("refund late")
+	assert.Len(t, results, 1)
+
+	results, _ = search("deliv*")
+	assert.Len(t, results, 1)
+
+	results, _ = search(`"unbalanced AND (`)
+	assert.Empty(t, results)
+
+	_, code = search("")
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/surveys/999/responses/search?q=refund", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
@@ -1,134 +1,290 @@
 package main
 
 import (
-	"database/sql"
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
-	"log"
+	"math/rand"
+	"os"
+	"strings"
+	"time"
 
-	_ "github.com/mattn/go-sqlite3"
+	"gopkg.in/yaml.v3"
 )
 
-func main() {
-	// Open database
-	db, err := sql.Open("sqlite3", "./survey_form.db")
-	if err != nil {
-		log.Fatal(err)
-	}
-	defer db.Close()
+// defaultSeedFixtures is the fixtures file seed loads unless given -fixtures
+const defaultSeedFixtures = "fixtures/seed.yaml"
 
-	// Clear existing data
-	_, err = db.Exec("DELETE FROM survey_responses")
-	if err != nil {
-		log.Fatal(err)
-	}
-	_, err = db.Exec("DELETE FROM surveys")
-	if err != nil {
-		log.Fatal(err)
-	}
+// seedSpan is how far back synthetic responses are spread, so analytics and
+// time series have history to work with
+const seedSpan = 30 * 24 * time.Hour
 
-	fmt.Println("Creating sample surveys...")
+// seedFixtures are the surveys, with their questions and responses, that seed
+// loads from a YAML or JSON file
+type seedFixtures struct {
+	Surveys []seedSurvey `json:"surveys"`
+}
 
-	// Create sample surveys
-	survey1Data := map[string]interface{}{
-		"title":       "Customer Satisfaction Survey",
-		"description": "Help us improve our services by providing your feedback on your recent experience.",
-	}
+// seedSurvey is a survey fixture; allow_multiple_responses defaults to true
+// and status to published, as when creating a survey through the API
+type seedSurvey struct {
+	Title                  string         `json:"title"`
+	Description            string         `json:"description"`
+	Status                 string         `json:"status"`
+	AllowMultipleResponses *bool          `json:"allow_multiple_responses"`
+	Anonymous              bool           `json:"anonymous"`
+	Questions              []Question     `json:"questions"`
+	Responses              []seedResponse `json:"responses"`
+
+	// weight is the survey's share of synthetic responses relative to the
+	// others; fixtures share them equally
+	weight float64
+}
+
+// seedResponse is a response fixture
+type seedResponse struct {
+	UserIdentifier string          `json:"user_identifier"`
+	ResponseData   json.RawMessage `json:"response_data"`
+}
+
+// seedResult counts what seed created
+type seedResult struct {
+	Surveys   int
+	Responses int
+}
 
-	survey2Data := map[string]interface{}{
-		"title":       "Employee Engagement Survey",
-		"description": "We value your opinion! Please share your thoughts about workplace culture and satisfaction.",
+// runSeed loads fixtures into the database of DB_PATH, optionally after
+// removing every survey, and adds -responses synthetic responses, and
+// -surveys generated surveys to spread them over, for load testing
+func runSeed(args []string) error {
+	flags := flag.NewFlagSet("seed", flag.ContinueOnError)
+	fixturesPath := flags.String("fixtures", defaultSeedFixtures, "YAML or JSON file of the surveys and responses to create, none if empty")
+	surveys := flags.Int("surveys", 0, "number of randomized surveys to generate besides the fixtures")
+	var count int
+	flags.IntVar(&count, "responses", 0, "number of synthetic responses to add, spread over the published surveys with questions")
+	flags.IntVar(&count, "count", 0, "same as -responses")
+	reset := flags.Bool("reset", false, "delete every survey, with its responses, before seeding")
+	randomSeed := flags.Int64("random-seed", 0, "seed of the generated data, for reproducible runs; random if 0")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() > 0 {
+		return fmt.Errorf("seed takes no arguments, got %q", flags.Args())
+	}
+	if count < 0 || *surveys < 0 {
+		return errors.New("-responses and -surveys must not be negative")
 	}
 
-	survey3Data := map[string]interface{}{
-		"title":       "Product Feedback Form",
-		"description": "Tell us what you think about our latest product features and how we can make them better.",
+	var fixtures seedFixtures
+	if *fixturesPath != "" {
+		var err error
+		if fixtures, err = loadSeedFixtures(*fixturesPath); err != nil {
+			return err
+		}
+	}
+	if *randomSeed == 0 {
+		*randomSeed = time.Now().UnixNano()
 	}
+	rnd := rand.New(rand.NewSource(*randomSeed))
+	fixtures.Surveys = append(fixtures.Surveys, generateSurveys(rnd, *surveys)...)
+
+	initDatabase()
+	defer exportDB.Close()
+	defer db.Close()
 
-	// Insert surveys
-	result1, err := db.Exec("INSERT INTO surveys (title, description) VALUES (?, ?)", survey1Data["title"], survey1Data["description"])
+	started := time.Now()
+	result, err := seed(context.Background(), fixtures, count, *reset, rnd)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
-	survey1ID, _ := result1.LastInsertId()
+	fmt.Printf("Created %d surveys and %d responses in %s\n", result.Surveys, result.Responses, time.Since(started).Round(time.Millisecond))
+	return nil
+}
 
-	result2, err := db.Exec("INSERT INTO surveys (title, description) VALUES (?, ?)", survey2Data["title"], survey2Data["description"])
+// loadSeedFixtures reads and checks a fixtures file. YAML is a superset of
+// JSON, so both are read as YAML and then decoded as the API's JSON.
+func loadSeedFixtures(path string) (seedFixtures, error) {
+	var fixtures seedFixtures
+	raw, err := os.ReadFile(path)
 	if err != nil {
-		log.Fatal(err)
+		return fixtures, err
 	}
-	survey2ID, _ := result2.LastInsertId()
-
-	result3, err := db.Exec("INSERT INTO surveys (title, description) VALUES (?, ?)", survey3Data["title"], survey3Data["description"])
+	var doc interface{}
+	if err := yaml.Unmarshal(raw, &doc); err != nil {
+		return fixtures, fmt.Errorf("%s: %w", path, err)
+	}
+	data, err := json.Marshal(doc)
 	if err != nil {
-		log.Fatal(err)
+		return fixtures, fmt.Errorf("%s: %w", path, err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&fixtures); err != nil {
+		return fixtures, fmt.Errorf("%s: %w", path, err)
 	}
-	survey3ID, _ := result3.LastInsertId()
 
-	fmt.Println("Creating sample survey responses...")
+	var problems []string
+	for i, s := range fixtures.Surveys {
+		for _, problem := range s.validate() {
+			problems = append(problems, fmt.Sprintf("surveys[%d]: %s", i, problem))
+		}
+	}
+	if len(problems) > 0 {
+		return fixtures, fmt.Errorf("%s:\n  %s", path, strings.Join(problems, "\n  "))
+	}
+	return fixtures, nil
+}
 
-	// Create sample responses for survey1
-	response1Data := map[string]interface{}{
-		"overall_satisfaction":      "5",
-		"service_quality":           "4",
-		"recommendation_likelihood": "5",
-		"comments":                  "Great service, very satisfied!",
+// validate checks a survey fixture as the API checks a new survey and its
+// responses; fixtures are expected to be clean, so answer warnings are errors
+func (s seedSurvey) validate() []string {
+	var problems []string
+	if s.Title == "" {
+		problems = append(problems, "Title is required")
+	}
+	if s.Description == "" {
+		problems = append(problems, "Description is required")
 	}
-	response1JSON, _ := json.Marshal(response1Data)
+	if s.Status != "" && s.Status != SurveyStatusDraft && s.Status != SurveyStatusPublished {
+		problems = append(problems, "Status must be either draft or published")
+	}
+	problems = append(problems, validateQuestions(s.Questions)...)
 
-	response2Data := map[string]interface{}{
-		"overall_satisfaction":      "3",
-		"service_quality":           "4",
-		"recommendation_likelihood": "3",
-		"comments":                  "Service was okay, room for improvement.",
+	survey := Survey{Questions: s.Questions}
+	for i, r := range s.Responses {
+		label := fmt.Sprintf("responses[%d]", i)
+		if !s.Anonymous && (len(r.UserIdentifier) < 3 || len(r.UserIdentifier) > 100) {
+			problems = append(problems, label+": User identifier must be 3-100 characters long")
+		}
+		if isJSONNull(r.ResponseData) {
+			problems = append(problems, label+": Response data is required")
+			continue
+		}
+		for _, warning := range answerWarnings(survey, r.ResponseData) {
+			problems = append(problems, label+": "+warning)
+		}
 	}
-	response2JSON, _ := json.Marshal(response2Data)
+	return problems
+}
 
-	// Create sample responses for survey2
-	response3Data := map[string]interface{}{
-		"workplace_culture":  "4",
-		"job_satisfaction":   "5",
-		"work_life_balance":  "4",
-		"management_support": "5",
-		"suggestions":        "More team building activities would be great!",
+// seed creates the fixtures and count synthetic responses in one transaction,
+// after deleting every survey when reset is set. Synthetic responses are
+// shared among the published surveys with questions by weight.
+func seed(ctx context.Context, fixtures seedFixtures, count int, reset bool, rnd *rand.Rand) (seedResult, error) {
+	var result seedResult
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return result, err
 	}
-	response3JSON, _ := json.Marshal(response3Data)
+	defer tx.Rollback()
 
-	// Create sample responses for survey3
-	response4Data := map[string]interface{}{
-		"product_rating":      "4",
-		"feature_usefulness":  "5",
-		"ease_of_use":         "4",
-		"additional_features": "Mobile app would be helpful",
-		"overall_impression":  "Very good product!",
+	if reset {
+		statements := []string{"DELETE FROM response_revisions"}
+		for _, table := range surveyTables {
+			statements = append(statements, fmt.Sprintf("DELETE FROM %s WHERE survey_id IS NOT NULL", table))
+		}
+		statements = append(statements, "DELETE FROM surveys")
+		for _, statement := range statements {
+			if _, err := tx.ExecContext(ctx, statement); err != nil {
+				return result, err
+			}
+		}
 	}
-	response4JSON, _ := json.Marshal(response4Data)
 
-	// Insert responses
-	_, err = db.Exec("INSERT INTO survey_responses (survey_id, user_identifier, response_data) VALUES (?, ?, ?)", survey1ID, "user123", response1JSON)
+	insert, err := tx.PrepareContext(ctx, `
+		INSERT INTO survey_responses (survey_id, user_identifier, response_data, validation_warnings, created_at, updated_at)
+		VALUES (?, ?, ?, '[]', ?, ?)
+	`)
 	if err != nil {
-		log.Fatal(err)
+		return result, err
+	}
+	defer insert.Close()
+	addResponse := func(surveyID int64, s seedSurvey, userIdentifier string, data []byte, at time.Time) error {
+		if s.Anonymous {
+			userIdentifier = newRespondentToken()
+		}
+		stamp := at.UTC().Format(cursorTimeFormat)
+		if _, err := insert.ExecContext(ctx, surveyID, userIdentifier, sealResponseData(data), stamp, stamp); err != nil {
+			return err
+		}
+		result.Responses++
+		return nil
 	}
 
-	_, err = db.Exec("INSERT INTO survey_responses (survey_id, user_identifier, response_data) VALUES (?, ?, ?)", survey1ID, "user456", response2JSON)
-	if err != nil {
-		log.Fatal(err)
+	type target struct {
+		id     int64
+		survey seedSurvey
 	}
+	var targets []target
+	now := time.Now()
+	for _, s := range fixtures.Surveys {
+		status, allowMultiple := s.Status, true
+		if status == "" {
+			status = SurveyStatusPublished
+		}
+		if s.AllowMultipleResponses != nil {
+			allowMultiple = *s.AllowMultipleResponses
+		}
+		questions, _ := json.Marshal(s.Questions)
+		if s.Questions == nil {
+			questions = []byte("[]")
+		}
+		res, err := tx.ExecContext(ctx, `
+			INSERT INTO surveys (title, description, status, allow_multiple_responses, anonymous, questions)
+			VALUES (?, ?, ?, ?, ?, ?)
+		`, s.Title, s.Description, status, allowMultiple, s.Anonymous, string(questions))
+		if err != nil {
+			return result, err
+		}
+		id, _ := res.LastInsertId()
+		result.Surveys++
 
-	_, err = db.Exec("INSERT INTO survey_responses (survey_id, user_identifier, response_data) VALUES (?, ?, ?)", survey2ID, "employee001", response3JSON)
-	if err != nil {
-		log.Fatal(err)
+		for _, r := range s.Responses {
+			if err := addResponse(id, s, r.UserIdentifier, r.ResponseData, now); err != nil {
+				return result, err
+			}
+		}
+		if status == SurveyStatusPublished && len(s.Questions) > 0 {
+			targets = append(targets, target{id, s})
+		}
 	}
 
-	_, err = db.Exec("INSERT INTO survey_responses (survey_id, user_identifier, response_data) VALUES (?, ?, ?)", survey3ID, "customer789", response4JSON)
-	if err != nil {
-		log.Fatal(err)
+	if count > 0 && len(targets) == 0 {
+		return result, errors.New("synthetic responses need a published survey with questions")
+	}
+	weights := make([]float64, len(targets))
+	var total float64
+	for i, t := range targets {
+		weights[i] = t.survey.weight
+		if weights[i] <= 0 {
+			weights[i] = 1
+		}
+		total += weights[i]
+	}
+	quotas := make([]int, len(targets))
+	left := count
+	for i, w := range weights {
+		quotas[i] = int(float64(count) * w / total)
+		left -= quotas[i]
+	}
+	for i := 0; i < left; i++ {
+		quotas[i%len(quotas)]++
 	}
 
-	// Count created data
-	var surveyCount, responseCount int
-	db.QueryRow("SELECT COUNT(*) FROM surveys").Scan(&surveyCount)
-	db.QueryRow("SELECT COUNT(*) FROM survey_responses").Scan(&responseCount)
+	n := 0
+	for i, t := range targets {
+		for j := 0; j < quotas[i]; j++ {
+			n++
+			data, _ := json.Marshal(syntheticAnswers(rnd, t.survey.Questions))
+			at := now.Add(-time.Duration(rnd.Int63n(int64(seedSpan))))
+			if err := addResponse(t.id, t.survey, fakeRespondent(rnd, n), data, at); err != nil {
+				return result, err
+			}
+		}
+	}
 
-	fmt.Println("Sample data created successfully!")
-	fmt.Printf("Created %d surveys and %d responses\n", surveyCount, responseCount)
+	return result, tx.Commit()
 }
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSeed(t *testing.T) {
+	setupTestDB()
+	defer testDB.Close()
+	setupTestRouter()
+
+	fixtures, err := loadSeedFixtures(defaultSeedFixtures)
+	require.NoError(t, err)
+	require.Len(t, fixtures.Surveys, 3)
+
+	ctx := context.Background()
+	result, err := seed(ctx, fixtures, 30, false, rand.New(rand.NewSource(1)))
+	require.NoError(t, err)
+	assert.Equal(t, seedResult{Surveys: 3, Responses: 34}, result)
+
+	// Synthetic responses answer the questions of their survey
+	var count, warned int
+	testDB.QueryRow("SELECT COUNT(*) FROM survey_responses WHERE survey_id = 2").Scan(&count)
+	assert.Equal(t, 11, count)
+	survey, err := findSurvey(ctx, 1)
+	require.NoError(t, err)
+	rows, err := testDB.Query("SELECT response_data FROM survey_responses WHERE survey_id = 1")
+	require.NoError(t, err)
+	for rows.Next() {
+		var data []byte
+		rows.Scan(&data)
+		if len(answerWarnings(survey, data)) > 0 {
+			warned++
+		}
+	}
+	rows.Close()
+	assert.Zero(t, warned)
+	assert.Equal(t, 12, survey.ResponsesCount)
+
+	// Seeding again adds to the data unless reset
+	result, err = seed(ctx, fixtures, 0, true, rand.New(rand.NewSource(1)))
+	require.NoError(t, err)
+	testDB.QueryRow("SELECT COUNT(*) FROM survey_responses").Scan(&count)
+	assert.Equal(t, result.Responses, count)
+	testDB.QueryRow("SELECT COUNT(*) FROM surveys").Scan(&count)
+	assert.Equal(t, 3, count)
+
+	_, err = seed(ctx, seedFixtures{Surveys: []seedSurvey{{Title: "Empty", Description: "d"}}}, 5, false, rand.New(rand.NewSource(1)))
+	assert.Error(t, err)
+}
+
+func TestLoadSeedFixtures(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+		return path
+	}
+
+	// JSON files are read too
+	fixtures, err := loadSeedFixtures(write("seed.json", `{"surveys": [{"title": "Poll", "description": "d", "anonymous": true,
+		"questions": [{"id": "q", "type": "boolean", "label": "Yes?"}], "responses": [{"response_data": {"q": true}}]}]}`))
+	require.NoError(t, err)
+	assert.True(t, fixtures.Surveys[0].Anonymous)
+
+	_, err = loadSeedFixtures(write("unknown.yaml", "surveys:\n  - title: Poll\n    descripton: typo\n"))
+	assert.ErrorContains(t, err, "descripton")
+
+	_, err = loadSeedFixtures(write("invalid.yaml", `
+surveys:
+  - title: Poll
+    status: open
+    questions: [{id: q, type: rating, label: Rate us}]
+    responses:
+      - {user_identifier: ab, response_data: {q: 9, r: 1}}
+`))
+	require.Error(t, err)
+	for _, problem := range []string{
+		"surveys[0]: Description is required",
+		"surveys[0]: Status must be either draft or published",
+		"surveys[0]: responses[0]: User identifier must be 3-100 characters long",
+		"surveys[0]: responses[0]: r is not a question of this survey",
+	} {
+		assert.Contains(t, err.Error(), problem)
+	}
+}
+
+func TestSyntheticAnswers(t *testing.T) {
+	zero, ten, two := 0.0, 10.0, 2.0
+	survey := Survey{Questions: []Question{
+		{ID: "nps", Type: QuestionRating, Label: "NPS", Min: &zero, Max: &ten, Required: true},
+		{ID: "age", Type: QuestionNumber, Label: "Age", Min: &ten, Required: true},
+		{ID: "ok", Type: QuestionBoolean, Label: "OK?"},
+		{ID: "color", Type: QuestionSingleChoice, Label: "Color", Options: []string{"Red", "Blue"}},
+		{ID: "days", Type: QuestionMultipleChoice, Label: "Days", Options: []string{"Mon", "Tue", "Wed"}, Max: &two, Required: true},
+		{ID: "note", Type: QuestionText, Label: "Note"},
+	}}
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < 200; i++ {
+		answers := syntheticAnswers(rnd, survey.Questions)
+		data, _ := json.Marshal(answers)
+		assert.Empty(t, answerWarnings(survey, data), string(data))
+		assert.NotEmpty(t, answers["days"])
+		assert.LessOrEqual(t, len(answers["days"].([]string)), 2)
+	}
+}
+
+func TestGenerateSurveys(t *testing.T) {
+	setupTestDB()
+	defer testDB.Close()
+	setupTestRouter()
+
+	rnd := rand.New(rand.NewSource(1))
+	surveys := generateSurveys(rnd, 40)
+	require.Len(t, surveys, 40)
+	for i, s := range surveys {
+		assert.Empty(t, s.validate(), "surveys[%d]", i)
+		assert.True(t, s.Questions[0].Required)
+		assert.Positive(t, s.weight)
+	}
+
+	result, err := seed(context.Background(), seedFixtures{Surveys: surveys}, 2000, false, rnd)
+	require.NoError(t, err)
+	assert.Equal(t, seedResult{Surveys: 40, Responses: 2000}, result)
+
+	// Popular surveys get more responses, and drafts none
+	var drafts, fewest, most int
+	testDB.QueryRow("SELECT COUNT(*) FROM survey_responses sr JOIN surveys s ON s.id = sr.survey_id WHERE s.status = 'draft'").Scan(&drafts)
+	assert.Zero(t, drafts)
+	testDB.QueryRow(`SELECT MIN(n), MAX(n) FROM (SELECT COUNT(*) AS n FROM survey_responses GROUP BY survey_id)`).Scan(&fewest, &most)
+	assert.Greater(t, most, 3*fewest)
+	var respondents int
+	testDB.QueryRow("SELECT COUNT(DISTINCT user_identifier) FROM survey_responses").Scan(&respondents)
+	assert.Equal(t, 2000, respondents)
+}
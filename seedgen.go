@@ -0,0 +1,207 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
+	"time"
+)
+
+// Synthetic data for load testing: surveys made up from the topics below, and
+// respondents with made-up names whose answers follow one mood per response,
+// so ratings, yes/no answers and comments agree as in real data
+
+// seedFirstNames and seedLastNames make up respondents' email addresses
+var (
+	seedFirstNames = []string{
+		"Ana", "Ben", "Carla", "David", "Elena", "Farid", "Grace", "Hiro", "Ines", "James",
+		"Kofi", "Lena", "Mateo", "Nadia", "Omar", "Priya", "Quinn", "Rosa", "Sam", "Tara",
+		"Uma", "Victor", "Wei", "Ximena", "Yusuf", "Zoe",
+	}
+	seedLastNames = []string{
+		"Garcia", "Smith", "Kim", "Nguyen", "Okafor", "Rossi", "Müller", "Silva", "Khan", "Cohen",
+		"Ivanova", "Tanaka", "Dubois", "Hansen", "Novak", "Lopez", "Murphy", "Singh", "Chen", "Ali",
+	}
+)
+
+// seedComments are pieces of comments by mood: negative, neutral and positive
+var seedComments = [3]struct{ openers, subjects, closers []string }{
+	{
+		openers:  []string{"Honestly, ", "Sadly, ", "", "To be frank, "},
+		subjects: []string{"the support team", "the checkout", "the new release", "the onboarding", "the pricing", "the app"},
+		closers:  []string{" was a letdown.", " took far too long.", " kept failing for me.", " is confusing.", " needs a lot of work."},
+	},
+	{
+		openers:  []string{"", "Overall ", "I think ", "Mostly, "},
+		subjects: []string{"the support team", "the checkout", "the new release", "the onboarding", "the pricing", "the app"},
+		closers:  []string{" was fine.", " is okay, with room for improvement.", " does the job.", " could be clearer."},
+	},
+	{
+		openers:  []string{"", "Really happy: ", "Great job, ", "Loved it! "},
+		subjects: []string{"the support team", "the checkout", "the new release", "the onboarding", "the pricing", "the app"},
+		closers:  []string{" was excellent.", " is fast and friendly.", " made my day easier.", " exceeded my expectations."},
+	},
+}
+
+// seedTopic is the kind of survey generated surveys are made from: the first
+// question is always asked, and some of the others
+type seedTopic struct {
+	title, description string
+	questions          []Question
+}
+
+func seedFloat(f float64) *float64 { return &f }
+
+var seedTopics = []seedTopic{
+	{"Customer Satisfaction", "Tell us how we did on your recent experience.", []Question{
+		{ID: "overall_satisfaction", Type: QuestionRating, Label: "How satisfied are you overall?", Required: true},
+		{ID: "recommendation_likelihood", Type: QuestionRating, Label: "How likely are you to recommend us to a friend?", Min: seedFloat(0), Max: seedFloat(10)},
+		{ID: "service_quality", Type: QuestionRating, Label: "How would you rate the quality of our service?"},
+		{ID: "resolved", Type: QuestionBoolean, Label: "Was your issue resolved?"},
+		{ID: "contact_channel", Type: QuestionSingleChoice, Label: "How did you contact us?", Options: []string{"Phone", "Email", "Chat", "In store"}},
+		{ID: "comments", Type: QuestionText, Label: "Anything else you'd like to tell us?"},
+	}},
+	{"Employee Engagement", "Share your thoughts about workplace culture and satisfaction.", []Question{
+		{ID: "job_satisfaction", Type: QuestionRating, Label: "How satisfied are you with your job?", Required: true},
+		{ID: "workplace_culture", Type: QuestionRating, Label: "How would you rate our workplace culture?"},
+		{ID: "work_life_balance", Type: QuestionRating, Label: "How is your work-life balance?"},
+		{ID: "department", Type: QuestionSingleChoice, Label: "Which department are you in?", Options: []string{"Engineering", "Sales", "Marketing", "Support", "Finance", "Operations"}},
+		{ID: "years_at_company", Type: QuestionNumber, Label: "How many years have you been with us?", Min: seedFloat(0), Max: seedFloat(30)},
+		{ID: "would_stay", Type: QuestionBoolean, Label: "Do you see yourself here in two years?"},
+		{ID: "suggestions", Type: QuestionText, Label: "What could we do better?"},
+	}},
+	{"Product Feedback", "Tell us what you think about our product and what we should build next.", []Question{
+		{ID: "product_rating", Type: QuestionRating, Label: "How would you rate the product?", Required: true},
+		{ID: "recommendation_likelihood", Type: QuestionRating, Label: "How likely are you to recommend the product?", Min: seedFloat(0), Max: seedFloat(10)},
+		{ID: "features_used", Type: QuestionMultipleChoice, Label: "Which features do you use?", Options: []string{"Dashboards", "Reports", "Integrations", "Mobile app", "API", "Alerts"}},
+		{ID: "plan", Type: QuestionSingleChoice, Label: "Which plan are you on?", Options: []string{"Free", "Starter", "Pro", "Enterprise"}},
+		{ID: "ease_of_use", Type: QuestionRating, Label: "How easy is the product to use?"},
+		{ID: "next_feature", Type: QuestionText, Label: "Which feature would you like next?"},
+	}},
+	{"Event Feedback", "Help us plan the next event by rating this one.", []Question{
+		{ID: "event_rating", Type: QuestionRating, Label: "How would you rate the event?", Required: true},
+		{ID: "sessions_attended", Type: QuestionNumber, Label: "How many sessions did you attend?", Min: seedFloat(1), Max: seedFloat(12)},
+		{ID: "favorite_track", Type: QuestionSingleChoice, Label: "Which track did you enjoy most?", Options: []string{"Keynotes", "Workshops", "Panels", "Networking"}},
+		{ID: "attend_again", Type: QuestionBoolean, Label: "Would you attend again?"},
+		{ID: "venue_rating", Type: QuestionRating, Label: "How was the venue?"},
+		{ID: "feedback", Type: QuestionText, Label: "Any other feedback?"},
+	}},
+	{"Website Usability", "A few questions about finding your way around our website.", []Question{
+		{ID: "found_what_needed", Type: QuestionBoolean, Label: "Did you find what you were looking for?", Required: true},
+		{ID: "ease_of_navigation", Type: QuestionRating, Label: "How easy was the site to navigate?"},
+		{ID: "visit_reason", Type: QuestionSingleChoice, Label: "Why did you visit today?", Options: []string{"Buy something", "Get support", "Compare prices", "Just browsing"}},
+		{ID: "devices", Type: QuestionMultipleChoice, Label: "Which devices do you use to visit us?", Options: []string{"Phone", "Tablet", "Laptop", "Desktop"}},
+		{ID: "visits_per_month", Type: QuestionNumber, Label: "How often do you visit per month?", Min: seedFloat(0), Max: seedFloat(50)},
+		{ID: "improvements", Type: QuestionText, Label: "What would make the site better?"},
+	}},
+}
+
+// generateSurveys makes up n surveys from the seed topics. Most are published,
+// and they differ in popularity, so some get many more responses than others.
+func generateSurveys(rnd *rand.Rand, n int) []seedSurvey {
+	surveys := make([]seedSurvey, n)
+	now := time.Now()
+	for i := range surveys {
+		topic := seedTopics[rnd.Intn(len(seedTopics))]
+		period := now.AddDate(0, -rnd.Intn(24), 0)
+		s := seedSurvey{
+			Title:       fmt.Sprintf("%s – %s %d", topic.title, period.Month(), period.Year()),
+			Description: topic.description,
+			Anonymous:   rnd.Intn(10) == 0,
+			Questions:   []Question{topic.questions[0]},
+			weight:      0.2 + rnd.ExpFloat64(),
+		}
+		for _, q := range topic.questions[1:] {
+			if rnd.Intn(4) != 0 {
+				s.Questions = append(s.Questions, q)
+			}
+		}
+		if rnd.Intn(20) == 0 {
+			s.Status = SurveyStatusDraft
+		}
+		if rnd.Intn(10) == 0 {
+			allowMultiple := false
+			s.AllowMultipleResponses = &allowMultiple
+		}
+		surveys[i] = s
+	}
+	return surveys
+}
+
+// fakeRespondent makes up the email address of the nth synthetic respondent
+func fakeRespondent(rnd *rand.Rand, n int) string {
+	first := seedFirstNames[rnd.Intn(len(seedFirstNames))]
+	last := seedLastNames[rnd.Intn(len(seedLastNames))]
+	return strings.ToLower(fmt.Sprintf("%s.%s%d@example.com", first, last, n))
+}
+
+// fakeComment makes up a comment of a respondent in a mood from 0 to 1
+func fakeComment(rnd *rand.Rand, mood float64) string {
+	pieces := seedComments[int(math.Min(mood*3, 2))]
+	pick := func(s []string) string { return s[rnd.Intn(len(s))] }
+	subject := pick(pieces.subjects)
+	opener := pick(pieces.openers)
+	if opener == "" {
+		subject = strings.ToUpper(subject[:1]) + subject[1:]
+	}
+	return opener + subject + pick(pieces.closers)
+}
+
+// syntheticAnswers makes up valid answers to questions, following one mood
+// that leans positive; optional questions are left unanswered now and then
+func syntheticAnswers(rnd *rand.Rand, questions []Question) map[string]interface{} {
+	mood := 1 - rnd.Float64()*rnd.Float64()
+	answers := map[string]interface{}{}
+	for _, q := range questions {
+		if !q.Required && rnd.Intn(5) == 0 {
+			continue
+		}
+		switch q.Type {
+		case QuestionText:
+			answers[q.ID] = fakeComment(rnd, mood)
+		case QuestionNumber:
+			min, max := 0.0, 100.0
+			if q.Min != nil {
+				min = *q.Min
+			}
+			if q.Max != nil {
+				max = *q.Max
+			}
+			answers[q.ID] = min + float64(rnd.Intn(int(max-min)+1))
+		case QuestionRating:
+			min, max := float64(defaultRatingMin), float64(defaultRatingMax)
+			if q.Min != nil {
+				min = *q.Min
+			}
+			if q.Max != nil {
+				max = *q.Max
+			}
+			// The mood, give or take a point
+			rating := math.Round(min + mood*(max-min) + float64(rnd.Intn(3)-1))
+			answers[q.ID] = math.Max(min, math.Min(max, rating))
+		case QuestionBoolean:
+			answers[q.ID] = rnd.Float64() < mood
+		case QuestionSingleChoice:
+			answers[q.ID] = q.Options[rnd.Intn(len(q.Options))]
+		case QuestionMultipleChoice:
+			min, max := 1, len(q.Options)
+			if q.Min != nil && int(*q.Min) > min {
+				min = int(*q.Min)
+			}
+			if q.Max != nil && int(*q.Max) < max {
+				max = int(*q.Max)
+			}
+			if min > max {
+				min = max
+			}
+			n := min + rnd.Intn(max-min+1)
+			var choices []string
+			for _, i := range rnd.Perm(len(q.Options))[:n] {
+				choices = append(choices, q.Options[i])
+			}
+			answers[q.ID] = choices
+		}
+	}
+	return answers
+}
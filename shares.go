@@ -0,0 +1,263 @@
+package main
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Share links are public, so guessing them is limited per client IP
+var shareIPRateLimit = envRateLimit("RATE_LIMIT_SHARE_IP", RateLimit{Requests: 60, Per: time.Minute})
+
+// ShareLink is a public link to a survey's form: anyone with its URL can see
+// the survey and its questions, never its responses
+type ShareLink struct {
+	ID       int `json:"id"`
+	SurveyID int `json:"survey_id"`
+	// Prefix identifies the link without revealing it
+	Prefix string `json:"prefix"`
+	// Token and URL are only returned when the link is created
+	Token string `json:"token,omitempty"`
+	URL   string `json:"url,omitempty"`
+	// CanonicalURL is the absolute URL of the link, on the custom domain of the
+	// survey's organization when it has one
+	CanonicalURL string     `json:"canonical_url,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+	RevokedAt    *time.Time `json:"revoked_at,omitempty"`
+}
+
+// SharedSurvey is what a share link exposes of a survey
+type SharedSurvey struct {
+	ID                 int                          `json:"id"`
+	Title              string                       `json:"title"`
+	Description        string                       `json:"description"`
+	Questions          []Question                   `json:"questions"`
+	Pages              []Page                       `json:"pages,omitempty"`
+	Logic              []LogicRule                  `json:"logic,omitempty"`
+	Translations       map[string]SurveyTranslation `json:"translations,omitempty"`
+	OpensAt            *time.Time                   `json:"opens_at,omitempty"`
+	ClosesAt           *time.Time                   `json:"closes_at,omitempty"`
+	AcceptingResponses bool                         `json:"accepting_responses"`
+}
+
+// shareLinkColumns lists the columns read by scanShareLink
+const shareLinkColumns = "id, survey_id, prefix, created_at, revoked_at"
+
+// scanShareLink scans a row selected with shareLinkColumns
+func scanShareLink(row rowScanner) (ShareLink, error) {
+	var link ShareLink
+	var revokedAt sql.NullTime
+	err := row.Scan(&link.ID, &link.SurveyID, &link.Prefix, &link.CreatedAt, &revokedAt)
+	link.RevokedAt = nullTimePtr(revokedAt)
+	return link, err
+}
+
+// sharedSurvey returns what share links expose of a survey. Recodes and PII
+// flags are for analysis and stay out.
+func sharedSurvey(survey Survey) SharedSurvey {
+	questions := make([]Question, len(survey.Questions))
+	for i, q := range survey.Questions {
+		q.Recodes, q.PII = nil, false
+		questions[i] = q
+	}
+	return SharedSurvey{
+		ID:                 survey.ID,
+		Title:              survey.Title,
+		Description:        survey.Description,
+		Questions:          questions,
+		Pages:              survey.Pages,
+		Logic:              survey.Logic,
+		Translations:       survey.Translations,
+		OpensAt:            survey.OpensAt,
+		ClosesAt:           survey.ClosesAt,
+		AcceptingResponses: survey.AcceptingResponses,
+	}
+}
+
+// shareParams reads the survey ID and, when present, the share link ID of a
+// share route, responding with the error when they are invalid
+func shareParams(c *gin.Context) (surveyID, shareID int, ok bool) {
+	surveyID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		abortWithError(c, errInvalidID("survey"))
+		return 0, 0, false
+	}
+	if c.Param("share_id") == "" {
+		return surveyID, 0, true
+	}
+	shareID, err = strconv.Atoi(c.Param("share_id"))
+	if err != nil {
+		abortWithError(c, errInvalidID("share link"))
+		return 0, 0, false
+	}
+	return surveyID, shareID, true
+}
+
+// findActiveShareLink loads a survey's unrevoked share link, responding 404 when there is none
+func findActiveShareLink(c *gin.Context, surveyID, shareID int) (ShareLink, bool) {
+	link, err := scanShareLink(db.QueryRowContext(c.Request.Context(),
+		"SELECT "+shareLinkColumns+" FROM survey_share_links WHERE id = ? AND survey_id = ? AND revoked_at IS NULL",
+		shareID, surveyID))
+	if err == sql.ErrNoRows {
+		abortWithError(c, errNotFound(CodeShareLinkNotFound, "Share link not found"))
+		return link, false
+	}
+	if err != nil {
+		abortWithError(c, errInternal("Failed to fetch share link", err))
+		return link, false
+	}
+	return link, true
+}
+
+// getShareLinks lists a survey's share links, including revoked ones, without their tokens
+func getShareLinks(c *gin.Context) {
+	surveyID, _, ok := shareParams(c)
+	if !ok {
+		return
+	}
+
+	rows, err := db.QueryContext(c.Request.Context(), "SELECT "+shareLinkColumns+" FROM survey_share_links WHERE survey_id = ? ORDER BY id", surveyID)
+	if err != nil {
+		abortWithError(c, errInternal("Failed to fetch share links", err))
+		return
+	}
+	defer rows.Close()
+
+	links := []ShareLink{}
+	for rows.Next() {
+		link, err := scanShareLink(rows)
+		if err != nil {
+			abortWithError(c, errInternal("Failed to scan share link data", err))
+			return
+		}
+		links = append(links, link)
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Status: "success",
+		Data:   links,
+	})
+}
+
+// createShareLink creates a public link to a survey; its token is shown once
+func createShareLink(c *gin.Context) {
+	ctx := c.Request.Context()
+	surveyID, _, ok := shareParams(c)
+	if !ok {
+		return
+	}
+
+	var orgID sql.NullInt64
+	if err := db.QueryRowContext(ctx, "SELECT org_id FROM surveys WHERE id = ?", surveyID).Scan(&orgID); err != nil {
+		abortWithError(c, errNotFound(CodeSurveyNotFound, "Survey not found"))
+		return
+	}
+
+	// 128 random bits, hex encoded so the link is URL safe
+	token := randomHex(16)
+	result, err := db.ExecContext(ctx, `
+		INSERT INTO survey_share_links (survey_id, prefix, token_hash, created_at)
+		VALUES (?, ?, ?, CURRENT_TIMESTAMP)
+	`, surveyID, token[:8], hashSurveyToken(token))
+	if err != nil {
+		abortWithError(c, errInternal("Failed to create share link", err))
+		return
+	}
+
+	id, _ := result.LastInsertId()
+	link, ok := findActiveShareLink(c, surveyID, int(id))
+	if !ok {
+		return
+	}
+	auditChange(c, "create", "share_link", link.ID, nil, link)
+
+	link.Token = token
+	link.URL = "/s/" + token
+	link.CanonicalURL = organizationBaseURL(ctx, nullIntPtr(orgID)) + link.URL
+	c.JSON(http.StatusCreated, APIResponse{
+		Status:  "success",
+		Message: "Share link created successfully",
+		Data:    link,
+	})
+}
+
+// revokeShareLink disables a share link; revoked links stay listed for reference
+func revokeShareLink(c *gin.Context) {
+	surveyID, shareID, ok := shareParams(c)
+	if !ok {
+		return
+	}
+	before, ok := findActiveShareLink(c, surveyID, shareID)
+	if !ok {
+		return
+	}
+
+	if _, err := db.ExecContext(c.Request.Context(), "UPDATE survey_share_links SET revoked_at = CURRENT_TIMESTAMP WHERE id = ?", shareID); err != nil {
+		abortWithError(c, errInternal("Failed to revoke share link", err))
+		return
+	}
+	auditChange(c, "delete", "share_link", shareID, before, nil)
+
+	c.JSON(http.StatusOK, APIResponse{
+		Status:  "success",
+		Message: "Share link revoked successfully",
+	})
+}
+
+// findSharedSurvey loads the survey of an active share link token; unknown
+// and revoked tokens both give sql.ErrNoRows, as do the surveys of other
+// organizations on a custom domain
+func findSharedSurvey(c *gin.Context, token string) (Survey, error) {
+	ctx := c.Request.Context()
+	var surveyID int
+	err := db.QueryRowContext(ctx,
+		"SELECT survey_id FROM survey_share_links WHERE token_hash = ? AND revoked_at IS NULL",
+		hashSurveyToken(token)).Scan(&surveyID)
+	if err != nil {
+		return Survey{}, err
+	}
+	survey, err := findSurvey(ctx, surveyID)
+	if err == nil && !servesSurvey(c, survey) {
+		return Survey{}, sql.ErrNoRows
+	}
+	return survey, err
+}
+
+// getSharedSurvey serves the survey of a share link. Unknown and revoked
+// links look alike; drafts are coming soon, as through the API.
+func getSharedSurvey(c *gin.Context) {
+	survey, err := findSharedSurvey(c, c.Param("token"))
+	if err != nil || !survey.Indexable {
+		noIndex(c)
+	}
+	if err == sql.ErrNoRows {
+		abortWithError(c, errNotFound(CodeShareLinkNotFound, "Share link not found"))
+		return
+	}
+	if err != nil {
+		abortWithError(c, errInternal("Failed to fetch shared survey", err))
+		return
+	}
+	if survey.Embargoed() {
+		c.JSON(http.StatusOK, APIResponse{
+			Status:  "success",
+			Message: "Survey coming soon",
+			Data: ComingSoon{
+				ID:         survey.ID,
+				GlobalID:   survey.GlobalID,
+				Status:     survey.Status,
+				PublishAt:  survey.PublishAt,
+				ComingSoon: true,
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Status: "success",
+		Data:   sharedSurvey(survey),
+	})
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShareLinks(t *testing.T) {
+	setupTestDB()
+	defer testDB.Close()
+	router := setupTestRouter()
+
+	result, _ := testDB.Exec(`INSERT INTO surveys (title, description, questions) VALUES ('Feedback', 'd', ?)`,
+		`[{"id":"nps","type":"rating","label":"How likely?","pii":true,"recodes":{"top":{"5":"yes"}}}]`)
+	surveyID, _ := result.LastInsertId()
+	testDB.Exec("INSERT INTO survey_responses (survey_id, user_identifier, response_data) VALUES (?, 'user1', '{\"nps\": 5}')", surveyID)
+
+	send := func(method, url string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, url, nil)
+		router.ServeHTTP(w, req)
+		return w
+	}
+	base := fmt.Sprintf("/api/surveys/%d/share", surveyID)
+
+	w := send("POST", base)
+	assert.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var created struct {
+		Data ShareLink `json:"data"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &created)
+	link := created.Data
+	assert.Len(t, link.Token, 32)
+	assert.Equal(t, "/s/"+link.Token, link.URL)
+	assert.Equal(t, publicURL+link.URL, link.CanonicalURL)
+	assert.Equal(t, link.Token[:8], link.Prefix)
+
+	// The link shows the form, without responses or analysis settings
+	w = send("GET", link.URL)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	body := w.Body.String()
+	assert.Contains(t, body, `"title":"Feedback"`)
+	assert.Contains(t, body, `"label":"How likely?"`)
+	for _, hidden := range []string{"responses_count", "recodes", "pii", "user1"} {
+		assert.NotContains(t, body, hidden)
+	}
+
+	w = send("GET", base)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), link.Token)
+
+	assert.Equal(t, http.StatusNotFound, send("GET", "/s/"+strings.Repeat("0", 32)).Code)
+	assert.Equal(t, http.StatusNotFound, send("POST", "/api/surveys/999/share").Code)
+
+	w = send("DELETE", fmt.Sprintf("%s/%d", base, link.ID))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, http.StatusNotFound, send("GET", link.URL).Code)
+	assert.Equal(t, http.StatusNotFound, send("DELETE", fmt.Sprintf("%s/%d", base, link.ID)).Code)
+}
@@ -0,0 +1,484 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Google endpoints, replaced in tests
+var (
+	googleTokenURL = "https://oauth2.googleapis.com/token"
+	sheetsAPIURL   = "https://sheets.googleapis.com"
+	sheetsClient   = &http.Client{Timeout: 30 * time.Second}
+)
+
+// sheetsBatchSize is how many responses are appended per Sheets API call
+const sheetsBatchSize = 200
+
+// sheetsFixedColumns start every row, before one column per question
+var sheetsFixedColumns = []string{"response_id", "created_at", "user_identifier"}
+
+// SheetSync appends a survey's responses to a Google Sheet. The OAuth client
+// secret and refresh token are write-only and stored encrypted when
+// RESPONSE_DATA_KEY is set.
+type SheetSync struct {
+	SurveyID      int    `json:"survey_id"`
+	SpreadsheetID string `json:"spreadsheet_id"`
+	SheetName     string `json:"sheet_name"`
+	ClientID      string `json:"client_id"`
+	// LastResponseID is the last new response appended
+	LastResponseID int `json:"last_response_id"`
+	// BackfillThrough is the last response received before the sync was
+	// configured; those are only appended by a backfill, 0 once done
+	BackfillThrough int        `json:"backfill_through"`
+	LastSyncedAt    *time.Time `json:"last_synced_at"`
+	LastError       string     `json:"last_error,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+
+	clientSecret  string
+	refreshToken  string
+	headerWritten bool
+}
+
+// SaveSheetSyncRequest represents the request body for configuring a Google Sheets sync
+type SaveSheetSyncRequest struct {
+	Sheets struct {
+		SpreadsheetID string `json:"spreadsheet_id" binding:"required"`
+		SheetName     string `json:"sheet_name"`
+		ClientID      string `json:"client_id" binding:"required"`
+		ClientSecret  string `json:"client_secret" binding:"required"`
+		RefreshToken  string `json:"refresh_token" binding:"required"`
+	} `json:"sheets" binding:"required"`
+}
+
+// SheetBackfill reports the responses a backfill appended
+type SheetBackfill struct {
+	Appended int `json:"appended"`
+}
+
+// sheetSyncColumns lists the columns read by scanSheetSync
+const sheetSyncColumns = "survey_id, spreadsheet_id, sheet_name, client_id, client_secret, refresh_token, last_response_id, backfill_through, header_written, last_synced_at, last_error, created_at"
+
+// scanSheetSync scans a row selected with sheetSyncColumns, decrypting its credentials
+func scanSheetSync(row rowScanner) (SheetSync, error) {
+	var s SheetSync
+	var secret, token []byte
+	var lastSyncedAt sql.NullTime
+	var lastError sql.NullString
+	err := row.Scan(&s.SurveyID, &s.SpreadsheetID, &s.SheetName, &s.ClientID, &secret, &token,
+		&s.LastResponseID, &s.BackfillThrough, &s.headerWritten, &lastSyncedAt, &lastError, &s.CreatedAt)
+	if err != nil {
+		return s, err
+	}
+	s.LastSyncedAt = nullTimePtr(lastSyncedAt)
+	s.LastError = lastError.String
+	if secret, err = decryptResponseData(secret); err != nil {
+		return s, err
+	}
+	if token, err = decryptResponseData(token); err != nil {
+		return s, err
+	}
+	s.clientSecret, s.refreshToken = string(secret), string(token)
+	return s, nil
+}
+
+// findSheetSync loads the Google Sheets sync of a survey
+func findSheetSync(ctx context.Context, surveyID int) (SheetSync, error) {
+	return scanSheetSync(db.QueryRowContext(ctx, "SELECT "+sheetSyncColumns+" FROM survey_sheets WHERE survey_id = ?", surveyID))
+}
+
+// sheetsAccessToken trades the sync's refresh token for an access token
+func sheetsAccessToken(ctx context.Context, s SheetSync) (string, error) {
+	form := url.Values{
+		"client_id":     {s.ClientID},
+		"client_secret": {s.clientSecret},
+		"refresh_token": {s.refreshToken},
+		"grant_type":    {"refresh_token"},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, googleTokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := sheetsClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+		Error       string `json:"error"`
+	}
+	json.NewDecoder(resp.Body).Decode(&token)
+	if resp.StatusCode != http.StatusOK || token.AccessToken == "" {
+		return "", fmt.Errorf("refreshing the Google access token failed with status %d %s", resp.StatusCode, token.Error)
+	}
+	return token.AccessToken, nil
+}
+
+// appendSheetRows appends rows after the last row of the sync's sheet
+func appendSheetRows(ctx context.Context, s SheetSync, accessToken string, rows [][]interface{}) error {
+	sheetRange := url.PathEscape("'" + strings.ReplaceAll(s.SheetName, "'", "''") + "'!A1")
+	endpoint := fmt.Sprintf("%s/v4/spreadsheets/%s/values/%s:append?valueInputOption=RAW&insertDataOption=INSERT_ROWS",
+		sheetsAPIURL, url.PathEscape(s.SpreadsheetID), sheetRange)
+	body, err := json.Marshal(map[string]interface{}{"values": rows})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	resp, err := sheetsClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("appending to the sheet failed with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// sheetRow lays out a response as a row: the fixed columns, then the answer
+// of each question, JSON encoded unless it is a string or number
+func sheetRow(questions []Question, response SurveyResponse) []interface{} {
+	row := []interface{}{response.ID, response.CreatedAt.UTC().Format(time.RFC3339), response.UserIdentifier}
+	var answers map[string]interface{}
+	json.Unmarshal(response.ResponseData, &answers)
+	for _, q := range questions {
+		switch answer := answers[q.ID].(type) {
+		case nil:
+			row = append(row, "")
+		case string, float64:
+			row = append(row, answer)
+		default:
+			encoded, _ := json.Marshal(answer)
+			row = append(row, string(encoded))
+		}
+	}
+	return row
+}
+
+// sheetHeader is the first row of a sheet, labelling the columns of sheetRow
+func sheetHeader(questions []Question) []interface{} {
+	header := []interface{}{}
+	for _, column := range sheetsFixedColumns {
+		header = append(header, column)
+	}
+	for _, q := range questions {
+		header = append(header, q.Label)
+	}
+	return header
+}
+
+// syncSheet appends a batch of the survey's responses with IDs in (after,
+// through], oldest first, returning how many and the last ID appended. The
+// first rows appended to a sheet start with a header.
+func syncSheet(ctx context.Context, s *SheetSync, accessToken string, after, through int) (int, int, error) {
+	survey, err := findSurvey(ctx, s.SurveyID)
+	if err != nil {
+		return 0, after, err
+	}
+	rows, err := db.QueryContext(ctx, `
+		SELECT `+responseColumns+` FROM `+responsesFrom+`
+		WHERE sr.survey_id = ? AND sr.deleted_at IS NULL AND sr.id > ? AND sr.id <= ?
+		ORDER BY sr.id LIMIT ?
+	`, s.SurveyID, after, through, sheetsBatchSize)
+	if err != nil {
+		return 0, after, err
+	}
+	var values [][]interface{}
+	last := after
+	for rows.Next() {
+		response, err := scanResponse(rows)
+		if err != nil {
+			rows.Close()
+			return 0, after, err
+		}
+		values = append(values, sheetRow(survey.Questions, response))
+		last = response.ID
+	}
+	rows.Close()
+	if len(values) == 0 {
+		return 0, after, rows.Err()
+	}
+	n := len(values)
+	if !s.headerWritten {
+		values = append([][]interface{}{sheetHeader(survey.Questions)}, values...)
+	}
+	if err := appendSheetRows(ctx, *s, accessToken, values); err != nil {
+		return 0, after, err
+	}
+	if !s.headerWritten {
+		s.headerWritten = true
+		if _, err := db.ExecContext(ctx, "UPDATE survey_sheets SET header_written = 1 WHERE survey_id = ?", s.SurveyID); err != nil {
+			return n, last, err
+		}
+	}
+	return n, last, nil
+}
+
+// recordSheetError keeps the error of a sync's last attempt, nil clearing it
+func recordSheetError(ctx context.Context, surveyID int, err error) {
+	var message interface{}
+	if err != nil {
+		message = err.Error()
+	}
+	db.ExecContext(ctx, "UPDATE survey_sheets SET last_error = ? WHERE survey_id = ?", message, surveyID)
+}
+
+// syncNewResponses appends the responses each survey with a Google Sheets
+// sync received since its last run. Failed appends are retried on the next
+// run, so a sheet catches up after Google or the credentials recover.
+func syncNewResponses(ctx context.Context, now time.Time) (int, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT `+sheetSyncColumns+` FROM survey_sheets ss
+		WHERE EXISTS (SELECT 1 FROM survey_responses sr WHERE sr.survey_id = ss.survey_id AND sr.id > ss.last_response_id AND sr.deleted_at IS NULL)
+	`)
+	if err != nil {
+		return 0, err
+	}
+	var syncs []SheetSync
+	for rows.Next() {
+		s, err := scanSheetSync(rows)
+		if err != nil {
+			rows.Close()
+			return 0, err
+		}
+		syncs = append(syncs, s)
+	}
+	rows.Close()
+
+	appended := 0
+	for _, s := range syncs {
+		token, err := sheetsAccessToken(ctx, s)
+		if err != nil {
+			log.Printf("Sheets: failed to sync survey %d: %v", s.SurveyID, err)
+			recordSheetError(ctx, s.SurveyID, err)
+			continue
+		}
+		after := s.LastResponseID
+		for {
+			n, last, err := syncSheet(ctx, &s, token, after, math.MaxInt32)
+			if err != nil {
+				log.Printf("Sheets: failed to sync survey %d: %v", s.SurveyID, err)
+				recordSheetError(ctx, s.SurveyID, err)
+				break
+			}
+			if n == 0 {
+				break
+			}
+			_, err = db.ExecContext(ctx, "UPDATE survey_sheets SET last_response_id = ?, last_synced_at = ?, last_error = NULL WHERE survey_id = ?",
+				last, now.UTC(), s.SurveyID)
+			if err != nil {
+				return appended, err
+			}
+			appended += n
+			after = last
+		}
+	}
+	return appended, nil
+}
+
+// sheetSyncParams reads the survey ID of a sheets route
+func sheetSyncParams(c *gin.Context) (int, bool) {
+	surveyID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		abortWithError(c, errInvalidID("survey"))
+		return 0, false
+	}
+	return surveyID, true
+}
+
+// findSheetSyncOr404 loads a survey's sync, responding 404 when there is none
+func findSheetSyncOr404(c *gin.Context, surveyID int) (SheetSync, bool) {
+	s, err := findSheetSync(c.Request.Context(), surveyID)
+	if err == sql.ErrNoRows {
+		abortWithError(c, errNotFound(CodeSheetSyncNotFound, "Google Sheets sync not found"))
+		return s, false
+	}
+	if err != nil {
+		abortWithError(c, errInternal("Failed to fetch Google Sheets sync", err))
+		return s, false
+	}
+	return s, true
+}
+
+// getSheetSync returns a survey's Google Sheets sync, without its credentials
+func getSheetSync(c *gin.Context) {
+	surveyID, ok := sheetSyncParams(c)
+	if !ok {
+		return
+	}
+	s, ok := findSheetSyncOr404(c, surveyID)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Status: "success",
+		Data:   s,
+	})
+}
+
+// saveSheetSync configures the Google Sheet a survey's new responses are
+// appended to. Responses received before are left to a backfill.
+func saveSheetSync(c *gin.Context) {
+	ctx := c.Request.Context()
+	surveyID, ok := sheetSyncParams(c)
+	if !ok {
+		return
+	}
+	var req SaveSheetSyncRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		abortWithError(c, errInvalidRequest(err))
+		return
+	}
+	if _, err := findSurvey(ctx, surveyID); err != nil {
+		abortWithError(c, errNotFound(CodeSurveyNotFound, "Survey not found"))
+		return
+	}
+	sheet := req.Sheets
+	if sheet.SheetName == "" {
+		sheet.SheetName = "Sheet1"
+	}
+	if len(sheet.SheetName) > 100 {
+		abortWithError(c, errValidation("Failed to save Google Sheets sync", []string{"Sheet name must be at most 100 characters"}))
+		return
+	}
+
+	// A sync that is reconfigured carries on where it was
+	var before interface{}
+	if s, err := findSheetSync(ctx, surveyID); err == nil {
+		before = s
+	}
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO survey_sheets (survey_id, spreadsheet_id, sheet_name, client_id, client_secret, refresh_token, backfill_through, last_response_id)
+		SELECT ?, ?, ?, ?, ?, ?, COALESCE(MAX(id), 0), COALESCE(MAX(id), 0) FROM survey_responses WHERE survey_id = ?
+		ON CONFLICT (survey_id) DO UPDATE SET
+			spreadsheet_id = excluded.spreadsheet_id, sheet_name = excluded.sheet_name, client_id = excluded.client_id,
+			client_secret = excluded.client_secret, refresh_token = excluded.refresh_token, last_error = NULL
+	`, surveyID, sheet.SpreadsheetID, sheet.SheetName, sheet.ClientID,
+		encryptResponseData([]byte(sheet.ClientSecret)), encryptResponseData([]byte(sheet.RefreshToken)), surveyID)
+	if err != nil {
+		abortWithError(c, errInternal("Failed to save Google Sheets sync", err))
+		return
+	}
+	s, ok := findSheetSyncOr404(c, surveyID)
+	if !ok {
+		return
+	}
+	auditChange(c, "update", "survey_sheets", surveyID, before, s)
+
+	c.JSON(http.StatusOK, APIResponse{
+		Status:  "success",
+		Message: "Google Sheets sync saved successfully",
+		Data:    s,
+	})
+}
+
+// deleteSheetSync stops appending a survey's responses to its Google Sheet
+func deleteSheetSync(c *gin.Context) {
+	ctx := c.Request.Context()
+	surveyID, ok := sheetSyncParams(c)
+	if !ok {
+		return
+	}
+	s, ok := findSheetSyncOr404(c, surveyID)
+	if !ok {
+		return
+	}
+	if _, err := db.ExecContext(ctx, "DELETE FROM survey_sheets WHERE survey_id = ?", surveyID); err != nil {
+		abortWithError(c, errInternal("Failed to delete Google Sheets sync", err))
+		return
+	}
+	auditChange(c, "delete", "survey_sheets", surveyID, s, nil)
+
+	c.JSON(http.StatusOK, APIResponse{
+		Status:  "success",
+		Message: "Google Sheets sync deleted successfully",
+	})
+}
+
+// backfillSheet appends the responses a survey received before its Google
+// Sheets sync was configured; a second backfill has nothing left to append
+func backfillSheet(c *gin.Context) {
+	ctx := c.Request.Context()
+	surveyID, ok := sheetSyncParams(c)
+	if !ok {
+		return
+	}
+	s, ok := findSheetSyncOr404(c, surveyID)
+	if !ok {
+		return
+	}
+
+	report := SheetBackfill{}
+	if s.BackfillThrough > 0 {
+		token, err := sheetsAccessToken(ctx, s)
+		if err != nil {
+			recordSheetError(ctx, surveyID, err)
+			abortWithError(c, errSheetsUnavailable(err))
+			return
+		}
+		// Resumes after the responses an interrupted backfill appended
+		var after int
+		db.QueryRowContext(ctx, "SELECT backfill_after FROM survey_sheets WHERE survey_id = ?", surveyID).Scan(&after)
+		for {
+			n, last, err := syncSheet(ctx, &s, token, after, s.BackfillThrough)
+			if err != nil {
+				recordSheetError(ctx, surveyID, err)
+				abortWithError(c, errSheetsUnavailable(err))
+				return
+			}
+			if n == 0 {
+				break
+			}
+			report.Appended += n
+			after = last
+			if _, err := db.ExecContext(ctx, "UPDATE survey_sheets SET backfill_after = ? WHERE survey_id = ?", after, surveyID); err != nil {
+				abortWithError(c, errInternal("Failed to record backfill progress", err))
+				return
+			}
+		}
+		_, err = db.ExecContext(ctx, "UPDATE survey_sheets SET backfill_through = 0, last_synced_at = ?, last_error = NULL WHERE survey_id = ?",
+			time.Now().UTC(), surveyID)
+		if err != nil {
+			abortWithError(c, errInternal("Failed to record backfill progress", err))
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Status:  "success",
+		Message: fmt.Sprintf("Appended %d row(s)", report.Appended),
+		Data:    report,
+	})
+}
+
+// errSheetsUnavailable reports a failed call to Google
+func errSheetsUnavailable(err error) *APIError {
+	return &APIError{
+		Status:  http.StatusBadGateway,
+		Code:    CodeSheetsUnavailable,
+		Message: "Google Sheets request failed",
+		Errors:  []string{err.Error()},
+		Err:     err,
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeGoogle stands in for Google's token endpoint and the Sheets API
+type fakeGoogle struct {
+	mu       sync.Mutex
+	paths    []string
+	rows     [][]interface{}
+	tokenErr bool
+}
+
+func (g *fakeGoogle) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if r.URL.Path == "/token" {
+		r.ParseForm()
+		if g.tokenErr || r.Form.Get("refresh_token") != "refresh-1" || r.Form.Get("client_secret") != "shh" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error": "invalid_grant"}`))
+			return
+		}
+		w.Write([]byte(`{"access_token": "access-1", "expires_in": 3599}`))
+		return
+	}
+	if r.Header.Get("Authorization") != "Bearer access-1" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	var body struct {
+		Values [][]interface{} `json:"values"`
+	}
+	json.NewDecoder(r.Body).Decode(&body)
+	g.paths = append(g.paths, r.URL.EscapedPath()+"?"+r.URL.RawQuery)
+	g.rows = append(g.rows, body.Values...)
+}
+
+func TestSheetSync(t *testing.T) {
+	setupTestDB()
+	defer testDB.Close()
+	router := setupTestRouter()
+	google := &fakeGoogle{}
+	server := httptest.NewServer(google)
+	defer server.Close()
+	defer func(token, api string) { googleTokenURL, sheetsAPIURL = token, api }(googleTokenURL, sheetsAPIURL)
+	googleTokenURL, sheetsAPIURL = server.URL+"/token", server.URL
+
+	testDB.Exec(`INSERT INTO surveys (title, description, questions) VALUES ('Pulse', 'd',
+		'[{"id": "rating", "type": "rating", "label": "Rating"}, {"id": "tags", "type": "checkbox", "label": "Tags"}]')`)
+	testDB.Exec(`INSERT INTO survey_responses (survey_id, user_identifier, response_data) VALUES
+		(1, 'old1', '{"rating": 4}'), (1, 'old2', '{"rating": 2, "tags": ["a", "b"]}')`)
+
+	send := func(method, url, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, url, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		return w
+	}
+	ctx := context.Background()
+
+	assert.Equal(t, http.StatusNotFound, send("GET", "/api/surveys/1/sheets", "").Code)
+	assert.Equal(t, http.StatusBadRequest, send("PUT", "/api/surveys/1/sheets", `{"sheets":{"spreadsheet_id":"abc"}}`).Code)
+	assert.Equal(t, http.StatusNotFound, send("PUT", "/api/surveys/9/sheets",
+		`{"sheets":{"spreadsheet_id":"abc","client_id":"id","client_secret":"shh","refresh_token":"refresh-1"}}`).Code)
+
+	w := send("PUT", "/api/surveys/1/sheets", `{"sheets":{"spreadsheet_id":"abc","sheet_name":"Team's","client_id":"id","client_secret":"shh","refresh_token":"refresh-1"}}`)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.NotContains(t, w.Body.String(), "shh")
+	assert.NotContains(t, w.Body.String(), "refresh-1")
+	var saved struct {
+		Data SheetSync `json:"data"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &saved)
+	assert.Equal(t, 2, saved.Data.BackfillThrough)
+	assert.Equal(t, 2, saved.Data.LastResponseID)
+
+	// Responses received before configuring wait for a backfill
+	n, err := syncNewResponses(ctx, time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, 0, n)
+
+	// New responses are appended, after a header
+	testDB.Exec(`INSERT INTO survey_responses (survey_id, user_identifier, response_data) VALUES (1, 'new1', '{"rating": 5, "tags": ["c"]}')`)
+	n, err = syncNewResponses(ctx, time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+	google.mu.Lock()
+	assert.Equal(t, []string{"/v4/spreadsheets/abc/values/%27Team%27%27s%27%21A1:append?valueInputOption=RAW&insertDataOption=INSERT_ROWS"}, google.paths)
+	if assert.Len(t, google.rows, 2) {
+		assert.Equal(t, []interface{}{"response_id", "created_at", "user_identifier", "Rating", "Tags"}, google.rows[0])
+		assert.Equal(t, []interface{}{float64(3)}, google.rows[1][:1])
+		assert.Equal(t, []interface{}{"new1", float64(5), `["c"]`}, google.rows[1][2:])
+	}
+	google.mu.Unlock()
+	n, _ = syncNewResponses(ctx, time.Now())
+	assert.Equal(t, 0, n)
+
+	// The backfill appends the earlier responses once
+	w = send("POST", "/api/surveys/1/sheets/backfill", "")
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), `"appended":2`)
+	google.mu.Lock()
+	if assert.Len(t, google.rows, 4) {
+		assert.Equal(t, []interface{}{"old1", float64(4), ""}, google.rows[2][2:])
+		assert.Equal(t, []interface{}{"old2", float64(2), `["a","b"]`}, google.rows[3][2:])
+	}
+	google.mu.Unlock()
+	w = send("POST", "/api/surveys/1/sheets/backfill", "")
+	assert.Contains(t, w.Body.String(), `"appended":0`)
+
+	// Failures are kept and retried by the next run
+	google.tokenErr = true
+	testDB.Exec(`INSERT INTO survey_responses (survey_id, user_identifier, response_data) VALUES (1, 'new2', '{}')`)
+	n, _ = syncNewResponses(ctx, time.Now())
+	assert.Equal(t, 0, n)
+	w = send("GET", "/api/surveys/1/sheets", "")
+	assert.Contains(t, w.Body.String(), "invalid_grant")
+	google.tokenErr = false
+	n, _ = syncNewResponses(ctx, time.Now())
+	assert.Equal(t, 1, n)
+	w = send("GET", "/api/surveys/1/sheets", "")
+	assert.NotContains(t, w.Body.String(), "last_error")
+
+	assert.Equal(t, http.StatusOK, send("DELETE", "/api/surveys/1/sheets", "").Code)
+	assert.Equal(t, http.StatusNotFound, send("POST", "/api/surveys/1/sheets/backfill", "").Code)
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxSitemapURLs is the most URLs a sitemap may hold
+const maxSitemapURLs = 50000
+
+// sitemap is a sitemaps.org URL set
+type sitemap struct {
+	XMLName xml.Name     `xml:"urlset"`
+	Xmlns   string       `xml:"xmlns,attr"`
+	URLs    []sitemapURL `xml:"url"`
+}
+
+// sitemapURL is a page listed in a sitemap, with the date it last changed
+type sitemapURL struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod"`
+}
+
+// findSitemapURLs lists the hosted forms of the open surveys that are both
+// discoverable and indexable, newest first. On the custom domain of an
+// organization, only its surveys are listed; elsewhere, the surveys of
+// organizations without a custom domain are, on PUBLIC_URL.
+func findSitemapURLs(c *gin.Context, now time.Time) ([]sitemapURL, error) {
+	ctx := c.Request.Context()
+	baseURL := publicURL
+	hostCondition := "o.custom_domain IS NULL"
+	args := []interface{}{SurveyStatusPublished, now.UTC(), now.UTC()}
+	if orgID, ok := c.Value("domain_org_id").(int); ok {
+		baseURL = organizationBaseURL(ctx, &orgID)
+		hostCondition = "s.org_id = ?"
+		args = append(args, orgID)
+	}
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT s.global_id, s.updated_at
+		FROM surveys s
+		LEFT JOIN organizations o ON o.id = s.org_id
+		WHERE `+openDiscoverableSurveys+` AND s.indexable AND `+hostCondition+`
+		ORDER BY s.id DESC
+		LIMIT `+fmt.Sprint(maxSitemapURLs), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	urls := []sitemapURL{}
+	for rows.Next() {
+		var globalID string
+		var updatedAt time.Time
+		if err := rows.Scan(&globalID, &updatedAt); err != nil {
+			return nil, err
+		}
+		urls = append(urls, sitemapURL{Loc: baseURL + "/f/" + globalID, LastMod: updatedAt.UTC().Format(time.RFC3339)})
+	}
+	return urls, rows.Err()
+}
+
+// getSitemap serves the sitemap of the hosted forms search engines may index,
+// when the survey directory is enabled
+func getSitemap(c *gin.Context) {
+	if !discoverEnabled {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+	urls, err := findSitemapURLs(c, time.Now())
+	if err != nil {
+		abortWithError(c, errInternal("Failed to fetch surveys", err))
+		return
+	}
+
+	c.Header("Cache-Control", fmt.Sprintf("public, max-age=%d", int(discoverCacheTTL.Seconds())))
+	c.Status(http.StatusOK)
+	c.Header("Content-Type", "application/xml; charset=utf-8")
+	c.Writer.WriteString(xml.Header)
+	enc := xml.NewEncoder(c.Writer)
+	enc.Indent("", "  ")
+	if err := enc.Encode(sitemap{Xmlns: "http://www.sitemaps.org/schemas/sitemap/0.9", URLs: urls}); err != nil {
+		log.Printf("Failed to render sitemap: %v", err)
+	}
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// snapshotDayFormat is how snapshot days are written and queried
+const snapshotDayFormat = "2006-01-02"
+
+// SurveySnapshot holds a survey's aggregates as they were at the end of a day
+// (UTC). Snapshots outlive the responses they were computed from.
+type SurveySnapshot struct {
+	Day            string           `json:"day"`
+	ResponsesCount int              `json:"responses_count"`
+	Questions      []QuestionResult `json:"questions"`
+	// Formulas are evaluated with the definitions of the day
+	Formulas  []FormulaResult `json:"formulas,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// snapshotDueSurveys snapshots the day before now for every published survey
+// that was open that day and hasn't been snapshotted for it yet
+func snapshotDueSurveys(ctx context.Context, now time.Time) (int, error) {
+	end := now.UTC().Truncate(24 * time.Hour)
+	day := end.Add(-24 * time.Hour)
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT s.id FROM surveys s
+		WHERE s.status = ? AND s.created_at < ? AND (s.closes_at IS NULL OR s.closes_at >= ?)
+		AND NOT EXISTS (SELECT 1 FROM survey_snapshots ss WHERE ss.survey_id = s.id AND ss.day = ?)
+	`, SurveyStatusPublished, end.Format(cursorTimeFormat), day, day.Format(snapshotDayFormat))
+	if err != nil {
+		return 0, err
+	}
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+
+	for i, id := range ids {
+		if err := snapshotSurvey(ctx, id, day); err != nil {
+			return i, err
+		}
+	}
+	return len(ids), nil
+}
+
+// snapshotSurvey stores a survey's aggregates over the responses created before the end of day
+func snapshotSurvey(ctx context.Context, surveyID int, day time.Time) error {
+	survey, err := findSurvey(ctx, surveyID)
+	if err != nil {
+		return err
+	}
+	q := responseListQuery{SurveyID: surveyID, CreatedTo: day.Add(24 * time.Hour).Format(cursorTimeFormat)}
+	results, err := computeSurveyResults(ctx, q)
+	if err != nil {
+		return err
+	}
+	formulas, err := listFormulas(ctx, surveyID)
+	if err != nil {
+		return err
+	}
+
+	questions, _ := json.Marshal(results.Questions)
+	evaluated, _ := json.Marshal(evaluateFormulas(survey, results, formulas))
+	_, err = db.ExecContext(ctx, `
+		INSERT OR IGNORE INTO survey_snapshots (survey_id, day, responses_count, questions, formulas, created_at)
+		VALUES (?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
+	`, surveyID, day.Format(snapshotDayFormat), results.ResponsesCount, string(questions), string(evaluated))
+	return err
+}
+
+// getSurveyHistory returns the daily snapshots of a survey, oldest first,
+// between ?from= and ?to= (YYYY-MM-DD, inclusive)
+func getSurveyHistory(c *gin.Context) {
+	ctx := c.Request.Context()
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		abortWithError(c, errInvalidID("survey"))
+		return
+	}
+
+	var errors []string
+	from, to := c.Query("from"), c.Query("to")
+	for _, bound := range []struct{ label, value string }{{"From", from}, {"To", to}} {
+		if _, err := time.Parse(snapshotDayFormat, bound.value); bound.value != "" && err != nil {
+			errors = append(errors, bound.label+" must be a date such as 2024-01-31")
+		}
+	}
+	if len(errors) > 0 {
+		abortWithError(c, errInvalidQuery(errors))
+		return
+	}
+
+	var exists bool
+	err = db.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM surveys WHERE id = ?)", id).Scan(&exists)
+	if err != nil || !exists {
+		abortWithError(c, errNotFound(CodeSurveyNotFound, "Survey not found"))
+		return
+	}
+
+	if to == "" {
+		to = "9999-12-31"
+	}
+	rows, err := db.QueryContext(ctx, `
+		SELECT day, responses_count, questions, formulas, created_at
+		FROM survey_snapshots
+		WHERE survey_id = ? AND day >= ? AND day <= ?
+		ORDER BY day
+	`, id, from, to)
+	if err != nil {
+		abortWithError(c, errInternal("Failed to fetch survey history", err))
+		return
+	}
+	defer rows.Close()
+
+	snapshots := []SurveySnapshot{}
+	for rows.Next() {
+		var s SurveySnapshot
+		var questions, formulas []byte
+		if err := rows.Scan(&s.Day, &s.ResponsesCount, &questions, &formulas, &s.CreatedAt); err != nil {
+			abortWithError(c, errInternal("Failed to scan survey snapshot", err))
+			return
+		}
+		json.Unmarshal(questions, &s.Questions)
+		json.Unmarshal(formulas, &s.Formulas)
+		snapshots = append(snapshots, s)
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Status: "success",
+		Data:   snapshots,
+	})
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSurveySnapshots(t *testing.T) {
+	setupTestDB()
+	defer testDB.Close()
+	router := setupTestRouter()
+
+	now := time.Now().UTC()
+	daysAgo := func(days int) string {
+		return now.Add(time.Duration(-days) * 24 * time.Hour).Format(cursorTimeFormat)
+	}
+	testDB.Exec("INSERT INTO surveys (title, description, status, created_at) VALUES ('Pulse', 'd', ?, ?), ('Draft', 'd', ?, ?)",
+		SurveyStatusPublished, daysAgo(5), SurveyStatusDraft, daysAgo(5))
+	for i, days := range []int{2, 2, 1, 0} {
+		testDB.Exec("INSERT INTO survey_responses (survey_id, user_identifier, response_data, created_at) VALUES (1, ?, ?, ?)",
+			fmt.Sprintf("user%d", i), fmt.Sprintf(`{"rating": %d}`, i+1), daysAgo(days))
+	}
+	testDB.Exec("INSERT INTO survey_formulas (survey_id, name, expression) VALUES (1, 'average', 'mean(rating)')")
+
+	ctx := context.Background()
+	n, err := snapshotDueSurveys(ctx, now.Add(-24*time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+	n, err = snapshotDueSurveys(ctx, now)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+	// Each day is only snapshotted once
+	n, _ = snapshotDueSurveys(ctx, now)
+	assert.Equal(t, 0, n)
+
+	// Snapshots outlive the responses
+	testDB.Exec("DELETE FROM survey_responses")
+
+	history := func(query string) []SurveySnapshot {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/api/surveys/1/history"+query, nil)
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var response struct {
+			Data []SurveySnapshot `json:"data"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		return response.Data
+	}
+
+	snapshots := history("")
+	assert.Len(t, snapshots, 2)
+	assert.Equal(t, now.Add(-48*time.Hour).Format(snapshotDayFormat), snapshots[0].Day)
+	assert.Equal(t, 2, snapshots[0].ResponsesCount)
+	assert.Equal(t, 3, snapshots[1].ResponsesCount)
+	assert.Equal(t, 2.0, *snapshots[1].Formulas[0].Value)
+	assert.Equal(t, map[string]int{"1": 1, "2": 1, "3": 1}, snapshots[1].Questions[0].Values)
+
+	snapshots = history("?from=" + now.Add(-24*time.Hour).Format(snapshotDayFormat))
+	assert.Len(t, snapshots, 1)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/surveys/1/history?from=yesterday", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/surveys/2/history", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"data":[]`)
+}
@@ -0,0 +1,510 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/mail"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// JobKindResponseSummary emails a respondent a copy of their answers
+const JobKindResponseSummary = "response_summary.send"
+
+// Summary email settings. Each address gets at most
+// RATE_LIMIT_SUMMARY_EMAIL copies, whatever the survey, so an email question
+// can't be used to flood someone else's inbox.
+var (
+	summaryEmailRateLimit   = envRateLimit("RATE_LIMIT_SUMMARY_EMAIL", RateLimit{Requests: 5, Per: time.Hour})
+	summaryEmailMaxAttempts = 5
+)
+
+// Limits of summary email templates
+const (
+	maxSummarySubject  = 200
+	maxSummaryTemplate = 10000
+)
+
+// Templates of summary emails that don't have their own
+const (
+	defaultSummarySubject  = `Your answers to "{{.SurveyTitle}}"`
+	defaultSummaryTemplate = `Thank you for answering "{{.SurveyTitle}}". Here is a copy of your answers.
+
+{{range .Answers}}{{.Question}}
+{{.Answer}}
+
+{{end}}`
+)
+
+// SummaryEmailSettings configure the copy of their answers emailed to
+// respondents after they submit
+type SummaryEmailSettings struct {
+	Enabled bool `json:"enabled"`
+	// EmailQuestion is the text question whose answer is the address to send
+	// the copy to; respondents invited by email get it at that address
+	EmailQuestion string `json:"email_question"`
+	// Subject and Template are text/template templates of a ResponseSummary;
+	// empty ones are the defaults
+	Subject  string `json:"subject"`
+	Template string `json:"template"`
+}
+
+// SaveSummaryEmailSettingsRequest represents the request body for configuring summary emails
+type SaveSummaryEmailSettingsRequest struct {
+	SummaryEmail SummaryEmailSettings `json:"summary_email" binding:"required"`
+}
+
+// ResponseSummary is what summary email templates render
+type ResponseSummary struct {
+	SurveyTitle       string
+	SurveyDescription string
+	ResponseID        string
+	SubmittedAt       time.Time
+	Answers           []SummaryAnswer
+}
+
+// SummaryAnswer is an answer as written in a summary email
+type SummaryAnswer struct {
+	Question string
+	Answer   string
+}
+
+// responseSummaryJob is the payload of a JobKindResponseSummary job
+type responseSummaryJob struct {
+	ResponseID int    `json:"response_id"`
+	Email      string `json:"email"`
+}
+
+// EmailSuppression is an address no summary emails are sent to
+type EmailSuppression struct {
+	Email     string    `json:"email"`
+	Reason    string    `json:"reason,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// CreateEmailSuppressionRequest represents the request body for suppressing an address
+type CreateEmailSuppressionRequest struct {
+	Suppression struct {
+		Email  string `json:"email" binding:"required"`
+		Reason string `json:"reason"`
+	} `json:"suppression" binding:"required"`
+}
+
+// withDefaults fills in the default templates
+func (s SummaryEmailSettings) withDefaults() SummaryEmailSettings {
+	if s.Subject == "" {
+		s.Subject = defaultSummarySubject
+	}
+	if s.Template == "" {
+		s.Template = defaultSummaryTemplate
+	}
+	return s
+}
+
+// findSummaryEmailSettings loads the summary email settings of a survey
+func findSummaryEmailSettings(ctx context.Context, surveyID int) (SummaryEmailSettings, error) {
+	var settings SummaryEmailSettings
+	err := db.QueryRowContext(ctx, `
+		SELECT summary_email_enabled, summary_email_question, summary_email_subject, summary_email_template
+		FROM surveys WHERE id = ?
+	`, surveyID).Scan(&settings.Enabled, &settings.EmailQuestion, &settings.Subject, &settings.Template)
+	return settings, err
+}
+
+// newResponseSummary lays out the answers of a response in the order of the
+// survey's questions; unanswered questions are left out
+func newResponseSummary(survey Survey, response SurveyResponse) ResponseSummary {
+	summary := ResponseSummary{
+		SurveyTitle:       survey.Title,
+		SurveyDescription: survey.Description,
+		ResponseID:        response.GlobalID,
+		SubmittedAt:       response.CreatedAt,
+		Answers:           []SummaryAnswer{},
+	}
+	var answers map[string]json.RawMessage
+	json.Unmarshal(response.ResponseData, &answers)
+	for _, q := range survey.Questions {
+		raw, ok := answers[q.ID]
+		if !ok || isJSONNull(raw) {
+			continue
+		}
+		summary.Answers = append(summary.Answers, SummaryAnswer{Question: q.Label, Answer: summaryAnswerText(raw)})
+	}
+	return summary
+}
+
+// summaryAnswerText writes an answer for people: booleans as Yes or No, and
+// the choices of multiple choice questions separated by commas
+func summaryAnswerText(raw json.RawMessage) string {
+	var value interface{}
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return string(raw)
+	}
+	switch v := value.(type) {
+	case string:
+		return v
+	case bool:
+		if v {
+			return "Yes"
+		}
+		return "No"
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case []interface{}:
+		parts := make([]string, len(v))
+		for i, item := range v {
+			parts[i] = fmt.Sprint(item)
+		}
+		return strings.Join(parts, ", ")
+	}
+	return string(raw)
+}
+
+// renderSummaryEmail renders the summary email of a response to email
+func renderSummaryEmail(settings SummaryEmailSettings, summary ResponseSummary, email string) (Email, error) {
+	settings = settings.withDefaults()
+	var subject, text strings.Builder
+	subjectTemplate, err := template.New("subject").Parse(settings.Subject)
+	if err != nil {
+		return Email{}, err
+	}
+	textTemplate, err := template.New("text").Parse(settings.Template)
+	if err != nil {
+		return Email{}, err
+	}
+	if err := subjectTemplate.Execute(&subject, summary); err != nil {
+		return Email{}, err
+	}
+	if err := textTemplate.Execute(&text, summary); err != nil {
+		return Email{}, err
+	}
+	return Email{To: email, Subject: strings.TrimSpace(subject.String()), Text: text.String()}, nil
+}
+
+// summaryRecipient returns the address a response's summary goes to: the
+// invited address, which the invitation link verified, or else a valid email
+// address answering the survey's email question. It returns "" when neither is.
+func summaryRecipient(settings SummaryEmailSettings, data json.RawMessage, invitation *Invitation) string {
+	if invitation != nil {
+		return strings.ToLower(invitation.Email)
+	}
+	if settings.EmailQuestion == "" {
+		return ""
+	}
+	var answers map[string]json.RawMessage
+	json.Unmarshal(data, &answers)
+	var answer string
+	if json.Unmarshal(answers[settings.EmailQuestion], &answer) != nil {
+		return ""
+	}
+	answer = strings.TrimSpace(answer)
+	addr, err := mail.ParseAddress(answer)
+	if err != nil || addr.Address != answer {
+		return ""
+	}
+	return strings.ToLower(addr.Address)
+}
+
+// emailSuppressed reports whether email is on the suppression list
+func emailSuppressed(ctx context.Context, email string) (bool, error) {
+	var n int
+	err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM email_suppressions WHERE email = ?", email).Scan(&n)
+	return n > 0, err
+}
+
+// queueResponseSummary queues the summary email of a new response, when the
+// survey sends them and the response has a recipient who isn't suppressed or
+// throttled. Responses to anonymous surveys get none, so no address is kept
+// alongside them. Failures are logged: the response is recorded either way.
+func queueResponseSummary(ctx context.Context, survey Survey, response SurveyResponse, invitation *Invitation) {
+	if survey.Anonymous {
+		return
+	}
+	settings, err := findSummaryEmailSettings(ctx, survey.ID)
+	if err != nil {
+		log.Printf("Summary emails: failed to fetch the settings of survey %d: %v", survey.ID, err)
+		return
+	}
+	if !settings.Enabled {
+		return
+	}
+	email := summaryRecipient(settings, response.ResponseData, invitation)
+	if email == "" {
+		return
+	}
+
+	suppressed, err := emailSuppressed(ctx, email)
+	if err != nil {
+		log.Printf("Summary emails: failed to check the suppression list for response %d: %v", response.ID, err)
+		return
+	}
+	if suppressed {
+		return
+	}
+	var recent int
+	since := time.Now().Add(-summaryEmailRateLimit.Per).UTC()
+	if err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM response_summary_emails WHERE email = ? AND created_at > ?", email, since).Scan(&recent); err != nil {
+		log.Printf("Summary emails: failed to count the emails sent for response %d: %v", response.ID, err)
+		return
+	}
+	if recent >= summaryEmailRateLimit.Requests {
+		log.Printf("Summary emails: not sending response %d's, its recipient got %d in the last %s", response.ID, recent, summaryEmailRateLimit.Per)
+		return
+	}
+
+	_, err = db.ExecContext(ctx, "INSERT INTO response_summary_emails (survey_id, response_id, email, created_at) VALUES (?, ?, ?, ?)",
+		survey.ID, response.ID, email, time.Now().UTC())
+	if err == nil {
+		_, err = enqueueJob(ctx, JobKindResponseSummary, responseSummaryJob{ResponseID: response.ID, Email: email}, summaryEmailMaxAttempts)
+	}
+	if err != nil {
+		log.Printf("Summary emails: failed to queue response %d's: %v", response.ID, err)
+	}
+}
+
+// sendResponseSummary runs a summary email job. Emails of responses deleted
+// since, or to addresses suppressed since, are dropped.
+func sendResponseSummary(ctx context.Context, payload json.RawMessage) error {
+	var job responseSummaryJob
+	if err := json.Unmarshal(payload, &job); err != nil {
+		return err
+	}
+	if suppressed, err := emailSuppressed(ctx, job.Email); err != nil || suppressed {
+		return err
+	}
+	var deletedAt sql.NullTime
+	err := db.QueryRowContext(ctx, "SELECT deleted_at FROM survey_responses WHERE id = ?", job.ResponseID).Scan(&deletedAt)
+	if err == sql.ErrNoRows || deletedAt.Valid {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	response, err := queryResponse(ctx, db, job.ResponseID)
+	if err != nil {
+		return err
+	}
+	survey, err := findSurvey(ctx, response.SurveyID)
+	if err != nil {
+		return err
+	}
+	settings, err := findSummaryEmailSettings(ctx, survey.ID)
+	if err != nil {
+		return err
+	}
+	email, err := renderSummaryEmail(settings, newResponseSummary(survey, response), job.Email)
+	if err != nil {
+		return err
+	}
+	return mailer.Send(ctx, email)
+}
+
+// getSummaryEmailSettings returns a survey's summary email settings, with the
+// default templates filled in
+func getSummaryEmailSettings(c *gin.Context) {
+	surveyID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		abortWithError(c, errInvalidID("survey"))
+		return
+	}
+	settings, err := findSummaryEmailSettings(c.Request.Context(), surveyID)
+	if err == sql.ErrNoRows {
+		abortWithError(c, errNotFound(CodeSurveyNotFound, "Survey not found"))
+		return
+	}
+	if err != nil {
+		abortWithError(c, errInternal("Failed to fetch summary email settings", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Status: "success",
+		Data:   settings.withDefaults(),
+	})
+}
+
+// saveSummaryEmailSettings configures the summary emails of a survey. The
+// templates are tried on the survey's questions, so mistakes show up now
+// rather than when respondents submit.
+func saveSummaryEmailSettings(c *gin.Context) {
+	ctx := c.Request.Context()
+	surveyID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		abortWithError(c, errInvalidID("survey"))
+		return
+	}
+	var req SaveSummaryEmailSettingsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		abortWithError(c, errInvalidRequest(err))
+		return
+	}
+	survey, err := findSurvey(ctx, surveyID)
+	if err == sql.ErrNoRows {
+		abortWithError(c, errNotFound(CodeSurveyNotFound, "Survey not found"))
+		return
+	}
+	if err != nil {
+		abortWithError(c, errInternal("Failed to fetch survey", err))
+		return
+	}
+	before, err := findSummaryEmailSettings(ctx, surveyID)
+	if err != nil {
+		abortWithError(c, errInternal("Failed to fetch summary email settings", err))
+		return
+	}
+
+	// Validation
+	settings := req.SummaryEmail
+	settings.Subject = strings.TrimSpace(settings.Subject)
+	var errors []string
+	if settings.Enabled && survey.Anonymous {
+		errors = append(errors, "Anonymous surveys can't email respondents their answers")
+	}
+	if id := settings.EmailQuestion; id != "" {
+		questionType := ""
+		for _, q := range survey.Questions {
+			if q.ID == id {
+				questionType = q.Type
+			}
+		}
+		switch questionType {
+		case "":
+			errors = append(errors, fmt.Sprintf("Email question %s doesn't exist", id))
+		case QuestionText:
+		default:
+			errors = append(errors, "Email question must be a text question")
+		}
+	}
+	if len(settings.Subject) > maxSummarySubject {
+		errors = append(errors, fmt.Sprintf("Subject must be at most %d characters", maxSummarySubject))
+	}
+	if len(settings.Template) > maxSummaryTemplate {
+		errors = append(errors, fmt.Sprintf("Template must be at most %d characters", maxSummaryTemplate))
+	}
+	if len(errors) == 0 {
+		summary := ResponseSummary{SurveyTitle: survey.Title, SurveyDescription: survey.Description, ResponseID: "rsp_example", SubmittedAt: time.Now()}
+		for _, q := range survey.Questions {
+			summary.Answers = append(summary.Answers, SummaryAnswer{Question: q.Label, Answer: "Example answer"})
+		}
+		if _, err := renderSummaryEmail(settings, summary, "respondent@example.com"); err != nil {
+			errors = append(errors, "Template is invalid: "+err.Error())
+		}
+	}
+	if len(errors) > 0 {
+		abortWithError(c, errValidation("Failed to save summary email settings", errors))
+		return
+	}
+
+	_, err = db.ExecContext(ctx, `
+		UPDATE surveys
+		SET summary_email_enabled = ?, summary_email_question = ?, summary_email_subject = ?, summary_email_template = ?,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?
+	`, settings.Enabled, settings.EmailQuestion, settings.Subject, settings.Template, surveyID)
+	if err != nil {
+		abortWithError(c, errInternal("Failed to save summary email settings", err))
+		return
+	}
+	auditChange(c, "update", "survey", surveyID, before, settings)
+
+	c.JSON(http.StatusOK, APIResponse{
+		Status:  "success",
+		Message: "Summary email settings saved successfully",
+		Data:    settings.withDefaults(),
+	})
+}
+
+// getEmailSuppressions lists the suppressed addresses, most recent first
+func getEmailSuppressions(c *gin.Context) {
+	rows, err := db.QueryContext(c.Request.Context(), "SELECT email, reason, created_at FROM email_suppressions ORDER BY created_at DESC, email")
+	if err != nil {
+		abortWithError(c, errInternal("Failed to fetch email suppressions", err))
+		return
+	}
+	defer rows.Close()
+
+	suppressions := []EmailSuppression{}
+	for rows.Next() {
+		var s EmailSuppression
+		if err := rows.Scan(&s.Email, &s.Reason, &s.CreatedAt); err != nil {
+			abortWithError(c, errInternal("Failed to scan email suppression data", err))
+			return
+		}
+		suppressions = append(suppressions, s)
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Status: "success",
+		Data:   suppressions,
+	})
+}
+
+// createEmailSuppression stops summary emails to an address, e.g. after a
+// complaint or a bounce; suppressing an address twice keeps the first reason
+func createEmailSuppression(c *gin.Context) {
+	var req CreateEmailSuppressionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		abortWithError(c, errInvalidRequest(err))
+		return
+	}
+	email := strings.ToLower(strings.TrimSpace(req.Suppression.Email))
+	var errors []string
+	if addr, err := mail.ParseAddress(email); err != nil || addr.Address != email {
+		errors = append(errors, "Email must be a valid email address")
+	}
+	if len(req.Suppression.Reason) > 255 {
+		errors = append(errors, "Reason must be less than 255 characters")
+	}
+	if len(errors) > 0 {
+		abortWithError(c, errValidation("Failed to suppress email", errors))
+		return
+	}
+
+	ctx := c.Request.Context()
+	_, err := db.ExecContext(ctx, "INSERT OR IGNORE INTO email_suppressions (email, reason, created_at) VALUES (?, ?, CURRENT_TIMESTAMP)",
+		email, req.Suppression.Reason)
+	if err != nil {
+		abortWithError(c, errInternal("Failed to suppress email", err))
+		return
+	}
+	var s EmailSuppression
+	if err := db.QueryRowContext(ctx, "SELECT email, reason, created_at FROM email_suppressions WHERE email = ?", email).Scan(&s.Email, &s.Reason, &s.CreatedAt); err != nil {
+		abortWithError(c, errInternal("Failed to fetch email suppression", err))
+		return
+	}
+	auditChange(c, "create", "email_suppression", 0, nil, s)
+
+	c.JSON(http.StatusCreated, APIResponse{
+		Status:  "success",
+		Message: "Email suppressed successfully",
+		Data:    s,
+	})
+}
+
+// deleteEmailSuppression lets summary emails reach an address again
+func deleteEmailSuppression(c *gin.Context) {
+	email := strings.ToLower(c.Param("email"))
+	n, err := rowsAffected(c.Request.Context(), db, "DELETE FROM email_suppressions WHERE email = ?", email)
+	if err != nil {
+		abortWithError(c, errInternal("Failed to delete email suppression", err))
+		return
+	}
+	if n == 0 {
+		abortWithError(c, errNotFound(CodeSuppressionNotFound, "Email suppression not found"))
+		return
+	}
+	auditChange(c, "delete", "email_suppression", 0, EmailSuppression{Email: email}, nil)
+
+	c.JSON(http.StatusOK, APIResponse{
+		Status:  "success",
+		Message: "Email suppression deleted successfully",
+	})
+}
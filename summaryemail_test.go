@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSummaryEmails(t *testing.T) {
+	setupTestDB()
+	defer testDB.Close()
+	router := setupTestRouter()
+	defer func(m Mailer, limit RateLimit) { mailer, summaryEmailRateLimit = m, limit }(mailer, summaryEmailRateLimit)
+	sent := &recordingMailer{}
+	mailer = sent
+	summaryEmailRateLimit = RateLimit{Requests: 1, Per: time.Hour}
+
+	testDB.Exec(`INSERT INTO surveys (title, description, questions) VALUES ('Workshop signup', 'd', ?)`,
+		`[{"id":"email","type":"text","label":"Your email"},{"id":"days","type":"multiple_choice","label":"Days","options":["Mon","Tue"]},{"id":"lunch","type":"boolean","label":"Lunch?"},{"id":"seats","type":"number","label":"Seats"}]`)
+
+	send := func(req *http.Request) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	put := func(body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("PUT", "/api/surveys/1/summary-email", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		return send(req)
+	}
+	submit := func(user, data string) {
+		req, _ := http.NewRequest("POST", "/api/surveys/1/responses", strings.NewReader(`{"survey_response": {"user_identifier": "`+user+`", "response_data": `+data+`}}`))
+		req.Header.Set("Content-Type", "application/json")
+		w := send(req)
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+		runDueJobs(context.Background(), time.Now())
+	}
+
+	// Settings are checked against the survey, templates included
+	w := put(`{"summary_email": {"enabled": true, "email_question": "missing"}}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Contains(t, w.Body.String(), "Email question missing doesn't exist")
+	assert.Equal(t, http.StatusUnprocessableEntity, put(`{"summary_email": {"enabled": true, "email_question": "days"}}`).Code)
+	assert.Equal(t, http.StatusUnprocessableEntity, put(`{"summary_email": {"enabled": true, "template": "{{.Nope}}"}}`).Code)
+	assert.Equal(t, http.StatusUnprocessableEntity, put(`{"summary_email": {"enabled": true, "template": "{{range}}"}}`).Code)
+
+	// Responses aren't emailed until the survey turns summaries on
+	submit("user0", `{"email": "early@example.com"}`)
+	assert.Empty(t, sent.sent)
+
+	w = put(`{"summary_email": {"enabled": true, "email_question": "email", "subject": "Copy: {{.SurveyTitle}}"}}`)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	req, _ := http.NewRequest("GET", "/api/surveys/1/summary-email", nil)
+	w = send(req)
+	assert.Contains(t, w.Body.String(), `"subject":"Copy: {{.SurveyTitle}}"`)
+	assert.Contains(t, w.Body.String(), `Here is a copy of your answers.`)
+
+	submit("user1", `{"email": " Ada@Example.com ", "days": ["Mon", "Tue"], "lunch": true, "seats": 2}`)
+	require.Len(t, sent.sent, 1)
+	email := sent.sent[0]
+	assert.Equal(t, "ada@example.com", email.To)
+	assert.Equal(t, "Copy: Workshop signup", email.Subject)
+	assert.Contains(t, email.Text, "Days\nMon, Tue\n")
+	assert.Contains(t, email.Text, "Lunch?\nYes\n")
+	assert.Contains(t, email.Text, "Seats\n2\n")
+
+	// Answers that aren't an address get nothing, and addresses are throttled
+	submit("user2", `{"email": "not an email"}`)
+	submit("user3", `{"email": "ada@example.com"}`)
+	assert.Len(t, sent.sent, 1)
+
+	// Suppressed addresses aren't emailed
+	assert.Equal(t, http.StatusForbidden, send(func() *http.Request {
+		req, _ := http.NewRequest("GET", "/api/admin/email-suppressions", nil)
+		return req
+	}()).Code)
+	w = send(adminRequest("POST", "/api/admin/email-suppressions", []byte(`{"suppression": {"email": "Grace@example.com", "reason": "complaint"}}`)))
+	assert.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	assert.Equal(t, http.StatusUnprocessableEntity, send(adminRequest("POST", "/api/admin/email-suppressions", []byte(`{"suppression": {"email": "nope"}}`))).Code)
+	w = send(adminRequest("GET", "/api/admin/email-suppressions", nil))
+	assert.Contains(t, w.Body.String(), `"email":"grace@example.com","reason":"complaint"`)
+	submit("user4", `{"email": "grace@example.com"}`)
+	assert.Len(t, sent.sent, 1)
+
+	assert.Equal(t, http.StatusOK, send(adminRequest("DELETE", "/api/admin/email-suppressions/grace@example.com", nil)).Code)
+	assert.Equal(t, http.StatusNotFound, send(adminRequest("DELETE", "/api/admin/email-suppressions/grace@example.com", nil)).Code)
+	submit("user5", `{"email": "grace@example.com"}`)
+	require.Len(t, sent.sent, 2)
+	assert.Equal(t, "grace@example.com", sent.sent[1].To)
+}
+
+func TestSummaryRecipient(t *testing.T) {
+	settings := SummaryEmailSettings{Enabled: true, EmailQuestion: "email"}
+	assert.Equal(t, "ada@example.com", summaryRecipient(settings, []byte(`{"email": "ada@example.com"}`), nil))
+	assert.Equal(t, "", summaryRecipient(settings, []byte(`{"email": "Ada <ada@example.com>"}`), nil))
+	assert.Equal(t, "", summaryRecipient(settings, []byte(`{"email": 42}`), nil))
+	assert.Equal(t, "", summaryRecipient(SummaryEmailSettings{Enabled: true}, []byte(`{"email": "ada@example.com"}`), nil))
+
+	// The invited address was verified by the invitation link
+	invitation := &Invitation{Email: "Invitee@example.com"}
+	assert.Equal(t, "invitee@example.com", summaryRecipient(settings, []byte(`{"email": "other@example.com"}`), invitation))
+}
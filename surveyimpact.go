@@ -0,0 +1,211 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Survey deletion modes
+const (
+	SurveyDeleteModeBlock   = "block"
+	SurveyDeleteModeCascade = "cascade"
+)
+
+// surveyTables lists every table with rows belonging to a survey through a
+// survey_id column, deleted with it by a cascading delete
+var surveyTables = []string{
+	"survey_responses", "partial_responses", "scanned_responses", "survey_invitations",
+	"response_views", "survey_formulas", "survey_snapshots", "survey_tokens", "survey_share_links",
+	"webhook_subscriptions", "survey_sheets", "export_jobs", "notifications",
+	"survey_lifecycle_events", "experience_events", "client_errors", "response_summary_emails",
+}
+
+// SurveyReference is something that refers to a survey and stops working
+// when it is deleted, such as a webhook or a share link
+type SurveyReference struct {
+	Type string `json:"type"`
+	ID   int    `json:"id,omitempty"`
+	// Name identifies the reference to people, e.g. a webhook's URL
+	Name string `json:"name"`
+}
+
+// SurveyImpact lists what deleting a survey would break or remove: the
+// integrations and links referring to it, and how much data it holds
+type SurveyImpact struct {
+	SurveyID         int               `json:"survey_id"`
+	References       []SurveyReference `json:"references"`
+	Responses        int               `json:"responses"`
+	PartialResponses int               `json:"partial_responses"`
+	ScannedResponses int               `json:"scanned_responses"`
+	Invitations      int               `json:"invitations"`
+	Snapshots        int               `json:"snapshots"`
+}
+
+// empty reports whether the survey can be deleted without losing anything
+func (i SurveyImpact) empty() bool {
+	return len(i.References) == 0 && i.Responses == 0 && i.PartialResponses == 0 &&
+		i.ScannedResponses == 0 && i.Invitations == 0 && i.Snapshots == 0
+}
+
+// surveyReferenceQueries select the ID and name of each kind of reference to
+// a survey; revoked tokens and links no longer refer to it
+var surveyReferenceQueries = []struct {
+	Type  string
+	Query string
+}{
+	{"webhook", "SELECT id, url FROM webhook_subscriptions WHERE survey_id = ? ORDER BY id"},
+	{"export_job", `SELECT id, destination || ' ' || format || ' every ' || interval_minutes || ' minutes' FROM export_jobs WHERE survey_id = ? ORDER BY id`},
+	{"sheet_sync", "SELECT 0, spreadsheet_id FROM survey_sheets WHERE survey_id = ?"},
+	{"share_link", "SELECT id, prefix FROM survey_share_links WHERE survey_id = ? AND revoked_at IS NULL ORDER BY id"},
+	{"survey_token", "SELECT id, name FROM survey_tokens WHERE survey_id = ? AND revoked_at IS NULL ORDER BY id"},
+	{"response_view", "SELECT id, name FROM response_views WHERE survey_id = ? ORDER BY id"},
+	{"formula", "SELECT id, name FROM survey_formulas WHERE survey_id = ? ORDER BY id"},
+}
+
+// rowsQueryer is implemented by both *sql.DB and *sql.Tx
+type rowsQueryer interface {
+	queryer
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// findSurveyImpact lists what refers to a survey and counts its data, through q
+func findSurveyImpact(ctx context.Context, q rowsQueryer, surveyID int) (SurveyImpact, error) {
+	impact := SurveyImpact{SurveyID: surveyID, References: []SurveyReference{}}
+	for _, kind := range surveyReferenceQueries {
+		rows, err := q.QueryContext(ctx, kind.Query, surveyID)
+		if err != nil {
+			return impact, err
+		}
+		for rows.Next() {
+			ref := SurveyReference{Type: kind.Type}
+			if err := rows.Scan(&ref.ID, &ref.Name); err != nil {
+				rows.Close()
+				return impact, err
+			}
+			impact.References = append(impact.References, ref)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return impact, err
+		}
+	}
+
+	err := q.QueryRowContext(ctx, `
+		SELECT
+			(SELECT COUNT(*) FROM survey_responses WHERE survey_id = ?),
+			(SELECT COUNT(*) FROM partial_responses WHERE survey_id = ? AND response_id IS NULL),
+			(SELECT COUNT(*) FROM scanned_responses WHERE survey_id = ?),
+			(SELECT COUNT(*) FROM survey_invitations WHERE survey_id = ?),
+			(SELECT COUNT(*) FROM survey_snapshots WHERE survey_id = ?)
+	`, surveyID, surveyID, surveyID, surveyID, surveyID).Scan(
+		&impact.Responses, &impact.PartialResponses, &impact.ScannedResponses, &impact.Invitations, &impact.Snapshots)
+	return impact, err
+}
+
+// surveyImpactParams reads the survey ID of an impact route and loads the survey
+func surveyImpactParams(c *gin.Context) (Survey, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		abortWithError(c, errInvalidID("survey"))
+		return Survey{}, false
+	}
+	survey, err := findSurvey(c.Request.Context(), id)
+	if err == sql.ErrNoRows {
+		abortWithError(c, errNotFound(CodeSurveyNotFound, "Survey not found"))
+		return survey, false
+	}
+	if err != nil {
+		abortWithError(c, errInternal("Failed to fetch survey", err))
+		return survey, false
+	}
+	return survey, true
+}
+
+// getSurveyImpact lists what deleting a survey would break or remove
+func getSurveyImpact(c *gin.Context) {
+	survey, ok := surveyImpactParams(c)
+	if !ok {
+		return
+	}
+	impact, err := findSurveyImpact(c.Request.Context(), db, survey.ID)
+	if err != nil {
+		abortWithError(c, errInternal("Failed to check survey impact", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Status: "success",
+		Data:   impact,
+	})
+}
+
+// deleteSurvey deletes a survey. With ?mode=block (the default) a survey
+// that anything refers to or that holds data is kept, answering 409 with its
+// impact; ?mode=cascade deletes it with everything listed in the impact.
+func deleteSurvey(c *gin.Context) {
+	ctx := c.Request.Context()
+	mode := c.DefaultQuery("mode", SurveyDeleteModeBlock)
+	if mode != SurveyDeleteModeBlock && mode != SurveyDeleteModeCascade {
+		abortWithError(c, errInvalidQuery([]string{"Mode must be block or cascade"}))
+		return
+	}
+	survey, ok := surveyImpactParams(c)
+	if !ok {
+		return
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		abortWithError(c, errInternal("Failed to delete survey", err))
+		return
+	}
+	defer tx.Rollback()
+
+	// Checked inside the transaction, so nothing can start referring to the
+	// survey between the check and the delete
+	impact, err := findSurveyImpact(ctx, tx, survey.ID)
+	if err != nil {
+		abortWithError(c, errInternal("Failed to delete survey", err))
+		return
+	}
+	if mode == SurveyDeleteModeBlock && !impact.empty() {
+		abortWithError(c, &APIError{
+			Status:  http.StatusConflict,
+			Code:    CodeSurveyInUse,
+			Message: "Survey is in use; remove what refers to it first, or delete with mode=cascade",
+			Data:    impact,
+		})
+		return
+	}
+
+	statements := []string{"DELETE FROM response_revisions WHERE response_id IN (SELECT id FROM survey_responses WHERE survey_id = ?)"}
+	for _, table := range surveyTables {
+		statements = append(statements, fmt.Sprintf("DELETE FROM %s WHERE survey_id = ?", table))
+	}
+	statements = append(statements, "DELETE FROM surveys WHERE id = ?")
+	for _, statement := range statements {
+		if _, err := tx.ExecContext(ctx, statement, survey.ID); err != nil {
+			abortWithError(c, errInternal("Failed to delete survey", err))
+			return
+		}
+	}
+	if err := recordChange(ctx, tx, currentActor(c), "delete", "survey", survey.ID, survey, nil); err != nil {
+		abortWithError(c, errInternal("Failed to delete survey", err))
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		abortWithError(c, errInternal("Failed to delete survey", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Status:  "success",
+		Message: "Survey deleted successfully",
+		Data:    impact,
+	})
+}
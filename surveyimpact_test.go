@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// surveyIDTables lists the tables with a survey_id column
+// surveyIDTables lists the tables with a survey_id column, but survey_changes,
+// whose tombstones of deleted surveys outlive them for offline clients
+func surveyIDTables(t *testing.T) []string {
+	rows, err := testDB.Query(`
+		SELECT m.name FROM sqlite_master m, pragma_table_info(m.name) p
+		WHERE m.type = 'table' AND p.name = 'survey_id' AND m.name != 'survey_changes' ORDER BY m.name`)
+	assert.NoError(t, err)
+	defer rows.Close()
+	var tables []string
+	for rows.Next() {
+		var name string
+		rows.Scan(&name)
+		tables = append(tables, name)
+	}
+	return tables
+}
+
+func TestSurveyTablesCoverSchema(t *testing.T) {
+	setupTestDB()
+	defer testDB.Close()
+	// A table added with a survey_id column must be deleted with its survey
+	assert.ElementsMatch(t, surveyTables, surveyIDTables(t))
+}
+
+func TestSurveyImpactAndDelete(t *testing.T) {
+	setupTestDB()
+	defer testDB.Close()
+	router := setupTestRouter()
+
+	testDB.Exec("INSERT INTO surveys (title, description) VALUES ('Pulse', 'd'), ('Empty', 'd')")
+	testDB.Exec(`INSERT INTO survey_responses (survey_id, user_identifier, response_data) VALUES (1, 'user1', '{}')`)
+	testDB.Exec(`INSERT INTO response_revisions (response_id, response_data) VALUES (1, '{}')`)
+	testDB.Exec(`INSERT INTO webhook_subscriptions (url, secret, survey_id) VALUES ('https://hooks.example.com/pulse', 's', 1), ('https://hooks.example.com/all', 's', NULL)`)
+	testDB.Exec(`INSERT INTO survey_share_links (survey_id, prefix, token_hash) VALUES (1, 'sl_ab12', 'h1'), (1, 'sl_cd34', 'h2')`)
+	testDB.Exec(`UPDATE survey_share_links SET revoked_at = CURRENT_TIMESTAMP WHERE prefix = 'sl_cd34'`)
+	testDB.Exec(`INSERT INTO export_jobs (survey_id, format, destination, config, interval_minutes, next_run_at) VALUES (1, 'csv', 's3', '{}', 60, CURRENT_TIMESTAMP)`)
+	testDB.Exec(`INSERT INTO response_views (survey_id, name) VALUES (1, 'Detractors')`)
+
+	send := func(method, url string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, adminRequest(method, url, nil))
+		return w
+	}
+
+	w := send("GET", "/api/admin/surveys/1/impact")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response struct {
+		Data SurveyImpact `json:"data"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	assert.Equal(t, []SurveyReference{
+		{Type: "webhook", ID: 1, Name: "https://hooks.example.com/pulse"},
+		{Type: "export_job", ID: 1, Name: "s3 csv every 60 minutes"},
+		{Type: "share_link", ID: 1, Name: "sl_ab12"},
+		{Type: "response_view", ID: 1, Name: "Detractors"},
+	}, response.Data.References)
+	assert.Equal(t, 1, response.Data.Responses)
+	assert.Equal(t, http.StatusNotFound, send("GET", "/api/admin/surveys/9/impact").Code)
+
+	// A survey in use is kept unless the caller cascades
+	w = send("DELETE", "/api/admin/surveys/1")
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Contains(t, w.Body.String(), "SURVEY_IN_USE")
+	assert.Contains(t, w.Body.String(), "sl_ab12")
+	assert.Equal(t, http.StatusBadRequest, send("DELETE", "/api/admin/surveys/1?mode=force").Code)
+
+	w = send("DELETE", "/api/admin/surveys/1?mode=cascade")
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, http.StatusNotFound, send("GET", "/api/admin/surveys/1/impact").Code)
+	for _, table := range surveyIDTables(t) {
+		var n int
+		testDB.QueryRow("SELECT COUNT(*) FROM " + table + " WHERE survey_id = 1").Scan(&n)
+		assert.Zero(t, n, table)
+	}
+	var n int
+	testDB.QueryRow("SELECT COUNT(*) FROM response_revisions").Scan(&n)
+	assert.Zero(t, n)
+	testDB.QueryRow("SELECT COUNT(*) FROM survey_changes WHERE survey_id = 1 AND deleted").Scan(&n)
+	assert.Equal(t, 1, n, "offline clients are told the survey was deleted")
+	testDB.QueryRow("SELECT COUNT(*) FROM webhook_subscriptions").Scan(&n)
+	assert.Equal(t, 1, n, "webhooks of every survey are kept")
+	testDB.QueryRow("SELECT COUNT(*) FROM audit_log WHERE entity_type = 'survey' AND action = 'delete' AND entity_id = 1").Scan(&n)
+	assert.Equal(t, 1, n)
+
+	// Nothing refers to an unused survey, so the default mode deletes it
+	assert.Equal(t, http.StatusOK, send("DELETE", "/api/admin/surveys/2").Code)
+	assert.Equal(t, http.StatusNotFound, send("DELETE", "/api/admin/surveys/2").Code)
+}
@@ -0,0 +1,298 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// surveyTokenPrefix marks bearer tokens scoped to one survey
+const surveyTokenPrefix = "svt_"
+
+// surveyTokenRoutes lists the requests a survey token permits, on its own survey only.
+// Routes without a survey ID check the survey themselves.
+var surveyTokenRoutes = map[string]bool{
+	"GET /api/surveys/:id":                 true,
+	"GET /api/surveys/:id/response-schema": true,
+	"POST /api/surveys/:id/responses":      true,
+
+	"POST /api/surveys/:id/partial-responses":              true,
+	"GET /api/surveys/:id/partial-responses/:partial_id":   true,
+	"PATCH /api/surveys/:id/partial-responses/:partial_id": true,
+
+	"POST /api/surveys/:id/partial-responses/:partial_id/resume-code": true,
+	"POST /api/surveys/:id/partial-responses/resume":                  true,
+
+	"POST /api/surveys/:id/experience-events": true,
+	"POST /api/client-errors":                 true,
+}
+
+// SurveyToken is a public credential for embedding one survey's form
+type SurveyToken struct {
+	ID       int    `json:"id"`
+	SurveyID int    `json:"survey_id"`
+	Name     string `json:"name"`
+	// Prefix identifies the token without revealing it
+	Prefix string `json:"prefix"`
+	// Token is only returned when the token is created or rotated
+	Token      string     `json:"token,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+// CreateSurveyTokenRequest represents the request body for issuing a survey token
+type CreateSurveyTokenRequest struct {
+	Token struct {
+		Name string `json:"name" binding:"required"`
+	} `json:"token" binding:"required"`
+}
+
+// surveyTokenColumns lists the columns read by scanSurveyToken
+const surveyTokenColumns = "id, survey_id, name, prefix, created_at, last_used_at, revoked_at"
+
+// scanSurveyToken scans a row selected with surveyTokenColumns
+func scanSurveyToken(row rowScanner) (SurveyToken, error) {
+	var st SurveyToken
+	var lastUsedAt, revokedAt sql.NullTime
+	err := row.Scan(&st.ID, &st.SurveyID, &st.Name, &st.Prefix, &st.CreatedAt, &lastUsedAt, &revokedAt)
+	st.LastUsedAt = nullTimePtr(lastUsedAt)
+	st.RevokedAt = nullTimePtr(revokedAt)
+	return st, err
+}
+
+// hashSurveyToken returns the stored form of a token; tokens themselves are never stored
+func hashSurveyToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// newSurveyToken returns a fresh token with its prefix and hash
+func newSurveyToken() (token, prefix, hash string) {
+	token = surveyTokenPrefix + randomHex(24)
+	return token, token[:len(surveyTokenPrefix)+8], hashSurveyToken(token)
+}
+
+// findSurveyToken loads the active token matching a bearer token
+func findSurveyToken(ctx context.Context, token string) (SurveyToken, error) {
+	return scanSurveyToken(db.QueryRowContext(ctx,
+		"SELECT "+surveyTokenColumns+" FROM survey_tokens WHERE token_hash = ? AND revoked_at IS NULL",
+		hashSurveyToken(token)))
+}
+
+// surveyTokenAuth restricts requests authenticated with a survey token to
+// fetching and answering that token's survey. Other requests pass through.
+func surveyTokenAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		token := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if !strings.HasPrefix(token, surveyTokenPrefix) {
+			c.Next()
+			return
+		}
+
+		st, err := findSurveyToken(ctx, token)
+		if err != nil {
+			abortWithError(c, &APIError{
+				Status:  http.StatusUnauthorized,
+				Code:    CodeInvalidSurveyToken,
+				Message: "Invalid or revoked survey token",
+			})
+			return
+		}
+		id := c.Param("id")
+		if !surveyTokenRoutes[c.Request.Method+" "+c.FullPath()] || (id != "" && id != strconv.Itoa(st.SurveyID)) {
+			abortWithError(c, &APIError{
+				Status:  http.StatusForbidden,
+				Code:    CodeSurveyTokenDenied,
+				Message: "Survey token does not permit this request",
+			})
+			return
+		}
+
+		db.ExecContext(ctx, "UPDATE survey_tokens SET last_used_at = CURRENT_TIMESTAMP WHERE id = ?", st.ID)
+		c.Set("actor", fmt.Sprintf("survey_token:%d", st.ID))
+		c.Set("survey_token_survey_id", st.SurveyID)
+		c.Next()
+	}
+}
+
+// surveyTokenParams reads the survey ID and, when present, the token ID of a
+// token route, responding with the error when they are invalid
+func surveyTokenParams(c *gin.Context) (surveyID, tokenID int, ok bool) {
+	surveyID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		abortWithError(c, errInvalidID("survey"))
+		return 0, 0, false
+	}
+	if c.Param("token_id") == "" {
+		return surveyID, 0, true
+	}
+	tokenID, err = strconv.Atoi(c.Param("token_id"))
+	if err != nil {
+		abortWithError(c, errInvalidID("token"))
+		return 0, 0, false
+	}
+	return surveyID, tokenID, true
+}
+
+// findActiveSurveyToken loads a survey's unrevoked token, responding 404 when there is none
+func findActiveSurveyToken(c *gin.Context, surveyID, tokenID int) (SurveyToken, bool) {
+	ctx := c.Request.Context()
+	st, err := scanSurveyToken(db.QueryRowContext(ctx,
+		"SELECT "+surveyTokenColumns+" FROM survey_tokens WHERE id = ? AND survey_id = ? AND revoked_at IS NULL",
+		tokenID, surveyID))
+	if err == sql.ErrNoRows {
+		abortWithError(c, errNotFound(CodeSurveyTokenNotFound, "Survey token not found"))
+		return st, false
+	}
+	if err != nil {
+		abortWithError(c, errInternal("Failed to fetch survey token", err))
+		return st, false
+	}
+	return st, true
+}
+
+// getSurveyTokens lists a survey's tokens, including revoked ones, without their values
+func getSurveyTokens(c *gin.Context) {
+	ctx := c.Request.Context()
+	surveyID, _, ok := surveyTokenParams(c)
+	if !ok {
+		return
+	}
+
+	rows, err := db.QueryContext(ctx, "SELECT "+surveyTokenColumns+" FROM survey_tokens WHERE survey_id = ? ORDER BY id", surveyID)
+	if err != nil {
+		abortWithError(c, errInternal("Failed to fetch survey tokens", err))
+		return
+	}
+	defer rows.Close()
+
+	tokens := []SurveyToken{}
+	for rows.Next() {
+		st, err := scanSurveyToken(rows)
+		if err != nil {
+			abortWithError(c, errInternal("Failed to scan survey token data", err))
+			return
+		}
+		tokens = append(tokens, st)
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Status: "success",
+		Data:   tokens,
+	})
+}
+
+// createSurveyToken issues a token for embedding a survey; its value is shown once
+func createSurveyToken(c *gin.Context) {
+	ctx := c.Request.Context()
+	surveyID, _, ok := surveyTokenParams(c)
+	if !ok {
+		return
+	}
+
+	var req CreateSurveyTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		abortWithError(c, errInvalidRequest(err))
+		return
+	}
+	if len(req.Token.Name) > 100 {
+		abortWithError(c, errValidation("Failed to create survey token", []string{"Name must be less than 100 characters"}))
+		return
+	}
+
+	var exists bool
+	if err := db.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM surveys WHERE id = ?)", surveyID).Scan(&exists); err != nil || !exists {
+		abortWithError(c, errNotFound(CodeSurveyNotFound, "Survey not found"))
+		return
+	}
+
+	token, prefix, hash := newSurveyToken()
+	result, err := db.ExecContext(ctx, `
+		INSERT INTO survey_tokens (survey_id, name, prefix, token_hash, created_at)
+		VALUES (?, ?, ?, ?, CURRENT_TIMESTAMP)
+	`, surveyID, req.Token.Name, prefix, hash)
+	if err != nil {
+		abortWithError(c, errInternal("Failed to create survey token", err))
+		return
+	}
+
+	id, _ := result.LastInsertId()
+	st, ok := findActiveSurveyToken(c, surveyID, int(id))
+	if !ok {
+		return
+	}
+	auditChange(c, "create", "survey_token", st.ID, nil, st)
+
+	st.Token = token
+	c.JSON(http.StatusCreated, APIResponse{
+		Status:  "success",
+		Message: "Survey token created successfully",
+		Data:    st,
+	})
+}
+
+// rotateSurveyToken replaces a token's value; the previous value stops working immediately
+func rotateSurveyToken(c *gin.Context) {
+	ctx := c.Request.Context()
+	surveyID, tokenID, ok := surveyTokenParams(c)
+	if !ok {
+		return
+	}
+	before, ok := findActiveSurveyToken(c, surveyID, tokenID)
+	if !ok {
+		return
+	}
+
+	token, prefix, hash := newSurveyToken()
+	if _, err := db.ExecContext(ctx, "UPDATE survey_tokens SET prefix = ?, token_hash = ?, last_used_at = NULL WHERE id = ?", prefix, hash, tokenID); err != nil {
+		abortWithError(c, errInternal("Failed to rotate survey token", err))
+		return
+	}
+
+	st, ok := findActiveSurveyToken(c, surveyID, tokenID)
+	if !ok {
+		return
+	}
+	auditChange(c, "update", "survey_token", st.ID, before, st)
+
+	st.Token = token
+	c.JSON(http.StatusOK, APIResponse{
+		Status:  "success",
+		Message: "Survey token rotated successfully",
+		Data:    st,
+	})
+}
+
+// revokeSurveyToken disables a token; revoked tokens stay listed for reference
+func revokeSurveyToken(c *gin.Context) {
+	ctx := c.Request.Context()
+	surveyID, tokenID, ok := surveyTokenParams(c)
+	if !ok {
+		return
+	}
+	before, ok := findActiveSurveyToken(c, surveyID, tokenID)
+	if !ok {
+		return
+	}
+
+	if _, err := db.ExecContext(ctx, "UPDATE survey_tokens SET revoked_at = CURRENT_TIMESTAMP WHERE id = ?", tokenID); err != nil {
+		abortWithError(c, errInternal("Failed to revoke survey token", err))
+		return
+	}
+	auditChange(c, "delete", "survey_token", tokenID, before, nil)
+
+	c.JSON(http.StatusOK, APIResponse{
+		Status:  "success",
+		Message: "Survey token revoked successfully",
+	})
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSurveyTokens(t *testing.T) {
+	setupTestDB()
+	defer testDB.Close()
+
+	result, err := testDB.Exec("INSERT INTO surveys (title, description) VALUES ('Embedded', 'd'), ('Other', 'd')")
+	assert.NoError(t, err)
+	otherID, _ := result.LastInsertId()
+	surveyID := otherID - 1
+
+	router := setupTestRouter()
+
+	issue := func() SurveyToken {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, adminRequest("POST", fmt.Sprintf("/api/admin/surveys/%d/tokens", surveyID), []byte(`{"token":{"name":"Homepage"}}`)))
+		assert.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+		var response struct {
+			Data SurveyToken `json:"data"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		return response.Data
+	}
+
+	withToken := func(method, url, token, body string) int {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, url, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.RemoteAddr = "192.0.2.1:1234"
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	st := issue()
+	assert.Contains(t, st.Token, surveyTokenPrefix)
+	assert.Equal(t, st.Prefix, st.Token[:len(st.Prefix)])
+
+	// The token fetches and answers its own survey only
+	submit := `{"survey_response":{"user_identifier":"embed1","response_data":{"rating":"5"}}}`
+	assert.Equal(t, http.StatusOK, withToken("GET", fmt.Sprintf("/api/surveys/%d", surveyID), st.Token, ""))
+	assert.Equal(t, http.StatusOK, withToken("GET", fmt.Sprintf("/api/surveys/%d/response-schema", surveyID), st.Token, ""))
+	assert.Equal(t, http.StatusCreated, withToken("POST", fmt.Sprintf("/api/surveys/%d/responses", surveyID), st.Token, submit))
+	assert.Equal(t, http.StatusForbidden, withToken("GET", fmt.Sprintf("/api/surveys/%d/responses", surveyID), st.Token, ""))
+	assert.Equal(t, http.StatusForbidden, withToken("GET", fmt.Sprintf("/api/surveys/%d", otherID), st.Token, ""))
+	assert.Equal(t, http.StatusForbidden, withToken("GET", "/api/surveys", st.Token, ""))
+	assert.Equal(t, http.StatusForbidden, withToken("GET", "/api/admin/audit", st.Token, ""))
+
+	var actor string
+	testDB.QueryRow("SELECT actor FROM audit_log WHERE entity_type = 'survey_response'").Scan(&actor)
+	assert.Equal(t, fmt.Sprintf("survey_token:%d", st.ID), actor)
+
+	// Rotation replaces the value
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, adminRequest("POST", fmt.Sprintf("/api/admin/surveys/%d/tokens/%d/rotate", surveyID, st.ID), nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	var rotated struct {
+		Data SurveyToken `json:"data"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &rotated)
+	assert.Equal(t, st.ID, rotated.Data.ID)
+	assert.NotEqual(t, st.Token, rotated.Data.Token)
+	assert.Equal(t, http.StatusUnauthorized, withToken("GET", fmt.Sprintf("/api/surveys/%d", surveyID), st.Token, ""))
+	assert.Equal(t, http.StatusOK, withToken("GET", fmt.Sprintf("/api/surveys/%d", surveyID), rotated.Data.Token, ""))
+
+	// Revoked tokens stop working but stay listed, without their values
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, adminRequest("DELETE", fmt.Sprintf("/api/admin/surveys/%d/tokens/%d", surveyID, st.ID), nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, http.StatusUnauthorized, withToken("GET", fmt.Sprintf("/api/surveys/%d", surveyID), rotated.Data.Token, ""))
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, adminRequest("GET", fmt.Sprintf("/api/admin/surveys/%d/tokens", surveyID), nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	var listed struct {
+		Data []SurveyToken `json:"data"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &listed)
+	assert.Len(t, listed.Data, 1)
+	assert.NotNil(t, listed.Data[0].RevokedAt)
+	assert.Empty(t, listed.Data[0].Token)
+	assert.NotContains(t, w.Body.String(), rotated.Data.Token)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, adminRequest("POST", "/api/admin/surveys/999/tokens", []byte(`{"token":{"name":"Missing"}}`)))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
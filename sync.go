@@ -0,0 +1,219 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxSyncKeys bounds the idempotency keys acknowledged by one sync
+const maxSyncKeys = 100
+
+// Statuses of an uploaded response in a sync
+const (
+	UploadReceived = "received"
+	UploadDeleted  = "deleted"
+	UploadUnknown  = "unknown"
+)
+
+// SyncResult is what changed for an offline client since its last sync
+type SyncResult struct {
+	// Surveys are the published surveys to cache, with their questions
+	Surveys []Survey `json:"surveys"`
+	// Removed are the IDs of surveys to drop: deleted or no longer published
+	Removed []int `json:"removed"`
+	// Acknowledgements tell which uploaded responses the server has
+	Acknowledgements []UploadAcknowledgement `json:"acknowledgements"`
+}
+
+// UploadAcknowledgement reports a response uploaded with an Idempotency-Key
+type UploadAcknowledgement struct {
+	IdempotencyKey string     `json:"idempotency_key"`
+	Status         string     `json:"status"`
+	SurveyID       int        `json:"survey_id,omitempty"`
+	ResponseID     int        `json:"response_id,omitempty"`
+	GlobalID       string     `json:"global_id,omitempty"`
+	ReceivedAt     *time.Time `json:"received_at,omitempty"`
+}
+
+// SyncMeta pages the changes of a sync. NextCursor is always set: clients
+// keep it for their next sync, and ask again at once while HasMore.
+type SyncMeta struct {
+	Limit      int    `json:"limit"`
+	NextCursor string `json:"next_cursor"`
+	HasMore    bool   `json:"has_more"`
+}
+
+// getSync returns the surveys that changed since ?since=, the cursor of the
+// previous sync, in the caller's scope, and acknowledges the responses
+// uploaded with the idempotency keys given as ?key=. Without a cursor every
+// survey is returned.
+func getSync(c *gin.Context) {
+	ctx := c.Request.Context()
+	var errors []string
+
+	// The cursor is the sequence number of the last change seen
+	since := 0
+	if value := c.Query("since"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			errors = append(errors, "Since is not a valid cursor")
+		}
+		since = n
+	}
+
+	limit := defaultPageSize
+	if value := c.Query("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > maxPageSize {
+			errors = append(errors, fmt.Sprintf("Limit must be between 1 and %d", maxPageSize))
+		}
+		limit = n
+	}
+
+	keys := c.QueryArray("key")
+	if len(keys) > maxSyncKeys {
+		errors = append(errors, fmt.Sprintf("At most %d keys can be acknowledged at once", maxSyncKeys))
+	}
+	for _, key := range keys {
+		if !idempotencyKeyPattern.MatchString(key) {
+			errors = append(errors, fmt.Sprintf("Key %q is not a valid idempotency key", key))
+		}
+	}
+
+	if len(errors) > 0 {
+		abortWithError(c, errInvalidQuery(errors))
+		return
+	}
+
+	scope, args := surveyScope(c, "sc")
+	rows, err := db.QueryContext(ctx, `
+		SELECT sc.seq, sc.survey_id, sc.deleted
+		FROM survey_changes sc
+		WHERE sc.seq > ? AND `+scope+`
+		ORDER BY sc.seq
+		LIMIT ?
+	`, append(append([]interface{}{since}, args...), limit+1)...)
+	if err != nil {
+		abortWithError(c, errInternal("Failed to fetch survey changes", err))
+		return
+	}
+	type change struct {
+		seq, surveyID int
+		deleted       bool
+	}
+	var changes []change
+	for rows.Next() {
+		var ch change
+		if err := rows.Scan(&ch.seq, &ch.surveyID, &ch.deleted); err != nil {
+			rows.Close()
+			abortWithError(c, errInternal("Failed to scan survey changes", err))
+			return
+		}
+		changes = append(changes, ch)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		abortWithError(c, errInternal("Failed to fetch survey changes", err))
+		return
+	}
+
+	meta := SyncMeta{Limit: limit, NextCursor: strconv.Itoa(since)}
+	if len(changes) > limit {
+		changes = changes[:limit]
+		meta.HasMore = true
+	}
+	result := SyncResult{Surveys: []Survey{}, Removed: []int{}, Acknowledgements: []UploadAcknowledgement{}}
+	for _, ch := range changes {
+		meta.NextCursor = strconv.Itoa(ch.seq)
+		if ch.deleted {
+			result.Removed = append(result.Removed, ch.surveyID)
+			continue
+		}
+		survey, err := findSurvey(ctx, ch.surveyID)
+		if err != nil {
+			abortWithError(c, errInternal("Failed to fetch survey", err))
+			return
+		}
+		if survey.Status != SurveyStatusPublished {
+			result.Removed = append(result.Removed, ch.surveyID)
+			continue
+		}
+		result.Surveys = append(result.Surveys, survey)
+	}
+
+	if result.Acknowledgements, err = acknowledgeUploads(c, keys); err != nil {
+		abortWithError(c, errInternal("Failed to acknowledge responses", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Status: "success",
+		Data:   result,
+		Meta:   meta,
+	})
+}
+
+// acknowledgeUploads looks up the responses submitted with the given
+// idempotency keys to the surveys in the caller's scope, in the order of the
+// keys. A key used with several surveys is acknowledged for each.
+func acknowledgeUploads(c *gin.Context, keys []string) ([]UploadAcknowledgement, error) {
+	acks := []UploadAcknowledgement{}
+	if len(keys) == 0 {
+		return acks, nil
+	}
+	scope, args := surveyScope(c, "s")
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(keys)), ", ")
+	keyArgs := make([]interface{}, len(keys))
+	for i, key := range keys {
+		keyArgs[i] = key
+	}
+	rows, err := db.QueryContext(c.Request.Context(), `
+		SELECT r.idempotency_key, r.survey_id, r.id, r.global_id, r.created_at, r.deleted_at IS NOT NULL
+		FROM survey_responses r
+		JOIN surveys s ON s.id = r.survey_id
+		WHERE r.idempotency_key IN (`+placeholders+`) AND `+scope+`
+		ORDER BY r.id
+	`, append(keyArgs, args...)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	found := map[string][]UploadAcknowledgement{}
+	for rows.Next() {
+		var ack UploadAcknowledgement
+		var receivedAt time.Time
+		var deleted bool
+		if err := rows.Scan(&ack.IdempotencyKey, &ack.SurveyID, &ack.ResponseID, &ack.GlobalID, &receivedAt, &deleted); err != nil {
+			return nil, err
+		}
+		ack.Status = UploadReceived
+		if deleted {
+			ack.Status = UploadDeleted
+		}
+		ack.ReceivedAt = &receivedAt
+		found[ack.IdempotencyKey] = append(found[ack.IdempotencyKey], ack)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	seen := map[string]bool{}
+	for _, key := range keys {
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		if len(found[key]) == 0 {
+			acks = append(acks, UploadAcknowledgement{IdempotencyKey: key, Status: UploadUnknown})
+			continue
+		}
+		acks = append(acks, found[key]...)
+	}
+	return acks, nil
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSync(t *testing.T) {
+	setupTestDB()
+	defer testDB.Close()
+	router := setupTestRouter()
+
+	sync := func(query url.Values) (SyncResult, SyncMeta) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/api/sync?"+query.Encode(), nil)
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var body struct {
+			Data SyncResult `json:"data"`
+			Meta SyncMeta   `json:"meta"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		return body.Data, body.Meta
+	}
+	ids := func(surveys []Survey) []int {
+		var ids []int
+		for _, s := range surveys {
+			ids = append(ids, s.ID)
+		}
+		return ids
+	}
+
+	testDB.Exec(`INSERT INTO surveys (title, description, questions) VALUES ('Field visit', 'd', '[{"id":"q1","type":"text","label":"Notes"}]')`)
+	testDB.Exec("INSERT INTO surveys (title, description) VALUES ('Intake', 'd')")
+	testDB.Exec("INSERT INTO surveys (title, description, status) VALUES ('Next month', 'd', 'draft')")
+	testDB.Exec("INSERT INTO creators (name, prefix, token_hash) VALUES ('Ana', 'crt_', 'x')")
+	testDB.Exec("INSERT INTO surveys (title, description, owner_id) VALUES ('Ana''s', 'd', 1)")
+
+	// The first sync pages through every survey in the caller's scope
+	first, meta := sync(url.Values{"limit": {"2"}})
+	assert.Equal(t, []int{1, 2}, ids(first.Surveys))
+	assert.Equal(t, "Notes", first.Surveys[0].Questions[0].Label)
+	assert.True(t, meta.HasMore)
+	rest, meta := sync(url.Values{"since": {meta.NextCursor}, "limit": {"2"}})
+	assert.Empty(t, rest.Surveys)
+	assert.Equal(t, []int{3}, rest.Removed)
+	assert.False(t, meta.HasMore)
+	cursor := meta.NextCursor
+
+	// Nothing changed since
+	unchanged, meta := sync(url.Values{"since": {cursor}})
+	assert.Empty(t, unchanged.Surveys)
+	assert.Empty(t, unchanged.Removed)
+	assert.Equal(t, cursor, meta.NextCursor)
+
+	// Edits, publications and deletions are reported once each; counting
+	// responses doesn't change the survey
+	testDB.Exec("UPDATE surveys SET title = 'Field visit v2', updated_at = datetime('now', '+1 second') WHERE id = 1")
+	testDB.Exec("UPDATE surveys SET status = 'published', updated_at = datetime('now', '+1 second') WHERE id = 3")
+	testDB.Exec("UPDATE surveys SET updated_at = datetime('now', '+2 seconds') WHERE id = 1")
+	testDB.Exec("DELETE FROM surveys WHERE id = 2")
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/surveys/3/responses", strings.NewReader(`{"survey_response":{"user_identifier":"phone","response_data":{}}}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Idempotency-Key", "phone-1")
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	changed, meta := sync(url.Values{"since": {cursor}, "key": {"phone-1", "phone-2", "phone-1"}})
+	assert.Equal(t, []int{3, 1}, ids(changed.Surveys))
+	assert.Equal(t, "Field visit v2", changed.Surveys[1].Title)
+	assert.Equal(t, []int{2}, changed.Removed)
+
+	// Uploaded responses are acknowledged by their idempotency keys
+	require.Len(t, changed.Acknowledgements, 2)
+	received := changed.Acknowledgements[0]
+	assert.Equal(t, "phone-1", received.IdempotencyKey)
+	assert.Equal(t, UploadReceived, received.Status)
+	assert.Equal(t, 3, received.SurveyID)
+	assert.NotZero(t, received.ResponseID)
+	assert.NotNil(t, received.ReceivedAt)
+	assert.Equal(t, UploadAcknowledgement{IdempotencyKey: "phone-2", Status: UploadUnknown}, changed.Acknowledgements[1])
+
+	testDB.Exec("UPDATE survey_responses SET deleted_at = CURRENT_TIMESTAMP")
+	acked, _ := sync(url.Values{"since": {meta.NextCursor}, "key": {"phone-1"}})
+	assert.Empty(t, acked.Surveys)
+	assert.Equal(t, UploadDeleted, acked.Acknowledgements[0].Status)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/sync?since=soon&key="+url.QueryEscape("bad key")+"&key="+strings.Repeat("k", 256), nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "Since is not a valid cursor")
+	assert.Contains(t, w.Body.String(), "is not a valid idempotency key")
+}
@@ -0,0 +1,285 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ResponseThread is a response with its follow-up trail: earlier versions of
+// its answers and the audit history of changes to it, for attaching to
+// external case systems
+type ResponseThread struct {
+	SurveyTitle string             `json:"survey_title"`
+	Questions   []Question         `json:"questions"`
+	Response    SurveyResponse     `json:"response"`
+	Deleted     bool               `json:"deleted"`
+	Revisions   []ResponseRevision `json:"revisions"`
+	History     []AuditEntry       `json:"history"`
+	ExportedAt  time.Time          `json:"exported_at"`
+}
+
+// findResponseThread loads the thread of a survey's response, deleted or not
+func findResponseThread(ctx context.Context, survey Survey, responseID int) (ResponseThread, error) {
+	thread := ResponseThread{SurveyTitle: survey.Title, Questions: survey.Questions, ExportedAt: time.Now().UTC()}
+	var deletedAt sql.NullTime
+	response, err := scanResponse(db.QueryRowContext(ctx, `
+		SELECT `+responseColumns+` FROM `+responsesFrom+`
+		WHERE sr.id = ? AND sr.survey_id = ?
+	`, responseID, survey.ID))
+	if err != nil {
+		return thread, err
+	}
+	db.QueryRowContext(ctx, "SELECT deleted_at FROM survey_responses WHERE id = ?", responseID).Scan(&deletedAt)
+	thread.Response, thread.Deleted = response, deletedAt.Valid
+
+	if thread.Revisions, err = findRevisions(ctx, responseID, nil); err != nil {
+		return thread, err
+	}
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT `+auditColumns+`
+		FROM audit_log
+		WHERE entity_type = 'survey_response' AND entity_id = ?
+		ORDER BY id
+	`, responseID)
+	if err != nil {
+		return thread, err
+	}
+	defer rows.Close()
+	thread.History = []AuditEntry{}
+	for rows.Next() {
+		entry, err := scanAuditEntry(rows)
+		if err != nil {
+			return thread, err
+		}
+		thread.History = append(thread.History, entry)
+	}
+	return thread, rows.Err()
+}
+
+// answerLines renders response data as one "Label: answer" line per question
+func answerLines(questions []Question, data json.RawMessage) []string {
+	var answers map[string]interface{}
+	json.Unmarshal(data, &answers)
+	var lines []string
+	for _, q := range questions {
+		var text string
+		switch answer := answers[q.ID].(type) {
+		case nil:
+			text = "-"
+		case string:
+			text = answer
+		default:
+			encoded, _ := json.Marshal(answer)
+			text = string(encoded)
+		}
+		label := q.Label
+		if label == "" {
+			label = q.ID
+		}
+		lines = append(lines, label+": "+text)
+	}
+	return lines
+}
+
+// lines renders the thread as the text of its PDF
+func (t ResponseThread) lines() []string {
+	const stamp = "2006-01-02 15:04 MST"
+	r := t.Response
+	lines := []string{
+		fmt.Sprintf("Response %d to %s", r.ID, t.SurveyTitle),
+		"",
+		"Respondent: " + r.UserIdentifier,
+		"Submitted: " + r.CreatedAt.UTC().Format(stamp),
+		"Last updated: " + r.UpdatedAt.UTC().Format(stamp),
+	}
+	if t.Deleted {
+		lines = append(lines, "Status: deleted")
+	}
+	lines = append(lines, "", "Answers")
+	lines = append(lines, answerLines(t.Questions, r.ResponseData)...)
+
+	if len(t.Revisions) > 0 {
+		lines = append(lines, "", "Earlier versions")
+		for _, revision := range t.Revisions {
+			lines = append(lines, fmt.Sprintf("Revision %d, replaced %s", revision.Revision, revision.ReplacedAt.UTC().Format(stamp)))
+			for _, line := range answerLines(t.Questions, revision.ResponseData) {
+				lines = append(lines, "  "+line)
+			}
+		}
+	}
+
+	lines = append(lines, "", "History")
+	if len(t.History) == 0 {
+		lines = append(lines, "No recorded changes")
+	}
+	for _, entry := range t.History {
+		actor := entry.Actor
+		if actor == "" {
+			actor = "system"
+		}
+		lines = append(lines, fmt.Sprintf("%s  %s by %s", entry.CreatedAt.UTC().Format(stamp), entry.Action, actor))
+	}
+
+	lines = append(lines, "", "Exported "+t.ExportedAt.Format(stamp))
+	return lines
+}
+
+// PDF page layout, in points on A4 paper
+const (
+	pdfPageWidth  = 595
+	pdfPageHeight = 842
+	pdfMargin     = 50
+	pdfFontSize   = 10
+	pdfLeading    = 14
+	// pdfLineChars wraps lines to fit the page in Helvetica at pdfFontSize
+	pdfLineChars = 95
+)
+
+// pdfEscaper escapes the characters with a meaning inside PDF strings
+var pdfEscaper = strings.NewReplacer(`\`, `\\`, `(`, `\(`, `)`, `\)`, "\r", "", "\t", " ")
+
+// wrapLine splits a line into pieces of at most width characters, at spaces where it can
+func wrapLine(line string, width int) []string {
+	runes := []rune(line)
+	if len(runes) <= width {
+		return []string{line}
+	}
+	var pieces []string
+	for len(runes) > width {
+		cut := width
+		for i := width; i > width/2; i-- {
+			if runes[i] == ' ' {
+				cut = i
+				break
+			}
+		}
+		pieces = append(pieces, string(runes[:cut]))
+		runes = []rune(strings.TrimLeft(string(runes[cut:]), " "))
+	}
+	return append(pieces, string(runes))
+}
+
+// pdfLatin1 keeps the characters Helvetica's standard encoding can show,
+// replacing the rest with "?"
+func pdfLatin1(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if r > 0xff {
+			r = '?'
+		}
+		b.WriteByte(byte(r))
+	}
+	return b.String()
+}
+
+// writePDF lays out lines of text on as many A4 pages as they need
+func writePDF(lines []string) []byte {
+	var wrapped []string
+	for _, line := range lines {
+		wrapped = append(wrapped, wrapLine(line, pdfLineChars)...)
+	}
+	perPage := (pdfPageHeight - 2*pdfMargin) / pdfLeading
+	var pages [][]string
+	for len(wrapped) > perPage {
+		pages = append(pages, wrapped[:perPage])
+		wrapped = wrapped[perPage:]
+	}
+	pages = append(pages, wrapped)
+
+	// Objects: 1 catalog, 2 page tree, 3 font, then a page and its content per page
+	var objects []string
+	kids := make([]string, len(pages))
+	for i := range pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 4+2*i)
+	}
+	objects = append(objects,
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pages)),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>",
+	)
+	for i, page := range pages {
+		var content strings.Builder
+		fmt.Fprintf(&content, "BT /F1 %d Tf %d TL %d %d Td\n", pdfFontSize, pdfLeading, pdfMargin, pdfPageHeight-pdfMargin)
+		for _, line := range page {
+			fmt.Fprintf(&content, "(%s) '\n", pdfEscaper.Replace(pdfLatin1(line)))
+		}
+		content.WriteString("ET")
+		objects = append(objects,
+			fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] /Resources << /Font << /F1 3 0 R >> >> /Contents %d 0 R >>",
+				pdfPageWidth, pdfPageHeight, 5+2*i),
+			fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", content.Len(), content.String()),
+		)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, object := range objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, object)
+	}
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	return buf.Bytes()
+}
+
+// getResponseThread exports a response with its follow-up trail as JSON, or
+// as a PDF document with ?format=pdf
+func getResponseThread(c *gin.Context) {
+	ctx := c.Request.Context()
+	sID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		abortWithError(c, errInvalidID("survey"))
+		return
+	}
+	rID, err := strconv.Atoi(c.Param("response_id"))
+	if err != nil {
+		abortWithError(c, errInvalidID("response"))
+		return
+	}
+	format := c.DefaultQuery("format", "json")
+	if format != "json" && format != "pdf" {
+		abortWithError(c, errInvalidQuery([]string{"Format must be json or pdf"}))
+		return
+	}
+
+	survey, err := findSurvey(ctx, sID)
+	if err != nil {
+		abortWithError(c, errNotFound(CodeResponseNotFound, "Survey response not found"))
+		return
+	}
+	thread, err := findResponseThread(ctx, survey, rID)
+	if err == sql.ErrNoRows {
+		abortWithError(c, errNotFound(CodeResponseNotFound, "Survey response not found"))
+		return
+	}
+	if err != nil {
+		abortWithError(c, errInternal("Failed to export response thread", err))
+		return
+	}
+
+	if format == "pdf" {
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="survey-%d-response-%d.pdf"`, sID, rID))
+		c.Data(http.StatusOK, "application/pdf", writePDF(thread.lines()))
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Status: "success",
+		Data:   thread,
+	})
+}
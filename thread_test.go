@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResponseThread(t *testing.T) {
+	setupTestDB()
+	defer testDB.Close()
+	router := setupTestRouter()
+	testDB.Exec(`INSERT INTO surveys (title, description, questions) VALUES ('Support (beta)', 'd',
+		'[{"id": "rating", "type": "rating", "label": "Rating"}, {"id": "email", "type": "text", "label": "Email", "pii": true}]')`)
+
+	send := func(method, url, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, url, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		return w
+	}
+	w := send("POST", "/api/surveys/1/responses", `{"survey_response":{"user_identifier":"customer1","response_data":{"rating":2,"email":"jo@example.com"}}}`)
+	assert.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	w = send("PATCH", "/api/surveys/1/responses/1", `{"survey_response":{"response_data":{"rating":4,"email":"jo@example.com"}}}`)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, http.StatusOK, send("DELETE", "/api/surveys/1/responses/1", "").Code)
+
+	// The thread is for admins only
+	assert.Equal(t, http.StatusForbidden, send("GET", "/api/admin/surveys/1/responses/1/thread", "").Code)
+
+	get := func(url string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, adminRequest("GET", url, nil))
+		return w
+	}
+	w = get("/api/admin/surveys/1/responses/1/thread")
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var response struct {
+		Data ResponseThread `json:"data"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	thread := response.Data
+	assert.Equal(t, "Support (beta)", thread.SurveyTitle)
+	assert.True(t, thread.Deleted)
+	assert.JSONEq(t, `{"rating": 4, "email": "jo@example.com"}`, string(thread.Response.ResponseData))
+	if assert.Len(t, thread.Revisions, 1) {
+		assert.JSONEq(t, `{"rating": 2, "email": "jo@example.com"}`, string(thread.Revisions[0].ResponseData))
+	}
+	var actions []string
+	for _, entry := range thread.History {
+		actions = append(actions, entry.Action)
+	}
+	assert.Equal(t, []string{"create", "update", "delete"}, actions)
+
+	w = get("/api/admin/surveys/1/responses/1/thread?format=pdf")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/pdf", w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Header().Get("Content-Disposition"), `filename="survey-1-response-1.pdf"`)
+	pdf := w.Body.String()
+	assert.True(t, strings.HasPrefix(pdf, "%PDF-1.4\n"))
+	assert.True(t, strings.HasSuffix(pdf, "%%EOF\n"))
+	assert.Contains(t, pdf, `(Response 1 to Support \(beta\)) '`)
+	assert.Contains(t, pdf, "(Rating: 4) '")
+	assert.Contains(t, pdf, "(  Rating: 2) '")
+	assert.Contains(t, pdf, "(Status: deleted) '")
+
+	assert.Equal(t, http.StatusBadRequest, get("/api/admin/surveys/1/responses/1/thread?format=docx").Code)
+	assert.Equal(t, http.StatusNotFound, get("/api/admin/surveys/1/responses/9/thread").Code)
+	assert.Equal(t, http.StatusNotFound, get("/api/admin/surveys/2/responses/1/thread").Code)
+}
+
+func TestWritePDF(t *testing.T) {
+	assert.Equal(t, []string{"short"}, wrapLine("short", 10))
+	assert.Equal(t, []string{"one two", "three"}, wrapLine("one two three", 10))
+	assert.Equal(t, []string{"abcdefghij", "klm"}, wrapLine("abcdefghijklm", 10))
+
+	// Long documents flow onto more pages, and the xref table points at each object
+	lines := make([]string, 100)
+	for i := range lines {
+		lines[i] = fmt.Sprintf("Line %d", i)
+	}
+	pdf := writePDF(lines)
+	assert.Contains(t, string(pdf), "/Count 2 >>")
+	start := bytes.LastIndex(pdf, []byte("startxref\n"))
+	var xref int
+	fmt.Sscanf(string(pdf[start+len("startxref\n"):]), "%d", &xref)
+	assert.True(t, bytes.HasPrefix(pdf[xref:], []byte("xref\n0 8\n")))
+	var offset int
+	fmt.Sscanf(strings.Split(string(pdf[xref:]), "\n")[4], "%d", &offset)
+	assert.True(t, bytes.HasPrefix(pdf[offset:], []byte("2 0 obj\n")))
+}
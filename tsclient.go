@@ -0,0 +1,416 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+//go:generate go run . tsclient -out clients/ts
+
+// defaultTSClientDir is where tsclient writes the TypeScript client unless given -out
+const defaultTSClientDir = "clients/ts"
+
+// tsSchema is the part of JSON Schema that buildOpenAPI produces
+type tsSchema struct {
+	Ref                  string               `json:"$ref"`
+	Type                 string               `json:"type"`
+	Nullable             bool                 `json:"nullable"`
+	Items                *tsSchema            `json:"items"`
+	AdditionalProperties *tsSchema            `json:"additionalProperties"`
+	Properties           map[string]*tsSchema `json:"properties"`
+	Required             []string             `json:"required"`
+	AllOf                []*tsSchema          `json:"allOf"`
+}
+
+// tsContent is a request body or response in the OpenAPI document
+type tsContent struct {
+	Content map[string]struct {
+		Schema *tsSchema `json:"schema"`
+	} `json:"content"`
+}
+
+// tsOperation is an operation of the OpenAPI document
+type tsOperation struct {
+	Summary     string `json:"summary"`
+	OperationID string `json:"operationId"`
+	Parameters  []struct {
+		Name        string   `json:"name"`
+		In          string   `json:"in"`
+		Description string   `json:"description"`
+		Schema      tsSchema `json:"schema"`
+	} `json:"parameters"`
+	RequestBody *tsContent           `json:"requestBody"`
+	Responses   map[string]tsContent `json:"responses"`
+}
+
+// tsSpec is the OpenAPI document the TypeScript client is generated from
+type tsSpec struct {
+	Paths      map[string]map[string]tsOperation `json:"paths"`
+	Components struct {
+		Schemas map[string]*tsSchema `json:"schemas"`
+	} `json:"components"`
+}
+
+// runTSClient writes the TypeScript client generated from the OpenAPI document
+// to -out/index.ts. The document is the one /api/openapi.json serves, or that
+// of the server at -spec, to generate a client for another deployment.
+func runTSClient(args []string) error {
+	flags := flag.NewFlagSet("tsclient", flag.ContinueOnError)
+	out := flags.String("out", defaultTSClientDir, "directory the client is written to")
+	specURL := flags.String("spec", "", "URL of the OpenAPI document to generate from, e.g. http://localhost:8081/api/openapi.json; this build's if empty")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() > 0 {
+		return fmt.Errorf("tsclient takes no arguments, got %q", flags.Args())
+	}
+
+	var spec []byte
+	var err error
+	if *specURL == "" {
+		spec, err = json.Marshal(buildOpenAPI())
+	} else {
+		spec, err = fetchOpenAPI(*specURL)
+	}
+	if err != nil {
+		return err
+	}
+	source, err := generateTSClient(spec)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(*out, 0o755); err != nil {
+		return err
+	}
+	path := filepath.Join(*out, "index.ts")
+	if err := os.WriteFile(path, []byte(source), 0o644); err != nil {
+		return err
+	}
+	fmt.Printf("Wrote %s\n", path)
+	return nil
+}
+
+// fetchOpenAPI downloads the OpenAPI document served at url
+func fetchOpenAPI(url string) ([]byte, error) {
+	httpClient := &http.Client{Timeout: 30 * time.Second}
+	resp, err := httpClient.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching %s: %s", url, resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// tsIdentifier matches property names that need no quotes in TypeScript
+var tsIdentifier = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$]*$`)
+
+// tsName quotes name unless it is a valid TypeScript identifier
+func tsName(name string) string {
+	if tsIdentifier.MatchString(name) {
+		return name
+	}
+	quoted, _ := json.Marshal(name)
+	return string(quoted)
+}
+
+// tsCamel joins the alphanumeric words of s in camelCase, e.g. get_surveys_id
+// to getSurveysId
+func tsCamel(s string) string {
+	words := strings.FieldsFunc(s, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9')
+	})
+	for i := 1; i < len(words); i++ {
+		words[i] = strings.ToUpper(words[i][:1]) + words[i][1:]
+	}
+	return strings.Join(words, "")
+}
+
+// tsType returns the TypeScript type of s
+func tsType(s *tsSchema) string {
+	if s == nil {
+		return "unknown"
+	}
+	var t string
+	switch {
+	case s.Ref != "":
+		t = strings.TrimPrefix(s.Ref, "#/components/schemas/")
+	case len(s.AllOf) > 0:
+		parts := make([]string, len(s.AllOf))
+		for i, part := range s.AllOf {
+			parts[i] = tsType(part)
+		}
+		t = strings.Join(parts, " & ")
+	case s.Type == "string":
+		t = "string"
+	case s.Type == "integer" || s.Type == "number":
+		t = "number"
+	case s.Type == "boolean":
+		t = "boolean"
+	case s.Type == "array":
+		t = tsType(s.Items)
+		if strings.ContainsAny(t, "|&") {
+			t = "(" + t + ")"
+		}
+		t += "[]"
+	case s.Type == "object" && len(s.Properties) > 0:
+		t = "{ " + strings.Join(tsProperties(s), " ") + " }"
+	case s.Type == "object" && s.AdditionalProperties != nil:
+		t = "Record<string, " + tsType(s.AdditionalProperties) + ">"
+	case s.Type == "object":
+		t = "Record<string, unknown>"
+	default:
+		t = "unknown"
+	}
+	if s.Nullable {
+		t += " | null"
+	}
+	return t
+}
+
+// tsProperties returns the property declarations of an object schema, sorted by name
+func tsProperties(s *tsSchema) []string {
+	required := map[string]bool{}
+	for _, name := range s.Required {
+		required[name] = true
+	}
+	names := make([]string, 0, len(s.Properties))
+	for name := range s.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	properties := make([]string, len(names))
+	for i, name := range names {
+		optional := "?"
+		if required[name] {
+			optional = ""
+		}
+		properties[i] = tsName(name) + optional + ": " + tsType(s.Properties[name]) + ";"
+	}
+	return properties
+}
+
+// tsList returns the members of an inline object type, between its braces
+func tsList(members []string) string {
+	if len(members) == 0 {
+		return ""
+	}
+	return " " + strings.Join(members, " ") + " "
+}
+
+// tsMethodOrder sorts the operations of a path
+var tsMethodOrder = map[string]int{"get": 0, "post": 1, "put": 2, "patch": 3, "delete": 4}
+
+// tsClientPreamble declares the client's shared types and its request helper
+const tsClientPreamble = `// Code generated by "survey_form_go tsclient" from the OpenAPI document. DO NOT EDIT.
+
+export type QueryValue = string | number | boolean;
+
+/** Query parameters; filters such as answer[question][operator] go in by name. */
+export type Query<T> = T & Record<string, QueryValue | undefined>;
+
+/** The envelope of a successful response whose data is T. */
+export type Envelope<T> = Omit<APIResponse, "data"> & { data: T };
+
+export interface ClientOptions {
+  /** Where the API is served, e.g. "http://localhost:8081". */
+  baseURL: string;
+  /** Admin, creator, viewer or survey token, or aggregate API key, sent as a bearer token. */
+  token?: string;
+  /** Organization requests act in, sent as X-Organization-ID. */
+  organizationID?: number;
+  fetch?: typeof fetch;
+}
+
+export interface RequestOptions<Q = {}, H = {}> {
+  query?: Query<Q>;
+  headers?: H & Record<string, string | undefined>;
+  signal?: AbortSignal;
+}
+
+/** Thrown when the API answers with an error status. */
+export class APIError extends Error {
+  constructor(readonly status: number, readonly response: APIResponse) {
+    super(response.message || "HTTP " + status);
+    this.name = "APIError";
+  }
+
+  /** Identifies the error, e.g. "SURVEY_NOT_FOUND". */
+  get code(): string | undefined {
+    return this.response.code;
+  }
+}
+
+export class SurveyFormClient {
+  private readonly baseURL: string;
+
+  constructor(private readonly options: ClientOptions) {
+    this.baseURL = options.baseURL.replace(/\/+$/, "");
+  }
+
+  private async send(method: string, path: string, options: RequestOptions<any, any>, body?: unknown): Promise<Response> {
+    const url = new URL(this.baseURL + path);
+    for (const [name, value] of Object.entries(options.query ?? {})) {
+      if (value !== undefined) {
+        url.searchParams.set(name, String(value));
+      }
+    }
+    const headers: Record<string, string> = {};
+    if (this.options.token) {
+      headers["Authorization"] = "Bearer " + this.options.token;
+    }
+    if (this.options.organizationID) {
+      headers["X-Organization-ID"] = String(this.options.organizationID);
+    }
+    if (body !== undefined) {
+      headers["Content-Type"] = "application/json";
+    }
+    for (const [name, value] of Object.entries(options.headers ?? {})) {
+      if (value !== undefined) {
+        headers[name] = String(value);
+      }
+    }
+
+    const response = await (this.options.fetch ?? fetch)(url, {
+      method,
+      headers,
+      body: body === undefined ? undefined : JSON.stringify(body),
+      signal: options.signal,
+    });
+    if (!response.ok) {
+      let envelope: APIResponse;
+      try {
+        envelope = await response.json();
+      } catch {
+        envelope = { status: "error", message: response.statusText };
+      }
+      throw new APIError(response.status, envelope);
+    }
+    return response;
+  }
+
+  private async request<T>(method: string, path: string, options: RequestOptions<any, any>, body?: unknown): Promise<T> {
+    const response = await this.send(method, path, options, body);
+    return (await response.json()) as T;
+  }
+`
+
+// generateTSClient returns the TypeScript source of a client for the OpenAPI
+// document spec: an interface per schema, and a SurveyFormClient method per
+// operation, named after its operationId
+func generateTSClient(spec []byte) (string, error) {
+	var doc tsSpec
+	if err := json.Unmarshal(spec, &doc); err != nil {
+		return "", fmt.Errorf("reading the OpenAPI document: %w", err)
+	}
+
+	var b strings.Builder
+	b.WriteString(tsClientPreamble)
+
+	paths := make([]string, 0, len(doc.Paths))
+	for path := range doc.Paths {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	methodNames := map[string]bool{}
+	for _, path := range paths {
+		methods := make([]string, 0, len(doc.Paths[path]))
+		for method := range doc.Paths[path] {
+			methods = append(methods, method)
+		}
+		sort.Slice(methods, func(i, j int) bool { return tsMethodOrder[methods[i]] < tsMethodOrder[methods[j]] })
+
+		for _, method := range methods {
+			op := doc.Paths[path][method]
+			name := tsCamel(op.OperationID)
+			if methodNames[name] {
+				return "", fmt.Errorf("operations %s %s and another are both named %s", strings.ToUpper(method), path, name)
+			}
+			methodNames[name] = true
+			b.WriteString(tsMethod(method, path, name, op))
+		}
+	}
+	b.WriteString("}\n")
+
+	names := make([]string, 0, len(doc.Components.Schemas))
+	for name := range doc.Components.Schemas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(&b, "\nexport interface %s {\n", name)
+		for _, property := range tsProperties(doc.Components.Schemas[name]) {
+			b.WriteString("  " + property + "\n")
+		}
+		b.WriteString("}\n")
+	}
+	return b.String(), nil
+}
+
+// tsMethod returns the client method calling op. Path parameters come first,
+// then the request body, then the query and header parameters.
+func tsMethod(method, path, name string, op tsOperation) string {
+	var args, query, headers []string
+	urlPath := path
+	for _, p := range op.Parameters {
+		switch p.In {
+		case "path":
+			arg := tsCamel(p.Name)
+			args = append(args, arg+": "+tsType(&p.Schema))
+			urlPath = strings.Replace(urlPath, "{"+p.Name+"}", "${encodeURIComponent("+arg+")}", 1)
+		case "query":
+			if !strings.Contains(p.Name, "[") {
+				query = append(query, tsName(p.Name)+"?: QueryValue;")
+			}
+		case "header":
+			headers = append(headers, tsName(p.Name)+"?: string;")
+		}
+	}
+
+	body := ""
+	if op.RequestBody != nil {
+		args = append(args, "body: "+tsType(op.RequestBody.Content["application/json"].Schema))
+		body = ", body"
+	}
+	options := "RequestOptions"
+	if len(query) > 0 || len(headers) > 0 {
+		options += "<{" + tsList(query) + "}"
+		if len(headers) > 0 {
+			options += ", {" + tsList(headers) + "}"
+		}
+		options += ">"
+	}
+	args = append(args, "options: "+options+" = {}")
+
+	// The success response is the one that isn't the default error response
+	returns, call := "APIResponse", "request<APIResponse>"
+	for status, response := range op.Responses {
+		if status == "default" {
+			continue
+		}
+		for contentType, content := range response.Content {
+			if contentType != "application/json" {
+				returns, call = "Response", "send"
+			} else if s := content.Schema; len(s.AllOf) == 2 && s.AllOf[1].Properties["data"] != nil {
+				returns = "Envelope<" + tsType(s.AllOf[1].Properties["data"]) + ">"
+				call = "request<" + returns + ">"
+			}
+		}
+	}
+
+	return fmt.Sprintf("\n  /** %s */\n  %s(%s): Promise<%s> {\n    return this.%s(%q, `%s`, options%s);\n  }\n",
+		op.Summary, name, strings.Join(args, ", "), returns, call, strings.ToUpper(method), urlPath, body)
+}
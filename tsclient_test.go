@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTSClientUpToDate(t *testing.T) {
+	spec, err := json.Marshal(buildOpenAPI())
+	require.NoError(t, err)
+	source, err := generateTSClient(spec)
+	require.NoError(t, err)
+
+	committed, err := os.ReadFile(filepath.Join(defaultTSClientDir, "index.ts"))
+	require.NoError(t, err)
+	assert.True(t, string(committed) == source, "clients/ts/index.ts is out of date with the OpenAPI document; run go generate -run tsclient .")
+}
+
+func TestGenerateTSClient(t *testing.T) {
+	spec := `{
+		"paths": {
+			"/api/surveys/{id}/responses": {
+				"get": {
+					"summary": "List a survey's responses",
+					"operationId": "get_surveys_id_responses",
+					"parameters": [
+						{"name": "id", "in": "path", "schema": {"type": "integer"}},
+						{"name": "limit", "in": "query", "schema": {"type": "string"}},
+						{"name": "answer[question][operator]", "in": "query", "schema": {"type": "string"}}
+					],
+					"responses": {
+						"200": {"content": {"application/json": {"schema": {"allOf": [
+							{"$ref": "#/components/schemas/APIResponse"},
+							{"type": "object", "properties": {"data": {"type": "array", "items": {"$ref": "#/components/schemas/SurveyResponse"}}}}
+						]}}}},
+						"default": {"content": {"application/json": {"schema": {"$ref": "#/components/schemas/APIResponse"}}}}
+					}
+				},
+				"post": {
+					"summary": "Submit a response",
+					"operationId": "post_surveys_id_responses",
+					"parameters": [{"name": "id", "in": "path", "schema": {"type": "integer"}}],
+					"requestBody": {"content": {"application/json": {"schema": {"$ref": "#/components/schemas/SurveyResponse"}}}},
+					"responses": {"201": {"content": {"application/json": {"schema": {"$ref": "#/components/schemas/APIResponse"}}}}}
+				}
+			},
+			"/api/surveys/{id}/responses/export.ndjson": {
+				"get": {
+					"summary": "Stream all responses",
+					"operationId": "get_surveys_id_responses_export.ndjson",
+					"parameters": [
+						{"name": "id", "in": "path", "schema": {"type": "integer"}},
+						{"name": "X-Export-Password", "in": "header", "schema": {"type": "string"}}
+					],
+					"responses": {"200": {"content": {"application/x-ndjson": {"schema": {"type": "object"}}}}}
+				}
+			}
+		},
+		"components": {"schemas": {
+			"APIResponse": {"type": "object", "properties": {"status": {"type": "string"}, "data": {}}, "required": ["status"]},
+			"SurveyResponse": {"type": "object", "properties": {
+				"id": {"type": "integer"},
+				"user_identifier": {"type": "string"},
+				"submitted_at": {"type": "string", "format": "date-time", "nullable": true},
+				"response_data": {"type": "object", "additionalProperties": {}}
+			}, "required": ["id", "user_identifier"]}
+		}}
+	}`
+	source, err := generateTSClient([]byte(spec))
+	require.NoError(t, err)
+
+	assert.Contains(t, source, "  getSurveysIdResponses(id: number, options: RequestOptions<{ limit?: QueryValue; }> = {}): Promise<Envelope<SurveyResponse[]>> {\n"+
+		"    return this.request<Envelope<SurveyResponse[]>>(\"GET\", `/api/surveys/${encodeURIComponent(id)}/responses`, options);\n")
+	assert.Contains(t, source, "  postSurveysIdResponses(id: number, body: SurveyResponse, options: RequestOptions = {}): Promise<APIResponse> {\n"+
+		"    return this.request<APIResponse>(\"POST\", `/api/surveys/${encodeURIComponent(id)}/responses`, options, body);\n")
+	assert.Contains(t, source, "getSurveysIdResponsesExportNdjson(id: number, options: RequestOptions<{}, { \"X-Export-Password\"?: string; }> = {}): Promise<Response> {\n"+
+		"    return this.send(\"GET\", `/api/surveys/${encodeURIComponent(id)}/responses/export.ndjson`, options);\n")
+	assert.Contains(t, source, "export interface SurveyResponse {\n"+
+		"  id: number;\n"+
+		"  response_data?: Record<string, unknown>;\n"+
+		"  submitted_at?: string | null;\n"+
+		"  user_identifier: string;\n"+
+		"}\n")
+
+	_, err = generateTSClient([]byte(`{"paths": {
+		"/api/a_b": {"get": {"operationId": "get_a_b"}},
+		"/api/a/b": {"get": {"operationId": "get_a/b"}}
+	}}`))
+	assert.Error(t, err)
+}
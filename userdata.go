@@ -0,0 +1,500 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Data subject request modes
+const (
+	UserDataModeDelete    = "delete"
+	UserDataModeAnonymize = "anonymize"
+)
+
+// UserDataReport summarizes what a data subject request removed or anonymized.
+// It never includes the user identifier, so the audit log doesn't keep it.
+type UserDataReport struct {
+	Mode                 string `json:"mode"`
+	SurveyIDs            []int  `json:"survey_ids"`
+	ResponsesDeleted     int    `json:"responses_deleted"`
+	ResponsesAnonymized  int    `json:"responses_anonymized"`
+	RevisionsDeleted     int    `json:"revisions_deleted"`
+	PartialsDeleted      int    `json:"partial_responses_deleted"`
+	ScansDeleted         int    `json:"scanned_responses_deleted"`
+	ScansAnonymized      int    `json:"scanned_responses_anonymized"`
+	InvitationsDeleted   int    `json:"invitations_deleted"`
+	AuditEntriesRedacted int    `json:"audit_entries_redacted"`
+	JobsDeleted          int    `json:"jobs_deleted"`
+}
+
+// userResponseIDs selects the IDs of a user's responses, deleted or not
+const userResponseIDs = "SELECT id FROM survey_responses WHERE user_identifier = ?"
+
+// rowsAffected runs a statement and returns how many rows it changed
+func rowsAffected(ctx context.Context, exec execer, query string, args ...interface{}) (int, error) {
+	result, err := exec.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	n, err := result.RowsAffected()
+	return int(n), err
+}
+
+// eraseUserData handles a data subject request for every response tied to a
+// user identifier, across all surveys and including deleted ones. ?mode=delete
+// (the default) removes them with their revisions and autosaved answers;
+// ?mode=anonymize keeps the answers under a pseudonym no one can link back.
+// Audit snapshots of the responses are redacted either way.
+func eraseUserData(c *gin.Context) {
+	ctx := c.Request.Context()
+	userIdentifier := c.Param("user_identifier")
+	mode := c.DefaultQuery("mode", UserDataModeDelete)
+	if mode != UserDataModeDelete && mode != UserDataModeAnonymize {
+		abortWithError(c, errInvalidQuery([]string{"Mode must be either delete or anonymize"}))
+		return
+	}
+
+	// Jobs may be queued in Redis, so they're deleted apart from the rest
+	jobsDeleted, err := eraseUserJobs(ctx, userIdentifier)
+	if err != nil {
+		abortWithError(c, errInternal("Failed to erase user data", err))
+		return
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		abortWithError(c, errInternal("Failed to erase user data", err))
+		return
+	}
+	defer tx.Rollback()
+
+	report, err := eraseUserResponses(ctx, tx, userIdentifier, mode)
+	report.JobsDeleted = jobsDeleted
+	if err == nil {
+		err = recordAudit(ctx, tx, currentActor(c), mode, "user_data", 0, report)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		abortWithError(c, errInternal("Failed to erase user data", err))
+		return
+	}
+
+	message := "User data deleted successfully"
+	if mode == UserDataModeAnonymize {
+		message = "User data anonymized successfully"
+	}
+	c.JSON(http.StatusOK, APIResponse{
+		Status:  "success",
+		Message: message,
+		Data:    report,
+	})
+}
+
+// eraseUserJobs deletes the background jobs, queued, running or dead, whose
+// payload names the user or one of their responses, such as webhook deliveries
+// of the responses, whatever the mode: their payloads copy the answers.
+// Notifications only name a survey and what happened to it, so they are kept.
+func eraseUserJobs(ctx context.Context, userIdentifier string) (int, error) {
+	responseIDs := map[float64]bool{}
+	rows, err := db.QueryContext(ctx, userResponseIDs, userIdentifier)
+	if err != nil {
+		return 0, err
+	}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		responseIDs[float64(id)] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	// A limit of -1 lists every job
+	jobs, err := jobQueue.List(ctx, "", "", -1)
+	if err != nil {
+		return 0, err
+	}
+	deleted := 0
+	for _, job := range jobs {
+		var payload interface{}
+		if json.Unmarshal(job.Payload, &payload) != nil || !mentionsUser(payload, userIdentifier, responseIDs) {
+			continue
+		}
+		if err := jobQueue.Delete(ctx, job.ID); err != nil && err != errJobNotFound {
+			return deleted, err
+		}
+		deleted++
+	}
+	return deleted, nil
+}
+
+// mentionsUser reports whether a decoded JSON value holds the user identifier,
+// as a user_identifier or an email address, or one of their response IDs:
+// a response's id, or a response_id
+func mentionsUser(value interface{}, userIdentifier string, responseIDs map[float64]bool) bool {
+	switch v := value.(type) {
+	case string:
+		return strings.EqualFold(v, userIdentifier)
+	case []interface{}:
+		for _, item := range v {
+			if mentionsUser(item, userIdentifier, responseIDs) {
+				return true
+			}
+		}
+	case map[string]interface{}:
+		if id, ok := v["response_id"].(float64); ok && responseIDs[id] {
+			return true
+		}
+		if id, ok := v["id"].(float64); ok && v["response_data"] != nil && responseIDs[id] {
+			return true
+		}
+		for _, item := range v {
+			if mentionsUser(item, userIdentifier, responseIDs) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// eraseUserResponses deletes or anonymizes the responses and scanned responses of a user within tx
+func eraseUserResponses(ctx context.Context, tx *sql.Tx, userIdentifier, mode string) (UserDataReport, error) {
+	report := UserDataReport{Mode: mode, SurveyIDs: []int{}}
+
+	rows, err := tx.QueryContext(ctx, "SELECT DISTINCT survey_id FROM survey_responses WHERE user_identifier = ? ORDER BY survey_id", userIdentifier)
+	if err != nil {
+		return report, err
+	}
+	for rows.Next() {
+		var surveyID int
+		if err := rows.Scan(&surveyID); err != nil {
+			rows.Close()
+			return report, err
+		}
+		report.SurveyIDs = append(report.SurveyIDs, surveyID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return report, err
+	}
+
+	// Snapshots hold the identifier and answers; they're redacted while the
+	// responses can still be found
+	report.AuditEntriesRedacted, err = rowsAffected(ctx, tx, `
+		UPDATE audit_log SET before_data = NULL, after_data = NULL
+		WHERE entity_type = 'survey_response' AND entity_id IN (`+userResponseIDs+`)
+			AND (before_data IS NOT NULL OR after_data IS NOT NULL)
+	`, userIdentifier)
+	if err != nil {
+		return report, err
+	}
+	// An invitation is only its email, so it's deleted whatever the mode
+	if report.InvitationsDeleted, err = rowsAffected(ctx, tx, "DELETE FROM survey_invitations WHERE email = LOWER(?)", userIdentifier); err != nil {
+		return report, err
+	}
+	// So is the record of a summary email, kept for throttling
+	if _, err := tx.ExecContext(ctx, "DELETE FROM response_summary_emails WHERE email = LOWER(?) OR response_id IN ("+userResponseIDs+")", userIdentifier, userIdentifier); err != nil {
+		return report, err
+	}
+
+	if mode == UserDataModeDelete {
+		if report.RevisionsDeleted, err = rowsAffected(ctx, tx, "DELETE FROM response_revisions WHERE response_id IN ("+userResponseIDs+")", userIdentifier); err != nil {
+			return report, err
+		}
+		if report.PartialsDeleted, err = rowsAffected(ctx, tx, "DELETE FROM partial_responses WHERE response_id IN ("+userResponseIDs+")", userIdentifier); err != nil {
+			return report, err
+		}
+		if report.ResponsesDeleted, err = rowsAffected(ctx, tx, "DELETE FROM survey_responses WHERE user_identifier = ?", userIdentifier); err != nil {
+			return report, err
+		}
+		report.ScansDeleted, err = rowsAffected(ctx, tx, "DELETE FROM scanned_responses WHERE user_identifier = ?", userIdentifier)
+		return report, err
+	}
+
+	// A random key makes the pseudonym unlinkable to the identifier, while the
+	// user's responses still share it
+	key := make([]byte, 32)
+	rand.Read(key)
+	pseudonym := anonymizer{mode: AnonymizeModeHash, key: key}.pseudonym(userIdentifier)
+	if report.ResponsesAnonymized, err = rowsAffected(ctx, tx, "UPDATE survey_responses SET user_identifier = ? WHERE user_identifier = ?", pseudonym, userIdentifier); err != nil {
+		return report, err
+	}
+	report.ScansAnonymized, err = rowsAffected(ctx, tx, "UPDATE scanned_responses SET user_identifier = ? WHERE user_identifier = ?", pseudonym, userIdentifier)
+	return report, err
+}
+
+// UserDataExport holds everything stored under a user identifier, for data
+// portability requests. Responses to anonymous surveys aren't tied to it.
+type UserDataExport struct {
+	UserIdentifier string    `json:"user_identifier"`
+	ExportedAt     time.Time `json:"exported_at"`
+	// Surveys gives the context of the responses: the questions their answers are keyed by
+	Surveys          []UserDataSurvey   `json:"surveys"`
+	Responses        []UserDataResponse `json:"responses"`
+	ScannedResponses []ScannedResponse  `json:"scanned_responses"`
+}
+
+// UserDataSurvey is a survey the user responded to
+type UserDataSurvey struct {
+	ID          int        `json:"id"`
+	Title       string     `json:"title"`
+	Description string     `json:"description"`
+	Questions   []Question `json:"questions,omitempty"`
+}
+
+// UserDataResponse is one of the user's responses, deleted or not, with the
+// answers edits replaced
+type UserDataResponse struct {
+	ID           int                `json:"id"`
+	SurveyID     int                `json:"survey_id"`
+	ResponseData json.RawMessage    `json:"response_data"`
+	Channel      string             `json:"channel,omitempty"`
+	Country      string             `json:"country,omitempty"`
+	Device       string             `json:"device,omitempty"`
+	CreatedAt    time.Time          `json:"created_at"`
+	UpdatedAt    time.Time          `json:"updated_at"`
+	DeletedAt    *time.Time         `json:"deleted_at,omitempty"`
+	Revisions    []ResponseRevision `json:"revisions"`
+}
+
+// collectUserData loads the responses, revisions and scanned responses of a user
+func collectUserData(ctx context.Context, userIdentifier string) (UserDataExport, error) {
+	export := UserDataExport{
+		UserIdentifier:   userIdentifier,
+		ExportedAt:       time.Now().UTC(),
+		Surveys:          []UserDataSurvey{},
+		Responses:        []UserDataResponse{},
+		ScannedResponses: []ScannedResponse{},
+	}
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT sr.id, sr.survey_id, sr.response_data, sr.channel, sr.country, sr.device,
+		       sr.created_at, sr.updated_at, sr.deleted_at
+		FROM `+responsesFrom+`
+		WHERE sr.user_identifier = ? AND NOT s.anonymous
+		ORDER BY sr.created_at ASC, sr.id ASC
+	`, userIdentifier)
+	if err != nil {
+		return export, err
+	}
+	defer rows.Close()
+	byID := map[int]*UserDataResponse{}
+	for rows.Next() {
+		r := UserDataResponse{Revisions: []ResponseRevision{}}
+		var data []byte
+		var deletedAt sql.NullTime
+		if err := rows.Scan(&r.ID, &r.SurveyID, &data, &r.Channel, &r.Country, &r.Device, &r.CreatedAt, &r.UpdatedAt, &deletedAt); err != nil {
+			return export, err
+		}
+		if r.ResponseData, err = openResponseData(data); err != nil {
+			return export, err
+		}
+		r.DeletedAt = nullTimePtr(deletedAt)
+		export.Responses = append(export.Responses, r)
+	}
+	if err := rows.Err(); err != nil {
+		return export, err
+	}
+	rows.Close()
+
+	seen := map[int]bool{}
+	for i := range export.Responses {
+		r := &export.Responses[i]
+		byID[r.ID] = r
+		if seen[r.SurveyID] {
+			continue
+		}
+		seen[r.SurveyID] = true
+		survey, err := findSurvey(ctx, r.SurveyID)
+		if err != nil {
+			return export, err
+		}
+		export.Surveys = append(export.Surveys, UserDataSurvey{ID: survey.ID, Title: survey.Title, Description: survey.Description, Questions: survey.Questions})
+	}
+	sort.Slice(export.Surveys, func(i, j int) bool { return export.Surveys[i].ID < export.Surveys[j].ID })
+
+	rows, err = db.QueryContext(ctx, `
+		SELECT id, response_id, response_data, created_at
+		FROM response_revisions
+		WHERE response_id IN (`+userResponseIDs+`)
+		ORDER BY id
+	`, userIdentifier)
+	if err != nil {
+		return export, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var revision ResponseRevision
+		var data []byte
+		if err := rows.Scan(&revision.ID, &revision.ResponseID, &data, &revision.ReplacedAt); err != nil {
+			return export, err
+		}
+		// Responses of anonymous surveys aren't exported, nor are their revisions
+		r := byID[revision.ResponseID]
+		if r == nil {
+			continue
+		}
+		if revision.ResponseData, err = openResponseData(data); err != nil {
+			return export, err
+		}
+		revision.Revision = len(r.Revisions) + 1
+		r.Revisions = append(r.Revisions, revision)
+	}
+	if err := rows.Err(); err != nil {
+		return export, err
+	}
+	rows.Close()
+
+	rows, err = db.QueryContext(ctx, "SELECT "+scanColumns+" FROM scanned_responses WHERE user_identifier = ? ORDER BY id", userIdentifier)
+	if err != nil {
+		return export, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		s, err := scanScannedResponse(rows)
+		if err != nil {
+			return export, err
+		}
+		export.ScannedResponses = append(export.ScannedResponses, s)
+	}
+	return export, rows.Err()
+}
+
+// writeUserDataCSV writes one row per answer of the user's current responses
+func writeUserDataCSV(w *csv.Writer, export UserDataExport) {
+	surveys := map[int]UserDataSurvey{}
+	for _, s := range export.Surveys {
+		surveys[s.ID] = s
+	}
+
+	w.Write([]string{"survey_id", "survey_title", "response_id", "question_id", "question_label", "answer", "created_at", "updated_at"})
+	for _, r := range export.Responses {
+		if r.DeletedAt != nil {
+			continue
+		}
+		survey := surveys[r.SurveyID]
+		labels := map[string]string{}
+		for _, q := range survey.Questions {
+			labels[q.ID] = q.Label
+		}
+		var answers map[string]json.RawMessage
+		json.Unmarshal(r.ResponseData, &answers)
+		questionIDs := make([]string, 0, len(answers))
+		for id := range answers {
+			questionIDs = append(questionIDs, id)
+		}
+		sort.Strings(questionIDs)
+		for _, id := range questionIDs {
+			w.Write([]string{
+				strconv.Itoa(r.SurveyID),
+				survey.Title,
+				strconv.Itoa(r.ID),
+				id,
+				labels[id],
+				answerText(answers[id]),
+				r.CreatedAt.UTC().Format(time.RFC3339),
+				r.UpdatedAt.UTC().Format(time.RFC3339),
+			})
+		}
+	}
+	w.Flush()
+}
+
+// userDataZip packs the export as user-data.json and its answers as
+// responses.csv, encrypted with password unless it is empty
+func userDataZip(export UserDataExport, password string) ([]byte, error) {
+	var b bytes.Buffer
+	zw := zip.NewWriter(&b)
+
+	err := addZipEntry(zw, "user-data.json", password, func(w io.Writer) error {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(export)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = addZipEntry(zw, "responses.csv", password, func(w io.Writer) error {
+		cw := csv.NewWriter(w)
+		writeUserDataCSV(cw, export)
+		return cw.Error()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// exportUserData handles a data portability request: every response tied to
+// a user identifier, with the surveys they answer, as JSON or with ?format=zip
+// as a ZIP archive of that JSON and a CSV of the answers, password-protected
+// with an X-Export-Password. Exports are audited.
+func exportUserData(c *gin.Context) {
+	ctx := c.Request.Context()
+	userIdentifier := c.Param("user_identifier")
+	format := c.DefaultQuery("format", "json")
+	if format != "json" && format != "zip" {
+		abortWithError(c, errInvalidQuery([]string{"Format must be json or zip"}))
+		return
+	}
+	password, ok := exportPassword(c)
+	if !ok {
+		return
+	}
+	if password != "" && format != "zip" {
+		abortWithError(c, errInvalidQuery([]string{"Password-protected exports need format=zip"}))
+		return
+	}
+
+	export, err := collectUserData(ctx, userIdentifier)
+	if err != nil {
+		abortWithError(c, errInternal("Failed to export user data", err))
+		return
+	}
+	// Like erasure reports, the audit entry doesn't keep the user identifier
+	recordAudit(ctx, db, currentActor(c), "export", "user_data", 0, map[string]int{
+		"responses":         len(export.Responses),
+		"scanned_responses": len(export.ScannedResponses),
+	})
+
+	if format == "json" {
+		c.JSON(http.StatusOK, APIResponse{
+			Status: "success",
+			Data:   export,
+		})
+		return
+	}
+
+	archive, err := userDataZip(export, password)
+	if err != nil {
+		abortWithError(c, errInternal("Failed to export user data", err))
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="user-data-%s.zip"`, export.ExportedAt.Format("20060102")))
+	c.Data(http.StatusOK, "application/zip", archive)
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEraseUserData(t *testing.T) {
+	setupTestDB()
+	defer testDB.Close()
+
+	testDB.Exec("INSERT INTO surveys (title, description) VALUES ('First', 'd'), ('Second', 'd')")
+	testDB.Exec(`INSERT INTO survey_responses (survey_id, user_identifier, response_data) VALUES
+		(1, 'alice', '{"rating": 5}'), (2, 'alice', '{"rating": 3}'), (1, 'bob', '{"rating": 1}')`)
+	testDB.Exec("UPDATE survey_responses SET deleted_at = CURRENT_TIMESTAMP WHERE id = 2")
+	testDB.Exec(`INSERT INTO response_revisions (response_id, response_data) VALUES (1, '{"rating": 4}')`)
+	testDB.Exec(`INSERT INTO partial_responses (id, survey_id, answers, response_id) VALUES ('p1', 1, '{"rating": 5}', 1)`)
+	testDB.Exec(`INSERT INTO scanned_responses (survey_id, user_identifier, fields, status) VALUES (1, 'alice', '{}', 'needs_review')`)
+	testDB.Exec(`INSERT INTO audit_log (actor, action, entity_type, entity_id, details, after_data)
+		VALUES ('anonymous', 'create', 'survey_response', 1, '{}', '{"user_identifier": "alice"}')`)
+	router := setupTestRouter()
+	// Webhook deliveries carry the response, and summary emails its ID;
+	// notifications only name the survey
+	enqueueJob(context.Background(), JobKindWebhook, WebhookDelivery{SubscriptionID: 1, Event: EventResponseCreated,
+		Body: json.RawMessage(`{"event": "response.created", "data": {"id": 1, "user_identifier": "alice", "response_data": {"rating": 5}}}`)}, 1)
+	testDB.Exec("UPDATE jobs SET status = 'dead'")
+	enqueueJob(context.Background(), JobKindWebhook, WebhookDelivery{SubscriptionID: 1, Event: EventResponseUpdated,
+		Body: json.RawMessage(`{"rating": 4, "id": 2, "response_data": {"rating": 4}}`)}, 1)
+	enqueueJob(context.Background(), JobKindWebhook, WebhookDelivery{SubscriptionID: 1, Event: EventResponseCreated,
+		Body: json.RawMessage(`{"data": {"id": 3, "user_identifier": "bob", "response_data": {"rating": 1}}}`)}, 1)
+	enqueueJob(context.Background(), JobKindResponseSummary, responseSummaryJob{ResponseID: 2, Email: "someone@example.com"}, 1)
+
+	erase := func(url string) UserDataReport {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, adminRequest("DELETE", url, nil))
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var response struct {
+			Data UserDataReport `json:"data"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		return response.Data
+	}
+	count := func(query string) int {
+		var n int
+		testDB.QueryRow(query).Scan(&n)
+		return n
+	}
+
+	// Admin only
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("DELETE", "/api/users/alice/data", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	report := erase("/api/users/alice/data")
+	assert.Equal(t, UserDataReport{
+		Mode: UserDataModeDelete, SurveyIDs: []int{1, 2}, ResponsesDeleted: 2, RevisionsDeleted: 1,
+		PartialsDeleted: 1, ScansDeleted: 1, AuditEntriesRedacted: 1, JobsDeleted: 3,
+	}, report)
+	assert.Equal(t, 0, count("SELECT COUNT(*) FROM survey_responses WHERE user_identifier = 'alice'"))
+	assert.Equal(t, 1, count("SELECT COUNT(*) FROM survey_responses WHERE user_identifier = 'bob'"))
+	assert.Equal(t, 0, count("SELECT COUNT(*) FROM audit_log WHERE after_data LIKE '%alice%' OR details LIKE '%alice%'"))
+	assert.Equal(t, 1, count("SELECT COUNT(*) FROM audit_log WHERE action = 'delete' AND entity_type = 'user_data'"))
+	assert.Equal(t, 0, count("SELECT COUNT(*) FROM jobs WHERE payload LIKE '%alice%' OR payload LIKE '%\"id\":2%' OR payload LIKE '%response_id%'"))
+	assert.Equal(t, 1, count("SELECT COUNT(*) FROM jobs WHERE payload LIKE '%bob%'"))
+
+	// Anonymizing keeps the answers under a pseudonym; invitations are only
+	// their email, so they go either way
+	testDB.Exec("INSERT INTO survey_invitations (survey_id, email, status) VALUES (1, 'bob', 'responded')")
+	report = erase("/api/users/bob/data?mode=anonymize")
+	assert.Equal(t, 1, report.ResponsesAnonymized)
+	assert.Equal(t, 1, report.InvitationsDeleted)
+	assert.Equal(t, 1, report.JobsDeleted)
+	assert.Equal(t, 0, count("SELECT COUNT(*) FROM survey_responses WHERE user_identifier = 'bob'"))
+	assert.Equal(t, 1, count("SELECT COUNT(*) FROM survey_responses WHERE user_identifier LIKE 'anon_%'"))
+
+	// Requests for unknown users succeed with nothing to report
+	report = erase("/api/users/nobody/data")
+	assert.Equal(t, 0, report.ResponsesDeleted)
+	assert.Equal(t, []int{}, report.SurveyIDs)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, adminRequest("DELETE", "/api/users/bob/data?mode=shred", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestExportUserData(t *testing.T) {
+	setupTestDB()
+	defer testDB.Close()
+
+	testDB.Exec(`INSERT INTO surveys (title, description, questions) VALUES
+		('First', 'd', '[{"id": "rating", "type": "rating", "label": "How was it?"}]'), ('Second', 'd', '[]')`)
+	testDB.Exec("INSERT INTO surveys (title, description, anonymous) VALUES ('Anonymous', 'd', 1)")
+	testDB.Exec(`INSERT INTO survey_responses (survey_id, user_identifier, response_data) VALUES
+		(1, 'alice', '{"rating": 5}'), (2, 'alice', '{"comment": "ok"}'), (1, 'bob', '{"rating": 1}'), (3, 'alice', '{"rating": 2}')`)
+	testDB.Exec("UPDATE survey_responses SET deleted_at = CURRENT_TIMESTAMP WHERE id = 2")
+	testDB.Exec(`INSERT INTO response_revisions (response_id, response_data) VALUES (1, '{"rating": 4}'), (3, '{"rating": 2}')`)
+	router := setupTestRouter()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/users/alice/export", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, adminRequest("GET", "/api/users/alice/export", nil))
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var response struct {
+		Data UserDataExport `json:"data"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	export := response.Data
+	assert.Equal(t, "alice", export.UserIdentifier)
+	assert.Len(t, export.Surveys, 2)
+	assert.Equal(t, "How was it?", export.Surveys[0].Questions[0].Label)
+	assert.Len(t, export.Responses, 2)
+	assert.Len(t, export.Responses[0].Revisions, 1)
+	assert.NotNil(t, export.Responses[1].DeletedAt)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, adminRequest("GET", "/api/users/alice/export?format=zip", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/zip", w.Header().Get("Content-Type"))
+	archive, err := zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+	assert.NoError(t, err)
+	files := map[string]string{}
+	for _, f := range archive.File {
+		r, _ := f.Open()
+		content, _ := io.ReadAll(r)
+		files[f.Name] = string(content)
+	}
+	assert.Contains(t, files["user-data.json"], `"user_identifier": "alice"`)
+	// Deleted responses are only in the JSON
+	assert.True(t, strings.HasPrefix(files["responses.csv"], "survey_id,survey_title,response_id,question_id,question_label,answer,created_at,updated_at\n"))
+	assert.Contains(t, files["responses.csv"], "1,First,1,rating,How was it?,5,")
+	assert.NotContains(t, files["responses.csv"], "comment")
+
+	// A password encrypts both files
+	w = httptest.NewRecorder()
+	req = adminRequest("GET", "/api/users/alice/export?format=zip", nil)
+	req.Header.Set(exportPasswordHeader, "correct horse")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	archive, err = zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+	assert.NoError(t, err)
+	if assert.Len(t, archive.File, 2) {
+		content, err := openAESZipFile(archive.File[0], "correct horse")
+		assert.NoError(t, err)
+		assert.Contains(t, string(content), `"user_identifier": "alice"`)
+		content, err = openAESZipFile(archive.File[1], "correct horse")
+		assert.NoError(t, err)
+		assert.Equal(t, files["responses.csv"], string(content))
+	}
+	w = httptest.NewRecorder()
+	req = adminRequest("GET", "/api/users/alice/export", nil)
+	req.Header.Set(exportPasswordHeader, "correct horse")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	var audited int
+	testDB.QueryRow("SELECT COUNT(*) FROM audit_log WHERE action = 'export' AND entity_type = 'user_data' AND details NOT LIKE '%alice%'").Scan(&audited)
+	assert.Equal(t, 3, audited)
+}
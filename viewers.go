@@ -0,0 +1,262 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// viewerTokenPrefix marks bearer tokens of viewer accounts
+const viewerTokenPrefix = "vwr_"
+
+// maxViewerAttributes bounds the attributes of a viewer account
+const maxViewerAttributes = 10
+
+// viewerRoutes lists the requests a viewer token permits, on any survey. They
+// are read-only and, for analytics, scoped to the viewer's attributes.
+var viewerRoutes = map[string]bool{
+	"GET /api/surveys/:id":          true,
+	"GET /api/surveys/:id/results":  true,
+	"GET /api/surveys/:id/quality":  true,
+	"GET /api/surveys/:id/formulas": true,
+}
+
+// Viewer is an account seeing analytics of the responses matching its
+// attributes only, e.g. a manager seeing their department's
+type Viewer struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+	// Attributes map question IDs to the answer a response needs to be visible
+	Attributes map[string]string `json:"attributes"`
+	// Prefix identifies the token without revealing it
+	Prefix string `json:"prefix"`
+	// Token is only returned when the account is created
+	Token      string     `json:"token,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+// CreateViewerRequest represents the request body for creating a viewer account
+type CreateViewerRequest struct {
+	Viewer struct {
+		Name       string            `json:"name" binding:"required"`
+		Attributes map[string]string `json:"attributes" binding:"required"`
+	} `json:"viewer" binding:"required"`
+}
+
+// viewerColumns lists the columns read by scanViewer
+const viewerColumns = "id, name, attributes, prefix, created_at, last_used_at, revoked_at"
+
+// scanViewer scans a row selected with viewerColumns
+func scanViewer(row rowScanner) (Viewer, error) {
+	var v Viewer
+	var attributes []byte
+	var lastUsedAt, revokedAt sql.NullTime
+	err := row.Scan(&v.ID, &v.Name, &attributes, &v.Prefix, &v.CreatedAt, &lastUsedAt, &revokedAt)
+	if err == nil {
+		err = json.Unmarshal(attributes, &v.Attributes)
+	}
+	v.LastUsedAt = nullTimePtr(lastUsedAt)
+	v.RevokedAt = nullTimePtr(revokedAt)
+	return v, err
+}
+
+// findViewerByToken loads the active account matching a bearer token
+func findViewerByToken(ctx context.Context, token string) (Viewer, error) {
+	return scanViewer(db.QueryRowContext(ctx,
+		"SELECT "+viewerColumns+" FROM viewers WHERE token_hash = ? AND revoked_at IS NULL",
+		hashSurveyToken(token)))
+}
+
+// viewerAuth restricts requests authenticated with a viewer token to the
+// viewerRoutes, with the viewer's attributes set to scope analytics. Other
+// requests pass through.
+func viewerAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		token := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if !strings.HasPrefix(token, viewerTokenPrefix) {
+			c.Next()
+			return
+		}
+
+		v, err := findViewerByToken(ctx, token)
+		if err != nil {
+			abortWithError(c, &APIError{
+				Status:  http.StatusUnauthorized,
+				Code:    CodeInvalidViewerToken,
+				Message: "Invalid or revoked viewer token",
+			})
+			return
+		}
+		if !viewerRoutes[c.Request.Method+" "+c.FullPath()] {
+			abortWithError(c, &APIError{
+				Status:  http.StatusForbidden,
+				Code:    CodeViewerTokenDenied,
+				Message: "Viewer token does not permit this request",
+			})
+			return
+		}
+
+		db.ExecContext(ctx, "UPDATE viewers SET last_used_at = CURRENT_TIMESTAMP WHERE id = ?", v.ID)
+		c.Set("actor", fmt.Sprintf("viewer:%d", v.ID))
+		c.Set("viewer_attributes", v.Attributes)
+		c.Next()
+	}
+}
+
+// viewerScope returns the answer filters limiting a viewer's analytics to the
+// responses matching all of their attributes; none for other requests
+func viewerScope(c *gin.Context) []answerFilter {
+	attributes, _ := c.Value("viewer_attributes").(map[string]string)
+	questions := make([]string, 0, len(attributes))
+	for question := range attributes {
+		questions = append(questions, question)
+	}
+	sort.Strings(questions)
+
+	filters := make([]answerFilter, len(questions))
+	for i, question := range questions {
+		filters[i] = answerFilter{Question: question, Operator: "eq", Value: attributes[question]}
+	}
+	return filters
+}
+
+// validateViewerAttributes checks the attributes of a viewer account; at least
+// one is needed, as a viewer without any would see every response
+func validateViewerAttributes(attributes map[string]string) []string {
+	if len(attributes) == 0 {
+		return []string{"Attributes must not be empty"}
+	}
+	if len(attributes) > maxViewerAttributes {
+		return []string{fmt.Sprintf("At most %d attributes are allowed", maxViewerAttributes)}
+	}
+	var errors []string
+	for question, value := range attributes {
+		if !questionIDPattern.MatchString(question) {
+			errors = append(errors, fmt.Sprintf("Attribute %q must be a question ID of 1-64 letters, digits, underscores or dashes", question))
+		}
+		if value == "" || len(value) > 100 {
+			errors = append(errors, fmt.Sprintf("Attribute %q must have a value of 1-100 characters", question))
+		}
+	}
+	sort.Strings(errors)
+	return errors
+}
+
+// findActiveViewer loads an unrevoked viewer account, responding 404 when there is none
+func findActiveViewer(c *gin.Context, viewerID int) (Viewer, bool) {
+	v, err := scanViewer(db.QueryRowContext(c.Request.Context(),
+		"SELECT "+viewerColumns+" FROM viewers WHERE id = ? AND revoked_at IS NULL", viewerID))
+	if err == sql.ErrNoRows {
+		abortWithError(c, errNotFound(CodeViewerNotFound, "Viewer not found"))
+		return v, false
+	}
+	if err != nil {
+		abortWithError(c, errInternal("Failed to fetch viewer", err))
+		return v, false
+	}
+	return v, true
+}
+
+// getViewers lists the viewer accounts, including revoked ones, without their tokens
+func getViewers(c *gin.Context) {
+	rows, err := db.QueryContext(c.Request.Context(), "SELECT "+viewerColumns+" FROM viewers ORDER BY id")
+	if err != nil {
+		abortWithError(c, errInternal("Failed to fetch viewers", err))
+		return
+	}
+	defer rows.Close()
+
+	viewers := []Viewer{}
+	for rows.Next() {
+		v, err := scanViewer(rows)
+		if err != nil {
+			abortWithError(c, errInternal("Failed to scan viewer data", err))
+			return
+		}
+		viewers = append(viewers, v)
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Status: "success",
+		Data:   viewers,
+	})
+}
+
+// createViewer creates a viewer account; its token is shown once
+func createViewer(c *gin.Context) {
+	ctx := c.Request.Context()
+	var req CreateViewerRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		abortWithError(c, errInvalidRequest(err))
+		return
+	}
+
+	errors := validateViewerAttributes(req.Viewer.Attributes)
+	if len(req.Viewer.Name) > 100 {
+		errors = append([]string{"Name must be less than 100 characters"}, errors...)
+	}
+	if len(errors) > 0 {
+		abortWithError(c, errValidation("Failed to create viewer", errors))
+		return
+	}
+
+	token := viewerTokenPrefix + randomHex(24)
+	attributes, _ := json.Marshal(req.Viewer.Attributes)
+	result, err := db.ExecContext(ctx, `
+		INSERT INTO viewers (name, attributes, prefix, token_hash, created_at)
+		VALUES (?, ?, ?, ?, CURRENT_TIMESTAMP)
+	`, req.Viewer.Name, string(attributes), token[:len(viewerTokenPrefix)+8], hashSurveyToken(token))
+	if err != nil {
+		abortWithError(c, errInternal("Failed to create viewer", err))
+		return
+	}
+
+	id, _ := result.LastInsertId()
+	v, ok := findActiveViewer(c, int(id))
+	if !ok {
+		return
+	}
+	auditChange(c, "create", "viewer", v.ID, nil, v)
+
+	v.Token = token
+	c.JSON(http.StatusCreated, APIResponse{
+		Status:  "success",
+		Message: "Viewer created successfully",
+		Data:    v,
+	})
+}
+
+// revokeViewer disables a viewer account; revoked accounts stay listed for reference
+func revokeViewer(c *gin.Context) {
+	viewerID, err := strconv.Atoi(c.Param("viewer_id"))
+	if err != nil {
+		abortWithError(c, errInvalidID("viewer"))
+		return
+	}
+	before, ok := findActiveViewer(c, viewerID)
+	if !ok {
+		return
+	}
+
+	if _, err := db.ExecContext(c.Request.Context(), "UPDATE viewers SET revoked_at = CURRENT_TIMESTAMP WHERE id = ?", viewerID); err != nil {
+		abortWithError(c, errInternal("Failed to revoke viewer", err))
+		return
+	}
+	auditChange(c, "delete", "viewer", viewerID, before, nil)
+
+	c.JSON(http.StatusOK, APIResponse{
+		Status:  "success",
+		Message: "Viewer revoked successfully",
+	})
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestViewerScopedAnalytics(t *testing.T) {
+	setupTestDB()
+	defer testDB.Close()
+
+	result, err := testDB.Exec("INSERT INTO surveys (title, description) VALUES ('Engagement', 'd')")
+	assert.NoError(t, err)
+	surveyID, _ := result.LastInsertId()
+	for i, answers := range []string{
+		`{"department": "Sales", "rating": 5}`,
+		`{"department": "Sales", "rating": 4}`,
+		`{"department": "Support", "rating": 1}`,
+		`{"rating": 2}`,
+	} {
+		testDB.Exec("INSERT INTO survey_responses (survey_id, user_identifier, response_data) VALUES (?, ?, ?)",
+			surveyID, fmt.Sprintf("employee%d", i), answers)
+	}
+	router := setupTestRouter()
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, adminRequest("POST", "/api/admin/viewers", []byte(`{"viewer":{"name":"Sales manager","attributes":{"department":"Sales"}}}`)))
+	assert.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var created struct {
+		Data Viewer `json:"data"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &created)
+	assert.Contains(t, created.Data.Token, viewerTokenPrefix)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, adminRequest("POST", "/api/admin/viewers", []byte(`{"viewer":{"name":"Everyone","attributes":{}}}`)))
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+	asViewer := func(url, token string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", url, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// The viewer's results only count their department, even when filtering on another
+	for _, url := range []string{
+		fmt.Sprintf("/api/surveys/%d/results", surveyID),
+		fmt.Sprintf("/api/surveys/%d/results?answer[department]=Support", surveyID),
+	} {
+		w = asViewer(url, created.Data.Token)
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var results struct {
+			Data SurveyResults `json:"data"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &results)
+		for _, q := range results.Data.Questions {
+			if q.ID == "rating" {
+				if url == fmt.Sprintf("/api/surveys/%d/results", surveyID) {
+					assert.Equal(t, map[string]int{"4": 1, "5": 1}, q.Values)
+				} else {
+					assert.Empty(t, q.Values)
+				}
+			}
+		}
+	}
+
+	// Viewers can't read responses or administer anything
+	assert.Equal(t, http.StatusForbidden, asViewer(fmt.Sprintf("/api/surveys/%d/responses", surveyID), created.Data.Token).Code)
+	assert.Equal(t, http.StatusForbidden, asViewer(fmt.Sprintf("/api/surveys/%d/responses/export.ndjson", surveyID), created.Data.Token).Code)
+	assert.Equal(t, http.StatusForbidden, asViewer("/api/admin/viewers", created.Data.Token).Code)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, adminRequest("DELETE", fmt.Sprintf("/api/admin/viewers/%d", created.Data.ID), nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, http.StatusUnauthorized, asViewer(fmt.Sprintf("/api/surveys/%d/results", surveyID), created.Data.Token).Code)
+}
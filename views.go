@@ -0,0 +1,351 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ResponseView is a saved, named configuration of the response browser
+type ResponseView struct {
+	ID        int             `json:"id"`
+	SurveyID  int             `json:"survey_id"`
+	Name      string          `json:"name"`
+	Filters   json.RawMessage `json:"filters"`
+	Columns   []string        `json:"columns"`
+	Sort      string          `json:"sort"`
+	Order     string          `json:"order"`
+	CreatedAt time.Time       `json:"created_at"`
+	UpdatedAt time.Time       `json:"updated_at"`
+}
+
+// CreateViewRequest represents the request body for saving a response view
+type CreateViewRequest struct {
+	View struct {
+		Name    string          `json:"name" binding:"required"`
+		Filters json.RawMessage `json:"filters"`
+		Columns []string        `json:"columns"`
+		Sort    string          `json:"sort"`
+		Order   string          `json:"order"`
+	} `json:"view" binding:"required"`
+}
+
+// viewFilterParams are the response list filters a view may save, besides
+// answer[question] and answer[question][operator]
+var viewFilterParams = append(append([]string{}, metadataFilterColumns...),
+	"bot_score_min", "bot_score_max", "quality_flag", "exclude_quality")
+
+// viewFilterValues reads a view's filters, an object of the response list's
+// filter parameters such as {"channel": "email", "answer[rating][gte]": 4},
+// as the query parameters they stand for
+func viewFilterValues(filters json.RawMessage) (url.Values, []string) {
+	values := url.Values{}
+	var fields map[string]interface{}
+	if len(filters) == 0 || isJSONNull(filters) {
+		return values, nil
+	}
+	if err := json.Unmarshal(filters, &fields); err != nil {
+		return values, []string{"Filters must be a JSON object"}
+	}
+
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var errors []string
+	for _, key := range keys {
+		if !containsString(viewFilterParams, key) && !answerFilterParam.MatchString(key) {
+			if questionIDPattern.MatchString(key) {
+				errors = append(errors, fmt.Sprintf("Filters: %s is not a response filter; answers are filtered with answer[%s]", key, key))
+			} else {
+				errors = append(errors, fmt.Sprintf("Filters: %s is not a response filter", key))
+			}
+			continue
+		}
+		switch value := fields[key].(type) {
+		case string:
+			values.Set(key, value)
+		case float64:
+			values.Set(key, strconv.FormatFloat(value, 'f', -1, 64))
+		case bool:
+			values.Set(key, strconv.FormatBool(value))
+		default:
+			errors = append(errors, fmt.Sprintf("Filters: %s must be a string, number or boolean", key))
+		}
+	}
+	if len(errors) > 0 {
+		return values, errors
+	}
+
+	// The filters must parse as they will when the view is applied
+	var q responseListQuery
+	for _, problem := range append(parseMetadataFilters(values, &q), parseAnswerFilters(values, &q)...) {
+		errors = append(errors, "Filters: "+problem)
+	}
+	return values, errors
+}
+
+// viewColumns lists the columns read by scanResponseView
+const viewColumns = "id, survey_id, name, filters, columns, sort, sort_order, created_at, updated_at"
+
+// scanResponseView scans a row selected with viewColumns
+func scanResponseView(row rowScanner) (ResponseView, error) {
+	var view ResponseView
+	var filters, columns []byte
+	err := row.Scan(&view.ID, &view.SurveyID, &view.Name, &filters, &columns, &view.Sort, &view.Order, &view.CreatedAt, &view.UpdatedAt)
+	if err != nil {
+		return view, err
+	}
+	view.Filters = filters
+	err = json.Unmarshal(columns, &view.Columns)
+	return view, err
+}
+
+// findResponseView loads a saved view belonging to a survey
+func findResponseView(ctx context.Context, surveyID, viewID int) (ResponseView, error) {
+	return scanResponseView(db.QueryRowContext(ctx, `
+		SELECT `+viewColumns+`
+		FROM response_views
+		WHERE id = ? AND survey_id = ?
+	`, viewID, surveyID))
+}
+
+// getResponseViews returns the saved views of a survey
+func getResponseViews(c *gin.Context) {
+	ctx := c.Request.Context()
+	surveyID := c.Param("id")
+	id, err := strconv.Atoi(surveyID)
+	if err != nil {
+		abortWithError(c, errInvalidID("survey"))
+		return
+	}
+
+	// Check if survey exists
+	var exists bool
+	err = db.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM surveys WHERE id = ?)", id).Scan(&exists)
+	if err != nil || !exists {
+		abortWithError(c, errNotFound(CodeSurveyNotFound, "Survey not found"))
+		return
+	}
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT `+viewColumns+`
+		FROM response_views
+		WHERE survey_id = ?
+		ORDER BY name
+	`, id)
+	if err != nil {
+		abortWithError(c, errInternal("Failed to fetch views", err))
+		return
+	}
+	defer rows.Close()
+
+	var views []ResponseView
+	for rows.Next() {
+		view, err := scanResponseView(rows)
+		if err != nil {
+			abortWithError(c, errInternal("Failed to scan view data", err))
+			return
+		}
+		views = append(views, view)
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Status: "success",
+		Data:   views,
+	})
+}
+
+// getResponseView returns a specific saved view
+func getResponseView(c *gin.Context) {
+	ctx := c.Request.Context()
+	sID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		abortWithError(c, errInvalidID("survey"))
+		return
+	}
+
+	vID, err := strconv.Atoi(c.Param("view_id"))
+	if err != nil {
+		abortWithError(c, errInvalidID("view"))
+		return
+	}
+
+	view, err := findResponseView(ctx, sID, vID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			abortWithError(c, errNotFound(CodeViewNotFound, "View not found"))
+			return
+		}
+		abortWithError(c, errInternal("Failed to fetch view", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Status: "success",
+		Data:   view,
+	})
+}
+
+// createResponseView saves a named view for a survey
+func createResponseView(c *gin.Context) {
+	ctx := c.Request.Context()
+	sID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		abortWithError(c, errInvalidID("survey"))
+		return
+	}
+
+	survey, err := findSurvey(ctx, sID)
+	if err != nil {
+		abortWithError(c, errNotFound(CodeSurveyNotFound, "Survey not found"))
+		return
+	}
+
+	var req CreateViewRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		abortWithError(c, errInvalidRequest(err))
+		return
+	}
+
+	// Defaults match the response listing
+	view := req.View
+	if len(view.Filters) == 0 || string(view.Filters) == "null" {
+		view.Filters = json.RawMessage(`{}`)
+	}
+	if view.Columns == nil {
+		view.Columns = []string{}
+	}
+	if view.Sort == "" {
+		view.Sort = "updated_at"
+	}
+	if view.Order == "" {
+		view.Order = "desc"
+	}
+
+	// Validation
+	var errors []string
+	if len(view.Name) > 100 {
+		errors = append(errors, "Name must be less than 100 characters")
+	}
+	filters, problems := viewFilterValues(view.Filters)
+	errors = append(errors, problems...)
+	// Filters and columns refer to the survey's questions, when it defines them
+	if len(survey.Questions) > 0 {
+		known := func(questionID string) bool {
+			for _, q := range survey.Questions {
+				if q.ID == questionID {
+					return true
+				}
+			}
+			return false
+		}
+		keys := make([]string, 0, len(filters))
+		for key := range filters {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if m := answerFilterParam.FindStringSubmatch(key); m != nil && !known(m[1]) {
+				errors = append(errors, fmt.Sprintf("Filters: %s: question %s doesn't exist", key, m[1]))
+			}
+		}
+		for _, column := range view.Columns {
+			if !known(column) {
+				errors = append(errors, fmt.Sprintf("Columns: question %s doesn't exist", column))
+			}
+		}
+	} else {
+		for _, column := range view.Columns {
+			if !questionIDPattern.MatchString(column) {
+				errors = append(errors, "Columns must be question IDs of 1-64 letters, digits, underscores or dashes")
+				break
+			}
+		}
+	}
+	if !validResponseSort(view.Sort) {
+		errors = append(errors, "Sort must be either updated_at or created_at")
+	}
+	if view.Order != "asc" && view.Order != "desc" {
+		errors = append(errors, "Order must be either asc or desc")
+	}
+
+	var taken bool
+	err = db.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM response_views WHERE survey_id = ? AND name = ?)", sID, view.Name).Scan(&taken)
+	if err == nil && taken {
+		errors = append(errors, "Name has already been taken")
+	}
+
+	if len(errors) > 0 {
+		abortWithError(c, errValidation("Failed to save view", errors))
+		return
+	}
+
+	columns, _ := json.Marshal(view.Columns)
+	result, err := db.ExecContext(ctx, `
+		INSERT INTO response_views (survey_id, name, filters, columns, sort, sort_order, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+	`, sID, view.Name, string(view.Filters), string(columns), view.Sort, view.Order)
+	if err != nil {
+		abortWithError(c, errInternal("Failed to save view", err))
+		return
+	}
+
+	id, _ := result.LastInsertId()
+	saved, err := findResponseView(ctx, sID, int(id))
+	if err != nil {
+		abortWithError(c, errInternal("Failed to fetch saved view", err))
+		return
+	}
+	auditChange(c, "create", "response_view", saved.ID, nil, saved)
+
+	c.JSON(http.StatusCreated, APIResponse{
+		Status:  "success",
+		Message: "View saved successfully",
+		Data:    saved,
+	})
+}
+
+// deleteResponseView removes a saved view
+func deleteResponseView(c *gin.Context) {
+	ctx := c.Request.Context()
+	sID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		abortWithError(c, errInvalidID("survey"))
+		return
+	}
+
+	vID, err := strconv.Atoi(c.Param("view_id"))
+	if err != nil {
+		abortWithError(c, errInvalidID("view"))
+		return
+	}
+
+	view, err := findResponseView(ctx, sID, vID)
+	if err != nil {
+		abortWithError(c, errNotFound(CodeViewNotFound, "View not found"))
+		return
+	}
+
+	_, err = db.ExecContext(ctx, "DELETE FROM response_views WHERE id = ? AND survey_id = ?", vID, sID)
+	if err != nil {
+		abortWithError(c, errInternal("Failed to delete view", err))
+		return
+	}
+	auditChange(c, "delete", "response_view", vID, view, nil)
+
+	c.JSON(http.StatusOK, APIResponse{
+		Status:  "success",
+		Message: "View deleted successfully",
+	})
+}
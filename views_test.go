@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateAndGetResponseView(t *testing.T) {
+	setupTestDB()
+	defer testDB.Close()
+
+	surveyID, ids := insertTestResponses(t, 2)
+	router := setupTestRouter()
+
+	viewData := map[string]interface{}{
+		"view": map[string]interface{}{
+			"name":    "Oldest first",
+			"filters": map[string]string{"answer[rating]": "5"},
+			"columns": []string{"rating", "comment"},
+			"sort":    "created_at",
+			"order":   "asc",
+		},
+	}
+
+	jsonData, _ := json.Marshal(viewData)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", fmt.Sprintf("/api/surveys/%d/views", surveyID), bytes.NewBuffer(jsonData))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	var created struct {
+		Data ResponseView `json:"data"`
+	}
+	err := json.Unmarshal(w.Body.Bytes(), &created)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"rating", "comment"}, created.Data.Columns)
+	assert.JSONEq(t, `{"answer[rating]":"5"}`, string(created.Data.Filters))
+
+	// The view is retrievable
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", fmt.Sprintf("/api/surveys/%d/views/%d", surveyID, created.Data.ID), nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "Oldest first")
+
+	// Listing responses with the view applies its ordering
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", fmt.Sprintf("/api/surveys/%d/responses?view=%d", surveyID, created.Data.ID), nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var listed struct {
+		Data []TestSurveyResponse `json:"data"`
+	}
+	err = json.Unmarshal(w.Body.Bytes(), &listed)
+	assert.NoError(t, err)
+	assert.Equal(t, ids[0], listed.Data[0].ID)
+
+	// Names are unique per survey
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", fmt.Sprintf("/api/surveys/%d/views", surveyID), bytes.NewBuffer(jsonData))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Contains(t, w.Body.String(), "Name has already been taken")
+}
+
+func TestResponseViewFilters(t *testing.T) {
+	setupTestDB()
+	defer testDB.Close()
+	router := setupTestRouter()
+
+	testDB.Exec(`INSERT INTO surveys (title, description, questions) VALUES ('Support', 'd',
+		'[{"id":"rating","type":"rating","label":"Rate us"},{"id":"comment","type":"text","label":"Why?"}]')`)
+	for i, r := range []struct{ rating, channel string }{{"1", "email"}, {"2", "web"}, {"5", "email"}, {"1", "email"}} {
+		testDB.Exec("INSERT INTO survey_responses (survey_id, user_identifier, response_data, channel) VALUES (1, ?, ?, ?)",
+			fmt.Sprintf("user%d", i), `{"rating": `+r.rating+`}`, r.channel)
+	}
+
+	save := func(view string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/api/surveys/1/views", bytes.NewBufferString(`{"view":`+view+`}`))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		return w
+	}
+	list := func(query string) []int {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/api/surveys/1/responses?"+query, nil)
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var listed struct {
+			Data []TestSurveyResponse `json:"data"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &listed)
+		ids := []int{}
+		for _, r := range listed.Data {
+			ids = append(ids, r.ID)
+		}
+		return ids
+	}
+
+	w := save(`{"name": "Unhappy by email", "filters": {"channel": "email", "answer[rating][lte]": 2}, "columns": ["comment"], "sort": "created_at", "order": "asc"}`)
+	assert.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+	// The view's filters narrow the list; explicit parameters replace them
+	assert.Equal(t, []int{1, 4}, list("view=1"))
+	assert.Equal(t, []int{2}, list("view=1&channel=web"))
+	assert.Len(t, list(""), 4)
+
+	w = save(`{"name": "Broken", "filters": {"rating": "1", "answer[rating][near]": "1", "answer[mood]": "ok", "bot_score_min": 2, "device": ["mobile"]}, "columns": ["comment", "email"]}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	var failed struct {
+		Errors []string `json:"errors"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &failed)
+	assert.Equal(t, []string{
+		"Filters: device must be a string, number or boolean",
+		"Filters: rating is not a response filter; answers are filtered with answer[rating]",
+		"Filters: answer[mood]: question mood doesn't exist",
+		"Columns: question email doesn't exist",
+	}, failed.Errors)
+
+	w = save(`{"name": "Broken", "filters": {"answer[rating][near]": "1", "answer[mood]": "ok", "bot_score_min": 2}}`)
+	json.Unmarshal(w.Body.Bytes(), &failed)
+	assert.Equal(t, []string{
+		"Filters: bot_score_min must be between 0 and 1",
+		"Filters: answer[rating][near]: operator must be one of eq, ne, contains, gt, gte, lt, lte",
+		"Filters: answer[mood]: question mood doesn't exist",
+	}, failed.Errors)
+}
+
+func TestDeleteResponseView(t *testing.T) {
+	setupTestDB()
+	defer testDB.Close()
+
+	surveyID, _ := insertTestResponses(t, 0)
+	result, err := testDB.Exec("INSERT INTO response_views (survey_id, name) VALUES (?, ?)", surveyID, "Default")
+	assert.NoError(t, err)
+	viewID, _ := result.LastInsertId()
+
+	router := setupTestRouter()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("DELETE", fmt.Sprintf("/api/surveys/%d/views/%d", surveyID, viewID), nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", fmt.Sprintf("/api/surveys/%d/views/%d", surveyID, viewID), nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
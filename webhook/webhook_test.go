@@ -0,0 +1,48 @@
+package webhook
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerify(t *testing.T) {
+	secret := []byte("whsec_test")
+	body := []byte(`{"event":"response.created"}`)
+	sentAt := time.Unix(1700000000, 0)
+	signature := Sign(secret, sentAt, body)
+	timestamp := "1700000000"
+
+	assert.NoError(t, Verify(secret, signature, timestamp, body, sentAt.Add(time.Minute), DefaultTolerance))
+
+	// Any of several comma-separated signatures may match
+	assert.NoError(t, Verify(secret, "v1=deadbeef, "+signature, timestamp, body, sentAt, DefaultTolerance))
+
+	assert.Equal(t, ErrInvalidSignature, Verify([]byte("other"), signature, timestamp, body, sentAt, DefaultTolerance))
+	assert.Equal(t, ErrInvalidSignature, Verify(secret, signature, timestamp, []byte(`{}`), sentAt, DefaultTolerance))
+	assert.Equal(t, ErrExpired, Verify(secret, signature, timestamp, body, sentAt.Add(10*time.Minute), DefaultTolerance))
+	assert.Equal(t, ErrInvalidTimestamp, Verify(secret, signature, "yesterday", body, sentAt, DefaultTolerance))
+	assert.Equal(t, ErrMissingSignature, Verify(secret, "", timestamp, body, sentAt, DefaultTolerance))
+}
+
+func TestVerifyRequest(t *testing.T) {
+	secret := []byte("whsec_test")
+	body := []byte(`{"event":"response.created"}`)
+
+	req, _ := http.NewRequest("POST", "http://example.com/hooks", bytes.NewReader(body))
+	SignRequest(req, secret, body, time.Now())
+
+	got, err := VerifyRequest(req, secret, DefaultTolerance)
+	assert.NoError(t, err)
+	assert.Equal(t, body, got)
+
+	// A replayed delivery with an old timestamp is rejected
+	req, _ = http.NewRequest("POST", "http://example.com/hooks", bytes.NewReader(body))
+	SignRequest(req, secret, body, time.Now().Add(-time.Hour))
+
+	_, err = VerifyRequest(req, secret, DefaultTolerance)
+	assert.Equal(t, ErrExpired, err)
+}
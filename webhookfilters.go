@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// webhookFilterParams lists the response filters a subscription may set, named
+// as in the response listing; answer[question][operator] filters are also allowed
+var webhookFilterParams = append(append([]string{}, metadataFilterColumns...),
+	"bot_score_min", "bot_score_max", "quality_flag", "exclude_quality")
+
+// webhookSample draws the number compared with a subscription's sample rate
+var webhookSample = rand.Float64
+
+// isResponseEvent reports whether event is about a single response, the only
+// events subject to a subscription's sampling and filter
+func isResponseEvent(event string) bool {
+	return strings.HasPrefix(event, "response.")
+}
+
+// parseWebhookFilter validates a subscription's filter, returning the response
+// query it stands for
+func parseWebhookFilter(filter map[string]string) (responseListQuery, []string) {
+	var q responseListQuery
+	var errors []string
+
+	params := url.Values{}
+	keys := make([]string, 0, len(filter))
+	for key := range filter {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if !containsString(webhookFilterParams, key) && !answerFilterParam.MatchString(key) {
+			errors = append(errors, fmt.Sprintf("Unknown filter %q, expected one of %s or answer[question][operator]", key, strings.Join(webhookFilterParams, ", ")))
+			continue
+		}
+		params.Set(key, filter[key])
+	}
+
+	errors = append(errors, parseMetadataFilters(params, &q)...)
+	errors = append(errors, readAnswerFilters(params, &q)...)
+	return q, errors
+}
+
+// validateWebhookSampling checks the sample rate and filter of a subscription
+func validateWebhookSampling(sampleRate *float64, filter map[string]string) []string {
+	var errors []string
+	if r := sampleRate; r != nil && (*r <= 0 || *r > 1) {
+		errors = append(errors, "Sample rate must be greater than 0 and at most 1")
+	}
+	_, filterErrors := parseWebhookFilter(filter)
+	return append(errors, filterErrors...)
+}
+
+// samples reports whether a response event is delivered to the subscription:
+// it must be drawn by the sample rate, then match the filter. Other events are
+// always delivered. Answer filters are matched on the event's response, so
+// they keep working when response data is encrypted or compressed.
+func (sub WebhookSubscription) samples(ctx context.Context, event string, surveyID int, data interface{}) (bool, error) {
+	if !isResponseEvent(event) {
+		return true, nil
+	}
+	if sub.SampleRate != nil && webhookSample() >= *sub.SampleRate {
+		return false, nil
+	}
+	if len(sub.Filter) == 0 {
+		return true, nil
+	}
+	response, ok := data.(SurveyResponse)
+	if !ok {
+		return false, fmt.Errorf("%s carries %T, not a response", event, data)
+	}
+
+	q, errors := parseWebhookFilter(sub.Filter)
+	if len(errors) > 0 {
+		return false, fmt.Errorf("invalid filter: %s", strings.Join(errors, "; "))
+	}
+	for _, filter := range q.Answers {
+		if !filter.matches(response.ResponseData) {
+			return false, nil
+		}
+	}
+	q.Answers = nil
+	q.SurveyID = surveyID
+	// A deleted response matches on what it was
+	q.IncludeDeleted = event == EventResponseDeleted
+	where, args := q.where()
+
+	var matches bool
+	err := db.QueryRowContext(ctx, `
+		SELECT EXISTS(SELECT 1 FROM survey_responses sr WHERE `+where+` AND sr.id = ?)
+	`, append(args, response.ID)...).Scan(&matches)
+	return matches, err
+}
@@ -0,0 +1,436 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"survey_form_go/webhook"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Webhook events
+const (
+	EventSurveyCreated      = "survey.created"
+	EventSurveyUpdated      = "survey.updated"
+	EventSurveyPublished    = "survey.published"
+	EventSurveyClosed       = "survey.closed"
+	EventSurveyQuotaReached = "survey.quota_reached"
+	EventResponseCreated    = "response.created"
+	EventResponseUpdated    = "response.updated"
+	EventResponseDeleted    = "response.deleted"
+)
+
+// webhookEvents lists every event a subscription may filter on
+var webhookEvents = []string{
+	EventSurveyCreated, EventSurveyUpdated, EventSurveyPublished, EventSurveyClosed, EventSurveyQuotaReached,
+	EventResponseCreated, EventResponseUpdated, EventResponseDeleted,
+}
+
+// EventHeader names the event of a delivery
+const EventHeader = "X-Survey-Event"
+
+// WebhookSubscription sends events to a URL, signed with its secret
+type WebhookSubscription struct {
+	ID  int    `json:"id"`
+	URL string `json:"url"`
+	// Secret is only returned when the subscription is created
+	Secret string `json:"secret,omitempty"`
+	// Events filters the deliveries; empty means every event
+	Events []string `json:"events"`
+	// SurveyID limits the subscription to one survey; nil means every survey
+	SurveyID *int `json:"survey_id,omitempty"`
+	// SampleRate delivers this fraction of the response events; nil means all
+	SampleRate *float64 `json:"sample_rate,omitempty"`
+	// Filter limits response events to the responses matching it, with the
+	// filters of the response listing
+	Filter map[string]string `json:"filter"`
+	// Template reshapes deliveries for receivers expecting another payload: a
+	// text/template rendering the event's JSON into the JSON body sent. Empty
+	// sends the event as is.
+	Template string `json:"template"`
+	// RESTHook subscriptions were made through the REST Hooks endpoints; their
+	// deliveries are the event's data alone, and a 410 Gone unsubscribes them
+	RESTHook  bool      `json:"rest_hook"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// CreateWebhookRequest represents the request body for subscribing to webhooks
+type CreateWebhookRequest struct {
+	Webhook struct {
+		URL        string            `json:"url" binding:"required"`
+		Events     []string          `json:"events"`
+		SurveyID   *int              `json:"survey_id"`
+		SampleRate *float64          `json:"sample_rate"`
+		Filter     map[string]string `json:"filter"`
+		Template   string            `json:"template"`
+	} `json:"webhook" binding:"required"`
+}
+
+// WebhookEvent is the body of a delivery
+type WebhookEvent struct {
+	ID        string      `json:"id"`
+	Event     string      `json:"event"`
+	SurveyID  int         `json:"survey_id"`
+	CreatedAt time.Time   `json:"created_at"`
+	Data      interface{} `json:"data"`
+}
+
+// Delivery settings; failed deliveries are retried by the job queue with
+// exponential backoff
+var (
+	webhookClient      = &http.Client{Timeout: 10 * time.Second}
+	webhookMaxAttempts = 3
+)
+
+// errWebhookGone is returned for deliveries answered 410 Gone
+var errWebhookGone = errors.New("target is gone")
+
+// WebhookDelivery is the payload of a webhook delivery job
+type WebhookDelivery struct {
+	SubscriptionID int             `json:"subscription_id"`
+	Event          string          `json:"event"`
+	Body           json.RawMessage `json:"body"`
+}
+
+// webhookColumns lists the columns read by scanWebhook
+const webhookColumns = "id, url, secret, events, survey_id, sample_rate, response_filter, payload_template, rest_hook, created_at"
+
+// scanWebhook scans a row selected with webhookColumns
+func scanWebhook(row rowScanner) (WebhookSubscription, error) {
+	var sub WebhookSubscription
+	var events, filter []byte
+	var surveyID sql.NullInt64
+	var sampleRate sql.NullFloat64
+	err := row.Scan(&sub.ID, &sub.URL, &sub.Secret, &events, &surveyID, &sampleRate, &filter, &sub.Template, &sub.RESTHook, &sub.CreatedAt)
+	if err == nil {
+		err = json.Unmarshal(events, &sub.Events)
+	}
+	if err == nil {
+		err = json.Unmarshal(filter, &sub.Filter)
+	}
+	sub.SurveyID = nullIntPtr(surveyID)
+	if sampleRate.Valid {
+		sub.SampleRate = &sampleRate.Float64
+	}
+	return sub, err
+}
+
+// wants reports whether the subscription receives event
+func (sub WebhookSubscription) wants(event string) bool {
+	if len(sub.Events) == 0 {
+		return true
+	}
+	for _, e := range sub.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// randomHex returns n random bytes, hex encoded
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// emitEvent queues a delivery of event to every matching subscription. It
+// doesn't take the request's context, so the events of writes that succeeded are
+// sent even when the client has gone away. Response events are sampled and
+// filtered per subscription first. The survey's owner is notified as well,
+// per their notification preferences, and live results watchers are updated.
+func emitEvent(event string, surveyID int, data interface{}) {
+	queueNotification(event, surveyID)
+	switch event {
+	case EventResponseCreated, EventResponseUpdated, EventResponseDeleted:
+		liveResults.notify(surveyID)
+	}
+
+	rows, err := db.Query("SELECT "+webhookColumns+" FROM webhook_subscriptions WHERE survey_id IS NULL OR survey_id = ?", surveyID)
+	if err != nil {
+		log.Printf("Webhooks: failed to load subscriptions for %s: %v", event, err)
+		return
+	}
+	var subs []WebhookSubscription
+	for rows.Next() {
+		sub, err := scanWebhook(rows)
+		if err != nil {
+			log.Printf("Webhooks: failed to load subscriptions for %s: %v", event, err)
+			continue
+		}
+		if sub.wants(event) {
+			subs = append(subs, sub)
+		}
+	}
+	rows.Close()
+
+	sampled := subs[:0]
+	for _, sub := range subs {
+		ok, err := sub.samples(context.Background(), event, surveyID, data)
+		if err != nil {
+			log.Printf("Webhooks: failed to filter %s for subscription %d: %v", event, sub.ID, err)
+			continue
+		}
+		if ok {
+			sampled = append(sampled, sub)
+		}
+	}
+	subs = sampled
+	if len(subs) == 0 {
+		return
+	}
+
+	body, err := json.Marshal(WebhookEvent{
+		ID:        randomHex(16),
+		Event:     event,
+		SurveyID:  surveyID,
+		CreatedAt: time.Now().UTC(),
+		Data:      data,
+	})
+	if err != nil {
+		log.Printf("Webhooks: failed to encode %s: %v", event, err)
+		return
+	}
+
+	var dataBody []byte
+	for _, sub := range subs {
+		payload := body
+		switch {
+		case sub.RESTHook:
+			if dataBody == nil {
+				dataBody, _ = json.Marshal(data)
+			}
+			payload = dataBody
+		case sub.Template != "":
+			if payload, err = renderWebhookPayload(sub.Template, body); err != nil {
+				log.Printf("Webhooks: failed to render %s for subscription %d: %v", event, sub.ID, err)
+				continue
+			}
+		}
+		delivery := WebhookDelivery{SubscriptionID: sub.ID, Event: event, Body: payload}
+		if _, err := enqueueJob(context.Background(), JobKindWebhook, delivery, webhookMaxAttempts); err != nil {
+			log.Printf("Webhooks: failed to queue %s for subscription %d: %v", event, sub.ID, err)
+		}
+	}
+}
+
+// emitEventOnce emits a lifecycle event the first time it happens to a survey,
+// so events detected repeatedly, such as a quota being reached, are sent once
+func emitEventOnce(event string, surveyID int, data interface{}) {
+	result, err := db.Exec("INSERT OR IGNORE INTO survey_lifecycle_events (survey_id, event) VALUES (?, ?)", surveyID, event)
+	if err != nil {
+		log.Printf("Webhooks: failed to record %s for survey %d: %v", event, surveyID, err)
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 1 {
+		emitEvent(event, surveyID, data)
+	}
+}
+
+// deliverWebhook runs a delivery job. Deliveries to subscriptions deleted
+// since are dropped, and REST hooks whose target is gone are unsubscribed.
+func deliverWebhook(ctx context.Context, payload json.RawMessage) error {
+	var delivery WebhookDelivery
+	if err := json.Unmarshal(payload, &delivery); err != nil {
+		return err
+	}
+	sub, err := scanWebhook(db.QueryRowContext(ctx, "SELECT "+webhookColumns+" FROM webhook_subscriptions WHERE id = ?", delivery.SubscriptionID))
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	err = postWebhook(sub, delivery.Event, delivery.Body)
+	if err == errWebhookGone && sub.RESTHook {
+		log.Printf("Webhooks: unsubscribing REST hook %d, its target is gone", sub.ID)
+		_, err = db.ExecContext(ctx, "DELETE FROM webhook_subscriptions WHERE id = ?", sub.ID)
+	}
+	return err
+}
+
+// postWebhook sends one signed delivery attempt
+func postWebhook(sub WebhookSubscription, event string, body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, sub.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, event)
+	webhook.SignRequest(req, []byte(sub.Secret), body, time.Now())
+
+	resp, err := webhookClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusGone {
+		return errWebhookGone
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// validEvent reports whether event is a known webhook event
+func validEvent(event string) bool {
+	for _, e := range webhookEvents {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// validateWebhook returns the problems of a subscription about to be created
+func validateWebhook(ctx context.Context, sub WebhookSubscription) []string {
+	var errors []string
+	if u, err := url.Parse(sub.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		errors = append(errors, "URL must be an absolute http or https URL")
+	}
+	for _, event := range sub.Events {
+		if !validEvent(event) {
+			errors = append(errors, fmt.Sprintf("Unknown event %q, expected one of %s", event, strings.Join(webhookEvents, ", ")))
+		}
+	}
+	if id := sub.SurveyID; id != nil {
+		var exists bool
+		err := db.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM surveys WHERE id = ?)", *id).Scan(&exists)
+		if err != nil || !exists {
+			errors = append(errors, "Survey not found")
+		}
+	}
+	errors = append(errors, validateWebhookTemplate(sub.Template)...)
+	return append(errors, validateWebhookSampling(sub.SampleRate, sub.Filter)...)
+}
+
+// insertWebhook stores a validated subscription with a new secret, returning it as stored
+func insertWebhook(ctx context.Context, sub WebhookSubscription) (WebhookSubscription, error) {
+	if sub.Events == nil {
+		sub.Events = []string{}
+	}
+	if sub.Filter == nil {
+		sub.Filter = map[string]string{}
+	}
+	eventsJSON, _ := json.Marshal(sub.Events)
+	filterJSON, _ := json.Marshal(sub.Filter)
+	result, err := db.ExecContext(ctx, `
+		INSERT INTO webhook_subscriptions (url, secret, events, survey_id, sample_rate, response_filter, payload_template, rest_hook, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
+	`, sub.URL, randomHex(32), string(eventsJSON), sub.SurveyID, sub.SampleRate, string(filterJSON), sub.Template, sub.RESTHook)
+	if err != nil {
+		return sub, err
+	}
+	id, _ := result.LastInsertId()
+	return scanWebhook(db.QueryRowContext(ctx, "SELECT "+webhookColumns+" FROM webhook_subscriptions WHERE id = ?", id))
+}
+
+// createWebhook subscribes a URL to webhook events
+func createWebhook(c *gin.Context) {
+	ctx := c.Request.Context()
+	var req CreateWebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		abortWithError(c, errInvalidRequest(err))
+		return
+	}
+
+	sub := WebhookSubscription{
+		URL:        req.Webhook.URL,
+		Events:     req.Webhook.Events,
+		SurveyID:   req.Webhook.SurveyID,
+		SampleRate: req.Webhook.SampleRate,
+		Filter:     req.Webhook.Filter,
+		Template:   req.Webhook.Template,
+	}
+	if errors := validateWebhook(ctx, sub); len(errors) > 0 {
+		abortWithError(c, errValidation("Failed to create webhook", errors))
+		return
+	}
+	sub, err := insertWebhook(ctx, sub)
+	if err != nil {
+		abortWithError(c, errInternal("Failed to create webhook", err))
+		return
+	}
+
+	// The secret is shown once and kept out of the audit log
+	snapshot := sub
+	snapshot.Secret = ""
+	auditChange(c, "create", "webhook_subscription", sub.ID, nil, snapshot)
+
+	c.JSON(http.StatusCreated, APIResponse{
+		Status:  "success",
+		Message: "Webhook created successfully",
+		Data:    sub,
+	})
+}
+
+// getWebhooks lists the webhook subscriptions, without their secrets
+func getWebhooks(c *gin.Context) {
+	ctx := c.Request.Context()
+	rows, err := db.QueryContext(ctx, "SELECT "+webhookColumns+" FROM webhook_subscriptions ORDER BY id")
+	if err != nil {
+		abortWithError(c, errInternal("Failed to fetch webhooks", err))
+		return
+	}
+	defer rows.Close()
+
+	subs := []WebhookSubscription{}
+	for rows.Next() {
+		sub, err := scanWebhook(rows)
+		if err != nil {
+			abortWithError(c, errInternal("Failed to scan webhook data", err))
+			return
+		}
+		sub.Secret = ""
+		subs = append(subs, sub)
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Status: "success",
+		Data:   subs,
+	})
+}
+
+// deleteWebhook removes a webhook subscription
+func deleteWebhook(c *gin.Context) {
+	ctx := c.Request.Context()
+	id, err := strconv.Atoi(c.Param("webhook_id"))
+	if err != nil {
+		abortWithError(c, errInvalidID("webhook"))
+		return
+	}
+
+	sub, err := scanWebhook(db.QueryRowContext(ctx, "SELECT "+webhookColumns+" FROM webhook_subscriptions WHERE id = ?", id))
+	if err != nil {
+		abortWithError(c, errNotFound(CodeWebhookNotFound, "Webhook not found"))
+		return
+	}
+
+	if _, err := db.ExecContext(ctx, "DELETE FROM webhook_subscriptions WHERE id = ?", id); err != nil {
+		abortWithError(c, errInternal("Failed to delete webhook", err))
+		return
+	}
+	sub.Secret = ""
+	auditChange(c, "delete", "webhook_subscription", id, sub, nil)
+
+	c.JSON(http.StatusOK, APIResponse{
+		Status:  "success",
+		Message: "Webhook deleted successfully",
+	})
+}
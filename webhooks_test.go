@@ -0,0 +1,345 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"survey_form_go/webhook"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// webhookReceiver records the deliveries it receives
+type webhookReceiver struct {
+	mu       sync.Mutex
+	events   []WebhookEvent
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func (wr *webhookReceiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	var event WebhookEvent
+	json.Unmarshal(body, &event)
+
+	wr.mu.Lock()
+	defer wr.mu.Unlock()
+	wr.events = append(wr.events, event)
+	wr.requests = append(wr.requests, r)
+	wr.bodies = append(wr.bodies, body)
+}
+
+// names returns the received event names, sorted by arrival
+func (wr *webhookReceiver) names() []string {
+	wr.mu.Lock()
+	defer wr.mu.Unlock()
+	var names []string
+	for _, e := range wr.events {
+		names = append(names, e.Event)
+	}
+	return names
+}
+
+// subscribeWebhook creates a subscription through the admin API and returns it with its secret
+func subscribeWebhook(t *testing.T, router http.Handler, body string) WebhookSubscription {
+	w := httptest.NewRecorder()
+	req := adminRequest("POST", "/api/admin/webhooks", []byte(body))
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+	var response struct {
+		Data WebhookSubscription `json:"data"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NotEmpty(t, response.Data.Secret)
+	return response.Data
+}
+
+func TestSurveyLifecycleWebhooks(t *testing.T) {
+	setupTestDB()
+	defer testDB.Close()
+
+	router := setupTestRouter()
+
+	all := &webhookReceiver{}
+	allServer := httptest.NewServer(all)
+	defer allServer.Close()
+	quota := &webhookReceiver{}
+	quotaServer := httptest.NewServer(quota)
+	defer quotaServer.Close()
+
+	allSub := subscribeWebhook(t, router, fmt.Sprintf(`{"webhook":{"url":"%s"}}`, allServer.URL))
+	subscribeWebhook(t, router, fmt.Sprintf(`{"webhook":{"url":"%s","events":["survey.quota_reached"]}}`, quotaServer.URL))
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/surveys", bytes.NewBufferString(`{"survey":{"title":"Webhook Survey","description":"Fills up","max_responses":1}}`))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var created struct {
+		Data Survey `json:"data"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &created)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", fmt.Sprintf("/api/surveys/%d/responses", created.Data.ID),
+		bytes.NewBufferString(`{"survey_response":{"user_identifier":"user1","response_data":{"rating":"5"}}}`))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	runDueJobs(context.Background(), time.Now())
+
+	assert.ElementsMatch(t, []string{EventSurveyCreated, EventSurveyPublished, EventResponseCreated, EventSurveyQuotaReached}, all.names())
+	assert.Equal(t, []string{EventSurveyQuotaReached}, quota.names())
+
+	// Deliveries are signed with the subscription's secret
+	all.mu.Lock()
+	r, body := all.requests[0], all.bodies[0]
+	all.mu.Unlock()
+	assert.NoError(t, webhook.Verify([]byte(allSub.Secret), r.Header.Get(webhook.SignatureHeader), r.Header.Get(webhook.TimestampHeader), body, time.Now(), webhook.DefaultTolerance))
+	assert.NotEmpty(t, r.Header.Get(EventHeader))
+
+	quota.mu.Lock()
+	assert.Equal(t, created.Data.ID, quota.events[0].SurveyID)
+	quota.mu.Unlock()
+
+	// Secrets are not listed
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, adminRequest("GET", "/api/admin/webhooks", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), allSub.Secret)
+}
+
+func TestCloseDueSurveys(t *testing.T) {
+	setupTestDB()
+	defer testDB.Close()
+
+	db = testDB
+	receiver := &webhookReceiver{}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+	_, err := testDB.Exec("INSERT INTO webhook_subscriptions (url, secret, events) VALUES (?, 'secret', '[\"survey.closed\"]')", server.URL)
+	assert.NoError(t, err)
+
+	past := time.Now().Add(-time.Hour).UTC()
+	future := time.Now().Add(time.Hour).UTC()
+	_, err = testDB.Exec("INSERT INTO surveys (title, description, closes_at) VALUES ('Closed', 'd', ?), ('Open', 'd', ?)", past, future)
+	assert.NoError(t, err)
+
+	n, err := closeDueSurveys(context.Background(), time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	// Each survey is only reported closed once
+	n, err = closeDueSurveys(context.Background(), time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, 0, n)
+
+	runDueJobs(context.Background(), time.Now())
+	assert.Equal(t, []string{EventSurveyClosed}, receiver.names())
+}
+
+func TestCreateWebhookValidation(t *testing.T) {
+	setupTestDB()
+	defer testDB.Close()
+
+	router := setupTestRouter()
+
+	for _, body := range []string{
+		`{"webhook":{"url":"not a url"}}`,
+		`{"webhook":{"url":"ftp://example.com/hook"}}`,
+		`{"webhook":{"url":"https://example.com/hook","events":["survey.exploded"]}}`,
+		`{"webhook":{"url":"https://example.com/hook","survey_id":999}}`,
+		`{"webhook":{"url":"https://example.com/hook","sample_rate":0}}`,
+		`{"webhook":{"url":"https://example.com/hook","sample_rate":1.5}}`,
+		`{"webhook":{"url":"https://example.com/hook","filter":{"user_identifier":"alice"}}}`,
+		`{"webhook":{"url":"https://example.com/hook","filter":{"answer[rating][gte]":"high"}}}`,
+		`{"webhook":{"url":"https://example.com/hook","template":"{{.event"}}`,
+		`{"webhook":{"url":"https://example.com/hook","template":"rating={{.data.response_data.rating}}"}}`,
+		`{"webhook":{"url":"https://example.com/hook","template":"{{json (flatten .event)}}"}}`,
+	} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, adminRequest("POST", "/api/admin/webhooks", []byte(body)))
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code, body)
+	}
+
+	// Subscriptions are managed by admins only
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/admin/webhooks", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
+
+func TestWebhookRetries(t *testing.T) {
+	setupTestDB()
+	defer testDB.Close()
+	setupTestRouter()
+
+	var mu sync.Mutex
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		calls++
+		if calls < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+	testDB.Exec("INSERT INTO webhook_subscriptions (url, secret) VALUES (?, 'secret')", server.URL)
+
+	// Failed deliveries are retried with exponential backoff
+	emitEvent(EventSurveyCreated, 1, Survey{ID: 1})
+	now := time.Now()
+	for _, after := range []time.Duration{0, time.Second, jobRetryBackoff, 3 * jobRetryBackoff} {
+		runDueJobs(context.Background(), now.Add(after))
+	}
+	assert.Equal(t, 3, calls)
+	jobs, err := jobQueue.List(context.Background(), "", "", 10)
+	assert.NoError(t, err)
+	assert.Empty(t, jobs)
+}
+
+func TestWebhookSamplingAndFilters(t *testing.T) {
+	setupTestDB()
+	defer testDB.Close()
+	router := setupTestRouter()
+	defer func(sample func() float64) { webhookSample = sample }(webhookSample)
+
+	testDB.Exec("INSERT INTO surveys (title, description) VALUES ('Busy', 'd')")
+	testDB.Exec(`INSERT INTO survey_responses (survey_id, user_identifier, response_data, channel) VALUES
+		(1, 'a', '{"rating": 5}', 'email'), (1, 'b', '{"rating": 5}', 'web'), (1, 'c', '{"rating": 2}', 'email')`)
+
+	filtered, sampled := &webhookReceiver{}, &webhookReceiver{}
+	filteredServer, sampledServer := httptest.NewServer(filtered), httptest.NewServer(sampled)
+	defer filteredServer.Close()
+	defer sampledServer.Close()
+	sub := subscribeWebhook(t, router, fmt.Sprintf(`{"webhook":{"url":"%s","filter":{"channel":"email","answer[rating][gte]":"4"}}}`, filteredServer.URL))
+	assert.Equal(t, map[string]string{"channel": "email", "answer[rating][gte]": "4"}, sub.Filter)
+	sub = subscribeWebhook(t, router, fmt.Sprintf(`{"webhook":{"url":"%s","sample_rate":0.25}}`, sampledServer.URL))
+	assert.Equal(t, 0.25, *sub.SampleRate)
+
+	// Draws below the rate are delivered
+	draws := []float64{0.1, 0.6, 0.2}
+	webhookSample = func() float64 {
+		draw := draws[0]
+		draws = draws[1:]
+		return draw
+	}
+	for id := 1; id <= 3; id++ {
+		response, err := queryResponse(context.Background(), testDB, id)
+		assert.NoError(t, err)
+		emitEvent(EventResponseCreated, 1, response)
+	}
+	runDueJobs(context.Background(), time.Now())
+	ids := func(wr *webhookReceiver) []float64 {
+		wr.mu.Lock()
+		defer wr.mu.Unlock()
+		var ids []float64
+		for _, e := range wr.events {
+			ids = append(ids, e.Data.(map[string]interface{})["id"].(float64))
+		}
+		return ids
+	}
+	assert.Equal(t, []float64{1}, ids(filtered))
+	assert.ElementsMatch(t, []float64{1, 3}, ids(sampled))
+
+	// Deleted responses match on what they were, and survey events skip both
+	webhookSample = func() float64 { return 0.99 }
+	testDB.Exec("UPDATE survey_responses SET deleted_at = CURRENT_TIMESTAMP WHERE id = 1")
+	response, _ := queryResponse(context.Background(), testDB, 1)
+	emitEvent(EventResponseDeleted, 1, response)
+	runDueJobs(context.Background(), time.Now())
+	emitEvent(EventSurveyUpdated, 1, Survey{ID: 1})
+	runDueJobs(context.Background(), time.Now())
+	assert.Equal(t, []string{EventResponseCreated, EventResponseDeleted, EventSurveyUpdated}, filtered.names())
+	assert.Equal(t, []string{EventResponseCreated, EventResponseCreated, EventSurveyUpdated}, sampled.names())
+}
+
+func TestWebhookFiltersOnEncryptedResponses(t *testing.T) {
+	setupTestDB()
+	defer testDB.Close()
+	router := setupTestRouter()
+
+	defer func() { responseDataCipher = nil }()
+	var err error
+	responseDataCipher, err = newResponseDataCipher(bytes.Repeat([]byte{7}, 32))
+	assert.NoError(t, err)
+
+	testDB.Exec("INSERT INTO surveys (title, description) VALUES ('Private', 'd')")
+	for _, data := range []string{`{"rating": 5}`, `{"rating": 2}`} {
+		testDB.Exec("INSERT INTO survey_responses (survey_id, user_identifier, response_data) VALUES (1, 'a', ?)",
+			encryptResponseData([]byte(data)))
+	}
+
+	receiver := &webhookReceiver{}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+	subscribeWebhook(t, router, fmt.Sprintf(`{"webhook":{"url":"%s","filter":{"answer[rating][gte]":"4"}}}`, server.URL))
+
+	for id := 1; id <= 2; id++ {
+		response, err := queryResponse(context.Background(), testDB, id)
+		assert.NoError(t, err)
+		emitEvent(EventResponseCreated, 1, response)
+	}
+	runDueJobs(context.Background(), time.Now())
+
+	receiver.mu.Lock()
+	defer receiver.mu.Unlock()
+	if assert.Len(t, receiver.events, 1) {
+		assert.Equal(t, float64(1), receiver.events[0].Data.(map[string]interface{})["id"])
+	}
+}
+
+func TestWebhookPayloadTemplates(t *testing.T) {
+	setupTestDB()
+	defer testDB.Close()
+	router := setupTestRouter()
+
+	testDB.Exec("INSERT INTO surveys (title, description) VALUES ('Busy', 'd')")
+	testDB.Exec(`INSERT INTO survey_responses (survey_id, user_identifier, response_data) VALUES
+		(1, 'alice', '{"rating": 5, "address": {"city": "Oslo"}, "topics": ["speed"]}')`)
+
+	receiver := &webhookReceiver{}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+	template := `{"kind": {{json .event}}, "user": {{json .data.user_identifier}}, "fields": {{json (flatten .data.response_data)}}}`
+	body, _ := json.Marshal(map[string]interface{}{"webhook": map[string]interface{}{"url": server.URL, "template": template}})
+	sub := subscribeWebhook(t, router, string(body))
+	assert.Equal(t, template, sub.Template)
+
+	// Deliveries are reshaped and still signed
+	response, err := queryResponse(context.Background(), testDB, 1)
+	assert.NoError(t, err)
+	emitEvent(EventResponseCreated, 1, response)
+	// Survey events have no response_data to flatten
+	emitEvent(EventSurveyUpdated, 1, Survey{ID: 1})
+	runDueJobs(context.Background(), time.Now())
+
+	receiver.mu.Lock()
+	defer receiver.mu.Unlock()
+	if assert.Len(t, receiver.bodies, 2) {
+		assert.JSONEq(t, `{"kind": "response.created", "user": "alice", "fields": {"rating": 5, "address.city": "Oslo", "topics.0": "speed"}}`, string(receiver.bodies[0]))
+		r := receiver.requests[0]
+		assert.NoError(t, webhook.Verify([]byte(sub.Secret), r.Header.Get(webhook.SignatureHeader), r.Header.Get(webhook.TimestampHeader), receiver.bodies[0], time.Now(), webhook.DefaultTolerance))
+		assert.JSONEq(t, `{"kind": "survey.updated", "user": null, "fields": {}}`, string(receiver.bodies[1]))
+	}
+}
+
+func TestFlattenJSON(t *testing.T) {
+	flat, err := flattenJSON(map[string]interface{}{"a": map[string]interface{}{"b": []interface{}{1, map[string]interface{}{"c": true}}}, "d": nil})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"a.b.0": 1, "a.b.1.c": true, "d": nil}, flat)
+
+	_, err = flattenJSON("text")
+	assert.Error(t, err)
+}
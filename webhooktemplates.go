@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"text/template"
+	"time"
+)
+
+// maxWebhookTemplateBytes bounds the size of a subscription's payload template
+const maxWebhookTemplateBytes = 8 << 10
+
+// webhookTemplateFuncs are the functions payload templates may call besides
+// the text/template builtins
+var webhookTemplateFuncs = template.FuncMap{
+	"json":    webhookJSON,
+	"flatten": flattenJSON,
+}
+
+// webhookJSON encodes a value picked from the event as JSON, so templates
+// produce valid JSON whatever the answer's type; missing values are null
+func webhookJSON(v interface{}) (string, error) {
+	raw, err := json.Marshal(v)
+	return string(raw), err
+}
+
+// flattenJSON turns a nested object, such as response_data, into one object
+// whose keys are the paths of its values joined by dots, e.g. address.city or
+// tags.0. Nothing flattens to an empty object.
+func flattenJSON(v interface{}) (map[string]interface{}, error) {
+	flat := map[string]interface{}{}
+	var walk func(prefix string, v interface{})
+	walk = func(prefix string, v interface{}) {
+		join := func(key string) string {
+			if prefix == "" {
+				return key
+			}
+			return prefix + "." + key
+		}
+		switch v := v.(type) {
+		case map[string]interface{}:
+			for key, item := range v {
+				walk(join(key), item)
+			}
+		case []interface{}:
+			for i, item := range v {
+				walk(join(strconv.Itoa(i)), item)
+			}
+		default:
+			flat[prefix] = v
+		}
+	}
+	switch v.(type) {
+	case nil:
+		return flat, nil
+	case map[string]interface{}:
+		walk("", v)
+		return flat, nil
+	}
+	return nil, fmt.Errorf("flatten expects an object, not %T", v)
+}
+
+// parseWebhookTemplate parses a subscription's payload template
+func parseWebhookTemplate(text string) (*template.Template, error) {
+	return template.New("payload").Funcs(webhookTemplateFuncs).Option("missingkey=zero").Parse(text)
+}
+
+// renderWebhookPayload renders a payload template over the JSON of an event,
+// whose fields are named as in the default delivery ({{.event}},
+// {{.data.response_data}}). The result must be JSON.
+func renderWebhookPayload(text string, body []byte) ([]byte, error) {
+	tmpl, err := parseWebhookTemplate(text)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var event interface{}
+	if err := dec.Decode(&event); err != nil {
+		return nil, err
+	}
+
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, event); err != nil {
+		return nil, err
+	}
+	if !json.Valid(out.Bytes()) {
+		return nil, fmt.Errorf("payload template rendered invalid JSON: %.100s", out.String())
+	}
+	return out.Bytes(), nil
+}
+
+// validateWebhookTemplate checks a payload template by rendering it over a
+// sample response.created event
+func validateWebhookTemplate(text string) []string {
+	if text == "" {
+		return nil
+	}
+	if len(text) > maxWebhookTemplateBytes {
+		return []string{fmt.Sprintf("Template must be at most %d bytes", maxWebhookTemplateBytes)}
+	}
+	sample, _ := json.Marshal(WebhookEvent{
+		ID:        randomHex(16),
+		Event:     EventResponseCreated,
+		SurveyID:  1,
+		CreatedAt: time.Now().UTC(),
+		Data: SurveyResponse{
+			ID:             1,
+			SurveyID:       1,
+			UserIdentifier: "user1",
+			ResponseData:   json.RawMessage(`{"rating": 5, "comment": "Great", "topics": ["speed", "price"]}`),
+			CreatedAt:      time.Now().UTC(),
+			UpdatedAt:      time.Now().UTC(),
+		},
+	})
+	if _, err := renderWebhookPayload(text, sample); err != nil {
+		return []string{fmt.Sprintf("Template is invalid: %v", err)}
+	}
+	return nil
+}
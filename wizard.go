@@ -0,0 +1,535 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Page groups the questions shown together in the survey form
+type Page struct {
+	ID    string `json:"id"`
+	Title string `json:"title,omitempty"`
+}
+
+// Logic rule actions
+const (
+	LogicShowQuestion = "show_question"
+	LogicHideQuestion = "hide_question"
+	LogicSkipToPage   = "skip_to_page"
+)
+
+// LogicRule applies Action to Target when the answer to Question matches
+// Operator and Value. Operators are those of the answer filters.
+type LogicRule struct {
+	Question string          `json:"question"`
+	Operator string          `json:"operator"`
+	Value    json.RawMessage `json:"value"`
+	Action   string          `json:"action"`
+	// Target is a question ID, or a page ID for skip_to_page
+	Target string `json:"target"`
+}
+
+// SurveyTranslation holds a survey's texts in one language
+type SurveyTranslation struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	// Pages maps page IDs to titles
+	Pages     map[string]string              `json:"pages,omitempty"`
+	Questions map[string]QuestionTranslation `json:"questions,omitempty"`
+}
+
+// QuestionTranslation holds a question's texts in one language; Options are
+// in the order of the question's options
+type QuestionTranslation struct {
+	Label   string   `json:"label"`
+	Options []string `json:"options,omitempty"`
+}
+
+// languagePattern matches language tags such as fr or pt-BR
+var languagePattern = regexp.MustCompile(`^[a-z]{2,3}(-[A-Za-z0-9]{2,8})*$`)
+
+// Blocking issues found by validateSurvey
+const (
+	IssueNoQuestions         = "no_questions"
+	IssueEmptyPage           = "empty_page"
+	IssueUnknownPage         = "unknown_page"
+	IssueQuestionWithoutPage = "question_without_page"
+	IssueInvalidQuestion     = "invalid_question"
+	IssueBrokenLogic         = "broken_logic"
+	IssueMissingTranslation  = "missing_translation"
+)
+
+// SurveyIssue is a problem that keeps a survey from being published
+type SurveyIssue struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// SurveyValidation lists every blocking issue of a survey at once
+type SurveyValidation struct {
+	SurveyID int           `json:"survey_id"`
+	Valid    bool          `json:"valid"`
+	Issues   []SurveyIssue `json:"issues"`
+}
+
+// SavePagesRequest represents the request body for setting a draft's pages
+type SavePagesRequest struct {
+	Pages []Page `json:"pages" binding:"required"`
+}
+
+// AddQuestionRequest represents the request body for adding a question to a draft
+type AddQuestionRequest struct {
+	Question Question `json:"question" binding:"required"`
+}
+
+// SaveLogicRequest represents the request body for setting a draft's logic
+type SaveLogicRequest struct {
+	Logic []LogicRule `json:"logic" binding:"required"`
+}
+
+// SaveTranslationsRequest represents the request body for setting a draft's translations
+type SaveTranslationsRequest struct {
+	Translations map[string]SurveyTranslation `json:"translations" binding:"required"`
+}
+
+// validatePages checks the page definitions of a survey
+func validatePages(pages []Page) []string {
+	var errors []string
+	seen := map[string]bool{}
+	for i, p := range pages {
+		label := fmt.Sprintf("Page %d", i+1)
+		if !questionIDPattern.MatchString(p.ID) {
+			errors = append(errors, label+" ID must be 1-64 letters, digits, underscores or dashes")
+		} else if seen[p.ID] {
+			errors = append(errors, label+" ID "+p.ID+" is already used")
+		}
+		seen[p.ID] = true
+		if len(p.Title) > 255 {
+			errors = append(errors, label+" title must be less than 255 characters")
+		}
+	}
+	return errors
+}
+
+// validateLogic checks the form of logic rules; their references are checked by validateSurvey
+func validateLogic(logic []LogicRule) []string {
+	var errors []string
+	for i, rule := range logic {
+		label := fmt.Sprintf("Rule %d", i+1)
+		if rule.Question == "" {
+			errors = append(errors, label+" question is required")
+		}
+		if !containsString(answerFilterOperators, rule.Operator) {
+			errors = append(errors, label+" operator must be one of "+strings.Join(answerFilterOperators, ", "))
+		}
+		if len(rule.Value) == 0 || isJSONNull(rule.Value) {
+			errors = append(errors, label+" value is required")
+		}
+		switch rule.Action {
+		case LogicShowQuestion, LogicHideQuestion, LogicSkipToPage:
+		default:
+			errors = append(errors, label+" action must be one of show_question, hide_question or skip_to_page")
+		}
+		if rule.Target == "" {
+			errors = append(errors, label+" target is required")
+		}
+	}
+	return errors
+}
+
+// validateTranslations checks the language tags of translations; whether they
+// are complete is checked by validateSurvey
+func validateTranslations(translations map[string]SurveyTranslation) []string {
+	var errors []string
+	for language := range translations {
+		if !languagePattern.MatchString(language) {
+			errors = append(errors, fmt.Sprintf("Language %q must be a tag such as fr or pt-BR", language))
+		}
+	}
+	sort.Strings(errors)
+	return errors
+}
+
+// validateSurvey finds everything that keeps a survey from being published:
+// no questions, pages and logic referring to what doesn't exist, and texts
+// missing from its translations
+func validateSurvey(survey Survey) SurveyValidation {
+	issues := []SurveyIssue{}
+	add := func(code, format string, args ...interface{}) {
+		issues = append(issues, SurveyIssue{Code: code, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if len(survey.Questions) == 0 {
+		add(IssueNoQuestions, "Survey has no questions")
+	}
+	for _, problem := range validateQuestions(survey.Questions) {
+		add(IssueInvalidQuestion, "%s", problem)
+	}
+
+	// Questions are asked page by page, in their order within a page
+	pageIndex := map[string]int{}
+	for i, p := range survey.Pages {
+		pageIndex[p.ID] = i
+	}
+	questions := map[string]Question{}
+	position := map[string][2]int{}
+	onPage := map[string]int{}
+	for i, q := range survey.Questions {
+		questions[q.ID] = q
+		page := 0
+		if q.Page != "" {
+			index, ok := pageIndex[q.Page]
+			if !ok {
+				add(IssueUnknownPage, "Question %s is on page %s, which doesn't exist", q.ID, q.Page)
+			}
+			page = index
+			onPage[q.Page]++
+		} else if len(survey.Pages) > 0 {
+			add(IssueQuestionWithoutPage, "Question %s is not on a page", q.ID)
+		}
+		position[q.ID] = [2]int{page, i}
+	}
+	for _, p := range survey.Pages {
+		if onPage[p.ID] == 0 {
+			add(IssueEmptyPage, "Page %s has no questions", p.ID)
+		}
+	}
+
+	before := func(a, b [2]int) bool {
+		return a[0] < b[0] || (a[0] == b[0] && a[1] < b[1])
+	}
+	for i, rule := range survey.Logic {
+		label := fmt.Sprintf("Rule %d", i+1)
+		source, ok := questions[rule.Question]
+		if !ok {
+			add(IssueBrokenLogic, "%s depends on question %s, which doesn't exist", label, rule.Question)
+			continue
+		}
+		if rule.Operator == "eq" || rule.Operator == "ne" {
+			if source.Type == QuestionMultipleChoice {
+				add(IssueBrokenLogic, "%s compares multiple choice question %s with %s; use contains", label, rule.Question, rule.Operator)
+			} else if problem := answerProblem(source, rule.Value); problem != "" {
+				add(IssueBrokenLogic, "%s value can never be an answer to %s: it %s", label, rule.Question, problem)
+			}
+		}
+
+		switch rule.Action {
+		case LogicSkipToPage:
+			page, ok := pageIndex[rule.Target]
+			if !ok {
+				add(IssueBrokenLogic, "%s skips to page %s, which doesn't exist", label, rule.Target)
+			} else if page <= position[rule.Question][0] {
+				add(IssueBrokenLogic, "%s skips to page %s, which doesn't come after question %s", label, rule.Target, rule.Question)
+			}
+		default:
+			if _, ok := questions[rule.Target]; !ok {
+				add(IssueBrokenLogic, "%s targets question %s, which doesn't exist", label, rule.Target)
+			} else if !before(position[rule.Question], position[rule.Target]) {
+				add(IssueBrokenLogic, "%s targets question %s, which doesn't come after question %s", label, rule.Target, rule.Question)
+			}
+		}
+	}
+
+	languages := make([]string, 0, len(survey.Translations))
+	for language := range survey.Translations {
+		languages = append(languages, language)
+	}
+	sort.Strings(languages)
+	for _, language := range languages {
+		t := survey.Translations[language]
+		if t.Title == "" {
+			add(IssueMissingTranslation, "Title is not translated to %s", language)
+		}
+		if survey.Description != "" && t.Description == "" {
+			add(IssueMissingTranslation, "Description is not translated to %s", language)
+		}
+		for _, p := range survey.Pages {
+			if p.Title != "" && t.Pages[p.ID] == "" {
+				add(IssueMissingTranslation, "Title of page %s is not translated to %s", p.ID, language)
+			}
+		}
+		for _, q := range survey.Questions {
+			qt := t.Questions[q.ID]
+			if qt.Label == "" {
+				add(IssueMissingTranslation, "Label of question %s is not translated to %s", q.ID, language)
+			}
+			if len(q.Options) > 0 && len(qt.Options) != len(q.Options) {
+				add(IssueMissingTranslation, "Options of question %s are not all translated to %s", q.ID, language)
+			}
+		}
+	}
+
+	return SurveyValidation{SurveyID: survey.ID, Valid: len(issues) == 0, Issues: issues}
+}
+
+// findDraft loads the survey of a wizard route, responding with the error when
+// it doesn't exist or is no longer a draft
+func findDraft(c *gin.Context, action string) (Survey, bool) {
+	surveyID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		abortWithError(c, errInvalidID("survey"))
+		return Survey{}, false
+	}
+	survey, err := findSurvey(c.Request.Context(), surveyID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			abortWithError(c, errNotFound(CodeSurveyNotFound, "Survey not found"))
+			return Survey{}, false
+		}
+		abortWithError(c, errInternal("Failed to fetch survey", err))
+		return Survey{}, false
+	}
+	if survey.Status != SurveyStatusDraft {
+		abortWithError(c, errValidation("Failed to "+action, []string{"Only draft surveys can be edited"}))
+		return Survey{}, false
+	}
+	return survey, true
+}
+
+// saveDraft stores the pages, questions, logic and translations of a draft and
+// responds with the updated survey
+func saveDraft(c *gin.Context, before, draft Survey, action string) {
+	ctx := c.Request.Context()
+	pages, _ := json.Marshal(draft.Pages)
+	questions, _ := json.Marshal(draft.Questions)
+	logic, _ := json.Marshal(draft.Logic)
+	translations, _ := json.Marshal(draft.Translations)
+
+	// A survey published meanwhile is left alone
+	result, err := db.ExecContext(ctx, `
+		UPDATE surveys
+		SET pages = ?, questions = ?, logic = ?, translations = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND status = ?
+	`, string(pages), string(questions), string(logic), string(translations), draft.ID, SurveyStatusDraft)
+	if err != nil {
+		abortWithError(c, errInternal("Failed to "+action, err))
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		abortWithError(c, errValidation("Failed to "+action, []string{"Only draft surveys can be edited"}))
+		return
+	}
+
+	survey, err := findSurvey(ctx, draft.ID)
+	if err != nil {
+		abortWithError(c, errInternal("Failed to fetch updated survey", err))
+		return
+	}
+	auditChange(c, "update", "survey", survey.ID, before, survey)
+	emitEvent(EventSurveyUpdated, survey.ID, survey)
+
+	c.JSON(http.StatusOK, APIResponse{
+		Status:  "success",
+		Message: "Survey updated successfully",
+		Data:    survey,
+	})
+}
+
+// withDesignDefaults replaces nil pages, questions, logic and translations with
+// empty ones, so they are stored as JSON values rather than null
+func (s Survey) withDesignDefaults() Survey {
+	if s.Pages == nil {
+		s.Pages = []Page{}
+	}
+	if s.Questions == nil {
+		s.Questions = []Question{}
+	}
+	if s.Logic == nil {
+		s.Logic = []LogicRule{}
+	}
+	if s.Translations == nil {
+		s.Translations = map[string]SurveyTranslation{}
+	}
+	return s
+}
+
+// savePages replaces the pages of a draft survey
+func savePages(c *gin.Context) {
+	survey, ok := findDraft(c, "save pages")
+	if !ok {
+		return
+	}
+	var req SavePagesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		abortWithError(c, errInvalidRequest(err))
+		return
+	}
+	if errors := validatePages(req.Pages); len(errors) > 0 {
+		abortWithError(c, errValidation("Failed to save pages", errors))
+		return
+	}
+
+	draft := survey.withDesignDefaults()
+	draft.Pages = req.Pages
+	saveDraft(c, survey, draft, "save pages")
+}
+
+// addQuestion appends a question to a draft survey
+func addQuestion(c *gin.Context) {
+	survey, ok := findDraft(c, "add question")
+	if !ok {
+		return
+	}
+	var req AddQuestionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		abortWithError(c, errInvalidRequest(err))
+		return
+	}
+
+	draft := survey.withDesignDefaults()
+	draft.Questions = append(append([]Question{}, draft.Questions...), req.Question)
+	if errors := validateQuestions(draft.Questions); len(errors) > 0 {
+		abortWithError(c, errValidation("Failed to add question", errors))
+		return
+	}
+	saveDraft(c, survey, draft, "add question")
+}
+
+// removeQuestion removes a question from a draft survey; logic depending on it
+// is kept and reported by the validation
+func removeQuestion(c *gin.Context) {
+	survey, ok := findDraft(c, "remove question")
+	if !ok {
+		return
+	}
+
+	draft := survey.withDesignDefaults()
+	draft.Questions = []Question{}
+	for _, q := range survey.Questions {
+		if q.ID != c.Param("question_id") {
+			draft.Questions = append(draft.Questions, q)
+		}
+	}
+	if len(draft.Questions) == len(survey.Questions) {
+		abortWithError(c, errNotFound(CodeQuestionNotFound, "Question not found"))
+		return
+	}
+	saveDraft(c, survey, draft, "remove question")
+}
+
+// saveLogic replaces the logic rules of a draft survey
+func saveLogic(c *gin.Context) {
+	survey, ok := findDraft(c, "save logic")
+	if !ok {
+		return
+	}
+	var req SaveLogicRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		abortWithError(c, errInvalidRequest(err))
+		return
+	}
+	if errors := validateLogic(req.Logic); len(errors) > 0 {
+		abortWithError(c, errValidation("Failed to save logic", errors))
+		return
+	}
+
+	draft := survey.withDesignDefaults()
+	draft.Logic = req.Logic
+	saveDraft(c, survey, draft, "save logic")
+}
+
+// saveTranslations replaces the translations of a draft survey
+func saveTranslations(c *gin.Context) {
+	survey, ok := findDraft(c, "save translations")
+	if !ok {
+		return
+	}
+	var req SaveTranslationsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		abortWithError(c, errInvalidRequest(err))
+		return
+	}
+	if errors := validateTranslations(req.Translations); len(errors) > 0 {
+		abortWithError(c, errValidation("Failed to save translations", errors))
+		return
+	}
+
+	draft := survey.withDesignDefaults()
+	draft.Translations = req.Translations
+	saveDraft(c, survey, draft, "save translations")
+}
+
+// getSurveyValidation lists every issue blocking a survey's publication
+func getSurveyValidation(c *gin.Context) {
+	surveyID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		abortWithError(c, errInvalidID("survey"))
+		return
+	}
+	survey, err := findSurvey(c.Request.Context(), surveyID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			abortWithError(c, errNotFound(CodeSurveyNotFound, "Survey not found"))
+			return
+		}
+		abortWithError(c, errInternal("Failed to fetch survey", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Status: "success",
+		Data:   validateSurvey(survey),
+	})
+}
+
+// publishSurvey publishes a draft survey now, unless validation finds blocking issues
+func publishSurvey(c *gin.Context) {
+	ctx := c.Request.Context()
+	survey, ok := findDraft(c, "publish survey")
+	if !ok {
+		return
+	}
+
+	validation := validateSurvey(survey)
+	if !validation.Valid {
+		messages := make([]string, len(validation.Issues))
+		for i, issue := range validation.Issues {
+			messages[i] = issue.Message
+		}
+		abortWithError(c, &APIError{
+			Status:  http.StatusUnprocessableEntity,
+			Code:    CodeSurveyInvalid,
+			Message: "Survey has issues blocking its publication",
+			Errors:  messages,
+			Data:    validation,
+		})
+		return
+	}
+
+	// Publishing now drops a schedule; a survey published meanwhile is left alone
+	result, err := db.ExecContext(ctx, `
+		UPDATE surveys
+		SET status = ?, publish_at = NULL, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND status = ?
+	`, SurveyStatusPublished, survey.ID, SurveyStatusDraft)
+	if err != nil {
+		abortWithError(c, errInternal("Failed to publish survey", err))
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		abortWithError(c, errValidation("Failed to publish survey", []string{"Only draft surveys can be edited"}))
+		return
+	}
+
+	after, err := findSurvey(ctx, survey.ID)
+	if err != nil {
+		abortWithError(c, errInternal("Failed to fetch published survey", err))
+		return
+	}
+	auditChange(c, "publish", "survey", survey.ID, survey, after)
+	emitEventOnce(EventSurveyPublished, survey.ID, after)
+
+	c.JSON(http.StatusOK, APIResponse{
+		Status:  "success",
+		Message: "Survey published successfully",
+		Data:    after,
+	})
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateSurvey(t *testing.T) {
+	codes := func(v SurveyValidation) []string {
+		codes := []string{}
+		for _, issue := range v.Issues {
+			codes = append(codes, issue.Code)
+		}
+		return codes
+	}
+
+	v := validateSurvey(Survey{Title: "Empty"})
+	assert.False(t, v.Valid)
+	assert.Equal(t, []string{IssueNoQuestions}, codes(v))
+
+	survey := Survey{
+		Title:       "Feedback",
+		Description: "Tell us",
+		Pages:       []Page{{ID: "p1", Title: "About you"}, {ID: "p2"}},
+		Questions: []Question{
+			{ID: "member", Type: QuestionBoolean, Label: "Member?", Page: "p1"},
+			{ID: "plan", Type: QuestionSingleChoice, Label: "Plan", Options: []string{"Basic", "Pro"}, Page: "p1"},
+			{ID: "rating", Type: QuestionRating, Label: "Rating", Page: "p2"},
+		},
+		Logic: []LogicRule{
+			{Question: "member", Operator: "eq", Value: json.RawMessage(`true`), Action: LogicShowQuestion, Target: "plan"},
+			{Question: "plan", Operator: "eq", Value: json.RawMessage(`"Pro"`), Action: LogicSkipToPage, Target: "p2"},
+		},
+		Translations: map[string]SurveyTranslation{"fr": {
+			Title:       "Avis",
+			Description: "Dites-nous",
+			Pages:       map[string]string{"p1": "À propos de vous"},
+			Questions: map[string]QuestionTranslation{
+				"member": {Label: "Membre ?"},
+				"plan":   {Label: "Formule", Options: []string{"Basique", "Pro"}},
+				"rating": {Label: "Note"},
+			},
+		}},
+	}
+	v = validateSurvey(survey)
+	assert.True(t, v.Valid, v.Issues)
+	assert.Empty(t, v.Issues)
+
+	// Every issue is reported at once
+	survey.Questions = append(survey.Questions[:2:2], Question{ID: "extra", Type: QuestionText, Label: "Extra"})
+	survey.Pages = append(survey.Pages, Page{ID: "p3"})
+	survey.Logic = append(survey.Logic,
+		LogicRule{Question: "gone", Operator: "eq", Value: json.RawMessage(`1`), Action: LogicHideQuestion, Target: "plan"},
+		LogicRule{Question: "plan", Operator: "eq", Value: json.RawMessage(`"Gold"`), Action: LogicShowQuestion, Target: "member"},
+		LogicRule{Question: "plan", Operator: "ne", Value: json.RawMessage(`"Basic"`), Action: LogicSkipToPage, Target: "p9"},
+	)
+	v = validateSurvey(survey)
+	assert.False(t, v.Valid)
+	assert.Equal(t, []string{
+		IssueQuestionWithoutPage,
+		IssueEmptyPage, IssueEmptyPage,
+		IssueBrokenLogic, IssueBrokenLogic, IssueBrokenLogic, IssueBrokenLogic,
+		IssueMissingTranslation,
+	}, codes(v))
+}
+
+func TestSurveyWizard(t *testing.T) {
+	setupTestDB()
+	defer testDB.Close()
+
+	router := setupTestRouter()
+	send := func(method, url, body string) (*httptest.ResponseRecorder, Survey) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, url, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		var response struct {
+			Data Survey `json:"data"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		return w, response.Data
+	}
+
+	w, draft := send("POST", "/api/surveys", `{"survey":{"title":"Onboarding","description":"First week","status":"draft"}}`)
+	assert.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	base := fmt.Sprintf("/api/surveys/%d", draft.ID)
+
+	// Publishing an empty draft reports why it can't be
+	w, _ = send("POST", base+"/publish", "")
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Contains(t, w.Body.String(), CodeSurveyInvalid)
+	assert.Contains(t, w.Body.String(), IssueNoQuestions)
+
+	w, survey := send("PUT", base+"/pages", `{"pages":[{"id":"intro","title":"Intro"},{"id":"detail"}]}`)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Len(t, survey.Pages, 2)
+	w, _ = send("PUT", base+"/pages", `{"pages":[{"id":"a"},{"id":"a"}]}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+	w, _ = send("POST", base+"/questions", `{"question":{"id":"happy","type":"boolean","label":"Happy?","page":"intro"}}`)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	w, _ = send("POST", base+"/questions", `{"question":{"id":"why","type":"text","label":"Why not?","page":"detail"}}`)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	w, _ = send("POST", base+"/questions", `{"question":{"id":"why","type":"text","label":"Again"}}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	w, _ = send("POST", base+"/questions", `{"question":{"id":"tmp","type":"text","label":"Temporary","page":"detail"}}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	w, survey = send("DELETE", base+"/questions/tmp", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Len(t, survey.Questions, 2)
+	w, _ = send("DELETE", base+"/questions/tmp", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w, _ = send("PUT", base+"/logic", `{"logic":[{"question":"happy","operator":"eq","value":false,"action":"show_question","target":"why"}]}`)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	w, _ = send("PUT", base+"/logic", `{"logic":[{"question":"happy","operator":"like","action":"explode"}]}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+	w, _ = send("PUT", base+"/translations", `{"translations":{"fr":{"title":"Intégration","questions":{"happy":{"label":"Content ?"}}}}}`)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	w, _ = send("PUT", base+"/translations", `{"translations":{"French":{}}}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+	// Missing translations block publication until they are filled in
+	w = httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", base+"/validation", nil)
+	router.ServeHTTP(w, req)
+	var validation struct {
+		Data SurveyValidation `json:"data"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &validation)
+	assert.False(t, validation.Data.Valid)
+	assert.Len(t, validation.Data.Issues, 3)
+
+	w, _ = send("PUT", base+"/translations", `{"translations":{"fr":{"title":"Intégration","description":"Première semaine",
+		"pages":{"intro":"Introduction"},"questions":{"happy":{"label":"Content ?"},"why":{"label":"Pourquoi ?"}}}}}`)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	w, survey = send("POST", base+"/publish", "")
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, SurveyStatusPublished, survey.Status)
+	assert.Len(t, survey.Logic, 1)
+	assert.Equal(t, "Pourquoi ?", survey.Translations["fr"].Questions["why"].Label)
+
+	// Published surveys are no longer edited through the wizard
+	w, _ = send("POST", base+"/questions", `{"question":{"id":"late","type":"text","label":"Late"}}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	w, _ = send("POST", base+"/publish", "")
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+}